@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hootsuite/atlantis/server"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// LocksCmd lists the locks held by a running Atlantis server. The locks are
+// in the server's database, which only one process can open, so they're
+// listed through its API rather than read directly.
+type LocksCmd struct{}
+
+// Init returns the runnable cobra command.
+func (l *LocksCmd) Init() *cobra.Command {
+	var atlantisURL string
+	c := &cobra.Command{
+		Use:   "locks",
+		Short: "List the locks held by a running Atlantis server",
+		Long: "List the projects locked by plans that haven't been applied yet, oldest first, with the pull request and user holding each lock.\n" +
+			"Delete a stuck lock with: curl -X DELETE '<atlantis-url>/locks?id=<id>'",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return l.list(cmd.OutOrStdout(), atlantisURL)
+		},
+	}
+	c.Flags().StringVar(&atlantisURL, AtlantisURLFlag, "http://localhost:4141", "URL of the Atlantis server.")
+	return c
+}
+
+func (l *LocksCmd) list(w io.Writer, atlantisURL string) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(atlantisURL, "/") + "/locks")
+	if err != nil {
+		return errors.Wrap(err, "listing locks")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("listing locks: server responded with status %d", resp.StatusCode)
+	}
+	var locks []server.LockData
+	if err := json.NewDecoder(resp.Body).Decode(&locks); err != nil {
+		return errors.Wrap(err, "parsing locks")
+	}
+	if len(locks) == 0 {
+		fmt.Fprintln(w, "No locks are held.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "AGE\tREPO\tPATH\tENVIRONMENT\tPULL\tUSER\tID")
+	for _, lock := range locks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t#%d\t%s\t%s\n", lock.Age, lock.Repo, lock.Path, lock.Environment, lock.PullNum, lock.User, lock.ID)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,59 @@
+package cmd_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hootsuite/atlantis/cmd"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestLocksCmd(t *testing.T) {
+	t.Log("locks should list the server's locks in a table")
+	atlantis := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "GET", r.Method)
+		Equals(t, "/locks", r.URL.Path)
+		w.Write([]byte(`[{"id":"owner/repo/./production","repo":"owner/repo","path":".","environment":"production","pull_num":1,"user":"alice","age":"26h3m5s"}]`)) // nolint: errcheck
+	}))
+	defer atlantis.Close()
+
+	c := (&cmd.LocksCmd{}).Init()
+	out := new(bytes.Buffer)
+	c.SetOutput(out)
+	c.SetArgs([]string{"--atlantis-url", atlantis.URL + "/"})
+	Ok(t, c.Execute())
+	Equals(t, "AGE      REPO        PATH  ENVIRONMENT  PULL  USER   ID\n"+
+		"26h3m5s  owner/repo  .     production   #1    alice  owner/repo/./production\n", out.String())
+}
+
+func TestLocksCmd_NoLocks(t *testing.T) {
+	t.Log("locks should say when no locks are held")
+	atlantis := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`)) // nolint: errcheck
+	}))
+	defer atlantis.Close()
+
+	c := (&cmd.LocksCmd{}).Init()
+	out := new(bytes.Buffer)
+	c.SetOutput(out)
+	c.SetArgs([]string{"--atlantis-url", atlantis.URL})
+	Ok(t, c.Execute())
+	Equals(t, "No locks are held.\n", out.String())
+}
+
+func TestLocksCmd_ServerError(t *testing.T) {
+	t.Log("a server error should be returned")
+	atlantis := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer atlantis.Close()
+
+	c := (&cmd.LocksCmd{}).Init()
+	c.SetOutput(new(bytes.Buffer))
+	c.SetArgs([]string{"--atlantis-url", atlantis.URL})
+	err := c.Execute()
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "listing locks: server responded with status 503", err.Error())
+}
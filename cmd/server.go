@@ -2,42 +2,118 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"syscall"
 
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/hootsuite/atlantis/server"
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/tfc"
 	"github.com/mitchellh/go-homedir"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
 )
 
 // To add a new flag you must:
 // 1. Add a const with the flag name (in alphabetic order).
 // 2. Add a new field to server.Config and set the mapstructure tag equal to the flag name.
-// 3. Add your flag's description etc. to the stringFlags, intFlags, or boolFlags slices.
+// 3. Add your flag's description etc. to the stringFlags, intFlags, boolFlags or durationFlags slices.
 const (
 	AtlantisURLFlag             = "atlantis-url"
+	AllowFmtPushFlag            = "allow-fmt-push"
+	AllowedEgressHostsFlag      = "allowed-egress-hosts"
+	AllowedReposFlag            = "allowed-repos"
+	ApplyFailFastFlag           = "apply-fail-fast"
+	ApplyRoleARNFlag            = "apply-role-arn"
+	ApplyRollupStatusFlag       = "apply-rollup-status"
+	ApprovalCACertFlag          = "approval-ca-cert"
+	ApprovalClientCertFlag      = "approval-client-cert"
+	ApprovalClientKeyFlag       = "approval-client-key"
+	ApprovalJWTPublicKeyFlag    = "approval-jwt-public-key"
+	ApprovalSigningSecretFlag   = "approval-signing-secret"
 	ApprovalURLFlag             = "approval-url"
+	AuditLogPathFlag            = "audit-log-path"
+	AWSAssumeRoleARNFlag        = "aws-assume-role-arn"
+	AWSCredentialsPathFlag      = "aws-credentials-path"
+	AWSProfileFlag              = "aws-profile"
+	CommandThrottleFlag         = "command-throttle-window"
+	CommentFormatFlag           = "comment-format"
 	ConfigFlag                  = "config"
 	DataDirFlag                 = "data-dir"
+	DestroyConfirmationEnvsFlag = "destroy-confirmation-envs"
+	DestroyWarningFlag          = "destroy-warning"
+	DestroyWarningIgnoreFlag    = "destroy-warning-ignore-types"
+	DryRunFlag                  = "dry-run"
+	EmergencyApplyUsersFlag     = "emergency-apply-users"
+	ExternalApprovalQuorumFlag  = "external-approval-quorum"
+	ExternalApprovalRetriesFlag = "external-approval-retries"
+	ExternalApprovalTimeoutFlag = "external-approval-timeout"
+	FmtPushUsersFlag            = "fmt-push-users"
+	ForkPolicyFlag              = "fork-policy"
+	GHAppIDFlag                 = "gh-app-id"
+	GHAppInstallationIDFlag     = "gh-app-installation-id"
+	GHAppKeyFileFlag            = "gh-app-key-file"
+	GHCACertFlag                = "gh-ca-cert"
 	GHHostnameFlag              = "gh-hostname"
+	GHRateLimitWaitFlag         = "gh-rate-limit-wait"
 	GHTokenFlag                 = "gh-token"
 	GHUserFlag                  = "gh-user"
 	GHWebHookSecret             = "gh-webhook-secret"
+	GitlabCACertFlag            = "gitlab-ca-cert"
 	GitlabHostnameFlag          = "gitlab-hostname"
 	GitlabTokenFlag             = "gitlab-token"
 	GitlabUserFlag              = "gitlab-user"
 	GitlabWebHookSecret         = "gitlab-webhook-secret"
+	HealthzPathFlag             = "healthz-path"
+	JiraTokenFlag               = "jira-token"
+	JiraURLFlag                 = "jira-url"
+	JiraUserFlag                = "jira-user"
 	LogLevelFlag                = "log-level"
+	ParallelApplyLimitFlag      = "parallel-apply-limit"
+	PlanDependentsFlag          = "plan-dependents"
+	PlanEncryptionKeyFlag       = "plan-encryption-key"
+	PlanExportTTLFlag           = "plan-export-ttl"
+	PlanExportUsersFlag         = "plan-export-users"
+	PlanRoleARNFlag             = "plan-role-arn"
+	MaxConcurrentCommandsFlag   = "max-concurrent-commands"
+	MaxPlanAgeFlag              = "max-plan-age"
+	MaxProjectsPerCommandFlag   = "max-projects-per-command"
+	MaxQueuedCommandsFlag       = "max-queued-commands"
+	MetricsPortFlag             = "metrics-port"
+	NoSelfApprovalFlag          = "no-self-approval"
+	OTLPEndpointFlag            = "otlp-endpoint"
 	PortFlag                    = "port"
+	PreviousCommentsFlag        = "previous-comments"
+	ReadyzPathFlag              = "readyz-path"
+	RepoAllowlistFlag           = "repo-allowlist"
+	RepoAllowlistCommentFlag    = "repo-allowlist-comment"
 	RequireApprovalFlag         = "require-approval"
 	RequireExternalApprovalFlag = "require-external-approval"
+	RequiredApproversFlag       = "required-approvers"
+	SlackChannelFlag            = "slack-channel"
+	SlackTokenFlag              = "slack-token"
+	StartupVCSCheckFlag         = "startup-vcs-check"
+	TFDistributionFlag          = "terraform-distribution"
+	TFNotFoundMessageFlag       = "terraform-not-found-message"
+	TFCAddressFlag              = "tfc-address"
+	TFCOrganizationFlag         = "tfc-organization"
+	TFCTokenFlag                = "tfc-token"
 	EnvDetectionWorkflow        = "environment-detection-workflow"
 	GitFlowEnvDir               = "gitflow-environment-dir"
 	GitFlowEnvBranchMap         = "gitflow-environment-branch-map"
+	WebhookConcurrencyFlag      = "webhook-concurrency"
+	WebhookSendTimeoutFlag      = "webhook-send-timeout"
+	WorkspaceTTLFlag            = "workspace-ttl"
 )
 
 var stringFlags = []stringFlag{
@@ -46,12 +122,39 @@ var stringFlags = []stringFlag{
 		description: "URL that Atlantis can be reached at. Defaults to http://$(hostname):$port where $port is from --" + PortFlag + ".",
 	},
 	{
-		name:        ApprovalURLFlag,
-		description: "URL for approval endpoint.",
+		name: AuditLogPathFlag,
+		description: "File to append an audit entry to, as a line of JSON, for every apply with who ran it, what it applied, its approvals and its result." +
+			" If not set, applies aren't audited.",
 	},
 	{
-		name:        ConfigFlag,
-		description: "Path to config file.",
+		name:        ApprovalCACertFlag,
+		description: "Path to PEM encoded CA certificates to verify the approval services' certificates with instead of the system's.",
+	},
+	{
+		name:        ApprovalClientCertFlag,
+		description: "Path to a PEM encoded client certificate to authenticate to the approval services with, for mutual TLS. Requires --" + ApprovalClientKeyFlag + ".",
+	},
+	{
+		name:        ApprovalClientKeyFlag,
+		description: "Path to the PEM encoded private key of --" + ApprovalClientCertFlag + ".",
+	},
+	{
+		name: ApprovalJWTPublicKeyFlag,
+		description: "Path to a PEM encoded RSA or ECDSA public key. If set, the approval endpoint must respond with a JWT signed by its private key," +
+			" whose claims say whether the pull request is approved, by whom and until when, instead of plain JSON.",
+	},
+	{
+		name: ApprovalSigningSecretFlag,
+		description: "Optional secret to sign approval requests with so the approval services can verify they came from Atlantis." +
+			" The signature is an HMAC-SHA256 of the body in the X-Atlantis-Signature header, ex. sha256=<hex>. " +
+			"Can also be specified via the ATLANTIS_APPROVAL_SIGNING_SECRET environment variable.",
+		env: "ATLANTIS_APPROVAL_SIGNING_SECRET",
+	},
+	{
+		name: PlanEncryptionKeyFlag,
+		description: "Optional base64 encoded 32 byte key to encrypt plan files at rest with, ex. from \"openssl rand -base64 32\"." +
+			" Plans are only decrypted while they're applied. Can also be specified via the ATLANTIS_PLAN_ENCRYPTION_KEY environment variable.",
+		env: "ATLANTIS_PLAN_ENCRYPTION_KEY",
 	},
 	{
 		name:        DataDirFlag,
@@ -63,6 +166,15 @@ var stringFlags = []stringFlag{
 		description: "Hostname of your Github Enterprise installation. If using github.com, no need to set.",
 		value:       "github.com",
 	},
+	{
+		name: GHAppKeyFileFlag,
+		description: "Path to the PEM encoded private key of the GitHub App that Atlantis authenticates as instead of --" + GHUserFlag + "/--" + GHTokenFlag + "." +
+			" Requires --" + GHAppIDFlag + " and --" + GHAppInstallationIDFlag + ".",
+	},
+	{
+		name:        GHCACertFlag,
+		description: "Path to PEM encoded CA certificates to verify your GitHub Enterprise installation's certificate with, in addition to the system's.",
+	},
 	{
 		name:        GHUserFlag,
 		description: "GitHub username of API user.",
@@ -84,6 +196,10 @@ var stringFlags = []stringFlag{
 		description: "Hostname of your GitLab Enterprise installation. If using gitlab.com, no need to set.",
 		value:       "gitlab.com",
 	},
+	{
+		name:        GitlabCACertFlag,
+		description: "Path to PEM encoded CA certificates to verify your GitLab Enterprise installation's certificate with, in addition to the system's.",
+	},
 	{
 		name:        GitlabUserFlag,
 		description: "GitLab username of API user.",
@@ -100,6 +216,44 @@ var stringFlags = []stringFlag{
 			"Can also be specified via the ATLANTIS_GITLAB_WEBHOOK_SECRET environment variable.",
 		env: "ATLANTIS_GITLAB_WEBHOOK_SECRET",
 	},
+	{
+		name: JiraURLFlag,
+		description: "URL of your Jira, ex. https://example.atlassian.net. If set, the change ticket referenced by \"atlantis apply --ticket\"" +
+			" is looked up in Jira and commented on the pull request.",
+	},
+	{
+		name:        JiraUserFlag,
+		description: "Jira user to authenticate as. For Jira Cloud this is the user's email.",
+	},
+	{
+		name:        JiraTokenFlag,
+		description: "Jira API token of --" + JiraUserFlag + ". Can also be specified via the ATLANTIS_JIRA_TOKEN environment variable.",
+		env:         "ATLANTIS_JIRA_TOKEN",
+	},
+	{
+		name:        SlackTokenFlag,
+		description: "Slack API token for webhooks of kind slack and --" + SlackChannelFlag + ". Can also be specified via the ATLANTIS_SLACK_TOKEN environment variable.",
+		env:         "ATLANTIS_SLACK_TOKEN",
+	},
+	{
+		name:        SlackChannelFlag,
+		description: "Slack channel to post the result of every apply to. Requires --" + SlackTokenFlag + ".",
+	},
+	{
+		name:        TFCAddressFlag,
+		description: "Address of Terraform Cloud or your Terraform Enterprise.",
+		value:       tfc.DefaultAddress,
+	},
+	{
+		name:        TFCOrganizationFlag,
+		description: "Terraform Cloud organization that the workspaces in the tfc-workspaces config are in.",
+	},
+	{
+		name: TFCTokenFlag,
+		description: "Terraform Cloud API token. If set, plans and applies of projects with a remote backend or cloud block run in Terraform Cloud through its API." +
+			" Can also be specified via the ATLANTIS_TFC_TOKEN environment variable.",
+		env: "ATLANTIS_TFC_TOKEN",
+	},
 	{
 		name: GitFlowEnvDir,
 		description: "Directory relative to the repo root which holds the environment configuration. Leave empty to reference the root dir" +
@@ -118,8 +272,125 @@ var stringFlags = []stringFlag{
 		description: "Log level. Either debug, info, warn, or error.",
 		value:       "info",
 	},
+	{
+		name: StartupVCSCheckFlag,
+		description: "Whether to check the VCS credentials on startup. Either fail, to stop Atlantis from starting if a check fails," +
+			" warn, to log failed checks, or skip.",
+		value: "skip",
+	},
+	{
+		name:        OTLPEndpointFlag,
+		description: "URL of an OpenTelemetry collector to send traces of each command to over OTLP/HTTP, ex. http://localhost:4318. If not set, tracing is disabled.",
+	},
+	{
+		name: PlanRoleARNFlag,
+		description: "AWS role for terraform to assume for every command except apply when running in ECS, ex. a read-only role." +
+			" If not set, the task's role is used.",
+	},
+	{
+		name: ApplyRoleARNFlag,
+		description: "AWS role for terraform to assume for apply when running in ECS. If not set, the task's role is used." +
+			" Older AWS providers need AWS_SDK_LOAD_CONFIG=1 to assume a role.",
+	},
+	{
+		name: AWSAssumeRoleARNFlag,
+		description: "AWS role that Atlantis assumes with the task's role when running in ECS, writing the role's credentials for terraform instead, ex. one in another account." +
+			" Environments can assume their own role with aws-assume-roles in the config file. If not set, the task's role is written.",
+	},
+	{
+		name:        AWSCredentialsPathFlag,
+		description: "Where the credentials of the task's role are written for terraform when running in ECS. The AWS config file is written next to it.",
+		value:       "/home/atlantis/.aws/credentials",
+	},
+	{
+		name: AWSProfileFlag,
+		description: "Prefix of the profiles that each command's credentials are written to when running in ECS, ex. atlantis-plan-3." +
+			" Terraform is run with AWS_PROFILE set to the command's profile. Other profiles in --" + AWSCredentialsPathFlag + " are kept.",
+		value: "atlantis",
+	},
+	{
+		name: CommentFormatFlag,
+		description: "How command output is formatted in comments. Either markdown or plain. With plain, output isn't wrapped in code fences" +
+			" and logs aren't in collapsible sections, for integrations that can't render markdown.",
+		value: "markdown",
+	},
+	{
+		name: RepoAllowlistCommentFlag,
+		description: "Comment to post on a pull request the first time a command is ignored on it because its repo isn't in --" + RepoAllowlistFlag +
+			", ex. who to ask to set the repo up. If not set, those commands are ignored silently so it isn't revealed which repos are served.",
+	},
+	{
+		name: TFDistributionFlag,
+		description: "What to run in projects. Either terraform or terragrunt. With terragrunt, the terragrunt modules affected by the pull request" +
+			" are planned and applied in dependency order.",
+		value: "terraform",
+	},
+	{
+		name:        TFNotFoundMessageFlag,
+		description: "Extra text for the comment Atlantis posts when the version of terraform a command needs isn't installed, ex. who to ask to install it.",
+	},
+	{
+		name: ForkPolicyFlag,
+		description: "What to do with commands on pull requests from forks. Either ignore, plan-only or require-member-comment." +
+			" plan-only runs every command except apply. require-member-comment only runs commands commented by a member of the repo who isn't the pull request's author.",
+		value: "plan-only",
+	},
+	{
+		name: PreviousCommentsFlag,
+		description: "What to do with the comments Atlantis posted for previous commands when it comments on a pull request again. Either keep, delete or update." +
+			" delete deletes the comments from previous runs of the same command in the same environment and update edits the comment from the last run of the same command in the same environment for the same projects.",
+		value: "keep",
+	},
+	{
+		name:        HealthzPathFlag,
+		description: "Path of the endpoint that responds with a 200 as long as Atlantis is serving requests. It isn't authenticated.",
+		value:       server.DefaultHealthzPath,
+	},
+	{
+		name: ReadyzPathFlag,
+		description: "Path of the endpoint that responds with a 200 once the VCS credentials and the data dirs have been checked and a 503 until then." +
+			" It isn't authenticated.",
+		value: server.DefaultReadyzPath,
+	},
 }
 var boolFlags = []boolFlag{
+	{
+		name: ApplyRollupStatusFlag,
+		description: "Set a commit status for each environment, ex. atlantis/apply-all/production, that's only successful once every project in the pull request has been applied in it," +
+			" so branch protection can require pull requests to be applied before they're merged.",
+		value: false,
+	},
+	{
+		name: ApplyFailFastFlag,
+		description: "Stop applying the projects of an apply that haven't started once one of them fails to apply." +
+			" Otherwise only the projects that depend on it aren't applied.",
+		value: false,
+	},
+	{
+		name:        AllowFmtPushFlag,
+		description: "Allow \"atlantis fmt --fix\" to commit and push formatting fixes to pull request branches. Only the users in --" + FmtPushUsersFlag + " can run it.",
+		value:       false,
+	},
+	{
+		name:        DestroyWarningFlag,
+		description: "Warn at the top of plan comments about the resources that the plan destroys, including ones it replaces.",
+		value:       false,
+	},
+	{
+		name:        DryRunFlag,
+		description: "Validate the config, print it with secrets redacted and exit without starting the server.",
+		value:       false,
+	},
+	{
+		name:        PlanDependentsFlag,
+		description: "Also plan the projects that use a module modified in the pull request, even if none of their own files were modified.",
+		value:       false,
+	},
+	{
+		name:        NoSelfApprovalFlag,
+		description: "Don't count approvals by a pull request's author or the user running apply towards --" + RequireApprovalFlag + ".",
+		value:       false,
+	},
 	{
 		name:        RequireApprovalFlag,
 		description: "Require pull requests to be \"Approved\" before allowing the apply command to be run.",
@@ -132,17 +403,169 @@ var boolFlags = []boolFlag{
 	},
 }
 var intFlags = []intFlag{
+	{
+		name:        GHAppIDFlag,
+		description: "ID of the GitHub App that Atlantis authenticates as. Requires --" + GHAppInstallationIDFlag + " and --" + GHAppKeyFileFlag + ".",
+		value:       0,
+	},
+	{
+		name:        GHAppInstallationIDFlag,
+		description: "ID of the installation of the GitHub App in --" + GHAppIDFlag + " whose repos Atlantis runs in.",
+		value:       0,
+	},
+	{
+		name:        ExternalApprovalQuorumFlag,
+		description: "How many of the services at --" + ApprovalURLFlag + " have to approve an apply. If 0, all of them have to.",
+		value:       0,
+	},
+	{
+		name: ExternalApprovalRetriesFlag,
+		description: "How many times to retry a request to the service at --" + ApprovalURLFlag + " that fails with a network error or a 5xx status." +
+			" Retries back off exponentially. Responses that aren't approvals aren't retried.",
+		value: 2,
+	},
 	{
 		name:        PortFlag,
 		description: "Port to bind to.",
 		value:       4141,
 	},
+	{
+		name: MetricsPortFlag,
+		description: "Port to serve /metrics on instead of --" + PortFlag + ", ex. to keep metrics off the port that the VCS host and users reach." +
+			" If 0, metrics are served on --" + PortFlag + ".",
+		value: 0,
+	},
+	{
+		name:        MaxConcurrentCommandsFlag,
+		description: "Maximum number of commands to run at the same time. Commands over the limit are queued. If 0, there's no limit.",
+		value:       0,
+	},
+	{
+		name: MaxProjectsPerCommandFlag,
+		description: "Maximum number of projects that a single plan or apply can run in. Commands over it fail and ask to be run in one project at a time with -p." +
+			" Can be overridden for repos with repo-max-projects-per-command in the config file. If 0, there's no limit.",
+		value: 0,
+	},
+	{
+		name:        MaxQueuedCommandsFlag,
+		description: "Maximum number of commands to queue when --" + MaxConcurrentCommandsFlag + " is reached. Commands over this are rejected with a 429 so the VCS host can retry them later.",
+		value:       100,
+	},
+	{
+		name: ParallelApplyLimitFlag,
+		description: "Maximum number of projects that a single apply applies at the same time. Comments still list the projects in the same order." +
+			" Terragrunt modules are still applied after the modules they depend on.",
+		value: 1,
+	},
+	{
+		name:        WebhookConcurrencyFlag,
+		description: "Maximum number of webhooks to send at the same time.",
+		value:       1,
+	},
+}
+var durationFlags = []durationFlag{
+	{
+		name: CommandThrottleFlag,
+		description: "Ignore a comment command if the same command was commented on the same pull request this long ago or less, ex. 30s." +
+			" Atlantis comments that the command was ignored. If 0, commands aren't throttled.",
+		value: 0,
+	},
+	{
+		name:        ExternalApprovalTimeoutFlag,
+		description: "How long to wait for the service at --" + ApprovalURLFlag + " to respond before failing the apply, ex. 10s.",
+		value:       10 * time.Second,
+	},
+	{
+		name: GHRateLimitWaitFlag,
+		description: "How long a GitHub comment or status that's rate limited waits in total for the rate limit to reset before it fails, ex. 5m." +
+			" If 0, they fail as soon as they're rate limited.",
+		value: 0,
+	},
+	{
+		name: MaxPlanAgeFlag,
+		description: "Reject applying plans that were planned longer ago than this, ex. 24h, since the infrastructure may have drifted." +
+			" If 0, plans can be applied however old they are.",
+		value: 0,
+	},
+	{
+		name:        PlanExportTTLFlag,
+		description: "How long the links to plans exported with \"atlantis plan --export\" work for, ex. 1h.",
+		value:       time.Hour,
+	},
+	{
+		name:        WebhookSendTimeoutFlag,
+		description: "How long to wait for a single webhook to be sent before giving up on it, ex. 10s. If 0, waits forever.",
+		value:       0,
+	},
+	{
+		name: WorkspaceTTLFlag,
+		description: "Delete workspaces that were last cloned this long ago or whose pull requests are closed, ex. 168h." +
+			" Locked workspaces and workspaces a command is running in aren't deleted. If 0, workspaces are only deleted when their pull requests are closed.",
+		value: 0,
+	},
 }
 
 var stringSetFlags = []stringSetFlag{
 	stringSetFlag{
-		name:        GitFlowEnvBranchMap,
-		description: "A list of environment to branch mappings in the form of prod:master",
+		name: AllowedEgressHostsFlag,
+		description: "Hosts that the external approval service and webhooks can be sent to, ex. approvals.example.com." +
+			" Can be glob patterns, ex. *.webhook.office.com. Slack webhooks need slack.com. If not set, every host is allowed.",
+	},
+	stringSetFlag{
+		name: AllowedReposFlag,
+		description: "Repos whose pull requests can be applied, ex. owner/repo, or with the re: prefix, regexes that match the whole repo name, ex. re:owner/payments-.*." +
+			" Other repos can still be planned but not have formatting fixes pushed to them. If not set, every repo can be applied.",
+	},
+	stringSetFlag{
+		name: ApprovalURLFlag,
+		description: "URLs of the external approval services. Can be more than one, ex. to require two independent services to approve." +
+			" See --" + ExternalApprovalQuorumFlag + ".",
+	},
+	stringSetFlag{
+		name: ConfigFlag,
+		description: "Path to config file or a directory of config files. Can be specified multiple times." +
+			" Values in later files override earlier ones and files in a directory are read in alphabetical order.",
+	},
+	stringSetFlag{
+		name: EmergencyApplyUsersFlag,
+		description: "Users that can run emergency applies with \"atlantis apply <env> --emergency --ticket <ticket>\" to bypass the change window." +
+			" The other apply requirements still apply.",
+	},
+	stringSetFlag{
+		name: DestroyConfirmationEnvsFlag,
+		description: "Environments whose plans can only be applied with \"atlantis apply <env> --destroy-ok\" if they destroy resources, ex. production." +
+			" Plans that don't destroy anything are applied as usual.",
+	},
+	stringSetFlag{
+		name: DestroyWarningIgnoreFlag,
+		description: "Resource types whose destruction --" + DestroyWarningFlag + " doesn't warn about, ex. null_resource." +
+			" Can be glob patterns, ex. random_*.",
+	},
+	stringSetFlag{
+		name:        FmtPushUsersFlag,
+		description: "Users that can run \"atlantis fmt --fix\" to push formatting fixes. Required if --" + AllowFmtPushFlag + " is set.",
+	},
+	stringSetFlag{
+		name: PlanExportUsersFlag,
+		description: "Users that can run \"atlantis plan --export\" to download the plan files." +
+			" If not set, plans can't be exported.",
+	},
+	stringSetFlag{
+		name: RepoAllowlistFlag,
+		description: "Repos that Atlantis runs commands for, ex. owner/repo. Can be glob patterns, ex. owner/*." +
+			" If not set, commands are run for every repo.",
+	},
+	stringSetFlag{
+		name: RequiredApproversFlag,
+		description: "Users, or GitHub teams as org/team-slug, that one of has to approve a pull request for --" + RequireApprovalFlag + "." +
+			" If not set, any approval is enough.",
+	},
+	stringSetFlag{
+		name: GitFlowEnvBranchMap,
+		description: "A list of environment to branch mappings in the form of prod:master." +
+			" The branch can be a glob, ex. staging:release/*, or a regex prefixed with re:, ex. rc:re:release/.*-rc[0-9]+." +
+			" A branch is mapped to the environments of the first pattern it matches." +
+			" If set, pull requests into a branch that isn't mapped can't be planned.",
 	},
 }
 
@@ -162,6 +585,11 @@ type boolFlag struct {
 	description string
 	value       bool
 }
+type durationFlag struct {
+	name        string
+	description string
+	value       time.Duration
+}
 type stringSetFlag struct {
 	name        string
 	description string
@@ -194,6 +622,12 @@ type ServerStarter interface {
 	Start() error
 }
 
+// ServerReloader is implemented by servers that can change some of their
+// config without restarting.
+type ServerReloader interface {
+	Reload(config server.Config) error
+}
+
 // NewServer returns the real Atlantis server object.
 func (d *DefaultServerCreator) NewServer(config server.Config) (ServerStarter, error) {
 	return server.NewServer(config)
@@ -214,6 +648,9 @@ Config file values are overridden by environment variables which in turn are ove
 			return s.preRun()
 		}),
 		RunE: s.withErrPrint(func(cmd *cobra.Command, args []string) error {
+			if s.Viper.GetBool(DryRunFlag) {
+				return s.dryRun(cmd.OutOrStdout())
+			}
 			return s.run()
 		}),
 	}
@@ -245,6 +682,12 @@ Config file values are overridden by environment variables which in turn are ove
 		s.Viper.BindPFlag(f.name, c.Flags().Lookup(f.name)) // nolint: errcheck
 	}
 
+	// Set duration flags.
+	for _, f := range durationFlags {
+		c.Flags().Duration(f.name, f.value, f.description)
+		s.Viper.BindPFlag(f.name, c.Flags().Lookup(f.name)) // nolint: errcheck
+	}
+
 	// Set stringsetflags flags.
 	for _, f := range stringSetFlags {
 		c.Flags().StringSlice(f.name, f.value, f.description)
@@ -255,52 +698,188 @@ Config file values are overridden by environment variables which in turn are ove
 }
 
 func (s *ServerCmd) preRun() error {
-	// If passed a config file then try and load it.
-	configFile := s.Viper.GetString(ConfigFlag)
-	if configFile != "" {
+	// If passed config files then try and load them. Each file is merged
+	// into the config from the files before it.
+	configFiles, err := expandConfigDirs(s.Viper.GetStringSlice(ConfigFlag))
+	if err != nil {
+		return err
+	}
+	for i, configFile := range configFiles {
 		s.Viper.SetConfigFile(configFile)
-		if err := s.Viper.ReadInConfig(); err != nil {
+		read := s.Viper.MergeInConfig
+		if i == 0 {
+			read = s.Viper.ReadInConfig
+		}
+		if err := read(); err != nil {
 			return errors.Wrapf(err, "invalid config: reading %s", configFile)
 		}
 	}
 	return nil
 }
 
+// expandConfigDirs replaces any directories in paths with the config files
+// inside them, in alphabetical order.
+func expandConfigDirs(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			// If the file doesn't exist we let viper return the error so
+			// it's the same as when reading a single file.
+			files = append(files, path)
+			continue
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid config: reading %s", path)
+		}
+		var dirFiles []string
+		for _, e := range entries {
+			ext := strings.TrimPrefix(filepath.Ext(e.Name()), ".")
+			if !e.IsDir() && stringInSlice(ext, viper.SupportedExts) {
+				dirFiles = append(dirFiles, filepath.Join(path, e.Name()))
+			}
+		}
+		sort.Strings(dirFiles)
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+func stringInSlice(s string, slice []string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *ServerCmd) run() error {
+	config, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// Config looks good. Start the server.
+	server, err := s.ServerCreator.NewServer(config)
+	if err != nil {
+		return errors.Wrap(err, "initializing server")
+	}
+	if reloader, ok := server.(ServerReloader); ok {
+		s.reloadOnSIGHUP(reloader)
+	}
+	return server.Start()
+}
+
+// dryRun validates the config and writes it to w, with secrets redacted, as
+// yaml that can be used as a config file.
+func (s *ServerCmd) dryRun(w io.Writer) error {
+	config, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(configValues(reflect.ValueOf(config.Redacted())))
+	if err != nil {
+		return errors.Wrap(err, "serializing config")
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// configValues returns the fields of the struct v by their mapstructure tags,
+// which are the flag names.
+func configValues(v reflect.Value) map[string]interface{} {
+	values := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("mapstructure")
+		if name == "" {
+			continue
+		}
+		values[name] = configValue(v.Field(i))
+	}
+	return values
+}
+
+func configValue(v reflect.Value) interface{} {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		return v.Interface().(time.Duration).String()
+	case v.Kind() == reflect.Struct:
+		return configValues(v)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		var elems []interface{}
+		for i := 0; i < v.Len(); i++ {
+			elems = append(elems, configValues(v.Index(i)))
+		}
+		return elems
+	default:
+		return v.Interface()
+	}
+}
+
+// loadConfig returns the config from the flags, environment and config files
+// that have been read into our viper.
+func (s *ServerCmd) loadConfig() (server.Config, error) {
 	var config server.Config
 	if err := s.Viper.Unmarshal(&config); err != nil {
-		return err
+		return config, err
 	}
 	if err := validate(config); err != nil {
-		return err
+		return config, err
 	}
 	if err := setAtlantisURL(&config); err != nil {
-		return err
+		return config, err
 	}
-	if err := setDataDir(&config); err != nil {
-		return err
+	if err := expandHomeDirs(&config); err != nil {
+		return config, err
 	}
 	trimAtSymbolFromUsers(&config)
+	return config, nil
+}
 
-	// Config looks good. Start the server.
-	server, err := s.ServerCreator.NewServer(config)
+// reloadOnSIGHUP re-reads the config files every time the process receives
+// SIGHUP and passes the new config to reloader. If the new config is
+// invalid, the error is printed and the server keeps its current config.
+func (s *ServerCmd) reloadOnSIGHUP(reloader ServerReloader) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			if err := s.reload(reloader); err != nil && !s.SilenceOutput {
+				fmt.Fprintf(os.Stderr, "\033[31mError: reloading config: %s\033[39m\n\n", err.Error())
+			}
+		}
+	}()
+}
+
+func (s *ServerCmd) reload(reloader ServerReloader) error {
+	if err := s.preRun(); err != nil {
+		return err
+	}
+	config, err := s.loadConfig()
 	if err != nil {
-		return errors.Wrap(err, "initializing server")
+		return err
 	}
-	return server.Start()
+	return reloader.Reload(config)
 }
 
+// minAdminTokenLength is the shortest token an admin can have, ex. 16 random
+// bytes in hex, so they can't be guessed.
+const minAdminTokenLength = 32
+
 func validate(config server.Config) error {
 	logLevel := config.LogLevel
 	if logLevel != "debug" && logLevel != "info" && logLevel != "warn" && logLevel != "error" {
 		return errors.New("invalid log level: not one of debug, info, warn, error")
 	}
-	vcsErr := fmt.Errorf("--%s/--%s or --%s/--%s must be set", GHUserFlag, GHTokenFlag, GitlabUserFlag, GitlabTokenFlag)
+	vcsErr := fmt.Errorf("--%s/--%s, --%s/--%s/--%s or --%s/--%s must be set", GHUserFlag, GHTokenFlag, GHAppIDFlag, GHAppInstallationIDFlag, GHAppKeyFileFlag, GitlabUserFlag, GitlabTokenFlag)
 
 	// The following combinations are valid.
 	// 1. github user and token
-	// 2. gitlab user and token
-	// 3. all 4 set
+	// 2. github app id, installation id and key file
+	// 3. gitlab user and token
+	// 4. one of the github ones and the gitlab one
 	// We validate using contradiction (I think).
 	if config.GithubUser != "" && config.GithubToken == "" || config.GithubToken != "" && config.GithubUser == "" {
 		return vcsErr
@@ -308,9 +887,24 @@ func validate(config server.Config) error {
 	if config.GitlabUser != "" && config.GitlabToken == "" || config.GitlabToken != "" && config.GitlabUser == "" {
 		return vcsErr
 	}
+	githubApp := config.GithubAppID != 0 || config.GithubAppInstallationID != 0 || config.GithubAppKeyFile != ""
+	if githubApp {
+		if config.GithubUser != "" {
+			return fmt.Errorf("--%s/--%s and --%s/--%s/--%s can't both be set: GitHub has to be authenticated with either a token or a GitHub App", GHUserFlag, GHTokenFlag, GHAppIDFlag, GHAppInstallationIDFlag, GHAppKeyFileFlag)
+		}
+		if config.GithubAppID < 0 {
+			return fmt.Errorf("invalid --%s: must not be negative", GHAppIDFlag)
+		}
+		if config.GithubAppInstallationID < 0 {
+			return fmt.Errorf("invalid --%s: must not be negative", GHAppInstallationIDFlag)
+		}
+		if config.GithubAppID == 0 || config.GithubAppInstallationID == 0 || config.GithubAppKeyFile == "" {
+			return fmt.Errorf("--%s, --%s and --%s must all be set to authenticate as a GitHub App", GHAppIDFlag, GHAppInstallationIDFlag, GHAppKeyFileFlag)
+		}
+	}
 	// At this point, we know that there can't be a single user/token without
 	// its pair, but we haven't checked if any user/token is set at all.
-	if config.GithubUser == "" && config.GitlabUser == "" {
+	if config.GithubUser == "" && !githubApp && config.GitlabUser == "" {
 		return vcsErr
 	}
 
@@ -320,17 +914,173 @@ func validate(config server.Config) error {
 		return errors.New("invalid env detection workflow: not one of modifiedfiles, gitflow")
 	}
 
-	// Check if GitFlowEnvDirMapping has the correct syntax
-	sep := regexp.MustCompile(":")
-	for _, val := range config.GitflowEnvBranchMapping {
-		if len(sep.FindAllStringIndex(val, -1)) != 1 {
-			return fmt.Errorf("Invalid GitflowEnvBranchMapping argument %s. Must be env:branch", val)
+	previousComments := config.PreviousComments
+	if previousComments != "keep" && previousComments != "delete" && previousComments != "update" {
+		return fmt.Errorf("invalid --%s: not one of keep, delete, update", PreviousCommentsFlag)
+	}
+
+	if !strings.HasPrefix(config.HealthzPath, "/") {
+		return fmt.Errorf("invalid --%s: must start with /", HealthzPathFlag)
+	}
+	if !strings.HasPrefix(config.ReadyzPath, "/") {
+		return fmt.Errorf("invalid --%s: must start with /", ReadyzPathFlag)
+	}
+	if config.HealthzPath == config.ReadyzPath {
+		return fmt.Errorf("invalid --%s: must be different from --%s", ReadyzPathFlag, HealthzPathFlag)
+	}
+
+	forkPolicy := config.ForkPolicy
+	if forkPolicy != "ignore" && forkPolicy != "plan-only" && forkPolicy != "require-member-comment" {
+		return fmt.Errorf("invalid --%s: not one of ignore, plan-only, require-member-comment", ForkPolicyFlag)
+	}
+
+	commentFormat := config.CommentFormat
+	if commentFormat != "markdown" && commentFormat != "plain" {
+		return fmt.Errorf("invalid --%s: not one of markdown, plain", CommentFormatFlag)
+	}
+
+	distribution := config.TerraformDistribution
+	if distribution != "terraform" && distribution != "terragrunt" {
+		return fmt.Errorf("invalid --%s: not one of terraform, terragrunt", TFDistributionFlag)
+	}
+
+	startupVCSCheck := config.StartupVCSCheck
+	if startupVCSCheck != "fail" && startupVCSCheck != "warn" && startupVCSCheck != "skip" {
+		return fmt.Errorf("invalid --%s: not one of fail, warn, skip", StartupVCSCheckFlag)
+	}
+
+	if config.MaxConcurrentCommands < 0 {
+		return fmt.Errorf("invalid --%s: must not be negative", MaxConcurrentCommandsFlag)
+	}
+	if config.MaxQueuedCommands < 0 {
+		return fmt.Errorf("invalid --%s: must not be negative", MaxQueuedCommandsFlag)
+	}
+	if config.MaxProjectsPerCommand < 0 {
+		return fmt.Errorf("invalid --%s: must not be negative", MaxProjectsPerCommandFlag)
+	}
+	if config.ParallelApplyLimit < 1 {
+		return fmt.Errorf("invalid --%s: must be at least 1", ParallelApplyLimitFlag)
+	}
+	if config.MetricsPort < 0 {
+		return fmt.Errorf("invalid --%s: must not be negative", MetricsPortFlag)
+	}
+	if config.MetricsPort != 0 && config.MetricsPort == config.Port {
+		return fmt.Errorf("invalid --%s: must be different from --%s", MetricsPortFlag, PortFlag)
+	}
+	if config.CommandThrottleWindow < 0 {
+		return fmt.Errorf("invalid --%s: must not be negative", CommandThrottleFlag)
+	}
+	if config.MaxPlanAge < 0 {
+		return fmt.Errorf("invalid --%s: must not be negative", MaxPlanAgeFlag)
+	}
+	if config.GithubRateLimitWait < 0 {
+		return fmt.Errorf("invalid --%s: must not be negative", GHRateLimitWaitFlag)
+	}
+	if config.WorkspaceTTL < 0 {
+		return fmt.Errorf("invalid --%s: must not be negative", WorkspaceTTLFlag)
+	}
+	if config.ExternalApprovalRetries < 0 {
+		return fmt.Errorf("invalid --%s: must not be negative", ExternalApprovalRetriesFlag)
+	}
+	if config.ExternalApprovalTimeout <= 0 {
+		return fmt.Errorf("invalid --%s: must be positive", ExternalApprovalTimeoutFlag)
+	}
+
+	if config.AWSProfile == "" || strings.ContainsAny(config.AWSProfile, "[] \t\n") {
+		return fmt.Errorf("invalid --%s: must not be empty or contain brackets or whitespace", AWSProfileFlag)
+	}
+	if config.PlanRoleARN != "" && !strings.HasPrefix(config.PlanRoleARN, "arn:") {
+		return fmt.Errorf("invalid --%s: not an ARN", PlanRoleARNFlag)
+	}
+	if config.ApplyRoleARN != "" && !strings.HasPrefix(config.ApplyRoleARN, "arn:") {
+		return fmt.Errorf("invalid --%s: not an ARN", ApplyRoleARNFlag)
+	}
+	if config.AWSAssumeRoleARN != "" && !strings.HasPrefix(config.AWSAssumeRoleARN, "arn:") {
+		return fmt.Errorf("invalid --%s: not an ARN", AWSAssumeRoleARNFlag)
+	}
+	for env, arn := range config.AWSAssumeRoles {
+		if !strings.HasPrefix(arn, "arn:") {
+			return fmt.Errorf("invalid aws-assume-roles for environment %q: %q is not an ARN", env, arn)
 		}
 	}
 
-	if config.RequireExternalApproval && config.ApprovalURL == "" {
+	// Admins are named in the freeze and logs so each needs their own token.
+	var admins []string
+	for name := range config.AdminTokens {
+		admins = append(admins, name)
+	}
+	sort.Strings(admins)
+	adminsByToken := make(map[string]string)
+	for _, name := range admins {
+		token := config.AdminTokens[name]
+		if len(token) < minAdminTokenLength {
+			return fmt.Errorf("invalid admin-tokens for %q: must be at least %d characters", name, minAdminTokenLength)
+		}
+		if other, ok := adminsByToken[token]; ok {
+			return fmt.Errorf("invalid admin-tokens for %q: same token as %q", name, other)
+		}
+		adminsByToken[token] = name
+	}
+
+	if config.ExternalApprovalQuorum < 0 {
+		return fmt.Errorf("invalid --%s: must not be negative", ExternalApprovalQuorumFlag)
+	}
+	if config.ExternalApprovalQuorum > len(config.ApprovalURLs) {
+		return fmt.Errorf("invalid --%s: can't be more than the %d urls in --%s", ExternalApprovalQuorumFlag, len(config.ApprovalURLs), ApprovalURLFlag)
+	}
+	// Approvals can also be required by only some environments, which the
+	// approval flags then apply to.
+	requireApproval, requireExternalApproval := config.RequireApproval, config.RequireExternalApproval
+	var approvalEnvs []string
+	for env := range config.EnvironmentApprovals {
+		approvalEnvs = append(approvalEnvs, env)
+	}
+	sort.Strings(approvalEnvs)
+	for _, env := range approvalEnvs {
+		approval, err := events.ParseEnvApproval(config.EnvironmentApprovals[env])
+		if err != nil {
+			return errors.Wrapf(err, "invalid environment-approvals for environment %q", env)
+		}
+		requireApproval = requireApproval || approval.Internal
+		requireExternalApproval = requireExternalApproval || approval.External
+	}
+	if config.NoSelfApproval && !requireApproval {
+		return fmt.Errorf("--%s requires --%s to be set", NoSelfApprovalFlag, RequireApprovalFlag)
+	}
+	if len(config.RequiredApprovers) > 0 && !requireApproval {
+		return fmt.Errorf("--%s requires --%s to be set", RequiredApproversFlag, RequireApprovalFlag)
+	}
+	if requireExternalApproval && len(config.ApprovalURLs) == 0 {
 		return fmt.Errorf("--%s requires --%s to be set", RequireApprovalFlag, ApprovalURLFlag)
 	}
+	if (config.ApprovalClientCert == "") != (config.ApprovalClientKey == "") {
+		return fmt.Errorf("--%s and --%s must be set together", ApprovalClientCertFlag, ApprovalClientKeyFlag)
+	}
+	if config.ApprovalJWTPublicKey != "" && !requireExternalApproval {
+		return fmt.Errorf("--%s requires --%s to be set", ApprovalJWTPublicKeyFlag, RequireExternalApprovalFlag)
+	}
+	if config.JiraURL != "" && (config.JiraUser == "" || config.JiraToken == "") {
+		return fmt.Errorf("--%s requires --%s and --%s to be set", JiraURLFlag, JiraUserFlag, JiraTokenFlag)
+	}
+	if config.SlackChannel != "" && config.SlackToken == "" {
+		return fmt.Errorf("--%s requires --%s to be set", SlackChannelFlag, SlackTokenFlag)
+	}
+	if config.TFCToken != "" && config.TFCOrganization == "" {
+		return fmt.Errorf("--%s requires --%s to be set", TFCTokenFlag, TFCOrganizationFlag)
+	}
+	if config.AllowFmtPush && len(config.FmtPushUsers) == 0 {
+		return fmt.Errorf("--%s requires --%s to be set", AllowFmtPushFlag, FmtPushUsersFlag)
+	}
+	if len(config.DestroyWarningIgnoreTypes) > 0 && !config.DestroyWarning {
+		return fmt.Errorf("--%s requires --%s to be set", DestroyWarningIgnoreFlag, DestroyWarningFlag)
+	}
+
+	if config.RepoAllowlistComment != "" && len(config.RepoAllowlist) == 0 {
+		return fmt.Errorf("--%s requires --%s to be set", RepoAllowlistCommentFlag, RepoAllowlistFlag)
+	}
+	if len(config.PlanExportUsers) > 0 && config.PlanExportTTL <= 0 {
+		return fmt.Errorf("--%s must be greater than 0", PlanExportTTLFlag)
+	}
 
 	return nil
 }
@@ -347,16 +1097,18 @@ func setAtlantisURL(config *server.Config) error {
 	return nil
 }
 
-// setDataDir checks if ~ was used in data-dir and converts it to the actual
-// home directory. If we don't do this, we'll create a directory called "~"
-// instead of actually using home.
-func setDataDir(config *server.Config) error {
-	if strings.HasPrefix(config.DataDir, "~/") {
-		expanded, err := homedir.Expand(config.DataDir)
-		if err != nil {
-			return errors.Wrap(err, "determining home directory")
+// expandHomeDirs checks if ~ was used in data-dir or aws-credentials-path and
+// converts it to the actual home directory. If we don't do this, we'll create
+// a directory called "~" instead of actually using home.
+func expandHomeDirs(config *server.Config) error {
+	for _, path := range []*string{&config.DataDir, &config.AWSCredentialsPath} {
+		if strings.HasPrefix(*path, "~/") {
+			expanded, err := homedir.Expand(*path)
+			if err != nil {
+				return errors.Wrap(err, "determining home directory")
+			}
+			*path = expanded
 		}
-		config.DataDir = expanded
 	}
 	return nil
 }
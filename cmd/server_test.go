@@ -1,10 +1,14 @@
 package cmd_test
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/hootsuite/atlantis/cmd"
 	"github.com/hootsuite/atlantis/server"
@@ -12,6 +16,7 @@ import (
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
 )
 
 // passedConfig is set to whatever config ended up being passed to NewServer.
@@ -31,6 +36,27 @@ func (s *ServerStarterMock) Start() error {
 	return nil
 }
 
+// ReloadableServerCreatorMock creates servers that send every config they're
+// reloaded with to reloaded.
+type ReloadableServerCreatorMock struct {
+	reloaded chan server.Config
+}
+
+func (s *ReloadableServerCreatorMock) NewServer(config server.Config) (cmd.ServerStarter, error) {
+	passedConfig = config
+	return &ReloadableServerStarterMock{reloaded: s.reloaded}, nil
+}
+
+type ReloadableServerStarterMock struct {
+	ServerStarterMock
+	reloaded chan server.Config
+}
+
+func (s *ReloadableServerStarterMock) Reload(config server.Config) error {
+	s.reloaded <- config
+	return nil
+}
+
 func TestExecute_NoConfigFlag(t *testing.T) {
 	t.Log("If there is no config flag specified Execute should return nil.")
 	c := setup(map[string]interface{}{
@@ -102,8 +128,471 @@ func TestExecute_ValidateLogLevel(t *testing.T) {
 	Equals(t, "invalid log level: not one of debug, info, warn, error", err.Error())
 }
 
+func TestExecute_ValidatePreviousComments(t *testing.T) {
+	t.Log("Should validate what to do with previous comments.")
+	c := setup(map[string]interface{}{
+		cmd.PreviousCommentsFlag: "invalid",
+		cmd.GHUserFlag:           "user",
+		cmd.GHTokenFlag:          "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --previous-comments: not one of keep, delete, update", err.Error())
+}
+
+func TestExecute_ValidateHealthPaths(t *testing.T) {
+	cases := []struct {
+		Description string
+		Flags       map[string]interface{}
+		Expected    string
+	}{
+		{
+			"a relative healthz path",
+			map[string]interface{}{cmd.HealthzPathFlag: "healthz"},
+			"invalid --healthz-path: must start with /",
+		},
+		{
+			"a relative readyz path",
+			map[string]interface{}{cmd.ReadyzPathFlag: "readyz"},
+			"invalid --readyz-path: must start with /",
+		},
+		{
+			"the same path for both",
+			map[string]interface{}{cmd.HealthzPathFlag: "/status", cmd.ReadyzPathFlag: "/status"},
+			"invalid --readyz-path: must be different from --healthz-path",
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.Description + " should be an error")
+		c.Flags[cmd.GHUserFlag] = "user"
+		c.Flags[cmd.GHTokenFlag] = "token"
+		err := setup(c.Flags).Execute()
+		Assert(t, err != nil, "should be an error")
+		Equals(t, c.Expected, err.Error())
+	}
+}
+
+func TestExecute_ValidateParallelApplyLimit(t *testing.T) {
+	t.Log("Should validate the parallel apply limit.")
+	c := setup(map[string]interface{}{
+		cmd.ParallelApplyLimitFlag: 0,
+		cmd.GHUserFlag:             "user",
+		cmd.GHTokenFlag:            "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --parallel-apply-limit: must be at least 1", err.Error())
+}
+
+func TestExecute_ValidateMetricsPort(t *testing.T) {
+	t.Log("Should validate the metrics port.")
+	for port, expErr := range map[int]string{
+		-1:   "invalid --metrics-port: must not be negative",
+		4141: "invalid --metrics-port: must be different from --port",
+	} {
+		c := setup(map[string]interface{}{
+			cmd.MetricsPortFlag: port,
+			cmd.GHUserFlag:      "user",
+			cmd.GHTokenFlag:     "token",
+		})
+		err := c.Execute()
+		Assert(t, err != nil, "should be an error")
+		Equals(t, expErr, err.Error())
+	}
+}
+
+func TestExecute_ValidateForkPolicy(t *testing.T) {
+	t.Log("Should validate what to do with pull requests from forks.")
+	c := setup(map[string]interface{}{
+		cmd.ForkPolicyFlag: "invalid",
+		cmd.GHUserFlag:     "user",
+		cmd.GHTokenFlag:    "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --fork-policy: not one of ignore, plan-only, require-member-comment", err.Error())
+}
+
+func TestExecute_ValidateStartupVCSCheck(t *testing.T) {
+	t.Log("Should validate the startup VCS check.")
+	c := setup(map[string]interface{}{
+		cmd.StartupVCSCheckFlag: "invalid",
+		cmd.GHUserFlag:          "user",
+		cmd.GHTokenFlag:         "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --startup-vcs-check: not one of fail, warn, skip", err.Error())
+}
+
+func TestExecute_ValidateMaxConcurrentCommands(t *testing.T) {
+	t.Log("Should validate the max concurrent commands.")
+	c := setup(map[string]interface{}{
+		cmd.MaxConcurrentCommandsFlag: -1,
+		cmd.GHUserFlag:                "user",
+		cmd.GHTokenFlag:               "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --max-concurrent-commands: must not be negative", err.Error())
+}
+
+func TestExecute_ValidateMaxPlanAge(t *testing.T) {
+	t.Log("Should validate the max plan age.")
+	c := setup(map[string]interface{}{
+		cmd.MaxPlanAgeFlag: "-1h",
+		cmd.GHUserFlag:     "user",
+		cmd.GHTokenFlag:    "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --max-plan-age: must not be negative", err.Error())
+}
+
+func TestExecute_ValidateGHRateLimitWait(t *testing.T) {
+	t.Log("Should validate the GitHub rate limit wait.")
+	c := setup(map[string]interface{}{
+		cmd.GHRateLimitWaitFlag: "-1m",
+		cmd.GHUserFlag:          "user",
+		cmd.GHTokenFlag:         "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --gh-rate-limit-wait: must not be negative", err.Error())
+}
+
+func TestExecute_ValidateWorkspaceTTL(t *testing.T) {
+	t.Log("Should validate the workspace TTL.")
+	c := setup(map[string]interface{}{
+		cmd.WorkspaceTTLFlag: "-1h",
+		cmd.GHUserFlag:       "user",
+		cmd.GHTokenFlag:      "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --workspace-ttl: must not be negative", err.Error())
+}
+
+func TestExecute_ValidateMaxProjectsPerCommand(t *testing.T) {
+	t.Log("Should validate the max projects per command.")
+	c := setup(map[string]interface{}{
+		cmd.MaxProjectsPerCommandFlag: -1,
+		cmd.GHUserFlag:                "user",
+		cmd.GHTokenFlag:               "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --max-projects-per-command: must not be negative", err.Error())
+}
+
+func TestExecute_ValidateExternalApprovalQuorum(t *testing.T) {
+	t.Log("Should require the external approval quorum to be at most the number of approval urls.")
+	c := setup(map[string]interface{}{
+		cmd.ExternalApprovalQuorumFlag: 3,
+		cmd.ApprovalURLFlag:            []string{"https://approvals.example.com", "https://signoff.example.com"},
+		cmd.GHUserFlag:                 "user",
+		cmd.GHTokenFlag:                "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --external-approval-quorum: can't be more than the 2 urls in --approval-url", err.Error())
+}
+
+func TestExecute_ValidateExternalApprovalTimeout(t *testing.T) {
+	t.Log("Should require the external approval timeout to be positive.")
+	c := setup(map[string]interface{}{
+		cmd.ExternalApprovalTimeoutFlag: "0s",
+		cmd.GHUserFlag:                  "user",
+		cmd.GHTokenFlag:                 "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --external-approval-timeout: must be positive", err.Error())
+}
+
+func TestExecute_ValidateRoleARN(t *testing.T) {
+	t.Log("Should validate the plan and apply role ARNs.")
+	c := setup(map[string]interface{}{
+		cmd.ApplyRoleARNFlag: "not-an-arn",
+		cmd.GHUserFlag:       "user",
+		cmd.GHTokenFlag:      "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --apply-role-arn: not an ARN", err.Error())
+}
+
+func TestExecute_ValidateAWSAssumeRoles(t *testing.T) {
+	t.Log("Should validate the roles that Atlantis assumes.")
+	c := setup(map[string]interface{}{
+		cmd.AWSAssumeRoleARNFlag: "role/atlantis",
+		cmd.GHUserFlag:           "user",
+		cmd.GHTokenFlag:          "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --aws-assume-role-arn: not an ARN", err.Error())
+
+	tmpFile := tempFile(t, "aws-assume-roles:\n  production: role/production")
+	defer os.Remove(tmpFile) // nolint: errcheck
+	c = setup(map[string]interface{}{
+		cmd.ConfigFlag:  tmpFile,
+		cmd.GHUserFlag:  "user",
+		cmd.GHTokenFlag: "token",
+	})
+	err = c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, `invalid aws-assume-roles for environment "production": "role/production" is not an ARN`, err.Error())
+}
+
+func TestExecute_ValidateAdminTokens(t *testing.T) {
+	t.Log("Should require long admin tokens that aren't shared.")
+	tmpFile := tempFile(t, "admin-tokens:\n  alice: short")
+	defer os.Remove(tmpFile) // nolint: errcheck
+	c := setup(map[string]interface{}{
+		cmd.ConfigFlag:  tmpFile,
+		cmd.GHUserFlag:  "user",
+		cmd.GHTokenFlag: "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, `invalid admin-tokens for "alice": must be at least 32 characters`, err.Error())
+
+	token := strings.Repeat("a", 32)
+	sharedFile := tempFile(t, "admin-tokens:\n  alice: "+token+"\n  bob: "+token)
+	defer os.Remove(sharedFile) // nolint: errcheck
+	c = setup(map[string]interface{}{
+		cmd.ConfigFlag:  sharedFile,
+		cmd.GHUserFlag:  "user",
+		cmd.GHTokenFlag: "token",
+	})
+	err = c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, `invalid admin-tokens for "bob": same token as "alice"`, err.Error())
+}
+
+func TestExecute_ValidateAllowFmtPush(t *testing.T) {
+	t.Log("Should require the users that can push formatting fixes.")
+	c := setup(map[string]interface{}{
+		cmd.AllowFmtPushFlag: true,
+		cmd.GHUserFlag:       "user",
+		cmd.GHTokenFlag:      "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "--allow-fmt-push requires --fmt-push-users to be set", err.Error())
+}
+
+func TestExecute_ValidateRequiredApprovers(t *testing.T) {
+	t.Log("Should require approval if there are required approvers.")
+	c := setup(map[string]interface{}{
+		cmd.RequiredApproversFlag: []string{"alice"},
+		cmd.GHUserFlag:            "user",
+		cmd.GHTokenFlag:           "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "--required-approvers requires --require-approval to be set", err.Error())
+}
+
+func TestExecute_ValidateNoSelfApproval(t *testing.T) {
+	t.Log("Should require approval if self approval isn't allowed.")
+	c := setup(map[string]interface{}{
+		cmd.NoSelfApprovalFlag: true,
+		cmd.GHUserFlag:         "user",
+		cmd.GHTokenFlag:        "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "--no-self-approval requires --require-approval to be set", err.Error())
+}
+
+func TestExecute_ValidateEnvironmentApprovals(t *testing.T) {
+	t.Log("Should validate the environment approvals and count them as requiring approval.")
+	cases := []struct {
+		Description string
+		Config      string
+		Flags       map[string]interface{}
+		ExpErr      string
+	}{
+		{
+			"an invalid approval",
+			"environment-approvals:\n  production: everyone",
+			nil,
+			`invalid environment-approvals for environment "production": "everyone" is not none or a list of internal and external`,
+		},
+		{
+			"an environment requiring internal approval with required approvers",
+			"environment-approvals:\n  production: internal\n  dev: none",
+			map[string]interface{}{cmd.RequiredApproversFlag: []string{"alice"}, cmd.NoSelfApprovalFlag: true},
+			"",
+		},
+		{
+			"an environment requiring external approval without approval urls",
+			"environment-approvals:\n  production: internal,external",
+			nil,
+			"--require-approval requires --approval-url to be set",
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.Description)
+		tmpFile := tempFile(t, c.Config)
+		defer os.Remove(tmpFile) // nolint: errcheck
+		flags := map[string]interface{}{
+			cmd.ConfigFlag:  tmpFile,
+			cmd.GHUserFlag:  "user",
+			cmd.GHTokenFlag: "token",
+		}
+		for k, v := range c.Flags {
+			flags[k] = v
+		}
+		err := setup(flags).Execute()
+		if c.ExpErr == "" {
+			Ok(t, err)
+			Equals(t, map[string]string{"production": "internal", "dev": "none"}, passedConfig.EnvironmentApprovals)
+			continue
+		}
+		Assert(t, err != nil, "should be an error")
+		Equals(t, c.ExpErr, err.Error())
+	}
+}
+
+func TestExecute_ValidatePlanExportTTL(t *testing.T) {
+	t.Log("Should require the plan export links to last for some time.")
+	c := setup(map[string]interface{}{
+		cmd.PlanExportTTLFlag:   "0s",
+		cmd.PlanExportUsersFlag: []string{"dave"},
+		cmd.GHUserFlag:          "user",
+		cmd.GHTokenFlag:         "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "--plan-export-ttl must be greater than 0", err.Error())
+}
+
+func TestExecute_ValidateRepoAllowlistComment(t *testing.T) {
+	t.Log("Should require the repo allowlist if its comment is set.")
+	c := setup(map[string]interface{}{
+		cmd.RepoAllowlistCommentFlag: "Ask #platform to set it up.",
+		cmd.GHUserFlag:               "user",
+		cmd.GHTokenFlag:              "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "--repo-allowlist-comment requires --repo-allowlist to be set", err.Error())
+}
+
+func TestExecute_ValidateCommentFormat(t *testing.T) {
+	t.Log("Should validate the comment format.")
+	c := setup(map[string]interface{}{
+		cmd.CommentFormatFlag: "html",
+		cmd.GHUserFlag:        "user",
+		cmd.GHTokenFlag:       "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --comment-format: not one of markdown, plain", err.Error())
+}
+
+func TestExecute_ValidateAWSProfile(t *testing.T) {
+	t.Log("Should error if the AWS profile would break the credentials file.")
+	c := setup(map[string]interface{}{
+		cmd.AWSProfileFlag: "[default]",
+		cmd.GHUserFlag:     "user",
+		cmd.GHTokenFlag:    "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --aws-profile: must not be empty or contain brackets or whitespace", err.Error())
+}
+
+func TestExecute_ValidateDestroyWarningIgnoreTypes(t *testing.T) {
+	t.Log("Should require destroy warnings to be enabled if resource types are ignored.")
+	c := setup(map[string]interface{}{
+		cmd.DestroyWarningIgnoreFlag: []string{"random_*"},
+		cmd.GHUserFlag:               "user",
+		cmd.GHTokenFlag:              "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "--destroy-warning-ignore-types requires --destroy-warning to be set", err.Error())
+}
+
+func TestExecute_ValidateTerraformDistribution(t *testing.T) {
+	t.Log("Should validate the terraform distribution.")
+	c := setup(map[string]interface{}{
+		cmd.TFDistributionFlag: "opentofu",
+		cmd.GHUserFlag:         "user",
+		cmd.GHTokenFlag:        "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --terraform-distribution: not one of terraform, terragrunt", err.Error())
+}
+
+func TestExecute_ValidateApprovalJWTPublicKey(t *testing.T) {
+	t.Log("Should require external approval if the approval JWT public key is set.")
+	c := setup(map[string]interface{}{
+		cmd.ApprovalJWTPublicKeyFlag: "/etc/atlantis/approval.pem",
+		cmd.GHUserFlag:               "user",
+		cmd.GHTokenFlag:              "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "--approval-jwt-public-key requires --require-external-approval to be set", err.Error())
+}
+
+func TestExecute_ValidateApprovalClientCert(t *testing.T) {
+	t.Log("Should require the approval client certificate and key to be set together.")
+	c := setup(map[string]interface{}{
+		cmd.ApprovalClientCertFlag: "/etc/atlantis/client.pem",
+		cmd.GHUserFlag:             "user",
+		cmd.GHTokenFlag:            "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "--approval-client-cert and --approval-client-key must be set together", err.Error())
+}
+
+func TestExecute_ValidateJira(t *testing.T) {
+	t.Log("Should require the Jira credentials if the Jira URL is set.")
+	c := setup(map[string]interface{}{
+		cmd.JiraURLFlag:  "https://example.atlassian.net",
+		cmd.JiraUserFlag: "atlantis@example.com",
+		cmd.GHUserFlag:   "user",
+		cmd.GHTokenFlag:  "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "--jira-url requires --jira-user and --jira-token to be set", err.Error())
+}
+
+func TestExecute_ValidateSlack(t *testing.T) {
+	t.Log("Should require the Slack token if the Slack channel is set.")
+	c := setup(map[string]interface{}{
+		cmd.SlackChannelFlag: "deploys",
+		cmd.GHUserFlag:       "user",
+		cmd.GHTokenFlag:      "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "--slack-channel requires --slack-token to be set", err.Error())
+}
+
+func TestExecute_ValidateTFC(t *testing.T) {
+	t.Log("Should require the Terraform Cloud organization if the token is set.")
+	c := setup(map[string]interface{}{
+		cmd.TFCTokenFlag: "tfc-token",
+		cmd.GHUserFlag:   "user",
+		cmd.GHTokenFlag:  "token",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "--tfc-token requires --tfc-organization to be set", err.Error())
+}
+
 func TestExecute_ValidateVCSConfig(t *testing.T) {
-	expErr := "--gh-user/--gh-token or --gitlab-user/--gitlab-token must be set"
+	expErr := "--gh-user/--gh-token, --gh-app-id/--gh-app-installation-id/--gh-app-key-file or --gitlab-user/--gitlab-token must be set"
 	cases := []struct {
 		description string
 		flags       map[string]interface{}
@@ -166,6 +655,15 @@ func TestExecute_ValidateVCSConfig(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"github app set and should be successful",
+			map[string]interface{}{
+				cmd.GHAppIDFlag:             1,
+				cmd.GHAppInstallationIDFlag: 2,
+				cmd.GHAppKeyFileFlag:        "/etc/atlantis/app.pem",
+			},
+			false,
+		},
 		{
 			"gitlab user and gitlab token set and should be successful",
 			map[string]interface{}{
@@ -198,6 +696,79 @@ func TestExecute_ValidateVCSConfig(t *testing.T) {
 	}
 }
 
+func TestExecute_ValidateGithubApp(t *testing.T) {
+	cases := []struct {
+		description string
+		flags       map[string]interface{}
+		expErr      string
+	}{
+		{
+			"only part of the app is set",
+			map[string]interface{}{
+				cmd.GHAppIDFlag:      1,
+				cmd.GHAppKeyFileFlag: "/etc/atlantis/app.pem",
+			},
+			"--gh-app-id, --gh-app-installation-id and --gh-app-key-file must all be set to authenticate as a GitHub App",
+		},
+		{
+			"the app and a token are set",
+			map[string]interface{}{
+				cmd.GHAppIDFlag:             1,
+				cmd.GHAppInstallationIDFlag: 2,
+				cmd.GHAppKeyFileFlag:        "/etc/atlantis/app.pem",
+				cmd.GHUserFlag:              "user",
+				cmd.GHTokenFlag:             "token",
+			},
+			"--gh-user/--gh-token and --gh-app-id/--gh-app-installation-id/--gh-app-key-file can't both be set: GitHub has to be authenticated with either a token or a GitHub App",
+		},
+		{
+			"the app id is negative",
+			map[string]interface{}{
+				cmd.GHAppIDFlag:             -1,
+				cmd.GHAppInstallationIDFlag: 2,
+				cmd.GHAppKeyFileFlag:        "/etc/atlantis/app.pem",
+			},
+			"invalid --gh-app-id: must not be negative",
+		},
+	}
+	for _, c := range cases {
+		t.Log("Should validate the GitHub App when " + c.description)
+		err := setup(c.flags).Execute()
+		Assert(t, err != nil, "should be an error")
+		Equals(t, c.expErr, err.Error())
+	}
+}
+
+func TestExecute_GithubApp(t *testing.T) {
+	t.Log("The GitHub App should be passed to the server.")
+	c := setup(map[string]interface{}{
+		cmd.GHAppIDFlag:             1,
+		cmd.GHAppInstallationIDFlag: 2,
+		cmd.GHAppKeyFileFlag:        "/etc/atlantis/app.pem",
+	})
+	Ok(t, c.Execute())
+	Equals(t, 1, passedConfig.GithubAppID)
+	Equals(t, 2, passedConfig.GithubAppInstallationID)
+	Equals(t, "/etc/atlantis/app.pem", passedConfig.GithubAppKeyFile)
+	Equals(t, "", passedConfig.GithubUser)
+}
+
+func TestExecute_BuildInfo(t *testing.T) {
+	t.Log("The build info should be passed to the server.")
+	c := setup(map[string]interface{}{
+		"version":       "0.2.0",
+		"commit":        "1a2b3c4",
+		"build-date":    "2017-09-01T00:00:00Z",
+		cmd.GHUserFlag:  "user",
+		cmd.GHTokenFlag: "token",
+	})
+	err := c.Execute()
+	Ok(t, err)
+	Equals(t, "0.2.0", passedConfig.Version)
+	Equals(t, "1a2b3c4", passedConfig.Commit)
+	Equals(t, "2017-09-01T00:00:00Z", passedConfig.BuildDate)
+}
+
 func TestExecute_Defaults(t *testing.T) {
 	t.Log("Should set the defaults for all unspecified flags.")
 	c := setup(map[string]interface{}{
@@ -212,6 +783,9 @@ func TestExecute_Defaults(t *testing.T) {
 	Equals(t, "user", passedConfig.GithubUser)
 	Equals(t, "token", passedConfig.GithubToken)
 	Equals(t, "", passedConfig.GithubWebHookSecret)
+	Equals(t, "", passedConfig.PlanEncryptionKey)
+	Equals(t, "", passedConfig.SlackChannel)
+	Equals(t, "", passedConfig.SlackToken)
 	Equals(t, "gitlab-user", passedConfig.GitlabUser)
 	Equals(t, "gitlab-token", passedConfig.GitlabToken)
 	Equals(t, "", passedConfig.GitlabWebHookSecret)
@@ -219,24 +793,64 @@ func TestExecute_Defaults(t *testing.T) {
 	hostname, err := os.Hostname()
 	Ok(t, err)
 	Equals(t, "http://"+hostname+":4141", passedConfig.AtlantisURL)
+	Equals(t, "", passedConfig.AuditLogPath)
 
 	// Get our home dir since that's what gets defaulted to
 	dataDir, err := homedir.Expand("~/.atlantis")
 	Ok(t, err)
 	Equals(t, dataDir, passedConfig.DataDir)
 	Equals(t, "github.com", passedConfig.GithubHostname)
+	Equals(t, 0, passedConfig.GithubAppID)
+	Equals(t, 0, passedConfig.GithubAppInstallationID)
+	Equals(t, "", passedConfig.GithubAppKeyFile)
+	Equals(t, "", passedConfig.GithubCACert)
+	Equals(t, "", passedConfig.GitlabCACert)
 	Equals(t, "gitlab.com", passedConfig.GitlabHostname)
 	Equals(t, "info", passedConfig.LogLevel)
+	Equals(t, "/home/atlantis/.aws/credentials", passedConfig.AWSCredentialsPath)
+	Equals(t, "", passedConfig.AWSAssumeRoleARN)
+	Equals(t, "atlantis", passedConfig.AWSProfile)
 	Equals(t, false, passedConfig.RequireApproval)
+	Equals(t, false, passedConfig.NoSelfApproval)
+	Equals(t, false, passedConfig.AllowFmtPush)
+	Equals(t, false, passedConfig.ApplyFailFast)
+	Equals(t, 1, passedConfig.ParallelApplyLimit)
+	Equals(t, false, passedConfig.PlanDependents)
+	Equals(t, "https://app.terraform.io", passedConfig.TFCAddress)
 	Equals(t, 4141, passedConfig.Port)
+	Equals(t, 0, passedConfig.MetricsPort)
+	Equals(t, 0, passedConfig.MaxConcurrentCommands)
+	Equals(t, 0, passedConfig.MaxProjectsPerCommand)
+	Equals(t, 100, passedConfig.MaxQueuedCommands)
+	Equals(t, "keep", passedConfig.PreviousComments)
+	Equals(t, "/healthz", passedConfig.HealthzPath)
+	Equals(t, "/readyz", passedConfig.ReadyzPath)
+	Equals(t, "", passedConfig.RepoAllowlistComment)
+	Equals(t, "plan-only", passedConfig.ForkPolicy)
+	Equals(t, "skip", passedConfig.StartupVCSCheck)
+	Equals(t, "terraform", passedConfig.TerraformDistribution)
+	Equals(t, 1, passedConfig.WebhookConcurrency)
+	Equals(t, time.Duration(0), passedConfig.WebhookSendTimeout)
+	Equals(t, time.Duration(0), passedConfig.WorkspaceTTL)
+	Equals(t, time.Duration(0), passedConfig.MaxPlanAge)
+	Equals(t, time.Duration(0), passedConfig.GithubRateLimitWait)
+	Equals(t, time.Duration(0), passedConfig.CommandThrottleWindow)
+	Equals(t, 10*time.Second, passedConfig.ExternalApprovalTimeout)
+	Equals(t, 2, passedConfig.ExternalApprovalRetries)
+	Equals(t, 0, passedConfig.ExternalApprovalQuorum)
+	Equals(t, "markdown", passedConfig.CommentFormat)
+	Equals(t, false, passedConfig.DestroyWarning)
+	Equals(t, false, passedConfig.ApplyRollupStatus)
+	Equals(t, time.Hour, passedConfig.PlanExportTTL)
 }
 
 func TestExecute_ExpandHomeDir(t *testing.T) {
 	t.Log("Should expand the ~ in the home dir to the actual home dir.")
 	c := setup(map[string]interface{}{
-		cmd.GHUserFlag:  "user",
-		cmd.GHTokenFlag: "token",
-		cmd.DataDirFlag: "~/this/is/a/path",
+		cmd.GHUserFlag:             "user",
+		cmd.GHTokenFlag:            "token",
+		cmd.DataDirFlag:            "~/this/is/a/path",
+		cmd.AWSCredentialsPathFlag: "~/.aws/credentials",
 	})
 	err := c.Execute()
 	Ok(t, err)
@@ -244,6 +858,7 @@ func TestExecute_ExpandHomeDir(t *testing.T) {
 	home, err := homedir.Dir()
 	Ok(t, err)
 	Equals(t, home+"/this/is/a/path", passedConfig.DataDir)
+	Equals(t, home+"/.aws/credentials", passedConfig.AWSCredentialsPath)
 }
 
 func TestExecute_GithubUser(t *testing.T) {
@@ -273,26 +888,114 @@ func TestExecute_GitlabUser(t *testing.T) {
 func TestExecute_Flags(t *testing.T) {
 	t.Log("Should use all flags that are set.")
 	c := setup(map[string]interface{}{
-		cmd.AtlantisURLFlag:     "url",
-		cmd.DataDirFlag:         "path",
-		cmd.GHHostnameFlag:      "ghhostname",
-		cmd.GHUserFlag:          "user",
-		cmd.GHTokenFlag:         "token",
-		cmd.GHWebHookSecret:     "secret",
-		cmd.GitlabHostnameFlag:  "gitlab-hostname",
-		cmd.GitlabUserFlag:      "gitlab-user",
-		cmd.GitlabTokenFlag:     "gitlab-token",
-		cmd.GitlabWebHookSecret: "gitlab-secret",
-		cmd.LogLevelFlag:        "debug",
-		cmd.PortFlag:            8181,
-		cmd.RequireApprovalFlag: true,
+		cmd.AtlantisURLFlag:             "url",
+		cmd.AuditLogPathFlag:            "/var/log/atlantis/audit.jsonl",
+		cmd.ApplyFailFastFlag:           true,
+		cmd.ParallelApplyLimitFlag:      4,
+		cmd.AllowFmtPushFlag:            true,
+		cmd.AllowedEgressHostsFlag:      []string{"approvals.example.com"},
+		cmd.AllowedReposFlag:            []string{"owner/repo", "re:owner/payments-.*"},
+		cmd.ApplyRoleARNFlag:            "arn:aws:iam::123456789012:role/apply",
+		cmd.ApplyRollupStatusFlag:       true,
+		cmd.ApprovalJWTPublicKeyFlag:    "/etc/atlantis/approval.pem",
+		cmd.ApprovalCACertFlag:          "/etc/atlantis/ca.pem",
+		cmd.ApprovalClientCertFlag:      "/etc/atlantis/client.pem",
+		cmd.ApprovalClientKeyFlag:       "/etc/atlantis/client-key.pem",
+		cmd.ApprovalURLFlag:             "https://approvals.example.com",
+		cmd.AWSAssumeRoleARNFlag:        "arn:aws:iam::123456789012:role/atlantis",
+		cmd.AWSCredentialsPathFlag:      "/root/.aws/credentials",
+		cmd.AWSProfileFlag:              "atlantis",
+		cmd.CommandThrottleFlag:         "30s",
+		cmd.CommentFormatFlag:           "plain",
+		cmd.DestroyConfirmationEnvsFlag: []string{"production"},
+		cmd.DestroyWarningFlag:          true,
+		cmd.DestroyWarningIgnoreFlag:    []string{"random_*"},
+		cmd.DataDirFlag:                 "path",
+		cmd.EmergencyApplyUsersFlag:     []string{"alice", "bob"},
+		cmd.FmtPushUsersFlag:            []string{"carol"},
+		cmd.ForkPolicyFlag:              "require-member-comment",
+		cmd.GHCACertFlag:                "/etc/atlantis/gh-ca.pem",
+		cmd.GHHostnameFlag:              "ghhostname",
+		cmd.GHUserFlag:                  "user",
+		cmd.GHTokenFlag:                 "token",
+		cmd.GHWebHookSecret:             "secret",
+		cmd.GitlabCACertFlag:            "/etc/atlantis/gitlab-ca.pem",
+		cmd.GitlabHostnameFlag:          "gitlab-hostname",
+		cmd.GitlabUserFlag:              "gitlab-user",
+		cmd.GitlabTokenFlag:             "gitlab-token",
+		cmd.GitlabWebHookSecret:         "gitlab-secret",
+		cmd.JiraTokenFlag:               "jira-token",
+		cmd.JiraURLFlag:                 "https://example.atlassian.net",
+		cmd.JiraUserFlag:                "jira-user",
+		cmd.LogLevelFlag:                "debug",
+		cmd.MaxConcurrentCommandsFlag:   2,
+		cmd.MaxProjectsPerCommandFlag:   20,
+		cmd.MaxQueuedCommandsFlag:       10,
+		cmd.OTLPEndpointFlag:            "http://localhost:4318",
+		cmd.PlanDependentsFlag:          true,
+		cmd.PlanEncryptionKeyFlag:       "a2V5",
+		cmd.PlanExportTTLFlag:           "15m",
+		cmd.PlanExportUsersFlag:         []string{"dave"},
+		cmd.PlanRoleARNFlag:             "arn:aws:iam::123456789012:role/plan",
+		cmd.PortFlag:                    8181,
+		cmd.MetricsPortFlag:             9090,
+		cmd.PreviousCommentsFlag:        "delete",
+		cmd.HealthzPathFlag:             "/livez",
+		cmd.ReadyzPathFlag:              "/ready",
+		cmd.RepoAllowlistFlag:           []string{"owner/*"},
+		cmd.RepoAllowlistCommentFlag:    "Ask #platform to set it up.",
+		cmd.RequireApprovalFlag:         true,
+		cmd.RequiredApproversFlag:       []string{"alice", "org/payments"},
+		cmd.NoSelfApprovalFlag:          true,
+		cmd.RequireExternalApprovalFlag: true,
+		cmd.SlackChannelFlag:            "deploys",
+		cmd.SlackTokenFlag:              "slack-token",
+		cmd.StartupVCSCheckFlag:         "fail",
+		cmd.TFDistributionFlag:          "terragrunt",
+		cmd.TFNotFoundMessageFlag:       "Ask #platform to install it.",
+		cmd.TFCAddressFlag:              "https://tfe.example.com",
+		cmd.TFCOrganizationFlag:         "example",
+		cmd.TFCTokenFlag:                "tfc-token",
+		cmd.WebhookConcurrencyFlag:      4,
+		cmd.WebhookSendTimeoutFlag:      "10s",
+		cmd.WorkspaceTTLFlag:            "168h",
+		cmd.MaxPlanAgeFlag:              "24h",
+		cmd.GHRateLimitWaitFlag:         "5m",
+		cmd.ExternalApprovalTimeoutFlag: "30s",
+		cmd.ExternalApprovalRetriesFlag: 5,
+		cmd.ExternalApprovalQuorumFlag:  1,
 	})
 	err := c.Execute()
 	Ok(t, err)
 
 	Equals(t, "url", passedConfig.AtlantisURL)
+	Equals(t, "/var/log/atlantis/audit.jsonl", passedConfig.AuditLogPath)
+	Equals(t, true, passedConfig.AllowFmtPush)
+	Equals(t, true, passedConfig.ApplyFailFast)
+	Equals(t, 4, passedConfig.ParallelApplyLimit)
+	Equals(t, true, passedConfig.PlanDependents)
+	Equals(t, "a2V5", passedConfig.PlanEncryptionKey)
+	Equals(t, 15*time.Minute, passedConfig.PlanExportTTL)
+	Equals(t, []string{"dave"}, passedConfig.PlanExportUsers)
+	Equals(t, "arn:aws:iam::123456789012:role/apply", passedConfig.ApplyRoleARN)
+	Equals(t, "arn:aws:iam::123456789012:role/atlantis", passedConfig.AWSAssumeRoleARN)
+	Equals(t, true, passedConfig.ApplyRollupStatus)
+	Equals(t, "/etc/atlantis/approval.pem", passedConfig.ApprovalJWTPublicKey)
+	Equals(t, "/etc/atlantis/ca.pem", passedConfig.ApprovalCACert)
+	Equals(t, "/etc/atlantis/client.pem", passedConfig.ApprovalClientCert)
+	Equals(t, "/etc/atlantis/client-key.pem", passedConfig.ApprovalClientKey)
+	Equals(t, []string{"https://approvals.example.com"}, passedConfig.ApprovalURLs)
+	Equals(t, []string{"approvals.example.com"}, passedConfig.AllowedEgressHosts)
+	Equals(t, []string{"owner/repo", "re:owner/payments-.*"}, passedConfig.AllowedRepos)
+	Equals(t, "/root/.aws/credentials", passedConfig.AWSCredentialsPath)
+	Equals(t, "atlantis", passedConfig.AWSProfile)
 	Equals(t, "path", passedConfig.DataDir)
+	Equals(t, []string{"alice", "bob"}, passedConfig.EmergencyApplyUsers)
+	Equals(t, []string{"carol"}, passedConfig.FmtPushUsers)
+	Equals(t, "require-member-comment", passedConfig.ForkPolicy)
 	Equals(t, "ghhostname", passedConfig.GithubHostname)
+	Equals(t, "/etc/atlantis/gh-ca.pem", passedConfig.GithubCACert)
+	Equals(t, "/etc/atlantis/gitlab-ca.pem", passedConfig.GitlabCACert)
 	Equals(t, "user", passedConfig.GithubUser)
 	Equals(t, "token", passedConfig.GithubToken)
 	Equals(t, "secret", passedConfig.GithubWebHookSecret)
@@ -300,9 +1003,47 @@ func TestExecute_Flags(t *testing.T) {
 	Equals(t, "gitlab-user", passedConfig.GitlabUser)
 	Equals(t, "gitlab-token", passedConfig.GitlabToken)
 	Equals(t, "gitlab-secret", passedConfig.GitlabWebHookSecret)
+	Equals(t, "jira-token", passedConfig.JiraToken)
+	Equals(t, "https://example.atlassian.net", passedConfig.JiraURL)
+	Equals(t, "jira-user", passedConfig.JiraUser)
+	Equals(t, "https://tfe.example.com", passedConfig.TFCAddress)
+	Equals(t, "example", passedConfig.TFCOrganization)
+	Equals(t, "tfc-token", passedConfig.TFCToken)
 	Equals(t, "debug", passedConfig.LogLevel)
+	Equals(t, "http://localhost:4318", passedConfig.OTLPEndpoint)
+	Equals(t, "arn:aws:iam::123456789012:role/plan", passedConfig.PlanRoleARN)
 	Equals(t, 8181, passedConfig.Port)
+	Equals(t, 9090, passedConfig.MetricsPort)
+	Equals(t, 2, passedConfig.MaxConcurrentCommands)
+	Equals(t, 20, passedConfig.MaxProjectsPerCommand)
+	Equals(t, 10, passedConfig.MaxQueuedCommands)
+	Equals(t, "delete", passedConfig.PreviousComments)
+	Equals(t, "/livez", passedConfig.HealthzPath)
+	Equals(t, "/ready", passedConfig.ReadyzPath)
+	Equals(t, []string{"owner/*"}, passedConfig.RepoAllowlist)
+	Equals(t, "Ask #platform to set it up.", passedConfig.RepoAllowlistComment)
 	Equals(t, true, passedConfig.RequireApproval)
+	Equals(t, []string{"alice", "org/payments"}, passedConfig.RequiredApprovers)
+	Equals(t, true, passedConfig.NoSelfApproval)
+	Equals(t, true, passedConfig.RequireExternalApproval)
+	Equals(t, "deploys", passedConfig.SlackChannel)
+	Equals(t, "slack-token", passedConfig.SlackToken)
+	Equals(t, "fail", passedConfig.StartupVCSCheck)
+	Equals(t, "terragrunt", passedConfig.TerraformDistribution)
+	Equals(t, "Ask #platform to install it.", passedConfig.TerraformNotFoundMessage)
+	Equals(t, 4, passedConfig.WebhookConcurrency)
+	Equals(t, 10*time.Second, passedConfig.WebhookSendTimeout)
+	Equals(t, 168*time.Hour, passedConfig.WorkspaceTTL)
+	Equals(t, 24*time.Hour, passedConfig.MaxPlanAge)
+	Equals(t, 5*time.Minute, passedConfig.GithubRateLimitWait)
+	Equals(t, 30*time.Second, passedConfig.CommandThrottleWindow)
+	Equals(t, 30*time.Second, passedConfig.ExternalApprovalTimeout)
+	Equals(t, 5, passedConfig.ExternalApprovalRetries)
+	Equals(t, 1, passedConfig.ExternalApprovalQuorum)
+	Equals(t, "plain", passedConfig.CommentFormat)
+	Equals(t, true, passedConfig.DestroyWarning)
+	Equals(t, []string{"random_*"}, passedConfig.DestroyWarningIgnoreTypes)
+	Equals(t, []string{"production"}, passedConfig.DestroyConfirmationEnvs)
 }
 
 func TestExecute_ConfigFile(t *testing.T) {
@@ -343,6 +1084,100 @@ require-approval: true`)
 	Equals(t, true, passedConfig.RequireApproval)
 }
 
+func TestExecute_MultipleConfigFiles(t *testing.T) {
+	t.Log("Later config files should override earlier ones.")
+	base := tempFile(t, "gh-user: user\ngh-token: token\nport: 8181\nrequired-terraform-versions:\n  staging: 0.11.0\n  production: 0.10.0")
+	defer os.Remove(base) // nolint: errcheck
+	override := tempFile(t, "port: 9191\nrequired-terraform-versions:\n  production: 0.11.0")
+	defer os.Remove(override) // nolint: errcheck
+	c := setup(map[string]interface{}{
+		cmd.ConfigFlag: []string{base, override},
+	})
+	err := c.Execute()
+	Ok(t, err)
+	Equals(t, "user", passedConfig.GithubUser)
+	Equals(t, 9191, passedConfig.Port)
+	Equals(t, map[string]string{"staging": "0.11.0", "production": "0.11.0"}, passedConfig.RequiredTerraformVersions)
+}
+
+func TestExecute_ConfigDir(t *testing.T) {
+	t.Log("Config files in a directory should be read in alphabetical order.")
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, "10-base.yaml"), []byte("gh-user: user\ngh-token: token\nport: 8181"), 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, "20-override.yml"), []byte("port: 9191"), 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("not config"), 0600))
+	c := setup(map[string]interface{}{
+		cmd.ConfigFlag: dir,
+	})
+	err = c.Execute()
+	Ok(t, err)
+	Equals(t, "user", passedConfig.GithubUser)
+	Equals(t, 9191, passedConfig.Port)
+}
+
+func TestExecute_DryRun(t *testing.T) {
+	t.Log("--dry-run should print the config with secrets redacted instead of starting the server")
+	tmpFile := tempFile(t, "gh-user: user\ngh-token: token\nwebhooks:\n- event: apply\n  kind: msteams\n  url: https://example.webhook.office.com/secret")
+	defer os.Remove(tmpFile) // nolint: errcheck
+	passedConfig = server.Config{}
+	c := setup(map[string]interface{}{
+		cmd.ConfigFlag: tmpFile,
+		cmd.DryRunFlag: true,
+		cmd.PortFlag:   8181,
+	})
+	var out bytes.Buffer
+	c.SetOutput(&out)
+	err := c.Execute()
+	Ok(t, err)
+	Equals(t, server.Config{}, passedConfig)
+
+	var printed map[string]interface{}
+	Ok(t, yaml.Unmarshal(out.Bytes(), &printed))
+	Equals(t, "user", printed[cmd.GHUserFlag])
+	Equals(t, 8181, printed[cmd.PortFlag])
+	Equals(t, "10s", printed[cmd.ExternalApprovalTimeoutFlag])
+	Equals(t, server.RedactedValue, printed[cmd.GHTokenFlag])
+	Equals(t, "", printed[cmd.GitlabTokenFlag])
+	Assert(t, !strings.Contains(out.String(), "office.com"), "exp the webhook url to be redacted")
+}
+
+func TestExecute_DryRunInvalid(t *testing.T) {
+	t.Log("--dry-run should fail if the config is invalid")
+	c := setup(map[string]interface{}{
+		cmd.DryRunFlag: true,
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "exp an error")
+}
+
+func TestExecute_ReloadOnSIGHUP(t *testing.T) {
+	t.Log("On SIGHUP the config file should be re-read and passed to the server.")
+	tmpFile := tempFile(t, "gh-user: user\ngh-token: token\napproval-url: https://old.example.com")
+	defer os.Remove(tmpFile) // nolint: errcheck
+	creator := &ReloadableServerCreatorMock{reloaded: make(chan server.Config, 1)}
+	v := viper.New()
+	v.Set(cmd.ConfigFlag, tmpFile)
+	c := (&cmd.ServerCmd{
+		ServerCreator: creator,
+		Viper:         v,
+		SilenceOutput: true,
+	}).Init()
+	err := c.Execute()
+	Ok(t, err)
+	Equals(t, []string{"https://old.example.com"}, passedConfig.ApprovalURLs)
+
+	Ok(t, ioutil.WriteFile(tmpFile, []byte("gh-user: user\ngh-token: token\napproval-url: https://new.example.com"), 0600))
+	Ok(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	select {
+	case config := <-creator.reloaded:
+		Equals(t, []string{"https://new.example.com"}, config.ApprovalURLs)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+}
+
 func TestExecute_EnvironmentOverride(t *testing.T) {
 	t.Log("Environment variables should override config file flags.")
 	tmpFile := tempFile(t, "gh-user: config\ngh-token: config2")
@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 
+	"github.com/hootsuite/atlantis/server"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -18,7 +20,30 @@ func (v *VersionCmd) Init() *cobra.Command {
 		Use:   "version",
 		Short: "Print the current Atlantis version",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("atlantis %s\n", v.Viper.Get("version"))
+			v.print(cmd.OutOrStdout())
 		},
 	}
 }
+
+// InitFlag adds a --version flag to root that prints the same as the version
+// command. Without the flag root prints its help like it does without a Run.
+func (v *VersionCmd) InitFlag(root *cobra.Command) {
+	var printVersion bool
+	root.Flags().BoolVar(&printVersion, "version", false, "Print the current Atlantis version")
+	root.Run = func(cmd *cobra.Command, args []string) {
+		if !printVersion {
+			cmd.Help() // nolint: errcheck
+			return
+		}
+		v.print(cmd.OutOrStdout())
+	}
+}
+
+func (v *VersionCmd) print(w io.Writer) {
+	build := server.BuildInfo{
+		Version: v.Viper.GetString("version"),
+		Commit:  v.Viper.GetString("commit"),
+		Date:    v.Viper.GetString("build-date"),
+	}
+	fmt.Fprintf(w, "atlantis %s\n", build)
+}
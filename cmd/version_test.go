@@ -0,0 +1,31 @@
+package cmd_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hootsuite/atlantis/cmd"
+	. "github.com/hootsuite/atlantis/testing"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestVersionCmd(t *testing.T) {
+	t.Log("the version command and the --version flag should print the build info")
+	v := viper.New()
+	v.Set("version", "0.2.0")
+	v.Set("commit", "1a2b3c4")
+	v.Set("build-date", "2017-09-01T00:00:00Z")
+	version := &cmd.VersionCmd{Viper: v}
+
+	for _, args := range [][]string{{"version"}, {"--version"}} {
+		root := &cobra.Command{Use: "atlantis"}
+		root.AddCommand(version.Init())
+		version.InitFlag(root)
+		out := new(bytes.Buffer)
+		root.SetOutput(out)
+		root.SetArgs(args)
+		Ok(t, root.Execute())
+		Equals(t, "atlantis 0.2.0 (commit 1a2b3c4, built 2017-09-01T00:00:00Z)\n", out.String())
+	}
+}
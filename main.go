@@ -5,9 +5,19 @@ import (
 	"github.com/spf13/viper"
 )
 
+// These are set at build time with -ldflags, ex.
+// -X main.commit=$(git rev-parse --short HEAD). See the Makefile.
+var (
+	version = "0.2.0"
+	commit  = "none"
+	date    = "unknown"
+)
+
 func main() {
 	v := viper.New()
-	v.Set("version", "0.2.0")
+	v.Set("version", version)
+	v.Set("commit", commit)
+	v.Set("build-date", date)
 
 	server := &cmd.ServerCmd{
 		ServerCreator: &cmd.DefaultServerCreator{},
@@ -15,8 +25,11 @@ func main() {
 	}
 	version := &cmd.VersionCmd{Viper: v}
 	bootstrap := &cmd.BootstrapCmd{}
+	locks := &cmd.LocksCmd{}
 	cmd.RootCmd.AddCommand(server.Init())
 	cmd.RootCmd.AddCommand(version.Init())
 	cmd.RootCmd.AddCommand(bootstrap.Init())
+	cmd.RootCmd.AddCommand(locks.Init())
+	version.InitFlag(cmd.RootCmd)
 	cmd.Execute()
 }
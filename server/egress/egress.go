@@ -0,0 +1,56 @@
+// Package egress limits the hosts that Atlantis sends requests to, ex. so a
+// misconfigured or tampered URL can't send pull request details or approvals
+// to an arbitrary host.
+package egress
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Allowlist is the hosts that requests can be sent to. A nil Allowlist
+// allows every host.
+type Allowlist struct {
+	// Hosts are the allowed hostnames, without ports, ex. "approvals.example.com".
+	// They can be glob patterns, ex. "*.example.com".
+	Hosts []string
+}
+
+// NewAllowlist returns an allowlist of hosts. If hosts is empty, it returns
+// nil so every host is allowed.
+func NewAllowlist(hosts []string) (*Allowlist, error) {
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+	var lower []string
+	for _, h := range hosts {
+		if _, err := path.Match(h, ""); err != nil {
+			return nil, fmt.Errorf("invalid host pattern %q: %s", h, err)
+		}
+		lower = append(lower, strings.ToLower(h))
+	}
+	return &Allowlist{Hosts: lower}, nil
+}
+
+// Check returns an error if the host of rawURL isn't allowed. The error never
+// contains rawURL since webhook URLs can have credentials in them.
+func (a *Allowlist) Check(rawURL string) error {
+	if a == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return errors.New("url has no host")
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range a.Hosts {
+		if matched, _ := path.Match(h, host); matched {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %q isn't an allowed egress host", host)
+}
@@ -0,0 +1,40 @@
+package egress_test
+
+import (
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/egress"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestAllowlist_Check(t *testing.T) {
+	a, err := egress.NewAllowlist([]string{"approvals.example.com", "*.webhook.office.com"})
+	Ok(t, err)
+
+	t.Log("allowed hosts should be allowed regardless of port and case")
+	Ok(t, a.Check("https://approvals.example.com/approve"))
+	Ok(t, a.Check("https://Approvals.Example.com:8443/approve"))
+	Ok(t, a.Check("https://contoso.webhook.office.com/webhookb2/secret"))
+
+	t.Log("other hosts should be rejected without the url in the error")
+	err = a.Check("https://evil.example.org/webhookb2/secret")
+	Assert(t, err != nil, "exp an error")
+	Equals(t, `host "evil.example.org" isn't an allowed egress host`, err.Error())
+	err = a.Check("not a url with a secret")
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "url has no host", err.Error())
+}
+
+func TestAllowlist_Nil(t *testing.T) {
+	t.Log("an empty allowlist should allow every host")
+	a, err := egress.NewAllowlist(nil)
+	Ok(t, err)
+	Ok(t, a.Check("https://anywhere.example.org"))
+}
+
+func TestNewAllowlist_InvalidPattern(t *testing.T) {
+	t.Log("an invalid pattern should be an error")
+	_, err := egress.NewAllowlist([]string{"[example.com"})
+	Assert(t, err != nil, "exp an error")
+	Equals(t, `invalid host pattern "[example.com": syntax error in pattern`, err.Error())
+}
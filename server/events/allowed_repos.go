@@ -0,0 +1,56 @@
+package events
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// allowedRepoRegexPrefix marks an allowed repo as a regex.
+const allowedRepoRegexPrefix = "re:"
+
+// AllowedRepos are the repos whose pull requests can be applied. Other repos
+// can still be planned. A nil AllowedRepos allows every repo.
+type AllowedRepos struct {
+	// names are the full names of the repos that are allowed exactly.
+	names map[string]bool
+	// regexes match the full names of the other allowed repos.
+	regexes []*regexp.Regexp
+}
+
+// NewAllowedRepos returns the repos in repos, which are full names, ex.
+// owner/repo, or with the re: prefix, regexes that have to match the whole
+// full name, ex. re:owner/payments-.*. If repos is empty, it returns nil so
+// every repo is allowed.
+func NewAllowedRepos(repos []string) (*AllowedRepos, error) {
+	if len(repos) == 0 {
+		return nil, nil
+	}
+	a := &AllowedRepos{names: make(map[string]bool)}
+	for _, r := range repos {
+		if !strings.HasPrefix(r, allowedRepoRegexPrefix) {
+			a.names[r] = true
+			continue
+		}
+		regex, err := regexp.Compile("^(?:" + strings.TrimPrefix(r, allowedRepoRegexPrefix) + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%q has an invalid regex: %s", r, err)
+		}
+		a.regexes = append(a.regexes, regex)
+	}
+	return a, nil
+}
+
+// IsAllowed returns true if the pull requests of the repo with repoFullName
+// can be applied.
+func (a *AllowedRepos) IsAllowed(repoFullName string) bool {
+	if a == nil || a.names[repoFullName] {
+		return true
+	}
+	for _, regex := range a.regexes {
+		if regex.MatchString(repoFullName) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/mocks"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+func TestAllowedRepos_IsAllowed(t *testing.T) {
+	t.Log("a repo should only be allowed if it's listed or matches a regex")
+	allowed, err := events.NewAllowedRepos([]string{"owner/repo", "re:owner/payments-.*"})
+	Ok(t, err)
+	cases := []struct {
+		Description string
+		Repo        string
+		Exp         bool
+	}{
+		{"an exact name", "owner/repo", true},
+		{"a name that's only a prefix", "owner/repo-2", false},
+		{"a name that matches the regex", "owner/payments-api", true},
+		{"a name that only partly matches the regex", "other/owner/payments-api", false},
+		{"an unlisted repo", "other/repo", false},
+	}
+	for _, c := range cases {
+		t.Log(c.Description)
+		Equals(t, c.Exp, allowed.IsAllowed(c.Repo))
+	}
+
+	t.Log("without any repos every repo should be allowed")
+	none, err := events.NewAllowedRepos(nil)
+	Ok(t, err)
+	Equals(t, true, none.IsAllowed("other/repo"))
+}
+
+func TestNewAllowedRepos_InvalidRegex(t *testing.T) {
+	t.Log("an invalid regex should be an error")
+	_, err := events.NewAllowedRepos([]string{"re:owner/("})
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "\"re:owner/(\" has an invalid regex: error parsing regexp: missing closing ): `^(?:owner/()$`", err.Error())
+}
+
+func TestApplyExecutor_AllowedRepos(t *testing.T) {
+	t.Log("only the pull requests of allowed repos should be applied")
+	RegisterMockTestingT(t)
+	workspace := mocks.NewMockWorkspace()
+	When(workspace.GetWorkspace(applyCtx.BaseRepo, applyCtx.Pull, "production")).ThenReturn("", errors.New("not found"))
+	a := &events.ApplyExecutor{Workspace: workspace}
+
+	allowed, err := events.NewAllowedRepos([]string{"re:owner/.*"})
+	Ok(t, err)
+	a.SetPolicy(events.ApplyPolicy{AllowedRepos: allowed})
+	r := a.Execute(&applyCtx)
+	Equals(t, "No workspace found. Did you run plan?", r.Failure)
+
+	t.Log("a repo that isn't allowed should fail before its workspace is looked up")
+	allowed, err = events.NewAllowedRepos([]string{"other/repo"})
+	Ok(t, err)
+	a.SetPolicy(events.ApplyPolicy{AllowedRepos: allowed})
+	r = a.Execute(&applyCtx)
+	Equals(t, "Applies aren't allowed for owner/repo. Ask your Atlantis administrators to add it to --allowed-repos.", r.Failure)
+	workspace.VerifyWasCalledOnce().GetWorkspace(applyCtx.BaseRepo, applyCtx.Pull, "production")
+}
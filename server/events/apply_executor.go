@@ -2,106 +2,647 @@ package events
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"path/filepath"
 
+	"github.com/hashicorp/go-version"
+	"github.com/hootsuite/atlantis/server/egress"
+	"github.com/hootsuite/atlantis/server/events/audit"
+	"github.com/hootsuite/atlantis/server/events/jira"
 	"github.com/hootsuite/atlantis/server/events/models"
 	"github.com/hootsuite/atlantis/server/events/run"
 	"github.com/hootsuite/atlantis/server/events/terraform"
+	"github.com/hootsuite/atlantis/server/events/tfc"
 	"github.com/hootsuite/atlantis/server/events/vcs"
 	"github.com/hootsuite/atlantis/server/events/webhooks"
+	"github.com/hootsuite/atlantis/server/logging"
+	"github.com/hootsuite/atlantis/server/tracing"
 )
 
 type ApplyExecutor struct {
-	VCSClient               vcs.ClientProxy
-	Terraform               *terraform.Client
+	VCSClient         vcs.ClientProxy
+	Terraform         *terraform.Client
+	Run               *run.Run
+	Workspace         Workspace
+	ProjectPreExecute *ProjectPreExecute
+	Webhooks          webhooks.Sender
+	// Jira, if set, is used to comment the change ticket referenced with
+	// --ticket on the pull request and to transition it after the apply.
+	Jira *jira.Client
+	// JiraTransitions maps an environment to the transition that its change
+	// tickets are moved through after a successful apply, ex. "Applied".
+	JiraTransitions map[string]string
+	// TFC, if set, applies the plans of projects with a remote backend in
+	// Terraform Cloud instead of locally.
+	TFC *tfc.Client
+	// TFCWorkspaces maps an environment to the Terraform Cloud workspace its
+	// plans ran in.
+	TFCWorkspaces map[string]string
+	// AccountGuard, if set, checks that each environment is applied in its
+	// AWS account.
+	AccountGuard *AWSAccountGuard
+	// ApprovalMetrics, if set, records the outcome and latency of external
+	// approval checks.
+	ApprovalMetrics *ApprovalMetrics
+	// CommandMetrics, if set, records the result of each project's apply and
+	// how long terraform took to apply it.
+	CommandMetrics *CommandMetrics
+	// Terragrunt, if set, applies the plans of terragrunt modules in
+	// dependency order and doesn't apply a module if one of its dependencies
+	// failed to apply.
+	Terragrunt *Terragrunt
+	// ProjectLimit is the most projects that an apply can run in.
+	ProjectLimit *ProjectLimit
+	// MaxPlanAge, if set, is how long ago a plan can have been planned to
+	// still be applied since the infrastructure may have drifted since.
+	MaxPlanAge time.Duration
+	// PlanEncryptor decrypts the plans that are encrypted at rest while
+	// they're applied.
+	PlanEncryptor *PlanEncryptor
+	// DestroyWarning, if set, is the destroy warning of plan comments. The
+	// resource types it ignores don't have to be confirmed with --destroy-ok
+	// either.
+	DestroyWarning *DestroyWarning
+	// ApplyRollup, if set, is told which projects were applied.
+	ApplyRollup *ApplyRollup
+	// AuditLog, if set, gets an entry for the result of every apply.
+	AuditLog *audit.Log
+	// Freeze, if set and frozen, disables every apply.
+	Freeze *ApplyFreeze
+	// ParallelApplyLimit is how many projects are applied at the same time.
+	// If it's 0 or 1, they're applied one at a time.
+	ParallelApplyLimit int
+	// ApplyFailFast stops applying projects that haven't started once a
+	// project fails to apply. Otherwise only the projects that depend on it
+	// aren't applied.
+	ApplyFailFast bool
+
+	policyMutex sync.RWMutex
+	policy      ApplyPolicy
+}
+
+// ApplyPolicy decides who can apply and when. It's kept separate from the
+// rest of ApplyExecutor so it can be replaced while the server is running.
+type ApplyPolicy struct {
 	RequireApproval         bool
 	RequireExternalApproval bool
-	ApprovalURL             string
-	Run                     *run.Run
-	Workspace               Workspace
-	ProjectPreExecute       *ProjectPreExecute
-	Webhooks                webhooks.Sender
+	// EnvApprovals, if set, are the approvals that applies to each
+	// environment require. Environments that aren't in it require the
+	// approvals of RequireApproval and RequireExternalApproval.
+	EnvApprovals map[string]EnvApproval
+	// RequiredApprovers, if set, are the users and teams, ex. org/team-slug,
+	// that one of has to approve the pull request if RequireApproval is
+	// true. Otherwise any approval is enough.
+	RequiredApprovers []string
+	// NoSelfApproval is true if approvals by the pull request's author or the
+	// user running apply don't count towards RequireApproval.
+	NoSelfApproval bool
+	// ApprovalURLs are the approval services that are asked whether a pull
+	// request is approved.
+	ApprovalURLs []string
+	// ApprovalQuorum is how many of the services have to approve. If it's 0,
+	// all of them have to.
+	ApprovalQuorum int
+	// ApprovalTimeout is how long we wait for the approval service to
+	// respond.
+	ApprovalTimeout time.Duration
+	// ApprovalRetries is how many times a request to the approval service is
+	// retried if it fails with a network error or a 5xx status.
+	ApprovalRetries int
+	// ApprovalRetryBackoff is how long we wait before the first retry. It's
+	// doubled for each retry after that.
+	ApprovalRetryBackoff time.Duration
+	// ApprovalTLS, if set, is the TLS config for requests to the approval
+	// services, ex. with a client certificate for mutual TLS.
+	ApprovalTLS *tls.Config
+	// ApprovalSigningSecret, if set, signs the requests to the approval
+	// services. See ApprovalSignatureHeader.
+	ApprovalSigningSecret []byte
+	// ApprovalTokenVerifier, if set, verifies that the approval service's
+	// response is a signed approval token instead of trusting plain JSON.
+	ApprovalTokenVerifier *ApprovalTokenVerifier
+	// EgressHosts, if set, must allow ApprovalURLs for the approval services
+	// to be called.
+	EgressHosts *egress.Allowlist
+	// ChangeWindows are when applies are allowed for each environment.
+	// Environments without a window can be applied anytime.
+	ChangeWindows map[string]ChangeWindow
+	// FreezeWindows are scheduled freezes by name, ex. a weekend freeze,
+	// during which no applies are allowed.
+	FreezeWindows map[string]ChangeWindow
+	// EmergencyUsers are the users that can run emergency applies, which
+	// bypass the change window. It's separate from who can run normal
+	// applies.
+	EmergencyUsers []string
+	// AllowedRepos, if set, are the only repos whose pull requests can be
+	// applied.
+	AllowedRepos *AllowedRepos
+	// DestroyConfirmationEnvs are the environments whose plans can only be
+	// applied with --destroy-ok if they destroy resources.
+	DestroyConfirmationEnvs []string
+}
+
+// SetPolicy replaces the policy used by applies that start after it returns.
+// Applies that are already running keep the policy they started with.
+func (a *ApplyExecutor) SetPolicy(policy ApplyPolicy) {
+	a.policyMutex.Lock()
+	defer a.policyMutex.Unlock()
+	a.policy = policy
+}
+
+// Policy returns the current policy.
+func (a *ApplyExecutor) Policy() ApplyPolicy {
+	a.policyMutex.RLock()
+	defer a.policyMutex.RUnlock()
+	return a.policy
+}
+
+// ApprovalSignatureHeader is the header of approval requests with their
+// signature if ApprovalSigningSecret is set. Its value is "sha256=" followed
+// by the hex encoded HMAC-SHA256 of the request body keyed with the secret,
+// the same format as GitHub's X-Hub-Signature-256. Services should compute it
+// themselves and compare them in constant time.
+const ApprovalSignatureHeader = "X-Atlantis-Signature"
+
+// ApprovalSignature returns the value of ApprovalSignatureHeader for body.
+func ApprovalSignature(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body) // nolint: errcheck
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// DefaultApprovalRetryBackoff is how long we wait before retrying a failed
+// request to the approval service for the first time.
+const DefaultApprovalRetryBackoff = 500 * time.Millisecond
+
+// approvalRequest is what the approval service is sent. The head commit and
+// environment let the service pin an approval to them, ex. to require
+// approving again after new commits.
+type approvalRequest struct {
+	RepoOwner   string `json:"repo_owner"`
+	RepoName    string `json:"repo_name"`
+	PullRequest int    `json:"pull_request"`
+	HeadCommit  string `json:"head_commit"`
+	Environment string `json:"environment"`
+	User        string `json:"user"`
 }
 
+// externalApproval is the approval service's response. Status is one of
+// ApprovalApproved, ApprovalDenied or ApprovalPending. Services that don't
+// send it only say whether the pull request is approved, which is taken to
+// mean it's pending if it isn't.
 type externalApproval struct {
 	PullRequest string
 	ApprovedBy  string
 	Approved    bool
+	Status      string
 }
 
-func (a *ApplyExecutor) checkExternalApproval(ctx *CommandContext, repo models.Repo, pull models.PullRequest) (bool, error) {
-	client := &http.Client{
-		Timeout: time.Second * 1,
+// externalDecision is the combined decision of the approval services.
+type externalDecision struct {
+	// Decision is one of ApprovalApproved, ApprovalDenied or ApprovalPending.
+	Decision string
+	// Services are the hosts of the services that denied the pull request if
+	// it's denied, or that haven't decided yet if it's pending.
+	Services []string
+	// Approvals is how many services approved the pull request and Quorum
+	// how many have to.
+	Approvals int
+	Quorum    int
+	// Total is how many services were asked.
+	Total int
+}
+
+// serviceDecision is the decision of one approval service.
+type serviceDecision struct {
+	Host     string
+	Decision string
+	Err      error
+}
+
+// checkExternalApproval asks every approval service for its decision at the
+// same time and combines them. An error means there weren't enough decisions
+// to know whether the pull request is approved or denied.
+func (a *ApplyExecutor) checkExternalApproval(ctx *CommandContext, policy ApplyPolicy, repo models.Repo, pull models.PullRequest) (decision externalDecision, err error) {
+	start := time.Now()
+	defer func() {
+		a.ApprovalMetrics.Observe(repo.FullName, ctx.Command.Environment, approvalOutcome(decision.Decision, err), time.Since(start))
+	}()
+
+	for _, u := range policy.ApprovalURLs {
+		if err := policy.EgressHosts.Check(u); err != nil {
+			ctx.Log.Err("refusing to call the approval service: %s", err)
+			return externalDecision{}, err
+		}
 	}
 
-	payload := fmt.Sprintf("{\"repo_owner\": \"%s\", \"repo_name\": \"%s\", \"pull_request\": %d}", repo.Owner, repo.Name, pull.Num)
-	req, err := http.NewRequest("POST", a.ApprovalURL, bytes.NewBuffer([]byte(payload)))
-	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{
+		Timeout: policy.ApprovalTimeout,
+	}
+	if policy.ApprovalTLS != nil {
+		client.Transport = &http.Transport{TLSClientConfig: policy.ApprovalTLS}
+	}
 
+	payload, err := json.Marshal(approvalRequest{
+		RepoOwner:   repo.Owner,
+		RepoName:    repo.Name,
+		PullRequest: pull.Num,
+		HeadCommit:  pull.HeadCommit,
+		Environment: ctx.Command.Environment,
+		User:        ctx.User.Username,
+	})
 	if err != nil {
-		return false, err
+		return externalDecision{}, errors.Wrap(err, "serializing approval request")
+	}
+
+	// Once the decisions so far make the quorum or make it unreachable, the
+	// requests that are still running are cancelled since their decisions
+	// can't change the outcome.
+	requestsCtx, cancel := context.WithCancel(ctx.Context)
+	defer cancel()
+	type indexedDecision struct {
+		i int
+		d serviceDecision
+	}
+	results := make(chan indexedDecision, len(policy.ApprovalURLs))
+	for i, u := range policy.ApprovalURLs {
+		go func(i int, u string) {
+			// ctx.Log isn't safe to use from more than one goroutine so each
+			// request logs without keeping history.
+			serviceCtx := *ctx
+			serviceCtx.Context = requestsCtx
+			serviceCtx.Log = logging.NewSimpleLogger(ctx.Log.Source, ctx.Log.Logger, false, ctx.Log.Level)
+			d, err := askApprovalService(&serviceCtx, client, policy, u, payload, repo, pull)
+			results <- indexedDecision{i, serviceDecision{Host: approvalHost(u), Decision: d, Err: err}}
+		}(i, u)
+	}
+	decisions := make([]serviceDecision, len(policy.ApprovalURLs))
+	for range policy.ApprovalURLs {
+		r := <-results
+		decisions[r.i] = r.d
+		if quorumDecided(decisions, policy.ApprovalQuorum) {
+			cancel()
+		}
+	}
+	return combineApprovals(decisions, policy.ApprovalQuorum)
+}
+
+// quorumDecided returns true if the decisions that have been made already
+// approve or deny the pull request however the services that haven't decided
+// yet decide. quorum is as for combineApprovals.
+func quorumDecided(decisions []serviceDecision, quorum int) bool {
+	if quorum == 0 {
+		quorum = len(decisions)
+	}
+	var approved, denied int
+	for _, d := range decisions {
+		if d.Err != nil {
+			continue
+		}
+		switch d.Decision {
+		case ApprovalApproved:
+			approved++
+		case ApprovalDenied:
+			denied++
+		}
+	}
+	return approved >= quorum || len(decisions)-denied < quorum
+}
+
+// combineApprovals returns the decision of the services given that quorum of
+// them have to approve, or all of them if quorum is 0. The pull request is
+// denied once so many services denied it that the quorum can't be reached.
+// Otherwise if a service errored the result is an error since it might have
+// approved.
+func combineApprovals(decisions []serviceDecision, quorum int) (externalDecision, error) {
+	if len(decisions) == 0 {
+		return externalDecision{}, errors.New("no approval urls are set")
+	}
+	if quorum == 0 {
+		quorum = len(decisions)
+	}
+	combined := externalDecision{Quorum: quorum, Total: len(decisions)}
+	var denied, pending, errs []string
+	var lastErr error
+	for _, d := range decisions {
+		switch {
+		case d.Err != nil:
+			errs = append(errs, fmt.Sprintf("approval service at %s: %s", d.Host, d.Err))
+			lastErr = d.Err
+		case d.Decision == ApprovalApproved:
+			combined.Approvals++
+		case d.Decision == ApprovalDenied:
+			denied = append(denied, d.Host)
+		default:
+			pending = append(pending, d.Host)
+		}
+	}
+	switch {
+	case combined.Approvals >= quorum:
+		combined.Decision = ApprovalApproved
+	case len(decisions)-len(denied) < quorum:
+		combined.Decision = ApprovalDenied
+		combined.Services = denied
+	case len(decisions) == 1 && lastErr != nil:
+		return externalDecision{}, lastErr
+	case len(errs) > 0:
+		return externalDecision{}, errors.New(strings.Join(errs, "; "))
+	default:
+		combined.Decision = ApprovalPending
+		combined.Services = pending
+	}
+	return combined, nil
+}
+
+// failure returns the failure message for the decision, or "" if the pull
+// request is approved. The services are only named if there's more than one.
+func (d externalDecision) failure() string {
+	services := "the approval service"
+	if d.Total > 1 {
+		services = fmt.Sprintf("the approval service at %s", strings.Join(d.Services, ", "))
+		if len(d.Services) > 1 {
+			services = fmt.Sprintf("the approval services at %s", strings.Join(d.Services, ", "))
+		}
+	}
+	switch d.Decision {
+	case ApprovalDenied:
+		return fmt.Sprintf("Pull request was denied by %s. (external)", services)
+	case ApprovalPending:
+		if d.Total > 1 {
+			return fmt.Sprintf("Pull request is still waiting for a decision from %s. It has %d of the %d approvals it needs. (external)", services, d.Approvals, d.Quorum)
+		}
+		return fmt.Sprintf("Pull request is still waiting for a decision from %s. (external)", services)
+	}
+	return ""
+}
+
+// approvalHost returns the host of the approval service at rawURL to name it
+// without showing anything from its path or query, which might be secret.
+func approvalHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
 	}
+	return u.Host
+}
 
+// askApprovalService returns the decision of the approval service at
+// serviceURL, retrying errors that might be transient. A denied or pending
+// response is a decision so it isn't retried.
+func askApprovalService(ctx *CommandContext, client *http.Client, policy ApplyPolicy, serviceURL string, payload []byte, repo models.Repo, pull models.PullRequest) (string, error) {
+	backoff := policy.ApprovalRetryBackoff
+	for attempt := 1; ; attempt++ {
+		decision, retryable, err := requestApproval(ctx, client, policy, serviceURL, payload, repo, pull)
+		if err == nil || !retryable {
+			return decision, err
+		}
+		if attempt > policy.ApprovalRetries {
+			if attempt > 1 {
+				err = errors.Wrapf(err, "gave up after %d attempts", attempt)
+			}
+			return "", err
+		}
+		ctx.Log.Warn("approval request failed, retrying in %s: %s", backoff, err)
+		select {
+		case <-ctx.Context.Done():
+			return "", errors.Wrap(ctx.Context.Err(), "waiting to retry approval request")
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// requestApproval asks the approval service at serviceURL once for its
+// decision on the pull request. retryable is true if the error might go away if we ask again,
+// ex. because the service is restarting.
+func requestApproval(ctx *CommandContext, client *http.Client, policy ApplyPolicy, serviceURL string, payload []byte, repo models.Repo, pull models.PullRequest) (decision string, retryable bool, err error) {
+	req, err := http.NewRequest("POST", serviceURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return "", false, errors.Wrap(err, "building approval request")
+	}
+	// The request is cancelled with the command or once the other services
+	// have decided.
+	req = req.WithContext(ctx.Context)
+	req.Header.Set("Content-Type", "application/json")
+	if len(policy.ApprovalSigningSecret) > 0 {
+		req.Header.Set(ApprovalSignatureHeader, ApprovalSignature(policy.ApprovalSigningSecret, payload))
+	}
 	req.Close = true
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return false, err
+		if ctx.Context.Err() != nil {
+			return "", false, errors.Wrap(ctx.Context.Err(), "calling approval service")
+		}
+		// A transport error isn't a decision so it's an error rather than
+		// not approved.
+		if urlErr, ok := err.(*url.Error); ok && urlErr.Timeout() {
+			return "", true, fmt.Errorf("approval service didn't respond within %s", policy.ApprovalTimeout)
+		}
+		return "", true, errors.Wrap(err, "calling approval service")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return ApprovalDenied, false, nil
+	case resp.StatusCode >= 500:
+		return "", true, fmt.Errorf("approval service responded with status %d", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return "", false, fmt.Errorf("approval service responded with status %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", true, errors.Wrap(err, "reading approval response")
 	}
 
-	if resp.StatusCode == 200 {
-		body, err := ioutil.ReadAll(resp.Body)
+	if policy.ApprovalTokenVerifier != nil {
+		claims, err := policy.ApprovalTokenVerifier.Verify(string(body), repo, pull, ctx.Command.Environment, time.Now())
 		if err != nil {
-			return false, err
+			return "", false, err
 		}
-
-		var approval externalApproval
-		if json.Unmarshal(body, &approval) != nil {
-			return false, err
+		decision, err := approvalDecision(claims.Approved, claims.Status)
+		if decision == ApprovalApproved {
+			ctx.Log.Info("approval token was signed for approver %q", claims.Approver)
 		}
+		return decision, false, err
+	}
+
+	var approval externalApproval
+	if err := json.Unmarshal(body, &approval); err != nil {
+		return "", false, errors.Wrap(err, "parsing approval response")
+	}
+	decision, err = approvalDecision(approval.Approved, approval.Status)
+	return decision, false, err
+}
 
-		if approval.Approved {
-			return true, nil
+// approvalDecision returns the decision of a response with approved and
+// status. A status that contradicts approved is an error rather than either
+// of them winning so a buggy service can't approve by accident.
+func approvalDecision(approved bool, status string) (string, error) {
+	switch status {
+	case "":
+		if approved {
+			return ApprovalApproved, nil
 		}
+		return ApprovalPending, nil
+	case ApprovalApproved, ApprovalDenied, ApprovalPending:
+		if approved != (status == ApprovalApproved) {
+			return "", fmt.Errorf("approval response has status %q but approved is %t", status, approved)
+		}
+		return status, nil
+	default:
+		return "", fmt.Errorf("approval response has unknown status %q", status)
+	}
+}
 
-		return false, nil
+func (p ApplyPolicy) isEmergencyUser(username string) bool {
+	for _, u := range p.EmergencyUsers {
+		if u == username {
+			return true
+		}
 	}
+	return false
+}
 
-	if resp.StatusCode >= 400 || resp.StatusCode < 200 {
-		return false, nil
+// requiresDestroyConfirmation returns true if plans that destroy resources
+// in env have to be applied with --destroy-ok.
+func (p ApplyPolicy) requiresDestroyConfirmation(env string) bool {
+	for _, e := range p.DestroyConfirmationEnvs {
+		if e == env {
+			return true
+		}
 	}
+	return false
+}
 
-	return false, nil
+// applyApprovals are the audit statuses of an apply's approvals.
+type applyApprovals struct {
+	Internal string
+	External string
+	// ExternalDecision is the decision of the approval services that's sent
+	// with webhooks, or nil if external approval isn't required.
+	ExternalDecision *webhooks.ExternalApproval
+	// DestroyConfirmation is true if plans that destroy resources have to be
+	// confirmed with --destroy-ok.
+	DestroyConfirmation bool
 }
 
 func (a *ApplyExecutor) Execute(ctx *CommandContext) CommandResponse {
-	if a.RequireApproval {
-		approved, err := a.VCSClient.PullIsApproved(ctx.BaseRepo, ctx.Pull, ctx.VCSHost)
+	policy := a.Policy()
+	approvals := &applyApprovals{Internal: audit.NotRequired, External: audit.NotRequired}
+	if policy.requiresApproval(ctx.Command.Environment) {
+		approvals.Internal = audit.NotChecked
+	}
+	if policy.requiresExternalApproval(ctx.Command.Environment) {
+		approvals.External = audit.NotChecked
+	}
+	approvals.DestroyConfirmation = policy.requiresDestroyConfirmation(ctx.Command.Environment)
+	response := a.execute(ctx, policy, approvals)
+	// Applies that ended before they got to the projects are audited once
+	// for the whole command.
+	if len(response.ProjectResults) == 0 {
+		a.audit(ctx, approvals, "", ProjectResult{Error: response.Error, Failure: response.Failure})
+	}
+	return response
+}
+
+// audit appends an entry for the result of applying project to the audit
+// log.
+func (a *ApplyExecutor) audit(ctx *CommandContext, approvals *applyApprovals, project string, result ProjectResult) {
+	if a.AuditLog == nil {
+		return
+	}
+	err := a.AuditLog.Append(audit.Entry{
+		Time:             time.Now(),
+		User:             ctx.User.Username,
+		Repo:             ctx.BaseRepo.FullName,
+		PullNum:          ctx.Pull.Num,
+		Commit:           ctx.Pull.HeadCommit,
+		Environment:      ctx.Command.Environment,
+		Project:          project,
+		InternalApproval: approvals.Internal,
+		ExternalApproval: approvals.External,
+		Emergency:        ctx.Command.Emergency,
+		Ticket:           ctx.Command.Ticket,
+		Result:           resultLabel(result),
+	})
+	if err != nil {
+		ctx.Log.Err("unable to write to the audit log: %s", err)
+	}
+}
+
+func (a *ApplyExecutor) execute(ctx *CommandContext, policy ApplyPolicy, approvals *applyApprovals) CommandResponse {
+	if failure := a.freezeFailure(policy, time.Now()); failure != "" {
+		return CommandResponse{Failure: failure}
+	}
+	if !policy.AllowedRepos.IsAllowed(ctx.BaseRepo.FullName) {
+		return CommandResponse{Failure: fmt.Sprintf("Applies aren't allowed for %s. Ask your Atlantis administrators to add it to --allowed-repos.", ctx.BaseRepo.FullName)}
+	}
+	if ctx.Command.Emergency {
+		if ctx.Command.Ticket == "" {
+			return CommandResponse{Failure: "Emergency applies must reference a change ticket with --ticket."}
+		}
+		if !policy.isEmergencyUser(ctx.User.Username) {
+			return CommandResponse{Failure: fmt.Sprintf("%s is not allowed to run emergency applies.", ctx.User.Username)}
+		}
+	}
+	if window, ok := policy.ChangeWindows[ctx.Command.Environment]; ok {
+		if window.Contains(time.Now()) {
+			ctx.Log.Info("confirmed apply is inside the change window %q", window)
+		} else if ctx.Command.Emergency {
+			ctx.Log.Warn("EMERGENCY APPLY: %s is bypassing the change window %q for the %s environment with ticket %s",
+				ctx.User.Username, window, ctx.Command.Environment, ctx.Command.Ticket)
+		} else {
+			return CommandResponse{Failure: fmt.Sprintf("Apply is outside the approved change window for the %s environment: %s.", ctx.Command.Environment, window)}
+		}
+	}
+
+	if policy.requiresApproval(ctx.Command.Environment) {
+		failure, err := a.approvalFailure(ctx, policy)
 		if err != nil {
+			approvals.Internal = audit.Errored
 			return CommandResponse{Error: errors.Wrap(err, "checking if pull request was approved")}
 		}
-		if !approved {
-			return CommandResponse{Failure: "Pull request must be approved before running apply."}
+		if failure != "" {
+			approvals.Internal = audit.NotApproved
+			return CommandResponse{Failure: failure}
 		}
+		approvals.Internal = audit.Approved
 		ctx.Log.Info("confirmed pull request was approved")
 	}
 
-	if a.RequireExternalApproval {
-		approved, err := a.checkExternalApproval(ctx, ctx.BaseRepo, ctx.Pull)
+	if policy.requiresExternalApproval(ctx.Command.Environment) {
+		decision, err := a.checkExternalApproval(ctx, policy, ctx.BaseRepo, ctx.Pull)
+		approvals.External = approvalOutcome(decision.Decision, err)
 		if err != nil {
 			return CommandResponse{Error: errors.Wrap(err, "checking if pull request was approved (external)")}
 		}
-		if !approved {
-			return CommandResponse{Failure: "Pull request must be approved before running apply. (external)"}
+		if failure := decision.failure(); failure != "" {
+			return CommandResponse{Failure: failure}
+		}
+		approvals.ExternalDecision = &webhooks.ExternalApproval{
+			Decision:  decision.Decision,
+			Approvals: decision.Approvals,
+			Quorum:    decision.Quorum,
+			Total:     decision.Total,
 		}
 		ctx.Log.Info("confirmed pull request was approved (external)")
 	}
@@ -114,75 +655,435 @@ func (a *ApplyExecutor) Execute(ctx *CommandContext) CommandResponse {
 
 	// plans are stored at project roots by their environment names. We just need to find them
 	var plans []models.Plan
+	// planned is when each plan was planned by its local path.
+	planned := make(map[string]time.Time)
 	err = filepath.Walk(repoDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		// if the plan is for the right env,
-		if !info.IsDir() && info.Name() == ctx.Command.Environment+".tfplan" {
-			rel, _ := filepath.Rel(repoDir, filepath.Dir(path))
-			plans = append(plans, models.Plan{
-				Project:   models.NewProject(ctx.BaseRepo.FullName, rel),
-				LocalPath: path,
-			})
+		planName := ctx.Command.Environment + ".tfplan"
+		if info.IsDir() || (info.Name() != planName && info.Name() != planName+EncryptedPlanSuffix) {
+			return nil
 		}
+		encrypted := info.Name() != planName
+		localPath := strings.TrimSuffix(path, EncryptedPlanSuffix)
+		// A plan that's decrypted next to its encrypted file is only left
+		// behind if an apply was interrupted so the encrypted one is used.
+		if !encrypted {
+			if _, err := os.Stat(path + EncryptedPlanSuffix); err == nil {
+				return nil
+			}
+			// With a key, plans are encrypted however planning ends, so an
+			// unencrypted one is from a plan that crashed or that ran
+			// before plans were encrypted. It's removed so it's never
+			// applied.
+			if a.PlanEncryptor != nil {
+				ctx.Log.Warn("removing unencrypted plan %q", path)
+				return os.Remove(path)
+			}
+		}
+		rel, _ := filepath.Rel(repoDir, filepath.Dir(path))
+		plans = append(plans, models.Plan{
+			Project:   models.NewProject(ctx.BaseRepo.FullName, rel),
+			LocalPath: localPath,
+			Encrypted: encrypted,
+		})
+		planned[localPath] = info.ModTime()
 		return nil
 	})
 	if err != nil {
 		return CommandResponse{Error: errors.Wrap(err, "finding plans")}
 	}
+	if ctx.Command.ProjectName != "" {
+		path, err := FindProjectByName(a.ProjectPreExecute.ConfigReader, repoDir, ctx.Command.ProjectName)
+		if err != nil {
+			return CommandResponse{Failure: err.Error()}
+		}
+		var named []models.Plan
+		for _, plan := range plans {
+			if plan.Project.Path == path {
+				named = append(named, plan)
+			}
+		}
+		if len(named) == 0 {
+			return CommandResponse{Failure: fmt.Sprintf("No plan found for project %q in that environment. Did you run plan?", ctx.Command.ProjectName)}
+		}
+		plans = named
+	}
 	if len(plans) == 0 {
 		return CommandResponse{Failure: "No plans found for that environment."}
 	}
+	if failure := a.planAgeFailure(plans, planned); failure != "" {
+		return CommandResponse{Failure: failure}
+	}
+	if failure := a.ProjectLimit.Check(ctx.BaseRepo.FullName, len(plans)); failure != "" {
+		return CommandResponse{Failure: failure}
+	}
 	var paths []string
 	for _, p := range plans {
 		paths = append(paths, p.LocalPath)
 	}
 	ctx.Log.Info("found %d plan(s) in our workspace: %v", len(plans), paths)
 
-	results := []ProjectResult{}
+	// Every project is applied with the same credentials so we only check
+	// the account once.
+	if a.AccountGuard != nil {
+		failure, err := a.AccountGuard.Check(ctx)
+		if err != nil {
+			return CommandResponse{Error: err}
+		}
+		if failure != "" {
+			return CommandResponse{Failure: failure}
+		}
+	}
+
+	// dependencies maps the path of each project to the projects being
+	// applied that it depends on.
+	var dependencies map[string][]string
+	if a.Terragrunt != nil {
+		plans, dependencies, err = a.sortPlans(repoDir, plans)
+		if err != nil {
+			return CommandResponse{Error: err}
+		}
+	}
+
+	// Sent once for the whole command so on-call know about the apply
+	// before it changes anything.
+	a.Webhooks.Send(ctx.Context, ctx.Log, webhooks.ApplyResult{ // nolint: errcheck
+		Workspace:        ctx.Command.Environment,
+		User:             ctx.User,
+		Repo:             ctx.BaseRepo,
+		Pull:             ctx.Pull,
+		Emergency:        ctx.Command.Emergency,
+		Ticket:           ctx.Command.Ticket,
+		PreApply:         true,
+		Changes:          totalPlanChanges(plans),
+		ExternalApproval: approvals.ExternalDecision,
+	})
+
+	results := a.applyPlans(ctx, repoDir, plans, dependencies, approvals)
+	applied := make(map[string]bool)
+	for i, plan := range plans {
+		applied[plan.Project.Path] = results[i].Status() == vcs.Success
+	}
+	a.ApplyRollup.Record(ctx, applied)
+	a.updateTicket(ctx, results)
+	return CommandResponse{ProjectResults: results}
+}
+
+// planAgeFailure returns a failure message if any of plans were planned more
+// than MaxPlanAge ago. Otherwise it returns "".
+func (a *ApplyExecutor) planAgeFailure(plans []models.Plan, planned map[string]time.Time) string {
+	if a.MaxPlanAge <= 0 {
+		return ""
+	}
+	var stale []string
 	for _, plan := range plans {
+		if age := time.Since(planned[plan.LocalPath]); age > a.MaxPlanAge {
+			stale = append(stale, fmt.Sprintf("`%s` was planned %s ago", plan.Project.Path, age.Truncate(time.Second)))
+		}
+	}
+	if len(stale) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Plans older than %s can't be applied since the infrastructure may have changed: %s. Run plan again.", a.MaxPlanAge, strings.Join(stale, ", "))
+}
+
+// appliedProjects is which projects of an apply have failed so far. It's
+// shared by the projects being applied at the same time.
+type appliedProjects struct {
+	mutex  sync.Mutex
+	failed map[string]bool
+}
+
+func (p *appliedProjects) record(path string, result ProjectResult) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if result.Status() != vcs.Success {
+		p.failed[path] = true
+	}
+}
+
+// firstFailed returns the first of paths that failed or "" if none did.
+func (p *appliedProjects) firstFailed(paths []string) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return firstFailed(paths, p.failed)
+}
+
+func (p *appliedProjects) anyFailed() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.failed) > 0
+}
+
+// applyPlans applies plans, up to ParallelApplyLimit at a time, and returns
+// their results in the same order as plans no matter which finishes first.
+// A plan is only applied once the plans it depends on have been.
+func (a *ApplyExecutor) applyPlans(ctx *CommandContext, repoDir string, plans []models.Plan, dependencies map[string][]string, approvals *applyApprovals) []ProjectResult {
+	results := make([]ProjectResult, len(plans))
+	projects := &appliedProjects{failed: make(map[string]bool)}
+	if a.ParallelApplyLimit <= 1 {
+		for i, plan := range plans {
+			results[i] = a.applyPlan(ctx, repoDir, plan, dependencies[plan.Project.Path], projects, approvals)
+		}
+		return results
+	}
+
+	done := make(map[string]chan struct{})
+	for _, plan := range plans {
+		done[plan.Project.Path] = make(chan struct{})
+	}
+	// A plan waits for its dependencies before taking a slot so the slots
+	// are only held by plans that can be applied.
+	slots := make(chan struct{}, a.ParallelApplyLimit)
+	var wg sync.WaitGroup
+	for i, plan := range plans {
+		wg.Add(1)
+		go func(i int, plan models.Plan) {
+			defer wg.Done()
+			defer close(done[plan.Project.Path])
+			for _, dep := range dependencies[plan.Project.Path] {
+				<-done[dep]
+			}
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			// The command's logger isn't safe to use from more than one
+			// goroutine.
+			projectCtx := *ctx
+			projectCtx.Log = logging.NewSimpleLogger(ctx.Log.Source, ctx.Log.Logger, false, ctx.Log.Level)
+			results[i] = a.applyPlan(&projectCtx, repoDir, plan, dependencies[plan.Project.Path], projects, approvals)
+		}(i, plan)
+	}
+	wg.Wait()
+	return results
+}
+
+// applyPlan applies plan unless one of its dependencies failed to apply or,
+// with ApplyFailFast, any project has.
+func (a *ApplyExecutor) applyPlan(ctx *CommandContext, repoDir string, plan models.Plan, dependencies []string, projects *appliedProjects, approvals *applyApprovals) ProjectResult {
+	var result ProjectResult
+	if dep := projects.firstFailed(dependencies); dep != "" {
+		ctx.Log.Info("not applying project at path %q since its dependency %q failed", plan.Project.Path, dep)
+		result = ProjectResult{Failure: fmt.Sprintf("Not applied because its dependency %s failed to apply.", dep)}
+	} else if a.ApplyFailFast && projects.anyFailed() {
+		ctx.Log.Info("not applying project at path %q since another project failed", plan.Project.Path)
+		result = ProjectResult{Failure: "Not applied because another project failed to apply."}
+	} else {
 		ctx.Log.Info("running apply for project at path %q", plan.Project.Path)
-		result := a.apply(ctx, repoDir, plan)
-		result.Path = plan.LocalPath
-		results = append(results, result)
+		result = a.apply(ctx, repoDir, plan, approvals)
 	}
-	return CommandResponse{ProjectResults: results}
+	a.CommandMetrics.ObserveResult(Apply, ctx.Command.Environment, result)
+	a.audit(ctx, approvals, plan.Project.Path, result)
+	projects.record(plan.Project.Path, result)
+	result.Path = plan.LocalPath
+	return result
 }
 
-func (a *ApplyExecutor) apply(ctx *CommandContext, repoDir string, plan models.Plan) ProjectResult {
+// sortPlans returns plans in the order that their terragrunt modules need to
+// be applied in and the modules being applied that each one depends on.
+func (a *ApplyExecutor) sortPlans(repoDir string, plans []models.Plan) ([]models.Plan, map[string][]string, error) {
+	byPath := make(map[string]models.Plan)
+	var paths []string
+	for _, plan := range plans {
+		byPath[plan.Project.Path] = plan
+		paths = append(paths, plan.Project.Path)
+	}
+	sorted, dependencies, err := a.Terragrunt.SortByDependencies(repoDir, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	var sortedPlans []models.Plan
+	for _, path := range sorted {
+		sortedPlans = append(sortedPlans, byPath[path])
+	}
+	return sortedPlans, dependencies, nil
+}
+
+// firstFailed returns the first of paths that's in failed or "" if none are.
+func firstFailed(paths []string, failed map[string]bool) string {
+	for _, path := range paths {
+		if failed[path] {
+			return path
+		}
+	}
+	return ""
+}
+
+// updateTicket comments the summary and status of the change ticket on the
+// pull request. If every project applied, it also transitions the ticket with
+// the transition for the environment so the change is traceable from Jira.
+func (a *ApplyExecutor) updateTicket(ctx *CommandContext, results []ProjectResult) {
+	if a.Jira == nil || ctx.Command.Ticket == "" {
+		return
+	}
+	var comment string
+	issue, err := a.Jira.GetIssue(ctx.Command.Ticket)
+	if err != nil {
+		ctx.Log.Warn("unable to look up change ticket: %s", err)
+		comment = fmt.Sprintf("**Change ticket**: couldn't look up %s in Jira: %s", ctx.Command.Ticket, err)
+	} else {
+		comment = fmt.Sprintf("**Change ticket** [%s](%s): %s\n\nStatus: %s", issue.Key, issue.URL, issue.Summary, issue.Status)
+		if transition, ok := a.JiraTransitions[ctx.Command.Environment]; ok && allSucceeded(results) {
+			if err := a.Jira.Transition(issue.Key, transition); err != nil {
+				ctx.Log.Warn("unable to transition change ticket: %s", err)
+				comment += fmt.Sprintf("\n\nCouldn't transition it with %q: %s", transition, err)
+			} else {
+				ctx.Log.Info("transitioned change ticket %s with %q", issue.Key, transition)
+				comment += fmt.Sprintf("\n\nTransitioned it with %q.", transition)
+			}
+		}
+	}
+	if err := a.VCSClient.CreateComment(ctx.Context, ctx.BaseRepo, ctx.Pull, comment, ctx.VCSHost); err != nil {
+		ctx.Log.Warn("unable to comment change ticket: %s", err)
+	}
+}
+
+func allSucceeded(results []ProjectResult) bool {
+	for _, r := range results {
+		if r.Status() != vcs.Success {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *ApplyExecutor) apply(ctx *CommandContext, repoDir string, plan models.Plan, approvals *applyApprovals) ProjectResult {
 	preExecute := a.ProjectPreExecute.Execute(ctx, repoDir, plan.Project)
 	if preExecute.ProjectResult != (ProjectResult{}) {
 		return preExecute.ProjectResult
 	}
 	config := preExecute.ProjectConfig
 	terraformVersion := preExecute.TerraformVersion
+	if plan.Encrypted {
+		cleanup, err := a.PlanEncryptor.Decrypt(repoDir, ctx.Command.Environment, plan.LocalPath)
+		if err != nil {
+			return ProjectResult{Error: err}
+		}
+		defer cleanup()
+	}
 
-	applyExtraArgs := config.GetExtraArguments(ctx.Command.Name.String())
+	applyExtraArgs := config.GetExtraArgumentsForEnv(ctx.Command.Name.String(), ctx.Command.Environment)
 	absolutePath := filepath.Join(repoDir, plan.Project.Path)
 	env := ctx.Command.Environment
-	tfApplyCmd := append(append(append([]string{"apply", "-no-color"}, applyExtraArgs...), ctx.Command.Flags...), plan.LocalPath)
-	output, err := a.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, tfApplyCmd, terraformVersion, env)
-
-	a.Webhooks.Send(ctx.Log, webhooks.ApplyResult{ // nolint: errcheck
-		Workspace: env,
-		User:      ctx.User,
-		Repo:      ctx.BaseRepo,
-		Pull:      ctx.Pull,
-		Success:   err == nil,
+	var output string
+	var err error
+	var remoteRun *tfc.Run
+	var summary *PlanSummary
+	var showOutput string
+	remote := a.TFC != nil && tfc.UsesRemoteBackend(absolutePath)
+	if !remote {
+		summary, showOutput = a.planSummary(ctx, absolutePath, plan, terraformVersion, env, config.Command)
+	}
+	if approvals.DestroyConfirmation && !ctx.Command.DestroyOK {
+		if failure := destroyConfirmationFailure(env, plan, summary, showOutput, a.DestroyWarning); failure != "" {
+			return ProjectResult{Failure: failure, PlanSummary: summary}
+		}
+	}
+	start := time.Now()
+	if remote {
+		workspace, ok := a.TFCWorkspaces[env]
+		if !ok {
+			return ProjectResult{Failure: fmt.Sprintf("No Terraform Cloud workspace is mapped to the %s environment.", env)}
+		}
+		remoteRun, output, err = a.remoteApply(ctx, workspace, plan)
+	} else {
+		tfApplyCmd := append(append(append([]string{"apply", "-no-color"}, applyExtraArgs...), ctx.Command.Flags...), plan.LocalPath)
+		_, span := tracing.Start(ctx.Context, "terraform apply")
+		span.SetAttribute("atlantis.project", plan.Project.Path)
+		output, err = a.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, tfApplyCmd, terraformVersion, env, config.Command, ctx.AWSProfile)
+		a.CommandMetrics.ObserveTerraform(Apply, env, time.Since(start))
+		span.SetError(err)
+		span.End()
+	}
+
+	a.Webhooks.Send(ctx.Context, ctx.Log, webhooks.ApplyResult{ // nolint: errcheck
+		Workspace:        env,
+		User:             ctx.User,
+		Repo:             ctx.BaseRepo,
+		Pull:             ctx.Pull,
+		Success:          err == nil,
+		Emergency:        ctx.Command.Emergency,
+		Ticket:           ctx.Command.Ticket,
+		Changes:          totalPlanChanges([]models.Plan{plan}),
+		Project:          plan.Project.Path,
+		Output:           output,
+		Duration:         time.Since(start),
+		ExternalApproval: approvals.ExternalDecision,
 	})
 
+	if notFoundErr, ok := err.(*terraform.ExecutableNotFoundError); ok {
+		return ProjectResult{Failure: notFoundErr.Error()}
+	}
 	if err != nil {
-		return ProjectResult{Error: fmt.Errorf("%s\n%s", err.Error(), output)}
+		return ProjectResult{Error: fmt.Errorf("%s\n%s", err.Error(), output), RemoteRun: remoteRun, PlanSummary: summary}
 	}
 	ctx.Log.Info("apply succeeded")
 
 	if len(config.PostApply) > 0 {
-		_, err := a.Run.Execute(ctx.Log, config.PostApply, absolutePath, env, terraformVersion, "post_apply")
+		_, err := a.Run.Execute(ctx.Log, config.PostApply, absolutePath, env, terraformVersion, "post_apply", ctx.AWSProfile)
 		if err != nil {
-			return ProjectResult{Error: errors.Wrap(err, "running post apply commands")}
+			return ProjectResult{Error: errors.Wrap(err, "running post apply commands"), RemoteRun: remoteRun, PlanSummary: summary}
 		}
 	}
 
-	return ProjectResult{ApplySuccess: output}
+	return ProjectResult{ApplySuccess: output, RemoteRun: remoteRun, PlanSummary: summary}
+}
+
+// planShowJSONVersion is the first version of terraform that can show plans
+// as json.
+var planShowJSONVersion = version.Must(version.NewVersion("0.12.0"))
+
+// planSummary returns the summary of plan before it's applied and the output
+// of terraform show -json it's from, or nil if terraform can't show it, ex.
+// because it's older than 0.12. The summary is only informational so failing
+// to get it doesn't fail the apply.
+func (a *ApplyExecutor) planSummary(ctx *CommandContext, absolutePath string, plan models.Plan, terraformVersion *version.Version, env string, command string) (*PlanSummary, string) {
+	if terraformVersion.LessThan(planShowJSONVersion) {
+		ctx.Log.Debug("not summarizing the plan since terraform %s can't show it as json", terraformVersion)
+		return nil, ""
+	}
+	showOutput, err := a.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, []string{"show", "-json", plan.LocalPath}, terraformVersion, env, command, ctx.AWSProfile)
+	if err != nil {
+		ctx.Log.Warn("unable to show the plan to summarize it: %s", err)
+		return nil, ""
+	}
+	summary, err := ParsePlanSummary(showOutput)
+	if err != nil {
+		ctx.Log.Warn("unable to summarize the plan: %s", err)
+		return nil, ""
+	}
+	if len(summary.Destroyed) > 0 {
+		ctx.Log.Warn("applying a plan that destroys %d resource(s): %s", len(summary.Destroyed), strings.Join(summary.Destroyed, ", "))
+	}
+	return summary, showOutput
+}
+
+// remoteApply applies the Terraform Cloud run whose ID was saved in the plan
+// file and returns the run and its apply log.
+func (a *ApplyExecutor) remoteApply(ctx *CommandContext, workspace string, plan models.Plan) (*tfc.Run, string, error) {
+	runID, err := ioutil.ReadFile(plan.LocalPath)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "reading terraform cloud run id")
+	}
+	_, span := tracing.Start(ctx.Context, "terraform cloud apply")
+	span.SetAttribute("atlantis.project", plan.Project.Path)
+	defer span.End()
+	comment := fmt.Sprintf("Applied by Atlantis for %s#%d by %s", ctx.BaseRepo.FullName, ctx.Pull.Num, ctx.User.Username)
+	run, err := a.TFC.Apply(ctx.Context, workspace, strings.TrimSpace(string(runID)), comment)
+	if err != nil {
+		span.SetError(err)
+		return nil, "", errors.Wrap(err, "applying in terraform cloud")
+	}
+	ctx.Log.Info("terraform cloud run %s finished with status %s", run.ID, run.Status)
+	if run.Status == "planned_and_finished" {
+		return &run, "No changes to apply.", nil
+	}
+	if !run.Applied() {
+		err := fmt.Errorf("terraform cloud run %s", run.Status)
+		span.SetError(err)
+		return &run, run.Log, err
+	}
+	return &run, run.Log, nil
 }
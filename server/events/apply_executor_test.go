@@ -0,0 +1,677 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/audit"
+	"github.com/hootsuite/atlantis/server/events/locking"
+	lmocks "github.com/hootsuite/atlantis/server/events/locking/mocks"
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/events/terraform"
+	"github.com/hootsuite/atlantis/server/events/webhooks"
+	wmocks "github.com/hootsuite/atlantis/server/events/webhooks/mocks"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+var applyCtx = events.CommandContext{
+	Context:  context.Background(),
+	BaseRepo: models.Repo{Owner: "owner", Name: "repo", FullName: "owner/repo"},
+	Pull:     models.PullRequest{Num: 1},
+	User:     models.User{Username: "alice"},
+	Command:  &events.Command{Name: events.Apply, Environment: "production"},
+	Log:      logging.NewNoopLogger(),
+}
+
+// approvalExecutor returns an ApplyExecutor that requires the service
+// handled by handler to approve applies.
+func approvalExecutor(t *testing.T, handler http.HandlerFunc, timeout time.Duration) (*events.ApplyExecutor, func()) {
+	service := httptest.NewServer(handler)
+	a := &events.ApplyExecutor{}
+	a.SetPolicy(events.ApplyPolicy{
+		RequireExternalApproval: true,
+		ApprovalURLs:            []string{service.URL},
+		ApprovalTimeout:         timeout,
+	})
+	return a, service.Close
+}
+
+func TestApplyExecutor_ExternalApprovalTimeout(t *testing.T) {
+	t.Log("an approval service that doesn't respond in time should be an error, not a denial")
+	release := make(chan struct{})
+	a, stop := approvalExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}, 50*time.Millisecond)
+	defer stop()
+	defer close(release)
+
+	start := time.Now()
+	r := a.Execute(&applyCtx)
+	Assert(t, time.Since(start) < time.Second, "exp the timeout to be honored")
+	Assert(t, r.Error != nil, "exp an error")
+	Equals(t, "checking if pull request was approved (external): approval service didn't respond within 50ms", r.Error.Error())
+	Equals(t, "", r.Failure)
+}
+
+func TestApplyExecutor_ExternalApprovalMalformedResponse(t *testing.T) {
+	t.Log("an approval response that isn't JSON should be an error, not a denial")
+	a, stop := approvalExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Approved": tru`)) // nolint: errcheck
+	}, time.Second)
+	defer stop()
+
+	r := a.Execute(&applyCtx)
+	Assert(t, r.Error != nil, "exp an error")
+	Equals(t, "checking if pull request was approved (external): parsing approval response: unexpected end of JSON input", r.Error.Error())
+}
+
+func TestApplyExecutor_ExternalApprovalInvalidURL(t *testing.T) {
+	t.Log("an approval url that a request can't be built for should be an error instead of a panic")
+	a := &events.ApplyExecutor{}
+	a.SetPolicy(events.ApplyPolicy{RequireExternalApproval: true, ApprovalURLs: []string{"://approvals"}, ApprovalTimeout: time.Second})
+
+	r := a.Execute(&applyCtx)
+	Assert(t, r.Error != nil, "exp an error")
+	Equals(t, `checking if pull request was approved (external): building approval request: parse "://approvals": missing protocol scheme`, r.Error.Error())
+}
+
+func TestApplyExecutor_ExternalApprovalRequest(t *testing.T) {
+	t.Log("the approval service should be sent the pull request's commit, environment and user")
+	var body []byte
+	a, stop := approvalExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte(`{"Approved": false}`)) // nolint: errcheck
+	}, time.Second)
+	defer stop()
+	ctx := applyCtx
+	ctx.BaseRepo = models.Repo{Owner: "owner", Name: `re"po`, FullName: `owner/re"po`}
+	ctx.Pull = models.PullRequest{Num: 1, HeadCommit: "abc123"}
+
+	r := a.Execute(&ctx)
+	Equals(t, "Pull request is still waiting for a decision from the approval service. (external)", r.Failure)
+	Equals(t, `{"repo_owner":"owner","repo_name":"re\"po","pull_request":1,"head_commit":"abc123","environment":"production","user":"alice"}`, string(body))
+}
+
+func TestApplyExecutor_ExternalApprovalSignature(t *testing.T) {
+	t.Log("approval requests should be signed with the HMAC-SHA256 of their body")
+	var body []byte
+	var signature string
+	a, stop := approvalExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(events.ApprovalSignatureHeader)
+		w.Write([]byte(`{"Approved": false}`)) // nolint: errcheck
+	}, time.Second)
+	defer stop()
+	policy := a.Policy()
+	policy.ApprovalSigningSecret = []byte("secret")
+	a.SetPolicy(policy)
+
+	a.Execute(&applyCtx)
+	Equals(t, `{"repo_owner":"owner","repo_name":"repo","pull_request":1,"head_commit":"","environment":"production","user":"alice"}`, string(body))
+	Equals(t, "sha256=4d3b70554cac4bd7ac53b2d694e25f88140d4bb3cd4415321aa80388daeba63d", signature)
+
+	t.Log("without a secret the requests shouldn't be signed")
+	policy.ApprovalSigningSecret = nil
+	a.SetPolicy(policy)
+	a.Execute(&applyCtx)
+	Equals(t, "", signature)
+}
+
+func TestApplyExecutor_ExternalApprovalRetries(t *testing.T) {
+	t.Log("a 5xx from the approval service should be retried")
+	requests, failures := 0, 2
+	a, stop := approvalExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"Approved": false}`)) // nolint: errcheck
+	}, time.Second)
+	defer stop()
+	policy := a.Policy()
+	policy.ApprovalRetries = 2
+	policy.ApprovalRetryBackoff = time.Millisecond
+	a.SetPolicy(policy)
+
+	r := a.Execute(&applyCtx)
+	Equals(t, 3, requests)
+	Equals(t, "Pull request is still waiting for a decision from the approval service. (external)", r.Failure)
+
+	t.Log("the last error should be returned once the retries run out")
+	requests, failures = 0, 3
+	r = a.Execute(&applyCtx)
+	Equals(t, 3, requests)
+	Assert(t, r.Error != nil, "exp an error")
+	Equals(t, "checking if pull request was approved (external): gave up after 3 attempts: approval service responded with status 503", r.Error.Error())
+}
+
+func TestApplyExecutor_ExternalApprovalDenialNotRetried(t *testing.T) {
+	t.Log("a 403 from the approval service is a decision so it shouldn't be retried")
+	requests := 0
+	a, stop := approvalExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	}, time.Second)
+	defer stop()
+	policy := a.Policy()
+	policy.ApprovalRetries = 2
+	policy.ApprovalRetryBackoff = time.Millisecond
+	a.SetPolicy(policy)
+
+	r := a.Execute(&applyCtx)
+	Equals(t, 1, requests)
+	Equals(t, "Pull request was denied by the approval service. (external)", r.Failure)
+}
+
+func TestApplyExecutor_ExternalApprovalDecisions(t *testing.T) {
+	cases := []struct {
+		Description string
+		Status      int
+		Body        string
+		Failure     string
+		Error       string
+	}{
+		{
+			"an approval without a status",
+			http.StatusOK,
+			`{"Approved": false}`,
+			"Pull request is still waiting for a decision from the approval service. (external)",
+			"",
+		},
+		{
+			"a denial",
+			http.StatusOK,
+			`{"Approved": false, "Status": "denied"}`,
+			"Pull request was denied by the approval service. (external)",
+			"",
+		},
+		{
+			"a pending approval",
+			http.StatusOK,
+			`{"Approved": false, "Status": "pending"}`,
+			"Pull request is still waiting for a decision from the approval service. (external)",
+			"",
+		},
+		{
+			"an approval with a contradicting status",
+			http.StatusOK,
+			`{"Approved": true, "Status": "denied"}`,
+			"",
+			`checking if pull request was approved (external): approval response has status "denied" but approved is true`,
+		},
+		{
+			"an unknown status",
+			http.StatusOK,
+			`{"Approved": false, "Status": "maybe"}`,
+			"",
+			`checking if pull request was approved (external): approval response has unknown status "maybe"`,
+		},
+		{
+			"an unexpected status code",
+			http.StatusNotFound,
+			"",
+			"",
+			"checking if pull request was approved (external): approval service responded with status 404",
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.Description + " should be reported as such")
+		a, stop := approvalExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(c.Status)
+			w.Write([]byte(c.Body)) // nolint: errcheck
+		}, time.Second)
+		r := a.Execute(&applyCtx)
+		stop()
+		Equals(t, c.Failure, r.Failure)
+		if c.Error == "" {
+			Ok(t, r.Error)
+		} else {
+			Assert(t, r.Error != nil, "exp an error")
+			Equals(t, c.Error, r.Error.Error())
+		}
+	}
+}
+
+func TestApplyExecutor_AuditLog(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+
+	cases := []struct {
+		Description string
+		Status      int
+		Body        string
+		External    string
+		Result      string
+	}{
+		{"a denial", http.StatusOK, `{"Approved": false, "Status": "denied"}`, "denied", "failure"},
+		{"a pending approval", http.StatusOK, `{"Approved": false}`, "pending", "failure"},
+		{"an approval service error", http.StatusInternalServerError, "token=hunter2", "errored", "error"},
+	}
+	for i, c := range cases {
+		t.Log(c.Description + " should be audited without the service's response")
+		a, stop := approvalExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(c.Status)
+			w.Write([]byte(c.Body)) // nolint: errcheck
+		}, time.Second)
+		auditPath := filepath.Join(tmp, fmt.Sprintf("audit-%d.jsonl", i))
+		a.AuditLog, err = audit.NewLog(auditPath)
+		Ok(t, err)
+		ctx := applyCtx
+		ctx.Pull.HeadCommit = "abc123"
+		a.Execute(&ctx)
+		stop()
+
+		contents, err := ioutil.ReadFile(auditPath)
+		Ok(t, err)
+		Assert(t, !strings.Contains(string(contents), "hunter2"), "exp the response to not be audited in %q", string(contents))
+		var entry audit.Entry
+		Ok(t, json.Unmarshal(contents, &entry))
+		Equals(t, "alice", entry.User)
+		Equals(t, "owner/repo", entry.Repo)
+		Equals(t, 1, entry.PullNum)
+		Equals(t, "abc123", entry.Commit)
+		Equals(t, "production", entry.Environment)
+		Equals(t, "", entry.Project)
+		Equals(t, audit.NotRequired, entry.InternalApproval)
+		Equals(t, c.External, entry.ExternalApproval)
+		Equals(t, c.Result, entry.Result)
+	}
+}
+
+func TestApplyExecutor_ParallelApplyLimit(t *testing.T) {
+	t.Log("projects should be applied up to the limit at a time and their results kept in order")
+	locker := &slowLocker{delay: 20 * time.Millisecond}
+	a, workspace, cleanup := parallelApplyExecutor(t, locker, []string{"a", "b", "c", "d", "e", "f"})
+	defer cleanup()
+	a.ParallelApplyLimit = 3
+
+	r := a.Execute(&applyCtx)
+	Ok(t, r.Error)
+	Equals(t, 6, len(r.ProjectResults))
+	for i, project := range []string{"a", "b", "c", "d", "e", "f"} {
+		Equals(t, filepath.Join(workspace, project, "production.tfplan"), r.ProjectResults[i].Path)
+		Equals(t, "This project is currently locked by #2. The locking plan must be applied or discarded before future plans can execute.", r.ProjectResults[i].Failure)
+	}
+	Equals(t, 6, locker.calls)
+	Assert(t, locker.maxInFlight > 1, "exp projects to be applied at the same time")
+	Assert(t, locker.maxInFlight <= 3, "exp at most 3 projects to be applied at the same time but got %d", locker.maxInFlight)
+}
+
+func TestApplyExecutor_ApplyFailFast(t *testing.T) {
+	t.Log("with fail fast, the projects after a failed one shouldn't be applied")
+	locker := &slowLocker{}
+	a, _, cleanup := parallelApplyExecutor(t, locker, []string{"a", "b", "c"})
+	defer cleanup()
+	a.ApplyFailFast = true
+
+	r := a.Execute(&applyCtx)
+	Ok(t, r.Error)
+	Equals(t, 3, len(r.ProjectResults))
+	Equals(t, "This project is currently locked by #2. The locking plan must be applied or discarded before future plans can execute.", r.ProjectResults[0].Failure)
+	Equals(t, "Not applied because another project failed to apply.", r.ProjectResults[1].Failure)
+	Equals(t, "Not applied because another project failed to apply.", r.ProjectResults[2].Failure)
+	Equals(t, 1, locker.calls)
+}
+
+// parallelApplyExecutor returns an ApplyExecutor with a plan for each of
+// projects in its workspace, which it also returns, and whose projects are
+// locked with locker.
+func parallelApplyExecutor(t *testing.T, locker locking.Locker, projects []string) (*events.ApplyExecutor, string, func()) {
+	RegisterMockTestingT(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	workspace := filepath.Join(tmp, "repos", "owner/repo", "1", "production")
+	for _, project := range projects {
+		Ok(t, os.MkdirAll(filepath.Join(workspace, project), 0700))
+		Ok(t, ioutil.WriteFile(filepath.Join(workspace, project, "production.tfplan"), nil, 0600))
+	}
+	a := &events.ApplyExecutor{
+		Workspace:         &events.FileWorkspace{DataDir: tmp},
+		ProjectPreExecute: &events.ProjectPreExecute{Locker: locker},
+		Webhooks:          wmocks.NewMockSender(),
+	}
+	return a, workspace, func() { os.RemoveAll(tmp) } // nolint: errcheck
+}
+
+func TestApplyExecutor_WebhookExternalApproval(t *testing.T) {
+	t.Log("webhooks should be sent the decision of the approval services")
+	approver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Approved": true}`)) // nolint: errcheck
+	}))
+	defer approver.Close()
+	a, _, cleanup := parallelApplyExecutor(t, &slowLocker{}, []string{"a"})
+	defer cleanup()
+	sender := &recordingSender{}
+	a.Webhooks = sender
+	a.SetPolicy(events.ApplyPolicy{
+		RequireExternalApproval: true,
+		ApprovalURLs:            []string{approver.URL, approver.URL},
+		ApprovalTimeout:         time.Second,
+	})
+
+	a.Execute(&applyCtx)
+	Equals(t, 1, len(sender.results))
+	Equals(t, true, sender.results[0].PreApply)
+	Equals(t, &webhooks.ExternalApproval{Decision: events.ApprovalApproved, Approvals: 2, Quorum: 2, Total: 2}, sender.results[0].ExternalApproval)
+}
+
+// recordingSender is a webhooks.Sender that records what it's sent.
+type recordingSender struct {
+	results []webhooks.ApplyResult
+}
+
+func (s *recordingSender) Send(_ context.Context, log *logging.SimpleLogger, result webhooks.ApplyResult) error {
+	s.results = append(s.results, result)
+	return nil
+}
+
+// slowLocker is a Locker whose projects are locked by another pull request.
+// It takes delay to say so and counts how many locks are tried at the same
+// time.
+type slowLocker struct {
+	delay       time.Duration
+	mutex       sync.Mutex
+	calls       int
+	inFlight    int
+	maxInFlight int
+}
+
+func (l *slowLocker) TryLock(p models.Project, env string, pull models.PullRequest, user models.User) (locking.TryLockResponse, error) {
+	l.mutex.Lock()
+	l.calls++
+	l.inFlight++
+	if l.inFlight > l.maxInFlight {
+		l.maxInFlight = l.inFlight
+	}
+	l.mutex.Unlock()
+	time.Sleep(l.delay)
+	l.mutex.Lock()
+	l.inFlight--
+	l.mutex.Unlock()
+	return locking.TryLockResponse{CurrLock: models.ProjectLock{Pull: models.PullRequest{Num: 2}}}, nil
+}
+
+func (l *slowLocker) Unlock(key string) (*models.ProjectLock, error) { return nil, nil }
+
+func (l *slowLocker) List() (map[string]models.ProjectLock, error) { return nil, nil }
+
+func (l *slowLocker) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
+	return nil, nil
+}
+
+func (l *slowLocker) GetLock(key string) (*models.ProjectLock, error) { return nil, nil }
+
+func TestApplyExecutor_ExternalApprovalQuorum(t *testing.T) {
+	respond := func(status int, body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			w.Write([]byte(body)) // nolint: errcheck
+		}))
+	}
+	approver := respond(http.StatusOK, `{"Approved": true}`)
+	defer approver.Close()
+	denier := respond(http.StatusForbidden, "")
+	defer denier.Close()
+	undecided := respond(http.StatusOK, `{"Approved": false}`)
+	defer undecided.Close()
+	broken := respond(http.StatusBadRequest, "")
+	defer broken.Close()
+	host := func(s *httptest.Server) string { return strings.TrimPrefix(s.URL, "http://") }
+	noWorkspace := "No workspace found. Did you run plan?"
+
+	cases := []struct {
+		Description string
+		URLs        []string
+		Quorum      int
+		Failure     string
+		Error       string
+	}{
+		{
+			"all services approving",
+			[]string{approver.URL, approver.URL},
+			0,
+			noWorkspace,
+			"",
+		},
+		{
+			"one service denying when all have to approve",
+			[]string{approver.URL, denier.URL},
+			0,
+			"Pull request was denied by the approval service at " + host(denier) + ". (external)",
+			"",
+		},
+		{
+			"one service denying when the others make the quorum",
+			[]string{approver.URL, denier.URL, approver.URL},
+			2,
+			noWorkspace,
+			"",
+		},
+		{
+			"too many services denying for the quorum",
+			[]string{denier.URL, undecided.URL, denier.URL},
+			2,
+			"Pull request was denied by the approval services at " + host(denier) + ", " + host(denier) + ". (external)",
+			"",
+		},
+		{
+			"a service that hasn't decided",
+			[]string{approver.URL, undecided.URL},
+			0,
+			"Pull request is still waiting for a decision from the approval service at " + host(undecided) + ". It has 1 of the 2 approvals it needs. (external)",
+			"",
+		},
+		{
+			"a service erroring when it could still make the quorum",
+			[]string{approver.URL, broken.URL},
+			0,
+			"",
+			"checking if pull request was approved (external): approval service at " + host(broken) + ": approval service responded with status 400",
+		},
+		{
+			"a service erroring when the others make the quorum",
+			[]string{approver.URL, broken.URL},
+			1,
+			noWorkspace,
+			"",
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.Description + " should combine into the right decision")
+		tmp, err := ioutil.TempDir("", "")
+		Ok(t, err)
+		a := &events.ApplyExecutor{Workspace: &events.FileWorkspace{DataDir: tmp}}
+		a.SetPolicy(events.ApplyPolicy{
+			RequireExternalApproval: true,
+			ApprovalURLs:            c.URLs,
+			ApprovalQuorum:          c.Quorum,
+			ApprovalTimeout:         time.Second,
+		})
+		r := a.Execute(&applyCtx)
+		os.RemoveAll(tmp) // nolint: errcheck
+		Equals(t, c.Failure, r.Failure)
+		if c.Error == "" {
+			Ok(t, r.Error)
+		} else {
+			Assert(t, r.Error != nil, "exp an error")
+			Equals(t, c.Error, r.Error.Error())
+		}
+	}
+}
+
+func TestApplyExecutor_ExternalApprovalCancelledAtQuorum(t *testing.T) {
+	t.Log("once the quorum is reached the requests to the other services should be cancelled")
+	approver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Approved": true}`)) // nolint: errcheck
+	}))
+	defer approver.Close()
+	cancelled := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client going away once the body's
+		// been read.
+		ioutil.ReadAll(r.Body) // nolint: errcheck
+		<-r.Context().Done()
+		close(cancelled)
+	}))
+	defer slow.Close()
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	a := &events.ApplyExecutor{Workspace: &events.FileWorkspace{DataDir: tmp}}
+	a.SetPolicy(events.ApplyPolicy{
+		RequireExternalApproval: true,
+		ApprovalURLs:            []string{approver.URL, slow.URL},
+		ApprovalQuorum:          1,
+		ApprovalTimeout:         time.Minute,
+	})
+
+	start := time.Now()
+	r := a.Execute(&applyCtx)
+	Assert(t, time.Since(start) < 10*time.Second, "exp the slow request to be cancelled rather than time out")
+	Ok(t, r.Error)
+	Equals(t, "No workspace found. Did you run plan?", r.Failure)
+	select {
+	case <-cancelled:
+	case <-time.After(10 * time.Second):
+		t.Fatal("exp the slow service to see its request cancelled")
+	}
+}
+
+func TestApplyExecutor_ExternalApprovalCommandCancelled(t *testing.T) {
+	t.Log("cancelling the command should cancel its approval requests")
+	release := make(chan struct{})
+	a, stop := approvalExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body) // nolint: errcheck
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}, time.Minute)
+	defer stop()
+	defer close(release)
+	cmdCtx, cancel := context.WithCancel(context.Background())
+	ctx := applyCtx
+	ctx.Context = cmdCtx
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	r := a.Execute(&ctx)
+	Assert(t, time.Since(start) < 10*time.Second, "exp the request to be cancelled rather than time out")
+	Assert(t, r.Error != nil, "exp an error")
+	Equals(t, "checking if pull request was approved (external): calling approval service: context canceled", r.Error.Error())
+}
+
+func TestApplyExecutor_MaxPlanAge(t *testing.T) {
+	t.Log("plans older than the max plan age shouldn't be applied")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	repoDir := filepath.Join(tmp, "repos", "owner/repo", "1", "production")
+	for _, project := range []string{"a", "b"} {
+		Ok(t, os.MkdirAll(filepath.Join(repoDir, project), 0700))
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, project, "production.tfplan"), nil, 0600))
+	}
+	old := time.Now().Add(-3 * time.Hour)
+	Ok(t, os.Chtimes(filepath.Join(repoDir, "b", "production.tfplan"), old, old))
+	// The project limit fails applies that get past the plan age check.
+	a := &events.ApplyExecutor{
+		Workspace:    &events.FileWorkspace{DataDir: tmp},
+		ProjectLimit: &events.ProjectLimit{Max: 1},
+		MaxPlanAge:   time.Hour,
+	}
+
+	r := a.Execute(&applyCtx)
+	Assert(t, strings.HasPrefix(r.Failure, "Plans older than 1h0m0s can't be applied since the infrastructure may have changed: `b` was planned 3h0m"), "unexpected failure %q", r.Failure)
+	Assert(t, strings.HasSuffix(r.Failure, "ago. Run plan again."), "unexpected failure %q", r.Failure)
+
+	t.Log("a plan that was planned again should be applied")
+	Ok(t, os.Chtimes(filepath.Join(repoDir, "b", "production.tfplan"), time.Now(), time.Now()))
+	r = a.Execute(&applyCtx)
+	Equals(t, "This command would run in 2 projects, which is more than the limit of 1. Run it in one project at a time with -p <project name>.", r.Failure)
+
+	t.Log("without a max plan age old plans should be applied")
+	Ok(t, os.Chtimes(filepath.Join(repoDir, "b", "production.tfplan"), old, old))
+	a.MaxPlanAge = 0
+	r = a.Execute(&applyCtx)
+	Equals(t, "This command would run in 2 projects, which is more than the limit of 1. Run it in one project at a time with -p <project name>.", r.Failure)
+}
+
+// fakeTerraformExecutor returns an ApplyExecutor whose terraform, which has
+// to be first in PATH, is a fake of tfVersion in binDir that shows the plan as
+// showJSON and applies successfully. The executor applies plans of project a.
+func fakeTerraformExecutor(t *testing.T, binDir string, tfVersion string, showJSON string) *events.ApplyExecutor {
+	showFile := filepath.Join(binDir, "show.json")
+	Ok(t, ioutil.WriteFile(showFile, []byte(showJSON), 0600))
+	script := fmt.Sprintf("#!/bin/sh\ncase \"$1\" in\nversion) echo 'Terraform v%s';;\nshow) cat %q;;\napply) echo applied;;\nesac\n", tfVersion, showFile)
+	Ok(t, ioutil.WriteFile(filepath.Join(binDir, "terraform"), []byte(script), 0755))
+	tf, err := terraform.NewClient()
+	Ok(t, err)
+	locker := lmocks.NewMockLocker()
+	When(locker.TryLock(models.NewProject("owner/repo", "a"), "production", applyCtx.Pull, applyCtx.User)).
+		ThenReturn(locking.TryLockResponse{LockAcquired: true}, nil)
+	return &events.ApplyExecutor{
+		Terraform: tf,
+		Workspace: &events.FileWorkspace{DataDir: filepath.Dir(binDir)},
+		ProjectPreExecute: &events.ProjectPreExecute{
+			Locker:       locker,
+			ConfigReader: &events.ProjectConfigManager{},
+			Terraform:    tf,
+		},
+		Webhooks: wmocks.NewMockSender(),
+	}
+}
+
+// fakeTerraformDir returns a data dir in tmp with a plan for project a in the
+// production environment and puts its bin dir first in PATH. It returns the
+// path of the plan and the bin dir.
+func fakeTerraformDir(t *testing.T, tmp string) (string, string) {
+	planFile := filepath.Join(tmp, "repos", "owner/repo", "1", "production", "a", "production.tfplan")
+	Ok(t, os.MkdirAll(filepath.Dir(planFile), 0700))
+	Ok(t, ioutil.WriteFile(planFile, nil, 0600))
+	binDir := filepath.Join(tmp, "bin")
+	Ok(t, os.MkdirAll(binDir, 0700))
+	Ok(t, os.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH")))
+	return planFile, binDir
+}
+
+func TestApplyExecutor_PlanSummary(t *testing.T) {
+	t.Log("the summary of the plan should be returned with the apply")
+	RegisterMockTestingT(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path) // nolint: errcheck
+	_, binDir := fakeTerraformDir(t, tmp)
+
+	r := fakeTerraformExecutor(t, binDir, "0.12.0", destroyPlanJSON).Execute(&applyCtx)
+	Equals(t, 1, len(r.ProjectResults))
+	Ok(t, r.ProjectResults[0].Error)
+	Equals(t, "applied\n", r.ProjectResults[0].ApplySuccess)
+	Equals(t, &events.PlanSummary{
+		Changes:   webhooks.PlanChanges{Add: 2, Change: 1, Destroy: 4},
+		Destroyed: []string{"aws_db_instance.db", "aws_s3_bucket.logs", "null_resource.trigger", "random_id.suffix"},
+	}, r.ProjectResults[0].PlanSummary)
+
+	t.Log("terraform versions that can't show plans as json should apply without a summary")
+	r = fakeTerraformExecutor(t, binDir, "0.11.0", destroyPlanJSON).Execute(&applyCtx)
+	Equals(t, 1, len(r.ProjectResults))
+	Ok(t, r.ProjectResults[0].Error)
+	Equals(t, "applied\n", r.ProjectResults[0].ApplySuccess)
+	Assert(t, r.ProjectResults[0].PlanSummary == nil, "exp no summary")
+}
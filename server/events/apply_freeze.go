@@ -0,0 +1,120 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ApplyFreezeFile is the name of the file, relative to the data dir, that
+// the freeze of all applies is kept in so it survives restarts.
+const ApplyFreezeFile = "apply-freeze.json"
+
+// Freeze is why, by whom and since when applies have been frozen.
+type Freeze struct {
+	Reason string `json:"reason"`
+	// By is the name of the admin that froze applies.
+	By   string    `json:"by"`
+	Time time.Time `json:"time"`
+}
+
+// ApplyFreeze disables every apply, ex. during an incident, until the freeze
+// is lifted. Unlike locks, it's for all repos and environments. A nil
+// ApplyFreeze is never frozen.
+type ApplyFreeze struct {
+	Path   string
+	mutex  sync.RWMutex
+	freeze *Freeze
+}
+
+// NewApplyFreeze returns the ApplyFreeze that's kept in dataDir, which is
+// frozen if it was frozen when Atlantis stopped.
+func NewApplyFreeze(dataDir string) (*ApplyFreeze, error) {
+	f := &ApplyFreeze{Path: filepath.Join(dataDir, ApplyFreezeFile)}
+	contents, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", f.Path)
+	}
+	var freeze Freeze
+	if err := json.Unmarshal(contents, &freeze); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", f.Path)
+	}
+	f.freeze = &freeze
+	return f, nil
+}
+
+// Current returns the freeze or nil if applies aren't frozen.
+func (f *ApplyFreeze) Current() *Freeze {
+	if f == nil {
+		return nil
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.freeze
+}
+
+// Start freezes applies for reason on behalf of by, replacing the current
+// freeze if there is one.
+func (f *ApplyFreeze) Start(reason string, by string) (Freeze, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	freeze := Freeze{Reason: reason, By: by, Time: time.Now()}
+	contents, err := json.Marshal(freeze)
+	if err != nil {
+		return Freeze{}, errors.Wrap(err, "serializing freeze")
+	}
+	// We write to a temporary file first so a crash can't leave a partial
+	// freeze that stops Atlantis from starting.
+	tmp := f.Path + ".tmp"
+	if err := ioutil.WriteFile(tmp, contents, 0600); err != nil {
+		return Freeze{}, errors.Wrapf(err, "writing %s", tmp)
+	}
+	if err := os.Rename(tmp, f.Path); err != nil {
+		return Freeze{}, errors.Wrapf(err, "renaming %s", tmp)
+	}
+	f.freeze = &freeze
+	return freeze, nil
+}
+
+// Lift unfreezes applies. It returns the freeze that was lifted or nil if
+// applies weren't frozen.
+func (f *ApplyFreeze) Lift() (*Freeze, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "removing %s", f.Path)
+	}
+	lifted := f.freeze
+	f.freeze = nil
+	return lifted, nil
+}
+
+// freezeFailure returns why applies are disabled, or "" if they aren't,
+// either because they're frozen or because one of policy's freeze windows
+// contains now.
+func (a *ApplyExecutor) freezeFailure(policy ApplyPolicy, now time.Time) string {
+	if freeze := a.Freeze.Current(); freeze != nil {
+		return fmt.Sprintf("Applies are currently disabled: %s", freeze.Reason)
+	}
+	var names []string
+	for name := range policy.FreezeWindows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if window := policy.FreezeWindows[name]; window.Contains(now) {
+			return fmt.Sprintf("Applies are currently disabled: the scheduled freeze %q (%s) is in effect", name, window)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+package events_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/mocks"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+func TestApplyFreeze(t *testing.T) {
+	t.Log("a freeze should be kept in the data dir until it's lifted")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmpDir) // nolint: errcheck
+	freeze, err := events.NewApplyFreeze(tmpDir)
+	Ok(t, err)
+	Assert(t, freeze.Current() == nil, "exp no freeze")
+
+	started, err := freeze.Start("incident 42", "alice")
+	Ok(t, err)
+	Equals(t, "incident 42", freeze.Current().Reason)
+
+	t.Log("the freeze should survive a restart")
+	restarted, err := events.NewApplyFreeze(tmpDir)
+	Ok(t, err)
+	Equals(t, "incident 42", restarted.Current().Reason)
+	Equals(t, "alice", restarted.Current().By)
+	Assert(t, started.Time.Equal(restarted.Current().Time), "exp %s, got %s", started.Time, restarted.Current().Time)
+
+	lifted, err := restarted.Lift()
+	Ok(t, err)
+	Equals(t, "incident 42", lifted.Reason)
+	Assert(t, restarted.Current() == nil, "exp no freeze")
+	restarted, err = events.NewApplyFreeze(tmpDir)
+	Ok(t, err)
+	Assert(t, restarted.Current() == nil, "exp no freeze after restarting")
+
+	t.Log("lifting without a freeze should be fine")
+	lifted, err = restarted.Lift()
+	Ok(t, err)
+	Assert(t, lifted == nil, "exp nothing to be lifted")
+}
+
+func TestNewApplyFreeze_Invalid(t *testing.T) {
+	t.Log("a freeze file that can't be parsed should be an error rather than unfreezing")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmpDir) // nolint: errcheck
+	path := filepath.Join(tmpDir, events.ApplyFreezeFile)
+	Ok(t, ioutil.WriteFile(path, []byte("{"), 0600))
+	_, err = events.NewApplyFreeze(tmpDir)
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "parsing "+path+": unexpected end of JSON input", err.Error())
+}
+
+func TestApplyExecutor_Freeze(t *testing.T) {
+	t.Log("applies should be disabled while they're frozen")
+	RegisterMockTestingT(t)
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmpDir) // nolint: errcheck
+	freeze, err := events.NewApplyFreeze(tmpDir)
+	Ok(t, err)
+	_, err = freeze.Start("incident 42", "alice")
+	Ok(t, err)
+	workspace := mocks.NewMockWorkspace()
+	When(workspace.GetWorkspace(applyCtx.BaseRepo, applyCtx.Pull, "production")).ThenReturn("", errors.New("not found"))
+	a := &events.ApplyExecutor{Workspace: workspace, Freeze: freeze}
+
+	r := a.Execute(&applyCtx)
+	Equals(t, "Applies are currently disabled: incident 42", r.Failure)
+	workspace.VerifyWasCalled(Never()).GetWorkspace(applyCtx.BaseRepo, applyCtx.Pull, "production")
+
+	t.Log("applies should be disabled during a scheduled freeze")
+	_, err = freeze.Lift()
+	Ok(t, err)
+	always, err := events.ParseChangeWindow("Sun-Sat 00:00-00:00")
+	Ok(t, err)
+	a.SetPolicy(events.ApplyPolicy{FreezeWindows: map[string]events.ChangeWindow{"weekend": always}})
+	r = a.Execute(&applyCtx)
+	Equals(t, `Applies are currently disabled: the scheduled freeze "weekend" (Sun-Sat 00:00-00:00) is in effect`, r.Failure)
+
+	t.Log("after the freeze applies should go ahead")
+	a.SetPolicy(events.ApplyPolicy{})
+	r = a.Execute(&applyCtx)
+	Equals(t, "No workspace found. Did you run plan?", r.Failure)
+}
@@ -0,0 +1,182 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/events/vcs"
+	"github.com/pkg/errors"
+)
+
+// ApplyRollupStatusName starts the names of the commit statuses that are only
+// successful once every project in the pull request has been applied in an
+// environment, ex. atlantis/apply-all/production. Branch protection can
+// require them to make pull requests be applied before they're merged.
+const ApplyRollupStatusName = "atlantis/apply-all"
+
+// ApplyRollupFile is the name of the file, relative to the data dir, that the
+// applied projects of each pull request are stored in.
+const ApplyRollupFile = "apply-rollup.json"
+
+// ApplyRollup tracks which projects of each pull request have been applied in
+// each environment and sets the environment's status from it. The projects of
+// a pull request are determined again every time so projects that are added
+// to or removed from it are accounted for. A nil ApplyRollup does nothing.
+type ApplyRollup struct {
+	VCSClient vcs.ClientProxy
+	// ProjectDeterminer determines the projects that need to be applied.
+	ProjectDeterminer ProjectDeterminer
+	// Path is the file that the applied projects are stored in so they
+	// survive restarts.
+	Path  string
+	mutex sync.Mutex
+}
+
+// NewApplyRollup returns an ApplyRollup that stores its file in dataDir.
+func NewApplyRollup(dataDir string, vcsClient vcs.ClientProxy, projectDeterminer ProjectDeterminer) *ApplyRollup {
+	return &ApplyRollup{
+		VCSClient:         vcsClient,
+		ProjectDeterminer: projectDeterminer,
+		Path:              filepath.Join(dataDir, ApplyRollupFile),
+	}
+}
+
+// Record stores whether each project, by its path, is applied in the
+// command's environment and updates the environment's status. A project is
+// applied if its apply succeeded or a plan found nothing to change, and isn't
+// once a plan has changes for it or its apply failed. Errors are logged since
+// the command itself succeeded.
+func (r *ApplyRollup) Record(ctx *CommandContext, applied map[string]bool) {
+	if r == nil || len(applied) == 0 {
+		return
+	}
+	if err := r.record(ctx, applied); err != nil {
+		ctx.Log.Warn("unable to update the %s status: %s", rollupStatusName(ctx.Command.Environment), err)
+	}
+}
+
+func (r *ApplyRollup) record(ctx *CommandContext, applied map[string]bool) error {
+	r.mutex.Lock()
+	pulls, err := r.load()
+	if err == nil {
+		key := rollupKey(ctx.BaseRepo, ctx.Pull, ctx.Command.Environment)
+		if pulls[key] == nil {
+			pulls[key] = make(map[string]bool)
+		}
+		for path, ok := range applied {
+			pulls[key][path] = ok
+		}
+		applied = pulls[key]
+		err = r.save(pulls)
+	}
+	r.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	projects, err := r.ProjectDeterminer.DetermineProjects(ctx)
+	if err != nil {
+		return errors.Wrap(err, "determining projects")
+	}
+	status, description := rollupStatus(projects, applied)
+	return r.VCSClient.UpdateNamedStatus(ctx.Context, ctx.BaseRepo, ctx.Pull, status, rollupStatusName(ctx.Command.Environment), description, ctx.VCSHost)
+}
+
+// Forget deletes what was stored for pull in every environment, ex. when it's
+// closed.
+func (r *ApplyRollup) Forget(repo models.Repo, pull models.PullRequest) error {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	pulls, err := r.load()
+	if err != nil {
+		return err
+	}
+	prefix := pullKey(repo, pull)
+	forgot := false
+	for key := range pulls {
+		// Before they were kept per environment, the key was just the
+		// pull request's.
+		if key == prefix || strings.HasPrefix(key, prefix+"/") {
+			delete(pulls, key)
+			forgot = true
+		}
+	}
+	if !forgot {
+		return nil
+	}
+	return r.save(pulls)
+}
+
+// rollupStatus returns the status for projects given which are applied and
+// its description, ex. "Applied 1 of 3 projects, not path2, path3".
+func rollupStatus(projects []models.Project, applied map[string]bool) (vcs.CommitStatus, string) {
+	var notApplied []string
+	for _, p := range projects {
+		if !applied[p.Path] {
+			notApplied = append(notApplied, p.Path)
+		}
+	}
+	if len(notApplied) == 0 {
+		return vcs.Success, fmt.Sprintf("Applied all %d projects", len(projects))
+	}
+	sort.Strings(notApplied)
+	description := fmt.Sprintf("Applied %d of %d projects, not %s", len(projects)-len(notApplied), len(projects), strings.Join(notApplied, ", "))
+	if len(description) > maxStatusDescription {
+		description = description[:maxStatusDescription-3] + "..."
+	}
+	return vcs.Pending, description
+}
+
+// rollupStatusName returns the name of env's status.
+func rollupStatusName(env string) string {
+	return ApplyRollupStatusName + "/" + env
+}
+
+func pullKey(repo models.Repo, pull models.PullRequest) string {
+	return fmt.Sprintf("%s#%d", repo.FullName, pull.Num)
+}
+
+func rollupKey(repo models.Repo, pull models.PullRequest, env string) string {
+	return pullKey(repo, pull) + "/" + env
+}
+
+// load returns the applied projects of each pull request and environment by
+// rollupKey.
+func (r *ApplyRollup) load() (map[string]map[string]bool, error) {
+	pulls := make(map[string]map[string]bool)
+	raw, err := ioutil.ReadFile(r.Path)
+	if os.IsNotExist(err) {
+		return pulls, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", r.Path)
+	}
+	if err := json.Unmarshal(raw, &pulls); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", r.Path)
+	}
+	return pulls, nil
+}
+
+// save writes pulls to a temporary file first so a crash can't leave the file
+// half written.
+func (r *ApplyRollup) save(pulls map[string]map[string]bool) error {
+	raw, err := json.Marshal(pulls)
+	if err != nil {
+		return errors.Wrap(err, "serializing applied projects")
+	}
+	tmp := r.Path + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0600); err != nil {
+		return errors.Wrapf(err, "writing %s", tmp)
+	}
+	return errors.Wrapf(os.Rename(tmp, r.Path), "replacing %s", r.Path)
+}
@@ -0,0 +1,85 @@
+package events_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/events/vcs"
+	"github.com/hootsuite/atlantis/server/events/vcs/mocks"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+var rollupCtx = &events.CommandContext{
+	Context:  context.Background(),
+	Log:      logging.NewNoopLogger(),
+	BaseRepo: models.Repo{FullName: "owner/repo"},
+	Pull:     models.PullRequest{Num: 1},
+	Command:  &events.Command{Name: events.Apply, Environment: "production"},
+	VCSHost:  vcs.Github,
+}
+
+func TestApplyRollup_Record(t *testing.T) {
+	RegisterMockTestingT(t)
+	dataDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dataDir) // nolint: errcheck
+	client := mocks.NewMockClientProxy()
+	determiner := &fakeProjectDeterminer{[]models.Project{{Path: "path1"}, {Path: "path2"}}, nil}
+	r := events.NewApplyRollup(dataDir, client, determiner)
+
+	t.Log("the status should be pending until every project is applied")
+	r.Record(rollupCtx, map[string]bool{"path1": true, "path2": false})
+	client.VerifyWasCalledOnce().UpdateNamedStatus(rollupCtx.Context, rollupCtx.BaseRepo, rollupCtx.Pull, vcs.Pending, "atlantis/apply-all/production", "Applied 1 of 2 projects, not path2", vcs.Github)
+
+	t.Log("projects applied earlier should still count, even after a restart")
+	r = events.NewApplyRollup(dataDir, client, determiner)
+	r.Record(rollupCtx, map[string]bool{"path2": true})
+	client.VerifyWasCalledOnce().UpdateNamedStatus(rollupCtx.Context, rollupCtx.BaseRepo, rollupCtx.Pull, vcs.Success, "atlantis/apply-all/production", "Applied all 2 projects", vcs.Github)
+
+	t.Log("a project that's added to the pull request should need applying")
+	determiner.projects = append(determiner.projects, models.Project{Path: "path3"})
+	r.Record(rollupCtx, map[string]bool{"path1": true})
+	client.VerifyWasCalledOnce().UpdateNamedStatus(rollupCtx.Context, rollupCtx.BaseRepo, rollupCtx.Pull, vcs.Pending, "atlantis/apply-all/production", "Applied 2 of 3 projects, not path3", vcs.Github)
+
+	t.Log("a closed pull request should be forgotten")
+	Ok(t, r.Forget(rollupCtx.BaseRepo, rollupCtx.Pull))
+	r.Record(rollupCtx, map[string]bool{"path3": true})
+	client.VerifyWasCalledOnce().UpdateNamedStatus(rollupCtx.Context, rollupCtx.BaseRepo, rollupCtx.Pull, vcs.Pending, "atlantis/apply-all/production", "Applied 1 of 3 projects, not path1, path2", vcs.Github)
+}
+
+func TestApplyRollup_Environments(t *testing.T) {
+	t.Log("projects applied in one environment shouldn't count as applied in another")
+	RegisterMockTestingT(t)
+	dataDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dataDir) // nolint: errcheck
+	client := mocks.NewMockClientProxy()
+	determiner := &fakeProjectDeterminer{[]models.Project{{Path: "path1"}}, nil}
+	r := events.NewApplyRollup(dataDir, client, determiner)
+	staging := *rollupCtx
+	staging.Command = &events.Command{Name: events.Apply, Environment: "staging"}
+
+	r.Record(&staging, map[string]bool{"path1": true})
+	client.VerifyWasCalledOnce().UpdateNamedStatus(rollupCtx.Context, rollupCtx.BaseRepo, rollupCtx.Pull, vcs.Success, "atlantis/apply-all/staging", "Applied all 1 projects", vcs.Github)
+
+	r.Record(rollupCtx, map[string]bool{"path1": false})
+	client.VerifyWasCalledOnce().UpdateNamedStatus(rollupCtx.Context, rollupCtx.BaseRepo, rollupCtx.Pull, vcs.Pending, "atlantis/apply-all/production", "Applied 0 of 1 projects, not path1", vcs.Github)
+
+	t.Log("closing the pull request should forget every environment")
+	Ok(t, r.Forget(rollupCtx.BaseRepo, rollupCtx.Pull))
+	r.Record(&staging, map[string]bool{"path2": true})
+	client.VerifyWasCalledOnce().UpdateNamedStatus(rollupCtx.Context, rollupCtx.BaseRepo, rollupCtx.Pull, vcs.Pending, "atlantis/apply-all/staging", "Applied 0 of 1 projects, not path1", vcs.Github)
+}
+
+func TestApplyRollup_Nil(t *testing.T) {
+	t.Log("a nil rollup should do nothing")
+	var r *events.ApplyRollup
+	r.Record(rollupCtx, map[string]bool{"path1": true})
+	Ok(t, r.Forget(rollupCtx.BaseRepo, rollupCtx.Pull))
+}
@@ -0,0 +1,169 @@
+package events
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of an external approval check.
+const (
+	ApprovalApproved = "approved"
+	ApprovalDenied   = "denied"
+	ApprovalPending  = "pending"
+	ApprovalErrored  = "errored"
+)
+
+// approvalLatencyBuckets are the upper bounds, in seconds, of the approval
+// latency histogram's buckets. The check times out after a second so the
+// buckets are all below that.
+var approvalLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 0.75, 1}
+
+// ApprovalMetrics counts the outcomes of external approval checks and how
+// long they took, by repo and environment. A nil ApprovalMetrics records
+// nothing.
+type ApprovalMetrics struct {
+	mutex    sync.Mutex
+	outcomes map[approvalOutcomeKey]int
+	latency  map[approvalKey]*histogram
+}
+
+type approvalKey struct {
+	Repo        string
+	Environment string
+}
+
+type approvalOutcomeKey struct {
+	approvalKey
+	Outcome string
+}
+
+// histogram is a Prometheus histogram of durations.
+type histogram struct {
+	// bounds are the upper bounds, in seconds, of the buckets.
+	bounds []float64
+	// buckets are the number of observations in each of bounds, not
+	// cumulative.
+	buckets []int
+	count   int
+	sum     float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, buckets: make([]int, len(bounds))}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	for i, bound := range h.bounds {
+		if seconds <= bound {
+			h.buckets[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// write writes the histogram's lines for a series with labels.
+func (h *histogram) write(w io.Writer, name string, labels string) {
+	cumulative := 0
+	for i, bound := range h.bounds {
+		cumulative += h.buckets[i]
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%g\"} %d\n", name, labels, bound, cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, h.sum)
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// NewApprovalMetrics returns metrics with nothing recorded.
+func NewApprovalMetrics() *ApprovalMetrics {
+	return &ApprovalMetrics{
+		outcomes: make(map[approvalOutcomeKey]int),
+		latency:  make(map[approvalKey]*histogram),
+	}
+}
+
+// Observe records a check for repo and environment that ended with outcome
+// after d.
+func (m *ApprovalMetrics) Observe(repo string, environment string, outcome string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := approvalKey{Repo: repo, Environment: environment}
+	m.outcomes[approvalOutcomeKey{key, outcome}]++
+	h, ok := m.latency[key]
+	if !ok {
+		h = newHistogram(approvalLatencyBuckets)
+		m.latency[key] = h
+	}
+	h.observe(d)
+}
+
+// Write writes the metrics in the Prometheus text format.
+func (m *ApprovalMetrics) Write(w io.Writer) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	const outcomesName = "atlantis_external_approvals_total"
+	fmt.Fprintf(w, "# HELP %s Number of external approval checks by outcome.\n# TYPE %s counter\n", outcomesName, outcomesName)
+	var outcomeKeys []approvalOutcomeKey
+	for k := range m.outcomes {
+		outcomeKeys = append(outcomeKeys, k)
+	}
+	sort.Slice(outcomeKeys, func(i, j int) bool {
+		if outcomeKeys[i].approvalKey != outcomeKeys[j].approvalKey {
+			return outcomeKeys[i].approvalKey.less(outcomeKeys[j].approvalKey)
+		}
+		return outcomeKeys[i].Outcome < outcomeKeys[j].Outcome
+	})
+	for _, k := range outcomeKeys {
+		fmt.Fprintf(w, "%s{%s,outcome=%q} %d\n", outcomesName, k.labels(), k.Outcome, m.outcomes[k])
+	}
+
+	const latencyName = "atlantis_external_approval_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s How long external approval checks took.\n# TYPE %s histogram\n", latencyName, latencyName)
+	var keys []approvalKey
+	for k := range m.latency {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+	for _, k := range keys {
+		m.latency[k].write(w, latencyName, k.labels())
+	}
+}
+
+func (k approvalKey) less(other approvalKey) bool {
+	if k.Repo != other.Repo {
+		return k.Repo < other.Repo
+	}
+	return k.Environment < other.Environment
+}
+
+func (k approvalKey) labels() string {
+	return fmt.Sprintf("repo=\"%s\",environment=\"%s\"", escapeLabel(k.Repo), escapeLabel(k.Environment))
+}
+
+// escapeLabel escapes a label value for the Prometheus text format.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// approvalOutcome returns the outcome of a check that returned decision and
+// err.
+func approvalOutcome(decision string, err error) string {
+	if err != nil {
+		return ApprovalErrored
+	}
+	return decision
+}
@@ -0,0 +1,48 @@
+package events_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestApprovalMetrics_Write(t *testing.T) {
+	t.Log("approval metrics should count outcomes and bucket latency by repo and environment")
+	m := events.NewApprovalMetrics()
+	m.Observe("owner/repo", "production", events.ApprovalApproved, 80*time.Millisecond)
+	m.Observe("owner/repo", "production", events.ApprovalDenied, 300*time.Millisecond)
+	m.Observe("owner/repo", "staging", events.ApprovalErrored, 2*time.Second)
+
+	var buf bytes.Buffer
+	m.Write(&buf)
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE atlantis_external_approvals_total counter\n",
+		`atlantis_external_approvals_total{repo="owner/repo",environment="production",outcome="approved"} 1` + "\n",
+		`atlantis_external_approvals_total{repo="owner/repo",environment="production",outcome="denied"} 1` + "\n",
+		`atlantis_external_approvals_total{repo="owner/repo",environment="staging",outcome="errored"} 1` + "\n",
+		"# TYPE atlantis_external_approval_duration_seconds histogram\n",
+		`atlantis_external_approval_duration_seconds_bucket{repo="owner/repo",environment="production",le="0.05"} 0` + "\n",
+		`atlantis_external_approval_duration_seconds_bucket{repo="owner/repo",environment="production",le="0.1"} 1` + "\n",
+		`atlantis_external_approval_duration_seconds_bucket{repo="owner/repo",environment="production",le="0.5"} 2` + "\n",
+		`atlantis_external_approval_duration_seconds_bucket{repo="owner/repo",environment="production",le="+Inf"} 2` + "\n",
+		`atlantis_external_approval_duration_seconds_count{repo="owner/repo",environment="production"} 2` + "\n",
+		`atlantis_external_approval_duration_seconds_bucket{repo="owner/repo",environment="staging",le="1"} 0` + "\n",
+		`atlantis_external_approval_duration_seconds_bucket{repo="owner/repo",environment="staging",le="+Inf"} 1` + "\n",
+	} {
+		Assert(t, strings.Contains(out, line), "missing %q in %q", line, out)
+	}
+}
+
+func TestApprovalMetrics_Nil(t *testing.T) {
+	t.Log("nil approval metrics should record and write nothing")
+	var m *events.ApprovalMetrics
+	m.Observe("owner/repo", "production", events.ApprovalApproved, time.Second)
+	var buf bytes.Buffer
+	m.Write(&buf)
+	Equals(t, "", buf.String())
+}
@@ -0,0 +1,44 @@
+package events
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// NewApprovalTLSConfig returns the TLS config that the approval client uses
+// to authenticate with the client certificate in certFile and keyFile, and to
+// trust only the CA certificates in caFile instead of the system's. Any of
+// them can be empty but certFile and keyFile have to be set together. If none
+// are set it returns nil so the default TLS config is used.
+func NewApprovalTLSConfig(certFile string, keyFile string, caFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" && caFile == "" {
+		return nil, nil
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("a client certificate and key must be set together")
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pemCerts, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading CA certificate")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemCerts) {
+			return nil, fmt.Errorf("no PEM encoded certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
@@ -0,0 +1,114 @@
+package events_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestApplyExecutor_ExternalApprovalMutualTLS(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	certFile, keyFile, clientCert := writeClientCert(t, tmp)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	service := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Approved": false}`)) // nolint: errcheck
+	}))
+	service.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	service.StartTLS()
+	defer service.Close()
+	caFile := filepath.Join(tmp, "ca.pem")
+	Ok(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: service.Certificate().Raw}), 0600))
+
+	t.Log("the approval client should authenticate with its certificate and trust the service's CA")
+	tlsConfig, err := events.NewApprovalTLSConfig(certFile, keyFile, caFile)
+	Ok(t, err)
+	a := &events.ApplyExecutor{}
+	a.SetPolicy(events.ApplyPolicy{
+		RequireExternalApproval: true,
+		ApprovalURLs:            []string{service.URL},
+		ApprovalTimeout:         time.Second,
+		ApprovalTLS:             tlsConfig,
+	})
+	r := a.Execute(&applyCtx)
+	Ok(t, r.Error)
+	Equals(t, "Pull request is still waiting for a decision from the approval service. (external)", r.Failure)
+
+	t.Log("without a client certificate the handshake should fail")
+	tlsConfig, err = events.NewApprovalTLSConfig("", "", caFile)
+	Ok(t, err)
+	policy := a.Policy()
+	policy.ApprovalTLS = tlsConfig
+	a.SetPolicy(policy)
+	r = a.Execute(&applyCtx)
+	Assert(t, r.Error != nil, "exp an error")
+}
+
+func TestNewApprovalTLSConfig(t *testing.T) {
+	t.Log("no files should mean the default TLS config")
+	config, err := events.NewApprovalTLSConfig("", "", "")
+	Ok(t, err)
+	Assert(t, config == nil, "exp no config")
+
+	t.Log("a certificate without its key should be an error")
+	_, err = events.NewApprovalTLSConfig("client.pem", "", "")
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "a client certificate and key must be set together", err.Error())
+
+	t.Log("a CA file without certificates should be an error")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	caFile := filepath.Join(tmp, "ca.pem")
+	Ok(t, ioutil.WriteFile(caFile, []byte("not a certificate"), 0600))
+	_, err = events.NewApprovalTLSConfig("", "", caFile)
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "no PEM encoded certificates found in "+caFile, err.Error())
+}
+
+// writeClientCert writes a self-signed client certificate and its key to dir
+// and returns their paths and the certificate.
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Ok(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "atlantis"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Ok(t, err)
+	cert, err := x509.ParseCertificate(der)
+	Ok(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Ok(t, err)
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	Ok(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	Ok(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile, cert
+}
@@ -0,0 +1,137 @@
+package events
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/pkg/errors"
+)
+
+// ApprovalTokenVerifier verifies the approvals of an external approval
+// service that responds with a signed JWT instead of plain JSON, so an
+// approval can't be forged by something on the network path between Atlantis
+// and the service. Tokens must be signed with RS256 or ES256.
+type ApprovalTokenVerifier struct {
+	key crypto.PublicKey
+}
+
+// approvalClaims are the claims of an approval token. The repo, pull
+// request, head commit and environment are the ones in the approval request
+// so a token for one pull request can't be replayed for another, or for the
+// same pull request once more commits are pushed or in another environment.
+type approvalClaims struct {
+	Approved    bool   `json:"approved"`
+	Approver    string `json:"approver"`
+	Status      string `json:"status"`
+	Expiry      int64  `json:"exp"`
+	RepoOwner   string `json:"repo_owner"`
+	RepoName    string `json:"repo_name"`
+	PullRequest int    `json:"pull_request"`
+	HeadCommit  string `json:"head_commit"`
+	Environment string `json:"environment"`
+}
+
+// NewApprovalTokenVerifier returns a verifier for tokens signed by the private
+// key of pemKey, a PEM encoded RSA or P-256 ECDSA public key.
+func NewApprovalTokenVerifier(pemKey []byte) (*ApprovalTokenVerifier, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing public key")
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("ECDSA keys must use the P-256 curve")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T: must be RSA or ECDSA", key)
+	}
+	return &ApprovalTokenVerifier{key: key}, nil
+}
+
+// Verify returns the claims of token if it's signed by the verifier's key,
+// hasn't expired at now and is for the head commit of pull of repo in env.
+func (v *ApprovalTokenVerifier) Verify(token string, repo models.Repo, pull models.PullRequest, env string, now time.Time) (approvalClaims, error) {
+	var claims approvalClaims
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return claims, errors.New("approval token isn't a JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeTokenPart(parts[0], &header); err != nil {
+		return claims, errors.Wrap(err, "decoding approval token header")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, errors.Wrap(err, "decoding approval token signature")
+	}
+	// The algorithm must match the key so a token can't pick a weaker one,
+	// ex. "none".
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch key := v.key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != "RS256" {
+			return claims, fmt.Errorf("approval token is signed with %q but the key needs RS256", header.Alg)
+		}
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) != nil {
+			return claims, errors.New("approval token signature is invalid")
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" {
+			return claims, fmt.Errorf("approval token is signed with %q but the key needs ES256", header.Alg)
+		}
+		if len(sig) != 64 || !ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			return claims, errors.New("approval token signature is invalid")
+		}
+	}
+
+	if err := decodeTokenPart(parts[1], &claims); err != nil {
+		return claims, errors.Wrap(err, "decoding approval token claims")
+	}
+	if claims.Expiry == 0 {
+		return claims, errors.New("approval token has no expiry")
+	}
+	if !now.Before(time.Unix(claims.Expiry, 0)) {
+		return claims, fmt.Errorf("approval token expired at %s", time.Unix(claims.Expiry, 0).UTC().Format(time.RFC3339))
+	}
+	if claims.RepoOwner != repo.Owner || claims.RepoName != repo.Name || claims.PullRequest != pull.Num {
+		return claims, fmt.Errorf("approval token is for %s/%s#%d, not %s#%d", claims.RepoOwner, claims.RepoName, claims.PullRequest, repo.FullName, pull.Num)
+	}
+	if claims.HeadCommit == "" || claims.Environment == "" {
+		return claims, errors.New("approval token has no head_commit or environment")
+	}
+	if claims.HeadCommit != pull.HeadCommit {
+		return claims, fmt.Errorf("approval token is for commit %s, not %s", claims.HeadCommit, pull.HeadCommit)
+	}
+	if claims.Environment != env {
+		return claims, fmt.Errorf("approval token is for the %s environment, not %s", claims.Environment, env)
+	}
+	return claims, nil
+}
+
+func decodeTokenPart(part string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
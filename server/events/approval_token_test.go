@@ -0,0 +1,166 @@
+package events_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/models"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+var tokenRepo = models.Repo{Owner: "owner", Name: "repo", FullName: "owner/repo"}
+var tokenPull = models.PullRequest{Num: 1, HeadCommit: "abc123"}
+var tokenNow = time.Unix(1500000000, 0)
+
+func TestApprovalTokenVerifier_RS256(t *testing.T) {
+	t.Log("a token signed with RS256 by the RSA key should be verified")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Ok(t, err)
+	v := newTokenVerifier(t, &key.PublicKey)
+	token := signToken(t, "RS256", key, approvedClaims(tokenNow.Add(time.Minute)))
+
+	claims, err := v.Verify(token, tokenRepo, tokenPull, "production", tokenNow)
+	Ok(t, err)
+	Equals(t, true, claims.Approved)
+	Equals(t, "alice", claims.Approver)
+}
+
+func TestApprovalTokenVerifier_ES256(t *testing.T) {
+	t.Log("a token signed with ES256 by the ECDSA key should be verified")
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Ok(t, err)
+	v := newTokenVerifier(t, &key.PublicKey)
+	token := signToken(t, "ES256", key, approvedClaims(tokenNow.Add(time.Minute)))
+
+	claims, err := v.Verify(token, tokenRepo, tokenPull, "production", tokenNow)
+	Ok(t, err)
+	Equals(t, true, claims.Approved)
+}
+
+func TestApprovalTokenVerifier_Rejected(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Ok(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	Ok(t, err)
+	v := newTokenVerifier(t, &key.PublicKey)
+	valid := signToken(t, "RS256", key, approvedClaims(tokenNow.Add(time.Minute)))
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(approvedClaims(tokenNow.Add(time.Minute))))
+
+	cases := []struct {
+		Description string
+		Token       string
+		Pull        models.PullRequest
+		Expected    string
+	}{
+		{
+			"token signed by another key",
+			signToken(t, "RS256", other, approvedClaims(tokenNow.Add(time.Minute))),
+			tokenPull,
+			"approval token signature is invalid",
+		},
+		{
+			"unsigned token",
+			header + "." + payload + ".",
+			tokenPull,
+			`approval token is signed with "none" but the key needs RS256`,
+		},
+		{
+			"expired token",
+			signToken(t, "RS256", key, approvedClaims(tokenNow)),
+			tokenPull,
+			"approval token expired at 2017-07-14T02:40:00Z",
+		},
+		{
+			"token for another pull request",
+			valid,
+			models.PullRequest{Num: 2, HeadCommit: "abc123"},
+			"approval token is for owner/repo#1, not owner/repo#2",
+		},
+		{
+			"token for another commit",
+			valid,
+			models.PullRequest{Num: 1, HeadCommit: "def456"},
+			"approval token is for commit abc123, not def456",
+		},
+		{
+			"token without a head commit or environment",
+			signToken(t, "RS256", key, fmt.Sprintf(`{"approved":true,"exp":%d,"repo_owner":"owner","repo_name":"repo","pull_request":1}`, tokenNow.Add(time.Minute).Unix())),
+			tokenPull,
+			"approval token has no head_commit or environment",
+		},
+		{
+			"plain json",
+			`{"Approved": true}`,
+			tokenPull,
+			"approval token isn't a JWT",
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.Description + " should be rejected")
+		_, err := v.Verify(c.Token, tokenRepo, c.Pull, "production", tokenNow)
+		Assert(t, err != nil, "exp an error")
+		Equals(t, c.Expected, err.Error())
+	}
+}
+
+func TestApprovalTokenVerifier_OtherEnvironment(t *testing.T) {
+	t.Log("a token for one environment shouldn't approve an apply in another")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Ok(t, err)
+	v := newTokenVerifier(t, &key.PublicKey)
+	token := signToken(t, "RS256", key, approvedClaims(tokenNow.Add(time.Minute)))
+
+	_, err = v.Verify(token, tokenRepo, tokenPull, "staging", tokenNow)
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "approval token is for the production environment, not staging", err.Error())
+}
+
+func TestNewApprovalTokenVerifier_InvalidKey(t *testing.T) {
+	t.Log("a key that isn't PEM encoded should be an error")
+	_, err := events.NewApprovalTokenVerifier([]byte("not a key"))
+	Equals(t, "no PEM encoded key found", err.Error())
+}
+
+func newTokenVerifier(t *testing.T, key crypto.PublicKey) *events.ApprovalTokenVerifier {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	Ok(t, err)
+	v, err := events.NewApprovalTokenVerifier(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	Ok(t, err)
+	return v
+}
+
+func approvedClaims(expiry time.Time) string {
+	return fmt.Sprintf(`{"approved":true,"approver":"alice","exp":%d,"repo_owner":"owner","repo_name":"repo","pull_request":1,"head_commit":"abc123","environment":"production"}`, expiry.Unix())
+}
+
+func signToken(t *testing.T, alg string, key crypto.Signer, claims string) string {
+	signed := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"alg":%q,"typ":"JWT"}`, alg))) +
+		"." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		Ok(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		Ok(t, err)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
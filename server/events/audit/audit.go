@@ -0,0 +1,79 @@
+// Package audit records every apply that Atlantis attempts, who ran it, what
+// it applied and whether it was approved, as a trail for compliance reviews.
+// It's separate from the server's logs so it can be shipped and retained on
+// its own.
+package audit
+
+import (
+	"os"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events/jsonl"
+	"github.com/pkg/errors"
+)
+
+// Approval statuses of an entry.
+const (
+	// NotRequired means the approval isn't required by the apply policy.
+	NotRequired = "not-required"
+	// NotChecked means the approval is required but the apply ended before
+	// it was checked.
+	NotChecked = "not-checked"
+	Approved   = "approved"
+	// NotApproved means the pull request hasn't been approved by a reviewer.
+	NotApproved = "not-approved"
+	Denied      = "denied"
+	Pending     = "pending"
+	Errored     = "errored"
+)
+
+// Entry is an apply. It only has identifiers and statuses, never command
+// output or error messages, since those can contain secrets.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// User is the username of the user that commented apply.
+	User string `json:"user"`
+	// Repo is the owner and name of the repo, ex. "hootsuite/atlantis".
+	Repo        string `json:"repo"`
+	PullNum     int    `json:"pull_num"`
+	Commit      string `json:"commit"`
+	Environment string `json:"environment"`
+	// Project is the path of the project that was applied. It's empty if the
+	// apply ended before it got to the projects, ex. because it wasn't
+	// approved.
+	Project string `json:"project,omitempty"`
+	// InternalApproval is the status of the pull request's approval by a
+	// reviewer and ExternalApproval the status of the approval services'
+	// decision.
+	InternalApproval string `json:"internal_approval"`
+	ExternalApproval string `json:"external_approval"`
+	// Emergency is true if the apply was an emergency apply that could
+	// bypass the change window.
+	Emergency bool   `json:"emergency,omitempty"`
+	Ticket    string `json:"ticket,omitempty"`
+	// Result is "success", "failure" or "error".
+	Result string `json:"result"`
+}
+
+// Log writes entries to a file as newline-delimited JSON. The file is only
+// ever appended to.
+type Log struct {
+	file *jsonl.File
+}
+
+// NewLog returns a Log that writes to path. It checks that the file can be
+// written to so a bad path is found on startup rather than on the first
+// apply.
+func NewLog(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening %s", path)
+	}
+	f.Close() // nolint: errcheck
+	return &Log{file: jsonl.NewFile(path)}, nil
+}
+
+// Append adds e to the end of the log.
+func (l *Log) Append(e Entry) error {
+	return l.file.Append(e)
+}
@@ -0,0 +1,53 @@
+package audit_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events/audit"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestAppend(t *testing.T) {
+	t.Log("entries should be appended as lines of JSON")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	path := filepath.Join(tmp, "audit.jsonl")
+	l, err := audit.NewLog(path)
+	Ok(t, err)
+
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	Ok(t, l.Append(audit.Entry{
+		Time:             now,
+		User:             "alice",
+		Repo:             "owner/repo",
+		PullNum:          1,
+		Commit:           "abc123",
+		Environment:      "production",
+		Project:          "project",
+		InternalApproval: audit.Approved,
+		ExternalApproval: audit.NotRequired,
+		Result:           "success",
+	}))
+	Ok(t, l.Append(audit.Entry{Time: now, Repo: "owner/repo", Emergency: true, Ticket: "OPS-1", Result: "failure"}))
+
+	contents, err := ioutil.ReadFile(path)
+	Ok(t, err)
+	Equals(t, `{"time":"2017-01-01T00:00:00Z","user":"alice","repo":"owner/repo","pull_num":1,"commit":"abc123","environment":"production","project":"project","internal_approval":"approved","external_approval":"not-required","result":"success"}`+"\n"+
+		`{"time":"2017-01-01T00:00:00Z","user":"","repo":"owner/repo","pull_num":0,"commit":"","environment":"","internal_approval":"","external_approval":"","emergency":true,"ticket":"OPS-1","result":"failure"}`+"\n", string(contents))
+
+	info, err := os.Stat(path)
+	Ok(t, err)
+	Equals(t, os.FileMode(0600), info.Mode())
+}
+
+func TestNewLog_Unwritable(t *testing.T) {
+	t.Log("a path that can't be written to should be an error")
+	_, err := audit.NewLog("/does/not/exist/audit.jsonl")
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "opening /does/not/exist/audit.jsonl: open /does/not/exist/audit.jsonl: no such file or directory", err.Error())
+}
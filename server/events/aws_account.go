@@ -0,0 +1,90 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// awsAccountIDRegex matches AWS account IDs, which are always 12 digits.
+var awsAccountIDRegex = regexp.MustCompile(`^[0-9]{12}$`)
+
+// ValidAWSAccountID returns true if id is an AWS account ID.
+func ValidAWSAccountID(id string) bool {
+	return awsAccountIDRegex.MatchString(id)
+}
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_caller_identity.go CallerIdentity
+
+// CallerIdentity looks up the AWS account that terraform's credentials are
+// for. profile is the command's AWS profile, or empty to use the default
+// credentials.
+type CallerIdentity interface {
+	AccountID(profile string) (string, error)
+}
+
+// AWSCLICallerIdentity looks up the account with the AWS CLI's
+// sts get-caller-identity. The CLI reads the same credentials and config
+// files as terraform, including the role to assume, so it resolves the same
+// account that terraform would.
+type AWSCLICallerIdentity struct{}
+
+func (AWSCLICallerIdentity) AccountID(profile string) (string, error) {
+	cmd := exec.Command("aws", "sts", "get-caller-identity", "--output", "json")
+	if profile != "" {
+		cmd.Env = append(os.Environ(), fmt.Sprintf("AWS_PROFILE=%s", profile))
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("running aws sts get-caller-identity: %s: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", errors.Wrap(err, "running aws sts get-caller-identity")
+	}
+	var identity struct {
+		Account string
+	}
+	if err := json.Unmarshal(out, &identity); err != nil {
+		return "", errors.Wrap(err, "parsing aws sts get-caller-identity output")
+	}
+	if !ValidAWSAccountID(identity.Account) {
+		return "", fmt.Errorf("aws sts get-caller-identity returned %q which isn't an account ID", identity.Account)
+	}
+	return identity.Account, nil
+}
+
+// AWSAccountGuard stops applies from running with the credentials of the
+// wrong AWS account, ex. if the role mapped to staging is in the production
+// account.
+type AWSAccountGuard struct {
+	// Accounts maps an environment to the ID of the AWS account it must be
+	// applied in. Environments that aren't in the map aren't checked.
+	Accounts map[string]string
+	Identity CallerIdentity
+}
+
+// Check returns a failure explaining why if the environment can't be applied
+// with the current credentials. It returns an error if the account couldn't be
+// looked up, in which case the apply shouldn't run either.
+func (g *AWSAccountGuard) Check(ctx *CommandContext) (string, error) {
+	expected, ok := g.Accounts[ctx.Command.Environment]
+	if !ok {
+		return "", nil
+	}
+	account, err := g.Identity.AccountID(ctx.AWSProfile)
+	if err != nil {
+		return "", errors.Wrap(err, "checking aws account")
+	}
+	if account != expected {
+		ctx.Log.Warn("refusing to apply %s environment in aws account %s, expected %s", ctx.Command.Environment, account, expected)
+		return fmt.Sprintf("The %s environment must be applied in AWS account %s but Atlantis's credentials are for account %s. Check the role it's configured to assume.",
+			ctx.Command.Environment, expected, account), nil
+	}
+	ctx.Log.Info("confirmed aws account %s for %s environment", account, ctx.Command.Environment)
+	return "", nil
+}
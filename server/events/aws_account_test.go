@@ -0,0 +1,80 @@
+package events_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/mocks"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+func accountCtx(env string) *events.CommandContext {
+	return &events.CommandContext{
+		Command:    &events.Command{Name: events.Apply, Environment: env},
+		Log:        logging.NewNoopLogger(),
+		AWSProfile: "atlantis-" + env,
+	}
+}
+
+func TestAWSAccountGuard(t *testing.T) {
+	t.Log("applies should only be allowed in the account mapped to their environment")
+	RegisterMockTestingT(t)
+	identity := mocks.NewMockCallerIdentity()
+	When(identity.AccountID(AnyString())).ThenReturn("111111111111", nil)
+	guard := events.AWSAccountGuard{
+		Accounts: map[string]string{"staging": "111111111111", "production": "222222222222"},
+		Identity: identity,
+	}
+
+	failure, err := guard.Check(accountCtx("staging"))
+	Ok(t, err)
+	Equals(t, "", failure)
+
+	failure, err = guard.Check(accountCtx("production"))
+	Ok(t, err)
+	Equals(t, "The production environment must be applied in AWS account 222222222222 but Atlantis's credentials are for account 111111111111. Check the role it's configured to assume.", failure)
+
+	t.Log("environments without an account shouldn't be checked")
+	failure, err = guard.Check(accountCtx("dev"))
+	Ok(t, err)
+	Equals(t, "", failure)
+	t.Log("the account should be looked up with the command's profile")
+	identity.VerifyWasCalledOnce().AccountID("atlantis-staging")
+	identity.VerifyWasCalledOnce().AccountID("atlantis-production")
+	identity.VerifyWasCalled(Never()).AccountID("atlantis-dev")
+}
+
+func TestAWSAccountGuard_IdentityErr(t *testing.T) {
+	t.Log("if the account can't be looked up the apply should error")
+	RegisterMockTestingT(t)
+	identity := mocks.NewMockCallerIdentity()
+	When(identity.AccountID(AnyString())).ThenReturn("", errors.New("no credentials"))
+	guard := events.AWSAccountGuard{Accounts: map[string]string{"production": "222222222222"}, Identity: identity}
+
+	_, err := guard.Check(accountCtx("production"))
+	Assert(t, err != nil, "expected error")
+	Equals(t, "checking aws account: no credentials", err.Error())
+}
+
+func TestAWSCLICallerIdentity(t *testing.T) {
+	t.Log("the account should be read from the output of aws sts get-caller-identity")
+	binDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(binDir) // nolint: errcheck
+	t.Log("it should be run with the command's profile")
+	script := "#!/bin/sh\n[ \"$AWS_PROFILE\" = atlantis-1 ] || exit 1\necho '{\"UserId\": \"AROA:atlantis\", \"Account\": \"123456789012\", \"Arn\": \"arn:aws:sts::123456789012:assumed-role/apply/atlantis\"}'\n"
+	Ok(t, ioutil.WriteFile(filepath.Join(binDir, "aws"), []byte(script), 0755))
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path) // nolint: errcheck
+	Ok(t, os.Setenv("PATH", binDir+string(os.PathListSeparator)+path))
+
+	account, err := events.AWSCLICallerIdentity{}.AccountID("atlantis-1")
+	Ok(t, err)
+	Equals(t, "123456789012", account)
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdays maps the day names accepted in a change window to time.Weekday.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ChangeWindow is when applies to an environment are allowed, ex. during
+// business hours.
+type ChangeWindow struct {
+	// days are the days the window opens on, indexed by time.Weekday.
+	days [7]bool
+	// start and end are the time of day the window opens and closes. If end
+	// is before start, the window closes on the next day.
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+	spec     string
+}
+
+// ParseChangeWindow parses a change window of the form
+// "<days> <start>-<end> [timezone]", ex. "Mon-Fri 09:00-17:00 Europe/Berlin".
+// Days are a comma separated list of day names or ranges of them, ex.
+// "Mon,Wed" or "Mon-Thu,Sat". The timezone is an IANA name and defaults to
+// UTC.
+func ParseChangeWindow(spec string) (ChangeWindow, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 2 && len(fields) != 3 {
+		return ChangeWindow{}, fmt.Errorf("%q is not of the form \"<days> <start>-<end> [timezone]\"", spec)
+	}
+	w := ChangeWindow{location: time.UTC, spec: spec}
+
+	for _, days := range strings.Split(fields[0], ",") {
+		bounds := strings.SplitN(days, "-", 2)
+		first, ok := weekdays[strings.ToLower(bounds[0])]
+		if !ok {
+			return ChangeWindow{}, fmt.Errorf("%q is not a day", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdays[strings.ToLower(bounds[1])]; !ok {
+				return ChangeWindow{}, fmt.Errorf("%q is not a day", bounds[1])
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			w.days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+
+	times := strings.SplitN(fields[1], "-", 2)
+	if len(times) != 2 {
+		return ChangeWindow{}, fmt.Errorf("%q is not of the form \"<start>-<end>\"", fields[1])
+	}
+	var err error
+	if w.start, err = parseTimeOfDay(times[0]); err != nil {
+		return ChangeWindow{}, err
+	}
+	if w.end, err = parseTimeOfDay(times[1]); err != nil {
+		return ChangeWindow{}, err
+	}
+
+	if len(fields) == 3 {
+		if w.location, err = time.LoadLocation(fields[2]); err != nil {
+			return ChangeWindow{}, fmt.Errorf("%q is not a timezone", fields[2])
+		}
+	}
+	return w, nil
+}
+
+// Contains returns true if t is inside the window.
+func (w ChangeWindow) Contains(t time.Time) bool {
+	t = t.In(w.location)
+	timeOfDay := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.start < w.end {
+		return w.days[t.Weekday()] && timeOfDay >= w.start && timeOfDay < w.end
+	}
+	// The window closes on the day after it opens.
+	yesterday := (t.Weekday() + 6) % 7
+	return (w.days[t.Weekday()] && timeOfDay >= w.start) || (w.days[yesterday] && timeOfDay < w.end)
+}
+
+// String returns the window as it was configured.
+func (w ChangeWindow) String() string {
+	return w.spec
+}
+
+// parseTimeOfDay parses a time of the form 15:04 into the duration since
+// midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of the form HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
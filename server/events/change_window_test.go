@@ -0,0 +1,64 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestParseChangeWindow_Invalid(t *testing.T) {
+	t.Log("invalid change windows should return an error")
+	cases := map[string]string{
+		"Mon-Fri":                         "\"Mon-Fri\" is not of the form \"<days> <start>-<end> [timezone]\"",
+		"Mon-Fry 09:00-17:00":             "\"Fry\" is not a day",
+		"Mon-Fri 09:00":                   "\"09:00\" is not of the form \"<start>-<end>\"",
+		"Mon-Fri 9am-17:00":               "\"9am\" is not a time of the form HH:MM",
+		"Mon-Fri 09:00-17:00 Not/A_Place": "\"Not/A_Place\" is not a timezone",
+	}
+	for spec, expErr := range cases {
+		_, err := events.ParseChangeWindow(spec)
+		Assert(t, err != nil, "expected error for %q", spec)
+		Equals(t, expErr, err.Error())
+	}
+}
+
+func TestChangeWindow_Contains(t *testing.T) {
+	t.Log("Contains should check the day and time in the window's timezone")
+	w, err := events.ParseChangeWindow("Mon-Thu,Sat 09:00-17:00 Europe/Berlin")
+	Ok(t, err)
+	Equals(t, "Mon-Thu,Sat 09:00-17:00 Europe/Berlin", w.String())
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	cases := []struct {
+		t   time.Time
+		exp bool
+	}{
+		// Monday 2017-10-16.
+		{time.Date(2017, 10, 16, 9, 0, 0, 0, berlin), true},
+		{time.Date(2017, 10, 16, 16, 59, 0, 0, berlin), true},
+		{time.Date(2017, 10, 16, 17, 0, 0, 0, berlin), false},
+		{time.Date(2017, 10, 16, 8, 59, 0, 0, berlin), false},
+		// 07:30 UTC is 09:30 in Berlin.
+		{time.Date(2017, 10, 16, 7, 30, 0, 0, time.UTC), true},
+		// Friday and Saturday.
+		{time.Date(2017, 10, 20, 12, 0, 0, 0, berlin), false},
+		{time.Date(2017, 10, 21, 12, 0, 0, 0, berlin), true},
+	}
+	for _, c := range cases {
+		Equals(t, c.exp, w.Contains(c.t))
+	}
+}
+
+func TestChangeWindow_ContainsOvernight(t *testing.T) {
+	t.Log("a window that ends before it starts should close on the next day")
+	w, err := events.ParseChangeWindow("Fri-Sat 22:00-02:00")
+	Ok(t, err)
+	// Friday 2017-10-20.
+	Equals(t, true, w.Contains(time.Date(2017, 10, 20, 23, 0, 0, 0, time.UTC)))
+	Equals(t, true, w.Contains(time.Date(2017, 10, 21, 1, 0, 0, 0, time.UTC)))
+	Equals(t, false, w.Contains(time.Date(2017, 10, 20, 1, 0, 0, 0, time.UTC)))
+	Equals(t, true, w.Contains(time.Date(2017, 10, 22, 1, 59, 0, 0, time.UTC)))
+	Equals(t, false, w.Contains(time.Date(2017, 10, 22, 2, 0, 0, 0, time.UTC)))
+	Equals(t, false, w.Contains(time.Date(2017, 10, 22, 23, 0, 0, 0, time.UTC)))
+}
@@ -1,12 +1,17 @@
 package events
 
 import (
+	"context"
+
 	"github.com/hootsuite/atlantis/server/events/models"
 	"github.com/hootsuite/atlantis/server/events/vcs"
 	"github.com/hootsuite/atlantis/server/logging"
 )
 
 type CommandContext struct {
+	// Context is passed to the VCS clients so their requests are canceled if
+	// the command is.
+	Context  context.Context
 	BaseRepo models.Repo
 	HeadRepo models.Repo
 	Pull     models.PullRequest
@@ -14,4 +19,9 @@ type CommandContext struct {
 	Command  *Command
 	Log      *logging.SimpleLogger
 	VCSHost  vcs.Host
+	// AWSProfile is the profile with the command's credentials and role when
+	// running in ECS. Terraform and the commands it runs get it as AWS_PROFILE so
+	// concurrent commands never use each other's credentials. It's empty if
+	// Atlantis didn't write any credentials.
+	AWSProfile string
 }
@@ -1,14 +1,20 @@
 package events
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/go-github/github"
+	"github.com/hootsuite/atlantis/server/events/history"
 	"github.com/hootsuite/atlantis/server/events/models"
 	"github.com/hootsuite/atlantis/server/events/vcs"
 	"github.com/hootsuite/atlantis/server/logging"
 	"github.com/hootsuite/atlantis/server/recovery"
+	"github.com/hootsuite/atlantis/server/tracing"
 	"github.com/lkysow/go-gitlab"
 	"github.com/pkg/errors"
 )
@@ -21,6 +27,36 @@ const (
 	GitFlowWorkflow       Workflow = "gitflow"
 )
 
+// CommentRetention is what we do with the comments we posted for previous
+// commands when we comment with the results of a new one.
+type CommentRetention string
+
+const (
+	KeepComments CommentRetention = "keep"
+	// DeleteComments deletes the comments for previous runs of the same
+	// command in the same environment.
+	DeleteComments CommentRetention = "delete"
+	// UpdateComments edits the comment for the previous run of the same
+	// command in the same environment for the same projects instead of
+	// creating a new one.
+	UpdateComments CommentRetention = "update"
+)
+
+// ForkPolicy is what we do with commands on pull requests from forks. Their
+// code is run with our credentials so anyone who can open a pull request
+// could use them.
+type ForkPolicy string
+
+const (
+	// IgnoreForks doesn't run any commands on pull requests from forks.
+	IgnoreForks ForkPolicy = "ignore"
+	// PlanOnlyForks runs every command except apply.
+	PlanOnlyForks ForkPolicy = "plan-only"
+	// RequireMemberCommentForks only runs commands that were commented by a
+	// member of the repo who isn't the pull request's author.
+	RequireMemberCommentForks ForkPolicy = "require-member-comment"
+)
+
 //go:generate pegomock generate --use-experimental-model-gen --package mocks -o mocks/mock_command_runner.go CommandRunner
 
 type CommandRunner interface {
@@ -30,13 +66,13 @@ type CommandRunner interface {
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_github_pull_getter.go GithubPullGetter
 
 type GithubPullGetter interface {
-	GetPullRequest(repo models.Repo, pullNum int) (*github.PullRequest, error)
+	GetPullRequest(ctx context.Context, repo models.Repo, pullNum int) (*github.PullRequest, error)
 }
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_gitlab_merge_request_getter.go GitlabMergeRequestGetter
 
 type GitlabMergeRequestGetter interface {
-	GetMergeRequest(repoFullName string, pullNum int) (*gitlab.MergeRequest, error)
+	GetMergeRequest(ctx context.Context, repoFullName string, pullNum int) (*gitlab.MergeRequest, error)
 }
 
 // CommandHandler is the first step when processing a comment command.
@@ -44,6 +80,10 @@ type CommandHandler struct {
 	PlanExecutor             Executor
 	ApplyExecutor            Executor
 	HelpExecutor             Executor
+	VersionCheckExecutor     Executor
+	FmtExecutor              Executor
+	ValidateExecutor         Executor
+	HistoryExecutor          Executor
 	LockURLGenerator         LockURLGenerator
 	VCSClient                vcs.ClientProxy
 	GithubPullGetter         GithubPullGetter
@@ -54,17 +94,81 @@ type CommandHandler struct {
 	MarkdownRenderer         *MarkdownRenderer
 	Logger                   logging.SimpleLogging
 	ConfiguredWorkflow       Workflow
+	CommentRetention         CommentRetention
+	// GithubUser and GitlabUser are the users Atlantis comments as, so only
+	// its own comments are deleted or updated. If GithubApp is set, the app's
+	// login is used instead of GithubUser.
+	GithubUser string
+	GithubApp  *vcs.GithubAppTransport
+	GitlabUser string
+	// ForkPolicy is what we do with commands on pull requests from forks. If
+	// it's empty, PlanOnlyForks is used.
+	ForkPolicy ForkPolicy
+	// PlanRoleARN is the AWS role terraform assumes for every command except
+	// apply when running in ECS. If empty, the task's role is used directly.
+	PlanRoleARN string
+	// ApplyRoleARN is the AWS role terraform assumes for apply when running
+	// in ECS. If empty, the task's role is used directly.
+	ApplyRoleARN string
+	// AWSAssumeRoleARN is the AWS role that Atlantis assumes with the task
+	// role's credentials when running in ECS, writing the role's credentials
+	// instead. If empty, the task role's credentials are written.
+	AWSAssumeRoleARN string
+	// AWSAssumeRoles maps an environment to the role that's assumed instead
+	// of AWSAssumeRoleARN for its commands, ex. one in its own account.
+	AWSAssumeRoles map[string]string
+	// AWSCredentialsPath is where the task role's credentials are written when
+	// running in ECS. The AWS config file is written in the same directory.
+	AWSCredentialsPath string
+	// AWSProfile starts the name of each command's profile in
+	// AWSCredentialsPath that its credentials are written to, ex.
+	// "atlantis-plan-3". The file's other profiles are kept.
+	AWSProfile string
+	// RunHistory records plans and applies. If it's nil, nothing is recorded.
+	RunHistory RunHistory
+	// Tracer records a trace for each command. If it's nil, tracing is
+	// disabled.
+	Tracer *tracing.Tracer
+}
+
+// roleARN returns the AWS role that terraform should assume for the command.
+// Only apply gets the apply role so plans can be run with read-only
+// credentials.
+func (c *CommandHandler) roleARN(name CommandName) string {
+	if name == Apply {
+		return c.ApplyRoleARN
+	}
+	return c.PlanRoleARN
+}
+
+// assumeRoleARN returns the AWS role that Atlantis should assume for commands
+// in env before writing the credentials.
+func (c *CommandHandler) assumeRoleARN(env string) string {
+	if arn, ok := c.AWSAssumeRoles[env]; ok {
+		return arn
+	}
+	return c.AWSAssumeRoleARN
 }
 
 // ExecuteCommand executes the command
 func (c *CommandHandler) ExecuteCommand(baseRepo models.Repo, headRepo models.Repo, user models.User, pullNum int, cmd *Command, vcsHost vcs.Host) {
 	var err error
 	var pull models.PullRequest
+	cmdCtx, span := c.Tracer.Start(context.Background(), "command")
+	defer span.End()
+	span.SetAttribute("atlantis.repo", baseRepo.FullName)
+	span.SetAttribute("atlantis.pull", strconv.Itoa(pullNum))
+	span.SetAttribute("atlantis.user", user.Username)
+	span.SetAttribute("atlantis.vcs_host", vcsHost.String())
+
+	_, fetchSpan := tracing.Start(cmdCtx, "fetch pull request")
 	if vcsHost == vcs.Github {
-		pull, headRepo, err = c.getGithubData(baseRepo, pullNum)
+		pull, headRepo, err = c.getGithubData(cmdCtx, baseRepo, pullNum)
 	} else if vcsHost == vcs.Gitlab {
-		pull, err = c.getGitlabData(baseRepo.FullName, pullNum)
+		pull, err = c.getGitlabData(cmdCtx, baseRepo.FullName, pullNum)
 	}
+	fetchSpan.SetError(err)
+	fetchSpan.End()
 
 	// FIXME: this is a bodge to disable terraform environments regardless of the context
 	// Ideally it should happen in the command parser
@@ -74,10 +178,19 @@ func (c *CommandHandler) ExecuteCommand(baseRepo models.Repo, headRepo models.Re
 
 	log := c.buildLogger(baseRepo.FullName, pullNum)
 	if err != nil {
+		span.SetError(err)
 		log.Err(err.Error())
 		return
 	}
+	if cmd != nil {
+		span.SetAttribute("atlantis.command", cmd.Name.String())
+		span.SetAttribute("atlantis.environment", cmd.Environment)
+	}
+	if traceID := span.TraceID(); traceID != "" {
+		log.Info("trace id is %s", traceID)
+	}
 	ctx := &CommandContext{
+		Context:  cmdCtx,
 		User:     user,
 		Log:      log,
 		Pull:     pull,
@@ -89,11 +202,11 @@ func (c *CommandHandler) ExecuteCommand(baseRepo models.Repo, headRepo models.Re
 	c.run(ctx)
 }
 
-func (c *CommandHandler) getGithubData(baseRepo models.Repo, pullNum int) (models.PullRequest, models.Repo, error) {
+func (c *CommandHandler) getGithubData(ctx context.Context, baseRepo models.Repo, pullNum int) (models.PullRequest, models.Repo, error) {
 	if c.GithubPullGetter == nil {
 		return models.PullRequest{}, models.Repo{}, errors.New("Atlantis not configured to support GitHub")
 	}
-	ghPull, err := c.GithubPullGetter.GetPullRequest(baseRepo, pullNum)
+	ghPull, err := c.GithubPullGetter.GetPullRequest(ctx, baseRepo, pullNum)
 	if err != nil {
 		return models.PullRequest{}, models.Repo{}, errors.Wrap(err, "making pull request API call to GitHub")
 	}
@@ -104,11 +217,11 @@ func (c *CommandHandler) getGithubData(baseRepo models.Repo, pullNum int) (model
 	return pull, repo, nil
 }
 
-func (c *CommandHandler) getGitlabData(repoFullName string, pullNum int) (models.PullRequest, error) {
+func (c *CommandHandler) getGitlabData(ctx context.Context, repoFullName string, pullNum int) (models.PullRequest, error) {
 	if c.GitlabMergeRequestGetter == nil {
 		return models.PullRequest{}, errors.New("Atlantis not configured to support GitLab")
 	}
-	mr, err := c.GitlabMergeRequestGetter.GetMergeRequest(repoFullName, pullNum)
+	mr, err := c.GitlabMergeRequestGetter.GetMergeRequest(ctx, repoFullName, pullNum)
 	if err != nil {
 		return models.PullRequest{}, errors.Wrap(err, "making merge request API call to GitLab")
 	}
@@ -131,11 +244,27 @@ func (c *CommandHandler) run(ctx *CommandContext) {
 
 	if ctx.Pull.State != models.Open {
 		ctx.Log.Info("command was run on closed pull request")
-		c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull, "Atlantis commands can't be run on closed pull requests", ctx.VCSHost) // nolint: errcheck
+		c.VCSClient.CreateComment(ctx.Context, ctx.BaseRepo, ctx.Pull, "Atlantis commands can't be run on closed pull requests", ctx.VCSHost) // nolint: errcheck
 		return
 	}
 
-	c.CommitStatusUpdater.Update(ctx.BaseRepo, ctx.Pull, vcs.Pending, ctx.Command, ctx.VCSHost) // nolint: errcheck
+	if ctx.HeadRepo.FullName != "" && ctx.HeadRepo.FullName != ctx.BaseRepo.FullName {
+		if c.ForkPolicy == IgnoreForks {
+			ctx.Log.Info("ignoring command on pull request from fork %s", ctx.HeadRepo.FullName)
+			return
+		}
+		if res, ok := c.checkFork(ctx); !ok {
+			ctx.Log.Warn("not running command on pull request from fork %s", ctx.HeadRepo.FullName)
+			c.updatePull(ctx, res)
+			return
+		}
+	}
+
+	// Set a pending status before doing any work so reviewers can see the
+	// command is running rather than a stale status from the last run.
+	if err := c.CommitStatusUpdater.Update(ctx.Context, ctx.BaseRepo, ctx.Pull, vcs.Pending, ctx.Command, ctx.VCSHost); err != nil {
+		ctx.Log.Warn("unable to set pending commit status: %s", err)
+	}
 	if !c.EnvLocker.TryLock(ctx.BaseRepo.FullName, ctx.Command.Environment, ctx.Pull.Num) {
 		errMsg := fmt.Sprintf(
 			"The %s environment is currently locked by another"+
@@ -148,23 +277,41 @@ func (c *CommandHandler) run(ctx *CommandContext) {
 	}
 	defer c.EnvLocker.Unlock(ctx.BaseRepo.FullName, ctx.Command.Environment, ctx.Pull.Num)
 
-	// check if we're running in ECS and try to fetch IAM credentials for task's role
+	// check if we're running in ECS and try to fetch IAM credentials for task's role.
+	// validate doesn't touch the backend so it doesn't get any.
 	credentialsRelativeUri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI")
-	if credentialsRelativeUri != "" {
-		err := handleEcsCredentials(credentialsRelativeUri)
+	if credentialsRelativeUri != "" && ctx.Command.Name != Validate {
+		// Only the profiles we write are removed since the files might have
+		// other profiles in them.
+		roleARN := c.roleARN(ctx.Command.Name)
+		assumeRoleARN := c.assumeRoleARN(ctx.Command.Environment)
+		file := newAwsCredentialsFile(c.AWSCredentialsPath, c.AWSProfile, ctx.Command.Name)
+		ctx.AWSProfile = file.Profile
+		defer func() {
+			if err := file.remove(roleARN); err != nil {
+				ctx.Log.Err("failed to remove the ECS credentials: %s", err)
+			}
+		}()
+		credentials, err := handleEcsCredentials(credentialsRelativeUri, assumeRoleARN, roleARN, file)
 		if err != nil {
-			ctx.Log.Warn("failed to fetch ECS credentials")
+			ctx.Log.Warn("failed to fetch ECS credentials: %s", err)
 			return
 		}
+		// We wait for the refresh to stop so it can't write the credentials
+		// again after they're removed. ctx.Log isn't safe to use from another
+		// goroutine so the server's logger is used.
+		refreshCtx, stopRefresh := context.WithCancel(ctx.Context)
+		refreshDone := make(chan struct{})
+		go func() {
+			refreshEcsCredentials(refreshCtx, c.Logger, credentialsRelativeUri, assumeRoleARN, roleARN, file, credentials)
+			close(refreshDone)
+		}()
+		defer func() {
+			stopRefresh()
+			<-refreshDone
+		}()
 	}
 
-	defer func() {
-		err := os.RemoveAll("/home/atlantis/.aws")
-		if err != nil {
-			ctx.Log.Err("failed to remove /home/atlantis/.aws/credentials")
-		}
-	}()
-
 	var cr CommandResponse
 	switch ctx.Command.Name {
 	case Plan:
@@ -173,13 +320,47 @@ func (c *CommandHandler) run(ctx *CommandContext) {
 		cr = c.ApplyExecutor.Execute(ctx)
 	case Help:
 		cr = c.HelpExecutor.Execute(ctx)
+	case VersionCheck:
+		cr = c.VersionCheckExecutor.Execute(ctx)
+	case Fmt:
+		cr = c.FmtExecutor.Execute(ctx)
+	case Validate:
+		cr = c.ValidateExecutor.Execute(ctx)
+	case History:
+		cr = c.HistoryExecutor.Execute(ctx)
 	default:
 		ctx.Log.Err("failed to determine desired command, neither plan nor apply")
 	}
 	c.updatePull(ctx, cr)
 }
 
+// checkFork returns false and a response explaining why if the command can't
+// be run on a pull request from a fork.
+func (c *CommandHandler) checkFork(ctx *CommandContext) (CommandResponse, bool) {
+	if c.ForkPolicy != RequireMemberCommentForks {
+		if ctx.Command.Name == Apply {
+			return CommandResponse{Failure: "Apply is disabled for pull requests from forks. A member of the repo needs to open the pull request from a branch in the repo."}, false
+		}
+		return CommandResponse{}, true
+	}
+	if ctx.User.Username == ctx.Pull.Author {
+		return CommandResponse{Failure: "Commands on pull requests from forks must be commented by a member of the repo other than the pull request's author."}, false
+	}
+	isMember, err := c.VCSClient.UserIsMember(ctx.Context, ctx.BaseRepo, ctx.User.Username, ctx.VCSHost)
+	if err != nil {
+		return CommandResponse{Error: errors.Wrapf(err, "checking if %s is a member of %s", ctx.User.Username, ctx.BaseRepo.FullName)}, false
+	}
+	if !isMember {
+		return CommandResponse{Failure: fmt.Sprintf("Commands on pull requests from forks must be commented by a member of the repo. %s isn't a member of %s.", ctx.User.Username, ctx.BaseRepo.FullName)}, false
+	}
+	return CommandResponse{}, true
+}
+
 func (c *CommandHandler) updatePull(ctx *CommandContext, res CommandResponse) {
+	_, span := tracing.Start(ctx.Context, "comment")
+	defer span.End()
+	span.SetError(res.Error)
+
 	// Log if we got any errors or failures.
 	if res.Error != nil {
 		ctx.Log.Err(res.Error.Error())
@@ -187,17 +368,141 @@ func (c *CommandHandler) updatePull(ctx *CommandContext, res CommandResponse) {
 		ctx.Log.Warn(res.Failure)
 	}
 
+	c.recordRun(ctx, res)
+
 	// Update the pull request's status icon and comment back.
 	c.CommitStatusUpdater.UpdateProjectResult(ctx, res) // nolint: errcheck
 	comment := c.MarkdownRenderer.Render(res, ctx.Command.Name, ctx.Log.History.String(), ctx.Command.Verbose)
-	c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull, comment, ctx.VCSHost) // nolint: errcheck
+	marker := c.commentMarker(ctx, res)
+	comment = vcs.WithMarker(marker, comment)
+	switch c.CommentRetention {
+	case DeleteComments:
+		c.deletePreviousComments(ctx, marker)
+	case UpdateComments:
+		if c.updatePreviousComment(ctx, marker, comment) {
+			return
+		}
+	}
+	c.VCSClient.CreateComment(ctx.Context, ctx.BaseRepo, ctx.Pull, comment, ctx.VCSHost) // nolint: errcheck
+}
+
+// updatePreviousComment replaces the most recent comment that marker
+// supersedes with comment. It returns false if there was no comment to update
+// or we failed to update it, in which case the caller should create a new
+// comment.
+func (c *CommandHandler) updatePreviousComment(ctx *CommandContext, marker vcs.CommentMarker, comment string) bool {
+	comments, err := c.VCSClient.GetComments(ctx.Context, ctx.BaseRepo, ctx.Pull, ctx.VCSHost)
+	if err != nil {
+		ctx.Log.Warn("unable to get previous comments: %s", err)
+		return false
+	}
+	user, err := c.commentUser(ctx.VCSHost)
+	if err != nil {
+		ctx.Log.Warn("unable to get the user Atlantis comments as: %s", err)
+		return false
+	}
+	// Comments are returned oldest first.
+	for i := len(comments) - 1; i >= 0; i-- {
+		if !postedBy(comments[i], user) {
+			continue
+		}
+		prev, ok := vcs.ParseCommentMarker(comments[i].Body)
+		if !ok || !marker.Supersedes(prev) {
+			continue
+		}
+		if err := c.VCSClient.UpdateComment(ctx.Context, ctx.BaseRepo, ctx.Pull, comments[i].ID, comment, ctx.VCSHost); err != nil {
+			ctx.Log.Warn("unable to update previous comment %d, creating a new one: %s", comments[i].ID, err)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// recordRun adds plans and applies to the run history.
+func (c *CommandHandler) recordRun(ctx *CommandContext, res CommandResponse) {
+	if c.RunHistory == nil || (ctx.Command.Name != Plan && ctx.Command.Name != Apply) {
+		return
+	}
+	err := c.RunHistory.Append(history.Run{
+		Time:         time.Now(),
+		RepoFullName: ctx.BaseRepo.FullName,
+		PullNum:      ctx.Pull.Num,
+		User:         ctx.User.Username,
+		Environment:  ctx.Command.Environment,
+		Command:      ctx.Command.Name.String(),
+		Outcome:      res.Status().String(),
+		Emergency:    ctx.Command.Emergency,
+		Ticket:       ctx.Command.Ticket,
+		Exported:     ctx.Command.Name == Plan && ctx.Command.Export,
+	})
+	if err != nil {
+		ctx.Log.Warn("unable to record run in history: %s", err)
+	}
+}
+
+// commentMarker returns the marker that identifies the comment for res.
+func (c *CommandHandler) commentMarker(ctx *CommandContext, res CommandResponse) vcs.CommentMarker {
+	marker := vcs.CommentMarker{
+		Command:     ctx.Command.Name.String(),
+		Environment: ctx.Command.Environment,
+	}
+	for _, p := range res.ProjectResults {
+		marker.Projects = append(marker.Projects, p.Path)
+	}
+	return marker
+}
+
+// deletePreviousComments deletes the comments we posted with the results of
+// previous runs of the same command in the same environment. Failing to
+// delete a comment isn't fatal so we only log.
+func (c *CommandHandler) deletePreviousComments(ctx *CommandContext, marker vcs.CommentMarker) {
+	comments, err := c.VCSClient.GetComments(ctx.Context, ctx.BaseRepo, ctx.Pull, ctx.VCSHost)
+	if err != nil {
+		ctx.Log.Warn("unable to get previous comments: %s", err)
+		return
+	}
+	user, err := c.commentUser(ctx.VCSHost)
+	if err != nil {
+		ctx.Log.Warn("unable to get the user Atlantis comments as: %s", err)
+		return
+	}
+	for _, comment := range comments {
+		if !postedBy(comment, user) {
+			continue
+		}
+		prev, ok := vcs.ParseCommentMarker(comment.Body)
+		if !ok || prev.Command != marker.Command || prev.Environment != marker.Environment {
+			continue
+		}
+		if err := c.VCSClient.DeleteComment(ctx.Context, ctx.BaseRepo, ctx.Pull, comment.ID, ctx.VCSHost); err != nil {
+			ctx.Log.Warn("unable to delete previous comment %d: %s", comment.ID, err)
+		}
+	}
+}
+
+// commentUser returns the username Atlantis comments as on host.
+func (c *CommandHandler) commentUser(host vcs.Host) (string, error) {
+	if host == vcs.Gitlab {
+		return c.GitlabUser, nil
+	}
+	if c.GithubApp != nil {
+		return c.GithubApp.Login()
+	}
+	return c.GithubUser, nil
+}
+
+// postedBy returns true if comment was posted by user. Usernames aren't case
+// sensitive.
+func postedBy(comment vcs.Comment, user string) bool {
+	return user != "" && strings.EqualFold(comment.Author, user)
 }
 
 // logPanics logs and creates a comment on the pull request for panics
 func (c *CommandHandler) logPanics(ctx *CommandContext) {
 	if err := recover(); err != nil {
 		stack := recovery.Stack(3)
-		c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull, // nolint: errcheck
+		c.VCSClient.CreateComment(ctx.Context, ctx.BaseRepo, ctx.Pull, // nolint: errcheck
 			fmt.Sprintf("**Error: goroutine panic. This is a bug.**\n```\n%s\n%s```", err, stack), ctx.VCSHost)
 		ctx.Log.Err("PANIC: %s\n%s", err, stack)
 	}
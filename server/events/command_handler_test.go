@@ -2,6 +2,7 @@ package events_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"log"
 	"strings"
@@ -57,6 +58,7 @@ func setup(t *testing.T) {
 		MarkdownRenderer:         &events.MarkdownRenderer{},
 		GithubPullGetter:         githubGetter,
 		GitlabMergeRequestGetter: gitlabGetter,
+		GithubUser:               "atlantis-bot",
 		Logger: logger,
 	}
 }
@@ -64,9 +66,9 @@ func setup(t *testing.T) {
 func TestExecuteCommand_LogPanics(t *testing.T) {
 	t.Log("if there is a panic it is commented back on the pull request")
 	setup(t)
-	When(ghStatus.Update(fixtures.Repo, fixtures.Pull, vcs.Pending, nil, vcs.Github)).ThenPanic("panic")
+	When(ghStatus.Update(context.Background(), fixtures.Repo, fixtures.Pull, vcs.Pending, nil, vcs.Github)).ThenPanic("panic")
 	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, 1, nil, vcs.Github)
-	_, _, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost()).GetCapturedArguments()
+	_, _, _, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost()).GetCapturedArguments()
 	Assert(t, strings.Contains(comment, "Error: goroutine panic"), "comment should be about a goroutine panic")
 }
 
@@ -89,7 +91,7 @@ func TestExecuteCommand_NoGitlabMergeGetter(t *testing.T) {
 func TestExecuteCommand_GithubPullErr(t *testing.T) {
 	t.Log("if getting the github pull request fails an error should be logged")
 	setup(t)
-	When(githubGetter.GetPullRequest(fixtures.Repo, fixtures.Pull.Num)).ThenReturn(nil, errors.New("err"))
+	When(githubGetter.GetPullRequest(context.Background(), fixtures.Repo, fixtures.Pull.Num)).ThenReturn(nil, errors.New("err"))
 	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, nil, vcs.Github)
 	Equals(t, "[ERROR] hootsuite/atlantis#1: Making pull request API call to GitHub: err\n", logBytes.String())
 }
@@ -97,7 +99,7 @@ func TestExecuteCommand_GithubPullErr(t *testing.T) {
 func TestExecuteCommand_GitlabMergeRequestErr(t *testing.T) {
 	t.Log("if getting the gitlab merge request fails an error should be logged")
 	setup(t)
-	When(gitlabGetter.GetMergeRequest(fixtures.Repo.FullName, fixtures.Pull.Num)).ThenReturn(nil, errors.New("err"))
+	When(gitlabGetter.GetMergeRequest(context.Background(), fixtures.Repo.FullName, fixtures.Pull.Num)).ThenReturn(nil, errors.New("err"))
 	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, nil, vcs.Gitlab)
 	Equals(t, "[ERROR] hootsuite/atlantis#1: Making merge request API call to GitLab: err\n", logBytes.String())
 }
@@ -106,7 +108,7 @@ func TestExecuteCommand_GithubPullParseErr(t *testing.T) {
 	t.Log("if parsing the returned github pull request fails an error should be logged")
 	setup(t)
 	var pull github.PullRequest
-	When(githubGetter.GetPullRequest(fixtures.Repo, fixtures.Pull.Num)).ThenReturn(&pull, nil)
+	When(githubGetter.GetPullRequest(context.Background(), fixtures.Repo, fixtures.Pull.Num)).ThenReturn(&pull, nil)
 	When(eventParsing.ParseGithubPull(&pull)).ThenReturn(fixtures.Pull, fixtures.Repo, errors.New("err"))
 
 	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, nil, vcs.Github)
@@ -121,11 +123,11 @@ func TestExecuteCommand_ClosedPull(t *testing.T) {
 		State: github.String("closed"),
 	}
 	modelPull := models.PullRequest{State: models.Closed}
-	When(githubGetter.GetPullRequest(fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(githubGetter.GetPullRequest(context.Background(), fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
 	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.Repo, nil)
 
 	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, nil, vcs.Github)
-	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.Repo, modelPull, "Atlantis commands can't be run on closed pull requests", vcs.Github)
+	vcsClient.VerifyWasCalledOnce().CreateComment(context.Background(), fixtures.Repo, modelPull, "Atlantis commands can't be run on closed pull requests", vcs.Github)
 }
 
 func TestExecuteCommand_EnvLocked(t *testing.T) {
@@ -139,7 +141,7 @@ func TestExecuteCommand_EnvLocked(t *testing.T) {
 		Environment: "env",
 	}
 
-	When(githubGetter.GetPullRequest(fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(githubGetter.GetPullRequest(context.Background(), fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
 	When(eventParsing.ParseGithubPull(pull)).ThenReturn(fixtures.Pull, fixtures.Repo, nil)
 	When(envLocker.TryLock(fixtures.Repo.FullName, cmd.Environment, fixtures.Pull.Num)).ThenReturn(false)
 	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github)
@@ -147,11 +149,45 @@ func TestExecuteCommand_EnvLocked(t *testing.T) {
 	msg := "The env environment is currently locked by another" +
 		" command that is running for this pull request." +
 		" Wait until the previous command is complete and try again."
-	ghStatus.VerifyWasCalledOnce().Update(fixtures.Repo, fixtures.Pull, vcs.Pending, &cmd, vcs.Github)
+	ghStatus.VerifyWasCalledOnce().Update(context.Background(), fixtures.Repo, fixtures.Pull, vcs.Pending, &cmd, vcs.Github)
 	_, response := ghStatus.VerifyWasCalledOnce().UpdateProjectResult(matchers.AnyPtrToEventsCommandContext(), matchers.AnyEventsCommandResponse()).GetCapturedArguments()
 	Equals(t, msg, response.Failure)
-	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.Repo, fixtures.Pull,
-		"**Plan Failed**: "+msg+"\n\n", vcs.Github)
+	vcsClient.VerifyWasCalledOnce().CreateComment(context.Background(), fixtures.Repo, fixtures.Pull,
+		"<!-- atlantis:plan:env=env -->\n**Plan Failed**: "+msg+"\n\n", vcs.Github)
+}
+
+func TestExecuteCommand_DeletePreviousComments(t *testing.T) {
+	t.Log("when configured to delete comments we should only delete our own for the same command and environment")
+	setup(t)
+	ch.CommentRetention = events.DeleteComments
+	pull := &github.PullRequest{
+		State: github.String("closed"),
+	}
+	cmd := events.Command{
+		Name:        events.Plan,
+		Environment: "env",
+	}
+	When(githubGetter.GetPullRequest(context.Background(), fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(fixtures.Pull, fixtures.Repo, nil)
+	When(envLocker.TryLock(fixtures.Repo.FullName, cmd.Environment, fixtures.Pull.Num)).ThenReturn(true)
+	When(planner.Execute(matchers.AnyPtrToEventsCommandContext())).ThenReturn(events.CommandResponse{})
+	When(vcsClient.GetComments(context.Background(), fixtures.Repo, fixtures.Pull, vcs.Github)).ThenReturn([]vcs.Comment{
+		{ID: 1, Body: "<!-- atlantis:plan:env=env:project=. -->\nold plan", Author: "atlantis-bot"},
+		{ID: 2, Body: "a comment from a human", Author: "alice"},
+		{ID: 3, Body: "<!-- atlantis:plan:env=other -->\nother environment", Author: "atlantis-bot"},
+		{ID: 4, Body: "<!-- atlantis:apply:env=env -->\napply", Author: "atlantis-bot"},
+		{ID: 5, Body: "<!-- atlantis:plan:env=env -->\nquoted by a human", Author: "alice"},
+		{ID: 6, Body: "<!-- atlantis:plan:env=env -->\nolder plan", Author: "Atlantis-Bot"},
+	}, nil)
+
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github)
+
+	vcsClient.VerifyWasCalledOnce().DeleteComment(context.Background(), fixtures.Repo, fixtures.Pull, 1, vcs.Github)
+	vcsClient.VerifyWasCalledOnce().DeleteComment(context.Background(), fixtures.Repo, fixtures.Pull, 6, vcs.Github)
+	for _, id := range []int{2, 3, 4, 5} {
+		vcsClient.VerifyWasCalled(Never()).DeleteComment(context.Background(), fixtures.Repo, fixtures.Pull, id, vcs.Github)
+	}
+	vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost())
 }
 
 func TestExecuteCommand_FullRun(t *testing.T) {
@@ -166,7 +202,7 @@ func TestExecuteCommand_FullRun(t *testing.T) {
 			Name:        c,
 			Environment: "env",
 		}
-		When(githubGetter.GetPullRequest(fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+		When(githubGetter.GetPullRequest(context.Background(), fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
 		When(eventParsing.ParseGithubPull(pull)).ThenReturn(fixtures.Pull, fixtures.Repo, nil)
 		When(envLocker.TryLock(fixtures.Repo.FullName, cmd.Environment, fixtures.Pull.Num)).ThenReturn(true)
 		switch c {
@@ -180,10 +216,137 @@ func TestExecuteCommand_FullRun(t *testing.T) {
 
 		ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github)
 
-		ghStatus.VerifyWasCalledOnce().Update(fixtures.Repo, fixtures.Pull, vcs.Pending, &cmd, vcs.Github)
+		ghStatus.VerifyWasCalledOnce().Update(context.Background(), fixtures.Repo, fixtures.Pull, vcs.Pending, &cmd, vcs.Github)
 		_, response := ghStatus.VerifyWasCalledOnce().UpdateProjectResult(matchers.AnyPtrToEventsCommandContext(), matchers.AnyEventsCommandResponse()).GetCapturedArguments()
 		Equals(t, cmdResponse, response)
-		vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost())
+		vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost())
 		envLocker.VerifyWasCalledOnce().Unlock(fixtures.Repo.FullName, cmd.Environment, fixtures.Pull.Num)
 	}
 }
+
+func TestExecuteCommand_ForkPolicy(t *testing.T) {
+	t.Log("commands on pull requests from forks should only run if the fork policy allows them")
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	forkRepo := models.Repo{FullName: "someone/atlantis", Owner: "someone", Name: "atlantis"}
+	member := models.User{Username: "alice"}
+	cases := []struct {
+		description string
+		policy      events.ForkPolicy
+		command     events.CommandName
+		user        models.User
+		isMember    bool
+		expRun      bool
+		expFailure  string
+	}{
+		{"ignore", events.IgnoreForks, events.Plan, member, true, false, ""},
+		{"plan-only plan", events.PlanOnlyForks, events.Plan, fixtures.User, false, true, ""},
+		{"plan-only apply", events.PlanOnlyForks, events.Apply, member, true, false, "Apply is disabled for pull requests from forks. A member of the repo needs to open the pull request from a branch in the repo."},
+		{"require-member-comment by member", events.RequireMemberCommentForks, events.Apply, member, true, true, ""},
+		{"require-member-comment by author", events.RequireMemberCommentForks, events.Plan, fixtures.User, true, false, "Commands on pull requests from forks must be commented by a member of the repo other than the pull request's author."},
+		{"require-member-comment by non-member", events.RequireMemberCommentForks, events.Plan, member, false, false, "Commands on pull requests from forks must be commented by a member of the repo. alice isn't a member of hootsuite/atlantis."},
+	}
+	for _, c := range cases {
+		t.Log(c.description)
+		setup(t)
+		ch.ForkPolicy = c.policy
+		cmd := events.Command{
+			Name:        c.command,
+			Environment: "env",
+		}
+		When(githubGetter.GetPullRequest(context.Background(), fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+		When(eventParsing.ParseGithubPull(pull)).ThenReturn(fixtures.Pull, forkRepo, nil)
+		When(envLocker.TryLock(fixtures.Repo.FullName, cmd.Environment, fixtures.Pull.Num)).ThenReturn(true)
+		When(vcsClient.UserIsMember(matchers.AnyContextContext(), matchers.AnyModelsRepo(), AnyString(), matchers.AnyVcsHost())).ThenReturn(c.isMember, nil)
+		executor := planner
+		if c.command == events.Apply {
+			executor = applier
+		}
+		When(executor.Execute(matchers.AnyPtrToEventsCommandContext())).ThenReturn(events.CommandResponse{})
+
+		ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, c.user, fixtures.Pull.Num, &cmd, vcs.Github)
+
+		if c.expRun {
+			executor.VerifyWasCalledOnce().Execute(matchers.AnyPtrToEventsCommandContext())
+			continue
+		}
+		executor.VerifyWasCalled(Never()).Execute(matchers.AnyPtrToEventsCommandContext())
+		if c.expFailure == "" {
+			vcsClient.VerifyWasCalled(Never()).CreateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost())
+			continue
+		}
+		_, response := ghStatus.VerifyWasCalledOnce().UpdateProjectResult(matchers.AnyPtrToEventsCommandContext(), matchers.AnyEventsCommandResponse()).GetCapturedArguments()
+		Equals(t, c.expFailure, response.Failure)
+	}
+}
+
+func TestExecuteCommand_UpdatePreviousComment(t *testing.T) {
+	t.Log("when configured to update comments we should edit the latest comment for the same command, environment and projects")
+	setup(t)
+	ch.CommentRetention = events.UpdateComments
+	cmd := setupFullRun(events.Plan)
+	When(vcsClient.GetComments(context.Background(), fixtures.Repo, fixtures.Pull, vcs.Github)).ThenReturn([]vcs.Comment{
+		{ID: 1, Body: "<!-- atlantis:plan:env=env -->\nolder plan", Author: "atlantis-bot"},
+		{ID: 2, Body: "<!-- atlantis:plan:env=env -->\nold plan", Author: "atlantis-bot"},
+		{ID: 3, Body: "<!-- atlantis:apply:env=env -->\napply", Author: "atlantis-bot"},
+		{ID: 4, Body: "<!-- atlantis:plan:env=other -->\nother environment", Author: "atlantis-bot"},
+		{ID: 5, Body: "<!-- atlantis:plan:env=env:project=other -->\nother project", Author: "atlantis-bot"},
+		{ID: 6, Body: "<!-- atlantis:plan:env=env -->\nquoted by a human", Author: "alice"},
+	}, nil)
+
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github)
+
+	_, _, _, id, comment, _ := vcsClient.VerifyWasCalledOnce().UpdateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyInt(), AnyString(), matchers.AnyVcsHost()).GetCapturedArguments()
+	Equals(t, 2, id)
+	Assert(t, strings.HasPrefix(comment, "<!-- atlantis:plan:env=env -->\n"), "comment should start with its marker")
+	vcsClient.VerifyWasCalled(Never()).CreateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost())
+}
+
+func TestExecuteCommand_UpdatePreviousCommentFails(t *testing.T) {
+	t.Log("if we can't update the previous comment we should create a new one")
+	setup(t)
+	ch.CommentRetention = events.UpdateComments
+	cmd := setupFullRun(events.Plan)
+	When(vcsClient.GetComments(context.Background(), fixtures.Repo, fixtures.Pull, vcs.Github)).ThenReturn([]vcs.Comment{
+		{ID: 1, Body: "<!-- atlantis:plan:env=env -->\nold plan", Author: "atlantis-bot"},
+	}, nil)
+	When(vcsClient.UpdateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyInt(), AnyString(), matchers.AnyVcsHost())).ThenReturn(errors.New("err"))
+
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github)
+
+	vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost())
+}
+
+func TestExecuteCommand_UpdatePreviousCommentUnknownUser(t *testing.T) {
+	t.Log("if we don't know the user we comment as we shouldn't update any comments")
+	setup(t)
+	ch.CommentRetention = events.UpdateComments
+	ch.GithubUser = ""
+	cmd := setupFullRun(events.Plan)
+	When(vcsClient.GetComments(context.Background(), fixtures.Repo, fixtures.Pull, vcs.Github)).ThenReturn([]vcs.Comment{
+		{ID: 1, Body: "<!-- atlantis:plan:env=env -->\nold plan", Author: "alice"},
+	}, nil)
+
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github)
+
+	vcsClient.VerifyWasCalled(Never()).UpdateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyInt(), AnyString(), matchers.AnyVcsHost())
+	vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost())
+}
+
+// setupFullRun sets up the mocks so a command named name runs successfully
+// in the "env" environment.
+func setupFullRun(name events.CommandName) events.Command {
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	cmd := events.Command{
+		Name:        name,
+		Environment: "env",
+	}
+	When(githubGetter.GetPullRequest(context.Background(), fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(fixtures.Pull, fixtures.Repo, nil)
+	When(envLocker.TryLock(fixtures.Repo.FullName, cmd.Environment, fixtures.Pull.Num)).ThenReturn(true)
+	When(planner.Execute(matchers.AnyPtrToEventsCommandContext())).ThenReturn(events.CommandResponse{})
+	return cmd
+}
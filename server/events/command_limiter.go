@@ -0,0 +1,88 @@
+package events
+
+import (
+	"sync/atomic"
+)
+
+// CommandLimiter limits how many commands run at the same time. Commands over
+// the limit wait in a queue until a command finishes. Once the queue is full,
+// new commands are rejected so the caller can tell the VCS host to retry later.
+// A nil CommandLimiter doesn't limit anything.
+type CommandLimiter struct {
+	// slots has a buffer of the maximum number of commands in flight. It is
+	// nil if there's no limit.
+	slots     chan struct{}
+	maxQueued int64
+	inFlight  int64
+	queued    int64
+}
+
+// NewCommandLimiter returns a CommandLimiter that runs at most maxInFlight
+// commands at once and queues at most maxQueued more. If maxInFlight is 0 or
+// less, there's no limit.
+func NewCommandLimiter(maxInFlight int, maxQueued int) *CommandLimiter {
+	c := &CommandLimiter{maxQueued: int64(maxQueued)}
+	if maxInFlight > 0 {
+		c.slots = make(chan struct{}, maxInFlight)
+	}
+	return c
+}
+
+// Go runs f in a new goroutine once there's room for another command. It
+// returns false without running f if the queue is full.
+func (c *CommandLimiter) Go(f func()) bool {
+	if c == nil || c.slots == nil {
+		go c.run(f)
+		return true
+	}
+	select {
+	case c.slots <- struct{}{}:
+		go c.run(f)
+		return true
+	default:
+	}
+
+	if atomic.AddInt64(&c.queued, 1) > c.maxQueued {
+		atomic.AddInt64(&c.queued, -1)
+		return false
+	}
+	go func() {
+		c.slots <- struct{}{}
+		atomic.AddInt64(&c.queued, -1)
+		c.run(f)
+	}()
+	return true
+}
+
+// InFlight returns the number of commands that are running.
+func (c *CommandLimiter) InFlight() int {
+	if c == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&c.inFlight))
+}
+
+// Queued returns the number of commands waiting to run.
+func (c *CommandLimiter) Queued() int {
+	if c == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&c.queued))
+}
+
+// run runs f and frees up its slot when it's done. The caller must already
+// hold a slot if there is a limit.
+func (c *CommandLimiter) run(f func()) {
+	if c == nil {
+		f()
+		return
+	}
+	atomic.AddInt64(&c.inFlight, 1)
+	defer func() {
+		atomic.AddInt64(&c.inFlight, -1)
+		if c.slots != nil {
+			<-c.slots
+		}
+	}()
+	f()
+}
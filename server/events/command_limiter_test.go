@@ -0,0 +1,60 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestCommandLimiter_Unlimited(t *testing.T) {
+	t.Log("with no limit every command should run straight away")
+	l := events.NewCommandLimiter(0, 0)
+	release := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		Assert(t, l.Go(func() { <-release }), "expected command to be accepted")
+	}
+	waitFor(t, func() bool { return l.InFlight() == 5 })
+	Equals(t, 0, l.Queued())
+	close(release)
+	waitFor(t, func() bool { return l.InFlight() == 0 })
+}
+
+func TestCommandLimiter_QueuesThenRejects(t *testing.T) {
+	t.Log("commands over the limit should be queued and rejected once the queue is full")
+	l := events.NewCommandLimiter(1, 1)
+	release := make(chan struct{})
+	ran := make(chan int, 2)
+
+	Assert(t, l.Go(func() { <-release; ran <- 1 }), "expected first command to run")
+	Assert(t, l.Go(func() { ran <- 2 }), "expected second command to be queued")
+	Assert(t, !l.Go(func() {}), "expected third command to be rejected")
+	waitFor(t, func() bool { return l.InFlight() == 1 })
+	Equals(t, 1, l.Queued())
+
+	close(release)
+	Equals(t, 1, <-ran)
+	Equals(t, 2, <-ran)
+	waitFor(t, func() bool { return l.InFlight() == 0 && l.Queued() == 0 })
+}
+
+func TestCommandLimiter_Nil(t *testing.T) {
+	t.Log("a nil limiter should run commands without limiting them")
+	var l *events.CommandLimiter
+	done := make(chan struct{})
+	Assert(t, l.Go(func() { close(done) }), "expected command to be accepted")
+	<-done
+	Equals(t, 0, l.InFlight())
+	Equals(t, 0, l.Queued())
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for condition")
+}
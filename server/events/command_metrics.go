@@ -0,0 +1,136 @@
+package events
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Results of a plan or apply in a project.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultError   = "error"
+)
+
+// terraformDurationBuckets are the upper bounds, in seconds, of the terraform
+// duration histogram's buckets. Applies of large projects can take several
+// minutes.
+var terraformDurationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800}
+
+// metricsCommands are the commands that CommandMetrics counts, in the order
+// they're written.
+var metricsCommands = []CommandName{Plan, Apply}
+
+// CommandMetrics counts the results of plans and applies in each project by
+// environment and how long terraform took to run them. A nil CommandMetrics
+// records nothing.
+type CommandMetrics struct {
+	mutex     sync.Mutex
+	results   map[commandResultKey]int
+	durations map[commandKey]*histogram
+}
+
+type commandKey struct {
+	Command     CommandName
+	Environment string
+}
+
+type commandResultKey struct {
+	commandKey
+	Result string
+}
+
+// NewCommandMetrics returns metrics with nothing recorded.
+func NewCommandMetrics() *CommandMetrics {
+	return &CommandMetrics{
+		results:   make(map[commandResultKey]int),
+		durations: make(map[commandKey]*histogram),
+	}
+}
+
+// ObserveResult records the result of command in a project in environment.
+func (m *CommandMetrics) ObserveResult(command CommandName, environment string, result ProjectResult) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.results[commandResultKey{commandKey{command, environment}, resultLabel(result)}]++
+}
+
+// ObserveTerraform records that terraform took d to run command in
+// environment.
+func (m *CommandMetrics) ObserveTerraform(command CommandName, environment string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	key := commandKey{command, environment}
+	h, ok := m.durations[key]
+	if !ok {
+		h = newHistogram(terraformDurationBuckets)
+		m.durations[key] = h
+	}
+	h.observe(d)
+}
+
+// Write writes the metrics in the Prometheus text format.
+func (m *CommandMetrics) Write(w io.Writer) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, command := range metricsCommands {
+		name := fmt.Sprintf("atlantis_%s_total", command)
+		fmt.Fprintf(w, "# HELP %s Number of projects %s ran in by result.\n# TYPE %s counter\n", name, command, name)
+		var keys []commandResultKey
+		for k := range m.results {
+			if k.Command == command {
+				keys = append(keys, k)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].Environment != keys[j].Environment {
+				return keys[i].Environment < keys[j].Environment
+			}
+			return keys[i].Result < keys[j].Result
+		})
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s{result=%q,environment=\"%s\"} %d\n", name, k.Result, escapeLabel(k.Environment), m.results[k])
+		}
+	}
+
+	const durationName = "atlantis_terraform_command_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s How long terraform plan and apply took.\n# TYPE %s histogram\n", durationName, durationName)
+	var keys []commandKey
+	for k := range m.durations {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Command != keys[j].Command {
+			return keys[i].Command < keys[j].Command
+		}
+		return keys[i].Environment < keys[j].Environment
+	})
+	for _, k := range keys {
+		labels := fmt.Sprintf("command=%q,environment=\"%s\"", k.Command.String(), escapeLabel(k.Environment))
+		m.durations[k].write(w, durationName, labels)
+	}
+}
+
+// resultLabel returns whether result is a success, a failure or an error.
+func resultLabel(result ProjectResult) string {
+	if result.Error != nil {
+		return ResultError
+	}
+	if result.Failure != "" {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
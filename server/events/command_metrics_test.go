@@ -0,0 +1,54 @@
+package events_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestCommandMetrics_Write(t *testing.T) {
+	t.Log("command metrics should count results by environment and bucket terraform durations by command")
+	m := events.NewCommandMetrics()
+	m.ObserveResult(events.Apply, "production", events.ProjectResult{ApplySuccess: "applied"})
+	m.ObserveResult(events.Apply, "production", events.ProjectResult{Failure: "not approved"})
+	m.ObserveResult(events.Apply, "production", events.ProjectResult{Error: errors.New("err")})
+	m.ObserveResult(events.Plan, "staging", events.ProjectResult{PlanSuccess: &events.PlanSuccess{}})
+	m.ObserveTerraform(events.Apply, "production", 45*time.Second)
+	m.ObserveTerraform(events.Plan, "staging", 3*time.Second)
+
+	var buf bytes.Buffer
+	m.Write(&buf)
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE atlantis_plan_total counter\n",
+		`atlantis_plan_total{result="success",environment="staging"} 1` + "\n",
+		"# TYPE atlantis_apply_total counter\n",
+		`atlantis_apply_total{result="error",environment="production"} 1` + "\n",
+		`atlantis_apply_total{result="failure",environment="production"} 1` + "\n",
+		`atlantis_apply_total{result="success",environment="production"} 1` + "\n",
+		"# TYPE atlantis_terraform_command_duration_seconds histogram\n",
+		`atlantis_terraform_command_duration_seconds_bucket{command="apply",environment="production",le="30"} 0` + "\n",
+		`atlantis_terraform_command_duration_seconds_bucket{command="apply",environment="production",le="60"} 1` + "\n",
+		`atlantis_terraform_command_duration_seconds_sum{command="apply",environment="production"} 45` + "\n",
+		`atlantis_terraform_command_duration_seconds_bucket{command="plan",environment="staging",le="5"} 1` + "\n",
+		`atlantis_terraform_command_duration_seconds_count{command="plan",environment="staging"} 1` + "\n",
+	} {
+		Assert(t, strings.Contains(out, line), "missing %q in %q", line, out)
+	}
+	Assert(t, !strings.Contains(out, `atlantis_plan_total{result="success",environment="production"}`), "exp applies to not be counted as plans")
+}
+
+func TestCommandMetrics_Nil(t *testing.T) {
+	t.Log("nil command metrics should record and write nothing")
+	var m *events.CommandMetrics
+	m.ObserveResult(events.Apply, "production", events.ProjectResult{})
+	m.ObserveTerraform(events.Apply, "production", time.Second)
+	var buf bytes.Buffer
+	m.Write(&buf)
+	Equals(t, "", buf.String())
+}
@@ -6,6 +6,10 @@ const (
 	Apply CommandName = iota
 	Plan
 	Help
+	VersionCheck
+	History
+	Fmt
+	Validate
 	// Adding more? Don't forget to update String() below
 )
 
@@ -17,6 +21,14 @@ func (c CommandName) String() string {
 		return "plan"
 	case Help:
 		return "help"
+	case VersionCheck:
+		return "version-check"
+	case History:
+		return "history"
+	case Fmt:
+		return "fmt"
+	case Validate:
+		return "validate"
 	}
 	return ""
 }
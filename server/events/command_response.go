@@ -1,7 +1,28 @@
 package events
 
+import (
+	"github.com/hootsuite/atlantis/server/events/history"
+	"github.com/hootsuite/atlantis/server/events/vcs"
+)
+
 type CommandResponse struct {
 	Error          error
 	Failure        string
 	ProjectResults []ProjectResult
+	// History is set by the history command.
+	History []history.Run
+}
+
+// Status returns the commit status that represents the response. It's the
+// worst status of any of the projects.
+func (c CommandResponse) Status() vcs.CommitStatus {
+	if c.Error != nil || c.Failure != "" {
+		return vcs.Failed
+	}
+	for _, p := range c.ProjectResults {
+		if p.Status() == vcs.Failed {
+			return vcs.Failed
+		}
+	}
+	return vcs.Success
 }
@@ -0,0 +1,80 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CommandThrottle collapses duplicate commands that are commented on the same
+// pull request in quick succession, ex. when a user comments "atlantis plan"
+// several times because the first one seems slow. A nil CommandThrottle
+// allows every command.
+type CommandThrottle struct {
+	// Window is how long after a command is allowed that the same command
+	// on the same pull request is throttled.
+	Window time.Duration
+
+	mutex sync.Mutex
+	// allowed is when each command was last allowed, keyed by throttleKey.
+	allowed map[string]time.Time
+	now     func() time.Time
+}
+
+// NewCommandThrottle returns a throttle with window. If window is 0, it
+// returns nil so nothing is throttled.
+func NewCommandThrottle(window time.Duration) *CommandThrottle {
+	if window <= 0 {
+		return nil
+	}
+	return &CommandThrottle{
+		Window:  window,
+		allowed: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Allow returns true if cmd should be run on the pull request. It returns
+// false if the same command, with the same environment and flags, was allowed
+// on the pull request less than Window ago, no matter who commented it.
+func (t *CommandThrottle) Allow(repoFullName string, pullNum int, cmd *Command) bool {
+	if t == nil {
+		return true
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	// Forget commands that are no longer throttled so the map doesn't grow
+	// forever.
+	for key, at := range t.allowed {
+		if now.Sub(at) >= t.Window {
+			delete(t.allowed, key)
+		}
+	}
+
+	key := throttleKey(repoFullName, pullNum, cmd)
+	if _, ok := t.allowed[key]; ok {
+		return false
+	}
+	t.allowed[key] = now
+	return true
+}
+
+// ThrottledComment is what we comment when cmd is throttled.
+func (t *CommandThrottle) ThrottledComment(cmd *Command) string {
+	return fmt.Sprintf("The same %s for the %s environment was commented on this pull request less than %s ago so this command was ignored as a duplicate.",
+		cmd.Name, cmd.Environment, t.Window)
+}
+
+// throttleKey identifies cmd on the pull request. Every field of the command
+// is part of the key so a command that differs from one that was allowed,
+// ex. an apply retried with --destroy-ok or a plan of another project, isn't
+// throttled.
+func throttleKey(repoFullName string, pullNum int, cmd *Command) string {
+	normalized := *cmd
+	if len(normalized.Flags) == 0 {
+		normalized.Flags = nil
+	}
+	return fmt.Sprintf("%s#%d/%#v", repoFullName, pullNum, normalized)
+}
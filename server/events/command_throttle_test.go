@@ -0,0 +1,55 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestCommandThrottle_Duplicates(t *testing.T) {
+	t.Log("the same command on the same pull request should be throttled until the window has passed")
+	throttle := events.NewCommandThrottle(50 * time.Millisecond)
+	plan := &events.Command{Name: events.Plan, Environment: "default"}
+	Assert(t, throttle.Allow("owner/repo", 1, plan), "expected first plan to be allowed")
+	Assert(t, !throttle.Allow("owner/repo", 1, plan), "expected duplicate plan to be throttled")
+
+	time.Sleep(60 * time.Millisecond)
+	Assert(t, throttle.Allow("owner/repo", 1, plan), "expected plan to be allowed after the window")
+}
+
+func TestCommandThrottle_DifferentCommands(t *testing.T) {
+	t.Log("different commands, environments and pull requests shouldn't throttle each other")
+	throttle := events.NewCommandThrottle(time.Minute)
+	Assert(t, throttle.Allow("owner/repo", 1, &events.Command{Name: events.Plan, Environment: "default"}), "expected plan to be allowed")
+	Assert(t, throttle.Allow("owner/repo", 1, &events.Command{Name: events.Apply, Environment: "default"}), "expected apply to be allowed")
+	Assert(t, throttle.Allow("owner/repo", 1, &events.Command{Name: events.Plan, Environment: "staging"}), "expected staging plan to be allowed")
+	Assert(t, throttle.Allow("owner/repo", 2, &events.Command{Name: events.Plan, Environment: "default"}), "expected plan on another pull request to be allowed")
+	Assert(t, throttle.Allow("owner/other", 1, &events.Command{Name: events.Plan, Environment: "default"}), "expected plan on another repo to be allowed")
+}
+
+func TestCommandThrottle_DifferentFlags(t *testing.T) {
+	t.Log("the same command with different flags or projects shouldn't be throttled")
+	throttle := events.NewCommandThrottle(time.Minute)
+	apply := &events.Command{Name: events.Apply, Environment: "production"}
+	Assert(t, throttle.Allow("owner/repo", 1, apply), "expected apply to be allowed")
+	Assert(t, throttle.Allow("owner/repo", 1, &events.Command{Name: events.Apply, Environment: "production", DestroyOK: true}), "expected apply retried with --destroy-ok to be allowed")
+	Assert(t, throttle.Allow("owner/repo", 1, &events.Command{Name: events.Apply, Environment: "production", Emergency: true, Ticket: "CHG-1"}), "expected emergency apply to be allowed")
+	Assert(t, throttle.Allow("owner/repo", 1, &events.Command{Name: events.Plan, Environment: "production", ProjectName: "a"}), "expected plan of project a to be allowed")
+	Assert(t, throttle.Allow("owner/repo", 1, &events.Command{Name: events.Plan, Environment: "production", ProjectName: "b"}), "expected plan of project b to be allowed")
+	Assert(t, throttle.Allow("owner/repo", 1, &events.Command{Name: events.Plan, Environment: "production", Flags: []string{"-target=a"}}), "expected plan with extra flags to be allowed")
+
+	t.Log("a duplicate of any of them should still be throttled")
+	Assert(t, !throttle.Allow("owner/repo", 1, &events.Command{Name: events.Apply, Environment: "production", DestroyOK: true}), "expected duplicate apply with --destroy-ok to be throttled")
+	Assert(t, !throttle.Allow("owner/repo", 1, &events.Command{Name: events.Apply, Environment: "production", Flags: []string{}}), "expected duplicate apply without flags to be throttled")
+}
+
+func TestCommandThrottle_Disabled(t *testing.T) {
+	t.Log("with a window of 0 nothing should be throttled")
+	throttle := events.NewCommandThrottle(0)
+	Assert(t, throttle == nil, "expected a nil throttle")
+	plan := &events.Command{Name: events.Plan, Environment: "default"}
+	Assert(t, throttle.Allow("owner/repo", 1, plan), "expected plan to be allowed")
+	Assert(t, throttle.Allow("owner/repo", 1, plan), "expected duplicate plan to be allowed")
+}
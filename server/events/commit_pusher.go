@@ -0,0 +1,47 @@
+package events
+
+import (
+	"os/exec"
+
+	"github.com/hootsuite/atlantis/server/logging"
+	"github.com/pkg/errors"
+)
+
+// committerName and committerEmail are who commits made by Atlantis are from.
+const (
+	committerName  = "atlantis"
+	committerEmail = "atlantis@localhost"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_commit_pusher.go CommitPusher
+
+// CommitPusher commits changes in a clone and pushes them to the pull
+// request's branch.
+type CommitPusher interface {
+	// CommitAndPush commits every change to a tracked file in repoDir with
+	// message and pushes the commit to branch.
+	CommitAndPush(log *logging.SimpleLogger, repoDir string, branch string, message string) error
+}
+
+// GitCommitPusher commits and pushes with git. It pushes to the remote the
+// repo was cloned from, which already has the credentials for pushing.
+type GitCommitPusher struct{}
+
+func (g *GitCommitPusher) CommitAndPush(log *logging.SimpleLogger, repoDir string, branch string, message string) error {
+	log.Info("committing changes in %q", repoDir)
+	commitCmd := exec.Command("git", "-c", "user.name="+committerName, "-c", "user.email="+committerEmail, "commit", "--all", "--message", message)
+	commitCmd.Dir = repoDir
+	if output, err := commitCmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "committing: %s", string(output))
+	}
+
+	log.Info("pushing to branch %q", branch)
+	pushCmd := exec.Command("git", "push", "origin", "HEAD:refs/heads/"+branch)
+	pushCmd.Dir = repoDir
+	// We don't include the output because it can contain the clone URL
+	// which has our credentials in it.
+	if err := pushCmd.Run(); err != nil {
+		return errors.Wrapf(err, "pushing to branch %s", branch)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package events
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -11,7 +12,7 @@ import (
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_commit_status_updater.go CommitStatusUpdater
 
 type CommitStatusUpdater interface {
-	Update(repo models.Repo, pull models.PullRequest, status vcs.CommitStatus, cmd *Command, host vcs.Host) error
+	Update(ctx context.Context, repo models.Repo, pull models.PullRequest, status vcs.CommitStatus, cmd *Command, host vcs.Host) error
 	UpdateProjectResult(ctx *CommandContext, res CommandResponse) error
 }
 
@@ -19,30 +20,47 @@ type DefaultCommitStatusUpdater struct {
 	Client vcs.ClientProxy
 }
 
-func (d *DefaultCommitStatusUpdater) Update(repo models.Repo, pull models.PullRequest, status vcs.CommitStatus, cmd *Command, host vcs.Host) error {
+func (d *DefaultCommitStatusUpdater) Update(ctx context.Context, repo models.Repo, pull models.PullRequest, status vcs.CommitStatus, cmd *Command, host vcs.Host) error {
 	description := fmt.Sprintf("%s %s", strings.Title(cmd.Name.String()), strings.Title(status.String()))
-	return d.Client.UpdateStatus(repo, pull, status, description, host)
+	// We only set a pending status when a command starts so we say it's
+	// running to let reviewers know we're working on it.
+	if status == vcs.Pending {
+		description = fmt.Sprintf("%s Running", strings.Title(cmd.Name.String()))
+	}
+	return d.Client.UpdateStatus(ctx, repo, pull, status, description, host)
 }
 
+// maxStatusDescription is the longest description GitHub accepts.
+const maxStatusDescription = 140
+
+// UpdateProjectResult sets one status for the whole command, no matter how
+// many projects it ran in, so big pull requests don't use up our API rate
+// limit. If it ran in more than one project, the description says how many
+// failed and which.
 func (d *DefaultCommitStatusUpdater) UpdateProjectResult(ctx *CommandContext, res CommandResponse) error {
-	var status vcs.CommitStatus
-	if res.Error != nil || res.Failure != "" {
-		status = vcs.Failed
-	} else {
-		var statuses []vcs.CommitStatus
-		for _, p := range res.ProjectResults {
-			statuses = append(statuses, p.Status())
-		}
-		status = d.worstStatus(statuses)
+	status := res.Status()
+	if res.Error != nil || res.Failure != "" || len(res.ProjectResults) < 2 {
+		return d.Update(ctx.Context, ctx.BaseRepo, ctx.Pull, status, ctx.Command, ctx.VCSHost)
 	}
-	return d.Update(ctx.BaseRepo, ctx.Pull, status, ctx.Command, ctx.VCSHost)
+	return d.Client.UpdateStatus(ctx.Context, ctx.BaseRepo, ctx.Pull, status, projectsDescription(ctx.Command, res.ProjectResults), ctx.VCSHost)
 }
 
-func (d *DefaultCommitStatusUpdater) worstStatus(ss []vcs.CommitStatus) vcs.CommitStatus {
-	for _, s := range ss {
-		if s == vcs.Failed {
-			return vcs.Failed
+// projectsDescription describes the results of cmd in each project, ex.
+// "Plan Failed in 2 of 5 projects: path1, path2".
+func projectsDescription(cmd *Command, results []ProjectResult) string {
+	name := strings.Title(cmd.Name.String())
+	var failed []string
+	for _, result := range results {
+		if result.Status() == vcs.Failed {
+			failed = append(failed, result.Path)
 		}
 	}
-	return vcs.Success
+	if len(failed) == 0 {
+		return fmt.Sprintf("%s Succeeded in %d projects", name, len(results))
+	}
+	description := fmt.Sprintf("%s Failed in %d of %d projects: %s", name, len(failed), len(results), strings.Join(failed, ", "))
+	if len(description) > maxStatusDescription {
+		description = description[:maxStatusDescription-3] + "..."
+	}
+	return description
 }
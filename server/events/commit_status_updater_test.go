@@ -1,6 +1,7 @@
 package events_test
 
 import (
+	"context"
 	"errors"
 	"strings"
 	"testing"
@@ -9,6 +10,7 @@ import (
 	"github.com/hootsuite/atlantis/server/events/models"
 	"github.com/hootsuite/atlantis/server/events/vcs"
 	"github.com/hootsuite/atlantis/server/events/vcs/mocks"
+	"github.com/hootsuite/atlantis/server/events/vcs/mocks/matchers"
 	. "github.com/hootsuite/atlantis/testing"
 	. "github.com/petergtz/pegomock"
 )
@@ -35,14 +37,25 @@ func TestUpdate(t *testing.T) {
 	RegisterMockTestingT(t)
 	client := mocks.NewMockClientProxy()
 	s := events.DefaultCommitStatusUpdater{Client: client}
-	err := s.Update(repoModel, pullModel, status, &cmd, vcs.Github)
+	err := s.Update(context.Background(), repoModel, pullModel, status, &cmd, vcs.Github)
 	Ok(t, err)
-	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, status, "Plan Success", vcs.Github)
+	client.VerifyWasCalledOnce().UpdateStatus(context.Background(), repoModel, pullModel, status, "Plan Success", vcs.Github)
+}
+
+func TestUpdate_Pending(t *testing.T) {
+	t.Log("a pending status should say that the command is running")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockClientProxy()
+	s := events.DefaultCommitStatusUpdater{Client: client}
+	err := s.Update(context.Background(), repoModel, pullModel, vcs.Pending, &events.Command{Name: events.Apply}, vcs.Github)
+	Ok(t, err)
+	client.VerifyWasCalledOnce().UpdateStatus(context.Background(), repoModel, pullModel, vcs.Pending, "Apply Running", vcs.Github)
 }
 
 func TestUpdateProjectResult_Error(t *testing.T) {
 	RegisterMockTestingT(t)
 	ctx := &events.CommandContext{
+		Context:  context.Background(),
 		BaseRepo: repoModel,
 		Pull:     pullModel,
 		Command:  &events.Command{Name: events.Plan},
@@ -52,12 +65,13 @@ func TestUpdateProjectResult_Error(t *testing.T) {
 	s := events.DefaultCommitStatusUpdater{Client: client}
 	err := s.UpdateProjectResult(ctx, events.CommandResponse{Error: errors.New("err")})
 	Ok(t, err)
-	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, vcs.Failed, "Plan Failed", vcs.Github)
+	client.VerifyWasCalledOnce().UpdateStatus(context.Background(), repoModel, pullModel, vcs.Failed, "Plan Failed", vcs.Github)
 }
 
 func TestUpdateProjectResult_Failure(t *testing.T) {
 	RegisterMockTestingT(t)
 	ctx := &events.CommandContext{
+		Context:  context.Background(),
 		BaseRepo: repoModel,
 		Pull:     pullModel,
 		Command:  &events.Command{Name: events.Plan},
@@ -67,7 +81,7 @@ func TestUpdateProjectResult_Failure(t *testing.T) {
 	s := events.DefaultCommitStatusUpdater{Client: client}
 	err := s.UpdateProjectResult(ctx, events.CommandResponse{Failure: "failure"})
 	Ok(t, err)
-	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, vcs.Failed, "Plan Failed", vcs.Github)
+	client.VerifyWasCalledOnce().UpdateStatus(context.Background(), repoModel, pullModel, vcs.Failed, "Plan Failed", vcs.Github)
 }
 
 func TestUpdateProjectResult(t *testing.T) {
@@ -75,6 +89,7 @@ func TestUpdateProjectResult(t *testing.T) {
 	RegisterMockTestingT(t)
 
 	ctx := &events.CommandContext{
+		Context:  context.Background(),
 		BaseRepo: repoModel,
 		Pull:     pullModel,
 		Command:  &events.Command{Name: events.Plan},
@@ -135,6 +150,46 @@ func TestUpdateProjectResult(t *testing.T) {
 		s := events.DefaultCommitStatusUpdater{Client: client}
 		err := s.UpdateProjectResult(ctx, resp)
 		Ok(t, err)
-		client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, c.Expected, "Plan "+strings.Title(c.Expected.String()), vcs.Github)
+		client.VerifyWasCalledOnce().UpdateStatus(matchers.AnyContextContext(), matchers.EqModelsRepo(repoModel), matchers.EqModelsPullRequest(pullModel), matchers.EqVcsCommitStatus(c.Expected), AnyString(), matchers.EqVcsHost(vcs.Github))
+	}
+}
+
+func TestUpdateProjectResult_Description(t *testing.T) {
+	t.Log("with more than one project the description should say how many and which failed")
+	RegisterMockTestingT(t)
+	ctx := &events.CommandContext{
+		Context:  context.Background(),
+		BaseRepo: repoModel,
+		Pull:     pullModel,
+		Command:  &events.Command{Name: events.Plan},
+		VCSHost:  vcs.Github,
+	}
+	long := strings.Repeat("a", 150)
+	cases := []struct {
+		results  []events.ProjectResult
+		expected string
+	}{
+		{
+			[]events.ProjectResult{{Path: "path1"}},
+			"Plan Success",
+		},
+		{
+			[]events.ProjectResult{{Path: "path1"}, {Path: "path2"}},
+			"Plan Succeeded in 2 projects",
+		},
+		{
+			[]events.ProjectResult{{Path: "path1", Failure: "failure"}, {Path: "path2"}, {Path: "path3", Error: errors.New("err")}},
+			"Plan Failed in 2 of 3 projects: path1, path3",
+		},
+		{
+			[]events.ProjectResult{{Path: long, Failure: "failure"}, {Path: "path2"}},
+			"Plan Failed in 1 of 2 projects: " + long[:137-len("Plan Failed in 1 of 2 projects: ")] + "...",
+		},
+	}
+	for _, c := range cases {
+		client := mocks.NewMockClientProxy()
+		s := events.DefaultCommitStatusUpdater{Client: client}
+		Ok(t, s.UpdateProjectResult(ctx, events.CommandResponse{ProjectResults: c.results}))
+		client.VerifyWasCalledOnce().UpdateStatus(matchers.AnyContextContext(), matchers.EqModelsRepo(repoModel), matchers.EqModelsPullRequest(pullModel), matchers.AnyVcsCommitStatus(), EqString(c.expected), matchers.EqVcsHost(vcs.Github))
 	}
 }
@@ -0,0 +1,159 @@
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/logging"
+	"github.com/pkg/errors"
+)
+
+// localSourceRegex matches the source of a module that's in the same repo,
+// ex. source = "../modules/vpc".
+var localSourceRegex = regexp.MustCompile(`(?m)^\s*source\s*=\s*"(\.\.?/[^"]*)"`)
+
+// DependentProjectFinder finds the projects that use a module that was
+// modified so they can be planned even though none of their own files
+// changed. A project's modules are the local modules in its source
+// references and the directories listed under dependencies in its config
+// file.
+type DependentProjectFinder struct {
+	ConfigReader ProjectConfigReader
+}
+
+// DependentProject is a project that's planned because a module it uses was
+// modified.
+type DependentProject struct {
+	Project models.Project
+	// Module is the path of the modified module, relative to the repo root.
+	Module string
+}
+
+// FindDependents returns the projects in repoDir that use a module with one
+// of modifiedFiles in it, directly or through another module. projects are
+// the projects that were already modified and aren't returned again.
+func (d *DependentProjectFinder) FindDependents(log *logging.SimpleLogger, repoDir string, modifiedFiles []string, projects []models.Project, repoFullName string) ([]DependentProject, error) {
+	planned := make(map[string]bool)
+	for _, project := range projects {
+		planned[project.Path] = true
+	}
+
+	// dependencies maps each directory with terraform files in it to the
+	// modules it uses, all relative to repoDir.
+	dependencies := make(map[string][]string)
+	var candidates []string
+	finder := &ProjectFinder{}
+	err := filepath.Walk(repoDir, func(absPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		dir, _ := filepath.Rel(repoDir, absPath)
+		modules, isTerraform, err := d.localModules(repoDir, dir)
+		if err != nil {
+			return err
+		}
+		if !isTerraform {
+			return nil
+		}
+		dependencies[dir] = modules
+		if finder.isInExcludeList(dir) {
+			return nil
+		}
+		// Only projects, not modules, can declare their dependencies.
+		if d.ConfigReader.Exists(absPath) {
+			config, err := d.ConfigReader.Read(absPath)
+			if err != nil {
+				return err
+			}
+			for _, dep := range config.Dependencies {
+				dependencies[dir] = append(dependencies[dir], filepath.Join(dir, dep))
+			}
+		}
+		candidates = append(candidates, dir)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "finding projects that depend on modified modules")
+	}
+
+	var dependents []DependentProject
+	for _, dir := range candidates {
+		if planned[models.NewProject(repoFullName, dir).Path] {
+			continue
+		}
+		for _, module := range dependencies[dir] {
+			if modified := modifiedModule(module, dependencies, modifiedFiles, make(map[string]bool)); modified != "" {
+				dependents = append(dependents, DependentProject{
+					Project: models.NewProject(repoFullName, dir),
+					Module:  modified,
+				})
+				break
+			}
+		}
+	}
+	if len(dependents) > 0 {
+		var paths []string
+		for _, dependent := range dependents {
+			paths = append(paths, dependent.Project.Path)
+		}
+		log.Info("found %d project(s) that use a modified module at path(s): %v", len(dependents), strings.Join(paths, ", "))
+	}
+	return dependents, nil
+}
+
+// localModules returns the local modules that the terraform files directly
+// in dir use and whether there are any terraform files in dir.
+func (d *DependentProjectFinder) localModules(repoDir string, dir string) ([]string, bool, error) {
+	files, err := filepath.Glob(filepath.Join(repoDir, dir, "*.tf"))
+	if err != nil || len(files) == 0 {
+		return nil, false, err
+	}
+	var modules []string
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, match := range localSourceRegex.FindAllStringSubmatch(string(contents), -1) {
+			module := filepath.Join(dir, match[1])
+			// We can't plan for modules outside of the repo.
+			if module == ".." || strings.HasPrefix(module, "../") {
+				continue
+			}
+			modules = append(modules, module)
+		}
+	}
+	return modules, true, nil
+}
+
+// modifiedModule returns the path of the module that was modified if module
+// or one of the modules it uses has one of modifiedFiles in it. It returns an
+// empty string otherwise. seen stops us looping on modules that use each
+// other.
+func modifiedModule(module string, dependencies map[string][]string, modifiedFiles []string, seen map[string]bool) string {
+	if seen[module] {
+		return ""
+	}
+	seen[module] = true
+	for _, file := range modifiedFiles {
+		if strings.HasPrefix(file, module+"/") {
+			return module
+		}
+	}
+	for _, dep := range dependencies[module] {
+		if modified := modifiedModule(dep, dependencies, modifiedFiles, seen); modified != "" {
+			return modified
+		}
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+// dependentsRepo is a repo where prod uses the vpc module, which uses the
+// subnets module, staging declares that it uses the dns module and legacy
+// doesn't use any of them.
+var dependentsRepo = map[string]string{
+	"prod/main.tf":            "module \"vpc\" {\n  source = \"../modules/vpc\"\n}\n",
+	"staging/main.tf":         "module \"dns\" {\n  source = \"git::https://example.com/infra.git//modules/dns\"\n}\n",
+	"staging/atlantis.yaml":   "dependencies:\n- ../modules/dns\n",
+	"legacy/main.tf":          "resource \"null_resource\" \"a\" {}\n",
+	"modules/vpc/main.tf":     "module \"subnets\" {\n  source = \"../subnets\"\n}\n",
+	"modules/subnets/main.tf": "resource \"null_resource\" \"b\" {}\n",
+	"modules/dns/main.tf":     "resource \"null_resource\" \"c\" {}\n",
+}
+
+func TestFindDependents(t *testing.T) {
+	t.Log("projects that use a modified module, directly, through another module or by declaring it, should be found")
+	repoDir := writeRepo(t, dependentsRepo)
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+	finder := events.DependentProjectFinder{ConfigReader: &events.ProjectConfigManager{}}
+
+	cases := []struct {
+		description   string
+		modifiedFiles []string
+		projects      []models.Project
+		exp           []events.DependentProject
+	}{
+		{
+			"no modules modified",
+			[]string{"legacy/main.tf"},
+			[]models.Project{models.NewProject("owner/repo", "legacy")},
+			nil,
+		},
+		{
+			"module used directly",
+			[]string{"modules/vpc/main.tf"},
+			nil,
+			[]events.DependentProject{{Project: models.NewProject("owner/repo", "prod"), Module: "modules/vpc"}},
+		},
+		{
+			"module used through another module",
+			[]string{"modules/subnets/main.tf"},
+			nil,
+			[]events.DependentProject{{Project: models.NewProject("owner/repo", "prod"), Module: "modules/subnets"}},
+		},
+		{
+			"declared dependency",
+			[]string{"modules/dns/main.tf"},
+			nil,
+			[]events.DependentProject{{Project: models.NewProject("owner/repo", "staging"), Module: "modules/dns"}},
+		},
+		{
+			"project already modified",
+			[]string{"prod/main.tf", "modules/vpc/main.tf"},
+			[]models.Project{models.NewProject("owner/repo", "prod")},
+			nil,
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.description)
+		dependents, err := finder.FindDependents(logging.NewNoopLogger(), repoDir, c.modifiedFiles, c.projects, "owner/repo")
+		Ok(t, err)
+		Equals(t, c.exp, dependents)
+	}
+}
+
+// writeRepo writes files, which maps paths to their contents, to a new
+// directory and returns it.
+func writeRepo(t *testing.T, files map[string]string) string {
+	repoDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	for path, contents := range files {
+		Ok(t, os.MkdirAll(filepath.Join(repoDir, filepath.Dir(path)), 0755))
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, path), []byte(contents), 0644))
+	}
+	return repoDir
+}
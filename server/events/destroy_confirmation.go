@@ -0,0 +1,42 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hootsuite/atlantis/server/events/models"
+)
+
+// destroyConfirmationFailure returns why plan can't be applied in env without
+// --destroy-ok, or "" if it doesn't destroy anything. The resources are
+// listed from summary, which was parsed from showOutput, if it could be read.
+// The ones whose type warning ignores, if it's set, don't have to be
+// confirmed. Otherwise the change counts saved when it was planned are used,
+// which don't say which types are destroyed. If neither is known, the plan
+// has to be confirmed too since it might destroy resources.
+func destroyConfirmationFailure(env string, plan models.Plan, summary *PlanSummary, showOutput string, warning *DestroyWarning) string {
+	confirm := fmt.Sprintf("Destroying resources in the %s environment has to be confirmed by running `atlantis apply %s --destroy-ok`.", env, env)
+	if summary != nil {
+		destroyed := summary.Destroyed
+		if warning != nil {
+			var err error
+			if destroyed, err = warning.Destroyed(showOutput); err != nil {
+				// summary was parsed from showOutput so this can't happen
+				// but if it does, every resource has to be confirmed.
+				destroyed = summary.Destroyed
+			}
+		}
+		if len(destroyed) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("This plan destroys %d resource(s): `%s`. %s", len(destroyed), strings.Join(destroyed, "`, `"), confirm)
+	}
+	changes := loadPlanChanges(plan)
+	if changes == nil {
+		return "Couldn't check whether this plan destroys resources. " + confirm
+	}
+	if changes.Destroy == 0 {
+		return ""
+	}
+	return fmt.Sprintf("This plan destroys %d resource(s). %s", changes.Destroy, confirm)
+}
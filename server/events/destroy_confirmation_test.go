@@ -0,0 +1,113 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+const updatePlanJSON = `{"resource_changes": [{"address": "aws_iam_role.app", "type": "aws_iam_role", "change": {"actions": ["update"]}}]}`
+
+func TestApplyExecutor_DestroyConfirmation(t *testing.T) {
+	t.Log("plans that destroy resources in a strict environment should only be applied with --destroy-ok")
+	RegisterMockTestingT(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path) // nolint: errcheck
+	_, binDir := fakeTerraformDir(t, tmp)
+	strict := events.ApplyPolicy{DestroyConfirmationEnvs: []string{"production"}}
+
+	a := fakeTerraformExecutor(t, binDir, "0.12.0", destroyPlanJSON)
+	a.SetPolicy(strict)
+	r := a.Execute(&applyCtx)
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "This plan destroys 4 resource(s): `aws_db_instance.db`, `aws_s3_bucket.logs`, `null_resource.trigger`, `random_id.suffix`."+
+		" Destroying resources in the production environment has to be confirmed by running `atlantis apply production --destroy-ok`.", r.ProjectResults[0].Failure)
+	Equals(t, "", r.ProjectResults[0].ApplySuccess)
+
+	t.Log("with --destroy-ok it should be applied")
+	confirmed := applyCtx
+	confirmed.Command = &events.Command{Name: events.Apply, Environment: "production", DestroyOK: true}
+	r = a.Execute(&confirmed)
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "applied\n", r.ProjectResults[0].ApplySuccess)
+
+	t.Log("in a lenient environment it should be applied")
+	a.SetPolicy(events.ApplyPolicy{DestroyConfirmationEnvs: []string{"staging"}})
+	r = a.Execute(&applyCtx)
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "applied\n", r.ProjectResults[0].ApplySuccess)
+
+	t.Log("a plan that doesn't destroy anything should be applied")
+	a = fakeTerraformExecutor(t, binDir, "0.12.0", updatePlanJSON)
+	a.SetPolicy(strict)
+	r = a.Execute(&applyCtx)
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "applied\n", r.ProjectResults[0].ApplySuccess)
+}
+
+func TestApplyExecutor_DestroyConfirmationIgnoredTypes(t *testing.T) {
+	t.Log("resource types that the destroy warning ignores shouldn't have to be confirmed")
+	RegisterMockTestingT(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path) // nolint: errcheck
+	_, binDir := fakeTerraformDir(t, tmp)
+	ignoredPlanJSON := `{"resource_changes": [{"address": "null_resource.trigger", "type": "null_resource", "change": {"actions": ["delete"]}}, {"address": "random_id.suffix", "type": "random_id", "change": {"actions": ["delete", "create"]}}]}`
+
+	a := fakeTerraformExecutor(t, binDir, "0.12.0", ignoredPlanJSON)
+	a.DestroyWarning, err = events.NewDestroyWarning([]string{"null_resource", "random_*"})
+	Ok(t, err)
+	a.SetPolicy(events.ApplyPolicy{DestroyConfirmationEnvs: []string{"production"}})
+	r := a.Execute(&applyCtx)
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "", r.ProjectResults[0].Failure)
+	Equals(t, "applied\n", r.ProjectResults[0].ApplySuccess)
+
+	t.Log("the resources that aren't ignored should still have to be confirmed")
+	a = fakeTerraformExecutor(t, binDir, "0.12.0", destroyPlanJSON)
+	a.DestroyWarning, err = events.NewDestroyWarning([]string{"null_resource", "random_*"})
+	Ok(t, err)
+	a.SetPolicy(events.ApplyPolicy{DestroyConfirmationEnvs: []string{"production"}})
+	r = a.Execute(&applyCtx)
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "This plan destroys 2 resource(s): `aws_db_instance.db`, `aws_s3_bucket.logs`."+
+		" Destroying resources in the production environment has to be confirmed by running `atlantis apply production --destroy-ok`.", r.ProjectResults[0].Failure)
+}
+
+func TestApplyExecutor_DestroyConfirmationWithoutJSON(t *testing.T) {
+	t.Log("without plan json the destroy count saved when planning should be used")
+	RegisterMockTestingT(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path) // nolint: errcheck
+	planFile, binDir := fakeTerraformDir(t, tmp)
+	a := fakeTerraformExecutor(t, binDir, "0.11.0", "")
+	a.SetPolicy(events.ApplyPolicy{DestroyConfirmationEnvs: []string{"production"}})
+
+	Ok(t, ioutil.WriteFile(planFile+".changes", []byte(`{"add":1,"change":0,"destroy":2}`), 0600))
+	r := a.Execute(&applyCtx)
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "This plan destroys 2 resource(s). Destroying resources in the production environment has to be confirmed by running `atlantis apply production --destroy-ok`.", r.ProjectResults[0].Failure)
+
+	Ok(t, ioutil.WriteFile(planFile+".changes", []byte(`{"add":1,"change":0,"destroy":0}`), 0600))
+	r = a.Execute(&applyCtx)
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "applied\n", r.ProjectResults[0].ApplySuccess)
+
+	t.Log("if the destroy count wasn't saved either the apply should have to be confirmed")
+	Ok(t, os.Remove(planFile+".changes"))
+	r = a.Execute(&applyCtx)
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "Couldn't check whether this plan destroys resources. Destroying resources in the production environment has to be confirmed by running `atlantis apply production --destroy-ok`.", r.ProjectResults[0].Failure)
+}
@@ -0,0 +1,75 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// DestroyWarning finds the resources that a plan destroys so the plan comment
+// can warn about them. Resources that are destroyed when they're replaced
+// count too since their state is lost.
+type DestroyWarning struct {
+	// IgnoreTypes are the resource types whose destruction isn't warned
+	// about, ex. "null_resource". They can be glob patterns, ex. "random_*",
+	// for resources that are recreated all the time and hold no state.
+	IgnoreTypes []string
+}
+
+// NewDestroyWarning returns a DestroyWarning that ignores ignoreTypes.
+func NewDestroyWarning(ignoreTypes []string) (*DestroyWarning, error) {
+	for _, t := range ignoreTypes {
+		if _, err := path.Match(t, ""); err != nil {
+			return nil, fmt.Errorf("invalid resource type pattern %q: %s", t, err)
+		}
+	}
+	return &DestroyWarning{IgnoreTypes: ignoreTypes}, nil
+}
+
+// planJSON is the part of the output of terraform show -json for a plan file
+// that we use.
+type planJSON struct {
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Type    string `json:"type"`
+		Change  struct {
+			Actions []string `json:"actions"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// Destroyed returns the sorted addresses of the resources that the plan in
+// showOutput, the output of terraform show -json, destroys, except the ones
+// whose type is ignored.
+func (d *DestroyWarning) Destroyed(showOutput string) ([]string, error) {
+	var plan planJSON
+	if err := json.Unmarshal([]byte(showOutput), &plan); err != nil {
+		return nil, errors.Wrap(err, "parsing plan json")
+	}
+	var destroyed []string
+	for _, rc := range plan.ResourceChanges {
+		if d.isIgnored(rc.Type) {
+			continue
+		}
+		for _, action := range rc.Change.Actions {
+			if action == "delete" {
+				destroyed = append(destroyed, rc.Address)
+				break
+			}
+		}
+	}
+	sort.Strings(destroyed)
+	return destroyed, nil
+}
+
+func (d *DestroyWarning) isIgnored(resourceType string) bool {
+	for _, t := range d.IgnoreTypes {
+		if matched, _ := path.Match(t, resourceType); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+var destroyPlanJSON = `{
+  "resource_changes": [
+    {"address": "aws_s3_bucket.logs", "type": "aws_s3_bucket", "change": {"actions": ["delete"]}},
+    {"address": "aws_db_instance.db", "type": "aws_db_instance", "change": {"actions": ["create", "delete"]}},
+    {"address": "aws_iam_role.app", "type": "aws_iam_role", "change": {"actions": ["update"]}},
+    {"address": "null_resource.trigger", "type": "null_resource", "change": {"actions": ["delete", "create"]}},
+    {"address": "random_id.suffix", "type": "random_id", "change": {"actions": ["delete"]}}
+  ]
+}`
+
+func TestDestroyWarning_Destroyed(t *testing.T) {
+	t.Log("destroyed and replaced resources should be returned sorted")
+	d, err := events.NewDestroyWarning(nil)
+	Ok(t, err)
+	destroyed, err := d.Destroyed(destroyPlanJSON)
+	Ok(t, err)
+	Equals(t, []string{"aws_db_instance.db", "aws_s3_bucket.logs", "null_resource.trigger", "random_id.suffix"}, destroyed)
+}
+
+func TestDestroyWarning_IgnoreTypes(t *testing.T) {
+	t.Log("resources whose type is ignored shouldn't be returned")
+	d, err := events.NewDestroyWarning([]string{"null_resource", "random_*"})
+	Ok(t, err)
+	destroyed, err := d.Destroyed(destroyPlanJSON)
+	Ok(t, err)
+	Equals(t, []string{"aws_db_instance.db", "aws_s3_bucket.logs"}, destroyed)
+}
+
+func TestDestroyWarning_InvalidJSON(t *testing.T) {
+	t.Log("output that isn't plan json should be an error")
+	d, err := events.NewDestroyWarning(nil)
+	Ok(t, err)
+	_, err = d.Destroyed("Error: no plan file")
+	Assert(t, err != nil, "exp an error")
+}
+
+func TestNewDestroyWarning_InvalidPattern(t *testing.T) {
+	t.Log("an invalid resource type pattern should be an error")
+	_, err := events.NewDestroyWarning([]string{"random_["})
+	Equals(t, `invalid resource type pattern "random_[": syntax error in pattern`, err.Error())
+}
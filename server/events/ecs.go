@@ -1,15 +1,34 @@
 package events
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/hootsuite/atlantis/server/logging"
+	"github.com/pkg/errors"
 )
 
+// ecsMetadataHost is where the ECS agent serves the task role's credentials.
+// It's set by tests to a fake metadata endpoint.
+var ecsMetadataHost = "http://169.254.170.2"
+
+// ecsRefreshBefore is how long before the task role's credentials expire that
+// they're fetched again.
+const ecsRefreshBefore = 5 * time.Minute
+
+// ecsRetryInterval is how long we wait before trying again if fetching the
+// credentials again fails.
+const ecsRetryInterval = 30 * time.Second
+
 type EcsCredentials struct {
 	AccessKeyId     string
 	Expiration      string
@@ -18,48 +37,306 @@ type EcsCredentials struct {
 	Token           string
 }
 
-func handleEcsCredentials(relative_uri string) error {
+// handleEcsCredentials fetches the credentials of the task's role and writes
+// them to file for terraform to use. If assumeRoleArn is set, Atlantis
+// assumes that role with them and writes its credentials instead. If roleArn
+// is set, terraform assumes that role using the written credentials instead
+// of using them directly.
+func handleEcsCredentials(relative_uri string, assumeRoleArn string, roleArn string, file awsCredentialsFile) (*EcsCredentials, error) {
+	if err := validateEcsRelativeURI(relative_uri); err != nil {
+		return nil, err
+	}
 	httpClient := &http.Client{Timeout: 5 * time.Second}
-	url := fmt.Sprintf("http://169.254.170.2%s", relative_uri)
+	url := fmt.Sprintf("%s%s", ecsMetadataHost, relative_uri)
 	r, err := httpClient.Get(url)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ECS metadata endpoint responded with status %d for %s", r.StatusCode, relative_uri)
+	}
 
 	credentials := &EcsCredentials{}
 	err = json.NewDecoder(r.Body).Decode(credentials)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if assumeRoleArn != "" {
+		credentials, err = assumeRole(credentials, assumeRoleArn)
+		if err != nil {
+			return nil, errors.Wrapf(err, "assuming %s", assumeRoleArn)
+		}
 	}
 
-	err = writeAwsCredentials(credentials)
+	err = file.write(credentials, roleArn)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	return credentials, nil
+}
+
+// validateEcsRelativeURI returns an error if uri, which is from
+// AWS_CONTAINER_CREDENTIALS_RELATIVE_URI, can't be a path on the metadata
+// endpoint.
+func validateEcsRelativeURI(uri string) error {
+	if uri == "" {
+		return errors.New("invalid ECS credentials URI: AWS_CONTAINER_CREDENTIALS_RELATIVE_URI is empty")
+	}
+	if !strings.HasPrefix(uri, "/") {
+		return fmt.Errorf("invalid ECS credentials URI %q: AWS_CONTAINER_CREDENTIALS_RELATIVE_URI must start with /", uri)
+	}
 	return nil
 }
 
-func writeAwsCredentials(credentials *EcsCredentials) error {
-	template := []string{
-		"[default]",
+// refreshEcsCredentials fetches and writes the credentials again shortly
+// before they expire, until ctx is done, so commands that run for longer than
+// the credentials last don't fail. Assumed roles are assumed again then too.
+// It's run in its own goroutine so log must be safe to use from multiple
+// goroutines.
+func refreshEcsCredentials(ctx context.Context, log logging.SimpleLogging, relativeURI string, assumeRoleArn string, roleArn string, file awsCredentialsFile, credentials *EcsCredentials) {
+	next, err := ecsRefreshTime(credentials)
+	if err != nil {
+		log.Warn("not refreshing ECS credentials: %s", err)
+		return
+	}
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		refreshed, err := handleEcsCredentials(relativeURI, assumeRoleArn, roleArn, file)
+		if err != nil {
+			log.Warn("failed to refresh ECS credentials, retrying in %s: %s", ecsRetryInterval, err)
+			next = time.Now().Add(ecsRetryInterval)
+			continue
+		}
+		log.Debug("refreshed ECS credentials, they now expire at %s", refreshed.Expiration)
+		if next, err = ecsRefreshTime(refreshed); err != nil {
+			log.Warn("not refreshing ECS credentials: %s", err)
+			return
+		}
+	}
+}
+
+// ecsRefreshTime returns when credentials should be fetched again.
+func ecsRefreshTime(credentials *EcsCredentials) (time.Time, error) {
+	expiration, err := time.Parse(time.RFC3339, credentials.Expiration)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiration %q", credentials.Expiration)
+	}
+	return expiration.Add(-ecsRefreshBefore), nil
+}
+
+// awsCredentialsFile is where the task role's credentials are written for
+// terraform.
+type awsCredentialsFile struct {
+	// Path is the credentials file. The config file is written next to it.
+	Path string
+	// Profile is the profile that terraform uses. Each command has its own
+	// so commands that run at the same time never overwrite each other's
+	// credentials or roles.
+	Profile string
+}
+
+// awsProfileCount numbers the profiles of the commands so each is unique.
+var awsProfileCount uint64
+
+// newAwsCredentialsFile returns the file at path with a new profile for a
+// run of command that starts with prefix, ex. "atlantis-plan-3". Plans and
+// applies never share a profile so a plan can't run with the apply role.
+func newAwsCredentialsFile(path string, prefix string, command CommandName) awsCredentialsFile {
+	n := atomic.AddUint64(&awsProfileCount, 1)
+	return awsCredentialsFile{Path: path, Profile: fmt.Sprintf("%s-%s-%d", prefix, command, n)}
+}
+
+// awsFilesMutex stops commands in different environments from overwriting
+// each other's changes to the files.
+var awsFilesMutex sync.Mutex
+
+// write writes credentials to the file's profile. If roleArn is set, they're
+// written to the source profile instead and the profile assumes roleArn with
+// them. Other profiles in the files are kept.
+func (f awsCredentialsFile) write(credentials *EcsCredentials, roleArn string) error {
+	awsFilesMutex.Lock()
+	defer awsFilesMutex.Unlock()
+	profile := f.Profile
+	if roleArn != "" {
+		profile = f.sourceProfile()
+	}
+	section := []string{
 		fmt.Sprintf("aws_access_key_id=%s", credentials.AccessKeyId),
 		fmt.Sprintf("aws_secret_access_key=%s", credentials.SecretAccessKey),
 		fmt.Sprintf("aws_session_token=%s", credentials.Token),
 	}
 
-	templateRendered := strings.Join(template, "\n")
+	err := os.MkdirAll(filepath.Dir(f.Path), os.FileMode(0700))
+	if err != nil {
+		return errors.Wrapf(err, "creating %s", filepath.Dir(f.Path))
+	}
+
+	werr := setIniSection(f.Path, profile, section)
+	if werr != nil {
+		return errors.Wrapf(werr, "writing AWS credentials to %s", f.Path)
+	}
+
+	if roleArn == "" {
+		return nil
+	}
+	config := []string{
+		fmt.Sprintf("role_arn=%s", roleArn),
+		fmt.Sprintf("source_profile=%s", f.sourceProfile()),
+		"role_session_name=atlantis",
+	}
+	configPath := f.configPath()
+	return errors.Wrapf(setIniSection(configPath, f.configSection(), config), "writing AWS config to %s", configPath)
+}
+
+// remove removes the profiles that write writes, and the files if nothing
+// else is left in them.
+func (f awsCredentialsFile) remove(roleArn string) error {
+	awsFilesMutex.Lock()
+	defer awsFilesMutex.Unlock()
+	if roleArn == "" {
+		return removeIniSection(f.Path, f.Profile)
+	}
+	if err := removeIniSection(f.Path, f.sourceProfile()); err != nil {
+		return err
+	}
+	return removeIniSection(f.configPath(), f.configSection())
+}
+
+// sourceProfile returns the profile that the credentials are written to
+// when the profile assumes a role with them.
+func (f awsCredentialsFile) sourceProfile() string {
+	return f.Profile + "-source"
+}
 
-	err := os.MkdirAll("/home/atlantis/.aws", os.FileMode(0700))
+// configPath returns the path of the AWS config file that goes with the
+// credentials file.
+func (f awsCredentialsFile) configPath() string {
+	return filepath.Join(filepath.Dir(f.Path), "config")
+}
+
+// configSection returns the name of the profile's section in the config
+// file, which unlike the credentials file prefixes profiles other than the
+// default one with "profile ".
+func (f awsCredentialsFile) configSection() string {
+	if f.Profile == "default" {
+		return f.Profile
+	}
+	return "profile " + f.Profile
+}
+
+// setIniSection sets the lines of the section called name in the ini file at
+// path, adding the section if it's not there. The file's other sections are
+// kept.
+func setIniSection(path string, name string, lines []string) error {
+	sections, err := readIniSections(path)
 	if err != nil {
 		return err
 	}
+	header := fmt.Sprintf("[%s]", name)
+	replaced := false
+	for i, section := range sections {
+		if section[0] == header {
+			sections[i] = append([]string{header}, lines...)
+			replaced = true
+		}
+	}
+	if !replaced {
+		sections = append(sections, append([]string{header}, lines...))
+	}
+	return writeIniSections(path, sections)
+}
 
-	werr := ioutil.WriteFile("/home/atlantis/.aws/credentials", []byte(templateRendered), 0644)
-	if werr != nil {
+// removeIniSection removes the section called name from the ini file at path.
+// If nothing else is left in the file, the file is removed.
+func removeIniSection(path string, name string) error {
+	sections, err := readIniSections(path)
+	if err != nil {
 		return err
 	}
+	header := fmt.Sprintf("[%s]", name)
+	var kept [][]string
+	for _, section := range sections {
+		if section[0] != header {
+			kept = append(kept, section)
+		}
+	}
+	if len(kept) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return writeIniSections(path, kept)
+}
+
+// readIniSections returns the sections of the ini file at path, each starting
+// with its header line. Lines before the first section, ex. comments, are
+// returned as a section with an empty header. A missing file has no
+// sections.
+func readIniSections(path string) ([][]string, error) {
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sections [][]string
+	for _, line := range strings.Split(strings.TrimRight(string(raw), "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			sections = append(sections, []string{trimmed})
+			continue
+		}
+		if len(sections) == 0 {
+			if trimmed == "" {
+				continue
+			}
+			sections = append(sections, []string{""})
+		}
+		sections[len(sections)-1] = append(sections[len(sections)-1], line)
+	}
+	return sections, nil
+}
 
+// writeIniSections replaces the ini file at path with sections. The file is
+// written to a temporary file next to it first and renamed into place so
+// terraform refreshing its credentials never reads a half written file.
+func writeIniSections(path string, sections [][]string) error {
+	var lines []string
+	for _, section := range sections {
+		if section[0] == "" {
+			lines = append(lines, section[1:]...)
+		} else {
+			lines = append(lines, section...)
+		}
+	}
+	// TempFile creates the file readable only by us since it has secrets.
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		tmp.Close()           // nolint: errcheck
+		os.Remove(tmp.Name()) // nolint: errcheck
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name()) // nolint: errcheck
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name()) // nolint: errcheck
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,454 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/events/vcs"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestRefreshEcsCredentials(t *testing.T) {
+	t.Log("credentials should be written again before they expire until the context is done")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	credentialsPath := filepath.Join(tmp, ".aws", "credentials")
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expiration := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		fmt.Fprintf(w, `{"AccessKeyId": "refreshed", "Expiration": %q}`, expiration) // nolint: errcheck
+	}))
+	defer metadata.Close()
+	defer func(host string) { ecsMetadataHost = host }(ecsMetadataHost)
+	ecsMetadataHost = metadata.URL
+
+	// These expire just after they're due to be refreshed.
+	expiring := &EcsCredentials{AccessKeyId: "expiring", Expiration: time.Now().Add(ecsRefreshBefore + time.Second).UTC().Format(time.RFC3339)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		refreshEcsCredentials(ctx, logging.NewNoopLogger(), "/creds", "", "", awsCredentialsFile{Path: credentialsPath, Profile: "default"}, expiring)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		written, _ := ioutil.ReadFile(credentialsPath)
+		if strings.Contains(string(written), "aws_access_key_id=refreshed") {
+			break
+		}
+		Assert(t, time.Now().Before(deadline), "exp the credentials to be refreshed")
+		time.Sleep(50 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("exp the refresh to stop once the context is done")
+	}
+}
+
+func TestEcsRefreshTime_InvalidExpiration(t *testing.T) {
+	t.Log("credentials without a valid expiration shouldn't be refreshed")
+	_, err := ecsRefreshTime(&EcsCredentials{Expiration: "tomorrow"})
+	Assert(t, err != nil, "exp an error")
+	Equals(t, `invalid expiration "tomorrow"`, err.Error())
+}
+
+func TestAwsCredentialsFile_WriteError(t *testing.T) {
+	t.Log("an error writing the credentials should be returned")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	// A directory can't be written to even as root, unlike a read-only file.
+	credentialsPath := filepath.Join(tmp, "credentials")
+	Ok(t, os.Mkdir(credentialsPath, 0700))
+
+	file := awsCredentialsFile{Path: credentialsPath, Profile: "default"}
+	err = file.write(&EcsCredentials{AccessKeyId: "key"}, "")
+	Assert(t, err != nil, "exp an error")
+	Assert(t, strings.HasPrefix(err.Error(), "writing AWS credentials to "+credentialsPath+": "), "exp the path in the error, got %q", err.Error())
+
+	t.Log("an error writing the config should be returned too")
+	Ok(t, os.Remove(credentialsPath))
+	Ok(t, os.Mkdir(filepath.Join(tmp, "config"), 0700))
+	err = file.write(&EcsCredentials{AccessKeyId: "key"}, "arn:aws:iam::123456789012:role/apply")
+	Assert(t, err != nil, "exp an error")
+	Assert(t, strings.HasPrefix(err.Error(), "writing AWS config to "+filepath.Join(tmp, "config")+": "), "exp the path in the error, got %q", err.Error())
+}
+
+func TestAwsCredentialsFile_KeepsOtherProfiles(t *testing.T) {
+	t.Log("writing and removing the credentials should keep the other profiles")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	credentialsPath := filepath.Join(tmp, "credentials")
+	existing := "# managed by hand\n[other]\naws_access_key_id=other\n\n[atlantis]\naws_access_key_id=old\n"
+	Ok(t, ioutil.WriteFile(credentialsPath, []byte(existing), 0600))
+	file := awsCredentialsFile{Path: credentialsPath, Profile: "atlantis"}
+
+	Ok(t, file.write(&EcsCredentials{AccessKeyId: "key", SecretAccessKey: "secret", Token: "token"}, ""))
+	written, err := ioutil.ReadFile(credentialsPath)
+	Ok(t, err)
+	Equals(t, "# managed by hand\n[other]\naws_access_key_id=other\n\n[atlantis]\naws_access_key_id=key\naws_secret_access_key=secret\naws_session_token=token\n", string(written))
+
+	t.Log("with a role, the profile should be in the config file")
+	Ok(t, file.write(&EcsCredentials{AccessKeyId: "key"}, "arn:aws:iam::123456789012:role/apply"))
+	config, err := ioutil.ReadFile(filepath.Join(tmp, "config"))
+	Ok(t, err)
+	Equals(t, "[profile atlantis]\nrole_arn=arn:aws:iam::123456789012:role/apply\nsource_profile=atlantis-source\nrole_session_name=atlantis\n", string(config))
+
+	Ok(t, file.remove("arn:aws:iam::123456789012:role/apply"))
+	Ok(t, file.remove(""))
+	written, err = ioutil.ReadFile(credentialsPath)
+	Ok(t, err)
+	Equals(t, "# managed by hand\n[other]\naws_access_key_id=other\n\n", string(written))
+	_, err = os.Stat(filepath.Join(tmp, "config"))
+	Assert(t, os.IsNotExist(err), "exp the empty config file to be removed")
+}
+
+func TestAwsCredentialsFile_WriteIsAtomic(t *testing.T) {
+	t.Log("reading the credentials while they're written should always see a complete file")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	credentialsPath := filepath.Join(tmp, "credentials")
+	file := awsCredentialsFile{Path: credentialsPath, Profile: "default"}
+	// A long token makes a torn write likely if the file isn't replaced
+	// atomically.
+	token := strings.Repeat("t", 1<<16)
+	exp := fmt.Sprintf("[default]\naws_access_key_id=key\naws_secret_access_key=secret\naws_session_token=%s\n", token)
+	credentials := &EcsCredentials{AccessKeyId: "key", SecretAccessKey: "secret", Token: token}
+	Ok(t, file.write(credentials, ""))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			if err := file.write(credentials, ""); err != nil {
+				t.Errorf("writing the credentials: %s", err)
+				return
+			}
+		}
+	}()
+	reads := 0
+	for finished := false; !finished; reads++ {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		written, err := ioutil.ReadFile(credentialsPath)
+		Ok(t, err)
+		Assert(t, string(written) == exp, "exp a complete file, got %d bytes", len(written))
+	}
+	t.Logf("read the credentials %d times", reads)
+
+	t.Log("the file should only be readable by us and no temporary files should be left")
+	info, err := os.Stat(credentialsPath)
+	Ok(t, err)
+	Equals(t, os.FileMode(0600), info.Mode().Perm())
+	entries, err := ioutil.ReadDir(tmp)
+	Ok(t, err)
+	Equals(t, 1, len(entries))
+}
+
+// credentialsExecutor records the access key and role in each command's
+// profile once every command has written its credentials, as terraform would
+// see them. They're keyed by the command and environment, ex. "plan staging".
+type credentialsExecutor struct {
+	credentialsPath string
+	written         *sync.WaitGroup
+	mutex           sync.Mutex
+	keys            map[string]string
+	roles           map[string]string
+	profiles        map[string]string
+}
+
+func newCredentialsExecutor(credentialsPath string, commands int) *credentialsExecutor {
+	written := &sync.WaitGroup{}
+	written.Add(commands)
+	return &credentialsExecutor{
+		credentialsPath: credentialsPath,
+		written:         written,
+		keys:            map[string]string{},
+		roles:           map[string]string{},
+		profiles:        map[string]string{},
+	}
+}
+
+func (e *credentialsExecutor) Execute(ctx *CommandContext) CommandResponse {
+	e.written.Done()
+	e.written.Wait()
+	key := iniValue(e.credentialsPath, ctx.AWSProfile, "aws_access_key_id")
+	if key == "" {
+		key = iniValue(e.credentialsPath, ctx.AWSProfile+"-source", "aws_access_key_id")
+	}
+	role := iniValue(filepath.Join(filepath.Dir(e.credentialsPath), "config"), "profile "+ctx.AWSProfile, "role_arn")
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	label := fmt.Sprintf("%s %s", ctx.Command.Name, ctx.Command.Environment)
+	e.keys[label] = key
+	e.roles[label] = role
+	e.profiles[label] = ctx.AWSProfile
+	return CommandResponse{}
+}
+
+// runConcurrently runs each command on its own pull in its own goroutine
+// and waits for them all to finish.
+func runConcurrently(c *CommandHandler, cmds ...*Command) {
+	var done sync.WaitGroup
+	for i, cmd := range cmds {
+		done.Add(1)
+		go func(pullNum int, cmd *Command) {
+			defer done.Done()
+			c.run(&CommandContext{
+				Context:  context.Background(),
+				BaseRepo: models.Repo{FullName: "owner/repo"},
+				Pull:     models.PullRequest{Num: pullNum, State: models.Open},
+				Command:  cmd,
+				VCSHost:  vcs.Github,
+			})
+		}(i+1, cmd)
+	}
+	done.Wait()
+}
+
+// iniValue returns the value of key in the section called name of the ini
+// file at path, or "" if it's not there.
+func iniValue(path string, name string, key string) string {
+	sections, _ := readIniSections(path)
+	for _, section := range sections {
+		if section[0] != "["+name+"]" {
+			continue
+		}
+		for _, line := range section[1:] {
+			if strings.HasPrefix(line, key+"=") {
+				return strings.TrimPrefix(line, key+"=")
+			}
+		}
+	}
+	return ""
+}
+
+func TestCommandHandler_ConcurrentEcsCredentials(t *testing.T) {
+	t.Log("commands running at the same time in different environments should each use their own role's credentials")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	credentialsPath := filepath.Join(tmp, "credentials")
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"AccessKeyId": "task", "SecretAccessKey": "task-secret", "Token": "task-token"}`) // nolint: errcheck
+	}))
+	defer metadata.Close()
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role := r.FormValue("RoleArn")
+		account := strings.Split(role, ":")[4]
+		fmt.Fprintf(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials><AccessKeyId>%s</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken></Credentials></AssumeRoleResult></AssumeRoleResponse>`, account) // nolint: errcheck
+	}))
+	defer sts.Close()
+	defer func(host string, endpoint string) { ecsMetadataHost, stsEndpoint = host, endpoint }(ecsMetadataHost, stsEndpoint)
+	ecsMetadataHost, stsEndpoint = metadata.URL, sts.URL+"/"
+	defer os.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI")) // nolint: errcheck
+	Ok(t, os.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "/creds"))
+
+	executor := newCredentialsExecutor(credentialsPath, 2)
+	vcsClient := vcs.NewDefaultClientProxy(nil, nil)
+	c := &CommandHandler{
+		PlanExecutor:        executor,
+		VCSClient:           vcsClient,
+		CommitStatusUpdater: &DefaultCommitStatusUpdater{Client: vcsClient},
+		EnvLocker:           NewEnvLock(),
+		MarkdownRenderer:    &MarkdownRenderer{},
+		Logger:              logging.NewNoopLogger(),
+		AWSAssumeRoles: map[string]string{
+			"staging":    "arn:aws:iam::111111111111:role/atlantis",
+			"production": "arn:aws:iam::222222222222:role/atlantis",
+		},
+		AWSCredentialsPath: credentialsPath,
+		AWSProfile:         "atlantis",
+	}
+	runConcurrently(c, &Command{Name: Plan, Environment: "staging"}, &Command{Name: Plan, Environment: "production"})
+
+	Equals(t, map[string]string{"plan staging": "111111111111", "plan production": "222222222222"}, executor.keys)
+	Assert(t, executor.profiles["plan staging"] != executor.profiles["plan production"], "exp each command to have its own profile, got %s", executor.profiles["plan staging"])
+	for _, profile := range executor.profiles {
+		Assert(t, strings.HasPrefix(profile, "atlantis-plan-"), "exp the profile to start with atlantis-plan-, got %s", profile)
+	}
+
+	t.Log("both profiles should be removed once the commands finish")
+	_, err = os.Stat(credentialsPath)
+	Assert(t, os.IsNotExist(err), "exp the credentials to be removed")
+}
+
+func TestCommandHandler_ConcurrentPlanAndApplyRoles(t *testing.T) {
+	t.Log("a plan running at the same time as an apply should never assume the apply role")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	credentialsPath := filepath.Join(tmp, "credentials")
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"AccessKeyId": "task", "SecretAccessKey": "task-secret", "Token": "task-token"}`) // nolint: errcheck
+	}))
+	defer metadata.Close()
+	defer func(host string) { ecsMetadataHost = host }(ecsMetadataHost)
+	ecsMetadataHost = metadata.URL
+	defer os.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI")) // nolint: errcheck
+	Ok(t, os.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "/creds"))
+
+	executor := newCredentialsExecutor(credentialsPath, 2)
+	vcsClient := vcs.NewDefaultClientProxy(nil, nil)
+	c := &CommandHandler{
+		PlanExecutor:        executor,
+		ApplyExecutor:       executor,
+		VCSClient:           vcsClient,
+		CommitStatusUpdater: &DefaultCommitStatusUpdater{Client: vcsClient},
+		EnvLocker:           NewEnvLock(),
+		MarkdownRenderer:    &MarkdownRenderer{},
+		Logger:              logging.NewNoopLogger(),
+		PlanRoleARN:         "arn:aws:iam::123456789012:role/plan",
+		ApplyRoleARN:        "arn:aws:iam::123456789012:role/apply",
+		AWSCredentialsPath:  credentialsPath,
+		AWSProfile:          "atlantis",
+	}
+	runConcurrently(c, &Command{Name: Plan, Environment: "default"}, &Command{Name: Apply, Environment: "default"})
+
+	Equals(t, map[string]string{"plan default": "arn:aws:iam::123456789012:role/plan", "apply default": "arn:aws:iam::123456789012:role/apply"}, executor.roles)
+	Equals(t, map[string]string{"plan default": "task", "apply default": "task"}, executor.keys)
+	Assert(t, strings.HasPrefix(executor.profiles["plan default"], "atlantis-plan-"), "exp a plan profile, got %s", executor.profiles["plan default"])
+	Assert(t, strings.HasPrefix(executor.profiles["apply default"], "atlantis-apply-"), "exp an apply profile, got %s", executor.profiles["apply default"])
+
+	t.Log("both profiles should be removed once the commands finish")
+	_, err = os.Stat(credentialsPath)
+	Assert(t, os.IsNotExist(err), "exp the credentials to be removed")
+	_, err = os.Stat(filepath.Join(tmp, "config"))
+	Assert(t, os.IsNotExist(err), "exp the config to be removed")
+}
+
+func TestHandleEcsCredentials_AssumeRole(t *testing.T) {
+	t.Log("the assumed role's credentials should be written instead of the task role's")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	credentialsPath := filepath.Join(tmp, "credentials")
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"AccessKeyId": "task", "SecretAccessKey": "task-secret", "Token": "task-token", "Expiration": "2017-09-01T06:00:00Z"}`) // nolint: errcheck
+	}))
+	defer metadata.Close()
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "AssumeRole", r.FormValue("Action"))
+		Equals(t, "arn:aws:iam::222222222222:role/production", r.FormValue("RoleArn"))
+		Equals(t, "task-token", r.Header.Get("X-Amz-Security-Token"))
+		auth := r.Header.Get("Authorization")
+		Assert(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=task/"), "exp the request to be signed with the task's credentials, got %q", auth)
+		Assert(t, strings.Contains(auth, "/eu-central-1/sts/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-security-token, Signature="), "exp the request to be signed for the region, got %q", auth)
+		fmt.Fprint(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>assumed</AccessKeyId>
+      <SecretAccessKey>assumed-secret</SecretAccessKey>
+      <SessionToken>assumed-token</SessionToken>
+      <Expiration>2017-09-01T01:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`) // nolint: errcheck
+	}))
+	defer sts.Close()
+	defer func(host string, endpoint string, region string) {
+		ecsMetadataHost, stsEndpoint = host, endpoint
+		os.Setenv("AWS_REGION", region) // nolint: errcheck
+	}(ecsMetadataHost, stsEndpoint, os.Getenv("AWS_REGION"))
+	ecsMetadataHost, stsEndpoint = metadata.URL, sts.URL+"/"
+	os.Setenv("AWS_REGION", "eu-central-1") // nolint: errcheck
+
+	file := awsCredentialsFile{Path: credentialsPath, Profile: "default"}
+	credentials, err := handleEcsCredentials("/creds", "arn:aws:iam::222222222222:role/production", "", file)
+	Ok(t, err)
+	// The assumed credentials expire first so they decide when to refresh.
+	Equals(t, "2017-09-01T01:00:00Z", credentials.Expiration)
+	written, err := ioutil.ReadFile(credentialsPath)
+	Ok(t, err)
+	Equals(t, "[default]\naws_access_key_id=assumed\naws_secret_access_key=assumed-secret\naws_session_token=assumed-token\n", string(written))
+}
+
+func TestHandleEcsCredentials_AssumeRoleDenied(t *testing.T) {
+	t.Log("STS denying the role should be an error and nothing should be written")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	credentialsPath := filepath.Join(tmp, "credentials")
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"AccessKeyId": "task", "SecretAccessKey": "task-secret", "Token": "task-token"}`) // nolint: errcheck
+	}))
+	defer metadata.Close()
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<ErrorResponse><Error><Code>AccessDenied</Code><Message>not authorized to perform sts:AssumeRole</Message></Error></ErrorResponse>`) // nolint: errcheck
+	}))
+	defer sts.Close()
+	defer func(host string, endpoint string) { ecsMetadataHost, stsEndpoint = host, endpoint }(ecsMetadataHost, stsEndpoint)
+	ecsMetadataHost, stsEndpoint = metadata.URL, sts.URL+"/"
+
+	_, err = handleEcsCredentials("/creds", "arn:aws:iam::222222222222:role/production", "", awsCredentialsFile{Path: credentialsPath, Profile: "default"})
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "assuming arn:aws:iam::222222222222:role/production: STS responded with status 403: AccessDenied: not authorized to perform sts:AssumeRole", err.Error())
+	_, err = os.Stat(credentialsPath)
+	Assert(t, os.IsNotExist(err), "exp no credentials to be written")
+}
+
+func TestHandleEcsCredentials_InvalidRelativeURI(t *testing.T) {
+	t.Log("a relative URI that isn't a path should be an error before the metadata endpoint is called")
+	called := false
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer metadata.Close()
+	defer func(host string) { ecsMetadataHost = host }(ecsMetadataHost)
+	ecsMetadataHost = metadata.URL
+	file := awsCredentialsFile{Path: filepath.Join(os.TempDir(), "credentials"), Profile: "default"}
+
+	for uri, exp := range map[string]string{
+		"":                 "invalid ECS credentials URI: AWS_CONTAINER_CREDENTIALS_RELATIVE_URI is empty",
+		"v2/credentials/1": `invalid ECS credentials URI "v2/credentials/1": AWS_CONTAINER_CREDENTIALS_RELATIVE_URI must start with /`,
+		".evil.com/creds":  `invalid ECS credentials URI ".evil.com/creds": AWS_CONTAINER_CREDENTIALS_RELATIVE_URI must start with /`,
+	} {
+		_, err := handleEcsCredentials(uri, "", "", file)
+		Assert(t, err != nil, "exp an error for %q", uri)
+		Equals(t, exp, err.Error())
+	}
+	Assert(t, !called, "exp the metadata endpoint not to be called")
+}
+
+func TestHandleEcsCredentials_MetadataError(t *testing.T) {
+	t.Log("the metadata endpoint responding with an error should be an error and nothing should be written")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	credentialsPath := filepath.Join(tmp, "credentials")
+	var requested string
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		http.NotFound(w, r)
+	}))
+	defer metadata.Close()
+	defer func(host string) { ecsMetadataHost = host }(ecsMetadataHost)
+	ecsMetadataHost = metadata.URL
+
+	_, err = handleEcsCredentials("/v2/credentials/unknown", "", "", awsCredentialsFile{Path: credentialsPath, Profile: "default"})
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "ECS metadata endpoint responded with status 404 for /v2/credentials/unknown", err.Error())
+	Equals(t, "/v2/credentials/unknown", requested)
+	_, err = os.Stat(credentialsPath)
+	Assert(t, os.IsNotExist(err), "exp no credentials to be written")
+}
@@ -0,0 +1,56 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnvApproval is which approvals applies to an environment require instead
+// of ApplyPolicy's RequireApproval and RequireExternalApproval.
+type EnvApproval struct {
+	// Internal is true if the pull request has to be approved on the VCS
+	// host, like RequireApproval.
+	Internal bool
+	// External is true if the approval services have to approve the pull
+	// request, like RequireExternalApproval.
+	External bool
+}
+
+// ParseEnvApproval parses an environment's approvals, a comma separated list
+// of internal and external, ex. "internal,external", or none if applies to
+// the environment don't need to be approved.
+func ParseEnvApproval(spec string) (EnvApproval, error) {
+	var a EnvApproval
+	if strings.TrimSpace(spec) == "none" {
+		return a, nil
+	}
+	for _, approval := range strings.Split(spec, ",") {
+		switch strings.TrimSpace(approval) {
+		case "internal":
+			a.Internal = true
+		case "external":
+			a.External = true
+		default:
+			return EnvApproval{}, fmt.Errorf("%q is not none or a list of internal and external", spec)
+		}
+	}
+	return a, nil
+}
+
+// requiresApproval returns true if applies to env need the pull request to
+// be approved on the VCS host.
+func (p ApplyPolicy) requiresApproval(env string) bool {
+	if a, ok := p.EnvApprovals[env]; ok {
+		return a.Internal
+	}
+	return p.RequireApproval
+}
+
+// requiresExternalApproval returns true if applies to env need the approval
+// services to approve the pull request.
+func (p ApplyPolicy) requiresExternalApproval(env string) bool {
+	if a, ok := p.EnvApprovals[env]; ok {
+		return a.External
+	}
+	return p.RequireExternalApproval
+}
@@ -0,0 +1,76 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/mocks"
+	vcsmocks "github.com/hootsuite/atlantis/server/events/vcs/mocks"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+func TestParseEnvApproval(t *testing.T) {
+	t.Log("environment approvals should be none or a list of internal and external")
+	cases := []struct {
+		Spec   string
+		Exp    events.EnvApproval
+		ExpErr string
+	}{
+		{"none", events.EnvApproval{}, ""},
+		{"internal", events.EnvApproval{Internal: true}, ""},
+		{"external", events.EnvApproval{External: true}, ""},
+		{"internal, external", events.EnvApproval{Internal: true, External: true}, ""},
+		{"", events.EnvApproval{}, `"" is not none or a list of internal and external`},
+		{"none,internal", events.EnvApproval{}, `"none,internal" is not none or a list of internal and external`},
+	}
+	for _, c := range cases {
+		t.Log(c.Spec)
+		approval, err := events.ParseEnvApproval(c.Spec)
+		if c.ExpErr != "" {
+			Assert(t, err != nil, "exp an error")
+			Equals(t, c.ExpErr, err.Error())
+			continue
+		}
+		Ok(t, err)
+		Equals(t, c.Exp, approval)
+	}
+}
+
+func TestApplyExecutor_EnvApprovals(t *testing.T) {
+	t.Log("only environments that require approval should need the pull request to be approved")
+	RegisterMockTestingT(t)
+	cases := []struct {
+		Description     string
+		Env             string
+		RequireApproval bool
+		ExpFailure      string
+	}{
+		{"an environment that requires approval", "production", false, "Pull request must be approved before running apply."},
+		{"an environment that doesn't", "dev", true, "No workspace found. Did you run plan?"},
+		{"an unlisted environment without global approval", "staging", false, "No workspace found. Did you run plan?"},
+		{"an unlisted environment with global approval", "staging", true, "Pull request must be approved before running apply."},
+	}
+	for _, c := range cases {
+		t.Log(c.Description)
+		vcsClient := vcsmocks.NewMockClientProxy()
+		When(vcsClient.PullIsApproved(applyCtx.Context, applyCtx.BaseRepo, applyCtx.Pull, applyCtx.VCSHost)).ThenReturn(false, nil)
+		workspace := mocks.NewMockWorkspace()
+		When(workspace.GetWorkspace(applyCtx.BaseRepo, applyCtx.Pull, c.Env)).ThenReturn("", errors.New("not found"))
+		a := &events.ApplyExecutor{VCSClient: vcsClient, Workspace: workspace}
+		a.SetPolicy(events.ApplyPolicy{
+			RequireApproval: c.RequireApproval,
+			EnvApprovals: map[string]events.EnvApproval{
+				"production": {Internal: true},
+				"dev":        {},
+			},
+		})
+
+		ctx := applyCtx
+		ctx.Command = &events.Command{Name: events.Apply, Environment: c.Env}
+		r := a.Execute(&ctx)
+		Ok(t, r.Error)
+		Equals(t, c.ExpFailure, r.Failure)
+	}
+}
@@ -0,0 +1,167 @@
+package events
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/events/vcs"
+	"github.com/pkg/errors"
+)
+
+// EnvDetector determines the projects that a plan for a pull request runs
+// in. It's picked by --environment-detection-workflow.
+type EnvDetector interface {
+	// Detect returns the projects to run in and, if the detector looked at
+	// them, the files modified in the pull request.
+	Detect(ctx *CommandContext) (projects []models.Project, modifiedFiles []string, err error)
+}
+
+// ModifiedFilesDetector runs in the projects with files modified in the pull
+// request. This is the ModifiedFilesWorkflow.
+type ModifiedFilesDetector struct {
+	VCSClient     vcs.ClientProxy
+	ProjectFinder ModifiedProjectFinder
+}
+
+// Detect returns the projects with modified files.
+func (m *ModifiedFilesDetector) Detect(ctx *CommandContext) ([]models.Project, []string, error) {
+	modifiedFiles, err := m.VCSClient.GetModifiedFiles(ctx.Context, ctx.BaseRepo, ctx.Pull, ctx.VCSHost)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "getting modified files")
+	}
+	ctx.Log.Info("found %d files modified in this pull request", len(modifiedFiles))
+	return m.ProjectFinder.FindModified(ctx.Log, modifiedFiles, ctx.BaseRepo.FullName), modifiedFiles, nil
+}
+
+// GitflowDetector runs in the directory of each environment, under EnvDir,
+// that the pull request's base branch is mapped to. This is the
+// GitFlowWorkflow.
+type GitflowDetector struct {
+	EnvDir string
+	// BranchMapping maps environments to branches. If it's empty, the base
+	// branch is the environment.
+	BranchMapping []GitflowMapping
+}
+
+// gitflowRegexPrefix marks the branch side of a mapping as a regex.
+const gitflowRegexPrefix = "re:"
+
+// GitflowMapping maps an environment to the branches that match Branch, a
+// branch name, a glob like release/* or, with the re: prefix, a regex that
+// has to match the whole branch name.
+type GitflowMapping struct {
+	Env    string
+	Branch string
+	// regex is the compiled regex if Branch is one.
+	regex *regexp.Regexp
+}
+
+// Matches returns true if branch matches the mapping's branch pattern.
+func (m GitflowMapping) Matches(branch string) bool {
+	if m.regex != nil {
+		return m.regex.MatchString(branch)
+	}
+	// The pattern was checked when it was parsed so it can't be bad.
+	ok, _ := path.Match(m.Branch, branch)
+	return ok
+}
+
+// NewGitflowDetector returns a detector for the environments in envDir with
+// branchMap, a list of env:branch entries. Space around either side is
+// ignored. Every invalid entry is reported, not just the first. An
+// environment can only be mapped once but a branch can be mapped to more than
+// one environment to plan them all.
+func NewGitflowDetector(envDir string, branchMap []string) (*GitflowDetector, error) {
+	g := &GitflowDetector{EnvDir: envDir}
+	var problems []string
+	entries := make(map[string]string)
+	for _, entry := range branchMap {
+		m, err := parseGitflowMapping(entry)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if other, ok := entries[m.Env]; ok {
+			problems = append(problems, fmt.Sprintf("environment %q is mapped by both %q and %q", m.Env, other, entry))
+			continue
+		}
+		entries[m.Env] = entry
+		g.BranchMapping = append(g.BranchMapping, m)
+	}
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	return g, nil
+}
+
+func parseGitflowMapping(entry string) (GitflowMapping, error) {
+	parts := strings.SplitN(entry, ":", 2)
+	if len(parts) != 2 {
+		return GitflowMapping{}, fmt.Errorf("%q must be env:branch", entry)
+	}
+	m := GitflowMapping{Env: strings.TrimSpace(parts[0]), Branch: strings.TrimSpace(parts[1])}
+	if m.Env == "" || m.Branch == "" {
+		return GitflowMapping{}, fmt.Errorf("%q must be env:branch", entry)
+	}
+	if strings.HasPrefix(m.Branch, gitflowRegexPrefix) {
+		regex, err := regexp.Compile("^(?:" + strings.TrimPrefix(m.Branch, gitflowRegexPrefix) + ")$")
+		if err != nil {
+			return GitflowMapping{}, fmt.Errorf("%q has an invalid regex: %s", entry, err)
+		}
+		m.regex = regex
+		return m, nil
+	}
+	// Branch names can't have colons so one is likely a mistake.
+	if strings.Contains(m.Branch, ":") {
+		return GitflowMapping{}, fmt.Errorf("%q must be env:branch", entry)
+	}
+	if _, err := path.Match(m.Branch, ""); err != nil {
+		return GitflowMapping{}, fmt.Errorf("%q has an invalid glob: %s", entry, err)
+	}
+	return m, nil
+}
+
+// Detect returns a project for each environment of the base branch.
+func (g *GitflowDetector) Detect(ctx *CommandContext) ([]models.Project, []string, error) {
+	envs, err := g.Environments(ctx.Pull.BaseBranch)
+	if err != nil {
+		return nil, nil, err
+	}
+	var projects []models.Project
+	for _, env := range envs {
+		dir := filepath.Join(g.EnvDir, env)
+		projects = append(projects, models.NewProject(ctx.BaseRepo.FullName, dir))
+		ctx.Log.Info("created new project %s, env path: %s", ctx.BaseRepo.FullName, dir)
+	}
+	return projects, nil, nil
+}
+
+// Environments returns the environments that branch is mapped to. The first
+// pattern in the mapping that branch matches wins, so a specific pattern
+// should be mapped before a broader one that overlaps it. Every environment
+// mapped to that same pattern is returned, in the order they're mapped. It's
+// an error if there's a mapping but branch doesn't match it so a pull request
+// into an unexpected branch can't plan an arbitrary directory.
+func (g *GitflowDetector) Environments(branch string) ([]string, error) {
+	if len(g.BranchMapping) == 0 {
+		return []string{branch}, nil
+	}
+	var envs []string
+	var pattern string
+	for _, m := range g.BranchMapping {
+		if pattern == "" && m.Matches(branch) {
+			pattern = m.Branch
+		}
+		if pattern != "" && m.Branch == pattern {
+			envs = append(envs, m.Env)
+		}
+	}
+	if len(envs) == 0 {
+		return nil, fmt.Errorf("no environment is mapped to branch %q, add one to --gitflow-environment-branch-map", branch)
+	}
+	return envs, nil
+}
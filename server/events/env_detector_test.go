@@ -0,0 +1,89 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestGitflowDetector_MappedBranch(t *testing.T) {
+	t.Log("a pull request into a mapped branch should run in the directory of each of its environments")
+	g, err := events.NewGitflowDetector("envs", []string{"prod:master", "staging:develop", "prod-eu : master"})
+	Ok(t, err)
+	ctx := &events.CommandContext{
+		Log:      logging.NewNoopLogger(),
+		BaseRepo: models.Repo{FullName: "owner/repo"},
+		Pull:     models.PullRequest{BaseBranch: "master"},
+	}
+
+	projects, modifiedFiles, err := g.Detect(ctx)
+	Ok(t, err)
+	Equals(t, []models.Project{models.NewProject("owner/repo", "envs/prod"), models.NewProject("owner/repo", "envs/prod-eu")}, projects)
+	Equals(t, 0, len(modifiedFiles))
+}
+
+func TestGitflowDetector_UnmappedBranch(t *testing.T) {
+	t.Log("a pull request into a branch that isn't mapped should be an error")
+	g, err := events.NewGitflowDetector("envs", []string{"prod:master"})
+	Ok(t, err)
+
+	_, err = g.Environments("feature")
+	Assert(t, err != nil, "exp an error")
+	Equals(t, `no environment is mapped to branch "feature", add one to --gitflow-environment-branch-map`, err.Error())
+}
+
+func TestGitflowDetector_NoMapping(t *testing.T) {
+	t.Log("without a mapping the base branch should be the environment")
+	g := &events.GitflowDetector{EnvDir: "envs"}
+
+	envs, err := g.Environments("develop")
+	Ok(t, err)
+	Equals(t, []string{"develop"}, envs)
+}
+
+func TestNewGitflowDetector_Invalid(t *testing.T) {
+	t.Log("every invalid entry in the branch map should be reported")
+	_, err := events.NewGitflowDetector("envs", []string{"prod:master", "staging", "prod:release", "dev:feature:x", ":develop", "qa: "})
+	Assert(t, err != nil, "exp an error")
+	Equals(t, `"staging" must be env:branch; environment "prod" is mapped by both "prod:master" and "prod:release"; "dev:feature:x" must be env:branch; ":develop" must be env:branch; "qa: " must be env:branch`, err.Error())
+}
+
+func TestGitflowDetector_Patterns(t *testing.T) {
+	t.Log("the first pattern a branch matches should pick its environments")
+	g, err := events.NewGitflowDetector("envs", []string{
+		"prod:master",
+		"prod-eu:master",
+		"rc:re:release/.*-rc[0-9]+",
+		"staging:release/*",
+		"hotfix:hotfix-*",
+	})
+	Ok(t, err)
+
+	cases := map[string][]string{
+		"master":            {"prod", "prod-eu"},
+		"release/1.0":       {"staging"},
+		"release/1.0-rc1":   {"rc"},
+		"hotfix-login":      {"hotfix"},
+		"release/1.0/extra": nil,
+		"xrelease/1.0-rc1":  nil,
+	}
+	for branch, exp := range cases {
+		envs, err := g.Environments(branch)
+		if exp == nil {
+			Assert(t, err != nil, "exp %s to not be mapped", branch)
+			continue
+		}
+		Ok(t, err)
+		Equals(t, exp, envs)
+	}
+}
+
+func TestNewGitflowDetector_InvalidPatterns(t *testing.T) {
+	t.Log("patterns that don't compile should be reported")
+	_, err := events.NewGitflowDetector("envs", []string{"prod:re:release/(", "staging:release/["})
+	Assert(t, err != nil, "exp an error")
+	Equals(t, `"prod:re:release/(" has an invalid regex: error parsing regexp: missing closing ): `+"`^(?:release/()$`"+`; "staging:release/[" has an invalid glob: syntax error in pattern`, err.Error())
+}
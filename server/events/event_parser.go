@@ -20,6 +20,22 @@ type Command struct {
 	Environment string
 	Verbose     bool
 	Flags       []string
+	// Emergency is true if the user asked to bypass the change window with
+	// --emergency.
+	Emergency bool
+	// Ticket is the change ticket the user referenced with --ticket.
+	Ticket string
+	// Fix is true if the user asked fmt to push its fixes with --fix.
+	Fix bool
+	// ProjectName is the name of the project the user asked to run the
+	// command in with -p. It's looked up in the projects' config files.
+	ProjectName string
+	// Export is true if the user asked plan to export its plan files with
+	// --export.
+	Export bool
+	// DestroyOK is true if the user confirmed with --destroy-ok that apply
+	// can destroy resources.
+	DestroyOK bool
 }
 
 type EventParsing interface {
@@ -35,6 +51,9 @@ type EventParsing interface {
 type EventParser struct {
 	GithubUser  string
 	GithubToken string
+	// GithubApp, if set, is the GitHub App installation whose token repos
+	// are cloned with instead of GithubUser and GithubToken.
+	GithubApp   *vcs.GithubAppTransport
 	GitlabUser  string
 	GitlabToken string
 }
@@ -44,7 +63,7 @@ type EventParser struct {
 func (e *EventParser) DetermineCommand(comment string, vcsHost vcs.Host) (*Command, error) {
 	// valid commands contain:
 	// the initial "executable" name, 'run' or 'atlantis' or '@GithubUser' where GithubUser is the api user atlantis is running as
-	// then a command, either 'plan', 'apply', or 'help'
+	// then a command, either 'plan', 'apply', 'version-check', 'fmt', 'validate', 'history' or 'help'
 	// then an optional environment argument, an optional --verbose flag and any other flags
 	//
 	// examples:
@@ -53,6 +72,11 @@ func (e *EventParser) DetermineCommand(comment string, vcsHost vcs.Host) (*Comma
 	// @GithubUser plan staging
 	// atlantis plan staging --verbose
 	// atlantis plan staging --verbose -key=value -key2 value2
+	// atlantis apply production --emergency --ticket CHG-123
+	// atlantis fmt --fix
+	// atlantis validate
+	// atlantis plan -p payments-prod
+	// atlantis plan production --export
 	err := errors.New("not an Atlantis command")
 	args := strings.Fields(comment)
 	if len(args) < 2 {
@@ -61,21 +85,35 @@ func (e *EventParser) DetermineCommand(comment string, vcsHost vcs.Host) (*Comma
 
 	env := "default"
 	verbose := false
+	emergency := false
+	fix := false
+	export := false
+	destroyOK := false
+	ticket := ""
+	projectName := ""
 	var flags []string
 
 	vcsUser := e.GithubUser
 	if vcsHost == vcs.Gitlab {
 		vcsUser = e.GitlabUser
 	}
-	if !e.stringInSlice(args[0], []string{"run", "atlantis", "@" + vcsUser}) {
+	executables := []string{"run", "atlantis"}
+	// GitHub Apps don't have a user to mention.
+	if vcsUser != "" {
+		executables = append(executables, "@"+vcsUser)
+	}
+	if !e.stringInSlice(args[0], executables) {
 		return nil, err
 	}
-	if !e.stringInSlice(args[1], []string{"plan", "apply", "help"}) {
+	if !e.stringInSlice(args[1], []string{"plan", "apply", "version-check", "fmt", "validate", "history", "help"}) {
 		return nil, err
 	}
 	if args[1] == "help" {
 		return &Command{Name: Help}, nil
 	}
+	if args[1] == "history" {
+		return &Command{Name: History}, nil
+	}
 	command := args[1]
 
 	if len(args) > 2 {
@@ -94,16 +132,40 @@ func (e *EventParser) DetermineCommand(comment string, vcsHost vcs.Host) (*Comma
 			verbose = true
 			flags = e.removeOccurrences("--verbose", flags)
 		}
+		if e.stringInSlice("--emergency", flags) {
+			emergency = true
+			flags = e.removeOccurrences("--emergency", flags)
+		}
+		if e.stringInSlice("--fix", flags) {
+			fix = true
+			flags = e.removeOccurrences("--fix", flags)
+		}
+		if e.stringInSlice("--export", flags) {
+			export = true
+			flags = e.removeOccurrences("--export", flags)
+		}
+		if e.stringInSlice("--destroy-ok", flags) {
+			destroyOK = true
+			flags = e.removeOccurrences("--destroy-ok", flags)
+		}
+		ticket, flags = e.extractFlag("--ticket", flags)
+		projectName, flags = e.extractFlag("-p", flags)
 	}
 
-	c := &Command{Verbose: verbose, Environment: env, Flags: flags}
+	c := &Command{Verbose: verbose, Environment: env, Flags: flags, Emergency: emergency, Ticket: ticket, Fix: fix, ProjectName: projectName, Export: export, DestroyOK: destroyOK}
 	switch command {
 	case "plan":
 		c.Name = Plan
 	case "apply":
 		c.Name = Apply
+	case "version-check":
+		c.Name = VersionCheck
+	case "fmt":
+		c.Name = Fmt
+	case "validate":
+		c.Name = Validate
 	default:
-		return nil, fmt.Errorf("something went wrong parsing the command, the command we parsed %q was not apply or plan", command)
+		return nil, fmt.Errorf("something went wrong parsing the command, the command we parsed %q was not apply, plan, version-check or fmt", command)
 	}
 	return c, nil
 }
@@ -199,7 +261,15 @@ func (e *EventParser) ParseGithubRepo(ghRepo *github.Repository) (models.Repo, e
 	}
 
 	// Construct HTTPS repo clone url string with username and password.
-	repoCloneURL := strings.Replace(repoSanitizedCloneURL, "https://", fmt.Sprintf("https://%s:%s@", e.GithubUser, e.GithubToken), -1)
+	user, token := e.GithubUser, e.GithubToken
+	if e.GithubApp != nil {
+		var err error
+		if token, err = e.GithubApp.Token(); err != nil {
+			return repo, fmt.Errorf("getting token to clone with: %s", err)
+		}
+		user = "x-access-token"
+	}
+	repoCloneURL := strings.Replace(repoSanitizedCloneURL, "https://", fmt.Sprintf("https://%s:%s@", user, token), -1)
 
 	return models.Repo{
 		Owner:             repoOwner,
@@ -313,7 +383,29 @@ func (e *EventParser) stringInSlice(a string, list []string) bool {
 	return false
 }
 
-// nolint: unparam
+// extractFlag removes the flag called name, ex. "--ticket", from flags and
+// returns its value, which can be given as "--ticket CHG-1" or
+// "--ticket=CHG-1". These are our flags so they must not be passed on to
+// terraform.
+func (e *EventParser) extractFlag(name string, flags []string) (string, []string) {
+	var value string
+	var out []string
+	for i := 0; i < len(flags); i++ {
+		switch {
+		case flags[i] == name:
+			if i+1 < len(flags) {
+				value = flags[i+1]
+				i++
+			}
+		case strings.HasPrefix(flags[i], name+"="):
+			value = strings.TrimPrefix(flags[i], name+"=")
+		default:
+			out = append(out, flags[i])
+		}
+	}
+	return value, out
+}
+
 func (e *EventParser) removeOccurrences(a string, list []string) []string {
 	var out []string
 	for _, b := range list {
@@ -46,6 +46,21 @@ func TestDetermineCommandInvalid(t *testing.T) {
 	}
 }
 
+func TestDetermineCommandHistory(t *testing.T) {
+	t.Log("given a history comment, should match")
+	comments := []string{
+		"run history",
+		"atlantis history",
+		"@github-user history",
+		"atlantis history staging",
+	}
+	for _, c := range comments {
+		command, e := parser.DetermineCommand(c, vcs.Github)
+		Ok(t, e)
+		Equals(t, events.History, command.Name)
+	}
+}
+
 func TestDetermineCommandHelp(t *testing.T) {
 	t.Log("given a help comment, should match")
 	comments := []string{
@@ -61,9 +76,99 @@ func TestDetermineCommandHelp(t *testing.T) {
 	}
 }
 
+func TestDetermineCommandEmergency(t *testing.T) {
+	t.Log("--emergency and --ticket should be parsed and not passed on to terraform")
+	cases := []struct {
+		comment      string
+		expEmergency bool
+		expTicket    string
+		expFlags     []string
+	}{
+		{"atlantis apply production --emergency --ticket CHG-1", true, "CHG-1", nil},
+		{"atlantis apply production --ticket=CHG-2 --emergency -key=value", true, "CHG-2", []string{"-key=value"}},
+		{"atlantis apply production --ticket CHG-3", false, "CHG-3", nil},
+		{"atlantis apply production --emergency --ticket", true, "", nil},
+		{"atlantis apply production -key=value", false, "", []string{"-key=value"}},
+	}
+	for _, c := range cases {
+		command, err := parser.DetermineCommand(c.comment, vcs.Github)
+		Ok(t, err)
+		Equals(t, "production", command.Environment)
+		Equals(t, c.expEmergency, command.Emergency)
+		Equals(t, c.expTicket, command.Ticket)
+		Equals(t, c.expFlags, command.Flags)
+	}
+}
+
+func TestDetermineCommandProjectName(t *testing.T) {
+	t.Log("-p should be parsed as the project name and not passed on to terraform")
+	cases := []struct {
+		comment        string
+		expEnv         string
+		expProjectName string
+		expFlags       []string
+	}{
+		{"atlantis plan -p payments-prod", "default", "payments-prod", nil},
+		{"atlantis plan staging -p=payments -key=value", "staging", "payments", []string{"-key=value"}},
+		{"atlantis apply -p payments-prod --verbose", "default", "payments-prod", nil},
+		{"atlantis plan -key=value", "default", "", []string{"-key=value"}},
+	}
+	for _, c := range cases {
+		command, err := parser.DetermineCommand(c.comment, vcs.Github)
+		Ok(t, err)
+		Equals(t, c.expEnv, command.Environment)
+		Equals(t, c.expProjectName, command.ProjectName)
+		Equals(t, c.expFlags, command.Flags)
+	}
+}
+
+func TestDetermineCommandFmt(t *testing.T) {
+	t.Log("fmt should be parsed with --fix removed from its flags")
+	command, err := parser.DetermineCommand("atlantis fmt --fix", vcs.Github)
+	Ok(t, err)
+	Equals(t, events.Fmt, command.Name)
+	Equals(t, "default", command.Environment)
+	Equals(t, true, command.Fix)
+	Equals(t, []string(nil), command.Flags)
+
+	command, err = parser.DetermineCommand("atlantis fmt", vcs.Github)
+	Ok(t, err)
+	Equals(t, events.Fmt, command.Name)
+	Equals(t, false, command.Fix)
+}
+
+func TestDetermineCommandExport(t *testing.T) {
+	t.Log("plan should be parsed with --export removed from its flags")
+	command, err := parser.DetermineCommand("atlantis plan production --export -target=a", vcs.Github)
+	Ok(t, err)
+	Equals(t, events.Plan, command.Name)
+	Equals(t, "production", command.Environment)
+	Equals(t, true, command.Export)
+	Equals(t, []string{"-target=a"}, command.Flags)
+}
+
+func TestDetermineCommandDestroyOK(t *testing.T) {
+	t.Log("apply should be parsed with --destroy-ok removed from its flags")
+	command, err := parser.DetermineCommand("atlantis apply production --destroy-ok -target=a", vcs.Github)
+	Ok(t, err)
+	Equals(t, events.Apply, command.Name)
+	Equals(t, true, command.DestroyOK)
+	Equals(t, []string{"-target=a"}, command.Flags)
+}
+
+func TestDetermineCommandGithubApp(t *testing.T) {
+	t.Log("without a GitHub user to mention, only run and atlantis should be accepted")
+	app := events.EventParser{GitlabUser: "gitlab-user"}
+	_, err := app.DetermineCommand("@ plan", vcs.Github)
+	Assert(t, err != nil, "exp an error")
+	command, err := app.DetermineCommand("atlantis plan", vcs.Github)
+	Ok(t, err)
+	Equals(t, events.Plan, command.Name)
+}
+
 func TestDetermineCommandPermutations(t *testing.T) {
 	execNames := []string{"run", "atlantis", "@github-user", "@gitlab-user"}
-	commandNames := []events.CommandName{events.Plan, events.Apply}
+	commandNames := []events.CommandName{events.Plan, events.Apply, events.VersionCheck, events.Validate}
 	envs := []string{"", "default", "env", "env-dash", "env_underscore", "camelEnv"}
 	flagCases := [][]string{
 		{},
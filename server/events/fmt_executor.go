@@ -0,0 +1,146 @@
+package events
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hootsuite/atlantis/server/events/terraform"
+)
+
+// fmtWorkspace is the workspace we clone into when running terraform fmt.
+// Like the version check, it can't share the workspace of a real environment
+// or cloning would delete its plans.
+const fmtWorkspace = ".fmt"
+
+// fmtCommitMessage is the message of the commit with the formatting fixes.
+const fmtCommitMessage = "Run terraform fmt\n\nRequested with \"atlantis fmt --fix\"."
+
+// fmtRecursiveVersion is the first version of terraform that needs -recursive
+// to format subdirectories. Earlier versions always recurse and don't
+// understand the flag.
+var fmtRecursiveVersion = version.Must(version.NewVersion("0.12.0"))
+
+// FmtExecutor runs terraform fmt on the projects modified in the pull request.
+// By default it only reports the files that aren't formatted. With --fix it
+// formats them and pushes the fixes to the pull request's branch, if that's
+// allowed.
+type FmtExecutor struct {
+	Workspace         Workspace
+	ProjectDeterminer ProjectDeterminer
+	ProjectPreExecute *ProjectPreExecute
+	CommitPusher      CommitPusher
+	// AllowPush must be true for --fix to push to the branch.
+	AllowPush bool
+	// PushUsers are the only users that can run --fix since it writes to the
+	// branch.
+	PushUsers []string
+	// ApplyPolicy, if set, is the policy whose AllowedRepos are the only repos
+	// --fix can push to, so a repo that can't be applied can't be written to
+	// either.
+	ApplyPolicy ApplyPolicyGetter
+}
+
+// ApplyPolicyGetter returns the apply policy that's currently used.
+type ApplyPolicyGetter interface {
+	Policy() ApplyPolicy
+}
+
+// FmtSuccess is the result of running terraform fmt in a project.
+type FmtSuccess struct {
+	// Files are the files that weren't formatted, relative to the project.
+	Files []string
+	// Fixed is true if Files were formatted and pushed.
+	Fixed bool
+}
+
+func (f *FmtExecutor) Execute(ctx *CommandContext) CommandResponse {
+	if ctx.Command.Fix {
+		if !f.AllowPush {
+			return CommandResponse{Failure: "Pushing formatting fixes is disabled on this Atlantis server. Run terraform fmt locally instead."}
+		}
+		if !f.isPushUser(ctx.User.Username) {
+			return CommandResponse{Failure: fmt.Sprintf("%s is not allowed to push formatting fixes.", ctx.User.Username)}
+		}
+		if f.ApplyPolicy != nil && !f.ApplyPolicy.Policy().AllowedRepos.IsAllowed(ctx.BaseRepo.FullName) {
+			return CommandResponse{Failure: fmt.Sprintf("Pushing formatting fixes isn't allowed for %s. Ask your Atlantis administrators to add it to --allowed-repos.", ctx.BaseRepo.FullName)}
+		}
+	}
+
+	projects, err := f.ProjectDeterminer.DetermineProjects(ctx)
+	if err != nil {
+		return CommandResponse{Error: err}
+	}
+	if len(projects) == 0 {
+		return CommandResponse{Failure: "No Terraform files were modified."}
+	}
+
+	cloneDir, err := f.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, fmtWorkspace)
+	if err != nil {
+		return CommandResponse{Error: err}
+	}
+
+	results := []ProjectResult{}
+	var fixed bool
+	for _, project := range projects {
+		ctx.Log.Info("running terraform fmt for project at path %q", project.Path)
+		result := f.fmt(ctx, cloneDir, project.Path)
+		result.Path = project.Path
+		if result.FmtSuccess != nil && result.FmtSuccess.Fixed {
+			fixed = true
+		}
+		results = append(results, result)
+	}
+
+	if fixed {
+		if err := f.CommitPusher.CommitAndPush(ctx.Log, cloneDir, ctx.Pull.Branch, fmtCommitMessage); err != nil {
+			return CommandResponse{Error: err}
+		}
+	}
+	return CommandResponse{ProjectResults: results}
+}
+
+func (f *FmtExecutor) fmt(ctx *CommandContext, repoDir string, path string) ProjectResult {
+	var config ProjectConfig
+	absolutePath := filepath.Join(repoDir, path)
+	if f.ProjectPreExecute.ConfigReader.Exists(absolutePath) {
+		var err error
+		config, err = f.ProjectPreExecute.ConfigReader.Read(absolutePath)
+		if err != nil {
+			return ProjectResult{Error: err}
+		}
+	}
+	terraformVersion := f.ProjectPreExecute.TerraformVersion(config)
+
+	// -list makes terraform print the files that aren't formatted, -write
+	// decides if it also formats them.
+	args := []string{"fmt", "-list=true", fmt.Sprintf("-write=%t", ctx.Command.Fix)}
+	if !terraformVersion.LessThan(fmtRecursiveVersion) {
+		args = append(args, "-recursive")
+	}
+	output, err := f.ProjectPreExecute.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, args, terraformVersion, ctx.Command.Environment, config.Command, ctx.AWSProfile)
+	if err != nil {
+		if notFoundErr, ok := err.(*terraform.ExecutableNotFoundError); ok {
+			return ProjectResult{Failure: notFoundErr.Error()}
+		}
+		return ProjectResult{Error: err}
+	}
+
+	var files []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return ProjectResult{FmtSuccess: &FmtSuccess{Files: files, Fixed: ctx.Command.Fix && len(files) > 0}}
+}
+
+func (f *FmtExecutor) isPushUser(username string) bool {
+	for _, u := range f.PushUsers {
+		if u == username {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/mocks"
+	"github.com/hootsuite/atlantis/server/events/mocks/matchers"
+	"github.com/hootsuite/atlantis/server/events/models"
+	tmocks "github.com/hootsuite/atlantis/server/events/terraform/mocks"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+func fmtCtx(fix bool) *events.CommandContext {
+	return &events.CommandContext{
+		Command: &events.Command{
+			Name:        events.Fmt,
+			Environment: "default",
+			Fix:         fix,
+		},
+		User: models.User{Username: "alice"},
+		Pull: models.PullRequest{Branch: "branch"},
+		Log:  logging.NewNoopLogger(),
+	}
+}
+
+func TestFmt_FixNotAllowed(t *testing.T) {
+	t.Log("If pushing isn't allowed, --fix should fail")
+	f, _, _ := setupFmtTest(t, []models.Project{{Path: "."}})
+	f.AllowPush = false
+	r := f.Execute(fmtCtx(true))
+	Equals(t, "Pushing formatting fixes is disabled on this Atlantis server. Run terraform fmt locally instead.", r.Failure)
+}
+
+func TestFmt_FixUserNotAllowed(t *testing.T) {
+	t.Log("If the user isn't one of the push users, --fix should fail")
+	f, _, _ := setupFmtTest(t, []models.Project{{Path: "."}})
+	f.PushUsers = []string{"bob"}
+	r := f.Execute(fmtCtx(true))
+	Equals(t, "alice is not allowed to push formatting fixes.", r.Failure)
+}
+
+func TestFmt_FixRepoNotAllowed(t *testing.T) {
+	t.Log("If the repo isn't in the apply policy's allowed repos, --fix should fail without pushing")
+	f, _, pusher := setupFmtTest(t, []models.Project{{Path: "."}})
+	allowed, err := events.NewAllowedRepos([]string{"owner/other"})
+	Ok(t, err)
+	policy := &events.ApplyExecutor{}
+	policy.SetPolicy(events.ApplyPolicy{AllowedRepos: allowed})
+	f.ApplyPolicy = policy
+	ctx := fmtCtx(true)
+	ctx.BaseRepo = models.Repo{FullName: "owner/repo"}
+
+	r := f.Execute(ctx)
+	Equals(t, "Pushing formatting fixes isn't allowed for owner/repo. Ask your Atlantis administrators to add it to --allowed-repos.", r.Failure)
+	pusher.VerifyWasCalled(Never()).CommitAndPush(matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyString(), AnyString())
+}
+
+func TestFmt_Check(t *testing.T) {
+	t.Log("Without --fix the unformatted files should be listed but not written or pushed")
+	f, tm, pusher := setupFmtTest(t, []models.Project{{Path: "path"}})
+	ctx := fmtCtx(false)
+	v, _ := version.NewVersion("0.11.1")
+	When(tm.Version()).ThenReturn(v)
+	When(f.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, ".fmt")).ThenReturn("/tmp/clone", nil)
+	When(tm.RunCommandWithVersion(ctx.Log, "/tmp/clone/path", []string{"fmt", "-list=true", "-write=false"}, v, "default", "", "")).
+		ThenReturn("main.tf\nmodules/vars.tf\n", nil)
+
+	r := f.Execute(ctx)
+	Equals(t, []events.ProjectResult{
+		{
+			Path:       "path",
+			FmtSuccess: &events.FmtSuccess{Files: []string{"main.tf", "modules/vars.tf"}},
+		},
+	}, r.ProjectResults)
+	pusher.VerifyWasCalled(Never()).CommitAndPush(matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyString(), AnyString())
+}
+
+func TestFmt_Fix(t *testing.T) {
+	t.Log("With --fix the files should be formatted recursively and pushed to the branch")
+	f, tm, pusher := setupFmtTest(t, []models.Project{{Path: "path"}, {Path: "formatted"}})
+	ctx := fmtCtx(true)
+	v, _ := version.NewVersion("0.12.0")
+	When(tm.Version()).ThenReturn(v)
+	When(f.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, ".fmt")).ThenReturn("/tmp/clone", nil)
+	When(tm.RunCommandWithVersion(ctx.Log, "/tmp/clone/path", []string{"fmt", "-list=true", "-write=true", "-recursive"}, v, "default", "", "")).
+		ThenReturn("main.tf\n", nil)
+	When(tm.RunCommandWithVersion(ctx.Log, "/tmp/clone/formatted", []string{"fmt", "-list=true", "-write=true", "-recursive"}, v, "default", "", "")).
+		ThenReturn("", nil)
+
+	r := f.Execute(ctx)
+	Equals(t, []events.ProjectResult{
+		{
+			Path:       "path",
+			FmtSuccess: &events.FmtSuccess{Files: []string{"main.tf"}, Fixed: true},
+		},
+		{
+			Path:       "formatted",
+			FmtSuccess: &events.FmtSuccess{},
+		},
+	}, r.ProjectResults)
+	pusher.VerifyWasCalledOnce().CommitAndPush(ctx.Log, "/tmp/clone", "branch", "Run terraform fmt\n\nRequested with \"atlantis fmt --fix\".")
+}
+
+func setupFmtTest(t *testing.T, projects []models.Project) (*events.FmtExecutor, *tmocks.MockRunner, *mocks.MockCommitPusher) {
+	RegisterMockTestingT(t)
+	tm := tmocks.NewMockRunner()
+	pusher := mocks.NewMockCommitPusher()
+	return &events.FmtExecutor{
+		Workspace:         mocks.NewMockWorkspace(),
+		ProjectDeterminer: &fakeProjectDeterminer{projects, nil},
+		ProjectPreExecute: &events.ProjectPreExecute{
+			ConfigReader: mocks.NewMockProjectConfigReader(),
+			Terraform:    tm,
+		},
+		CommitPusher: pusher,
+		AllowPush:    true,
+		PushUsers:    []string{"alice"},
+	}, tm, pusher
+}
@@ -0,0 +1,83 @@
+// Package history records the plan and apply commands that Atlantis has run
+// so they can be shown to reviewers later.
+package history
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events/jsonl"
+	"github.com/pkg/errors"
+)
+
+// File is the name of the file, relative to the data dir, that runs are
+// written to.
+const File = "run-history.jsonl"
+
+// Run is a command that Atlantis ran. It only stores what's needed to show
+// who changed what and when. Command output is never stored since it can
+// contain secrets.
+type Run struct {
+	Time time.Time `json:"time"`
+	// RepoFullName is the owner and name of the repo, ex. "hootsuite/atlantis".
+	RepoFullName string `json:"repo_full_name"`
+	PullNum      int    `json:"pull_num"`
+	// User is the username of the user that commented the command.
+	User        string `json:"user"`
+	Environment string `json:"environment"`
+	Command     string `json:"command"`
+	// Outcome is the status of the command, ex. "success" or "failed".
+	Outcome string `json:"outcome"`
+	// Emergency is true if the command was an emergency apply that could
+	// bypass the change window.
+	Emergency bool `json:"emergency,omitempty"`
+	// Ticket is the change ticket the command referenced, if any.
+	Ticket string `json:"ticket,omitempty"`
+	// Exported is true if the plan files were exported with plan --export.
+	Exported bool `json:"exported,omitempty"`
+}
+
+// Log stores runs as newline-delimited JSON.
+type Log struct {
+	file *jsonl.File
+}
+
+// NewLog returns a Log that stores its file in dataDir.
+func NewLog(dataDir string) *Log {
+	return &Log{
+		file: jsonl.NewFile(filepath.Join(dataDir, File)),
+	}
+}
+
+// Append adds r to the end of the log.
+func (l *Log) Append(r Run) error {
+	return l.file.Append(r)
+}
+
+// Recent returns up to n of the most recent runs for the repo, newest first.
+func (l *Log) Recent(repoFullName string, n int) ([]Run, error) {
+	var runs []Run
+	err := l.file.Each(func(line []byte) error {
+		var r Run
+		if err := json.Unmarshal(line, &r); err != nil {
+			return errors.Wrapf(err, "parsing %s", l.file.Path)
+		}
+		if r.RepoFullName == repoFullName {
+			runs = append(runs, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The file is oldest first so reverse it and keep the first n.
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	if len(runs) > n {
+		runs = runs[:n]
+	}
+	return runs, nil
+}
@@ -0,0 +1,44 @@
+package history_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events/history"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestRecent_Empty(t *testing.T) {
+	t.Log("if nothing has been logged there should be no runs")
+	l, cleanup := tempLog(t)
+	defer cleanup()
+	runs, err := l.Recent("owner/repo", 10)
+	Ok(t, err)
+	Equals(t, 0, len(runs))
+}
+
+func TestRecent(t *testing.T) {
+	t.Log("should return the newest runs for the repo first, up to the limit")
+	l, cleanup := tempLog(t)
+	defer cleanup()
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		Ok(t, l.Append(history.Run{Time: start.Add(time.Duration(i) * time.Hour), RepoFullName: "owner/repo", PullNum: i}))
+		Ok(t, l.Append(history.Run{Time: start, RepoFullName: "owner/other", PullNum: 100 + i}))
+	}
+
+	runs, err := l.Recent("owner/repo", 2)
+	Ok(t, err)
+	Equals(t, []history.Run{
+		{Time: start.Add(2 * time.Hour), RepoFullName: "owner/repo", PullNum: 2},
+		{Time: start.Add(time.Hour), RepoFullName: "owner/repo", PullNum: 1},
+	}, runs)
+}
+
+func tempLog(t *testing.T) (*history.Log, func()) {
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	return history.NewLog(dir), func() { os.RemoveAll(dir) } // nolint: errcheck
+}
@@ -0,0 +1,28 @@
+package events
+
+import (
+	"github.com/hootsuite/atlantis/server/events/history"
+	"github.com/pkg/errors"
+)
+
+// historyLimit is the number of runs the history command shows.
+const historyLimit = 10
+
+// RunHistory records the plans and applies that were run.
+type RunHistory interface {
+	Append(r history.Run) error
+	Recent(repoFullName string, n int) ([]history.Run, error)
+}
+
+// HistoryExecutor shows the most recent runs for the repo.
+type HistoryExecutor struct {
+	History RunHistory
+}
+
+func (h *HistoryExecutor) Execute(ctx *CommandContext) CommandResponse {
+	runs, err := h.History.Recent(ctx.BaseRepo.FullName, historyLimit)
+	if err != nil {
+		return CommandResponse{Error: errors.Wrap(err, "getting run history")}
+	}
+	return CommandResponse{History: runs}
+}
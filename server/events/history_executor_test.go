@@ -0,0 +1,28 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/history"
+	"github.com/hootsuite/atlantis/server/events/models"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestHistoryExecute(t *testing.T) {
+	t.Log("should return the runs for the pull request's repo")
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	l := history.NewLog(dir)
+	Ok(t, l.Append(history.Run{RepoFullName: "owner/repo", Command: "plan"}))
+	Ok(t, l.Append(history.Run{RepoFullName: "owner/other", Command: "apply"}))
+
+	h := events.HistoryExecutor{History: l}
+	res := h.Execute(&events.CommandContext{BaseRepo: models.Repo{FullName: "owner/repo"}})
+	Equals(t, events.CommandResponse{
+		History: []history.Run{{RepoFullName: "owner/repo", Command: "plan"}},
+	}, res)
+}
@@ -0,0 +1,142 @@
+// Package jira looks up and transitions the Jira issues that are used as
+// change tickets for applies.
+package jira
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// keyRegex matches Jira issue keys, ex. CHG-123. Keys come from pull request
+// comments so we check them before putting them in a URL.
+var keyRegex = regexp.MustCompile(`^[A-Z][A-Z0-9_]*-[0-9]+$`)
+
+// timeout is how long we wait for each request to Jira.
+const timeout = 10 * time.Second
+
+// Client calls the Jira REST API. It authenticates with basic auth, which
+// for Jira Cloud is the user's email and an API token.
+type Client struct {
+	BaseURL string
+	User    string
+	Token   string
+	HTTP    *http.Client
+}
+
+// Issue is the part of a Jira issue that we show on the pull request.
+type Issue struct {
+	Key     string
+	Summary string
+	Status  string
+	// URL is the issue's page in the Jira UI.
+	URL string
+}
+
+// NewClient returns a client for the Jira at baseURL, ex.
+// https://example.atlassian.net.
+func NewClient(baseURL string, user string, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		User:    user,
+		Token:   token,
+		HTTP:    &http.Client{Timeout: timeout},
+	}
+}
+
+// ValidKey returns true if key looks like a Jira issue key.
+func ValidKey(key string) bool {
+	return keyRegex.MatchString(key)
+}
+
+// GetIssue looks up the issue with key.
+func (c *Client) GetIssue(key string) (Issue, error) {
+	if !ValidKey(key) {
+		return Issue{}, fmt.Errorf("%q is not a Jira issue key", key)
+	}
+	var resp struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary string `json:"summary"`
+			Status  struct {
+				Name string `json:"name"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	if err := c.do("GET", "/rest/api/2/issue/"+key+"?fields=summary,status", nil, &resp); err != nil {
+		return Issue{}, errors.Wrapf(err, "getting %s", key)
+	}
+	return Issue{
+		Key:     resp.Key,
+		Summary: resp.Fields.Summary,
+		Status:  resp.Fields.Status.Name,
+		URL:     c.BaseURL + "/browse/" + resp.Key,
+	}, nil
+}
+
+// Transition moves the issue with key through the transition called name,
+// ex. "Applied". Transition names are what's shown on the issue's buttons in
+// the Jira UI.
+func (c *Client) Transition(key string, name string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("%q is not a Jira issue key", key)
+	}
+	var resp struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	path := "/rest/api/2/issue/" + key + "/transitions"
+	if err := c.do("GET", path, nil, &resp); err != nil {
+		return errors.Wrapf(err, "getting transitions for %s", key)
+	}
+	for _, t := range resp.Transitions {
+		if strings.EqualFold(t.Name, name) {
+			body := map[string]interface{}{"transition": map[string]string{"id": t.ID}}
+			return errors.Wrapf(c.do("POST", path, body, nil), "transitioning %s", key)
+		}
+	}
+	return fmt.Errorf("%s can't be transitioned with %q from its current status", key, name)
+}
+
+// do makes a request to path and decodes the JSON response into out if it's
+// not nil.
+func (c *Client) do(method string, path string, body interface{}, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewBuffer(b)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.User, c.Token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("got status %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,92 @@
+package jira_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events/jira"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestGetIssue(t *testing.T) {
+	t.Log("GetIssue should return the issue's summary and status")
+	var user, token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, token, _ = r.BasicAuth()
+		Equals(t, "/rest/api/2/issue/CHG-1", r.URL.Path)
+		fmt.Fprint(w, `{"key": "CHG-1", "fields": {"summary": "Resize the database", "status": {"name": "Approved"}}}`)
+	}))
+	defer server.Close()
+
+	issue, err := jira.NewClient(server.URL+"/", "atlantis", "token").GetIssue("CHG-1")
+	Ok(t, err)
+	Equals(t, jira.Issue{
+		Key:     "CHG-1",
+		Summary: "Resize the database",
+		Status:  "Approved",
+		URL:     server.URL + "/browse/CHG-1",
+	}, issue)
+	Equals(t, "atlantis", user)
+	Equals(t, "token", token)
+}
+
+func TestGetIssue_InvalidKey(t *testing.T) {
+	t.Log("GetIssue should error without calling Jira if the key isn't an issue key")
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	_, err := jira.NewClient(server.URL, "atlantis", "token").GetIssue("../../myself")
+	Assert(t, err != nil, "expected error")
+	Equals(t, `"../../myself" is not a Jira issue key`, err.Error())
+	Assert(t, !called, "expected Jira not to be called")
+}
+
+func TestGetIssue_NotFound(t *testing.T) {
+	t.Log("GetIssue should error if Jira doesn't have the issue")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := jira.NewClient(server.URL, "atlantis", "token").GetIssue("CHG-1")
+	Assert(t, err != nil, "expected error")
+	Equals(t, "getting CHG-1: got status 404", err.Error())
+}
+
+func TestTransition(t *testing.T) {
+	t.Log("Transition should post the ID of the transition with the given name")
+	var posted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "/rest/api/2/issue/CHG-1/transitions", r.URL.Path)
+		if r.Method == "GET" {
+			fmt.Fprint(w, `{"transitions": [{"id": "11", "name": "Start"}, {"id": "31", "name": "Applied"}]}`)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		posted = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := jira.NewClient(server.URL, "atlantis", "token").Transition("CHG-1", "applied")
+	Ok(t, err)
+	Equals(t, `{"transition":{"id":"31"}}`, posted)
+}
+
+func TestTransition_NotAvailable(t *testing.T) {
+	t.Log("Transition should error if the issue doesn't have the transition")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"transitions": [{"id": "11", "name": "Start"}]}`)
+	}))
+	defer server.Close()
+
+	err := jira.NewClient(server.URL, "atlantis", "token").Transition("CHG-1", "Applied")
+	Assert(t, err != nil, "expected error")
+	Equals(t, `CHG-1 can't be transitioned with "Applied" from its current status`, err.Error())
+}
@@ -0,0 +1,129 @@
+// Package jsonl stores values in files as newline-delimited JSON, one value
+// per line, which is how the audit log, run history and webhook dead letters
+// are kept.
+package jsonl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// File is a file of newline-delimited JSON. It's safe to use from multiple
+// goroutines. The file is created with mode 0600 since the values can be
+// sensitive.
+type File struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+// NewFile returns a File at path.
+func NewFile(path string) *File {
+	return &File{Path: path}
+}
+
+// Append adds v to the end of the file, creating it if it doesn't exist.
+func (f *File) Append(v interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	line, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrapf(err, "serializing line for %s", f.Path)
+	}
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "opening %s", f.Path)
+	}
+	defer file.Close() // nolint: errcheck
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return errors.Wrapf(err, "writing to %s", f.Path)
+	}
+	return nil
+}
+
+// Each calls fn with each line of the file, oldest first, and stops at the
+// first error fn returns. Empty lines are skipped and a file that doesn't
+// exist has no lines.
+func (f *File) Each(fn func(line []byte) error) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.each(fn)
+}
+
+// Replace overwrites the file with the values update returns for its lines,
+// which are passed to update oldest first. The file isn't changed by anything
+// else in between and is replaced through a temporary file so a crash can't
+// leave it half written.
+func (f *File) Replace(update func(lines [][]byte) ([]interface{}, error)) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var lines [][]byte
+	err := f.each(func(line []byte) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	values, err := update(lines)
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "opening %s", tmp)
+	}
+	for _, v := range values {
+		line, err := json.Marshal(v)
+		if err != nil {
+			file.Close() // nolint: errcheck
+			return errors.Wrapf(err, "serializing line for %s", f.Path)
+		}
+		if _, err := file.Write(append(line, '\n')); err != nil {
+			file.Close() // nolint: errcheck
+			return errors.Wrapf(err, "writing to %s", tmp)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return errors.Wrapf(err, "closing %s", tmp)
+	}
+	return errors.Wrapf(os.Rename(tmp, f.Path), "replacing %s", f.Path)
+}
+
+func (f *File) each(fn func(line []byte) error) error {
+	file, err := os.Open(f.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "opening %s", f.Path)
+	}
+	defer file.Close() // nolint: errcheck
+
+	// bufio.Scanner has a maximum line length, which a line with command
+	// output in it can be over, so lines are read whole.
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return errors.Wrapf(err, "reading %s", f.Path)
+		}
+		if line = bytes.TrimSuffix(line, []byte("\n")); len(line) > 0 {
+			if fnErr := fn(line); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package jsonl_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events/jsonl"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestEach_NoFile(t *testing.T) {
+	t.Log("a file that doesn't exist yet should have no lines")
+	f, cleanup := tempFile(t)
+	defer cleanup()
+	Ok(t, f.Each(func(line []byte) error {
+		t.Errorf("unexpected line %q", line)
+		return nil
+	}))
+}
+
+func TestAppendAndEach(t *testing.T) {
+	t.Log("appended values should be read back as lines oldest first")
+	f, cleanup := tempFile(t)
+	defer cleanup()
+	Ok(t, f.Append(map[string]int{"a": 1}))
+	Ok(t, f.Append(map[string]int{"b": 2}))
+
+	Equals(t, []string{`{"a":1}`, `{"b":2}`}, lines(t, f))
+	info, err := os.Stat(f.Path)
+	Ok(t, err)
+	Equals(t, os.FileMode(0600), info.Mode())
+}
+
+func TestEach_LongLine(t *testing.T) {
+	t.Log("lines over bufio.Scanner's 64KB limit should be read back whole")
+	f, cleanup := tempFile(t)
+	defer cleanup()
+	long := strings.Repeat("a", 70*1024)
+	Ok(t, f.Append(long))
+	Ok(t, f.Append("short"))
+
+	Equals(t, []string{`"` + long + `"`, `"short"`}, lines(t, f))
+	Ok(t, f.Replace(func(lines [][]byte) ([]interface{}, error) {
+		Equals(t, 2, len(lines))
+		return []interface{}{string(lines[0])}, nil
+	}))
+	Equals(t, 1, len(lines(t, f)))
+}
+
+func TestEach_Error(t *testing.T) {
+	t.Log("an error from fn should stop reading the lines")
+	f, cleanup := tempFile(t)
+	defer cleanup()
+	Ok(t, f.Append(1))
+	Ok(t, f.Append(2))
+	calls := 0
+	err := f.Each(func(line []byte) error {
+		calls++
+		return errors.New("err")
+	})
+	Equals(t, "err", err.Error())
+	Equals(t, 1, calls)
+}
+
+func TestReplace(t *testing.T) {
+	t.Log("the file should only contain the values update returns")
+	f, cleanup := tempFile(t)
+	defer cleanup()
+	Ok(t, f.Append(1))
+	Ok(t, f.Append(2))
+
+	Ok(t, f.Replace(func(lines [][]byte) ([]interface{}, error) {
+		Equals(t, 2, len(lines))
+		return []interface{}{string(lines[1])}, nil
+	}))
+	Equals(t, []string{`"2"`}, lines(t, f))
+	_, err := os.Stat(f.Path + ".tmp")
+	Assert(t, os.IsNotExist(err), "exp the temporary file to be gone")
+
+	t.Log("if update fails the file shouldn't change")
+	err = f.Replace(func(lines [][]byte) ([]interface{}, error) {
+		return nil, errors.New("err")
+	})
+	Equals(t, "err", err.Error())
+	Equals(t, []string{`"2"`}, lines(t, f))
+}
+
+func lines(t *testing.T, f *jsonl.File) []string {
+	var lines []string
+	Ok(t, f.Each(func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	}))
+	return lines
+}
+
+func tempFile(t *testing.T) (*jsonl.File, func()) {
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	return jsonl.NewFile(filepath.Join(dir, "file.jsonl")), func() { os.RemoveAll(dir) } // nolint: errcheck
+}
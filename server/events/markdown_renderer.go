@@ -5,17 +5,29 @@ import (
 	"fmt"
 	"strings"
 	"text/template"
+
+	"github.com/hootsuite/atlantis/server/events/history"
 )
 
-var helpTmpl = template.Must(template.New("").Parse("```cmake\n" +
-	`atlantis - Terraform collaboration tool that enables you to collaborate on infrastructure
+var helpTmpl = template.Must(newTemplate(`{{code "cmake" .}}`))
+var helpText = `atlantis - Terraform collaboration tool that enables you to collaborate on infrastructure
 safely and securely.
 
-Usage: atlantis <command> [environment] [--verbose]
+Usage: atlantis <command> [environment] [-p project] [--verbose]
 
 Commands:
-plan           Runs 'terraform plan' on the files changed in the pull request
+plan           Runs 'terraform plan' on the files changed in the pull request.
+               With --export, also links to the plan files if you're allowed
 apply          Runs 'terraform apply' using the plans generated by 'atlantis plan'
+               With --destroy-ok, confirms plans that destroy resources
+version-check  Shows which version of terraform each project will run with and
+               whether it satisfies the version required for the environment
+fmt            Lists the files changed in the pull request that aren't formatted
+               with 'terraform fmt'. With --fix, formats them and pushes the
+               fixes to the branch if the server allows it
+validate       Runs 'terraform validate' on the projects changed in the pull
+               request without initializing their backends
+history        Shows the most recent plans and applies for this repo
 help           Get help
 
 Examples:
@@ -26,14 +38,17 @@ atlantis plan staging
 # Generates a plan for a standalone terraform project
 atlantis plan
 
+# Generates a plan for the project named payments-prod in its atlantis.yaml
+atlantis plan -p payments-prod
+
 # Applies a plan for staging environment
 atlantis apply staging
 
 # Applies a plan for a standalone terraform project
 atlantis apply
-`))
-var singleProjectTmpl = template.Must(template.New("").Parse("{{ range $result := .Results }}{{$result}}{{end}}\n" + logTmpl))
-var multiProjectTmpl = template.Must(template.New("").Parse(
+`
+var singleProjectTmpl = template.Must(newTemplate("{{ range $result := .Results }}{{$result}}{{end}}\n" + logTmpl))
+var multiProjectTmpl = template.Must(newTemplate(
 	"Ran {{.Command}} in {{ len .Results }} directories:\n" +
 		"{{ range $path, $result := .Results }}" +
 		" * `{{$path}}`\n" +
@@ -43,28 +58,94 @@ var multiProjectTmpl = template.Must(template.New("").Parse(
 		"{{$result}}\n" +
 		"---\n{{end}}" +
 		logTmpl))
-var planSuccessTmpl = template.Must(template.New("").Parse(
-	"```diff\n" +
-		"{{.TerraformOutput}}\n" +
-		"```\n\n" +
-		"* To **discard** this plan click [here]({{.LockURL}})."))
-var applySuccessTmpl = template.Must(template.New("").Parse(
-	"```diff\n" +
-		"{{.Output}}\n" +
-		"```"))
+var planSuccessTmpl = template.Must(newTemplate(
+	"{{if .Destroyed}}**Warning:** this plan destroys {{len .Destroyed}} resource(s):\n" +
+		"{{range .Destroyed}}* `{{.}}`\n{{end}}\n{{end}}" +
+		"{{code \"diff\" .TerraformOutput}}\n\n" +
+		"* To **discard** this plan click [here]({{.LockURL}})." + planExportTmpl))
+var planNoChangesTmpl = template.Must(newTemplate(
+	"**No changes.** The infrastructure matches the configuration.\n\n" +
+		"* To **discard** this plan click [here]({{.LockURL}})." + planExportTmpl))
+var planExportTmpl = "{{if .ExportURL}}\n* To **download** the plan file click [here]({{.ExportURL}}). The link expires at {{.ExportExpires.UTC.Format \"2006-01-02 15:04 MST\"}}.{{end}}"
+var validateSuccessTmpl = template.Must(newTemplate(
+	"The configuration is valid.{{if .Output}}\n\n{{code \"\" .Output}}{{end}}"))
+var applySuccessTmpl = template.Must(newTemplate(
+	"{{with .Summary}}Applied a plan with {{.Changes.Add}} to add, {{.Changes.Change}} to change, {{.Changes.Destroy}} to destroy.\n\n" +
+		"{{if .Destroyed}}**Warning:** this apply destroyed {{len .Destroyed}} resource(s):\n" +
+		"{{range .Destroyed}}* `{{.}}`\n{{end}}\n{{end}}{{end}}" +
+		"{{code \"diff\" .Output}}"))
 var errTmplText = "**{{.Command}} Error**\n" +
-	"```\n" +
-	"{{.Error}}\n" +
-	"```\n"
-var errTmpl = template.Must(template.New("").Parse(errTmplText))
-var errWithLogTmpl = template.Must(template.New("").Parse(errTmplText + logTmpl))
+	"{{code \"\" .Error}}\n"
+var errTmpl = template.Must(newTemplate(errTmplText))
+var errWithLogTmpl = template.Must(newTemplate(errTmplText + logTmpl))
 var failureTmplText = "**{{.Command}} Failed**: {{.Failure}}\n"
-var failureTmpl = template.Must(template.New("").Parse(failureTmplText))
-var failureWithLogTmpl = template.Must(template.New("").Parse(failureTmplText + logTmpl))
-var logTmpl = "{{if .Verbose}}\n<details><summary>Log</summary>\n  <p>\n\n```\n{{.Log}}```\n</p></details>{{end}}\n"
+var failureTmpl = template.Must(newTemplate(failureTmplText))
+var failureWithLogTmpl = template.Must(newTemplate(failureTmplText + logTmpl))
+var versionCheckTmpl = template.Must(newTemplate(
+	"| Project | Environment | Terraform Version | Required Version | Satisfied |\n" +
+		"|---|---|---|---|---|\n" +
+		"{{ range .Rows }}| `{{.Path}}` | {{.Environment}} | {{.Version}} | {{.Constraint}} | {{.Satisfied}} |\n{{end}}" +
+		logTmpl))
+var fmtTmpl = template.Must(newTemplate(
+	"| Project | Unformatted Files | Status |\n" +
+		"|---|---|---|\n" +
+		"{{ range .Rows }}| `{{.Path}}` | {{.Files}} | {{.Status}} |\n{{end}}" +
+		"{{ if .Unfixed }}\nComment `atlantis fmt --fix` to format these files and push the fixes to this branch.\n{{ end }}" +
+		logTmpl))
+var historyTmpl = template.Must(newTemplate(
+	"{{ if .Rows }}" +
+		"| Time | Pull Request | User | Environment | Command | Outcome |\n" +
+		"|---|---|---|---|---|---|\n" +
+		"{{ range .Rows }}| {{.Time}} | #{{.PullNum}} | {{.User}} | {{.Environment}} | {{.Command}} | {{.Outcome}} |\n{{end}}" +
+		"{{ else }}No plans or applies have been run for this repo yet.\n{{ end }}" +
+		logTmpl))
+var logTmpl = "{{if .Verbose}}\n{{details \"Log\" .Log}}{{end}}\n"
+
+// CommentFormat is how the output of commands is formatted in comments.
+type CommentFormat string
+
+const (
+	// MarkdownCommentFormat wraps output in code fences and puts logs in
+	// collapsible sections. It's the default.
+	MarkdownCommentFormat CommentFormat = "markdown"
+	// PlainCommentFormat leaves output as it is, for integrations that
+	// can't render markdown.
+	PlainCommentFormat CommentFormat = "plain"
+)
+
+// markdownFuncs format output blocks for MarkdownCommentFormat. The
+// templates are parsed with them.
+var markdownFuncs = template.FuncMap{
+	// code wraps text in a code fence that's highlighted as lang.
+	"code": func(lang string, text string) string {
+		return "```" + lang + "\n" + text + "\n```"
+	},
+	// details puts text in a collapsible section titled summary.
+	"details": func(summary string, text string) string {
+		return "<details><summary>" + summary + "</summary>\n  <p>\n\n```\n" + text + "```\n</p></details>"
+	},
+}
+
+// plainFuncs format output blocks for PlainCommentFormat.
+var plainFuncs = template.FuncMap{
+	"code": func(_ string, text string) string {
+		return text
+	},
+	"details": func(summary string, text string) string {
+		return summary + ":\n" + text
+	},
+}
+
+func newTemplate(text string) (*template.Template, error) {
+	return template.New("").Funcs(markdownFuncs).Parse(text)
+}
 
 // MarkdownRenderer renders responses as markdown
-type MarkdownRenderer struct{}
+type MarkdownRenderer struct {
+	// Format is how command output is formatted. If empty, it's
+	// MarkdownCommentFormat.
+	Format CommentFormat
+}
 
 type CommonData struct {
 	Command string
@@ -87,11 +168,51 @@ type ResultData struct {
 	CommonData
 }
 
+type VersionCheckData struct {
+	Rows []VersionCheckRow
+	CommonData
+}
+
+type FmtData struct {
+	Rows []FmtRow
+	// Unfixed is true if any of the files aren't formatted.
+	Unfixed bool
+	CommonData
+}
+
+type FmtRow struct {
+	Path   string
+	Files  string
+	Status string
+}
+
+type HistoryData struct {
+	Rows []HistoryRow
+	CommonData
+}
+
+type HistoryRow struct {
+	Time        string
+	PullNum     int
+	User        string
+	Environment string
+	Command     string
+	Outcome     string
+}
+
+type VersionCheckRow struct {
+	Path        string
+	Environment string
+	Version     string
+	Constraint  string
+	Satisfied   string
+}
+
 // Render formats the data into a string that can be commented back to GitHub.
 // nolint: interfacer
 func (g *MarkdownRenderer) Render(res CommandResponse, cmdName CommandName, log string, verbose bool) string {
 	if cmdName == Help {
-		return g.renderTemplate(helpTmpl, nil)
+		return g.renderTemplate(helpTmpl, strings.TrimSuffix(helpText, "\n"))
 	}
 	commandStr := strings.Title(cmdName.String())
 	common := CommonData{commandStr, verbose, log}
@@ -101,9 +222,105 @@ func (g *MarkdownRenderer) Render(res CommandResponse, cmdName CommandName, log
 	if res.Failure != "" {
 		return g.renderTemplate(failureWithLogTmpl, FailureData{res.Failure, common})
 	}
+	if cmdName == VersionCheck {
+		return g.renderVersionCheck(res.ProjectResults, common)
+	}
+	if cmdName == Fmt {
+		return g.renderFmt(res.ProjectResults, common)
+	}
+	if cmdName == History {
+		return g.renderHistory(res.History, common)
+	}
 	return g.renderProjectResults(res.ProjectResults, common)
 }
 
+// renderVersionCheck renders the results of a version check as a single
+// table with a row per project.
+func (g *MarkdownRenderer) renderVersionCheck(pathResults []ProjectResult, common CommonData) string {
+	var rows []VersionCheckRow
+	for _, result := range pathResults {
+		row := VersionCheckRow{Path: result.Path}
+		if result.Error != nil {
+			row.Satisfied = ":x: " + strings.Replace(result.Error.Error(), "\n", " ", -1)
+		} else if result.VersionCheckSuccess != nil {
+			check := result.VersionCheckSuccess
+			row.Environment = check.Environment
+			row.Version = check.Version
+			row.Constraint = check.Constraint
+			if row.Constraint == "" {
+				row.Constraint = "none"
+			}
+			row.Satisfied = ":x:"
+			if check.Satisfied {
+				row.Satisfied = ":white_check_mark:"
+			}
+		} else {
+			row.Satisfied = "Found no template. This is a bug!"
+		}
+		rows = append(rows, row)
+	}
+	return g.renderTemplate(versionCheckTmpl, VersionCheckData{rows, common})
+}
+
+// renderFmt renders the results of terraform fmt as a single table with a row
+// per project.
+func (g *MarkdownRenderer) renderFmt(pathResults []ProjectResult, common CommonData) string {
+	data := FmtData{CommonData: common}
+	for _, result := range pathResults {
+		row := FmtRow{Path: result.Path}
+		if result.Error != nil {
+			row.Status = ":x: " + strings.Replace(result.Error.Error(), "\n", " ", -1)
+		} else if result.Failure != "" {
+			row.Status = ":x: " + result.Failure
+		} else if result.FmtSuccess != nil {
+			var files []string
+			for _, f := range result.FmtSuccess.Files {
+				files = append(files, "`"+f+"`")
+			}
+			row.Files = strings.Join(files, ", ")
+			switch {
+			case len(files) == 0:
+				row.Status = ":white_check_mark: formatted"
+			case result.FmtSuccess.Fixed:
+				row.Status = ":white_check_mark: fixed and pushed"
+			default:
+				row.Status = ":x: not formatted"
+				data.Unfixed = true
+			}
+		} else {
+			row.Status = "Found no template. This is a bug!"
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	return g.renderTemplate(fmtTmpl, data)
+}
+
+// renderHistory renders runs as a table with a row per run.
+func (g *MarkdownRenderer) renderHistory(runs []history.Run, common CommonData) string {
+	var rows []HistoryRow
+	for _, r := range runs {
+		command := r.Command
+		if r.Emergency {
+			command += " (emergency)"
+		}
+		if r.Ticket != "" {
+			command += " " + r.Ticket
+		}
+		if r.Exported {
+			command += " (exported)"
+		}
+		rows = append(rows, HistoryRow{
+			Time:        r.Time.UTC().Format("2006-01-02 15:04 MST"),
+			PullNum:     r.PullNum,
+			User:        r.User,
+			Environment: r.Environment,
+			Command:     command,
+			Outcome:     r.Outcome,
+		})
+	}
+	return g.renderTemplate(historyTmpl, HistoryData{rows, common})
+}
+
 func (g *MarkdownRenderer) renderProjectResults(pathResults []ProjectResult, common CommonData) string {
 	results := make(map[string]string)
 	for _, result := range pathResults {
@@ -123,13 +340,26 @@ func (g *MarkdownRenderer) renderProjectResults(pathResults []ProjectResult, com
 				Command: common.Command,
 				Failure: result.Failure,
 			})
+		} else if result.PlanSuccess != nil && result.PlanSuccess.NoChanges {
+			results[result.Path] = g.renderTemplate(planNoChangesTmpl, *result.PlanSuccess)
 		} else if result.PlanSuccess != nil {
 			results[result.Path] = g.renderTemplate(planSuccessTmpl, *result.PlanSuccess)
+		} else if result.ValidateSuccess != nil {
+			results[result.Path] = g.renderTemplate(validateSuccessTmpl, *result.ValidateSuccess)
 		} else if result.ApplySuccess != "" {
-			results[result.Path] = g.renderTemplate(applySuccessTmpl, struct{ Output string }{result.ApplySuccess})
+			results[result.Path] = g.renderTemplate(applySuccessTmpl, struct {
+				Output  string
+				Summary *PlanSummary
+			}{result.ApplySuccess, result.PlanSummary})
 		} else {
 			results[result.Path] = "Found no template. This is a bug!"
 		}
+		if result.RemoteRun != nil {
+			results[result.Path] += fmt.Sprintf("\n* This ran in Terraform Cloud as [%s](%s), which finished with status `%s`.", result.RemoteRun.ID, result.RemoteRun.URL, result.RemoteRun.Status)
+		}
+		if result.ChangedModule != "" {
+			results[result.Path] = fmt.Sprintf("Planned because it uses the modified module `%s`.\n\n", result.ChangedModule) + results[result.Path]
+		}
 	}
 
 	var tmpl *template.Template
@@ -142,6 +372,11 @@ func (g *MarkdownRenderer) renderProjectResults(pathResults []ProjectResult, com
 }
 
 func (g *MarkdownRenderer) renderTemplate(tmpl *template.Template, data interface{}) string {
+	if g.Format == PlainCommentFormat {
+		// The templates are shared so they're cloned before their
+		// functions are replaced.
+		tmpl = template.Must(tmpl.Clone()).Funcs(plainFuncs)
+	}
 	buf := &bytes.Buffer{}
 	if err := tmpl.Execute(buf, data); err != nil {
 		return fmt.Sprintf("Failed to render template, this is a bug: %v", err)
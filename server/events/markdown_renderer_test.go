@@ -3,8 +3,12 @@ package events_test
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/history"
+	"github.com/hootsuite/atlantis/server/events/tfc"
+	"github.com/hootsuite/atlantis/server/events/webhooks"
 	. "github.com/hootsuite/atlantis/testing"
 )
 
@@ -116,6 +120,134 @@ func TestRenderProjectResults(t *testing.T) {
 			},
 			"```diff\nterraform-output\n```\n\n* To **discard** this plan click [here](lock-url).\n\n",
 		},
+		{
+			"plan that destroys resources",
+			events.Plan,
+			[]events.ProjectResult{
+				{
+					PlanSuccess: &events.PlanSuccess{
+						TerraformOutput: "terraform-output",
+						LockURL:         "lock-url",
+						Destroyed:       []string{"aws_db_instance.db", "aws_s3_bucket.logs"},
+					},
+				},
+			},
+			"**Warning:** this plan destroys 2 resource(s):\n* `aws_db_instance.db`\n* `aws_s3_bucket.logs`\n\n```diff\nterraform-output\n```\n\n* To **discard** this plan click [here](lock-url).\n\n",
+		},
+		{
+			"plan of a project that uses a modified module",
+			events.Plan,
+			[]events.ProjectResult{
+				{
+					PlanSuccess: &events.PlanSuccess{
+						TerraformOutput: "terraform-output",
+						LockURL:         "lock-url",
+					},
+					ChangedModule: "modules/vpc",
+				},
+			},
+			"Planned because it uses the modified module `modules/vpc`.\n\n```diff\nterraform-output\n```\n\n* To **discard** this plan click [here](lock-url).\n\n",
+		},
+		{
+			"single plan with no changes",
+			events.Plan,
+			[]events.ProjectResult{
+				{
+					PlanSuccess: &events.PlanSuccess{
+						TerraformOutput: "terraform-output",
+						LockURL:         "lock-url",
+						NoChanges:       true,
+					},
+				},
+			},
+			"**No changes.** The infrastructure matches the configuration.\n\n* To **discard** this plan click [here](lock-url).\n\n",
+		},
+		{
+			"validate",
+			events.Validate,
+			[]events.ProjectResult{
+				{
+					Path:            "path",
+					ValidateSuccess: &events.ValidateSuccess{Output: "Warning: Deprecated attribute"},
+				},
+			},
+			"The configuration is valid.\n\n```\nWarning: Deprecated attribute\n```\n\n",
+		},
+		{
+			"single plan that was exported",
+			events.Plan,
+			[]events.ProjectResult{
+				{
+					PlanSuccess: &events.PlanSuccess{
+						TerraformOutput: "terraform-output",
+						LockURL:         "lock-url",
+						ExportURL:       "export-url",
+						ExportExpires:   time.Date(2018, 1, 2, 15, 4, 0, 0, time.UTC),
+					},
+				},
+			},
+			"```diff\nterraform-output\n```\n\n* To **discard** this plan click [here](lock-url).\n* To **download** the plan file click [here](export-url). The link expires at 2018-01-02 15:04 UTC.\n\n",
+		},
+		{
+			"version check",
+			events.VersionCheck,
+			[]events.ProjectResult{
+				{
+					Path: "path",
+					VersionCheckSuccess: &events.VersionCheckSuccess{
+						Environment: "production",
+						Version:     "0.11.1",
+						Constraint:  "~> 0.11.0",
+						Satisfied:   true,
+					},
+				},
+				{
+					Path: "path2",
+					VersionCheckSuccess: &events.VersionCheckSuccess{
+						Environment: "production",
+						Version:     "0.10.8",
+					},
+				},
+				{
+					Path:  "path3",
+					Error: errors.New("error"),
+				},
+			},
+			"| Project | Environment | Terraform Version | Required Version | Satisfied |\n" +
+				"|---|---|---|---|---|\n" +
+				"| `path` | production | 0.11.1 | ~> 0.11.0 | :white_check_mark: |\n" +
+				"| `path2` | production | 0.10.8 | none | :x: |\n" +
+				"| `path3` |  |  |  | :x: error |\n\n",
+		},
+		{
+			"fmt",
+			events.Fmt,
+			[]events.ProjectResult{
+				{
+					Path:       "path",
+					FmtSuccess: &events.FmtSuccess{},
+				},
+				{
+					Path:       "path2",
+					FmtSuccess: &events.FmtSuccess{Files: []string{"main.tf", "vars.tf"}},
+				},
+				{
+					Path:       "path3",
+					FmtSuccess: &events.FmtSuccess{Files: []string{"main.tf"}, Fixed: true},
+				},
+				{
+					Path:  "path4",
+					Error: errors.New("error"),
+				},
+			},
+			"| Project | Unformatted Files | Status |\n" +
+				"|---|---|---|\n" +
+				"| `path` |  | :white_check_mark: formatted |\n" +
+				"| `path2` | `main.tf`, `vars.tf` | :x: not formatted |\n" +
+				"| `path3` | `main.tf` | :white_check_mark: fixed and pushed |\n" +
+				"| `path4` |  | :x: error |\n" +
+				"\nComment `atlantis fmt --fix` to format these files and push the fixes to this branch.\n\n",
+		},
 		{
 			"single successful apply",
 			events.Apply,
@@ -126,6 +258,42 @@ func TestRenderProjectResults(t *testing.T) {
 			},
 			"```diff\nsuccess\n```\n\n",
 		},
+		{
+			"apply with a plan summary",
+			events.Apply,
+			[]events.ProjectResult{
+				{
+					ApplySuccess: "success",
+					PlanSummary:  &events.PlanSummary{Changes: webhooks.PlanChanges{Add: 1, Change: 2}},
+				},
+			},
+			"Applied a plan with 1 to add, 2 to change, 0 to destroy.\n\n```diff\nsuccess\n```\n\n",
+		},
+		{
+			"apply that destroyed resources",
+			events.Apply,
+			[]events.ProjectResult{
+				{
+					ApplySuccess: "success",
+					PlanSummary: &events.PlanSummary{
+						Changes:   webhooks.PlanChanges{Destroy: 2},
+						Destroyed: []string{"aws_db_instance.db", "aws_s3_bucket.logs"},
+					},
+				},
+			},
+			"Applied a plan with 0 to add, 0 to change, 2 to destroy.\n\n**Warning:** this apply destroyed 2 resource(s):\n* `aws_db_instance.db`\n* `aws_s3_bucket.logs`\n\n```diff\nsuccess\n```\n\n",
+		},
+		{
+			"apply in terraform cloud",
+			events.Apply,
+			[]events.ProjectResult{
+				{
+					ApplySuccess: "success",
+					RemoteRun:    &tfc.Run{ID: "run-1", Status: "applied", URL: "run-url"},
+				},
+			},
+			"```diff\nsuccess\n```\n* This ran in Terraform Cloud as [run-1](run-url), which finished with status `applied`.\n\n",
+		},
 		{
 			"multiple successful plans",
 			events.Plan,
@@ -241,3 +409,75 @@ func TestRenderProjectResults(t *testing.T) {
 		}
 	}
 }
+
+func TestRenderHistory(t *testing.T) {
+	r := events.MarkdownRenderer{}
+	t.Log("no runs should say so")
+	Equals(t, "No plans or applies have been run for this repo yet.\n\n",
+		r.Render(events.CommandResponse{}, events.History, "", false))
+
+	t.Log("runs should be rendered as a table")
+	res := events.CommandResponse{
+		History: []history.Run{
+			{
+				Time:         time.Date(2017, 10, 2, 15, 4, 0, 0, time.UTC),
+				RepoFullName: "owner/repo",
+				PullNum:      1,
+				User:         "lkysow",
+				Environment:  "production",
+				Command:      "apply",
+				Outcome:      "success",
+			},
+			{
+				Time:         time.Date(2017, 10, 1, 23, 0, 0, 0, time.UTC),
+				RepoFullName: "owner/repo",
+				PullNum:      2,
+				User:         "lkysow",
+				Environment:  "production",
+				Command:      "apply",
+				Outcome:      "failed",
+				Emergency:    true,
+				Ticket:       "CHG-1",
+			},
+			{
+				Time:         time.Date(2017, 10, 1, 22, 0, 0, 0, time.UTC),
+				RepoFullName: "owner/repo",
+				PullNum:      2,
+				User:         "lkysow",
+				Environment:  "production",
+				Command:      "plan",
+				Outcome:      "success",
+				Exported:     true,
+			},
+		},
+	}
+	Equals(t, "| Time | Pull Request | User | Environment | Command | Outcome |\n"+
+		"|---|---|---|---|---|---|\n"+
+		"| 2017-10-02 15:04 UTC | #1 | lkysow | production | apply | success |\n"+
+		"| 2017-10-01 23:00 UTC | #2 | lkysow | production | apply (emergency) CHG-1 | failed |\n"+
+		"| 2017-10-01 22:00 UTC | #2 | lkysow | production | plan (exported) | success |\n\n",
+		r.Render(res, events.History, "", false))
+}
+
+func TestRenderPlainFormat(t *testing.T) {
+	t.Log("the plain format shouldn't fence output or collapse the log")
+	r := events.MarkdownRenderer{Format: events.PlainCommentFormat}
+	res := events.CommandResponse{
+		ProjectResults: []events.ProjectResult{
+			{
+				Path:         "path",
+				ApplySuccess: "success",
+			},
+			{
+				Path:  "path2",
+				Error: errors.New("error"),
+			},
+		},
+	}
+	Equals(t, "Ran Apply in 2 directories:\n * `path`\n * `path2`\n\n## path/\nsuccess\n---\n## path2/\n**Apply Error**\nerror\n\n---\n\nLog:\nlog\n",
+		r.Render(res, events.Apply, "log", true))
+
+	t.Log("the markdown format should be unchanged by rendering plain")
+	Equals(t, "**Plan Error**\n```\nerr\n```\n\n",
+		(&events.MarkdownRenderer{}).Render(events.CommandResponse{Error: errors.New("err")}, events.Plan, "", false))
+}
@@ -4,6 +4,7 @@
 package mocks
 
 import (
+	context "context"
 	"reflect"
 
 	events "github.com/hootsuite/atlantis/server/events"
@@ -20,8 +21,8 @@ func NewMockCommitStatusUpdater() *MockCommitStatusUpdater {
 	return &MockCommitStatusUpdater{fail: pegomock.GlobalFailHandler}
 }
 
-func (mock *MockCommitStatusUpdater) Update(repo models.Repo, pull models.PullRequest, status vcs.CommitStatus, cmd *events.Command, host vcs.Host) error {
-	params := []pegomock.Param{repo, pull, status, cmd, host}
+func (mock *MockCommitStatusUpdater) Update(ctx context.Context, repo models.Repo, pull models.PullRequest, status vcs.CommitStatus, cmd *events.Command, host vcs.Host) error {
+	params := []pegomock.Param{ctx, repo, pull, status, cmd, host}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("Update", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 error
 	if len(result) != 0 {
@@ -62,8 +63,8 @@ type VerifierCommitStatusUpdater struct {
 	inOrderContext         *pegomock.InOrderContext
 }
 
-func (verifier *VerifierCommitStatusUpdater) Update(repo models.Repo, pull models.PullRequest, status vcs.CommitStatus, cmd *events.Command, host vcs.Host) *CommitStatusUpdater_Update_OngoingVerification {
-	params := []pegomock.Param{repo, pull, status, cmd, host}
+func (verifier *VerifierCommitStatusUpdater) Update(ctx context.Context, repo models.Repo, pull models.PullRequest, status vcs.CommitStatus, cmd *events.Command, host vcs.Host) *CommitStatusUpdater_Update_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, status, cmd, host}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Update", params)
 	return &CommitStatusUpdater_Update_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -73,33 +74,37 @@ type CommitStatusUpdater_Update_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *CommitStatusUpdater_Update_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, vcs.CommitStatus, *events.Command, vcs.Host) {
-	repo, pull, status, cmd, host := c.GetAllCapturedArguments()
-	return repo[len(repo)-1], pull[len(pull)-1], status[len(status)-1], cmd[len(cmd)-1], host[len(host)-1]
+func (c *CommitStatusUpdater_Update_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, vcs.CommitStatus, *events.Command, vcs.Host) {
+	ctx, repo, pull, status, cmd, host := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], status[len(status)-1], cmd[len(cmd)-1], host[len(host)-1]
 }
 
-func (c *CommitStatusUpdater_Update_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []vcs.CommitStatus, _param3 []*events.Command, _param4 []vcs.Host) {
+func (c *CommitStatusUpdater_Update_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []vcs.CommitStatus, _param4 []*events.Command, _param5 []vcs.Host) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]models.Repo, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(models.Repo)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]models.PullRequest, len(params[1]))
+		_param1 = make([]models.Repo, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(models.PullRequest)
+			_param1[u] = param.(models.Repo)
 		}
-		_param2 = make([]vcs.CommitStatus, len(params[2]))
+		_param2 = make([]models.PullRequest, len(params[2]))
 		for u, param := range params[2] {
-			_param2[u] = param.(vcs.CommitStatus)
+			_param2[u] = param.(models.PullRequest)
 		}
-		_param3 = make([]*events.Command, len(params[3]))
+		_param3 = make([]vcs.CommitStatus, len(params[3]))
 		for u, param := range params[3] {
-			_param3[u] = param.(*events.Command)
+			_param3[u] = param.(vcs.CommitStatus)
 		}
-		_param4 = make([]vcs.Host, len(params[4]))
+		_param4 = make([]*events.Command, len(params[4]))
 		for u, param := range params[4] {
-			_param4[u] = param.(vcs.Host)
+			_param4[u] = param.(*events.Command)
+		}
+		_param5 = make([]vcs.Host, len(params[5]))
+		for u, param := range params[5] {
+			_param5[u] = param.(vcs.Host)
 		}
 	}
 	return
@@ -4,6 +4,7 @@
 package mocks
 
 import (
+	context "context"
 	"reflect"
 
 	github "github.com/google/go-github/github"
@@ -19,8 +20,8 @@ func NewMockGithubPullGetter() *MockGithubPullGetter {
 	return &MockGithubPullGetter{fail: pegomock.GlobalFailHandler}
 }
 
-func (mock *MockGithubPullGetter) GetPullRequest(repo models.Repo, pullNum int) (*github.PullRequest, error) {
-	params := []pegomock.Param{repo, pullNum}
+func (mock *MockGithubPullGetter) GetPullRequest(ctx context.Context, repo models.Repo, pullNum int) (*github.PullRequest, error) {
+	params := []pegomock.Param{ctx, repo, pullNum}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("GetPullRequest", params, []reflect.Type{reflect.TypeOf((**github.PullRequest)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 *github.PullRequest
 	var ret1 error
@@ -53,8 +54,8 @@ type VerifierGithubPullGetter struct {
 	inOrderContext         *pegomock.InOrderContext
 }
 
-func (verifier *VerifierGithubPullGetter) GetPullRequest(repo models.Repo, pullNum int) *GithubPullGetter_GetPullRequest_OngoingVerification {
-	params := []pegomock.Param{repo, pullNum}
+func (verifier *VerifierGithubPullGetter) GetPullRequest(ctx context.Context, repo models.Repo, pullNum int) *GithubPullGetter_GetPullRequest_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pullNum}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetPullRequest", params)
 	return &GithubPullGetter_GetPullRequest_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -64,21 +65,25 @@ type GithubPullGetter_GetPullRequest_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *GithubPullGetter_GetPullRequest_OngoingVerification) GetCapturedArguments() (models.Repo, int) {
-	repo, pullNum := c.GetAllCapturedArguments()
-	return repo[len(repo)-1], pullNum[len(pullNum)-1]
+func (c *GithubPullGetter_GetPullRequest_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, int) {
+	ctx, repo, pullNum := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pullNum[len(pullNum)-1]
 }
 
-func (c *GithubPullGetter_GetPullRequest_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []int) {
+func (c *GithubPullGetter_GetPullRequest_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []int) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]models.Repo, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(models.Repo)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]int, len(params[1]))
+		_param1 = make([]models.Repo, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(int)
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]int, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(int)
 		}
 	}
 	return
@@ -4,6 +4,7 @@
 package mocks
 
 import (
+	context "context"
 	"reflect"
 
 	go_gitlab "github.com/lkysow/go-gitlab"
@@ -18,8 +19,8 @@ func NewMockGitlabMergeRequestGetter() *MockGitlabMergeRequestGetter {
 	return &MockGitlabMergeRequestGetter{fail: pegomock.GlobalFailHandler}
 }
 
-func (mock *MockGitlabMergeRequestGetter) GetMergeRequest(repoFullName string, pullNum int) (*go_gitlab.MergeRequest, error) {
-	params := []pegomock.Param{repoFullName, pullNum}
+func (mock *MockGitlabMergeRequestGetter) GetMergeRequest(ctx context.Context, repoFullName string, pullNum int) (*go_gitlab.MergeRequest, error) {
+	params := []pegomock.Param{ctx, repoFullName, pullNum}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("GetMergeRequest", params, []reflect.Type{reflect.TypeOf((**go_gitlab.MergeRequest)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 *go_gitlab.MergeRequest
 	var ret1 error
@@ -52,8 +53,8 @@ type VerifierGitlabMergeRequestGetter struct {
 	inOrderContext         *pegomock.InOrderContext
 }
 
-func (verifier *VerifierGitlabMergeRequestGetter) GetMergeRequest(repoFullName string, pullNum int) *GitlabMergeRequestGetter_GetMergeRequest_OngoingVerification {
-	params := []pegomock.Param{repoFullName, pullNum}
+func (verifier *VerifierGitlabMergeRequestGetter) GetMergeRequest(ctx context.Context, repoFullName string, pullNum int) *GitlabMergeRequestGetter_GetMergeRequest_OngoingVerification {
+	params := []pegomock.Param{ctx, repoFullName, pullNum}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetMergeRequest", params)
 	return &GitlabMergeRequestGetter_GetMergeRequest_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -63,21 +64,25 @@ type GitlabMergeRequestGetter_GetMergeRequest_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *GitlabMergeRequestGetter_GetMergeRequest_OngoingVerification) GetCapturedArguments() (string, int) {
-	repoFullName, pullNum := c.GetAllCapturedArguments()
-	return repoFullName[len(repoFullName)-1], pullNum[len(pullNum)-1]
+func (c *GitlabMergeRequestGetter_GetMergeRequest_OngoingVerification) GetCapturedArguments() (context.Context, string, int) {
+	ctx, repoFullName, pullNum := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repoFullName[len(repoFullName)-1], pullNum[len(pullNum)-1]
 }
 
-func (c *GitlabMergeRequestGetter_GetMergeRequest_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []int) {
+func (c *GitlabMergeRequestGetter_GetMergeRequest_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []string, _param2 []int) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]string, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(string)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]int, len(params[1]))
+		_param1 = make([]string, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(int)
+			_param1[u] = param.(string)
+		}
+		_param2 = make([]int, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(int)
 		}
 	}
 	return
@@ -97,6 +97,9 @@ type Plan struct {
 	// LocalPath is the absolute path to the plan on disk
 	// (versus the relative path from the repo root).
 	LocalPath string
+	// Encrypted is true if the plan is encrypted at rest, in which case it's
+	// only at LocalPath while it's being applied.
+	Encrypted bool
 }
 
 // NewProject constructs a Project. Use this constructor because it
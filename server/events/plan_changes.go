@@ -0,0 +1,109 @@
+package events
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/events/webhooks"
+	"github.com/pkg/errors"
+)
+
+// planChangesSuffix is appended to the path of a plan file to get the file
+// that its change counts are saved in so apply can report them before it
+// starts.
+const planChangesSuffix = ".changes"
+
+var planChangesRegex = regexp.MustCompile(`(\d+) to add, (\d+) to change, (\d+) to destroy`)
+
+// ParsePlanChanges returns the change counts in the summary line of terraform
+// plan output, ex. "Plan: 1 to add, 0 to change, 2 to destroy.", or nil if
+// there isn't one.
+func ParsePlanChanges(output string) *webhooks.PlanChanges {
+	match := planChangesRegex.FindStringSubmatch(output)
+	if match == nil {
+		return nil
+	}
+	// The regex only matches digits so the conversions can't fail.
+	add, _ := strconv.Atoi(match[1])
+	change, _ := strconv.Atoi(match[2])
+	destroy, _ := strconv.Atoi(match[3])
+	return &webhooks.PlanChanges{Add: add, Change: change, Destroy: destroy}
+}
+
+// savePlanChanges saves the change counts of the plan in planFile.
+func savePlanChanges(planFile string, changes webhooks.PlanChanges) error {
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		return errors.Wrap(err, "serializing plan changes")
+	}
+	return errors.Wrap(ioutil.WriteFile(planFile+planChangesSuffix, raw, 0600), "saving plan changes")
+}
+
+// loadPlanChanges returns the change counts saved for plan, or nil if they
+// weren't saved, ex. because it was made by an older version of Atlantis.
+func loadPlanChanges(plan models.Plan) *webhooks.PlanChanges {
+	raw, err := ioutil.ReadFile(plan.LocalPath + planChangesSuffix)
+	if err != nil {
+		return nil
+	}
+	var changes webhooks.PlanChanges
+	if err := json.Unmarshal(raw, &changes); err != nil {
+		return nil
+	}
+	return &changes
+}
+
+// totalPlanChanges returns the sum of the change counts saved for plans, or
+// nil if they weren't saved for every plan.
+func totalPlanChanges(plans []models.Plan) *webhooks.PlanChanges {
+	var total webhooks.PlanChanges
+	for _, plan := range plans {
+		changes := loadPlanChanges(plan)
+		if changes == nil {
+			return nil
+		}
+		total.Add += changes.Add
+		total.Change += changes.Change
+		total.Destroy += changes.Destroy
+	}
+	return &total
+}
+
+// PlanSummary is what a plan that's being applied changes, so the applier
+// sees the same changes that were approved.
+type PlanSummary struct {
+	Changes webhooks.PlanChanges
+	// Destroyed are the sorted addresses of the resources that the plan
+	// destroys, including the ones it replaces.
+	Destroyed []string
+}
+
+// ParsePlanSummary returns the summary of the plan in showOutput, the output
+// of terraform show -json. Like terraform's own summary, a replaced resource
+// counts as both added and destroyed.
+func ParsePlanSummary(showOutput string) (*PlanSummary, error) {
+	var plan planJSON
+	if err := json.Unmarshal([]byte(showOutput), &plan); err != nil {
+		return nil, errors.Wrap(err, "parsing plan json")
+	}
+	var summary PlanSummary
+	for _, rc := range plan.ResourceChanges {
+		for _, action := range rc.Change.Actions {
+			switch action {
+			case "create":
+				summary.Changes.Add++
+			case "update":
+				summary.Changes.Change++
+			case "delete":
+				summary.Changes.Destroy++
+				summary.Destroyed = append(summary.Destroyed, rc.Address)
+			}
+		}
+	}
+	sort.Strings(summary.Destroyed)
+	return &summary, nil
+}
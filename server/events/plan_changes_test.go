@@ -0,0 +1,30 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/webhooks"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestParsePlanChanges(t *testing.T) {
+	t.Log("the change counts should be parsed from the plan's summary line")
+	output := "+ aws_instance.web\n\nPlan: 1 to add, 0 to change, 12 to destroy.\n"
+	Equals(t, &webhooks.PlanChanges{Add: 1, Change: 0, Destroy: 12}, events.ParsePlanChanges(output))
+
+	t.Log("output without a summary line should have no changes")
+	Assert(t, events.ParsePlanChanges("Error: something went wrong") == nil, "exp nil changes")
+}
+
+func TestParsePlanSummary(t *testing.T) {
+	t.Log("replaced resources should count as added and destroyed")
+	summary, err := events.ParsePlanSummary(destroyPlanJSON)
+	Ok(t, err)
+	Equals(t, webhooks.PlanChanges{Add: 2, Change: 1, Destroy: 4}, summary.Changes)
+	Equals(t, []string{"aws_db_instance.db", "aws_s3_bucket.logs", "null_resource.trigger", "random_id.suffix"}, summary.Destroyed)
+
+	t.Log("output that isn't json should be an error")
+	_, err = events.ParsePlanSummary("Error: unknown flag -json")
+	Assert(t, err != nil, "exp an error")
+}
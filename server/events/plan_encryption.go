@@ -0,0 +1,133 @@
+package events
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// EncryptedPlanSuffix is added to the name of plan files that are encrypted,
+// ex. production.tfplan.enc.
+const EncryptedPlanSuffix = ".enc"
+
+// PlanEncryptor encrypts plan files at rest with AES-256-GCM since they can
+// contain sensitive values. Plans are only decrypted next to the encrypted
+// file while they're applied. Each plan is bound to its environment and its
+// path in the workspace so it can't be decrypted if it's copied to another
+// project or environment. A nil PlanEncryptor leaves plans unencrypted.
+type PlanEncryptor struct {
+	aead cipher.AEAD
+}
+
+// NewPlanEncryptor returns an encryptor for key, a base64 encoded 32 byte
+// key.
+func NewPlanEncryptor(key string) (*PlanEncryptor, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return nil, errors.New("must be a base64 encoded 32 byte key")
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &PlanEncryptor{aead: aead}, nil
+}
+
+// Encrypt replaces planFile, the plan for env in the workspace at repoDir,
+// with an encrypted copy named planFile plus EncryptedPlanSuffix.
+func (e *PlanEncryptor) Encrypt(repoDir string, env string, planFile string) error {
+	if e == nil {
+		return nil
+	}
+	additionalData, err := planAdditionalData(repoDir, env, planFile)
+	if err != nil {
+		return err
+	}
+	plaintext, err := ioutil.ReadFile(planFile)
+	if err != nil {
+		return errors.Wrap(err, "reading plan to encrypt")
+	}
+	ciphertext, err := e.seal(plaintext, additionalData)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(planFile+EncryptedPlanSuffix, ciphertext, 0600); err != nil {
+		return errors.Wrap(err, "writing encrypted plan")
+	}
+	if err := os.Remove(planFile); err != nil {
+		return errors.Wrap(err, "removing unencrypted plan")
+	}
+	return nil
+}
+
+// Decrypt decrypts the plan that Encrypt encrypted from planFile back into
+// planFile so terraform can read it. repoDir and env must be the ones it was
+// encrypted with. The returned func removes planFile again and must be called
+// once it's no longer needed.
+func (e *PlanEncryptor) Decrypt(repoDir string, env string, planFile string) (func(), error) {
+	if e == nil {
+		return nil, errors.New("plan is encrypted but --plan-encryption-key isn't set")
+	}
+	additionalData, err := planAdditionalData(repoDir, env, planFile)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := ioutil.ReadFile(planFile + EncryptedPlanSuffix)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading encrypted plan")
+	}
+	plaintext, err := e.open(ciphertext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(planFile, plaintext, 0600); err != nil {
+		return nil, errors.Wrap(err, "writing decrypted plan")
+	}
+	return func() { os.Remove(planFile) }, nil // nolint: errcheck
+}
+
+// planAdditionalData returns the data that the plan for env at planFile in
+// the workspace at repoDir is authenticated with, ex.
+// "plan\x00production\x00project/production.tfplan".
+func planAdditionalData(repoDir string, env string, planFile string) ([]byte, error) {
+	rel, err := filepath.Rel(repoDir, planFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding plan in workspace")
+	}
+	return []byte("plan\x00" + env + "\x00" + filepath.ToSlash(rel)), nil
+}
+
+// seal encrypts plaintext and authenticates it together with additionalData,
+// which isn't stored but has to be the same to open it.
+func (e *PlanEncryptor) seal(plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "generating nonce")
+	}
+	// The nonce is stored in front of the ciphertext since it's needed to
+	// decrypt it.
+	return e.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func (e *PlanEncryptor) open(ciphertext []byte, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < e.aead.NonceSize() {
+		return nil, errors.New("decrypting plan: file is too short")
+	}
+	nonce, ciphertext := ciphertext[:e.aead.NonceSize()], ciphertext[e.aead.NonceSize():]
+	plaintext, err := e.aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		// The error never says more than that authentication failed.
+		return nil, errors.New("decrypting plan: wrong key or the plan was modified or moved")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,214 @@
+package events_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/locking"
+	lmocks "github.com/hootsuite/atlantis/server/events/locking/mocks"
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/events/terraform"
+	"github.com/hootsuite/atlantis/server/events/vcs/mocks/matchers"
+	wmocks "github.com/hootsuite/atlantis/server/events/webhooks/mocks"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+var planKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("k"), 32))
+
+func TestPlanEncryptor_RoundTrip(t *testing.T) {
+	t.Log("a plan should only be readable once it's decrypted with the same key")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	planFile := filepath.Join(tmp, "production.tfplan")
+	Ok(t, ioutil.WriteFile(planFile, []byte("secret plan"), 0600))
+	e, err := events.NewPlanEncryptor(planKey)
+	Ok(t, err)
+
+	Ok(t, e.Encrypt(tmp, "production", planFile))
+	_, err = os.Stat(planFile)
+	Assert(t, os.IsNotExist(err), "exp the unencrypted plan to be removed")
+	ciphertext, err := ioutil.ReadFile(planFile + events.EncryptedPlanSuffix)
+	Ok(t, err)
+	Assert(t, !bytes.Contains(ciphertext, []byte("secret plan")), "exp the plan to be encrypted")
+
+	cleanup, err := e.Decrypt(tmp, "production", planFile)
+	Ok(t, err)
+	plaintext, err := ioutil.ReadFile(planFile)
+	Ok(t, err)
+	Equals(t, "secret plan", string(plaintext))
+	cleanup()
+	_, err = os.Stat(planFile)
+	Assert(t, os.IsNotExist(err), "exp the decrypted plan to be removed")
+
+	t.Log("another key shouldn't be able to decrypt it")
+	other, err := events.NewPlanEncryptor(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("o"), 32)))
+	Ok(t, err)
+	_, err = other.Decrypt(tmp, "production", planFile)
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "decrypting plan: wrong key or the plan was modified or moved", err.Error())
+
+	t.Log("without a key it should be an error")
+	var none *events.PlanEncryptor
+	_, err = none.Decrypt(tmp, "production", planFile)
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "plan is encrypted but --plan-encryption-key isn't set", err.Error())
+}
+
+func TestPlanEncryptor_Moved(t *testing.T) {
+	t.Log("a plan copied to another project or environment shouldn't decrypt")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	e, err := events.NewPlanEncryptor(planKey)
+	Ok(t, err)
+	staging := filepath.Join(tmp, "staging")
+	planFile := filepath.Join(staging, "a", "production.tfplan")
+	Ok(t, os.MkdirAll(filepath.Dir(planFile), 0700))
+	Ok(t, ioutil.WriteFile(planFile, []byte("secret plan"), 0600))
+	Ok(t, e.Encrypt(staging, "staging", planFile))
+	ciphertext, err := ioutil.ReadFile(planFile + events.EncryptedPlanSuffix)
+	Ok(t, err)
+
+	production := filepath.Join(tmp, "production")
+	for _, c := range []struct {
+		description string
+		repoDir     string
+		env         string
+		planFile    string
+	}{
+		{"another project", staging, "staging", filepath.Join(staging, "b", "production.tfplan")},
+		{"another environment", production, "production", filepath.Join(production, "a", "production.tfplan")},
+	} {
+		t.Log(c.description)
+		Ok(t, os.MkdirAll(filepath.Dir(c.planFile), 0700))
+		Ok(t, ioutil.WriteFile(c.planFile+events.EncryptedPlanSuffix, ciphertext, 0600))
+		_, err := e.Decrypt(c.repoDir, c.env, c.planFile)
+		Assert(t, err != nil, "exp an error")
+		Equals(t, "decrypting plan: wrong key or the plan was modified or moved", err.Error())
+		_, err = os.Stat(c.planFile)
+		Assert(t, os.IsNotExist(err), "exp no decrypted plan")
+	}
+
+	t.Log("where it was encrypted it should still decrypt")
+	cleanup, err := e.Decrypt(staging, "staging", planFile)
+	Ok(t, err)
+	cleanup()
+}
+
+func TestNewPlanEncryptor_InvalidKey(t *testing.T) {
+	t.Log("keys that aren't 32 base64 encoded bytes should be an error")
+	for _, key := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		_, err := events.NewPlanEncryptor(key)
+		Assert(t, err != nil, "exp an error for %q", key)
+		Equals(t, "must be a base64 encoded 32 byte key", err.Error())
+	}
+}
+
+func TestApplyExecutor_EncryptedPlan(t *testing.T) {
+	t.Log("an encrypted plan should be found and decrypted for terraform apply")
+	RegisterMockTestingT(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	// The fake terraform prints the plan it's asked to apply.
+	binDir := filepath.Join(tmp, "bin")
+	Ok(t, os.MkdirAll(binDir, 0700))
+	script := "#!/bin/sh\ncase \"$1\" in\nversion) echo 'Terraform v0.11.0';;\napply) for last; do :; done; cat \"$last\";;\nesac\n"
+	Ok(t, ioutil.WriteFile(filepath.Join(binDir, "terraform"), []byte(script), 0755))
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path) // nolint: errcheck
+	Ok(t, os.Setenv("PATH", binDir+string(os.PathListSeparator)+path))
+	tf, err := terraform.NewClient()
+	Ok(t, err)
+
+	planFile := filepath.Join(tmp, "repos", "owner/repo", "1", "production", "a", "production.tfplan")
+	Ok(t, os.MkdirAll(filepath.Dir(planFile), 0700))
+	Ok(t, ioutil.WriteFile(planFile, []byte("planned changes"), 0600))
+	encryptor, err := events.NewPlanEncryptor(planKey)
+	Ok(t, err)
+	Ok(t, encryptor.Encrypt(filepath.Join(tmp, "repos", "owner/repo", "1", "production"), "production", planFile))
+
+	locker := lmocks.NewMockLocker()
+	When(locker.TryLock(models.NewProject("owner/repo", "a"), "production", applyCtx.Pull, applyCtx.User)).
+		ThenReturn(locking.TryLockResponse{LockAcquired: true}, nil)
+	a := &events.ApplyExecutor{
+		Terraform: tf,
+		Workspace: &events.FileWorkspace{DataDir: tmp},
+		ProjectPreExecute: &events.ProjectPreExecute{
+			Locker:       locker,
+			ConfigReader: &events.ProjectConfigManager{},
+			Terraform:    tf,
+		},
+		Webhooks:      wmocks.NewMockSender(),
+		PlanEncryptor: encryptor,
+	}
+
+	r := a.Execute(&applyCtx)
+	Equals(t, 1, len(r.ProjectResults))
+	Ok(t, r.ProjectResults[0].Error)
+	Equals(t, "planned changes", r.ProjectResults[0].ApplySuccess)
+	Equals(t, planFile, r.ProjectResults[0].Path)
+	_, err = os.Stat(planFile)
+	Assert(t, os.IsNotExist(err), "exp the decrypted plan to be removed after the apply")
+}
+
+func TestApplyExecutor_UnencryptedPlanWithKey(t *testing.T) {
+	t.Log("with a key, an unencrypted plan should be removed instead of applied")
+	RegisterMockTestingT(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	planFile := filepath.Join(tmp, "repos", "owner/repo", "1", "production", "a", "production.tfplan")
+	Ok(t, os.MkdirAll(filepath.Dir(planFile), 0700))
+	Ok(t, ioutil.WriteFile(planFile, []byte("planned changes"), 0600))
+	encryptor, err := events.NewPlanEncryptor(planKey)
+	Ok(t, err)
+	a := &events.ApplyExecutor{
+		Workspace:     &events.FileWorkspace{DataDir: tmp},
+		Webhooks:      wmocks.NewMockSender(),
+		PlanEncryptor: encryptor,
+	}
+
+	r := a.Execute(&applyCtx)
+	Equals(t, "No plans found for that environment.", r.Failure)
+	_, err = os.Stat(planFile)
+	Assert(t, os.IsNotExist(err), "exp the unencrypted plan to be removed")
+}
+
+func TestPlanExecutor_EncryptsFailedPlan(t *testing.T) {
+	t.Log("a plan should be encrypted even if a post plan command fails after terraform wrote it")
+	p, _, _ := setupPlanExecutorTest(t)
+	cloneDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(cloneDir) // nolint: errcheck
+	planFile := filepath.Join(cloneDir, "env.tfplan")
+	// The mock terraform doesn't write the plan so it's written up front.
+	Ok(t, ioutil.WriteFile(planFile, []byte("secret plan"), 0600))
+	p.PlanEncryptor, err = events.NewPlanEncryptor(planKey)
+	Ok(t, err)
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "env")).ThenReturn(cloneDir, nil)
+	When(p.ProjectPreExecute.Execute(&planCtx, cloneDir, models.Project{RepoFullName: "", Path: "."})).
+		ThenReturn(events.PreExecuteResult{
+			ProjectConfig: events.ProjectConfig{PostPlan: []string{"post-plan"}},
+		})
+	When(p.Run.Execute(planCtx.Log, []string{"post-plan"}, cloneDir, "env", nil, "post_plan", "")).
+		ThenReturn("", errors.New("err"))
+
+	r := p.Execute(&planCtx)
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "running post plan commands: err", r.ProjectResults[0].Error.Error())
+	_, err = os.Stat(planFile)
+	Assert(t, os.IsNotExist(err), "exp the unencrypted plan to be removed")
+	ciphertext, err := ioutil.ReadFile(planFile + events.EncryptedPlanSuffix)
+	Ok(t, err)
+	Assert(t, !bytes.Contains(ciphertext, []byte("secret plan")), "exp the plan to be encrypted")
+}
@@ -2,15 +2,19 @@ package events
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/hootsuite/atlantis/server/events/locking"
 	"github.com/hootsuite/atlantis/server/events/models"
 	"github.com/hootsuite/atlantis/server/events/run"
 	"github.com/hootsuite/atlantis/server/events/terraform"
+	"github.com/hootsuite/atlantis/server/events/tfc"
 	"github.com/hootsuite/atlantis/server/events/vcs"
+	"github.com/hootsuite/atlantis/server/events/webhooks"
+	"github.com/hootsuite/atlantis/server/tracing"
 	"github.com/pkg/errors"
 )
 
@@ -26,24 +30,70 @@ type LockURLGenerator interface {
 // with, containing the vcs username of who is running the command
 const atlantisUserTFVar = "atlantis_user"
 
+// planHasChangesExitCode is what terraform plan -detailed-exitcode exits with
+// when the plan succeeded and has changes.
+const planHasChangesExitCode = 2
+
 // PlanExecutor handles everything related to running terraform plan.
 type PlanExecutor struct {
-	VCSClient               vcs.ClientProxy
-	Terraform               terraform.Runner
-	Locker                  locking.Locker
-	LockURL                 func(id string) (url string)
-	Run                     run.Runner
-	Workspace               Workspace
-	ProjectPreExecute       ProjectPreExecutor
-	ProjectFinder           ModifiedProjectFinder
-	ConfiguredWorkflow      Workflow
-	GitflowEnvDir           string
-	GitflowEnvBranchMapping []string
+	VCSClient         vcs.ClientProxy
+	Terraform         terraform.Runner
+	Locker            locking.Locker
+	LockURL           func(id string) (url string)
+	Run               run.Runner
+	Workspace         Workspace
+	ProjectPreExecute ProjectPreExecutor
+	// ConfigReader is used to find projects that users refer to by name.
+	ConfigReader ProjectConfigReader
+	// DependentFinder, if set, is used to also plan the projects that use a
+	// modified module.
+	DependentFinder *DependentProjectFinder
+	// EnvDetector determines the projects to plan for the configured
+	// workflow.
+	EnvDetector        EnvDetector
+	ConfiguredWorkflow Workflow
+	SecretVarFiles     SecretVarFiles
+	// TFC, if set, runs the plans of projects with a remote backend in
+	// Terraform Cloud instead of locally.
+	TFC *tfc.Client
+	// TFCWorkspaces maps an environment to the Terraform Cloud workspace its
+	// plans run in.
+	TFCWorkspaces map[string]string
+	// CommandMetrics, if set, records the result of each project's plan and
+	// how long terraform took to plan it.
+	CommandMetrics *CommandMetrics
+	// Exporter, if set, lets its users download plan files with
+	// plan --export.
+	Exporter *PlanExporter
+	// PlanEncryptor, if set, encrypts plan files once they're no longer
+	// needed by the plan.
+	PlanEncryptor *PlanEncryptor
+	// Terragrunt, if set, plans the terragrunt modules affected by the pull
+	// request, in dependency order, instead of the projects with modified
+	// .tf files. It's only used with the modified files workflow.
+	Terragrunt *Terragrunt
+	// DestroyWarning, if set, is used to warn about the resources that plans
+	// destroy.
+	DestroyWarning *DestroyWarning
+	// ProjectLimit is the most projects that a plan can run in.
+	ProjectLimit *ProjectLimit
+	// ApplyRollup, if set, is told which projects have nothing left to
+	// apply.
+	ApplyRollup *ApplyRollup
 }
 
 type PlanSuccess struct {
 	TerraformOutput string
 	LockURL         string
+	// NoChanges is true if the plan has nothing to change.
+	NoChanges bool
+	// ExportURL is the link to download the plan file if it was exported.
+	ExportURL string
+	// ExportExpires is when ExportURL stops working.
+	ExportExpires time.Time
+	// Destroyed are the addresses of the resources that the plan destroys,
+	// if destroy warnings are enabled.
+	Destroyed []string
 }
 
 func (p *PlanExecutor) SetLockURL(f func(id string) (url string)) {
@@ -51,47 +101,33 @@ func (p *PlanExecutor) SetLockURL(f func(id string) (url string)) {
 }
 
 func (p *PlanExecutor) Execute(ctx *CommandContext) CommandResponse {
-	var projects []models.Project
+	if ctx.Command.Export {
+		if p.Exporter == nil {
+			return CommandResponse{Failure: "Exporting plans is disabled on this Atlantis server."}
+		}
+		if !p.Exporter.IsAllowed(ctx.User.Username) {
+			return CommandResponse{Failure: fmt.Sprintf("%s is not allowed to export plans.", ctx.User.Username)}
+		}
+	}
 
-	if p.ConfiguredWorkflow == ModifiedFilesWorkflow {
-		// figure out what projects have been modified so we know where to run plan
-		modifiedFiles, err := p.VCSClient.GetModifiedFiles(ctx.BaseRepo, ctx.Pull, ctx.VCSHost)
+	var projects []models.Project
+	var modifiedFiles []string
+	// Named projects are looked up in the clone so we only determine the
+	// projects up front if the user didn't name one.
+	if ctx.Command.ProjectName == "" {
+		var err error
+		projects, modifiedFiles, err = p.determineProjects(ctx)
 		if err != nil {
-			return CommandResponse{Error: errors.Wrap(err, "getting modified files")}
-		}
-		ctx.Log.Info("found %d files modified in this pull request", len(modifiedFiles))
-		projects = p.ProjectFinder.FindModified(ctx.Log, modifiedFiles, ctx.BaseRepo.FullName)
-
-	} else if p.ConfiguredWorkflow == GitFlowWorkflow {
-		// Check if we have a special mapping for this branch
-		// If there are multiple mappings, multiple projects will be created
-		var env, branch string
-		var found bool
-		for _, m := range p.GitflowEnvBranchMapping {
-			parts := strings.Split(m, ":")
-			env = parts[0]
-			branch = parts[1]
-
-			if branch == ctx.Pull.BaseBranch {
-				found = true
-				projects = append(projects, models.NewProject(ctx.BaseRepo.FullName, filepath.Join(
-					p.GitflowEnvDir, env)))
-				ctx.Log.Info("created new project %s, env path: %s", ctx.BaseRepo.FullName, filepath.Join(
-					p.GitflowEnvDir, env))
-			}
-
+			return CommandResponse{Error: err}
 		}
-		// If no mappings are found, use the default PR base branch
-		if !found {
-			projects = append(projects, models.NewProject(ctx.BaseRepo.FullName, filepath.Join(
-				p.GitflowEnvDir, ctx.Pull.BaseBranch)))
-			ctx.Log.Info("created new project %s, env path: %s", ctx.BaseRepo.FullName, filepath.Join(
-				p.GitflowEnvDir, ctx.Pull.BaseBranch))
+		// If only modules were modified, the projects that use them can
+		// only be found once we've cloned. So can terragrunt modules.
+		if len(projects) == 0 && (p.DependentFinder == nil && p.Terragrunt == nil || len(modifiedFiles) == 0) {
+			return CommandResponse{Failure: "No Terraform files were modified."}
 		}
 	}
-
-	if len(projects) == 0 {
-		return CommandResponse{Failure: "No Terraform files were modified."}
+	if p.Terragrunt != nil && !p.Terragrunt.MapsEnvironment(ctx.Command.Environment) {
+		return CommandResponse{Failure: fmt.Sprintf("No terragrunt directory is mapped to the %s environment.", ctx.Command.Environment)}
 	}
 
 	cloneDir, err := p.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, ctx.Command.Environment)
@@ -99,19 +135,92 @@ func (p *PlanExecutor) Execute(ctx *CommandContext) CommandResponse {
 		return CommandResponse{Error: err}
 	}
 
+	if ctx.Command.ProjectName != "" {
+		path, err := FindProjectByName(p.ConfigReader, cloneDir, ctx.Command.ProjectName)
+		if err != nil {
+			return CommandResponse{Failure: err.Error()}
+		}
+		ctx.Log.Info("project %q is at path %q", ctx.Command.ProjectName, path)
+		projects = []models.Project{models.NewProject(ctx.BaseRepo.FullName, path)}
+	}
+
+	if p.Terragrunt != nil && ctx.Command.ProjectName == "" && p.ConfiguredWorkflow == ModifiedFilesWorkflow {
+		projects, err = p.Terragrunt.ModifiedModules(ctx.Log, cloneDir, modifiedFiles, ctx.Command.Environment, ctx.BaseRepo.FullName)
+		if err != nil {
+			return CommandResponse{Error: err}
+		}
+		if len(projects) == 0 {
+			return CommandResponse{Failure: "No terragrunt modules were affected."}
+		}
+	}
+
+	// changedModules are the modules that made us plan each dependent
+	// project, by its path.
+	changedModules := make(map[string]string)
+	if p.DependentFinder != nil && p.Terragrunt == nil && len(modifiedFiles) > 0 {
+		dependents, err := p.DependentFinder.FindDependents(ctx.Log, cloneDir, modifiedFiles, projects, ctx.BaseRepo.FullName)
+		if err != nil {
+			return CommandResponse{Error: err}
+		}
+		for _, dependent := range dependents {
+			projects = append(projects, dependent.Project)
+			changedModules[dependent.Project.Path] = dependent.Module
+		}
+		if len(projects) == 0 {
+			return CommandResponse{Failure: "No Terraform files were modified."}
+		}
+	}
+
+	if failure := p.ProjectLimit.Check(ctx.BaseRepo.FullName, len(projects)); failure != "" {
+		return CommandResponse{Failure: failure}
+	}
+
 	results := []ProjectResult{}
 	for _, project := range projects {
 		ctx.Log.Info("running plan for project at path %q", project.Path)
 		result := p.plan(ctx, cloneDir, project)
 		result.Path = project.Path
+		result.ChangedModule = changedModules[project.Path]
+		p.CommandMetrics.ObserveResult(Plan, ctx.Command.Environment, result)
 		results = append(results, result)
 	}
+	p.ApplyRollup.Record(ctx, planApplied(results))
 	return CommandResponse{ProjectResults: results}
 }
 
+// planApplied returns whether each successfully planned project is applied,
+// which is when its plan has no changes. Failed plans don't change whether a
+// project was applied.
+func planApplied(results []ProjectResult) map[string]bool {
+	applied := make(map[string]bool)
+	for _, result := range results {
+		if result.PlanSuccess != nil {
+			applied[result.Path] = result.PlanSuccess.NoChanges
+		}
+	}
+	return applied
+}
+
+// DetermineProjects returns the projects that commands for this pull request
+// should run in, based on the configured workflow.
+func (p *PlanExecutor) DetermineProjects(ctx *CommandContext) ([]models.Project, error) {
+	projects, _, err := p.determineProjects(ctx)
+	return projects, err
+}
+
+// determineProjects is DetermineProjects but also returns the files modified
+// in the pull request if the workflow uses them.
+func (p *PlanExecutor) determineProjects(ctx *CommandContext) ([]models.Project, []string, error) {
+	_, span := tracing.Start(ctx.Context, "detect projects")
+	defer span.End()
+	projects, modifiedFiles, err := p.EnvDetector.Detect(ctx)
+	span.SetError(err)
+	return projects, modifiedFiles, err
+}
+
 // plan runs the steps necessary to run `terraform plan`. If there is an error, the error message will be encapsulated in error
 // and the GeneratePlanResponse struct will also contain the full log including the error
-func (p *PlanExecutor) plan(ctx *CommandContext, repoDir string, project models.Project) ProjectResult {
+func (p *PlanExecutor) plan(ctx *CommandContext, repoDir string, project models.Project) (result ProjectResult) {
 	preExecute := p.ProjectPreExecute.Execute(ctx, repoDir, project)
 	if preExecute.ProjectResult != (ProjectResult{}) {
 		return preExecute.ProjectResult
@@ -122,38 +231,179 @@ func (p *PlanExecutor) plan(ctx *CommandContext, repoDir string, project models.
 
 	// Run terraform plan
 	planFile := filepath.Join(repoDir, project.Path, fmt.Sprintf("%s.tfplan", tfEnv))
+	// However planning ends, ex. if a post plan command fails after
+	// terraform wrote the plan, the plan isn't left unencrypted.
+	defer func() {
+		if err := p.encryptPlan(repoDir, tfEnv, planFile); err != nil {
+			if result.Error != nil || result.Failure != "" {
+				ctx.Log.Err("unable to encrypt plan: %s", err)
+				return
+			}
+			result = ProjectResult{Error: err}
+		}
+	}()
 	userVar := fmt.Sprintf("%s=%s", atlantisUserTFVar, ctx.User.Username)
-	planExtraArgs := config.GetExtraArguments(ctx.Command.Name.String())
-	tfPlanCmd := append(append([]string{"plan", "-refresh", "-no-color", "-out", planFile, "-var", userVar}, planExtraArgs...), ctx.Command.Flags...)
+	planExtraArgs := config.GetExtraArgumentsForEnv(ctx.Command.Name.String(), tfEnv)
+	tfPlanCmd := append(append([]string{"plan", "-refresh", "-no-color", "-detailed-exitcode", "-out", planFile, "-var", userVar}, planExtraArgs...), ctx.Command.Flags...)
+
+	if p.TFC != nil && tfc.UsesRemoteBackend(filepath.Join(repoDir, project.Path)) {
+		return p.remotePlan(ctx, repoDir, project, preExecute.LockResponse.LockKey, planFile)
+	}
 
 	// check if env/{environment}.tfvars exist
 	tfEnvFileName := filepath.Join("env", tfEnv+".tfvars")
 	if _, err := os.Stat(filepath.Join(repoDir, project.Path, tfEnvFileName)); err == nil {
 		tfPlanCmd = append(tfPlanCmd, "-var-file", tfEnvFileName)
 	}
-	output, err := p.Terraform.RunCommandWithVersion(ctx.Log, filepath.Join(repoDir, project.Path), tfPlanCmd, terraformVersion, tfEnv)
+	// The secret var file comes last so it overrides the repo's var files.
+	// Apply doesn't need it since the values are saved in the plan.
+	secretVarFile, secrets, err := p.SecretVarFiles.Load(tfEnv)
+	if err != nil {
+		if _, unlockErr := p.Locker.Unlock(preExecute.LockResponse.LockKey); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
+		}
+		return ProjectResult{Error: err}
+	}
+	if secretVarFile != "" {
+		tfPlanCmd = append(tfPlanCmd, "-var-file", secretVarFile)
+	}
+	_, span := tracing.Start(ctx.Context, "terraform plan")
+	span.SetAttribute("atlantis.project", project.Path)
+	start := time.Now()
+	output, err := p.Terraform.RunCommandWithVersion(ctx.Log, filepath.Join(repoDir, project.Path), tfPlanCmd, terraformVersion, tfEnv, config.Command, ctx.AWSProfile)
+	p.CommandMetrics.ObserveTerraform(Plan, tfEnv, time.Since(start))
+	span.SetError(err)
+	span.End()
+	output = RedactSecrets(output, secrets)
+	// With -detailed-exitcode, terraform exits 0 if there are no changes and
+	// 2 if there are changes.
+	noChanges := err == nil
+	if cmdErr, ok := err.(*terraform.CommandError); ok && cmdErr.ExitCode() == planHasChangesExitCode {
+		err = nil
+	}
 	if err != nil {
 		// plan failed so unlock the state
 		if _, unlockErr := p.Locker.Unlock(preExecute.LockResponse.LockKey); unlockErr != nil {
 			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
 		}
-		return ProjectResult{Error: fmt.Errorf("%s\n%s", err.Error(), output)}
+		if notFoundErr, ok := err.(*terraform.ExecutableNotFoundError); ok {
+			return ProjectResult{Failure: notFoundErr.Error()}
+		}
+		return ProjectResult{Error: fmt.Errorf("%s\n%s", RedactSecrets(err.Error(), secrets), output)}
 	}
 	ctx.Log.Info("plan succeeded")
 
 	// if there are post plan commands then run them
 	if len(config.PostPlan) > 0 {
 		absolutePath := filepath.Join(repoDir, project.Path)
-		_, err := p.Run.Execute(ctx.Log, config.PostPlan, absolutePath, tfEnv, terraformVersion, "post_plan")
+		_, err := p.Run.Execute(ctx.Log, config.PostPlan, absolutePath, tfEnv, terraformVersion, "post_plan", ctx.AWSProfile)
 		if err != nil {
 			return ProjectResult{Error: errors.Wrap(err, "running post plan commands")}
 		}
 	}
 
+	planSuccess := &PlanSuccess{
+		TerraformOutput: output,
+		LockURL:         p.LockURL(preExecute.LockResponse.LockKey),
+		NoChanges:       noChanges,
+	}
+	p.saveChanges(ctx, planFile, output, noChanges)
+	if p.DestroyWarning != nil && !noChanges {
+		// The warning is only informational so the plan isn't failed if the
+		// plan file can't be read.
+		showOutput, err := p.Terraform.RunCommandWithVersion(ctx.Log, filepath.Join(repoDir, project.Path), []string{"show", "-json", planFile}, terraformVersion, tfEnv, config.Command, ctx.AWSProfile)
+		if err == nil {
+			planSuccess.Destroyed, err = p.DestroyWarning.Destroyed(showOutput)
+		}
+		if err != nil {
+			ctx.Log.Warn("unable to check the plan for destroyed resources: %s", err)
+		}
+	}
+	if ctx.Command.Export {
+		planSuccess.ExportURL, planSuccess.ExportExpires, err = p.Exporter.Export(planFile)
+		if err != nil {
+			return ProjectResult{Error: err}
+		}
+		// The link isn't logged since anyone with it can download the plan.
+		ctx.Log.Info("exported plan until %s", planSuccess.ExportExpires.UTC().Format(time.RFC3339))
+	}
+	return ProjectResult{PlanSuccess: planSuccess}
+}
+
+// encryptPlan encrypts planFile, the plan for env in the workspace at
+// repoDir, if plans are encrypted and terraform wrote it. If it can't be
+// encrypted it's removed instead.
+func (p *PlanExecutor) encryptPlan(repoDir string, env string, planFile string) error {
+	if p.PlanEncryptor == nil {
+		return nil
+	}
+	if _, err := os.Stat(planFile); os.IsNotExist(err) {
+		return nil
+	}
+	if err := p.PlanEncryptor.Encrypt(repoDir, env, planFile); err != nil {
+		os.Remove(planFile) // nolint: errcheck
+		return err
+	}
+	return nil
+}
+
+// remotePlan runs the plan in the Terraform Cloud workspace that the
+// environment is mapped to instead of running terraform locally. The run's ID
+// is saved in planFile so apply can find the run and apply exactly what was
+// planned.
+func (p *PlanExecutor) remotePlan(ctx *CommandContext, repoDir string, project models.Project, lockKey string, planFile string) ProjectResult {
+	unlock := func() {
+		if _, unlockErr := p.Locker.Unlock(lockKey); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
+		}
+	}
+	workspace, ok := p.TFCWorkspaces[ctx.Command.Environment]
+	if !ok {
+		unlock()
+		return ProjectResult{Failure: fmt.Sprintf("No Terraform Cloud workspace is mapped to the %s environment.", ctx.Command.Environment)}
+	}
+
+	_, span := tracing.Start(ctx.Context, "terraform cloud plan")
+	span.SetAttribute("atlantis.project", project.Path)
+	message := fmt.Sprintf("Planned by Atlantis for %s#%d by %s", ctx.BaseRepo.FullName, ctx.Pull.Num, ctx.User.Username)
+	run, err := p.TFC.Plan(ctx.Context, workspace, repoDir, project.Path, message)
+	span.SetError(err)
+	span.End()
+	if err != nil {
+		unlock()
+		return ProjectResult{Error: errors.Wrap(err, "running plan in terraform cloud")}
+	}
+	ctx.Log.Info("terraform cloud run %s finished planning with status %s", run.ID, run.Status)
+	if !run.Planned() {
+		unlock()
+		return ProjectResult{Error: fmt.Errorf("terraform cloud run %s\n%s", run.Status, run.Log), RemoteRun: &run}
+	}
+	if err := ioutil.WriteFile(planFile, []byte(run.ID), 0600); err != nil {
+		unlock()
+		return ProjectResult{Error: errors.Wrap(err, "saving terraform cloud run id")}
+	}
+	p.saveChanges(ctx, planFile, run.Log, !run.HasChanges)
 	return ProjectResult{
 		PlanSuccess: &PlanSuccess{
-			TerraformOutput: output,
-			LockURL:         p.LockURL(preExecute.LockResponse.LockKey),
+			TerraformOutput: run.Log,
+			LockURL:         p.LockURL(lockKey),
+			NoChanges:       !run.HasChanges,
 		},
+		RemoteRun: &run,
+	}
+}
+
+// saveChanges saves the change counts of the plan in output next to planFile
+// for the pre-apply webhook. They're only informational so errors are logged.
+func (p *PlanExecutor) saveChanges(ctx *CommandContext, planFile string, output string, noChanges bool) {
+	changes := ParsePlanChanges(output)
+	if noChanges {
+		changes = &webhooks.PlanChanges{}
+	}
+	if changes == nil {
+		return
+	}
+	if err := savePlanChanges(planFile, *changes); err != nil {
+		ctx.Log.Warn("unable to save the plan's changes: %s", err)
 	}
 }
@@ -1,7 +1,13 @@
 package events_test
 
 import (
+	"bytes"
 	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/hootsuite/atlantis/server/events"
@@ -10,6 +16,7 @@ import (
 	"github.com/hootsuite/atlantis/server/events/mocks"
 	"github.com/hootsuite/atlantis/server/events/models"
 	rmocks "github.com/hootsuite/atlantis/server/events/run/mocks"
+	"github.com/hootsuite/atlantis/server/events/terraform"
 	tmocks "github.com/hootsuite/atlantis/server/events/terraform/mocks"
 	vcsmocks "github.com/hootsuite/atlantis/server/events/vcs/mocks"
 	"github.com/hootsuite/atlantis/server/events/vcs/mocks/matchers"
@@ -35,7 +42,7 @@ var planCtx = events.CommandContext{
 func TestExecute_ModifiedFilesErr(t *testing.T) {
 	t.Log("If GetModifiedFiles returns an error we return an error")
 	p, _, _ := setupPlanExecutorTest(t)
-	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn(nil, errors.New("err"))
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn(nil, errors.New("err"))
 	r := p.Execute(&planCtx)
 
 	Assert(t, r.Error != nil, "exp .Error to be set")
@@ -55,7 +62,7 @@ func TestExecute_NoModifiedProjects(t *testing.T) {
 func TestExecute_CloneErr(t *testing.T) {
 	t.Log("If Workspace.Clone returns an error we return an error")
 	p, _, _ := setupPlanExecutorTest(t)
-	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
 	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "env")).ThenReturn("", errors.New("err"))
 	r := p.Execute(&planCtx)
 
@@ -66,7 +73,7 @@ func TestExecute_CloneErr(t *testing.T) {
 func TestExecute_Success(t *testing.T) {
 	t.Log("If there are no errors, the plan should be returned")
 	p, runner, _ := setupPlanExecutorTest(t)
-	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
 	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "env")).
 		ThenReturn("/tmp/clone-repo", nil)
 	When(p.ProjectPreExecute.Execute(&planCtx, "/tmp/clone-repo", models.Project{RepoFullName: "", Path: "."})).
@@ -81,21 +88,203 @@ func TestExecute_Success(t *testing.T) {
 	runner.VerifyWasCalledOnce().RunCommandWithVersion(
 		planCtx.Log,
 		"/tmp/clone-repo",
-		[]string{"plan", "-refresh", "-no-color", "-out", "/tmp/clone-repo/env.tfplan", "-var", "atlantis_user=anubhavmishra"},
+		[]string{"plan", "-refresh", "-no-color", "-detailed-exitcode", "-out", "/tmp/clone-repo/env.tfplan", "-var", "atlantis_user=anubhavmishra"},
 		nil,
 		"env",
+		"",
+		"",
 	)
 	Assert(t, len(r.ProjectResults) == 1, "exp one project result")
 	result := r.ProjectResults[0]
 	Assert(t, result.PlanSuccess != nil, "exp plan success to not be nil")
 	Equals(t, "", result.PlanSuccess.TerraformOutput)
 	Equals(t, "lockurl-key", result.PlanSuccess.LockURL)
+	Equals(t, true, result.PlanSuccess.NoChanges)
+}
+
+func TestExecute_CommandMetrics(t *testing.T) {
+	t.Log("the result of each project's plan and how long terraform took should be recorded")
+	p, _, _ := setupPlanExecutorTest(t)
+	p.CommandMetrics = events.NewCommandMetrics()
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "env")).
+		ThenReturn("/tmp/clone-repo", nil)
+	When(p.ProjectPreExecute.Execute(&planCtx, "/tmp/clone-repo", models.Project{RepoFullName: "", Path: "."})).
+		ThenReturn(events.PreExecuteResult{
+			LockResponse: locking.TryLockResponse{
+				LockKey: "key",
+			},
+		})
+
+	p.Execute(&planCtx)
+
+	var buf bytes.Buffer
+	p.CommandMetrics.Write(&buf)
+	out := buf.String()
+	for _, line := range []string{
+		`atlantis_plan_total{result="success",environment="env"} 1` + "\n",
+		`atlantis_terraform_command_duration_seconds_count{command="plan",environment="env"} 1` + "\n",
+	} {
+		Assert(t, strings.Contains(out, line), "missing %q in %q", line, out)
+	}
+}
+
+func TestExecute_SuccessWithChanges(t *testing.T) {
+	t.Log("If plan exits with 2 it has changes and should be returned as a success")
+	p, runner, locker := setupPlanExecutorTest(t)
+	p.ConfiguredWorkflow = events.ModifiedFilesWorkflow
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "env")).
+		ThenReturn("/tmp/clone-repo", nil)
+	When(p.ProjectPreExecute.Execute(&planCtx, "/tmp/clone-repo", models.Project{RepoFullName: "", Path: "."})).
+		ThenReturn(events.PreExecuteResult{LockResponse: locking.TryLockResponse{LockKey: "key"}})
+	exitErr := exec.Command("sh", "-c", "exit 2").Run()
+	When(runner.RunCommandWithVersion(
+		planCtx.Log,
+		"/tmp/clone-repo",
+		[]string{"plan", "-refresh", "-no-color", "-detailed-exitcode", "-out", "/tmp/clone-repo/env.tfplan", "-var", "atlantis_user=anubhavmishra"},
+		nil,
+		"env",
+		"",
+		"",
+	)).ThenReturn("Plan: 1 to add", &terraform.CommandError{Err: exitErr, Output: "Plan: 1 to add"})
+
+	r := p.Execute(&planCtx)
+
+	Assert(t, len(r.ProjectResults) == 1, "exp one project result")
+	result := r.ProjectResults[0]
+	Assert(t, result.PlanSuccess != nil, "exp plan success to not be nil")
+	Equals(t, "Plan: 1 to add", result.PlanSuccess.TerraformOutput)
+	Equals(t, false, result.PlanSuccess.NoChanges)
+	locker.VerifyWasCalled(Never()).Unlock("key")
+}
+
+func TestExecute_DestroyWarning(t *testing.T) {
+	t.Log("If destroy warnings are enabled, the plan's destroyed resources should be returned")
+	p, runner, _ := setupPlanExecutorTest(t)
+	p.ConfiguredWorkflow = events.ModifiedFilesWorkflow
+	p.DestroyWarning = &events.DestroyWarning{IgnoreTypes: []string{"random_*"}}
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "env")).
+		ThenReturn("/tmp/clone-repo", nil)
+	When(p.ProjectPreExecute.Execute(&planCtx, "/tmp/clone-repo", models.Project{RepoFullName: "", Path: "."})).
+		ThenReturn(events.PreExecuteResult{LockResponse: locking.TryLockResponse{LockKey: "key"}})
+	exitErr := exec.Command("sh", "-c", "exit 2").Run()
+	When(runner.RunCommandWithVersion(
+		planCtx.Log,
+		"/tmp/clone-repo",
+		[]string{"plan", "-refresh", "-no-color", "-detailed-exitcode", "-out", "/tmp/clone-repo/env.tfplan", "-var", "atlantis_user=anubhavmishra"},
+		nil,
+		"env",
+		"",
+		"",
+	)).ThenReturn("Plan: 1 to add, 2 to destroy", &terraform.CommandError{Err: exitErr, Output: "Plan: 1 to add, 2 to destroy"})
+	When(runner.RunCommandWithVersion(planCtx.Log, "/tmp/clone-repo", []string{"show", "-json", "/tmp/clone-repo/env.tfplan"}, nil, "env", "", "")).
+		ThenReturn(`{"resource_changes": [
+			{"address": "aws_db_instance.db", "type": "aws_db_instance", "change": {"actions": ["delete", "create"]}},
+			{"address": "random_id.suffix", "type": "random_id", "change": {"actions": ["delete"]}}
+		]}`, nil)
+
+	r := p.Execute(&planCtx)
+
+	Assert(t, len(r.ProjectResults) == 1, "exp one project result")
+	result := r.ProjectResults[0]
+	Assert(t, result.PlanSuccess != nil, "exp plan success to not be nil")
+	Equals(t, []string{"aws_db_instance.db"}, result.PlanSuccess.Destroyed)
+}
+
+func TestExecute_ExportNotAllowed(t *testing.T) {
+	t.Log("If the user can't export plans, plan --export should fail before planning")
+	p, _, _ := setupPlanExecutorTest(t)
+	exportCtx := planCtx
+	exportCtx.Command = &events.Command{Name: events.Plan, Environment: "env", Export: true}
+
+	r := p.Execute(&exportCtx)
+	Equals(t, "Exporting plans is disabled on this Atlantis server.", r.Failure)
+
+	p.Exporter = &events.PlanExporter{Users: []string{"alice"}}
+	r = p.Execute(&exportCtx)
+	Equals(t, "anubhavmishra is not allowed to export plans.", r.Failure)
+	p.VCSClient.(*vcsmocks.MockClientProxy).VerifyWasCalled(Never()).GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())
+}
+
+func TestExecute_NamedProject(t *testing.T) {
+	t.Log("If a project is named with -p, plan should run in just that project")
+	p, runner, _ := setupPlanExecutorTest(t)
+	p.ConfigReader = &events.ProjectConfigManager{}
+	cloneDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(cloneDir) // nolint: errcheck
+	Ok(t, os.MkdirAll(filepath.Join(cloneDir, "app"), 0755))
+	Ok(t, ioutil.WriteFile(filepath.Join(cloneDir, "app", events.ProjectConfigFile), []byte("name: payments-prod\n"), 0644))
+
+	ctx := planCtx
+	ctx.Command = &events.Command{Name: events.Plan, Environment: "env", ProjectName: "payments-prod"}
+	When(p.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, "env")).ThenReturn(cloneDir, nil)
+	When(p.ProjectPreExecute.Execute(&ctx, cloneDir, models.Project{RepoFullName: "", Path: "app"})).
+		ThenReturn(events.PreExecuteResult{LockResponse: locking.TryLockResponse{LockKey: "key"}})
+
+	r := p.Execute(&ctx)
+
+	Assert(t, len(r.ProjectResults) == 1, "exp one project result")
+	Equals(t, "app", r.ProjectResults[0].Path)
+	Assert(t, r.ProjectResults[0].PlanSuccess != nil, "exp plan success to not be nil")
+	changes, err := ioutil.ReadFile(filepath.Join(cloneDir, "app", "env.tfplan.changes"))
+	Ok(t, err)
+	Equals(t, `{"add":0,"change":0,"destroy":0}`, string(changes))
+	p.VCSClient.(*vcsmocks.MockClientProxy).VerifyWasCalled(Never()).GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())
+	runner.VerifyWasCalledOnce().RunCommandWithVersion(
+		ctx.Log,
+		filepath.Join(cloneDir, "app"),
+		[]string{"plan", "-refresh", "-no-color", "-detailed-exitcode", "-out", filepath.Join(cloneDir, "app", "env.tfplan"), "-var", "atlantis_user=anubhavmishra"},
+		nil,
+		"env",
+		"",
+		"",
+	)
+}
+
+func TestExecute_UnknownProjectName(t *testing.T) {
+	t.Log("If no project has the name given with -p, plan should fail")
+	p, _, _ := setupPlanExecutorTest(t)
+	p.ConfigReader = &events.ProjectConfigManager{}
+	cloneDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(cloneDir) // nolint: errcheck
+
+	ctx := planCtx
+	ctx.Command = &events.Command{Name: events.Plan, Environment: "env", ProjectName: "unknown"}
+	When(p.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, "env")).ThenReturn(cloneDir, nil)
+
+	r := p.Execute(&ctx)
+
+	Equals(t, `no project is named "unknown" in an atlantis.yaml file`, r.Failure)
+}
+
+func TestExecute_DependentProjects(t *testing.T) {
+	t.Log("If only a module was modified and dependents are planned, the projects that use it should be planned")
+	p, _, _ := setupPlanExecutorTest(t)
+	p.ConfiguredWorkflow = events.ModifiedFilesWorkflow
+	p.DependentFinder = &events.DependentProjectFinder{ConfigReader: &events.ProjectConfigManager{}}
+	cloneDir := writeRepo(t, dependentsRepo)
+	defer os.RemoveAll(cloneDir) // nolint: errcheck
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"modules/vpc/main.tf"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "env")).ThenReturn(cloneDir, nil)
+	When(p.ProjectPreExecute.Execute(&planCtx, cloneDir, models.Project{RepoFullName: "", Path: "prod"})).
+		ThenReturn(events.PreExecuteResult{LockResponse: locking.TryLockResponse{LockKey: "key"}})
+
+	r := p.Execute(&planCtx)
+
+	Assert(t, len(r.ProjectResults) == 1, "exp one project result")
+	Equals(t, "prod", r.ProjectResults[0].Path)
+	Equals(t, "modules/vpc", r.ProjectResults[0].ChangedModule)
+	Assert(t, r.ProjectResults[0].PlanSuccess != nil, "exp plan success to not be nil")
 }
 
 func TestExecute_PreExecuteResult(t *testing.T) {
 	t.Log("If ProjectPreExecute.Execute returns a ProjectResult we should return it")
 	p, _, _ := setupPlanExecutorTest(t)
-	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
 	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "env")).
 		ThenReturn("/tmp/clone-repo", nil)
 	projectResult := events.ProjectResult{
@@ -110,11 +299,26 @@ func TestExecute_PreExecuteResult(t *testing.T) {
 	Equals(t, "failure", result.Failure)
 }
 
+func TestExecute_OverProjectLimit(t *testing.T) {
+	t.Log("If more projects were modified than the limit, none should be planned")
+	p, _, _ := setupPlanExecutorTest(t)
+	p.ConfiguredWorkflow = events.ModifiedFilesWorkflow
+	p.ProjectLimit = &events.ProjectLimit{Max: 1}
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"path1/file.tf", "path2/file.tf"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "env")).
+		ThenReturn("/tmp/clone-repo", nil)
+
+	r := p.Execute(&planCtx)
+
+	Equals(t, "This command would run in 2 projects, which is more than the limit of 1. Run it in one project at a time with -p <project name>.", r.Failure)
+	p.ProjectPreExecute.(*mocks.MockProjectPreExecutor).VerifyWasCalled(Never()).Execute(&planCtx, "/tmp/clone-repo", models.Project{RepoFullName: "", Path: "path1"})
+}
+
 func TestExecute_MultiProjectFailure(t *testing.T) {
 	t.Log("If is an error planning in one project it should be returned. It shouldn't affect another project though.")
 	p, runner, locker := setupPlanExecutorTest(t)
 	// Two projects have been modified so we should run plan in two paths.
-	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"path1/file.tf", "path2/file.tf"}, nil)
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"path1/file.tf", "path2/file.tf"}, nil)
 	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "env")).
 		ThenReturn("/tmp/clone-repo", nil)
 
@@ -128,9 +332,11 @@ func TestExecute_MultiProjectFailure(t *testing.T) {
 	When(runner.RunCommandWithVersion(
 		planCtx.Log,
 		"/tmp/clone-repo/path1",
-		[]string{"plan", "-refresh", "-no-color", "-out", "/tmp/clone-repo/path1/env.tfplan", "-var", "atlantis_user=anubhavmishra"},
+		[]string{"plan", "-refresh", "-no-color", "-detailed-exitcode", "-out", "/tmp/clone-repo/path1/env.tfplan", "-var", "atlantis_user=anubhavmishra"},
 		nil,
 		"env",
+		"",
+		"",
 	)).ThenReturn("", errors.New("path1 err"))
 	// The second will succeed. We don't need to stub it because by default it
 	// will return a nil error.
@@ -154,14 +360,14 @@ func TestExecute_MultiProjectFailure(t *testing.T) {
 func TestExecute_PostPlanCommands(t *testing.T) {
 	t.Log("Should execute post-plan commands and return if there is an error")
 	p, _, _ := setupPlanExecutorTest(t)
-	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
 	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "env")).
 		ThenReturn("/tmp/clone-repo", nil)
 	When(p.ProjectPreExecute.Execute(&planCtx, "/tmp/clone-repo", models.Project{RepoFullName: "", Path: "."})).
 		ThenReturn(events.PreExecuteResult{
 			ProjectConfig: events.ProjectConfig{PostPlan: []string{"post-plan"}},
 		})
-	When(p.Run.Execute(planCtx.Log, []string{"post-plan"}, "/tmp/clone-repo", "env", nil, "post_plan")).
+	When(p.Run.Execute(planCtx.Log, []string{"post-plan"}, "/tmp/clone-repo", "env", nil, "post_plan", "")).
 		ThenReturn("", errors.New("err"))
 
 	r := p.Execute(&planCtx)
@@ -182,7 +388,7 @@ func setupPlanExecutorTest(t *testing.T) (*events.PlanExecutor, *tmocks.MockRunn
 	run := rmocks.NewMockRunner()
 	p := events.PlanExecutor{
 		VCSClient:         vcsProxy,
-		ProjectFinder:     &events.ProjectFinder{},
+		EnvDetector:       &events.ModifiedFilesDetector{VCSClient: vcsProxy, ProjectFinder: &events.ProjectFinder{}},
 		Workspace:         w,
 		ProjectPreExecute: ppe,
 		Terraform:         runner,
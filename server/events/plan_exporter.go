@@ -0,0 +1,205 @@
+package events
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// planExportsDir is the directory, relative to the data dir, that exported
+// plans are copied into.
+const planExportsDir = "plan-exports"
+
+// planExportIDRegex matches the IDs that Export generates. Anything else
+// can't be an export so it's never used in a path.
+var planExportIDRegex = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// ErrPlanExportNotFound is returned by Open if the export doesn't exist, its
+// link has expired or its signature is wrong. They're not told apart so a
+// link can't be probed.
+var ErrPlanExportNotFound = errors.New("plan export not found or link expired")
+
+// PlanExporter copies plan files out of pull request workspaces so reviewers
+// can download exactly what will be applied. Exports are served by Atlantis
+// under links that are signed with a key that's generated on startup, so
+// links stop working when they expire or Atlantis restarts.
+type PlanExporter struct {
+	// Dir is where exported plans are stored.
+	Dir string
+	// Users are the only users that can export plans.
+	Users []string
+	// TTL is how long links to exported plans work for.
+	TTL time.Duration
+	// URLPrefix is prepended to an export's ID to make its link, ex.
+	// "https://atlantis.example.com/plan-exports/".
+	URLPrefix string
+	// Encryptor, if set, encrypts exports at rest with the same key as the
+	// plans they're copied from. They're decrypted when they're downloaded.
+	Encryptor *PlanEncryptor
+	key       []byte
+}
+
+// NewPlanExporter returns an exporter that stores exports in dataDir and
+// makes links to them under atlantisURL.
+func NewPlanExporter(dataDir string, users []string, ttl time.Duration, atlantisURL string) (*PlanExporter, error) {
+	dir := filepath.Join(dataDir, planExportsDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "creating %s", dir)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.Wrap(err, "generating plan export key")
+	}
+	return &PlanExporter{
+		Dir:       dir,
+		Users:     users,
+		TTL:       ttl,
+		URLPrefix: atlantisURL + "/" + planExportsDir + "/",
+		key:       key,
+	}, nil
+}
+
+// IsAllowed returns true if username can export plans.
+func (e *PlanExporter) IsAllowed(username string) bool {
+	for _, u := range e.Users {
+		if u == username {
+			return true
+		}
+	}
+	return false
+}
+
+// Export copies planFile into the exports directory and returns the link to
+// download it and when the link expires. Exports whose links have expired
+// are deleted first.
+func (e *PlanExporter) Export(planFile string) (string, time.Time, error) {
+	e.deleteExpired()
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", time.Time{}, errors.Wrap(err, "generating plan export id")
+	}
+	id := hex.EncodeToString(idBytes)
+	if err := e.copyPlan(planFile, id); err != nil {
+		return "", time.Time{}, errors.Wrap(err, "exporting plan")
+	}
+	expires := time.Now().Add(e.TTL)
+	expiresStr := strconv.FormatInt(expires.Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expiresStr)
+	query.Set("signature", e.sign(id, expiresStr))
+	return e.URLPrefix + id + "?" + query.Encode(), expires, nil
+}
+
+// Open returns the exported plan with id if signature is its link's
+// signature and the link hasn't expired. The caller must close it.
+func (e *PlanExporter) Open(id string, expires string, signature string) (io.ReadCloser, error) {
+	e.deleteExpired()
+	if !planExportIDRegex.MatchString(id) || !hmac.Equal([]byte(signature), []byte(e.sign(id, expires))) {
+		return nil, ErrPlanExportNotFound
+	}
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().After(time.Unix(expiresUnix, 0)) {
+		return nil, ErrPlanExportNotFound
+	}
+	f, err := os.Open(e.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrPlanExportNotFound
+	}
+	if err != nil || e.Encryptor == nil {
+		return f, err
+	}
+	defer f.Close() // nolint: errcheck
+	ciphertext, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading plan export")
+	}
+	plaintext, err := e.Encryptor.open(ciphertext, exportAdditionalData(id))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(plaintext)), nil
+}
+
+// copyPlan copies planFile to the export with id, encrypting it if exports
+// are encrypted.
+func (e *PlanExporter) copyPlan(planFile string, id string) error {
+	if e.Encryptor == nil {
+		return copyFile(planFile, e.path(id))
+	}
+	plaintext, err := ioutil.ReadFile(planFile)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := e.Encryptor.seal(plaintext, exportAdditionalData(id))
+	if err != nil {
+		return err
+	}
+	return writeNewFile(e.path(id), bytes.NewReader(ciphertext))
+}
+
+// exportAdditionalData returns the data that the export with id is
+// authenticated with so it can only be downloaded as that export and can't
+// be copied into a workspace as a plan.
+func exportAdditionalData(id string) []byte {
+	return []byte("export\x00" + id)
+}
+
+func (e *PlanExporter) path(id string) string {
+	return filepath.Join(e.Dir, id+".tfplan")
+}
+
+func (e *PlanExporter) sign(id string, expires string) string {
+	mac := hmac.New(sha256.New, e.key)
+	fmt.Fprintf(mac, "%s:%s", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deleteExpired deletes the exports that are older than TTL. Errors are
+// ignored since they'll be retried on the next export.
+func (e *PlanExporter) deleteExpired() {
+	files, err := ioutil.ReadDir(e.Dir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if time.Since(f.ModTime()) > e.TTL {
+			os.Remove(filepath.Join(e.Dir, f.Name())) // nolint: errcheck
+		}
+	}
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close() // nolint: errcheck
+	return writeNewFile(dst, in)
+}
+
+// writeNewFile writes in to dst, which mustn't exist yet.
+func writeNewFile(dst string, in io.Reader) error {
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close() // nolint: errcheck
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,103 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestPlanExporter_Export(t *testing.T) {
+	t.Log("an exported plan should be downloadable with its link until the link expires")
+	dataDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dataDir) // nolint: errcheck
+	planFile := filepath.Join(dataDir, "env.tfplan")
+	Ok(t, ioutil.WriteFile(planFile, []byte("plan"), 0600))
+	e, err := events.NewPlanExporter(dataDir, []string{"alice"}, time.Hour, "https://atlantis.example.com")
+	Ok(t, err)
+
+	link, expires, err := e.Export(planFile)
+	Ok(t, err)
+	Assert(t, strings.HasPrefix(link, "https://atlantis.example.com/plan-exports/"), "unexpected link %s", link)
+	Assert(t, expires.After(time.Now()), "expected the link to expire in the future")
+	u, err := url.Parse(link)
+	Ok(t, err)
+	id := path.Base(u.Path)
+
+	f, err := e.Open(id, u.Query().Get("expires"), u.Query().Get("signature"))
+	Ok(t, err)
+	contents, err := ioutil.ReadAll(f)
+	f.Close() // nolint: errcheck
+	Ok(t, err)
+	Equals(t, "plan", string(contents))
+
+	t.Log("a link with the wrong signature or expiry shouldn't work")
+	_, err = e.Open(id, u.Query().Get("expires"), "bad")
+	Equals(t, events.ErrPlanExportNotFound, err)
+	_, err = e.Open(id, "1", u.Query().Get("signature"))
+	Equals(t, events.ErrPlanExportNotFound, err)
+	_, err = e.Open("../env", u.Query().Get("expires"), u.Query().Get("signature"))
+	Equals(t, events.ErrPlanExportNotFound, err)
+}
+
+func TestPlanExporter_Encrypted(t *testing.T) {
+	t.Log("with an encryptor, exports should be encrypted at rest and decrypted when they're downloaded")
+	dataDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dataDir) // nolint: errcheck
+	planFile := filepath.Join(dataDir, "env.tfplan")
+	Ok(t, ioutil.WriteFile(planFile, []byte("secret plan"), 0600))
+	e, err := events.NewPlanExporter(dataDir, []string{"alice"}, time.Hour, "https://atlantis.example.com")
+	Ok(t, err)
+	e.Encryptor, err = events.NewPlanEncryptor(planKey)
+	Ok(t, err)
+
+	link, _, err := e.Export(planFile)
+	Ok(t, err)
+	u, err := url.Parse(link)
+	Ok(t, err)
+	id := path.Base(u.Path)
+	ciphertext, err := ioutil.ReadFile(filepath.Join(e.Dir, id+".tfplan"))
+	Ok(t, err)
+	Assert(t, !strings.Contains(string(ciphertext), "secret plan"), "exp the export to be encrypted")
+
+	f, err := e.Open(id, u.Query().Get("expires"), u.Query().Get("signature"))
+	Ok(t, err)
+	contents, err := ioutil.ReadAll(f)
+	f.Close() // nolint: errcheck
+	Ok(t, err)
+	Equals(t, "secret plan", string(contents))
+}
+
+func TestPlanExporter_Expired(t *testing.T) {
+	t.Log("an exported plan shouldn't be downloadable once its link has expired")
+	dataDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dataDir) // nolint: errcheck
+	planFile := filepath.Join(dataDir, "env.tfplan")
+	Ok(t, ioutil.WriteFile(planFile, []byte("plan"), 0600))
+	e, err := events.NewPlanExporter(dataDir, []string{"alice"}, -time.Minute, "https://atlantis.example.com")
+	Ok(t, err)
+
+	link, _, err := e.Export(planFile)
+	Ok(t, err)
+	u, err := url.Parse(link)
+	Ok(t, err)
+	_, err = e.Open(path.Base(u.Path), u.Query().Get("expires"), u.Query().Get("signature"))
+	Equals(t, events.ErrPlanExportNotFound, err)
+}
+
+func TestPlanExporter_IsAllowed(t *testing.T) {
+	t.Log("only the exporter's users should be allowed to export plans")
+	e := events.PlanExporter{Users: []string{"alice"}}
+	Equals(t, true, e.IsAllowed("alice"))
+	Equals(t, false, e.IsAllowed("bob"))
+}
@@ -1,9 +1,12 @@
 package events
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/hashicorp/go-version"
 	"github.com/pkg/errors"
@@ -12,6 +15,11 @@ import (
 
 const ProjectConfigFile = "atlantis.yaml"
 
+// commandRegex matches the executables that can be configured as a project's
+// command. The command is run through sh so anything that the shell would
+// interpret, ex. spaces or ";", isn't allowed.
+var commandRegex = regexp.MustCompile(`^[a-zA-Z0-9_./-]+$`)
+
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_project_config_reader.go ProjectConfigReader
 
 // ProjectConfigReader implements reading project config.
@@ -31,6 +39,7 @@ type Hook struct {
 
 // projectConfigYAML is used to parse the YAML.
 type projectConfigYAML struct {
+	Name             string                  `yaml:"name"`
 	PreInit          Hook                    `yaml:"pre_init"`
 	PreGet           Hook                    `yaml:"pre_get"`
 	PrePlan          Hook                    `yaml:"pre_plan"`
@@ -38,12 +47,18 @@ type projectConfigYAML struct {
 	PreApply         Hook                    `yaml:"pre_apply"`
 	PostApply        Hook                    `yaml:"post_apply"`
 	TerraformVersion string                  `yaml:"terraform_version"`
+	RequiredVersion  string                  `yaml:"required_terraform_version"`
+	Command          string                  `yaml:"command"`
+	Dependencies     []string                `yaml:"dependencies"`
 	ExtraArguments   []commandExtraArguments `yaml:"extra_arguments"`
 }
 
 // ProjectConfig is a more usable version of projectConfigYAML that we can
 // return to our callers. It holds the config for a project.
 type ProjectConfig struct {
+	// Name is what users can refer to the project by with -p instead of its
+	// path. It's empty if the project isn't named.
+	Name string
 	// PreInit is a slice of command strings to run prior to terraform init.
 	PreInit []string
 	// PreGet is a slice of command strings to run prior to terraform get.
@@ -59,9 +74,22 @@ type ProjectConfig struct {
 	// TerraformVersion is the version specified in the config file or nil
 	// if version wasn't specified.
 	TerraformVersion *version.Version
+	// RequiredVersion is the constraint that the version of terraform the
+	// project is run with must satisfy, like terraform's required_version,
+	// or nil if there isn't one.
+	RequiredVersion version.Constraints
+	// Command is the executable to run instead of terraform, ex. terragrunt
+	// or a wrapper script relative to the project. It's run with the same
+	// args as terraform would be. It's empty if the project uses terraform.
+	Command string
+	// Dependencies are the directories of modules the project uses that
+	// Atlantis can't find from its source references, ex. because the
+	// project sources them from git. They're relative to the project.
+	Dependencies []string
 	// extraArguments is the extra args that we should tack on to certain
 	// terraform commands. It shouldn't be used directly and instead callers
-	// should use the GetExtraArguments method on ProjectConfig.
+	// should use the GetExtraArguments or GetExtraArgumentsForEnv methods on
+	// ProjectConfig.
 	extraArguments []commandExtraArguments
 }
 
@@ -70,6 +98,9 @@ type ProjectConfig struct {
 type commandExtraArguments struct {
 	// Name is the name of the command we should add the args to.
 	Name string `yaml:"command_name"`
+	// Environment, if set, is the only environment the args are added in.
+	// Args without one are added in every environment.
+	Environment string `yaml:"environment"`
 	// Arguments is the list of args we should append.
 	Arguments []string `yaml:"arguments"`
 }
@@ -107,8 +138,22 @@ func (c *ProjectConfigManager) Read(execPath string) (ProjectConfig, error) {
 			return pc, errors.Wrap(err, "parsing terraform_version")
 		}
 	}
+	var required version.Constraints
+	if pcYaml.RequiredVersion != "" {
+		required, err = version.NewConstraint(pcYaml.RequiredVersion)
+		if err != nil {
+			return pc, errors.Wrap(err, "parsing required_terraform_version")
+		}
+	}
+	if pcYaml.Command != "" && !commandRegex.MatchString(pcYaml.Command) {
+		return pc, fmt.Errorf("parsing command: %q isn't the name of or path to an executable", pcYaml.Command)
+	}
 	return ProjectConfig{
+		Name:             pcYaml.Name,
 		TerraformVersion: v,
+		RequiredVersion:  required,
+		Command:          pcYaml.Command,
+		Dependencies:     pcYaml.Dependencies,
 		extraArguments:   pcYaml.ExtraArguments,
 		PreInit:          pcYaml.PreInit.Commands,
 		PreGet:           pcYaml.PreGet.Commands,
@@ -120,12 +165,73 @@ func (c *ProjectConfigManager) Read(execPath string) (ProjectConfig, error) {
 }
 
 // GetExtraArguments returns the arguments that were specified to be appended
-// to command in the project config file.
+// to command in every environment in the project config file.
 func (c *ProjectConfig) GetExtraArguments(command string) []string {
+	return c.extraArgumentsFor(command, "")
+}
+
+// GetExtraArgumentsForEnv returns the arguments to append to command in env:
+// the ones for every environment followed by the ones for env. The ones for
+// env come last so they win when terraform uses the last of an argument, ex.
+// a variable set by more than one -var-file.
+func (c *ProjectConfig) GetExtraArgumentsForEnv(command string, env string) []string {
+	if env == "" {
+		return c.GetExtraArguments(command)
+	}
+	// Appended to a new slice so the entries' args aren't modified.
+	var args []string
+	args = append(args, c.GetExtraArguments(command)...)
+	return append(args, c.extraArgumentsFor(command, env)...)
+}
+
+// extraArgumentsFor returns the arguments of the first entry for command and
+// env, where an empty env is the entry for every environment.
+func (c *ProjectConfig) extraArgumentsFor(command string, env string) []string {
 	for _, value := range c.extraArguments {
-		if value.Name == command {
+		if value.Name == command && value.Environment == env {
 			return value.Arguments
 		}
 	}
 	return nil
 }
+
+// FindProjectByName returns the path, relative to repoDir, of the project
+// whose config file names it name. It errors if no project or more than one
+// project has that name.
+func FindProjectByName(reader ProjectConfigReader, repoDir string, name string) (string, error) {
+	var paths []string
+	err := filepath.Walk(repoDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !reader.Exists(path) {
+			return nil
+		}
+		config, err := reader.Read(path)
+		if err != nil {
+			return err
+		}
+		if config.Name == name {
+			rel, _ := filepath.Rel(repoDir, path)
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "finding project %q", name)
+	}
+	switch len(paths) {
+	case 0:
+		return "", fmt.Errorf("no project is named %q in an %s file", name, ProjectConfigFile)
+	case 1:
+		return paths[0], nil
+	default:
+		return "", fmt.Errorf("project name %q is ambiguous, it's used by the projects at %s", name, strings.Join(paths, ", "))
+	}
+}
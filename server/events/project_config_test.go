@@ -3,6 +3,7 @@ package events_test
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hootsuite/atlantis/server/events"
@@ -71,6 +72,34 @@ func TestRead_InvalidConfig(t *testing.T) {
 	Assert(t, err != nil, "expect an error")
 }
 
+func TestRead_Command(t *testing.T) {
+	t.Log("the command should be parsed if it's an executable and an error if the shell would interpret it")
+	writeAtlantisConfigFile(t, []byte("command: ./scripts/tf-wrapper.sh"))
+	defer os.Remove(tempConfigFile) // nolint: errcheck
+	config, err := c.Read("/tmp")
+	Ok(t, err)
+	Equals(t, "./scripts/tf-wrapper.sh", config.Command)
+
+	writeAtlantisConfigFile(t, []byte("command: terragrunt; rm -rf /"))
+	_, err = c.Read("/tmp")
+	Assert(t, err != nil, "expect an error")
+	Equals(t, "parsing command: \"terragrunt; rm -rf /\" isn't the name of or path to an executable", err.Error())
+}
+
+func TestRead_RequiredVersion(t *testing.T) {
+	t.Log("the required terraform version should be parsed as a constraint and an error if it isn't one")
+	writeAtlantisConfigFile(t, []byte(`required_terraform_version: ">= 0.11.0, < 0.12.0"`))
+	defer os.Remove(tempConfigFile) // nolint: errcheck
+	config, err := c.Read("/tmp")
+	Ok(t, err)
+	Equals(t, ">= 0.11.0, < 0.12.0", config.RequiredVersion.String())
+
+	writeAtlantisConfigFile(t, []byte("required_terraform_version: latest"))
+	_, err = c.Read("/tmp")
+	Assert(t, err != nil, "expect an error")
+	Equals(t, "parsing required_terraform_version: Malformed constraint: latest", err.Error())
+}
+
 func TestRead_ValidConfig(t *testing.T) {
 	t.Log("when the config file has valid yaml, it should be parsed")
 	writeAtlantisConfigFile(t, []byte(projectConfigFileStr))
@@ -90,6 +119,75 @@ func TestRead_ValidConfig(t *testing.T) {
 	Equals(t, 0, len(config.GetExtraArguments("not-specified")))
 }
 
+func TestRead_EnvExtraArguments(t *testing.T) {
+	t.Log("extra arguments for an environment should come after the ones for every environment")
+	writeAtlantisConfigFile(t, []byte(`
+extra_arguments:
+- command_name: plan
+  arguments: ["-var-file=common.tfvars"]
+- command_name: plan
+  environment: production
+  arguments: ["-var-file=production.tfvars"]
+- command_name: plan
+  environment: production
+  arguments: ["-var-file=ignored.tfvars"]
+- command_name: init
+  environment: staging
+  arguments: ["-backend-config=staging.hcl"]
+`))
+	defer os.Remove(tempConfigFile) // nolint: errcheck
+	config, err := c.Read("/tmp")
+	Ok(t, err)
+
+	cases := []struct {
+		Description string
+		Command     string
+		Env         string
+		Expected    []string
+	}{
+		{"an environment with its own args", "plan", "production", []string{"-var-file=common.tfvars", "-var-file=production.tfvars"}},
+		{"an environment without its own args", "plan", "staging", []string{"-var-file=common.tfvars"}},
+		{"no environment", "plan", "", []string{"-var-file=common.tfvars"}},
+		{"a command with only args for an environment", "init", "staging", []string{"-backend-config=staging.hcl"}},
+		{"another environment of that command", "init", "production", nil},
+	}
+	for _, c := range cases {
+		t.Log(c.Description + " should get the right args")
+		Equals(t, c.Expected, config.GetExtraArgumentsForEnv(c.Command, c.Env))
+	}
+	t.Log("args for an environment shouldn't be args for every environment")
+	Equals(t, 0, len(config.GetExtraArguments("init")))
+}
+
+func TestFindProjectByName(t *testing.T) {
+	t.Log("a named project should be found by its name and unknown or ambiguous names should error")
+	repoDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+	for path, name := range map[string]string{
+		"payments/prod": "payments-prod",
+		"payments/dev":  "shared",
+		"legacy":        "shared",
+		"unnamed":       "",
+	} {
+		dir := filepath.Join(repoDir, path)
+		Ok(t, os.MkdirAll(dir, 0755))
+		Ok(t, ioutil.WriteFile(filepath.Join(dir, events.ProjectConfigFile), []byte("name: "+name+"\n"), 0644))
+	}
+
+	path, err := events.FindProjectByName(&c, repoDir, "payments-prod")
+	Ok(t, err)
+	Equals(t, "payments/prod", path)
+
+	_, err = events.FindProjectByName(&c, repoDir, "unknown")
+	Assert(t, err != nil, "exp an error")
+	Equals(t, `no project is named "unknown" in an atlantis.yaml file`, err.Error())
+
+	_, err = events.FindProjectByName(&c, repoDir, "shared")
+	Assert(t, err != nil, "exp an error")
+	Equals(t, `project name "shared" is ambiguous, it's used by the projects at legacy, payments/dev`, err.Error())
+}
+
 func writeAtlantisConfigFile(t *testing.T, s []byte) {
 	err := ioutil.WriteFile(tempConfigFile, s, 0644)
 	Ok(t, err)
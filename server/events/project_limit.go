@@ -0,0 +1,46 @@
+package events
+
+import "fmt"
+
+// ProjectLimit is the most projects that a single plan or apply can run in,
+// so a pull request that touches hundreds of projects doesn't tie up Atlantis
+// and the VCS API. A nil ProjectLimit has no limit.
+type ProjectLimit struct {
+	// Max is the limit for repos that aren't in RepoMax. If 0, there's no
+	// limit.
+	Max int
+	// RepoMax overrides Max for repos by their full names, ex. "owner/repo".
+	// A limit of 0 means the repo has no limit.
+	RepoMax map[string]int
+}
+
+// NewProjectLimit returns a limit of max projects per command, overridden for
+// the repos in repoMax. If neither sets a limit, it returns nil.
+func NewProjectLimit(max int, repoMax map[string]int) (*ProjectLimit, error) {
+	for repo, m := range repoMax {
+		if m < 0 {
+			return nil, fmt.Errorf("invalid limit %d for %s: must not be negative", m, repo)
+		}
+	}
+	if max == 0 && len(repoMax) == 0 {
+		return nil, nil
+	}
+	return &ProjectLimit{Max: max, RepoMax: repoMax}, nil
+}
+
+// Check returns a failure message if running a command in numProjects
+// projects of the repo with repoFullName is over the limit. Otherwise it
+// returns "".
+func (l *ProjectLimit) Check(repoFullName string, numProjects int) string {
+	if l == nil {
+		return ""
+	}
+	max := l.Max
+	if m, ok := l.RepoMax[repoFullName]; ok {
+		max = m
+	}
+	if max == 0 || numProjects <= max {
+		return ""
+	}
+	return fmt.Sprintf("This command would run in %d projects, which is more than the limit of %d. Run it in one project at a time with -p <project name>.", numProjects, max)
+}
@@ -0,0 +1,34 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestProjectLimit_Check(t *testing.T) {
+	l, err := events.NewProjectLimit(2, map[string]int{"owner/monorepo": 5, "owner/unlimited": 0})
+	Ok(t, err)
+
+	t.Log("commands at the limit should be allowed")
+	Equals(t, "", l.Check("owner/repo", 2))
+
+	t.Log("commands over the limit should fail")
+	Equals(t, "This command would run in 3 projects, which is more than the limit of 2. Run it in one project at a time with -p <project name>.", l.Check("owner/repo", 3))
+
+	t.Log("a repo's override should be used instead of the limit")
+	Equals(t, "", l.Check("owner/monorepo", 5))
+	Assert(t, l.Check("owner/monorepo", 6) != "", "exp a failure over the repo's limit")
+
+	t.Log("an override of 0 should mean no limit")
+	Equals(t, "", l.Check("owner/unlimited", 100))
+}
+
+func TestNewProjectLimit_NoLimit(t *testing.T) {
+	t.Log("no limit should return nil, which allows any number of projects")
+	l, err := events.NewProjectLimit(0, nil)
+	Ok(t, err)
+	Assert(t, l == nil, "exp nil")
+	Equals(t, "", l.Check("owner/repo", 1000))
+}
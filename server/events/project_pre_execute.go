@@ -10,6 +10,7 @@ import (
 	"github.com/hootsuite/atlantis/server/events/models"
 	"github.com/hootsuite/atlantis/server/events/run"
 	"github.com/hootsuite/atlantis/server/events/terraform"
+	"github.com/hootsuite/atlantis/server/tracing"
 	"github.com/pkg/errors"
 )
 
@@ -24,6 +25,15 @@ type ProjectPreExecute struct {
 	ConfigReader ProjectConfigReader
 	Terraform    terraform.Runner
 	Run          run.Runner
+	// RequiredVersions maps environment names to the terraform version
+	// constraint that must be satisfied before we run in that environment.
+	// Environments that aren't in the map can use any version.
+	RequiredVersions map[string]version.Constraints
+	// Distribution is what's run in projects. With terragrunt, projects
+	// without a command in their config file run terragrunt and no
+	// workspace is selected since terragrunt keeps each environment's state
+	// in its own directory.
+	Distribution TerraformDistribution
 }
 
 type PreExecuteResult struct {
@@ -56,35 +66,58 @@ func (p *ProjectPreExecute) Execute(ctx *CommandContext, repoDir string, project
 		}
 		ctx.Log.Info("parsed atlantis config file in %q", absolutePath)
 	}
+	terragrunt := p.Distribution == TerraformDistributionTerragrunt
+	if terragrunt && config.Command == "" {
+		config.Command = string(TerraformDistributionTerragrunt)
+	}
 
-	// check if terraform version is >= 0.9.0
-	terraformVersion := p.Terraform.Version()
-	if config.TerraformVersion != nil {
-		terraformVersion = config.TerraformVersion
+	terraformVersion := p.TerraformVersion(config)
+	// Checked before init so a newer terraform can't upgrade the state.
+	if _, failure := p.CheckRequiredVersion(tfEnv, config, terraformVersion); failure != "" {
+		return PreExecuteResult{ProjectResult: ProjectResult{Failure: failure}}
 	}
+	// check if terraform version is >= 0.9.0
 	constraints, _ := version.NewConstraint(">= 0.9.0")
 	if constraints.Check(terraformVersion) {
 		ctx.Log.Info("determined that we are running terraform with version >= 0.9.0. Running version %s", terraformVersion)
 		if len(config.PreInit) > 0 {
-			_, err := p.Run.Execute(ctx.Log, config.PreInit, absolutePath, tfEnv, terraformVersion, "pre_init")
+			_, err := p.Run.Execute(ctx.Log, config.PreInit, absolutePath, tfEnv, terraformVersion, "pre_init", ctx.AWSProfile)
 			if err != nil {
 				return PreExecuteResult{ProjectResult: ProjectResult{Error: errors.Wrapf(err, "running %s commands", "pre_init")}}
 			}
 		}
-		_, err := p.Terraform.RunInitAndEnv(ctx.Log, absolutePath, tfEnv, config.GetExtraArguments("init"), terraformVersion)
+		_, span := tracing.Start(ctx.Context, "terraform init")
+		var err error
+		if terragrunt {
+			initCmd := append([]string{"init", "-no-color"}, config.GetExtraArgumentsForEnv("init", tfEnv)...)
+			_, err = p.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, initCmd, terraformVersion, tfEnv, config.Command, ctx.AWSProfile)
+		} else {
+			_, err = p.Terraform.RunInitAndEnv(ctx.Log, absolutePath, tfEnv, config.GetExtraArgumentsForEnv("init", tfEnv), terraformVersion, config.Command, ctx.AWSProfile)
+		}
+		span.SetError(err)
+		span.End()
+		if notFoundErr, ok := err.(*terraform.ExecutableNotFoundError); ok {
+			return PreExecuteResult{ProjectResult: ProjectResult{Failure: notFoundErr.Error()}}
+		}
 		if err != nil {
 			return PreExecuteResult{ProjectResult: ProjectResult{Error: err}}
 		}
 	} else {
 		ctx.Log.Info("determined that we are running terraform with version < 0.9.0. Running version %s", terraformVersion)
 		if len(config.PreGet) > 0 {
-			_, err := p.Run.Execute(ctx.Log, config.PreGet, absolutePath, tfEnv, terraformVersion, "pre_get")
+			_, err := p.Run.Execute(ctx.Log, config.PreGet, absolutePath, tfEnv, terraformVersion, "pre_get", ctx.AWSProfile)
 			if err != nil {
 				return PreExecuteResult{ProjectResult: ProjectResult{Error: errors.Wrapf(err, "running %s commands", "pre_get")}}
 			}
 		}
-		terraformGetCmd := append([]string{"get", "-no-color"}, config.GetExtraArguments("get")...)
-		_, err := p.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, terraformGetCmd, terraformVersion, tfEnv)
+		terraformGetCmd := append([]string{"get", "-no-color"}, config.GetExtraArgumentsForEnv("get", tfEnv)...)
+		_, span := tracing.Start(ctx.Context, "terraform get")
+		_, err := p.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, terraformGetCmd, terraformVersion, tfEnv, config.Command, ctx.AWSProfile)
+		span.SetError(err)
+		span.End()
+		if notFoundErr, ok := err.(*terraform.ExecutableNotFoundError); ok {
+			return PreExecuteResult{ProjectResult: ProjectResult{Failure: notFoundErr.Error()}}
+		}
 		if err != nil {
 			return PreExecuteResult{ProjectResult: ProjectResult{Error: err}}
 		}
@@ -98,10 +131,41 @@ func (p *ProjectPreExecute) Execute(ctx *CommandContext, repoDir string, project
 		commands = config.PreApply
 	}
 	if len(commands) > 0 {
-		_, err := p.Run.Execute(ctx.Log, commands, absolutePath, tfEnv, terraformVersion, stage)
+		_, err := p.Run.Execute(ctx.Log, commands, absolutePath, tfEnv, terraformVersion, stage, ctx.AWSProfile)
 		if err != nil {
 			return PreExecuteResult{ProjectResult: ProjectResult{Error: errors.Wrapf(err, "running %s commands", stage)}}
 		}
 	}
 	return PreExecuteResult{ProjectConfig: config, TerraformVersion: terraformVersion, LockResponse: lockAttempt}
 }
+
+// TerraformVersion returns the version of terraform that will be used to run
+// commands for a project with config.
+func (p *ProjectPreExecute) TerraformVersion(config ProjectConfig) *version.Version {
+	if config.TerraformVersion != nil {
+		return config.TerraformVersion
+	}
+	return p.Terraform.Version()
+}
+
+// CheckRequiredVersion returns the version constraints that are required for
+// env and by the project's config and why v doesn't satisfy them, or "" if it
+// does. If there are no constraints, the returned constraints are nil and v
+// is always satisfying.
+func (p *ProjectPreExecute) CheckRequiredVersion(env string, config ProjectConfig, v *version.Version) (version.Constraints, string) {
+	var constraints version.Constraints
+	failure := ""
+	if required, ok := p.RequiredVersions[env]; ok {
+		constraints = append(constraints, required...)
+		if !required.Check(v) {
+			failure = fmt.Sprintf("Terraform version %s does not satisfy the constraint %q required for the %s environment.", v, required.String(), env)
+		}
+	}
+	if config.RequiredVersion != nil {
+		constraints = append(constraints, config.RequiredVersion...)
+		if failure == "" && !config.RequiredVersion.Check(v) {
+			failure = fmt.Sprintf("Terraform version %s does not satisfy the constraint %q required by this project's %s.", v, config.RequiredVersion.String(), ProjectConfigFile)
+		}
+	}
+	return constraints, failure
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/hootsuite/atlantis/server/events/mocks"
 	"github.com/hootsuite/atlantis/server/events/models"
 	rmocks "github.com/hootsuite/atlantis/server/events/run/mocks"
+	"github.com/hootsuite/atlantis/server/events/terraform"
 	tmocks "github.com/hootsuite/atlantis/server/events/terraform/mocks"
 	"github.com/hootsuite/atlantis/server/logging"
 	. "github.com/hootsuite/atlantis/testing"
@@ -62,6 +63,68 @@ func TestExecute_ConfigErr(t *testing.T) {
 	Equals(t, "err", res.ProjectResult.Error.Error())
 }
 
+func TestExecute_RequiredVersionNotSatisfied(t *testing.T) {
+	t.Log("when the terraform version doesn't satisfy the environment's constraint we return a failure")
+	p, l, tm, _ := setupPreExecuteTest(t)
+	When(l.TryLock(project, "", ctx.Pull, ctx.User)).ThenReturn(locking.TryLockResponse{
+		LockAcquired: true,
+	}, nil)
+	tfVersion, _ := version.NewVersion("0.10.8")
+	When(tm.Version()).ThenReturn(tfVersion)
+	constraint, _ := version.NewConstraint("~> 0.11.0")
+	p.RequiredVersions = map[string]version.Constraints{"": constraint}
+
+	res := p.Execute(&ctx, "", project)
+	Equals(t, "Terraform version 0.10.8 does not satisfy the constraint \"~> 0.11.0\" required for the  environment.", res.ProjectResult.Failure)
+	tm.VerifyWasCalled(Never()).RunInitAndEnv(ctx.Log, "", "", nil, tfVersion, "", "")
+}
+
+func TestExecute_RequiredVersionSatisfied(t *testing.T) {
+	t.Log("when the terraform version satisfies the environment's constraint we continue")
+	p, l, tm, _ := setupPreExecuteTest(t)
+	When(l.TryLock(project, "", ctx.Pull, ctx.User)).ThenReturn(locking.TryLockResponse{
+		LockAcquired: true,
+	}, nil)
+	tfVersion, _ := version.NewVersion("0.11.3")
+	When(tm.Version()).ThenReturn(tfVersion)
+	constraint, _ := version.NewConstraint("~> 0.11.0")
+	p.RequiredVersions = map[string]version.Constraints{
+		"":           constraint,
+		"production": constraint,
+	}
+
+	res := p.Execute(&ctx, "", project)
+	Equals(t, "", res.ProjectResult.Failure)
+	Equals(t, tfVersion, res.TerraformVersion)
+	tm.VerifyWasCalledOnce().RunInitAndEnv(ctx.Log, "", "", nil, tfVersion, "", "")
+}
+
+func TestExecute_ProjectRequiredVersion(t *testing.T) {
+	t.Log("when the project's terraform version doesn't satisfy its config's constraint we return a failure before init")
+	p, l, tm, _ := setupPreExecuteTest(t)
+	When(l.TryLock(project, "", ctx.Pull, ctx.User)).ThenReturn(locking.TryLockResponse{
+		LockAcquired: true,
+	}, nil)
+	tfVersion, _ := version.NewVersion("0.12.0")
+	When(tm.Version()).ThenReturn(tfVersion)
+	constraint, _ := version.NewConstraint("~> 0.11.0")
+	When(p.ConfigReader.Exists("")).ThenReturn(true)
+	When(p.ConfigReader.Read("")).ThenReturn(events.ProjectConfig{RequiredVersion: constraint}, nil)
+
+	res := p.Execute(&ctx, "", project)
+	Equals(t, "Terraform version 0.12.0 does not satisfy the constraint \"~> 0.11.0\" required by this project's atlantis.yaml.", res.ProjectResult.Failure)
+	tm.VerifyWasCalled(Never()).RunInitAndEnv(ctx.Log, "", "", nil, tfVersion, "", "")
+
+	t.Log("when the project's terraform_version satisfies it we continue")
+	pinned, _ := version.NewVersion("0.11.7")
+	When(p.ConfigReader.Read("")).ThenReturn(events.ProjectConfig{RequiredVersion: constraint, TerraformVersion: pinned}, nil)
+
+	res = p.Execute(&ctx, "", project)
+	Equals(t, "", res.ProjectResult.Failure)
+	Equals(t, pinned, res.TerraformVersion)
+	tm.VerifyWasCalledOnce().RunInitAndEnv(ctx.Log, "", "", nil, pinned, "", "")
+}
+
 func TestExecute_PreInitErr(t *testing.T) {
 	t.Log("when the project is on tf >= 0.9 and we run a `pre_init` that returns an error we return it")
 	p, l, tm, r := setupPreExecuteTest(t)
@@ -74,7 +137,7 @@ func TestExecute_PreInitErr(t *testing.T) {
 	}, nil)
 	tfVersion, _ := version.NewVersion("0.9.0")
 	When(tm.Version()).ThenReturn(tfVersion)
-	When(r.Execute(ctx.Log, []string{"pre-init"}, "", "", tfVersion, "pre_init")).ThenReturn("", errors.New("err"))
+	When(r.Execute(ctx.Log, []string{"pre-init"}, "", "", tfVersion, "pre_init", "")).ThenReturn("", errors.New("err"))
 
 	res := p.Execute(&ctx, "", project)
 	Equals(t, "running pre_init commands: err", res.ProjectResult.Error.Error())
@@ -90,12 +153,31 @@ func TestExecute_InitErr(t *testing.T) {
 	When(p.ConfigReader.Read("")).ThenReturn(events.ProjectConfig{}, nil)
 	tfVersion, _ := version.NewVersion("0.9.0")
 	When(tm.Version()).ThenReturn(tfVersion)
-	When(tm.RunInitAndEnv(ctx.Log, "", "", nil, tfVersion)).ThenReturn(nil, errors.New("err"))
+	When(tm.RunInitAndEnv(ctx.Log, "", "", nil, tfVersion, "", "")).ThenReturn(nil, errors.New("err"))
 
 	res := p.Execute(&ctx, "", project)
 	Equals(t, "err", res.ProjectResult.Error.Error())
 }
 
+func TestExecute_InitTerraformNotFound(t *testing.T) {
+	t.Log("when the terraform executable for the version isn't installed we return a failure saying so")
+	p, l, tm, _ := setupPreExecuteTest(t)
+	When(l.TryLock(project, "", ctx.Pull, ctx.User)).ThenReturn(locking.TryLockResponse{
+		LockAcquired: true,
+	}, nil)
+	tfVersion, _ := version.NewVersion("0.11.3")
+	When(tm.Version()).ThenReturn(tfVersion)
+	When(tm.RunInitAndEnv(ctx.Log, "", "", nil, tfVersion, "", "")).ThenReturn(nil, &terraform.ExecutableNotFoundError{
+		Executable: "terraform0.11.3",
+		Version:    "0.11.3",
+		Message:    "Ask #platform to install it.",
+	})
+
+	res := p.Execute(&ctx, "", project)
+	Assert(t, res.ProjectResult.Error == nil, "exp no error")
+	Equals(t, "Terraform 0.11.3 is needed but isn't installed on the Atlantis server: \"terraform0.11.3\" was not found in $PATH. Ask #platform to install it.", res.ProjectResult.Failure)
+}
+
 func TestExecute_PreGetErr(t *testing.T) {
 	t.Log("when the project is on tf < 0.9 and we run a `pre_get` that returns an error we return it")
 	p, l, tm, r := setupPreExecuteTest(t)
@@ -108,7 +190,7 @@ func TestExecute_PreGetErr(t *testing.T) {
 	}, nil)
 	tfVersion, _ := version.NewVersion("0.8")
 	When(tm.Version()).ThenReturn(tfVersion)
-	When(r.Execute(ctx.Log, []string{"pre-get"}, "", "", tfVersion, "pre_get")).ThenReturn("", errors.New("err"))
+	When(r.Execute(ctx.Log, []string{"pre-get"}, "", "", tfVersion, "pre_get", "")).ThenReturn("", errors.New("err"))
 
 	res := p.Execute(&ctx, "", project)
 	Equals(t, "running pre_get commands: err", res.ProjectResult.Error.Error())
@@ -124,7 +206,7 @@ func TestExecute_GetErr(t *testing.T) {
 	When(p.ConfigReader.Read("")).ThenReturn(events.ProjectConfig{}, nil)
 	tfVersion, _ := version.NewVersion("0.8")
 	When(tm.Version()).ThenReturn(tfVersion)
-	When(tm.RunCommandWithVersion(ctx.Log, "", []string{"get", "-no-color"}, tfVersion, "")).ThenReturn("", errors.New("err"))
+	When(tm.RunCommandWithVersion(ctx.Log, "", []string{"get", "-no-color"}, tfVersion, "", "", "")).ThenReturn("", errors.New("err"))
 
 	res := p.Execute(&ctx, "", project)
 	Equals(t, "err", res.ProjectResult.Error.Error())
@@ -142,8 +224,8 @@ func TestExecute_PreCommandErr(t *testing.T) {
 	}, nil)
 	tfVersion, _ := version.NewVersion("0.9")
 	When(tm.Version()).ThenReturn(tfVersion)
-	When(tm.RunInitAndEnv(ctx.Log, "", "", nil, tfVersion)).ThenReturn(nil, nil)
-	When(r.Execute(ctx.Log, []string{"command"}, "", "", tfVersion, "pre_plan")).ThenReturn("", errors.New("err"))
+	When(tm.RunInitAndEnv(ctx.Log, "", "", nil, tfVersion, "", "")).ThenReturn(nil, nil)
+	When(r.Execute(ctx.Log, []string{"command"}, "", "", tfVersion, "pre_plan", "")).ThenReturn("", errors.New("err"))
 
 	res := p.Execute(&ctx, "", project)
 	Equals(t, "running pre_plan commands: err", res.ProjectResult.Error.Error())
@@ -163,7 +245,7 @@ func TestExecute_SuccessTF9(t *testing.T) {
 	When(p.ConfigReader.Read("")).ThenReturn(config, nil)
 	tfVersion, _ := version.NewVersion("0.9")
 	When(tm.Version()).ThenReturn(tfVersion)
-	When(tm.RunInitAndEnv(ctx.Log, "", "", nil, tfVersion)).ThenReturn(nil, nil)
+	When(tm.RunInitAndEnv(ctx.Log, "", "", nil, tfVersion, "", "")).ThenReturn(nil, nil)
 
 	res := p.Execute(&ctx, "", project)
 	Equals(t, events.PreExecuteResult{
@@ -171,8 +253,30 @@ func TestExecute_SuccessTF9(t *testing.T) {
 		TerraformVersion: tfVersion,
 		LockResponse:     lockResponse,
 	}, res)
-	tm.VerifyWasCalledOnce().RunInitAndEnv(ctx.Log, "", "", nil, tfVersion)
-	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"pre-init"}, "", "", tfVersion, "pre_init")
+	tm.VerifyWasCalledOnce().RunInitAndEnv(ctx.Log, "", "", nil, tfVersion, "", "")
+	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"pre-init"}, "", "", tfVersion, "pre_init", "")
+}
+
+func TestExecute_SuccessTerragrunt(t *testing.T) {
+	t.Log("with terragrunt, the project should run terragrunt init without selecting a workspace")
+	p, l, tm, _ := setupPreExecuteTest(t)
+	p.Distribution = events.TerraformDistributionTerragrunt
+	lockResponse := locking.TryLockResponse{
+		LockAcquired: true,
+	}
+	When(l.TryLock(project, "", ctx.Pull, ctx.User)).ThenReturn(lockResponse, nil)
+	When(p.ConfigReader.Exists("")).ThenReturn(false)
+	tfVersion, _ := version.NewVersion("0.9")
+	When(tm.Version()).ThenReturn(tfVersion)
+
+	res := p.Execute(&ctx, "", project)
+	Equals(t, events.PreExecuteResult{
+		ProjectConfig:    events.ProjectConfig{Command: "terragrunt"},
+		TerraformVersion: tfVersion,
+		LockResponse:     lockResponse,
+	}, res)
+	tm.VerifyWasCalledOnce().RunCommandWithVersion(ctx.Log, "", []string{"init", "-no-color"}, tfVersion, "", "terragrunt", "")
+	tm.VerifyWasCalled(Never()).RunInitAndEnv(ctx.Log, "", "", nil, tfVersion, "terragrunt", "")
 }
 
 func TestExecute_SuccessTF8(t *testing.T) {
@@ -196,8 +300,8 @@ func TestExecute_SuccessTF8(t *testing.T) {
 		TerraformVersion: tfVersion,
 		LockResponse:     lockResponse,
 	}, res)
-	tm.VerifyWasCalledOnce().RunCommandWithVersion(ctx.Log, "", []string{"get", "-no-color"}, tfVersion, "")
-	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"pre-get"}, "", "", tfVersion, "pre_get")
+	tm.VerifyWasCalledOnce().RunCommandWithVersion(ctx.Log, "", []string{"get", "-no-color"}, tfVersion, "", "", "")
+	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"pre-get"}, "", "", tfVersion, "pre_get", "")
 }
 
 func TestExecute_SuccessPrePlan(t *testing.T) {
@@ -221,7 +325,7 @@ func TestExecute_SuccessPrePlan(t *testing.T) {
 		TerraformVersion: tfVersion,
 		LockResponse:     lockResponse,
 	}, res)
-	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"command"}, "", "", tfVersion, "pre_plan")
+	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"command"}, "", "", tfVersion, "pre_plan", "")
 }
 
 func TestExecute_SuccessPreApply(t *testing.T) {
@@ -251,7 +355,7 @@ func TestExecute_SuccessPreApply(t *testing.T) {
 		TerraformVersion: tfVersion,
 		LockResponse:     lockResponse,
 	}, res)
-	r.VerifyWasCalledOnce().Execute(cpCtx.Log, []string{"command"}, "", "", tfVersion, "pre_apply")
+	r.VerifyWasCalledOnce().Execute(cpCtx.Log, []string{"command"}, "", "", tfVersion, "pre_apply", "")
 }
 
 func setupPreExecuteTest(t *testing.T) (*events.ProjectPreExecute, *lmocks.MockLocker, *tmocks.MockRunner, *rmocks.MockRunner) {
@@ -1,13 +1,28 @@
 package events
 
-import "github.com/hootsuite/atlantis/server/events/vcs"
+import (
+	"github.com/hootsuite/atlantis/server/events/tfc"
+	"github.com/hootsuite/atlantis/server/events/vcs"
+)
 
 type ProjectResult struct {
-	Path         string
-	Error        error
-	Failure      string
-	PlanSuccess  *PlanSuccess
-	ApplySuccess string
+	Path                string
+	Error               error
+	Failure             string
+	PlanSuccess         *PlanSuccess
+	ApplySuccess        string
+	VersionCheckSuccess *VersionCheckSuccess
+	FmtSuccess          *FmtSuccess
+	ValidateSuccess     *ValidateSuccess
+	// ChangedModule is the modified module that made us plan this project,
+	// if none of the project's own files were modified.
+	ChangedModule string
+	// RemoteRun is the Terraform Cloud run the command ran in, if it didn't
+	// run locally.
+	RemoteRun *tfc.Run
+	// PlanSummary is what the plan that was applied changes, if it could be
+	// read.
+	PlanSummary *PlanSummary
 }
 
 func (p ProjectResult) Status() vcs.CommitStatus {
@@ -17,5 +32,11 @@ func (p ProjectResult) Status() vcs.CommitStatus {
 	if p.Failure != "" {
 		return vcs.Failed
 	}
+	if p.VersionCheckSuccess != nil && !p.VersionCheckSuccess.Satisfied {
+		return vcs.Failed
+	}
+	if p.FmtSuccess != nil && !p.FmtSuccess.Fixed && len(p.FmtSuccess.Files) > 0 {
+		return vcs.Failed
+	}
 	return vcs.Success
 }
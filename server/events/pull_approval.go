@@ -0,0 +1,82 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// approvalFailure returns why the pull request isn't approved the way policy
+// requires, or "" if it is.
+func (a *ApplyExecutor) approvalFailure(ctx *CommandContext, policy ApplyPolicy) (string, error) {
+	const notApproved = "Pull request must be approved before running apply."
+	if len(policy.RequiredApprovers) == 0 && !policy.NoSelfApproval {
+		approved, err := a.VCSClient.PullIsApproved(ctx.Context, ctx.BaseRepo, ctx.Pull, ctx.VCSHost)
+		if err != nil {
+			return "", err
+		}
+		if !approved {
+			return notApproved, nil
+		}
+		return "", nil
+	}
+
+	approvers, err := a.VCSClient.GetApprovers(ctx.Context, ctx.BaseRepo, ctx.Pull, ctx.VCSHost)
+	if err != nil {
+		return "", errors.Wrap(err, "getting approvers")
+	}
+	if policy.NoSelfApproval {
+		var others []string
+		for _, approver := range approvers {
+			if approver != ctx.Pull.Author && approver != ctx.User.Username {
+				others = append(others, approver)
+			}
+		}
+		if len(approvers) > 0 && len(others) == 0 {
+			return fmt.Sprintf("Pull request was only approved by %s. It must be approved by someone other than its author and the user running apply.", strings.Join(approvers, ", ")), nil
+		}
+		approvers = others
+	}
+	if len(policy.RequiredApprovers) == 0 {
+		if len(approvers) == 0 {
+			return notApproved, nil
+		}
+		return "", nil
+	}
+	for _, approver := range approvers {
+		ok, err := a.isRequiredApprover(ctx, policy.RequiredApprovers, approver)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			ctx.Log.Info("pull request was approved by required approver %s", approver)
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("Pull request must be approved by one of %s before running apply.", strings.Join(policy.RequiredApprovers, ", ")), nil
+}
+
+// isRequiredApprover returns true if username is one of requiredApprovers
+// or a member of one of the teams in it. Teams are the entries with a /, ex.
+// org/team-slug.
+func (a *ApplyExecutor) isRequiredApprover(ctx *CommandContext, requiredApprovers []string, username string) (bool, error) {
+	var teams []string
+	for _, r := range requiredApprovers {
+		if strings.Contains(r, "/") {
+			teams = append(teams, r)
+		} else if r == username {
+			return true, nil
+		}
+	}
+	for _, team := range teams {
+		ok, err := a.VCSClient.UserIsTeamMember(ctx.Context, team, username, ctx.VCSHost)
+		if err != nil {
+			return false, errors.Wrapf(err, "checking if %s is a member of %s", username, team)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,133 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/mocks"
+	vcsmocks "github.com/hootsuite/atlantis/server/events/vcs/mocks"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+func TestApplyExecutor_RequiredApprovers(t *testing.T) {
+	t.Log("a pull request should only be applied if one of the required approvers approved it")
+	RegisterMockTestingT(t)
+	cases := []struct {
+		Description string
+		Approvers   []string
+		ExpFailure  string
+	}{
+		{
+			"approved by the wrong person",
+			[]string{"bob"},
+			"Pull request must be approved by one of alice, org/payments before running apply.",
+		},
+		{
+			"not approved",
+			nil,
+			"Pull request must be approved by one of alice, org/payments before running apply.",
+		},
+		{
+			"approved by a required user",
+			[]string{"bob", "alice"},
+			"No workspace found. Did you run plan?",
+		},
+		{
+			"approved by a member of a required team",
+			[]string{"carol"},
+			"No workspace found. Did you run plan?",
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.Description)
+		vcsClient := vcsmocks.NewMockClientProxy()
+		When(vcsClient.GetApprovers(applyCtx.Context, applyCtx.BaseRepo, applyCtx.Pull, applyCtx.VCSHost)).ThenReturn(c.Approvers, nil)
+		When(vcsClient.UserIsTeamMember(applyCtx.Context, "org/payments", "carol", applyCtx.VCSHost)).ThenReturn(true, nil)
+		workspace := mocks.NewMockWorkspace()
+		When(workspace.GetWorkspace(applyCtx.BaseRepo, applyCtx.Pull, "production")).ThenReturn("", errors.New("not found"))
+		a := &events.ApplyExecutor{VCSClient: vcsClient, Workspace: workspace}
+		a.SetPolicy(events.ApplyPolicy{RequireApproval: true, RequiredApprovers: []string{"alice", "org/payments"}})
+
+		r := a.Execute(&applyCtx)
+		Ok(t, r.Error)
+		Equals(t, c.ExpFailure, r.Failure)
+		vcsClient.VerifyWasCalled(Never()).PullIsApproved(applyCtx.Context, applyCtx.BaseRepo, applyCtx.Pull, applyCtx.VCSHost)
+	}
+}
+
+func TestApplyExecutor_RequiredApproversTeamError(t *testing.T) {
+	t.Log("an error checking team membership should be an error, not a rejection")
+	RegisterMockTestingT(t)
+	vcsClient := vcsmocks.NewMockClientProxy()
+	When(vcsClient.GetApprovers(applyCtx.Context, applyCtx.BaseRepo, applyCtx.Pull, applyCtx.VCSHost)).ThenReturn([]string{"bob"}, nil)
+	When(vcsClient.UserIsTeamMember(applyCtx.Context, "org/payments", "bob", applyCtx.VCSHost)).ThenReturn(false, errors.New("rate limited"))
+	a := &events.ApplyExecutor{VCSClient: vcsClient}
+	a.SetPolicy(events.ApplyPolicy{RequireApproval: true, RequiredApprovers: []string{"org/payments"}})
+
+	r := a.Execute(&applyCtx)
+	Assert(t, r.Error != nil, "exp an error")
+	Equals(t, "checking if pull request was approved: checking if bob is a member of org/payments: rate limited", r.Error.Error())
+}
+
+func TestApplyExecutor_NoSelfApproval(t *testing.T) {
+	t.Log("approvals by the pull request's author or the user running apply shouldn't count")
+	RegisterMockTestingT(t)
+	ctx := applyCtx
+	ctx.Pull.Author = "dave"
+	cases := []struct {
+		Description string
+		Approvers   []string
+		ExpFailure  string
+	}{
+		{
+			"approved by the author",
+			[]string{"dave"},
+			"Pull request was only approved by dave. It must be approved by someone other than its author and the user running apply.",
+		},
+		{
+			"approved by the user running apply",
+			[]string{"alice"},
+			"Pull request was only approved by alice. It must be approved by someone other than its author and the user running apply.",
+		},
+		{
+			"not approved",
+			nil,
+			"Pull request must be approved before running apply.",
+		},
+		{
+			"also approved by someone else",
+			[]string{"dave", "bob"},
+			"No workspace found. Did you run plan?",
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.Description)
+		vcsClient := vcsmocks.NewMockClientProxy()
+		When(vcsClient.GetApprovers(ctx.Context, ctx.BaseRepo, ctx.Pull, ctx.VCSHost)).ThenReturn(c.Approvers, nil)
+		workspace := mocks.NewMockWorkspace()
+		When(workspace.GetWorkspace(ctx.BaseRepo, ctx.Pull, "production")).ThenReturn("", errors.New("not found"))
+		a := &events.ApplyExecutor{VCSClient: vcsClient, Workspace: workspace}
+		a.SetPolicy(events.ApplyPolicy{RequireApproval: true, NoSelfApproval: true})
+
+		r := a.Execute(&ctx)
+		Ok(t, r.Error)
+		Equals(t, c.ExpFailure, r.Failure)
+	}
+}
+
+func TestApplyExecutor_NoSelfApprovalRequiredApprovers(t *testing.T) {
+	t.Log("a required approver shouldn't be able to approve their own pull request")
+	RegisterMockTestingT(t)
+	ctx := applyCtx
+	ctx.Pull.Author = "bob"
+	vcsClient := vcsmocks.NewMockClientProxy()
+	When(vcsClient.GetApprovers(ctx.Context, ctx.BaseRepo, ctx.Pull, ctx.VCSHost)).ThenReturn([]string{"bob"}, nil)
+	a := &events.ApplyExecutor{VCSClient: vcsClient}
+	a.SetPolicy(events.ApplyPolicy{RequireApproval: true, RequiredApprovers: []string{"bob"}, NoSelfApproval: true})
+
+	r := a.Execute(&ctx)
+	Ok(t, r.Error)
+	Equals(t, "Pull request was only approved by bob. It must be approved by someone other than its author and the user running apply.", r.Failure)
+}
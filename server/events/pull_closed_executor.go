@@ -2,6 +2,7 @@ package events
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 	"text/template"
@@ -24,6 +25,9 @@ type PullClosedExecutor struct {
 	Locker    locking.Locker
 	VCSClient vcs.ClientProxy
 	Workspace Workspace
+	// ApplyRollup, if set, forgets which projects of the pull request were
+	// applied.
+	ApplyRollup *ApplyRollup
 }
 
 type templatedProject struct {
@@ -42,6 +46,10 @@ func (p *PullClosedExecutor) CleanUpPull(repo models.Repo, pull models.PullReque
 		return errors.Wrap(err, "cleaning workspace")
 	}
 
+	if err := p.ApplyRollup.Forget(repo, pull); err != nil {
+		return errors.Wrap(err, "cleaning up applied projects")
+	}
+
 	// finally, delete locks. We do this last because when someone
 	// unlocks a project, right now we don't actually delete the plan
 	// so we might have plans laying around but no locks
@@ -60,7 +68,7 @@ func (p *PullClosedExecutor) CleanUpPull(repo models.Repo, pull models.PullReque
 	if err = pullClosedTemplate.Execute(&buf, templateData); err != nil {
 		return errors.Wrap(err, "rendering template for comment")
 	}
-	return p.VCSClient.CreateComment(repo, pull, buf.String(), host)
+	return p.VCSClient.CreateComment(context.Background(), repo, pull, buf.String(), host)
 }
 
 // buildTemplateData formats the lock data into a slice that can easily be templated
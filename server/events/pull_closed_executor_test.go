@@ -58,7 +58,7 @@ func TestCleanUpPullNoLocks(t *testing.T) {
 	When(l.UnlockByPull(fixtures.Repo.FullName, fixtures.Pull.Num)).ThenReturn(nil, nil)
 	err := pce.CleanUpPull(fixtures.Repo, fixtures.Pull, vcs.Github)
 	Ok(t, err)
-	cp.VerifyWasCalled(Never()).CreateComment(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost())
+	cp.VerifyWasCalled(Never()).CreateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost())
 }
 
 func TestCleanUpPullComments(t *testing.T) {
@@ -139,7 +139,7 @@ func TestCleanUpPullComments(t *testing.T) {
 		When(l.UnlockByPull(fixtures.Repo.FullName, fixtures.Pull.Num)).ThenReturn(c.Locks, nil)
 		err := pce.CleanUpPull(fixtures.Repo, fixtures.Pull, vcs.Github)
 		Ok(t, err)
-		_, _, comment, _ := cp.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost()).GetCapturedArguments()
+		_, _, _, comment, _ := cp.VerifyWasCalledOnce().CreateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost()).GetCapturedArguments()
 
 		expected := "Locks and plans deleted for the projects and environments modified in this pull request:\n\n" + c.Exp
 		Equals(t, expected, comment)
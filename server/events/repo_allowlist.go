@@ -0,0 +1,75 @@
+package events
+
+import (
+	"fmt"
+	"path"
+	"sync"
+)
+
+// RepoAllowlist is the repos that Atlantis runs commands for. Commands on
+// other repos are ignored. A nil RepoAllowlist allows every repo.
+type RepoAllowlist struct {
+	// Repos are the full names of the allowed repos, ex. "owner/repo". They
+	// can be glob patterns, ex. "owner/*".
+	Repos []string
+	// Comment, if set, is commented on a pull request the first time a command
+	// is ignored on it because its repo isn't allowed, ex. to say who to ask
+	// to set the repo up. If empty, commands are ignored silently so it isn't
+	// revealed which repos are served.
+	Comment string
+
+	mutex sync.Mutex
+	// commented are the pull requests that Comment has been commented on,
+	// keyed by "owner/repo#num".
+	commented map[string]bool
+}
+
+// NewRepoAllowlist returns an allowlist of repos. If repos is empty, it
+// returns nil so every repo is allowed.
+func NewRepoAllowlist(repos []string, comment string) (*RepoAllowlist, error) {
+	if len(repos) == 0 {
+		return nil, nil
+	}
+	for _, r := range repos {
+		if _, err := path.Match(r, ""); err != nil {
+			return nil, fmt.Errorf("invalid repo pattern %q: %s", r, err)
+		}
+	}
+	return &RepoAllowlist{
+		Repos:     repos,
+		Comment:   comment,
+		commented: make(map[string]bool),
+	}, nil
+}
+
+// IsAllowed returns true if Atlantis runs commands for the repo with
+// repoFullName.
+func (a *RepoAllowlist) IsAllowed(repoFullName string) bool {
+	if a == nil {
+		return true
+	}
+	for _, r := range a.Repos {
+		if matched, _ := path.Match(r, repoFullName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ShouldComment returns true if Comment should be commented on the pull
+// request of a repo that isn't allowed. It only returns true once per pull
+// request so repeated commands don't add more comments. Which pull requests
+// were commented on is forgotten when Atlantis restarts.
+func (a *RepoAllowlist) ShouldComment(repoFullName string, pullNum int) bool {
+	if a == nil || a.Comment == "" {
+		return false
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	key := fmt.Sprintf("%s#%d", repoFullName, pullNum)
+	if a.commented[key] {
+		return false
+	}
+	a.commented[key] = true
+	return true
+}
@@ -0,0 +1,45 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestRepoAllowlist_IsAllowed(t *testing.T) {
+	t.Log("repos should be allowed if they match one of the allowlist's patterns")
+	a, err := events.NewRepoAllowlist([]string{"owner/repo", "infra/*"}, "")
+	Ok(t, err)
+	Equals(t, true, a.IsAllowed("owner/repo"))
+	Equals(t, true, a.IsAllowed("infra/network"))
+	Equals(t, false, a.IsAllowed("owner/other"))
+	Equals(t, false, a.IsAllowed("infra/team/nested"))
+}
+
+func TestRepoAllowlist_Empty(t *testing.T) {
+	t.Log("an empty allowlist should allow every repo and never comment")
+	a, err := events.NewRepoAllowlist(nil, "comment")
+	Ok(t, err)
+	Equals(t, true, a.IsAllowed("owner/repo"))
+	Equals(t, false, a.ShouldComment("owner/repo", 1))
+}
+
+func TestRepoAllowlist_InvalidPattern(t *testing.T) {
+	t.Log("an allowlist with an invalid pattern should be an error")
+	_, err := events.NewRepoAllowlist([]string{"owner/[repo"}, "")
+	Assert(t, err != nil, "expected error")
+}
+
+func TestRepoAllowlist_ShouldComment(t *testing.T) {
+	t.Log("the comment should only be commented once per pull request and never if it's empty")
+	a, err := events.NewRepoAllowlist([]string{"owner/repo"}, "Ask #platform.")
+	Ok(t, err)
+	Equals(t, true, a.ShouldComment("other/repo", 1))
+	Equals(t, false, a.ShouldComment("other/repo", 1))
+	Equals(t, true, a.ShouldComment("other/repo", 2))
+
+	silent, err := events.NewRepoAllowlist([]string{"owner/repo"}, "")
+	Ok(t, err)
+	Equals(t, false, silent.ShouldComment("other/repo", 1))
+}
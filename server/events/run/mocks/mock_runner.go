@@ -19,8 +19,8 @@ func NewMockRunner() *MockRunner {
 	return &MockRunner{fail: pegomock.GlobalFailHandler}
 }
 
-func (mock *MockRunner) Execute(log *logging.SimpleLogger, commands []string, path string, environment string, terraformVersion *go_version.Version, stage string) (string, error) {
-	params := []pegomock.Param{log, commands, path, environment, terraformVersion, stage}
+func (mock *MockRunner) Execute(log *logging.SimpleLogger, commands []string, path string, environment string, terraformVersion *go_version.Version, stage string, awsProfile string) (string, error) {
+	params := []pegomock.Param{log, commands, path, environment, terraformVersion, stage, awsProfile}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("Execute", params, []reflect.Type{reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 string
 	var ret1 error
@@ -53,8 +53,8 @@ type VerifierRunner struct {
 	inOrderContext         *pegomock.InOrderContext
 }
 
-func (verifier *VerifierRunner) Execute(log *logging.SimpleLogger, commands []string, path string, environment string, terraformVersion *go_version.Version, stage string) *Runner_Execute_OngoingVerification {
-	params := []pegomock.Param{log, commands, path, environment, terraformVersion, stage}
+func (verifier *VerifierRunner) Execute(log *logging.SimpleLogger, commands []string, path string, environment string, terraformVersion *go_version.Version, stage string, awsProfile string) *Runner_Execute_OngoingVerification {
+	params := []pegomock.Param{log, commands, path, environment, terraformVersion, stage, awsProfile}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Execute", params)
 	return &Runner_Execute_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -64,12 +64,12 @@ type Runner_Execute_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *Runner_Execute_OngoingVerification) GetCapturedArguments() (*logging.SimpleLogger, []string, string, string, *go_version.Version, string) {
-	log, commands, path, environment, terraformVersion, stage := c.GetAllCapturedArguments()
-	return log[len(log)-1], commands[len(commands)-1], path[len(path)-1], environment[len(environment)-1], terraformVersion[len(terraformVersion)-1], stage[len(stage)-1]
+func (c *Runner_Execute_OngoingVerification) GetCapturedArguments() (*logging.SimpleLogger, []string, string, string, *go_version.Version, string, string) {
+	log, commands, path, environment, terraformVersion, stage, awsProfile := c.GetAllCapturedArguments()
+	return log[len(log)-1], commands[len(commands)-1], path[len(path)-1], environment[len(environment)-1], terraformVersion[len(terraformVersion)-1], stage[len(stage)-1], awsProfile[len(awsProfile)-1]
 }
 
-func (c *Runner_Execute_OngoingVerification) GetAllCapturedArguments() (_param0 []*logging.SimpleLogger, _param1 [][]string, _param2 []string, _param3 []string, _param4 []*go_version.Version, _param5 []string) {
+func (c *Runner_Execute_OngoingVerification) GetAllCapturedArguments() (_param0 []*logging.SimpleLogger, _param1 [][]string, _param2 []string, _param3 []string, _param4 []*go_version.Version, _param5 []string, _param6 []string) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]*logging.SimpleLogger, len(params[0]))
@@ -96,6 +96,10 @@ func (c *Runner_Execute_OngoingVerification) GetAllCapturedArguments() (_param0
 		for u, param := range params[5] {
 			_param5[u] = param.(string)
 		}
+		_param6 = make([]string, len(params[6]))
+		for u, param := range params[6] {
+			_param6[u] = param.(string)
+		}
 	}
 	return
 }
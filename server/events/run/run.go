@@ -20,20 +20,22 @@ const inlineShebang = "#!/bin/sh -e"
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_runner.go Runner
 
 type Runner interface {
-	Execute(log *logging.SimpleLogger, commands []string, path string, environment string, terraformVersion *version.Version, stage string) (string, error)
+	Execute(log *logging.SimpleLogger, commands []string, path string, environment string, terraformVersion *version.Version, stage string, awsProfile string) (string, error)
 }
 
 type Run struct{}
 
 // Execute runs the commands by writing them as a script to disk
-// and then executing the script.
+// and then executing the script. If awsProfile is set, the script is run with
+// AWS_PROFILE set to it.
 func (p *Run) Execute(
 	log *logging.SimpleLogger,
 	commands []string,
 	path string,
 	environment string,
 	terraformVersion *version.Version,
-	stage string) (string, error) {
+	stage string,
+	awsProfile string) (string, error) {
 	// we create a script from the commands provided
 	if len(commands) == 0 {
 		return "", errors.Errorf("%s commands cannot be empty", stage)
@@ -50,10 +52,17 @@ func (p *Run) Execute(
 	// set environment variable for the run.
 	// this is to support scripts to use the ENVIRONMENT, ATLANTIS_TERRAFORM_VERSION
 	// and WORKSPACE variables in their scripts
-	os.Setenv("ENVIRONMENT", environment)                              // nolint: errcheck
-	os.Setenv("ATLANTIS_TERRAFORM_VERSION", terraformVersion.String()) // nolint: errcheck
-	os.Setenv("WORKSPACE", path)                                       // nolint: errcheck
-	return execute(s)
+	// They're set on the script rather than our own environment since
+	// commands for other pull requests run at the same time.
+	env := append(os.Environ(),
+		fmt.Sprintf("ENVIRONMENT=%s", environment),
+		fmt.Sprintf("ATLANTIS_TERRAFORM_VERSION=%s", terraformVersion.String()),
+		fmt.Sprintf("WORKSPACE=%s", path),
+	)
+	if awsProfile != "" {
+		env = append(env, fmt.Sprintf("AWS_PROFILE=%s", awsProfile))
+	}
+	return execute(s, env)
 }
 
 func createScript(cmds []string, stage string) (string, error) {
@@ -86,8 +95,9 @@ func createScript(cmds []string, stage string) (string, error) {
 	return scriptName, nil
 }
 
-func execute(script string) (string, error) {
+func execute(script string, env []string) (string, error) {
 	localCmd := exec.Command("sh", "-c", script)
+	localCmd.Env = env
 	out, err := localCmd.CombinedOutput()
 	output := string(out)
 	if err != nil {
@@ -21,14 +21,14 @@ func TestRunCreateScript_valid(t *testing.T) {
 func TestRunExecuteScript_invalid(t *testing.T) {
 	cmds := []string{"invalid", "command"}
 	scriptName, _ := createScript(cmds, "post_apply")
-	_, err := execute(scriptName)
+	_, err := execute(scriptName, nil)
 	Assert(t, err != nil, "there should be an error")
 }
 
 func TestRunExecuteScript_valid(t *testing.T) {
 	cmds := []string{"echo", "date"}
 	scriptName, _ := createScript(cmds, "post_apply")
-	output, err := execute(scriptName)
+	output, err := execute(scriptName, nil)
 	Assert(t, err == nil, "there should not be an error")
 	Assert(t, output != "", "there should be output")
 }
@@ -36,6 +36,15 @@ func TestRunExecuteScript_valid(t *testing.T) {
 func TestRun_valid(t *testing.T) {
 	cmds := []string{"echo", "date"}
 	version, _ := version.NewVersion("0.8.8")
-	_, err := run.Execute(logger, cmds, "/tmp/atlantis", "staging", version, "post_apply")
+	_, err := run.Execute(logger, cmds, "/tmp/atlantis", "staging", version, "post_apply", "")
 	Ok(t, err)
 }
+
+func TestRun_Env(t *testing.T) {
+	t.Log("the script should get the command's environment and AWS profile")
+	cmds := []string{`echo "$ENVIRONMENT $ATLANTIS_TERRAFORM_VERSION $WORKSPACE $AWS_PROFILE"`}
+	version, _ := version.NewVersion("0.8.8")
+	output, err := run.Execute(logger, cmds, "/tmp/atlantis", "staging", version, "post_apply", "atlantis-1")
+	Ok(t, err)
+	Equals(t, "staging 0.8.8 /tmp/atlantis atlantis-1\n", output)
+}
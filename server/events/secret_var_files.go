@@ -0,0 +1,74 @@
+package events
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// redactedSecret replaces the values from secret var files in terraform output.
+const redactedSecret = "<redacted>"
+
+// secretValueRegex matches assignments of quoted strings in a .tfvars file,
+// ex. db_password = "hunter2".
+var secretValueRegex = regexp.MustCompile(`(?m)^\s*[\w-]+\s*=\s*"((?:[^"\\]|\\.)*)"`)
+
+// SecretVarFiles maps an environment to a .tfvars file that's stored outside
+// of the repo, ex. one mounted into the container from a secrets store. The
+// file is passed to terraform plan with -var-file so secrets never need to be
+// committed.
+type SecretVarFiles map[string]string
+
+// Validate returns an error if any of the files aren't absolute paths or are
+// inside dataDir. Repos are cloned into dataDir so a file inside it could end
+// up being committed or read by another pull request.
+func (s SecretVarFiles) Validate(dataDir string) error {
+	absDataDir, err := filepath.Abs(dataDir)
+	if err != nil {
+		return errors.Wrapf(err, "resolving %s", dataDir)
+	}
+	for env, path := range s {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("secret var file for environment %q must be an absolute path", env)
+		}
+		rel, err := filepath.Rel(absDataDir, filepath.Clean(path))
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("secret var file for environment %q must be outside the data dir %s", env, dataDir)
+		}
+	}
+	return nil
+}
+
+// Load reads the secret var file for env. It returns the path to pass to
+// -var-file and the values in the file that must be redacted from output.
+// The file is read on every call so changes to it are picked up without a
+// restart. If there's no file for env, the path is empty.
+func (s SecretVarFiles) Load(env string) (string, []string, error) {
+	path, ok := s[env]
+	if !ok {
+		return "", nil, nil
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "reading secret var file for environment %q", env)
+	}
+	var secrets []string
+	for _, match := range secretValueRegex.FindAllStringSubmatch(string(contents), -1) {
+		if match[1] != "" {
+			secrets = append(secrets, match[1])
+		}
+	}
+	return path, secrets, nil
+}
+
+// RedactSecrets replaces each of secrets in s.
+func RedactSecrets(s string, secrets []string) string {
+	for _, secret := range secrets {
+		s = strings.Replace(s, secret, redactedSecret, -1)
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestSecretVarFiles_Validate(t *testing.T) {
+	t.Log("secret var files must be absolute paths outside the data dir")
+	cases := []struct {
+		path   string
+		expErr string
+	}{
+		{"/etc/secrets/prod.tfvars", ""},
+		{"/data-other/prod.tfvars", ""},
+		{"secrets/prod.tfvars", "secret var file for environment \"prod\" must be an absolute path"},
+		{"/data/repos/owner/repo/prod.tfvars", "secret var file for environment \"prod\" must be outside the data dir /data"},
+		{"/data/../data/prod.tfvars", "secret var file for environment \"prod\" must be outside the data dir /data"},
+	}
+	for _, c := range cases {
+		err := events.SecretVarFiles{"prod": c.path}.Validate("/data")
+		if c.expErr == "" {
+			Ok(t, err)
+		} else {
+			Assert(t, err != nil, "expected error for %s", c.path)
+			Equals(t, c.expErr, err.Error())
+		}
+	}
+}
+
+func TestSecretVarFiles_Load(t *testing.T) {
+	t.Log("Load should return the path and the quoted values in the file")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmpDir) // nolint: errcheck
+	path := filepath.Join(tmpDir, "prod.tfvars")
+	Ok(t, ioutil.WriteFile(path, []byte("db_password = \"hunter2\"\napi_key=\"abc\\\"def\"\ncount = 3\nempty = \"\"\n"), 0600))
+
+	s := events.SecretVarFiles{"prod": path}
+	loadedPath, secrets, err := s.Load("prod")
+	Ok(t, err)
+	Equals(t, path, loadedPath)
+	Equals(t, []string{"hunter2", "abc\\\"def"}, secrets)
+
+	loadedPath, secrets, err = s.Load("staging")
+	Ok(t, err)
+	Equals(t, "", loadedPath)
+	Equals(t, 0, len(secrets))
+
+	_, _, err = events.SecretVarFiles{"prod": filepath.Join(tmpDir, "missing")}.Load("prod")
+	Assert(t, err != nil, "expected error for missing file")
+}
+
+func TestRedactSecrets(t *testing.T) {
+	t.Log("RedactSecrets should replace every occurrence of each secret")
+	Equals(t, "password: <redacted>, again <redacted>, key <redacted>", events.RedactSecrets("password: hunter2, again hunter2, key abc", []string{"hunter2", "abc"}))
+}
@@ -0,0 +1,146 @@
+package events
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// stsEndpoint, if set, is where roles are assumed instead of the STS
+// endpoint of the task's region. It's set by tests.
+var stsEndpoint string
+
+// stsSessionDuration is how long assumed credentials last. They're assumed
+// again before they expire like the task role's credentials.
+const stsSessionDuration = time.Hour
+
+// assumeRole assumes roleArn with credentials, ex. the task role's, and
+// returns the role's credentials. It calls STS itself so the credentials can
+// be written for tools that can't assume roles from a profile.
+func assumeRole(credentials *EcsCredentials, roleArn string) (*EcsCredentials, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	endpoint := stsEndpoint
+	if endpoint == "" && region != "" {
+		endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com/", region)
+	}
+	if endpoint == "" {
+		// The global endpoint is signed for us-east-1.
+		endpoint, region = "https://sts.amazonaws.com/", "us-east-1"
+	}
+	body := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {roleArn},
+		"RoleSessionName": {"atlantis"},
+		"DurationSeconds": {fmt.Sprintf("%d", int(stsSessionDuration.Seconds()))},
+	}.Encode()
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signSTSRequest(req, []byte(body), credentials, region, time.Now())
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var stsErr struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		if xml.Unmarshal(raw, &stsErr) == nil && stsErr.Code != "" {
+			return nil, fmt.Errorf("STS responded with status %d: %s: %s", resp.StatusCode, stsErr.Code, stsErr.Message)
+		}
+		return nil, fmt.Errorf("STS responded with status %d", resp.StatusCode)
+	}
+	var result struct {
+		AccessKeyID     string `xml:"AssumeRoleResult>Credentials>AccessKeyId"`
+		SecretAccessKey string `xml:"AssumeRoleResult>Credentials>SecretAccessKey"`
+		SessionToken    string `xml:"AssumeRoleResult>Credentials>SessionToken"`
+		Expiration      string `xml:"AssumeRoleResult>Credentials>Expiration"`
+	}
+	if err := xml.Unmarshal(raw, &result); err != nil {
+		return nil, errors.Wrap(err, "parsing STS response")
+	}
+	if result.AccessKeyID == "" {
+		return nil, errors.New("STS response has no credentials")
+	}
+	return &EcsCredentials{
+		AccessKeyId:     result.AccessKeyID,
+		Expiration:      result.Expiration,
+		RoleArn:         roleArn,
+		SecretAccessKey: result.SecretAccessKey,
+		Token:           result.SessionToken,
+	}, nil
+}
+
+// signSTSRequest signs req, whose body is body, for STS in region with
+// credentials using AWS Signature Version 4.
+func signSTSRequest(req *http.Request, body []byte, credentials *EcsCredentials, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.Token)
+	}
+	headers := []string{"content-type", "host", "x-amz-date"}
+	if credentials.Token != "" {
+		headers = append(headers, "x-amz-security-token")
+	}
+	var canonicalHeaders bytes.Buffer
+	for _, h := range headers {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", h, strings.TrimSpace(value))
+	}
+	signedHeaders := strings.Join(headers, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, sha256Hex(body)}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/sts/aws4_request", date, region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	for _, part := range []string{region, "sts", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", credentials.AccessKeyId, scope, signedHeaders, signature))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data)) // nolint: errcheck
+	return h.Sum(nil)
+}
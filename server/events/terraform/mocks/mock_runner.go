@@ -31,8 +31,8 @@ func (mock *MockRunner) Version() *go_version.Version {
 	return ret0
 }
 
-func (mock *MockRunner) RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *go_version.Version, env string) (string, error) {
-	params := []pegomock.Param{log, path, args, v, env}
+func (mock *MockRunner) RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *go_version.Version, env string, command string, awsProfile string) (string, error) {
+	params := []pegomock.Param{log, path, args, v, env, command, awsProfile}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("RunCommandWithVersion", params, []reflect.Type{reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 string
 	var ret1 error
@@ -47,8 +47,8 @@ func (mock *MockRunner) RunCommandWithVersion(log *logging.SimpleLogger, path st
 	return ret0, ret1
 }
 
-func (mock *MockRunner) RunInitAndEnv(log *logging.SimpleLogger, path string, env string, extraInitArgs []string, version *go_version.Version) ([]string, error) {
-	params := []pegomock.Param{log, path, env, extraInitArgs, version}
+func (mock *MockRunner) RunInitAndEnv(log *logging.SimpleLogger, path string, env string, extraInitArgs []string, version *go_version.Version, command string, awsProfile string) ([]string, error) {
+	params := []pegomock.Param{log, path, env, extraInitArgs, version, command, awsProfile}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("RunInitAndEnv", params, []reflect.Type{reflect.TypeOf((*[]string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 []string
 	var ret1 error
@@ -98,8 +98,8 @@ func (c *Runner_Version_OngoingVerification) GetCapturedArguments() {
 func (c *Runner_Version_OngoingVerification) GetAllCapturedArguments() {
 }
 
-func (verifier *VerifierRunner) RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *go_version.Version, env string) *Runner_RunCommandWithVersion_OngoingVerification {
-	params := []pegomock.Param{log, path, args, v, env}
+func (verifier *VerifierRunner) RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *go_version.Version, env string, command string, awsProfile string) *Runner_RunCommandWithVersion_OngoingVerification {
+	params := []pegomock.Param{log, path, args, v, env, command, awsProfile}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "RunCommandWithVersion", params)
 	return &Runner_RunCommandWithVersion_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -109,12 +109,12 @@ type Runner_RunCommandWithVersion_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *Runner_RunCommandWithVersion_OngoingVerification) GetCapturedArguments() (*logging.SimpleLogger, string, []string, *go_version.Version, string) {
-	log, path, args, v, env := c.GetAllCapturedArguments()
-	return log[len(log)-1], path[len(path)-1], args[len(args)-1], v[len(v)-1], env[len(env)-1]
+func (c *Runner_RunCommandWithVersion_OngoingVerification) GetCapturedArguments() (*logging.SimpleLogger, string, []string, *go_version.Version, string, string, string) {
+	log, path, args, v, env, command, awsProfile := c.GetAllCapturedArguments()
+	return log[len(log)-1], path[len(path)-1], args[len(args)-1], v[len(v)-1], env[len(env)-1], command[len(command)-1], awsProfile[len(awsProfile)-1]
 }
 
-func (c *Runner_RunCommandWithVersion_OngoingVerification) GetAllCapturedArguments() (_param0 []*logging.SimpleLogger, _param1 []string, _param2 [][]string, _param3 []*go_version.Version, _param4 []string) {
+func (c *Runner_RunCommandWithVersion_OngoingVerification) GetAllCapturedArguments() (_param0 []*logging.SimpleLogger, _param1 []string, _param2 [][]string, _param3 []*go_version.Version, _param4 []string, _param5 []string, _param6 []string) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]*logging.SimpleLogger, len(params[0]))
@@ -137,12 +137,20 @@ func (c *Runner_RunCommandWithVersion_OngoingVerification) GetAllCapturedArgumen
 		for u, param := range params[4] {
 			_param4[u] = param.(string)
 		}
+		_param5 = make([]string, len(params[5]))
+		for u, param := range params[5] {
+			_param5[u] = param.(string)
+		}
+		_param6 = make([]string, len(params[6]))
+		for u, param := range params[6] {
+			_param6[u] = param.(string)
+		}
 	}
 	return
 }
 
-func (verifier *VerifierRunner) RunInitAndEnv(log *logging.SimpleLogger, path string, env string, extraInitArgs []string, version *go_version.Version) *Runner_RunInitAndEnv_OngoingVerification {
-	params := []pegomock.Param{log, path, env, extraInitArgs, version}
+func (verifier *VerifierRunner) RunInitAndEnv(log *logging.SimpleLogger, path string, env string, extraInitArgs []string, version *go_version.Version, command string, awsProfile string) *Runner_RunInitAndEnv_OngoingVerification {
+	params := []pegomock.Param{log, path, env, extraInitArgs, version, command, awsProfile}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "RunInitAndEnv", params)
 	return &Runner_RunInitAndEnv_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -152,12 +160,12 @@ type Runner_RunInitAndEnv_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *Runner_RunInitAndEnv_OngoingVerification) GetCapturedArguments() (*logging.SimpleLogger, string, string, []string, *go_version.Version) {
-	log, path, env, extraInitArgs, version := c.GetAllCapturedArguments()
-	return log[len(log)-1], path[len(path)-1], env[len(env)-1], extraInitArgs[len(extraInitArgs)-1], version[len(version)-1]
+func (c *Runner_RunInitAndEnv_OngoingVerification) GetCapturedArguments() (*logging.SimpleLogger, string, string, []string, *go_version.Version, string, string) {
+	log, path, env, extraInitArgs, version, command, awsProfile := c.GetAllCapturedArguments()
+	return log[len(log)-1], path[len(path)-1], env[len(env)-1], extraInitArgs[len(extraInitArgs)-1], version[len(version)-1], command[len(command)-1], awsProfile[len(awsProfile)-1]
 }
 
-func (c *Runner_RunInitAndEnv_OngoingVerification) GetAllCapturedArguments() (_param0 []*logging.SimpleLogger, _param1 []string, _param2 []string, _param3 [][]string, _param4 []*go_version.Version) {
+func (c *Runner_RunInitAndEnv_OngoingVerification) GetAllCapturedArguments() (_param0 []*logging.SimpleLogger, _param1 []string, _param2 []string, _param3 [][]string, _param4 []*go_version.Version, _param5 []string, _param6 []string) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]*logging.SimpleLogger, len(params[0]))
@@ -180,6 +188,14 @@ func (c *Runner_RunInitAndEnv_OngoingVerification) GetAllCapturedArguments() (_p
 		for u, param := range params[4] {
 			_param4[u] = param.(*go_version.Version)
 		}
+		_param5 = make([]string, len(params[5]))
+		for u, param := range params[5] {
+			_param5[u] = param.(string)
+		}
+		_param6 = make([]string, len(params[6]))
+		for u, param := range params[6] {
+			_param6[u] = param.(string)
+		}
 	}
 	return
 }
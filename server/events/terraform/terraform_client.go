@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 
 	"strings"
+	"syscall"
 
 	version "github.com/hashicorp/go-version"
 	"github.com/hootsuite/atlantis/server/logging"
@@ -18,12 +20,62 @@ import (
 
 type Runner interface {
 	Version() *version.Version
-	RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *version.Version, env string) (string, error)
-	RunInitAndEnv(log *logging.SimpleLogger, path string, env string, extraInitArgs []string, version *version.Version) ([]string, error)
+	RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *version.Version, env string, command string, awsProfile string) (string, error)
+	RunInitAndEnv(log *logging.SimpleLogger, path string, env string, extraInitArgs []string, version *version.Version, command string, awsProfile string) ([]string, error)
 }
 
 type Client struct {
 	defaultVersion *version.Version
+	// NotFoundMessage is added to the error when the terraform executable for
+	// a version isn't installed, ex. to say who to ask to install it.
+	NotFoundMessage string
+}
+
+// ExecutableNotFoundError is returned when the terraform executable for the
+// version a command needs isn't installed, or the project's custom command
+// isn't.
+type ExecutableNotFoundError struct {
+	Executable string
+	Version    string
+	Message    string
+	// Custom is true if Executable is the project's custom command rather
+	// than terraform.
+	Custom bool
+}
+
+func (e *ExecutableNotFoundError) Error() string {
+	msg := fmt.Sprintf("Terraform %s is needed but isn't installed on the Atlantis server: %q was not found in $PATH.", e.Version, e.Executable)
+	if e.Custom {
+		msg = fmt.Sprintf("The project's command %q isn't installed on the Atlantis server: it was not found in $PATH or isn't executable.", e.Executable)
+	}
+	if e.Message != "" {
+		msg += " " + e.Message
+	}
+	return msg
+}
+
+// CommandError is returned when a terraform command exits with an error.
+type CommandError struct {
+	// Err is the error from running the command.
+	Err     error
+	Command string
+	Path    string
+	Output  string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%s: running %q in %q: \n%s", e.Err, e.Command, e.Path, e.Output)
+}
+
+// ExitCode returns the command's exit code or -1 if it didn't exit, ex. it
+// couldn't be started.
+func (e *CommandError) ExitCode() int {
+	if exitErr, ok := e.Err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus()
+		}
+	}
+	return -1
 }
 
 var versionRegex = regexp.MustCompile("Terraform v(.*)\n")
@@ -62,12 +114,35 @@ func (c *Client) Version() *version.Version {
 // RunCommandWithVersion executes the provided version of terraform with
 // the provided args in path. The variable "v" is the version of terraform executable to use and the variable "env" is the
 // environment specified by the user commenting "atlantis plan/apply {env}" which is set to "default" by default.
-func (c *Client) RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *version.Version, env string) (string, error) {
+// If command is set, it's run with the args instead of terraform, ex. a
+// wrapper script or terragrunt. It can be a path relative to path.
+// If awsProfile is set, it's run with AWS_PROFILE set to it, ex. the profile
+// with the command's ECS credentials.
+func (c *Client) RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *version.Version, env string, command string, awsProfile string) (string, error) {
 	tfExecutable := "terraform"
 	// if version is the same as the default, don't need to prepend the version name to the executable
 	if !v.Equal(c.defaultVersion) {
 		tfExecutable = fmt.Sprintf("%s%s", tfExecutable, v.String())
 	}
+	versionedExecutable := tfExecutable
+	if command != "" {
+		tfExecutable = command
+	}
+	// We run terraform through sh so if the executable is missing we'd only
+	// get a cryptic exit status. Check for it first so we can say what's
+	// wrong.
+	lookPath := tfExecutable
+	if strings.Contains(lookPath, "/") && !filepath.IsAbs(lookPath) {
+		lookPath = filepath.Join(path, lookPath)
+	}
+	if _, err := exec.LookPath(lookPath); err != nil {
+		if command != "" {
+			log.Err("command %q not found in $PATH or %q", command, path)
+			return "", &ExecutableNotFoundError{Executable: command, Version: v.String(), Message: c.NotFoundMessage, Custom: true}
+		}
+		log.Err("terraform executable %q for version %s not found in $PATH", tfExecutable, v)
+		return "", &ExecutableNotFoundError{Executable: tfExecutable, Version: v.String(), Message: c.NotFoundMessage}
+	}
 
 	// set environment variables
 	// this is to support scripts to use the ENVIRONMENT, ATLANTIS_TERRAFORM_VERSION
@@ -79,7 +154,17 @@ func (c *Client) RunCommandWithVersion(log *logging.SimpleLogger, path string, a
 		fmt.Sprintf("ATLANTIS_TERRAFORM_VERSION=%s", v.String()),
 		fmt.Sprintf("WORKSPACE=%s", path),
 	}
+	// terragrunt runs the terraform executable for the version the project
+	// needs and must never wait for input since there's no one to answer.
+	if filepath.Base(command) == "terragrunt" {
+		envVars = append(envVars, fmt.Sprintf("TERRAGRUNT_TFPATH=%s", versionedExecutable), "TERRAGRUNT_NON_INTERACTIVE=true")
+	}
 	envVars = append(envVars, os.Environ()...)
+	// This comes after our own environment so it overrides any AWS_PROFILE
+	// that Atlantis was started with.
+	if awsProfile != "" {
+		envVars = append(envVars, fmt.Sprintf("AWS_PROFILE=%s", awsProfile))
+	}
 
 	// append terraform executable name with args
 	tfCmd := fmt.Sprintf("%s %s", tfExecutable, strings.Join(args, " "))
@@ -90,7 +175,7 @@ func (c *Client) RunCommandWithVersion(log *logging.SimpleLogger, path string, a
 	out, err := terraformCmd.CombinedOutput()
 	commandStr := strings.Join(terraformCmd.Args, " ")
 	if err != nil {
-		err = fmt.Errorf("%s: running %q in %q: \n%s", err, commandStr, path, out)
+		err = &CommandError{Err: err, Command: commandStr, Path: path, Output: string(out)}
 		log.Debug("error: %s", err)
 		return string(out), err
 	}
@@ -100,24 +185,25 @@ func (c *Client) RunCommandWithVersion(log *logging.SimpleLogger, path string, a
 
 // RunInitAndEnv executes "terraform init" and "terraform env select" in path.
 // env is the environment to select and extraInitArgs are additional arguments
-// applied to the init command.
-func (c *Client) RunInitAndEnv(log *logging.SimpleLogger, path string, env string, extraInitArgs []string, version *version.Version) ([]string, error) {
+// applied to the init command. command and awsProfile are passed to
+// RunCommandWithVersion.
+func (c *Client) RunInitAndEnv(log *logging.SimpleLogger, path string, env string, extraInitArgs []string, version *version.Version, command string, awsProfile string) ([]string, error) {
 	var outputs []string
 
 	// run terraform init
-	output, err := c.RunCommandWithVersion(log, path, append([]string{"init", "-no-color"}, extraInitArgs...), version, env)
+	output, err := c.RunCommandWithVersion(log, path, append([]string{"init", "-no-color"}, extraInitArgs...), version, env, command, awsProfile)
 	outputs = append(outputs, output)
 	if err != nil {
 		return outputs, err
 	}
 
 	// run terraform env new and select
-	output, err = c.RunCommandWithVersion(log, path, []string{"env", "select", "-no-color", env}, version, env)
+	output, err = c.RunCommandWithVersion(log, path, []string{"env", "select", "-no-color", env}, version, env, command, awsProfile)
 	outputs = append(outputs, output)
 	if err != nil {
 		// if terraform env select fails we will run terraform env new
 		// to create a new environment
-		output, err = c.RunCommandWithVersion(log, path, []string{"env", "new", "-no-color", env}, version, env)
+		output, err = c.RunCommandWithVersion(log, path, []string{"env", "new", "-no-color", env}, version, env, command, awsProfile)
 		outputs = append(outputs, output)
 		if err != nil {
 			return outputs, err
@@ -0,0 +1,316 @@
+package events
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/logging"
+	"github.com/pkg/errors"
+)
+
+// TerraformDistribution is what Atlantis runs in projects.
+type TerraformDistribution string
+
+const (
+	// TerraformDistributionTerraform runs terraform in the projects with
+	// modified .tf files. It's the default.
+	TerraformDistributionTerraform TerraformDistribution = "terraform"
+	// TerraformDistributionTerragrunt runs terragrunt in the terragrunt
+	// modules that are affected by the pull request.
+	TerraformDistributionTerragrunt TerraformDistribution = "terragrunt"
+)
+
+// TerragruntConfigFile is the name of terragrunt's config file.
+const TerragruntConfigFile = "terragrunt.hcl"
+
+// terragruntCacheDir is where terragrunt downloads modules. It's never
+// searched for modules.
+const terragruntCacheDir = ".terragrunt-cache"
+
+// terragruntConfigPathRegex matches the config_path of a dependency block,
+// ex. config_path = "../vpc".
+var terragruntConfigPathRegex = regexp.MustCompile(`(?m)^\s*config_path\s*=\s*"([^"]+)"`)
+
+// terragruntDependenciesRegex matches the paths of a dependencies block, ex.
+// dependencies { paths = ["../vpc", "../db"] }.
+var terragruntDependenciesRegex = regexp.MustCompile(`(?s)dependencies\s*\{\s*paths\s*=\s*\[([^\]]*)\]`)
+
+var quotedRegex = regexp.MustCompile(`"([^"]+)"`)
+
+// Terragrunt finds the terragrunt modules to run commands in and the order to
+// run them in. A module is a directory with a terragrunt.hcl that has no
+// modules below it. Any terragrunt.hcl above modules is a parent config that
+// they include.
+type Terragrunt struct {
+	// EnvironmentDirs maps an environment to the directory, relative to the
+	// repo root, that holds its modules. If it's empty, every module is in
+	// every environment.
+	EnvironmentDirs map[string]string
+}
+
+// terragruntModule is a terragrunt module and what it uses, all relative to
+// the repo root.
+type terragruntModule struct {
+	// Dependencies are the modules in its dependency and dependencies
+	// blocks.
+	Dependencies []string
+	// Sources are the local directories of its terraform source.
+	Sources []string
+}
+
+// ModifiedModules returns the modules in the environment's directory that
+// run-all would need to run for modifiedFiles, in the order to run them in.
+// That's the modules with modified files, the modules below a modified .hcl
+// file, the modules whose local terraform source was modified and every
+// module that depends on one of those. A module's dependencies always come
+// before it.
+func (t *Terragrunt) ModifiedModules(log *logging.SimpleLogger, repoDir string, modifiedFiles []string, env string, repoFullName string) ([]models.Project, error) {
+	envDir, err := t.envDir(env)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := t.findModules(repoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	affected := make(map[string]bool)
+	for _, file := range modifiedFiles {
+		for dir, module := range modules {
+			// Parent configs and the .hcl files they read, ex. with
+			// read_terragrunt_config, are above the modules.
+			isParentConfig := filepath.Ext(file) == ".hcl" && isUnder(dir, filepath.Dir(file))
+			if isUnder(file, dir) || isParentConfig {
+				affected[dir] = true
+				continue
+			}
+			for _, source := range module.Sources {
+				if isUnder(file, source) {
+					affected[dir] = true
+				}
+			}
+		}
+	}
+	// Modules that depend on an affected module might plan differently
+	// since its outputs might change.
+	for changed := true; changed; {
+		changed = false
+		for dir, module := range modules {
+			if affected[dir] {
+				continue
+			}
+			for _, dep := range module.Dependencies {
+				if affected[dep] {
+					affected[dir] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	var paths []string
+	for dir := range affected {
+		if envDir == "" || dir == envDir || isUnder(dir, envDir) {
+			paths = append(paths, dir)
+		}
+	}
+	sorted, err := sortModules(modules, paths)
+	if err != nil {
+		return nil, err
+	}
+	var projects []models.Project
+	for _, path := range sorted {
+		projects = append(projects, models.NewProject(repoFullName, path))
+	}
+	if len(projects) > 0 {
+		log.Info("there are %d affected terragrunt module(s) at path(s): %v", len(projects), strings.Join(sorted, ", "))
+	}
+	return projects, nil
+}
+
+// SortByDependencies returns the modules at paths, relative to repoDir, in
+// the order to run them in and maps each one to the modules in paths that it
+// depends on, directly or not.
+func (t *Terragrunt) SortByDependencies(repoDir string, paths []string) ([]string, map[string][]string, error) {
+	modules, err := t.findModules(repoDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	sorted, err := sortModules(modules, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	included := make(map[string]bool)
+	for _, path := range paths {
+		included[path] = true
+	}
+	deps := make(map[string][]string)
+	for _, path := range sorted {
+		for _, dep := range transitiveDependencies(modules, path, make(map[string]bool)) {
+			if included[dep] {
+				deps[path] = append(deps[path], dep)
+			}
+		}
+		sort.Strings(deps[path])
+	}
+	return sorted, deps, nil
+}
+
+// MapsEnvironment returns true if env has a directory or no environments
+// do.
+func (t *Terragrunt) MapsEnvironment(env string) bool {
+	_, err := t.envDir(env)
+	return err == nil
+}
+
+func (t *Terragrunt) envDir(env string) (string, error) {
+	if len(t.EnvironmentDirs) == 0 {
+		return "", nil
+	}
+	dir, ok := t.EnvironmentDirs[env]
+	if !ok {
+		return "", fmt.Errorf("no terragrunt directory is mapped to the %s environment", env)
+	}
+	return filepath.Clean(dir), nil
+}
+
+// findModules returns the modules in repoDir by their paths relative to it.
+func (t *Terragrunt) findModules(repoDir string) (map[string]terragruntModule, error) {
+	var configDirs []string
+	err := filepath.Walk(repoDir, func(absPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && (info.Name() == ".git" || info.Name() == terragruntCacheDir) {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && info.Name() == TerragruntConfigFile {
+			dir, _ := filepath.Rel(repoDir, filepath.Dir(absPath))
+			configDirs = append(configDirs, dir)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "finding terragrunt modules")
+	}
+
+	modules := make(map[string]terragruntModule)
+	for _, dir := range configDirs {
+		isParent := false
+		for _, other := range configDirs {
+			if other != dir && isUnder(other, dir) {
+				isParent = true
+				break
+			}
+		}
+		if isParent {
+			continue
+		}
+		module, err := readTerragruntModule(repoDir, dir)
+		if err != nil {
+			return nil, err
+		}
+		modules[dir] = module
+	}
+	return modules, nil
+}
+
+func readTerragruntModule(repoDir string, dir string) (terragruntModule, error) {
+	var module terragruntModule
+	raw, err := ioutil.ReadFile(filepath.Join(repoDir, dir, TerragruntConfigFile))
+	if err != nil {
+		return module, errors.Wrapf(err, "reading %s", filepath.Join(dir, TerragruntConfigFile))
+	}
+	config := string(raw)
+	var deps []string
+	for _, match := range terragruntConfigPathRegex.FindAllStringSubmatch(config, -1) {
+		deps = append(deps, match[1])
+	}
+	for _, block := range terragruntDependenciesRegex.FindAllStringSubmatch(config, -1) {
+		for _, match := range quotedRegex.FindAllStringSubmatch(block[1], -1) {
+			deps = append(deps, match[1])
+		}
+	}
+	for _, dep := range deps {
+		// Paths built with functions, ex. ${get_terragrunt_dir()}, can't be
+		// resolved without terragrunt.
+		if strings.Contains(dep, "${") {
+			continue
+		}
+		module.Dependencies = append(module.Dependencies, filepath.Join(dir, dep))
+	}
+	for _, match := range localSourceRegex.FindAllStringSubmatch(config, -1) {
+		module.Sources = append(module.Sources, filepath.Join(dir, match[1]))
+	}
+	return module, nil
+}
+
+// sortModules returns paths ordered so that each module comes after the
+// modules it depends on. Modules that don't depend on each other are sorted
+// by path so the order is stable.
+func sortModules(modules map[string]terragruntModule, paths []string) ([]string, error) {
+	remaining := append([]string{}, paths...)
+	sort.Strings(remaining)
+	included := make(map[string]bool)
+	for _, path := range paths {
+		included[path] = true
+	}
+	done := make(map[string]bool)
+	var sorted []string
+	for len(remaining) > 0 {
+		var next []string
+		for _, path := range remaining {
+			ready := true
+			for _, dep := range transitiveDependencies(modules, path, make(map[string]bool)) {
+				if included[dep] && !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				sorted = append(sorted, path)
+				done[path] = true
+			} else {
+				next = append(next, path)
+			}
+		}
+		if len(next) == len(remaining) {
+			return nil, fmt.Errorf("terragrunt modules %s depend on each other", strings.Join(next, ", "))
+		}
+		remaining = next
+	}
+	return sorted, nil
+}
+
+// transitiveDependencies returns the modules that the module at path depends
+// on, directly or through other modules. seen stops it from looping on cycles.
+func transitiveDependencies(modules map[string]terragruntModule, path string, seen map[string]bool) []string {
+	var deps []string
+	for _, dep := range modules[path].Dependencies {
+		if seen[dep] {
+			continue
+		}
+		seen[dep] = true
+		deps = append(deps, dep)
+		deps = append(deps, transitiveDependencies(modules, dep, seen)...)
+	}
+	return deps
+}
+
+// isUnder returns true if path is inside dir. Both are relative to the repo
+// root. Every path is under ".".
+func isUnder(path string, dir string) bool {
+	path = filepath.Clean(path)
+	dir = filepath.Clean(dir)
+	if dir == "." {
+		return path != "."
+	}
+	return strings.HasPrefix(path, dir+"/")
+}
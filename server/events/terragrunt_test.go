@@ -0,0 +1,123 @@
+package events_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+// terragruntRepo is a terragrunt repo with a parent config for each
+// environment. In each environment, app depends on db which depends on vpc,
+// and dns doesn't depend on anything. vpc's terraform is in the repo.
+var terragruntRepo = map[string]string{
+	"live/production/terragrunt.hcl":     "remote_state {}\n",
+	"live/production/vpc/terragrunt.hcl": "include {\n  path = find_in_parent_folders()\n}\nterraform {\n  source = \"../../../modules//vpc\"\n}\n",
+	"live/production/db/terragrunt.hcl":  "dependency \"vpc\" {\n  config_path = \"../vpc\"\n}\n",
+	"live/production/app/terragrunt.hcl": "dependencies {\n  paths = [\"../db\"]\n}\n",
+	"live/production/dns/terragrunt.hcl": "terraform {\n  source = \"git::https://example.com/dns.git\"\n}\n",
+	"live/staging/terragrunt.hcl":        "remote_state {}\n",
+	"live/staging/vpc/terragrunt.hcl":    "terraform {\n  source = \"../../../modules//vpc\"\n}\n",
+	"live/staging/db/terragrunt.hcl":     "dependency \"vpc\" {\n  config_path = \"../vpc\"\n}\n",
+	"modules/vpc/main.tf":                "resource \"null_resource\" \"a\" {}\n",
+	"README.md":                          "docs\n",
+}
+
+func TestTerragrunt_ModifiedModules(t *testing.T) {
+	t.Log("the modules affected by the modified files and their dependents should be found in dependency order")
+	repoDir := writeRepo(t, terragruntRepo)
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+	tg := events.Terragrunt{}
+
+	cases := []struct {
+		description   string
+		modifiedFiles []string
+		exp           []string
+	}{
+		{
+			"nothing affected",
+			[]string{"README.md"},
+			nil,
+		},
+		{
+			"module without dependents",
+			[]string{"live/production/dns/terragrunt.hcl"},
+			[]string{"live/production/dns"},
+		},
+		{
+			"module with dependents",
+			[]string{"live/production/db/terragrunt.hcl"},
+			[]string{"live/production/db", "live/production/app"},
+		},
+		{
+			"dependents come after their dependencies",
+			[]string{"live/production/app/terragrunt.hcl", "live/production/vpc/terragrunt.hcl"},
+			[]string{"live/production/vpc", "live/production/db", "live/production/app"},
+		},
+		{
+			"parent config",
+			[]string{"live/staging/terragrunt.hcl"},
+			[]string{"live/staging/vpc", "live/staging/db"},
+		},
+		{
+			"local terraform source",
+			[]string{"modules/vpc/main.tf"},
+			[]string{"live/production/vpc", "live/staging/vpc", "live/production/db", "live/staging/db", "live/production/app"},
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.description)
+		projects, err := tg.ModifiedModules(logging.NewNoopLogger(), repoDir, c.modifiedFiles, "default", "owner/repo")
+		Ok(t, err)
+		var exp []models.Project
+		for _, path := range c.exp {
+			exp = append(exp, models.NewProject("owner/repo", path))
+		}
+		Equals(t, exp, projects)
+	}
+}
+
+func TestTerragrunt_ModifiedModulesEnvironmentDirs(t *testing.T) {
+	t.Log("only the modules in the environment's directory should be found")
+	repoDir := writeRepo(t, terragruntRepo)
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+	tg := events.Terragrunt{EnvironmentDirs: map[string]string{"staging": "live/staging", "production": "live/production"}}
+
+	projects, err := tg.ModifiedModules(logging.NewNoopLogger(), repoDir, []string{"modules/vpc/main.tf"}, "staging", "owner/repo")
+	Ok(t, err)
+	Equals(t, []models.Project{models.NewProject("owner/repo", "live/staging/vpc"), models.NewProject("owner/repo", "live/staging/db")}, projects)
+
+	Equals(t, false, tg.MapsEnvironment("qa"))
+	_, err = tg.ModifiedModules(logging.NewNoopLogger(), repoDir, []string{"modules/vpc/main.tf"}, "qa", "owner/repo")
+	Assert(t, err != nil, "expected error")
+}
+
+func TestTerragrunt_SortByDependencies(t *testing.T) {
+	t.Log("modules should be sorted and mapped to the modules they depend on, directly or not")
+	repoDir := writeRepo(t, terragruntRepo)
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+	tg := events.Terragrunt{}
+
+	sorted, deps, err := tg.SortByDependencies(repoDir, []string{"live/production/app", "live/production/dns", "live/production/vpc"})
+	Ok(t, err)
+	Equals(t, []string{"live/production/dns", "live/production/vpc", "live/production/app"}, sorted)
+	Equals(t, []string{"live/production/vpc"}, deps["live/production/app"])
+	Equals(t, 0, len(deps["live/production/dns"]))
+}
+
+func TestTerragrunt_Cycle(t *testing.T) {
+	t.Log("modules that depend on each other should be an error")
+	repoDir := writeRepo(t, map[string]string{
+		"a/terragrunt.hcl": "dependency \"b\" {\n  config_path = \"../b\"\n}\n",
+		"b/terragrunt.hcl": "dependency \"a\" {\n  config_path = \"../a\"\n}\n",
+	})
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+	tg := events.Terragrunt{}
+
+	_, err := tg.ModifiedModules(logging.NewNoopLogger(), repoDir, []string{"a/terragrunt.hcl"}, "default", "owner/repo")
+	Assert(t, err != nil, "expected error")
+	Equals(t, "terragrunt modules a, b depend on each other", err.Error())
+}
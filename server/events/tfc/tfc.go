@@ -0,0 +1,439 @@
+// Package tfc runs plans and applies in Terraform Cloud or Terraform
+// Enterprise workspaces through their API instead of running terraform
+// locally.
+package tfc
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultAddress is the address of Terraform Cloud.
+const DefaultAddress = "https://app.terraform.io"
+
+// timeout is how long we wait for each request to the API. Runs take much
+// longer so we poll them.
+const timeout = 30 * time.Second
+
+// defaultPollInterval is how often we check if a run has finished.
+const defaultPollInterval = 5 * time.Second
+
+// remoteBackendRegex matches the terraform settings that make a project run
+// in Terraform Cloud, ex. backend "remote" {} or cloud {}.
+var remoteBackendRegex = regexp.MustCompile(`(?m)^\s*(backend\s+"remote"|cloud)\s*\{`)
+
+// runIDRegex matches run IDs. They're read back from plan files so we check
+// them before putting them in a URL.
+var runIDRegex = regexp.MustCompile(`^run-[A-Za-z0-9]+$`)
+
+// phasePaths are the API paths of the phases of a run.
+var phasePaths = map[string]string{
+	"plan":  "plans",
+	"apply": "applies",
+}
+
+// ansiRegex matches the colour codes in run logs, which can't be turned off.
+var ansiRegex = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// plannedStatuses are the statuses of a run whose plan finished and that can
+// be applied, or that has nothing to apply if it's planned_and_finished.
+var plannedStatuses = map[string]bool{
+	"planned":              true,
+	"planned_and_finished": true,
+	"cost_estimated":       true,
+	"policy_checked":       true,
+	"policy_override":      true,
+	"post_plan_completed":  true,
+}
+
+// failedStatuses are the statuses of a run that won't go any further.
+var failedStatuses = map[string]bool{
+	"errored":        true,
+	"discarded":      true,
+	"canceled":       true,
+	"force_canceled": true,
+}
+
+// Client calls the Terraform Cloud or Enterprise API as a user or team with
+// Token. Token must not be logged.
+type Client struct {
+	Address      string
+	Token        string
+	Organization string
+	HTTP         *http.Client
+	PollInterval time.Duration
+}
+
+// Run is a Terraform Cloud run.
+type Run struct {
+	ID     string
+	Status string
+	// URL is the run's page in the Terraform Cloud UI.
+	URL        string
+	HasChanges bool
+	// Log is the output of the run's plan or apply, depending on which one
+	// was last waited for.
+	Log string
+}
+
+// Planned returns true if the run's plan succeeded.
+func (r Run) Planned() bool {
+	return plannedStatuses[r.Status]
+}
+
+// Applied returns true if the run's apply succeeded.
+func (r Run) Applied() bool {
+	return r.Status == "applied"
+}
+
+// NewClient returns a client for the organization in the Terraform Cloud or
+// Enterprise at address. If address is empty, DefaultAddress is used.
+func NewClient(address string, token string, organization string) *Client {
+	if address == "" {
+		address = DefaultAddress
+	}
+	return &Client{
+		Address:      strings.TrimSuffix(address, "/"),
+		Token:        token,
+		Organization: organization,
+		HTTP:         &http.Client{Timeout: timeout},
+		PollInterval: defaultPollInterval,
+	}
+}
+
+// UsesRemoteBackend returns true if the terraform files in dir configure a
+// remote backend or a cloud block, which means the project's runs belong in
+// Terraform Cloud.
+func UsesRemoteBackend(dir string) bool {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file)
+		if err == nil && remoteBackendRegex.Match(contents) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidRunID returns true if id looks like a run ID.
+func ValidRunID(id string) bool {
+	return runIDRegex.MatchString(id)
+}
+
+// Plan uploads the project at projectPath in repoDir to workspace and starts
+// a run with message. It waits for the run's plan to finish. The run isn't
+// applied until Apply is called with its ID. If the workspace has a working
+// directory, the whole repo is uploaded since the working directory is
+// relative to the repo root.
+func (c *Client) Plan(ctx context.Context, workspace string, repoDir string, projectPath string, message string) (Run, error) {
+	var ws struct {
+		Data struct {
+			ID         string `json:"id"`
+			Attributes struct {
+				WorkingDirectory string `json:"working-directory"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, "GET", fmt.Sprintf("/api/v2/organizations/%s/workspaces/%s", url.PathEscape(c.Organization), url.PathEscape(workspace)), nil, &ws); err != nil {
+		return Run{}, errors.Wrapf(err, "getting workspace %s", workspace)
+	}
+	uploadDir := filepath.Join(repoDir, projectPath)
+	if ws.Data.Attributes.WorkingDirectory != "" {
+		uploadDir = repoDir
+	}
+
+	var cv struct {
+		Data struct {
+			ID         string `json:"id"`
+			Attributes struct {
+				UploadURL string `json:"upload-url"`
+				Status    string `json:"status"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	cvBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "configuration-versions",
+			"attributes": map[string]interface{}{"auto-queue-runs": false},
+		},
+	}
+	if err := c.do(ctx, "POST", "/api/v2/workspaces/"+ws.Data.ID+"/configuration-versions", cvBody, &cv); err != nil {
+		return Run{}, errors.Wrap(err, "creating configuration version")
+	}
+	if err := c.upload(ctx, cv.Data.Attributes.UploadURL, uploadDir); err != nil {
+		return Run{}, errors.Wrap(err, "uploading configuration")
+	}
+	// Runs can only be created once the upload has been processed.
+	for cv.Data.Attributes.Status != "uploaded" {
+		if cv.Data.Attributes.Status == "errored" {
+			return Run{}, errors.New("configuration version errored")
+		}
+		if err := c.wait(ctx); err != nil {
+			return Run{}, err
+		}
+		if err := c.do(ctx, "GET", "/api/v2/configuration-versions/"+cv.Data.ID, nil, &cv); err != nil {
+			return Run{}, errors.Wrap(err, "getting configuration version")
+		}
+	}
+
+	var created struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	runBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "runs",
+			"attributes": map[string]interface{}{"message": message},
+			"relationships": map[string]interface{}{
+				"workspace":             map[string]interface{}{"data": map[string]string{"type": "workspaces", "id": ws.Data.ID}},
+				"configuration-version": map[string]interface{}{"data": map[string]string{"type": "configuration-versions", "id": cv.Data.ID}},
+			},
+		},
+	}
+	if err := c.do(ctx, "POST", "/api/v2/runs", runBody, &created); err != nil {
+		return Run{}, errors.Wrap(err, "creating run")
+	}
+	return c.waitForRun(ctx, workspace, created.Data.ID, "plan", func(r Run) bool { return r.Planned() })
+}
+
+// Apply applies the run with runID in workspace and waits for the apply to
+// finish. If the run has nothing to apply, it's returned as is.
+func (c *Client) Apply(ctx context.Context, workspace string, runID string, comment string) (Run, error) {
+	if !ValidRunID(runID) {
+		return Run{}, fmt.Errorf("%q is not a run ID", runID)
+	}
+	run, _, err := c.getRun(ctx, workspace, runID)
+	if err != nil {
+		return Run{}, err
+	}
+	if run.Status == "planned_and_finished" || !run.Planned() {
+		return run, nil
+	}
+	if err := c.do(ctx, "POST", "/api/v2/runs/"+runID+"/actions/apply", map[string]string{"comment": comment}, nil); err != nil {
+		return Run{}, errors.Wrapf(err, "applying %s", runID)
+	}
+	return c.waitForRun(ctx, workspace, runID, "apply", func(r Run) bool { return r.Applied() })
+}
+
+// waitForRun polls the run until done returns true for it or it fails. It
+// returns the run with the log of phase, which is "plan" or "apply".
+func (c *Client) waitForRun(ctx context.Context, workspace string, runID string, phase string, done func(Run) bool) (Run, error) {
+	for {
+		run, phaseIDs, err := c.getRun(ctx, workspace, runID)
+		if err != nil {
+			return Run{}, err
+		}
+		if done(run) || failedStatuses[run.Status] {
+			run.Log, err = c.log(ctx, phase, phaseIDs[phase])
+			return run, err
+		}
+		if err := c.wait(ctx); err != nil {
+			return Run{}, err
+		}
+	}
+}
+
+// getRun returns the run and the IDs of its plan and apply, keyed by "plan"
+// and "apply".
+func (c *Client) getRun(ctx context.Context, workspace string, runID string) (Run, map[string]string, error) {
+	var resp struct {
+		Data struct {
+			Attributes struct {
+				Status     string `json:"status"`
+				HasChanges bool   `json:"has-changes"`
+			} `json:"attributes"`
+			Relationships map[string]struct {
+				Data struct {
+					ID string `json:"id"`
+				} `json:"data"`
+			} `json:"relationships"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, "GET", "/api/v2/runs/"+runID, nil, &resp); err != nil {
+		return Run{}, nil, errors.Wrapf(err, "getting run %s", runID)
+	}
+	phaseIDs := map[string]string{
+		"plan":  resp.Data.Relationships["plan"].Data.ID,
+		"apply": resp.Data.Relationships["apply"].Data.ID,
+	}
+	return Run{
+		ID:         runID,
+		Status:     resp.Data.Attributes.Status,
+		URL:        fmt.Sprintf("%s/app/%s/workspaces/%s/runs/%s", c.Address, url.PathEscape(c.Organization), url.PathEscape(workspace), runID),
+		HasChanges: resp.Data.Attributes.HasChanges,
+	}, phaseIDs, nil
+}
+
+// log returns the log of the plan or apply with id, without colours.
+func (c *Client) log(ctx context.Context, phase string, id string) (string, error) {
+	if id == "" {
+		return "", nil
+	}
+	var resp struct {
+		Data struct {
+			Attributes struct {
+				LogReadURL string `json:"log-read-url"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, "GET", fmt.Sprintf("/api/v2/%s/%s", phasePaths[phase], id), nil, &resp); err != nil {
+		return "", errors.Wrapf(err, "getting %s", phase)
+	}
+	// The log URL is signed so we don't send our token to it.
+	req, err := http.NewRequestWithContext(ctx, "GET", resp.Data.Attributes.LogReadURL, nil)
+	if err != nil {
+		return "", err
+	}
+	logResp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(stripURL(err), "reading %s log", phase)
+	}
+	defer logResp.Body.Close() // nolint: errcheck
+	if logResp.StatusCode < 200 || logResp.StatusCode >= 300 {
+		return "", fmt.Errorf("reading %s log: got status %d", phase, logResp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(logResp.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading %s log", phase)
+	}
+	return ansiRegex.ReplaceAllString(string(b), ""), nil
+}
+
+// upload puts dir as a tar.gz at uploadURL. Like the log URL, the upload URL
+// is signed so we don't send our token to it.
+func (c *Client) upload(ctx context.Context, uploadURL string, dir string) error {
+	archive, err := tarball(dir)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "PUT", uploadURL, archive)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return stripURL(err)
+	}
+	resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("got status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// tarball returns the regular files in dir as a tar.gz. The .git and
+// .terraform directories are left out since they're local state.
+func tarball(dir string) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && (info.Name() == ".git" || info.Name() == ".terraform") {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint: errcheck
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "archiving %s", dir)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// wait sleeps for PollInterval or until ctx is done.
+func (c *Client) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(c.PollInterval):
+		return nil
+	}
+}
+
+// do makes a request to path and decodes the JSON response into out if it's
+// not nil.
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewBuffer(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Address+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/vnd.api+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/vnd.api+json")
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("got status %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// stripURL drops the URL from err since signed URLs are credentials.
+func stripURL(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package tfc_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events/tfc"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+// fakeTFC is a Terraform Cloud that plans run-1 in workspace ws-1 and applies
+// it. statuses are what run-1's status is each time it's fetched.
+type fakeTFC struct {
+	t        *testing.T
+	statuses []string
+	uploaded bool
+	applied  bool
+}
+
+func (f *fakeTFC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/upload" && r.URL.Path != "/log" {
+		Equals(f.t, "Bearer token", r.Header.Get("Authorization"))
+	}
+	switch r.Method + " " + r.URL.Path {
+	case "GET /api/v2/organizations/org/workspaces/prod":
+		fmt.Fprint(w, `{"data": {"id": "ws-1", "attributes": {"working-directory": ""}}}`)
+	case "POST /api/v2/workspaces/ws-1/configuration-versions":
+		fmt.Fprintf(w, `{"data": {"id": "cv-1", "attributes": {"upload-url": "http://%s/upload", "status": "pending"}}}`, r.Host)
+	case "PUT /upload":
+		Equals(f.t, "", r.Header.Get("Authorization"))
+		f.uploaded = true
+	case "GET /api/v2/configuration-versions/cv-1":
+		fmt.Fprint(w, `{"data": {"id": "cv-1", "attributes": {"status": "uploaded"}}}`)
+	case "POST /api/v2/runs":
+		Assert(f.t, f.uploaded, "expected the configuration to be uploaded before the run was created")
+		fmt.Fprint(w, `{"data": {"id": "run-1"}}`)
+	case "GET /api/v2/runs/run-1":
+		status := f.statuses[0]
+		if len(f.statuses) > 1 {
+			f.statuses = f.statuses[1:]
+		}
+		fmt.Fprintf(w, `{"data": {"attributes": {"status": %q, "has-changes": true}, "relationships": {"plan": {"data": {"id": "plan-1"}}, "apply": {"data": {"id": "apply-1"}}}}}`, status)
+	case "GET /api/v2/plans/plan-1", "GET /api/v2/applies/apply-1":
+		fmt.Fprintf(w, `{"data": {"attributes": {"log-read-url": "http://%s/log"}}}`, r.Host)
+	case "GET /log":
+		fmt.Fprint(w, "\x1b[1mPlan:\x1b[0m 1 to add")
+	case "POST /api/v2/runs/run-1/actions/apply":
+		f.applied = true
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	t.Log("Plan should upload the project, create a run and wait for it to be planned")
+	fake := &fakeTFC{t: t, statuses: []string{"pending", "planning", "planned"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	repoDir := writeProject(t)
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+
+	run, err := newClient(server.URL).Plan(context.Background(), "prod", repoDir, "project", "message")
+	Ok(t, err)
+	Equals(t, tfc.Run{
+		ID:         "run-1",
+		Status:     "planned",
+		URL:        server.URL + "/app/org/workspaces/prod/runs/run-1",
+		HasChanges: true,
+		Log:        "Plan: 1 to add",
+	}, run)
+	Assert(t, run.Planned(), "expected the run to be planned")
+}
+
+func TestApply(t *testing.T) {
+	t.Log("Apply should apply the run and wait for it to be applied")
+	fake := &fakeTFC{t: t, statuses: []string{"planned", "applying", "applied"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	run, err := newClient(server.URL).Apply(context.Background(), "prod", "run-1", "comment")
+	Ok(t, err)
+	Assert(t, fake.applied, "expected the run to be applied")
+	Equals(t, "applied", run.Status)
+	Equals(t, "Plan: 1 to add", run.Log)
+}
+
+func TestApply_NothingToApply(t *testing.T) {
+	t.Log("Apply shouldn't apply a run that has no changes")
+	fake := &fakeTFC{t: t, statuses: []string{"planned_and_finished"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	run, err := newClient(server.URL).Apply(context.Background(), "prod", "run-1", "comment")
+	Ok(t, err)
+	Assert(t, !fake.applied, "expected the run not to be applied")
+	Equals(t, "planned_and_finished", run.Status)
+}
+
+func TestApply_InvalidRunID(t *testing.T) {
+	t.Log("Apply should error without calling Terraform Cloud if the run ID isn't one")
+	_, err := tfc.NewClient("http://localhost:1", "token", "org").Apply(context.Background(), "prod", "../../account", "comment")
+	Assert(t, err != nil, "expected error")
+	Equals(t, `"../../account" is not a run ID`, err.Error())
+}
+
+func TestUsesRemoteBackend(t *testing.T) {
+	t.Log("Projects with a remote backend or a cloud block should use Terraform Cloud")
+	cases := map[string]bool{
+		"terraform {\n  backend \"remote\" {\n    organization = \"org\"\n  }\n}\n": true,
+		"terraform {\n  cloud {\n    organization = \"org\"\n  }\n}\n":              true,
+		"terraform {\n  backend \"s3\" {}\n}\n":                                     false,
+	}
+	for contents, exp := range cases {
+		dir, err := ioutil.TempDir("", "")
+		Ok(t, err)
+		Ok(t, ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte(contents), 0644))
+		Equals(t, exp, tfc.UsesRemoteBackend(dir))
+		os.RemoveAll(dir) // nolint: errcheck
+	}
+}
+
+func newClient(address string) *tfc.Client {
+	c := tfc.NewClient(address, "token", "org")
+	c.PollInterval = time.Millisecond
+	return c
+}
+
+func writeProject(t *testing.T) string {
+	repoDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	Ok(t, os.MkdirAll(filepath.Join(repoDir, "project"), 0755))
+	Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "project", "main.tf"), []byte("terraform {\n  cloud {}\n}\n"), 0644))
+	return repoDir
+}
@@ -0,0 +1,97 @@
+package events
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hootsuite/atlantis/server/events/terraform"
+)
+
+// validateWorkspace is the workspace we clone into when running terraform
+// validate. Like fmt, it can't share the workspace of a real environment or
+// cloning would delete its plans.
+const validateWorkspace = ".validate"
+
+// validateInitVersion is the first version of terraform that can install
+// modules and providers with init -backend=false. Earlier versions use
+// terraform get.
+var validateInitVersion = version.Must(version.NewVersion("0.9.0"))
+
+// ValidateExecutor runs terraform validate on the projects modified in the
+// pull request. It doesn't lock, initialize the backend or need credentials so
+// it's a cheap check that can be run before planning.
+type ValidateExecutor struct {
+	Workspace         Workspace
+	ProjectDeterminer ProjectDeterminer
+	ProjectPreExecute *ProjectPreExecute
+}
+
+// ValidateSuccess is the result of running terraform validate in a project
+// whose configuration is valid.
+type ValidateSuccess struct {
+	// Output is what terraform validate printed, ex. warnings.
+	Output string
+}
+
+func (v *ValidateExecutor) Execute(ctx *CommandContext) CommandResponse {
+	projects, err := v.ProjectDeterminer.DetermineProjects(ctx)
+	if err != nil {
+		return CommandResponse{Error: err}
+	}
+	if len(projects) == 0 {
+		return CommandResponse{Failure: "No Terraform files were modified."}
+	}
+
+	cloneDir, err := v.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, validateWorkspace)
+	if err != nil {
+		return CommandResponse{Error: err}
+	}
+
+	results := []ProjectResult{}
+	for _, project := range projects {
+		ctx.Log.Info("running terraform validate for project at path %q", project.Path)
+		result := v.validate(ctx, cloneDir, project.Path)
+		result.Path = project.Path
+		results = append(results, result)
+	}
+	return CommandResponse{ProjectResults: results}
+}
+
+func (v *ValidateExecutor) validate(ctx *CommandContext, repoDir string, path string) ProjectResult {
+	var config ProjectConfig
+	absolutePath := filepath.Join(repoDir, path)
+	if v.ProjectPreExecute.ConfigReader.Exists(absolutePath) {
+		var err error
+		config, err = v.ProjectPreExecute.ConfigReader.Read(absolutePath)
+		if err != nil {
+			return ProjectResult{Error: err}
+		}
+	}
+	terraformVersion := v.ProjectPreExecute.TerraformVersion(config)
+	env := ctx.Command.Environment
+
+	// Modules and providers need to be installed to validate but the backend
+	// doesn't, so we don't need its credentials.
+	initArgs := []string{"get", "-no-color"}
+	if !terraformVersion.LessThan(validateInitVersion) {
+		initArgs = []string{"init", "-backend=false", "-input=false", "-no-color"}
+	}
+	if output, err := v.ProjectPreExecute.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, initArgs, terraformVersion, env, config.Command, ctx.AWSProfile); err != nil {
+		return v.failed(err, output)
+	}
+
+	validateArgs := append([]string{"validate", "-no-color"}, config.GetExtraArgumentsForEnv(ctx.Command.Name.String(), env)...)
+	output, err := v.ProjectPreExecute.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, validateArgs, terraformVersion, env, config.Command, ctx.AWSProfile)
+	if err != nil {
+		return v.failed(err, output)
+	}
+	return ProjectResult{ValidateSuccess: &ValidateSuccess{Output: output}}
+}
+
+func (v *ValidateExecutor) failed(err error, output string) ProjectResult {
+	if notFoundErr, ok := err.(*terraform.ExecutableNotFoundError); ok {
+		return ProjectResult{Failure: notFoundErr.Error()}
+	}
+	return ProjectResult{Error: fmt.Errorf("%s\n%s", err, output)}
+}
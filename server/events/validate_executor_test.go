@@ -0,0 +1,64 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/mocks"
+	"github.com/hootsuite/atlantis/server/events/models"
+	tmocks "github.com/hootsuite/atlantis/server/events/terraform/mocks"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+var validateCtx = &events.CommandContext{
+	Command: &events.Command{
+		Name:        events.Validate,
+		Environment: "default",
+	},
+	Log: logging.NewNoopLogger(),
+}
+
+func TestValidate_NoProjects(t *testing.T) {
+	t.Log("If no projects were modified, validate should fail")
+	v, _ := setupValidateTest(t, nil)
+	r := v.Execute(validateCtx)
+	Equals(t, "No Terraform files were modified.", r.Failure)
+}
+
+func TestValidate(t *testing.T) {
+	t.Log("validate should init each project without its backend and validate it")
+	v, tm := setupValidateTest(t, []models.Project{{Path: "valid"}, {Path: "invalid"}})
+	tfVersion, _ := version.NewVersion("0.11.1")
+	When(tm.Version()).ThenReturn(tfVersion)
+	When(v.Workspace.Clone(validateCtx.Log, validateCtx.BaseRepo, validateCtx.HeadRepo, validateCtx.Pull, ".validate")).ThenReturn("/tmp/clone", nil)
+	initArgs := []string{"init", "-backend=false", "-input=false", "-no-color"}
+	validateArgs := []string{"validate", "-no-color"}
+	When(tm.RunCommandWithVersion(validateCtx.Log, "/tmp/clone/valid", validateArgs, tfVersion, "default", "", "")).ThenReturn("", nil)
+	When(tm.RunCommandWithVersion(validateCtx.Log, "/tmp/clone/invalid", validateArgs, tfVersion, "default", "", "")).
+		ThenReturn("Error: Missing required argument", errors.New("exit status 1"))
+
+	r := v.Execute(validateCtx)
+	Equals(t, 2, len(r.ProjectResults))
+	Equals(t, events.ProjectResult{Path: "valid", ValidateSuccess: &events.ValidateSuccess{}}, r.ProjectResults[0])
+	Equals(t, "invalid", r.ProjectResults[1].Path)
+	Equals(t, "exit status 1\nError: Missing required argument", r.ProjectResults[1].Error.Error())
+	tm.VerifyWasCalledOnce().RunCommandWithVersion(validateCtx.Log, "/tmp/clone/valid", initArgs, tfVersion, "default", "", "")
+	tm.VerifyWasCalledOnce().RunCommandWithVersion(validateCtx.Log, "/tmp/clone/invalid", initArgs, tfVersion, "default", "", "")
+}
+
+func setupValidateTest(t *testing.T, projects []models.Project) (*events.ValidateExecutor, *tmocks.MockRunner) {
+	RegisterMockTestingT(t)
+	tm := tmocks.NewMockRunner()
+	return &events.ValidateExecutor{
+		Workspace:         mocks.NewMockWorkspace(),
+		ProjectDeterminer: &fakeProjectDeterminer{projects, nil},
+		ProjectPreExecute: &events.ProjectPreExecute{
+			ConfigReader: mocks.NewMockProjectConfigReader(),
+			Terraform:    tm,
+		},
+	}, tm
+}
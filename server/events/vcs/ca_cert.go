@@ -0,0 +1,37 @@
+package vcs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// caCertTransport returns the transport that clients of VCS hosts with an
+// internal CA send their requests with. If caCertFile is empty it's
+// http.DefaultTransport, otherwise it's a copy of it that also trusts the PEM
+// encoded CA certificates in caCertFile.
+func caCertTransport(caCertFile string) (http.RoundTripper, error) {
+	if caCertFile == "" {
+		return http.DefaultTransport, nil
+	}
+	pemCerts, err := ioutil.ReadFile(caCertFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading CA certificate")
+	}
+	// We add to the system's CAs so that whatever the host redirects to, ex.
+	// a public object store for downloads, can still be verified.
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pemCerts) {
+		return nil, fmt.Errorf("no PEM encoded certificates found in %s", caCertFile)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool}
+	return transport, nil
+}
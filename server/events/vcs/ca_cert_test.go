@@ -0,0 +1,65 @@
+package vcs
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestNewGithubClient_CACert(t *testing.T) {
+	t.Log("a GitHub Enterprise with a certificate signed by --gh-ca-cert should be trusted")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"login": "octocat"}`)) // nolint: errcheck
+	}))
+	defer server.Close()
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmpDir) // nolint: errcheck
+	caFile := filepath.Join(tmpDir, "ca.pem")
+	Ok(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	client, err := NewGithubClient("github.com", "user", "token", caFile)
+	Ok(t, err)
+	client.client.BaseURL, err = url.Parse(server.URL + "/")
+	Ok(t, err)
+	_, _, err = client.client.Users.Get(context.Background(), "octocat")
+	Ok(t, err)
+
+	t.Log("without it the certificate shouldn't be trusted")
+	client, err = NewGithubClient("github.com", "user", "token", "")
+	Ok(t, err)
+	client.client.BaseURL, err = url.Parse(server.URL + "/")
+	Ok(t, err)
+	_, _, err = client.client.Users.Get(context.Background(), "octocat")
+	Assert(t, err != nil && strings.Contains(err.Error(), "certificate"), "exp a certificate error, got %v", err)
+}
+
+func TestNewGitlabClient_CACert(t *testing.T) {
+	t.Log("CA certificate files that don't exist or have no PEM encoded certificates should be an error")
+	_, err := NewGitlabClient("gitlab.example.com", "token", "/does/not/exist.pem")
+	Assert(t, err != nil, "exp an error")
+	Assert(t, strings.HasPrefix(err.Error(), "reading CA certificate"), "unexpected error %q", err)
+
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmpDir) // nolint: errcheck
+	caFile := filepath.Join(tmpDir, "ca.pem")
+	Ok(t, ioutil.WriteFile(caFile, []byte("not a certificate"), 0600))
+	_, err = NewGitlabClient("gitlab.example.com", "token", caFile)
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "no PEM encoded certificates found in "+caFile, err.Error())
+
+	t.Log("the client should use the API of the GitLab at the hostname")
+	client, err := NewGitlabClient("gitlab.example.com", "token", "")
+	Ok(t, err)
+	Equals(t, "https://gitlab.example.com/api/v4/", client.Client.BaseURL().String())
+}
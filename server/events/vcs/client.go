@@ -1,6 +1,8 @@
 package vcs
 
 import (
+	"context"
+
 	"github.com/hootsuite/atlantis/server/events/models"
 )
 
@@ -8,8 +10,21 @@ import (
 
 // Client is used to make API calls to a VCS host like GitHub or GitLab.
 type Client interface {
-	GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error)
-	CreateComment(repo models.Repo, pull models.PullRequest, comment string) error
-	PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error)
-	UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, description string) error
+	GetModifiedFiles(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]string, error)
+	CreateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, comment string) error
+	UpdateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, comment string) error
+	GetComments(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]Comment, error)
+	DeleteComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int) error
+	PullIsApproved(ctx context.Context, repo models.Repo, pull models.PullRequest) (bool, error)
+	// GetApprovers returns the usernames of the users that approved the pull
+	// request and haven't withdrawn their approval since.
+	GetApprovers(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]string, error)
+	UpdateStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state CommitStatus, description string) error
+	// UpdateNamedStatus is UpdateStatus for a status other than Atlantis's
+	// main one, ex. so branch protection can require it separately.
+	UpdateNamedStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state CommitStatus, name string, description string) error
+	UserIsMember(ctx context.Context, repo models.Repo, username string) (bool, error)
+	// UserIsTeamMember returns true if username is a member of team, ex.
+	// "org/team-slug". Only GitHub has teams.
+	UserIsTeamMember(ctx context.Context, team string, username string) (bool, error)
 }
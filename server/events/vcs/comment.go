@@ -0,0 +1,117 @@
+package vcs
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// commentMarkerRegex matches the marker at the start of a comment. The values
+// are query escaped so they can't contain ":" or "-->".
+var commentMarkerRegex = regexp.MustCompile(`^<!-- atlantis:([^:\s]*)((?::[a-z]+=[^:\s]*)*) -->`)
+
+// CommentMarker is embedded at the start of the comments Atlantis posts with
+// the results of a command so it can find them again later. It's rendered as
+// an HTML comment, ex. <!-- atlantis:plan:env=prod:project=x -->, so it isn't
+// visible on the pull request.
+type CommentMarker struct {
+	// Command is the name of the command the comment has the results of.
+	Command string
+	// Environment is the environment the command was run in.
+	Environment string
+	// Projects are the paths of the projects the comment has results for.
+	Projects []string
+}
+
+// String returns the marker as an HTML comment.
+func (m CommentMarker) String() string {
+	s := "<!-- atlantis:" + url.QueryEscape(m.Command)
+	if m.Environment != "" {
+		s += ":env=" + url.QueryEscape(m.Environment)
+	}
+	if len(m.Projects) > 0 {
+		var projects []string
+		for _, p := range m.Projects {
+			projects = append(projects, url.QueryEscape(p))
+		}
+		s += ":project=" + strings.Join(projects, ",")
+	}
+	return s + " -->"
+}
+
+// Supersedes returns true if a comment with marker m should replace a
+// comment with marker prev, ie. it's for the same command, environment and
+// projects. The projects can be in any order.
+func (m CommentMarker) Supersedes(prev CommentMarker) bool {
+	return m.Command == prev.Command && m.Environment == prev.Environment && sameProjects(m.Projects, prev.Projects)
+}
+
+// sameProjects returns true if a and b have the same projects, ignoring order.
+func sameProjects(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string(nil), a...)
+	sortedB := append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseCommentMarker returns the marker at the start of body. It returns
+// false if body doesn't start with a marker, ex. because it wasn't posted by
+// Atlantis.
+func ParseCommentMarker(body string) (CommentMarker, bool) {
+	match := commentMarkerRegex.FindStringSubmatch(body)
+	if match == nil {
+		return CommentMarker{}, false
+	}
+	var m CommentMarker
+	var err error
+	if m.Command, err = url.QueryUnescape(match[1]); err != nil {
+		return CommentMarker{}, false
+	}
+	for _, field := range strings.Split(match[2], ":") {
+		if field == "" {
+			continue
+		}
+		kv := strings.SplitN(field, "=", 2)
+		switch kv[0] {
+		case "env":
+			if m.Environment, err = url.QueryUnescape(kv[1]); err != nil {
+				return CommentMarker{}, false
+			}
+		case "project":
+			for _, p := range strings.Split(kv[1], ",") {
+				project, err := url.QueryUnescape(p)
+				if err != nil {
+					return CommentMarker{}, false
+				}
+				m.Projects = append(m.Projects, project)
+			}
+		}
+		// Ignore fields we don't know about so new fields can be added
+		// without breaking older versions of Atlantis.
+	}
+	return m, true
+}
+
+// WithMarker returns comment with marker embedded at the start.
+func WithMarker(marker CommentMarker, comment string) string {
+	return fmt.Sprintf("%s\n%s", marker, comment)
+}
+
+// Comment is a comment on a pull request.
+type Comment struct {
+	ID   int
+	Body string
+	// Author is the username of the user who posted the comment.
+	Author string
+}
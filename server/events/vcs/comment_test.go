@@ -0,0 +1,72 @@
+package vcs_test
+
+import (
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events/vcs"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestCommentMarker_String(t *testing.T) {
+	cases := []struct {
+		marker   vcs.CommentMarker
+		expected string
+	}{
+		{
+			vcs.CommentMarker{Command: "plan"},
+			"<!-- atlantis:plan -->",
+		},
+		{
+			vcs.CommentMarker{Command: "plan", Environment: "prod", Projects: []string{"x"}},
+			"<!-- atlantis:plan:env=prod:project=x -->",
+		},
+		{
+			vcs.CommentMarker{Command: "apply", Environment: "prod", Projects: []string{"a/b", "c"}},
+			"<!-- atlantis:apply:env=prod:project=a%2Fb,c -->",
+		},
+	}
+	for _, c := range cases {
+		Equals(t, c.expected, c.marker.String())
+	}
+}
+
+func TestParseCommentMarker_RoundTrip(t *testing.T) {
+	t.Log("values that would break the marker should survive being parsed")
+	marker := vcs.CommentMarker{
+		Command:     "plan",
+		Environment: "env with spaces:and colons",
+		Projects:    []string{"path/-->", "other,path"},
+	}
+	parsed, ok := vcs.ParseCommentMarker(vcs.WithMarker(marker, "**Plan Failed**"))
+	Assert(t, ok, "expected a marker")
+	Equals(t, marker, parsed)
+}
+
+func TestParseCommentMarker_NoMarker(t *testing.T) {
+	for _, body := range []string{
+		"",
+		"a comment from a human",
+		"text first <!-- atlantis:plan:env=prod -->",
+		"<!-- not atlantis -->",
+	} {
+		_, ok := vcs.ParseCommentMarker(body)
+		Assert(t, !ok, "did not expect a marker in %q", body)
+	}
+}
+
+func TestParseCommentMarker_UnknownFields(t *testing.T) {
+	t.Log("fields we don't know about should be ignored")
+	m, ok := vcs.ParseCommentMarker("<!-- atlantis:plan:env=prod:future=x -->\nbody")
+	Assert(t, ok, "expected a marker")
+	Equals(t, vcs.CommentMarker{Command: "plan", Environment: "prod"}, m)
+}
+
+func TestCommentMarker_Supersedes(t *testing.T) {
+	m := vcs.CommentMarker{Command: "plan", Environment: "prod", Projects: []string{"a", "b"}}
+	Assert(t, m.Supersedes(vcs.CommentMarker{Command: "plan", Environment: "prod", Projects: []string{"a", "b"}}), "same command, environment and projects")
+	Assert(t, m.Supersedes(vcs.CommentMarker{Command: "plan", Environment: "prod", Projects: []string{"b", "a"}}), "same projects in a different order")
+	Assert(t, !m.Supersedes(vcs.CommentMarker{Command: "plan", Environment: "prod", Projects: []string{"a"}}), "fewer projects")
+	Assert(t, !m.Supersedes(vcs.CommentMarker{Command: "plan", Environment: "prod", Projects: []string{"a", "c"}}), "different projects")
+	Assert(t, !m.Supersedes(vcs.CommentMarker{Command: "apply", Environment: "prod", Projects: []string{"a", "b"}}), "different command")
+	Assert(t, !m.Supersedes(vcs.CommentMarker{Command: "plan", Environment: "staging", Projects: []string{"a", "b"}}), "different environment")
+}
@@ -0,0 +1,138 @@
+package vcs
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/lkysow/go-gitlab"
+)
+
+// NotFoundError is returned when the VCS host says the resource we asked for
+// doesn't exist, or that we can't see it.
+type NotFoundError struct {
+	Err error
+}
+
+func (e *NotFoundError) Error() string { return e.Err.Error() }
+func (e *NotFoundError) Unwrap() error { return e.Err }
+func (e *NotFoundError) Cause() error  { return e.Err }
+
+// RateLimitError is returned when the VCS host is rate limiting us.
+type RateLimitError struct {
+	Err error
+	// RetryAfter is how long the host asked us to wait before retrying. It is
+	// 0 if the host didn't say.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string { return e.Err.Error() }
+func (e *RateLimitError) Unwrap() error { return e.Err }
+func (e *RateLimitError) Cause() error  { return e.Err }
+
+// AuthError is returned when the VCS host rejects our credentials or our
+// credentials don't have permission for the request.
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string { return e.Err.Error() }
+func (e *AuthError) Unwrap() error { return e.Err }
+func (e *AuthError) Cause() error  { return e.Err }
+
+// ValidationError is returned when the VCS host rejects the request itself,
+// ex. because a field was invalid.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+func (e *ValidationError) Unwrap() error { return e.Err }
+func (e *ValidationError) Cause() error  { return e.Err }
+
+// As is like errors.As from the standard library but it also follows errors
+// wrapped with github.com/pkg/errors. The vendored version of that package
+// doesn't implement Unwrap so errors.As alone would stop at the first
+// errors.Wrap.
+func As(err error, target interface{}) bool {
+	for err != nil {
+		if stderrors.As(err, target) {
+			return true
+		}
+		causer, ok := err.(interface {
+			Cause() error
+		})
+		if !ok {
+			return false
+		}
+		err = causer.Cause()
+	}
+	return false
+}
+
+// errorFromStatus wraps err in the error type that matches the HTTP status
+// code of the response. If the status code doesn't map to a type, err is
+// returned unchanged.
+func errorFromStatus(err error, resp *http.Response) error {
+	if resp == nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return &NotFoundError{Err: err}
+	case http.StatusTooManyRequests:
+		return &RateLimitError{Err: err, RetryAfter: retryAfter(resp)}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &AuthError{Err: err}
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return &ValidationError{Err: err}
+	}
+	return err
+}
+
+// retryAfter parses the Retry-After header of resp. It only supports the
+// number of seconds form since that's what the VCS hosts send.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// githubError converts an error returned by the GitHub client into one of
+// our error types.
+func githubError(err error) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *github.RateLimitError:
+		rateErr := &RateLimitError{Err: err}
+		if wait := time.Until(e.Rate.Reset.Time); wait > 0 {
+			rateErr.RetryAfter = wait
+		}
+		return rateErr
+	case *github.AbuseRateLimitError:
+		rateErr := &RateLimitError{Err: err}
+		if e.RetryAfter != nil {
+			rateErr.RetryAfter = *e.RetryAfter
+		}
+		return rateErr
+	case *github.TwoFactorAuthError:
+		return &AuthError{Err: err}
+	case *github.ErrorResponse:
+		return errorFromStatus(err, e.Response)
+	}
+	return err
+}
+
+// gitlabError converts an error returned by the GitLab client into one of
+// our error types.
+func gitlabError(err error) error {
+	if e, ok := err.(*gitlab.ErrorResponse); ok {
+		return errorFromStatus(err, e.Response)
+	}
+	return err
+}
@@ -0,0 +1,83 @@
+package vcs
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	. "github.com/hootsuite/atlantis/testing"
+	"github.com/lkysow/go-gitlab"
+	"github.com/pkg/errors"
+)
+
+func TestGithubError_StatusCodes(t *testing.T) {
+	t.Log("GitHub error responses should map to our error types by status code")
+	cases := []struct {
+		status int
+		check  func(error) bool
+	}{
+		{http.StatusNotFound, func(err error) bool { var e *NotFoundError; return As(err, &e) }},
+		{http.StatusUnauthorized, func(err error) bool { var e *AuthError; return As(err, &e) }},
+		{http.StatusForbidden, func(err error) bool { var e *AuthError; return As(err, &e) }},
+		{http.StatusUnprocessableEntity, func(err error) bool { var e *ValidationError; return As(err, &e) }},
+		{http.StatusTooManyRequests, func(err error) bool { var e *RateLimitError; return As(err, &e) }},
+	}
+	for _, c := range cases {
+		err := githubError(&github.ErrorResponse{Response: response(c.status)})
+		Assert(t, c.check(err), "wrong error type for status %d: %T", c.status, err)
+	}
+}
+
+func TestGithubError_Unknown(t *testing.T) {
+	t.Log("Errors that don't map to one of our types should be returned unchanged")
+	orig := &github.ErrorResponse{Response: response(http.StatusInternalServerError)}
+	Equals(t, error(orig), githubError(orig))
+	Equals(t, nil, githubError(nil))
+}
+
+func TestGithubError_AbuseRateLimit(t *testing.T) {
+	t.Log("The Retry-After of an abuse rate limit should be kept")
+	wait := 30 * time.Second
+	err := githubError(&github.AbuseRateLimitError{Response: response(http.StatusForbidden), RetryAfter: &wait})
+	var rateErr *RateLimitError
+	Assert(t, As(err, &rateErr), "expected a RateLimitError")
+	Equals(t, wait, rateErr.RetryAfter)
+}
+
+func TestGitlabError_StatusCodes(t *testing.T) {
+	t.Log("GitLab error responses should map to our error types by status code")
+	resp := response(http.StatusTooManyRequests)
+	resp.Header.Set("Retry-After", "10")
+	err := gitlabError(&gitlab.ErrorResponse{Response: resp})
+	var rateErr *RateLimitError
+	Assert(t, As(err, &rateErr), "expected a RateLimitError")
+	Equals(t, 10*time.Second, rateErr.RetryAfter)
+
+	err = gitlabError(&gitlab.ErrorResponse{Response: response(http.StatusNotFound)})
+	var notFound *NotFoundError
+	Assert(t, As(err, &notFound), "expected a NotFoundError")
+}
+
+func TestAs_PkgErrorsWrap(t *testing.T) {
+	t.Log("As should find our error types through errors wrapped with pkg/errors")
+	err := errors.Wrap(&AuthError{Err: errors.New("bad creds")}, "getting reviews")
+	var authErr *AuthError
+	Assert(t, As(err, &authErr), "expected an AuthError")
+	Equals(t, "getting reviews: bad creds", err.Error())
+
+	var notFound *NotFoundError
+	Assert(t, !As(err, &notFound), "did not expect a NotFoundError")
+}
+
+func response(status int) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{},
+		Request: &http.Request{
+			Method: "GET",
+			URL:    &url.URL{Scheme: "https", Host: "example.com", Path: "/"},
+		},
+	}
+}
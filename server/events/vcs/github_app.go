@@ -0,0 +1,214 @@
+package vcs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// githubAppTokenRefresh is how long before an installation token expires
+// that it's replaced, so requests and clones that started with it don't fail
+// halfway through.
+const githubAppTokenRefresh = 5 * time.Minute
+
+// GithubAppTransport authenticates requests to GitHub as an installation of a
+// GitHub App instead of as a user. It exchanges a JWT signed with the app's
+// private key for an installation token and replaces the token before it
+// expires, which GitHub does after an hour.
+type GithubAppTransport struct {
+	appID          int
+	installationID int
+	key            *rsa.PrivateKey
+	// baseURL is the url of the GitHub API, ex. https://api.github.com/.
+	baseURL string
+	// base sends the requests. It trusts the CA certificates the transport
+	// was created with.
+	base http.RoundTripper
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+	// login is the app's login, once it's been looked up by Login.
+	login string
+}
+
+// NewGithubAppTransport returns a transport for the installation with
+// installationID of the app with appID on hostname. pemKey is the app's PEM
+// encoded private key as downloaded from GitHub. caCertFile is as for
+// NewGithubClient.
+func NewGithubAppTransport(hostname string, appID int, installationID int, pemKey []byte, caCertFile string) (*GithubAppTransport, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+	// GitHub's keys are PKCS#1 but they're often converted to PKCS#8.
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if pkcs8Err != nil {
+			return nil, errors.Wrap(err, "parsing private key")
+		}
+		var ok bool
+		if key, ok = parsed.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("unsupported key type %T: must be RSA", parsed)
+		}
+	}
+	baseURL, err := githubAPIURL(hostname)
+	if err != nil {
+		return nil, err
+	}
+	base, err := caCertTransport(caCertFile)
+	if err != nil {
+		return nil, err
+	}
+	return &GithubAppTransport{
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+		baseURL:        baseURL.String(),
+		base:           base,
+	}, nil
+}
+
+// RoundTrip sends req with the installation token.
+func (t *GithubAppTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Token()
+	if err != nil {
+		return nil, err
+	}
+	// RoundTrippers mustn't modify the request.
+	authed := new(http.Request)
+	*authed = *req
+	authed.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		authed.Header[k] = v
+	}
+	authed.Header.Set("Authorization", "token "+token)
+	return t.base.RoundTrip(authed)
+}
+
+// Token returns the installation token, creating a new one if there isn't
+// one yet or it expires within githubAppTokenRefresh. It can be used as the
+// password for git over https with the user x-access-token.
+func (t *GithubAppTransport) Token() (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := time.Now()
+	if t.token != "" && now.Add(githubAppTokenRefresh).Before(t.expires) {
+		return t.token, nil
+	}
+	token, expires, err := t.createToken(now)
+	if err != nil {
+		return "", errors.Wrap(err, "creating GitHub App installation token")
+	}
+	t.token, t.expires = token, expires
+	return token, nil
+}
+
+// createToken asks GitHub for a new installation token and returns it with
+// when it expires.
+func (t *GithubAppTransport) createToken(now time.Time) (string, time.Time, error) {
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := t.appRequest(now, "POST", fmt.Sprintf("app/installations/%d/access_tokens", t.installationID), http.StatusCreated, &token); err != nil {
+		return "", time.Time{}, err
+	}
+	if token.Token == "" {
+		return "", time.Time{}, errors.New("response has no token")
+	}
+	return token.Token, token.ExpiresAt, nil
+}
+
+// Login returns the login GitHub shows as the author of the comments the app
+// posts, ie. the app's slug followed by [bot]. It's only looked up once.
+func (t *GithubAppTransport) Login() (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.login != "" {
+		return t.login, nil
+	}
+	var app struct {
+		Slug string `json:"slug"`
+	}
+	if err := t.appRequest(time.Now(), "GET", "app", http.StatusOK, &app); err != nil {
+		return "", errors.Wrap(err, "getting GitHub App")
+	}
+	if app.Slug == "" {
+		return "", errors.New("getting GitHub App: response has no slug")
+	}
+	t.login = app.Slug + "[bot]"
+	return t.login, nil
+}
+
+// appRequest sends a request to path authenticated as the app and decodes
+// the response into v. It returns an error if GitHub doesn't respond with
+// status.
+func (t *GithubAppTransport) appRequest(now time.Time, method string, path string, status int, v interface{}) error {
+	jwt, err := t.appJWT(now)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, t.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != status {
+		err := fmt.Errorf("GitHub responded with status %d: %s", resp.StatusCode, body)
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound {
+			return &AuthError{Err: err}
+		}
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.Wrap(err, "parsing response")
+	}
+	return nil
+}
+
+// appJWT returns the JWT that authenticates us as the app. It's backdated a
+// minute in case our clock is ahead of GitHub's and expires well within the
+// ten minutes GitHub allows.
+func (t *GithubAppTransport) appJWT(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(struct {
+		IssuedAt  int64  `json:"iat"`
+		ExpiresAt int64  `json:"exp"`
+		Issuer    string `json:"iss"`
+	}{now.Add(-time.Minute).Unix(), now.Add(9 * time.Minute).Unix(), strconv.Itoa(t.appID)})
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, t.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", errors.Wrap(err, "signing app JWT")
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,140 @@
+package vcs
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+// githubAppServer returns a transport for app 1's installation 2 that
+// creates its tokens with a fake GitHub, which checks the app's JWT and
+// returns tokens that expire after expiry. tokens counts the tokens created
+// and requests records the Authorization header of the other requests.
+func githubAppServer(t *testing.T, expiry time.Duration) (transport *GithubAppTransport, tokens *int, requests *[]string, stop func()) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Ok(t, err)
+	tokens = new(int)
+	requests = new([]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/app/installations/2/access_tokens" {
+			*requests = append(*requests, r.Header.Get("Authorization"))
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		Equals(t, 3, len(parts))
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		Ok(t, err)
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		Ok(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig))
+		raw, err := base64.RawURLEncoding.DecodeString(parts[1])
+		Ok(t, err)
+		var claims struct {
+			Issuer string `json:"iss"`
+		}
+		Ok(t, json.Unmarshal(raw, &claims))
+		Equals(t, "1", claims.Issuer)
+
+		*tokens++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "token-%d", "expires_at": %q}`, *tokens, time.Now().Add(expiry).Format(time.RFC3339)) // nolint: errcheck
+	}))
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	transport, err = NewGithubAppTransport("github.com", 1, 2, pemKey, "")
+	Ok(t, err)
+	transport.baseURL = server.URL + "/"
+	return transport, tokens, requests, server.Close
+}
+
+func TestGithubAppTransport_Token(t *testing.T) {
+	t.Log("requests should be sent with the installation token, which should be reused until it's about to expire")
+	transport, tokens, requests, stop := githubAppServer(t, time.Hour)
+	defer stop()
+	client, err := newGithubClient("github.com", &http.Client{Transport: transport})
+	Ok(t, err)
+	client.BaseURL, err = url.Parse(transport.baseURL)
+	Ok(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, _, err = client.Users.Get(context.Background(), "octocat")
+		Ok(t, err)
+	}
+	Equals(t, 1, *tokens)
+	Equals(t, []string{"token token-1", "token token-1"}, *requests)
+
+	t.Log("a token that expires soon should be replaced")
+	transport.expires = time.Now().Add(time.Minute)
+	token, err := transport.Token()
+	Ok(t, err)
+	Equals(t, "token-2", token)
+	Equals(t, 2, *tokens)
+}
+
+func TestGithubAppTransport_Rejected(t *testing.T) {
+	t.Log("GitHub rejecting the app should be an auth error")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Ok(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "A JSON web token could not be decoded"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	transport, err := NewGithubAppTransport("github.com", 1, 2, pemKey, "")
+	Ok(t, err)
+	transport.baseURL = server.URL + "/"
+
+	_, err = transport.Token()
+	Assert(t, err != nil, "exp an error")
+	var authErr *AuthError
+	Assert(t, As(err, &authErr), "exp an auth error, got %T", err)
+}
+
+func TestGithubAppTransport_Login(t *testing.T) {
+	t.Log("the app's login should be its slug followed by [bot] and only be looked up once")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Ok(t, err)
+	lookups := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "/app", r.URL.Path)
+		Assert(t, strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "), "exp the app's JWT")
+		lookups++
+		fmt.Fprint(w, `{"id": 1, "slug": "atlantis"}`) // nolint: errcheck
+	}))
+	defer server.Close()
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	transport, err := NewGithubAppTransport("github.com", 1, 2, pemKey, "")
+	Ok(t, err)
+	transport.baseURL = server.URL + "/"
+
+	for i := 0; i < 2; i++ {
+		login, err := transport.Login()
+		Ok(t, err)
+		Equals(t, "atlantis[bot]", login)
+	}
+	Equals(t, 1, lookups)
+}
+
+func TestNewGithubAppTransport_InvalidKey(t *testing.T) {
+	t.Log("keys that aren't PEM encoded RSA private keys should be an error")
+	_, err := NewGithubAppTransport("github.com", 1, 2, []byte("not a key"), "")
+	Assert(t, err != nil, "exp an error")
+	Equals(t, "no PEM encoded key found", err.Error())
+
+	_, err = NewGithubAppTransport("github.com", 1, 2, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("garbage")}), "")
+	Assert(t, err != nil, "exp an error")
+	Assert(t, strings.HasPrefix(err.Error(), "parsing private key"), "unexpected error %q", err)
+}
@@ -3,48 +3,115 @@ package vcs
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/logging"
 	"github.com/pkg/errors"
 )
 
 // GithubClient is used to perform GitHub actions.
 type GithubClient struct {
 	client *github.Client
-	ctx    context.Context
+	// app is set if we're authenticated as a GitHub App instead of a user.
+	app *GithubAppTransport
+	// RateLimitWait is how long comments and statuses wait in total for
+	// GitHub's rate limit to reset before failing. If it's 0, they fail as
+	// soon as they're rate limited.
+	RateLimitWait time.Duration
+	// Logger, if set, logs when we're waiting for the rate limit.
+	Logger *logging.SimpleLogger
 }
 
-// NewGithubClient returns a valid GitHub client.
-func NewGithubClient(hostname string, user string, pass string) (*GithubClient, error) {
+// githubRateLimitDefaultWait is how long we wait when GitHub rate limits us
+// without saying when the limit resets, which it does for its secondary
+// limits.
+const githubRateLimitDefaultWait = time.Minute
+
+// NewGithubClient returns a valid GitHub client. If caCertFile is set, the
+// GitHub Enterprise at hostname is also trusted if its certificate is signed
+// by one of the PEM encoded CA certificates in it.
+func NewGithubClient(hostname string, user string, pass string, caCertFile string) (*GithubClient, error) {
+	transport, err := caCertTransport(caCertFile)
+	if err != nil {
+		return nil, err
+	}
 	tp := github.BasicAuthTransport{
-		Username: strings.TrimSpace(user),
-		Password: strings.TrimSpace(pass),
+		Username:  strings.TrimSpace(user),
+		Password:  strings.TrimSpace(pass),
+		Transport: transport,
 	}
-	client := github.NewClient(tp.Client())
+	client, err := newGithubClient(hostname, tp.Client())
+	if err != nil {
+		return nil, err
+	}
+	return &GithubClient{
+		client: client,
+	}, nil
+}
+
+// NewGithubAppClient returns a GitHub client that's authenticated as the
+// GitHub App installation of app.
+func NewGithubAppClient(hostname string, app *GithubAppTransport) (*GithubClient, error) {
+	client, err := newGithubClient(hostname, &http.Client{Transport: app})
+	if err != nil {
+		return nil, err
+	}
+	return &GithubClient{
+		client: client,
+		app:    app,
+	}, nil
+}
+
+func newGithubClient(hostname string, httpClient *http.Client) (*github.Client, error) {
+	client := github.NewClient(httpClient)
 	// If we're using github.com then we don't need to do any additional configuration
 	// for the client. It we're using Github Enterprise, then we need to manually
 	// set the base url for the API.
 	if hostname != "github.com" {
-		baseURL := fmt.Sprintf("https://%s/api/v3/", hostname)
-		base, err := url.Parse(baseURL)
+		base, err := githubAPIURL(hostname)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Invalid github hostname trying to parse %s", baseURL)
+			return nil, err
 		}
 		client.BaseURL = base
 	}
+	return client, nil
+}
 
-	return &GithubClient{
-		client: client,
-		ctx:    context.Background(),
-	}, nil
+// githubAPIURL returns the url of the API of GitHub or the GitHub Enterprise
+// at hostname.
+func githubAPIURL(hostname string) (*url.URL, error) {
+	baseURL := "https://api.github.com/"
+	if hostname != "github.com" {
+		baseURL = fmt.Sprintf("https://%s/api/v3/", hostname)
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid github hostname trying to parse %s", baseURL)
+	}
+	return base, nil
+}
+
+// CheckAuth returns an error if GitHub can't be reached or rejects our
+// credentials.
+func (g *GithubClient) CheckAuth(ctx context.Context) error {
+	if g.app != nil {
+		// Installations aren't users so we check that we can list the
+		// repos the app is installed in instead.
+		_, _, err := g.client.Apps.ListRepos(ctx, &github.ListOptions{PerPage: 1})
+		return githubError(err)
+	}
+	_, _, err := g.client.Users.Get(ctx, "")
+	return githubError(err)
 }
 
 // GetModifiedFiles returns the names of files that were modified in the pull request.
 // The names include the path to the file from the repo root, ex. parent/child/file.txt.
-func (g *GithubClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
+func (g *GithubClient) GetModifiedFiles(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]string, error) {
 	var files []string
 	nextPage := 0
 	for {
@@ -54,9 +121,9 @@ func (g *GithubClient) GetModifiedFiles(repo models.Repo, pull models.PullReques
 		if nextPage != 0 {
 			opts.Page = nextPage
 		}
-		pageFiles, resp, err := g.client.PullRequests.ListFiles(g.ctx, repo.Owner, repo.Name, pull.Num, &opts)
+		pageFiles, resp, err := g.client.PullRequests.ListFiles(ctx, repo.Owner, repo.Name, pull.Num, &opts)
 		if err != nil {
-			return files, err
+			return files, githubError(err)
 		}
 		for _, f := range pageFiles {
 			files = append(files, f.GetFilename())
@@ -70,16 +137,62 @@ func (g *GithubClient) GetModifiedFiles(repo models.Repo, pull models.PullReques
 }
 
 // CreateComment creates a comment on the pull request.
-func (g *GithubClient) CreateComment(repo models.Repo, pull models.PullRequest, comment string) error {
-	_, _, err := g.client.Issues.CreateComment(g.ctx, repo.Owner, repo.Name, pull.Num, &github.IssueComment{Body: &comment})
-	return err
+func (g *GithubClient) CreateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, comment string) error {
+	return g.waitForRateLimit(ctx, func() error {
+		_, _, err := g.client.Issues.CreateComment(ctx, repo.Owner, repo.Name, pull.Num, &github.IssueComment{Body: &comment})
+		return githubError(err)
+	})
+}
+
+// UpdateComment replaces the body of the comment with id commentID.
+func (g *GithubClient) UpdateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, comment string) error {
+	return g.waitForRateLimit(ctx, func() error {
+		_, _, err := g.client.Issues.EditComment(ctx, repo.Owner, repo.Name, commentID, &github.IssueComment{Body: &comment})
+		return githubError(err)
+	})
+}
+
+// GetComments returns all the comments on the pull request.
+func (g *GithubClient) GetComments(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]Comment, error) {
+	var comments []Comment
+	opts := github.IssueListCommentsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		var pageComments []*github.IssueComment
+		var resp *github.Response
+		err := g.waitForRateLimit(ctx, func() error {
+			var err error
+			pageComments, resp, err = g.client.Issues.ListComments(ctx, repo.Owner, repo.Name, pull.Num, &opts)
+			return githubError(err)
+		})
+		if err != nil {
+			return comments, err
+		}
+		for _, c := range pageComments {
+			comments = append(comments, Comment{ID: c.GetID(), Body: c.GetBody(), Author: c.User.GetLogin()})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return comments, nil
+}
+
+// DeleteComment deletes the comment with id commentID.
+func (g *GithubClient) DeleteComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int) error {
+	return g.waitForRateLimit(ctx, func() error {
+		_, err := g.client.Issues.DeleteComment(ctx, repo.Owner, repo.Name, commentID)
+		return githubError(err)
+	})
 }
 
 // PullIsApproved returns true if the pull request was approved.
-func (g *GithubClient) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
-	reviews, _, err := g.client.PullRequests.ListReviews(g.ctx, repo.Owner, repo.Name, pull.Num, nil)
+func (g *GithubClient) PullIsApproved(ctx context.Context, repo models.Repo, pull models.PullRequest) (bool, error) {
+	reviews, _, err := g.client.PullRequests.ListReviews(ctx, repo.Owner, repo.Name, pull.Num, nil)
 	if err != nil {
-		return false, errors.Wrap(err, "getting reviews")
+		return false, errors.Wrap(githubError(err), "getting reviews")
 	}
 	for _, review := range reviews {
 		if review != nil && review.GetState() == "APPROVED" {
@@ -89,16 +202,93 @@ func (g *GithubClient) PullIsApproved(repo models.Repo, pull models.PullRequest)
 	return false, nil
 }
 
+// GetApprovers returns the users whose latest review of the pull request
+// approves it. Later comments don't withdraw an approval but requesting
+// changes or a dismissal does.
+func (g *GithubClient) GetApprovers(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]string, error) {
+	var reviews []*github.PullRequestReview
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := g.client.PullRequests.ListReviews(ctx, repo.Owner, repo.Name, pull.Num, opt)
+		if err != nil {
+			return nil, errors.Wrap(githubError(err), "getting reviews")
+		}
+		reviews = append(reviews, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	// Reviews are listed oldest first so the last state of each user wins.
+	var users []string
+	states := make(map[string]string)
+	for _, review := range reviews {
+		if review == nil || review.GetState() == "COMMENTED" {
+			continue
+		}
+		user := review.User.GetLogin()
+		if _, ok := states[user]; !ok {
+			users = append(users, user)
+		}
+		states[user] = review.GetState()
+	}
+	var approvers []string
+	for _, user := range users {
+		if states[user] == "APPROVED" {
+			approvers = append(approvers, user)
+		}
+	}
+	return approvers, nil
+}
+
+// UserIsMember returns true if username is a collaborator on the repo, which
+// includes the members of the organization's teams that have access to it.
+func (g *GithubClient) UserIsMember(ctx context.Context, repo models.Repo, username string) (bool, error) {
+	isCollaborator, _, err := g.client.Repositories.IsCollaborator(ctx, repo.Owner, repo.Name, username)
+	if err != nil {
+		return false, errors.Wrap(githubError(err), "checking collaborator")
+	}
+	return isCollaborator, nil
+}
+
+// UserIsTeamMember returns true if username is an active member of team,
+// ex. "org/team-slug". Pending invitations don't count.
+func (g *GithubClient) UserIsTeamMember(ctx context.Context, team string, username string) (bool, error) {
+	parts := strings.SplitN(team, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return false, fmt.Errorf("team %q must be org/team-slug", team)
+	}
+	// The client only looks teams up by id so we construct the url by hand.
+	apiURL := fmt.Sprintf("orgs/%s/teams/%s/memberships/%s", url.PathEscape(parts[0]), url.PathEscape(parts[1]), url.PathEscape(username))
+	req, err := g.client.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return false, err
+	}
+	var membership github.Membership
+	resp, err := g.client.Do(ctx, req, &membership)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(githubError(err), "checking team membership")
+	}
+	return membership.GetState() == "active", nil
+}
+
 // GetPullRequest returns the pull request.
-func (g *GithubClient) GetPullRequest(repo models.Repo, num int) (*github.PullRequest, error) {
-	pull, _, err := g.client.PullRequests.Get(g.ctx, repo.Owner, repo.Name, num)
-	return pull, err
+func (g *GithubClient) GetPullRequest(ctx context.Context, repo models.Repo, num int) (*github.PullRequest, error) {
+	pull, _, err := g.client.PullRequests.Get(ctx, repo.Owner, repo.Name, num)
+	return pull, githubError(err)
 }
 
 // UpdateStatus updates the status badge on the pull request.
 // See https://github.com/blog/1227-commit-status-api.
-func (g *GithubClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, description string) error {
-	const statusContext = "Atlantis"
+func (g *GithubClient) UpdateStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state CommitStatus, description string) error {
+	return g.UpdateNamedStatus(ctx, repo, pull, state, statusContext, description)
+}
+
+// UpdateNamedStatus updates the status with name on the pull request.
+func (g *GithubClient) UpdateNamedStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state CommitStatus, name string, description string) error {
 	ghState := "error"
 	switch state {
 	case Pending:
@@ -111,7 +301,39 @@ func (g *GithubClient) UpdateStatus(repo models.Repo, pull models.PullRequest, s
 	status := &github.RepoStatus{
 		State:       github.String(ghState),
 		Description: github.String(description),
-		Context:     github.String(statusContext)}
-	_, _, err := g.client.Repositories.CreateStatus(g.ctx, repo.Owner, repo.Name, pull.HeadCommit, status)
-	return err
+		Context:     github.String(name)}
+	return g.waitForRateLimit(ctx, func() error {
+		_, _, err := g.client.Repositories.CreateStatus(ctx, repo.Owner, repo.Name, pull.HeadCommit, status)
+		return githubError(err)
+	})
+}
+
+// waitForRateLimit returns the error of call. If GitHub rate limited it, we
+// wait for the limit to reset and call it again, as long as the waits add up
+// to at most RateLimitWait.
+func (g *GithubClient) waitForRateLimit(ctx context.Context, call func() error) error {
+	var waited time.Duration
+	for {
+		err := call()
+		var rateErr *RateLimitError
+		if err == nil || !As(err, &rateErr) {
+			return err
+		}
+		wait := rateErr.RetryAfter
+		if wait <= 0 {
+			wait = githubRateLimitDefaultWait
+		}
+		if waited+wait > g.RateLimitWait {
+			return err
+		}
+		if g.Logger != nil {
+			g.Logger.Warn("GitHub is rate limiting us, waiting %s for the limit to reset: %s", wait.Round(time.Second), err)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		waited += wait
+	}
 }
@@ -0,0 +1,63 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events/models"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+// rateLimitedGithub returns a client for a fake GitHub that rate limits the
+// first request until the next second and lets the others through. requests
+// counts the requests it got.
+func rateLimitedGithub(t *testing.T) (client *GithubClient, requests *int, stop func()) {
+	requests = new(int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if *requests == 1 {
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "API rate limit exceeded for user ID 1."}`) // nolint: errcheck
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{}`) // nolint: errcheck
+	}))
+	client, err := NewGithubClient("github.com", "user", "token", "")
+	Ok(t, err)
+	client.client.BaseURL, err = url.Parse(server.URL + "/")
+	Ok(t, err)
+	return client, requests, server.Close
+}
+
+func TestGithubClient_RateLimitWait(t *testing.T) {
+	t.Log("a rate limited comment should be created once the rate limit resets")
+	client, requests, stop := rateLimitedGithub(t)
+	defer stop()
+	client.RateLimitWait = 5 * time.Second
+
+	err := client.CreateComment(context.Background(), models.Repo{Owner: "owner", Name: "repo"}, models.PullRequest{Num: 1}, "comment")
+	Ok(t, err)
+	Equals(t, 2, *requests)
+}
+
+func TestGithubClient_RateLimitNoWait(t *testing.T) {
+	t.Log("without a rate limit wait a rate limited status should fail right away")
+	client, requests, stop := rateLimitedGithub(t)
+	defer stop()
+
+	err := client.UpdateStatus(context.Background(), models.Repo{Owner: "owner", Name: "repo"}, models.PullRequest{Num: 1, HeadCommit: "sha"}, Success, "description")
+	var rateErr *RateLimitError
+	Assert(t, As(err, &rateErr), "exp a rate limit error, got %v", err)
+	Assert(t, rateErr.RetryAfter > 0, "exp the reset to be known")
+	Equals(t, 1, *requests)
+}
@@ -1,20 +1,50 @@
 package vcs
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"net/url"
 
 	"github.com/hootsuite/atlantis/server/events/models"
 	"github.com/lkysow/go-gitlab"
+	"github.com/pkg/errors"
 )
 
 type GitlabClient struct {
 	Client *gitlab.Client
 }
 
+// NewGitlabClient returns a client for the GitLab at hostname that
+// authenticates with token. If caCertFile is set, hostname is also trusted
+// if its certificate is signed by one of the PEM encoded CA certificates in
+// it.
+func NewGitlabClient(hostname string, token string, caCertFile string) (*GitlabClient, error) {
+	transport, err := caCertTransport(caCertFile)
+	if err != nil {
+		return nil, err
+	}
+	client := gitlab.NewClient(&http.Client{Transport: transport}, token)
+	// The client uses gitlab.com unless we set the url of our own GitLab's
+	// API.
+	if hostname != "gitlab.com" {
+		if err := client.SetBaseURL(fmt.Sprintf("https://%s/api/v4/", hostname)); err != nil {
+			return nil, errors.Wrapf(err, "invalid GitLab hostname %q", hostname)
+		}
+	}
+	return &GitlabClient{Client: client}, nil
+}
+
+// CheckAuth returns an error if GitLab can't be reached or rejects our
+// credentials.
+func (g *GitlabClient) CheckAuth(ctx context.Context) error {
+	_, _, err := g.Client.Users.CurrentUser(withContext(ctx))
+	return gitlabError(err)
+}
+
 // GetModifiedFiles returns the names of files that were modified in the merge request.
 // The names include the path to the file from the repo root, ex. parent/child/file.txt.
-func (g *GitlabClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
+func (g *GitlabClient) GetModifiedFiles(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]string, error) {
 	const maxPerPage = 100
 	var files []string
 	nextPage := 1
@@ -25,14 +55,14 @@ func (g *GitlabClient) GetModifiedFiles(repo models.Repo, pull models.PullReques
 			Page:    nextPage,
 			PerPage: maxPerPage,
 		}
-		req, err := g.Client.NewRequest("GET", apiURL, opts, nil)
+		req, err := g.Client.NewRequest("GET", apiURL, opts, []gitlab.OptionFunc{withContext(ctx)})
 		if err != nil {
 			return nil, err
 		}
 		mr := new(gitlab.MergeRequest)
 		resp, err := g.Client.Do(req, mr)
 		if err != nil {
-			return nil, err
+			return nil, gitlabError(err)
 		}
 
 		for _, f := range mr.Changes {
@@ -48,27 +78,153 @@ func (g *GitlabClient) GetModifiedFiles(repo models.Repo, pull models.PullReques
 }
 
 // CreateComment creates a comment on the merge request.
-func (g *GitlabClient) CreateComment(repo models.Repo, pull models.PullRequest, comment string) error {
-	_, _, err := g.Client.Notes.CreateMergeRequestNote(repo.FullName, pull.Num, &gitlab.CreateMergeRequestNoteOptions{Body: gitlab.String(comment)})
-	return err
+func (g *GitlabClient) CreateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, comment string) error {
+	_, _, err := g.Client.Notes.CreateMergeRequestNote(repo.FullName, pull.Num, &gitlab.CreateMergeRequestNoteOptions{Body: gitlab.String(comment)}, withContext(ctx))
+	return gitlabError(err)
+}
+
+// UpdateComment replaces the body of the note with id commentID.
+func (g *GitlabClient) UpdateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, comment string) error {
+	_, _, err := g.Client.Notes.UpdateMergeRequestNote(repo.FullName, pull.Num, commentID, &gitlab.UpdateMergeRequestNoteOptions{Body: gitlab.String(comment)}, withContext(ctx))
+	return gitlabError(err)
+}
+
+// GetComments returns all the notes on the merge request.
+func (g *GitlabClient) GetComments(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]Comment, error) {
+	const maxPerPage = 100
+	var comments []Comment
+	nextPage := 1
+	// Constructing the api url by hand so we can do pagination.
+	apiURL := fmt.Sprintf("projects/%s/merge_requests/%d/notes", url.QueryEscape(repo.FullName), pull.Num)
+	for {
+		opts := gitlab.ListOptions{
+			Page:    nextPage,
+			PerPage: maxPerPage,
+		}
+		req, err := g.Client.NewRequest("GET", apiURL, opts, []gitlab.OptionFunc{withContext(ctx)})
+		if err != nil {
+			return nil, err
+		}
+		var notes []*gitlab.Note
+		resp, err := g.Client.Do(req, &notes)
+		if err != nil {
+			return nil, gitlabError(err)
+		}
+
+		for _, n := range notes {
+			comments = append(comments, Comment{ID: n.ID, Body: n.Body, Author: n.Author.Username})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		nextPage = resp.NextPage
+	}
+	return comments, nil
+}
+
+// DeleteComment deletes the note with id commentID.
+func (g *GitlabClient) DeleteComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int) error {
+	_, err := g.Client.Notes.DeleteMergeRequestNote(repo.FullName, pull.Num, commentID, withContext(ctx))
+	return gitlabError(err)
+}
+
+// gitlabApprovals is the part of a merge request's approvals that we use.
+// The client's type doesn't have approved so we decode them ourselves.
+type gitlabApprovals struct {
+	// Approved is whether the merge request's approval rules are satisfied.
+	// It's nil on older GitLab versions, which don't return it.
+	Approved *bool `json:"approved"`
+	// ApprovalsLeft is called ApprovalsMissing on older GitLab versions.
+	ApprovalsLeft    int `json:"approvals_left"`
+	ApprovalsMissing int `json:"approvals_missing"`
+	ApprovedBy       []struct {
+		User struct {
+			Username string `json:"username"`
+		} `json:"user"`
+	} `json:"approved_by"`
+}
+
+// getApprovals returns the approvals of the merge request from its
+// /approvals endpoint.
+func (g *GitlabClient) getApprovals(ctx context.Context, repo models.Repo, pull models.PullRequest) (*gitlabApprovals, error) {
+	apiURL := fmt.Sprintf("projects/%s/merge_requests/%d/approvals", url.QueryEscape(repo.FullName), pull.Num)
+	req, err := g.Client.NewRequest("GET", apiURL, nil, []gitlab.OptionFunc{withContext(ctx)})
+	if err != nil {
+		return nil, err
+	}
+	var approvals gitlabApprovals
+	if _, err := g.Client.Do(req, &approvals); err != nil {
+		return nil, gitlabError(err)
+	}
+	return &approvals, nil
 }
 
-// PullIsApproved returns true if the merge request was approved.
-func (g *GitlabClient) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
-	approvals, _, err := g.Client.MergeRequests.GetMergeRequestApprovals(repo.FullName, pull.Num)
+// PullIsApproved returns true if GitLab says the merge request is approved
+// and someone approved it. GitLab says merge requests of projects without
+// approval rules are approved without any approvals, which isn't what
+// requiring approval means.
+func (g *GitlabClient) PullIsApproved(ctx context.Context, repo models.Repo, pull models.PullRequest) (bool, error) {
+	approvals, err := g.getApprovals(ctx, repo, pull)
 	if err != nil {
 		return false, err
 	}
-	if approvals.ApprovalsMissing > 0 {
+	if len(approvals.ApprovedBy) == 0 {
 		return false, nil
 	}
-	return true, nil
+	if approvals.Approved != nil {
+		return *approvals.Approved, nil
+	}
+	return approvals.ApprovalsLeft == 0 && approvals.ApprovalsMissing == 0, nil
+}
+
+// GetApprovers returns the users that approved the merge request.
+func (g *GitlabClient) GetApprovers(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]string, error) {
+	approvals, err := g.getApprovals(ctx, repo, pull)
+	if err != nil {
+		return nil, err
+	}
+	var approvers []string
+	for _, a := range approvals.ApprovedBy {
+		approvers = append(approvers, a.User.Username)
+	}
+	return approvers, nil
+}
+
+// UserIsTeamMember always returns an error since GitLab doesn't have teams.
+func (g *GitlabClient) UserIsTeamMember(ctx context.Context, team string, username string) (bool, error) {
+	return false, fmt.Errorf("can't check if %s is a member of team %q since teams are only supported on GitHub", username, team)
+}
+
+// UserIsMember returns true if username is a member of the project, directly
+// or through one of its groups.
+func (g *GitlabClient) UserIsMember(ctx context.Context, repo models.Repo, username string) (bool, error) {
+	// The client only lists direct members so we construct the url for all
+	// members by hand.
+	apiURL := fmt.Sprintf("projects/%s/members/all", url.QueryEscape(repo.FullName))
+	req, err := g.Client.NewRequest("GET", apiURL, gitlab.ListProjectMembersOptions{Query: gitlab.String(username)}, []gitlab.OptionFunc{withContext(ctx)})
+	if err != nil {
+		return false, err
+	}
+	var members []*gitlab.ProjectMember
+	if _, err := g.Client.Do(req, &members); err != nil {
+		return false, gitlabError(err)
+	}
+	// The query also matches names and partial usernames.
+	for _, m := range members {
+		if m.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // UpdateStatus updates the build status of a commit.
-func (g *GitlabClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, description string) error {
-	const statusContext = "Atlantis"
+func (g *GitlabClient) UpdateStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state CommitStatus, description string) error {
+	return g.UpdateNamedStatus(ctx, repo, pull, state, statusContext, description)
+}
 
+// UpdateNamedStatus updates the build status with name of a commit.
+func (g *GitlabClient) UpdateNamedStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state CommitStatus, name string, description string) error {
 	gitlabState := gitlab.Failed
 	switch state {
 	case Pending:
@@ -80,13 +236,22 @@ func (g *GitlabClient) UpdateStatus(repo models.Repo, pull models.PullRequest, s
 	}
 	_, _, err := g.Client.Commits.SetCommitStatus(repo.FullName, pull.HeadCommit, &gitlab.SetCommitStatusOptions{
 		State:       gitlabState,
-		Context:     gitlab.String(statusContext),
+		Context:     gitlab.String(name),
 		Description: gitlab.String(description),
-	})
-	return err
+	}, withContext(ctx))
+	return gitlabError(err)
 }
 
-func (g *GitlabClient) GetMergeRequest(repoFullName string, pullNum int) (*gitlab.MergeRequest, error) {
-	mr, _, err := g.Client.MergeRequests.GetMergeRequest(repoFullName, pullNum)
-	return mr, err
+func (g *GitlabClient) GetMergeRequest(ctx context.Context, repoFullName string, pullNum int) (*gitlab.MergeRequest, error) {
+	mr, _, err := g.Client.MergeRequests.GetMergeRequest(repoFullName, pullNum, withContext(ctx))
+	return mr, gitlabError(err)
+}
+
+// withContext makes the request use ctx. This version of go-gitlab doesn't
+// support contexts so we swap the request for one with ctx set.
+func withContext(ctx context.Context) gitlab.OptionFunc {
+	return func(req *http.Request) error {
+		*req = *req.WithContext(ctx)
+		return nil
+	}
 }
@@ -0,0 +1,77 @@
+package vcs_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/events/vcs"
+	"github.com/hootsuite/atlantis/server/events/vcs/fixtures"
+	. "github.com/hootsuite/atlantis/testing"
+	"github.com/lkysow/go-gitlab"
+)
+
+// gitlabApprovalsClient returns a client for a fake GitLab that responds to
+// the approvals of owner/repo!5 with approvals.
+func gitlabApprovalsClient(t *testing.T, approvals string) (*vcs.GitlabClient, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "/api/v4/projects/owner%2Frepo/merge_requests/5/approvals", r.URL.EscapedPath())
+		fmt.Fprint(w, approvals) // nolint: errcheck
+	}))
+	client := gitlab.NewClient(nil, "token")
+	Ok(t, client.SetBaseURL(server.URL+"/api/v4/"))
+	return &vcs.GitlabClient{Client: client}, server.Close
+}
+
+var gitlabRepo = models.Repo{FullName: "owner/repo", Owner: "owner", Name: "repo"}
+
+func TestGitlabClient_PullIsApproved(t *testing.T) {
+	t.Log("a merge request that still needs approvals shouldn't be approved")
+	client, stop := gitlabApprovalsClient(t, fixtures.GitlabApprovals)
+	defer stop()
+	approved, err := client.PullIsApproved(context.Background(), gitlabRepo, models.PullRequest{Num: 5})
+	Ok(t, err)
+	Equals(t, false, approved)
+	approvers, err := client.GetApprovers(context.Background(), gitlabRepo, models.PullRequest{Num: 5})
+	Ok(t, err)
+	Equals(t, []string{"root"}, approvers)
+
+	t.Log("once GitLab says it's approved it should be")
+	client, stop = gitlabApprovalsClient(t, strings.Replace(strings.Replace(fixtures.GitlabApprovals, `"approved": false`, `"approved": true`, 1), `"approvals_left": 1`, `"approvals_left": 0`, 1))
+	defer stop()
+	approved, err = client.PullIsApproved(context.Background(), gitlabRepo, models.PullRequest{Num: 5})
+	Ok(t, err)
+	Equals(t, true, approved)
+
+	t.Log("a merge request without approval rules should need an approval even though GitLab says it's approved")
+	client, stop = gitlabApprovalsClient(t, `{"approved": true, "approvals_required": 0, "approvals_left": 0, "approved_by": []}`)
+	defer stop()
+	approved, err = client.PullIsApproved(context.Background(), gitlabRepo, models.PullRequest{Num: 5})
+	Ok(t, err)
+	Equals(t, false, approved)
+}
+
+func TestGitlabClient_PullIsApprovedWithoutApproved(t *testing.T) {
+	t.Log("on GitLab versions that don't return approved, no approvals should be missing and someone should have approved")
+	client, stop := gitlabApprovalsClient(t, `{"approvals_required": 2, "approvals_missing": 1, "approved_by": [{"user": {"username": "root"}}]}`)
+	defer stop()
+	approved, err := client.PullIsApproved(context.Background(), gitlabRepo, models.PullRequest{Num: 5})
+	Ok(t, err)
+	Equals(t, false, approved)
+
+	client, stop = gitlabApprovalsClient(t, `{"approvals_required": 0, "approvals_missing": 0, "approved_by": []}`)
+	defer stop()
+	approved, err = client.PullIsApproved(context.Background(), gitlabRepo, models.PullRequest{Num: 5})
+	Ok(t, err)
+	Equals(t, false, approved)
+
+	client, stop = gitlabApprovalsClient(t, `{"approvals_required": 0, "approvals_missing": 0, "approved_by": [{"user": {"username": "root"}}]}`)
+	defer stop()
+	approved, err = client.PullIsApproved(context.Background(), gitlabRepo, models.PullRequest{Num: 5})
+	Ok(t, err)
+	Equals(t, true, approved)
+}
@@ -0,0 +1,20 @@
+package matchers
+
+import (
+	"reflect"
+
+	vcs "github.com/hootsuite/atlantis/server/events/vcs"
+	"github.com/petergtz/pegomock"
+)
+
+func AnySliceOfVcsComment() []vcs.Comment {
+	pegomock.RegisterMatcher(pegomock.NewAnyMatcher(reflect.TypeOf((*([]vcs.Comment))(nil)).Elem()))
+	var nullValue []vcs.Comment
+	return nullValue
+}
+
+func EqSliceOfVcsComment(value []vcs.Comment) []vcs.Comment {
+	pegomock.RegisterMatcher(&pegomock.EqMatcher{Value: value})
+	var nullValue []vcs.Comment
+	return nullValue
+}
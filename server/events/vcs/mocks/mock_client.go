@@ -4,6 +4,7 @@
 package mocks
 
 import (
+	context "context"
 	"reflect"
 
 	models "github.com/hootsuite/atlantis/server/events/models"
@@ -19,8 +20,8 @@ func NewMockClient() *MockClient {
 	return &MockClient{fail: pegomock.GlobalFailHandler}
 }
 
-func (mock *MockClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
-	params := []pegomock.Param{repo, pull}
+func (mock *MockClient) GetModifiedFiles(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]string, error) {
+	params := []pegomock.Param{ctx, repo, pull}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("GetModifiedFiles", params, []reflect.Type{reflect.TypeOf((*[]string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 []string
 	var ret1 error
@@ -35,8 +36,8 @@ func (mock *MockClient) GetModifiedFiles(repo models.Repo, pull models.PullReque
 	return ret0, ret1
 }
 
-func (mock *MockClient) CreateComment(repo models.Repo, pull models.PullRequest, comment string) error {
-	params := []pegomock.Param{repo, pull, comment}
+func (mock *MockClient) CreateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, comment string) error {
+	params := []pegomock.Param{ctx, repo, pull, comment}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("CreateComment", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 error
 	if len(result) != 0 {
@@ -47,8 +48,48 @@ func (mock *MockClient) CreateComment(repo models.Repo, pull models.PullRequest,
 	return ret0
 }
 
-func (mock *MockClient) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
-	params := []pegomock.Param{repo, pull}
+func (mock *MockClient) UpdateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, comment string) error {
+	params := []pegomock.Param{ctx, repo, pull, commentID, comment}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdateComment", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockClient) GetComments(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]vcs.Comment, error) {
+	params := []pegomock.Param{ctx, repo, pull}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetComments", params, []reflect.Type{reflect.TypeOf((*[]vcs.Comment)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 []vcs.Comment
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].([]vcs.Comment)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockClient) DeleteComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int) error {
+	params := []pegomock.Param{ctx, repo, pull, commentID}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("DeleteComment", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockClient) PullIsApproved(ctx context.Context, repo models.Repo, pull models.PullRequest) (bool, error) {
+	params := []pegomock.Param{ctx, repo, pull}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("PullIsApproved", params, []reflect.Type{reflect.TypeOf((*bool)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 bool
 	var ret1 error
@@ -63,8 +104,24 @@ func (mock *MockClient) PullIsApproved(repo models.Repo, pull models.PullRequest
 	return ret0, ret1
 }
 
-func (mock *MockClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, description string) error {
-	params := []pegomock.Param{repo, pull, state, description}
+func (mock *MockClient) GetApprovers(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]string, error) {
+	params := []pegomock.Param{ctx, repo, pull}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetApprovers", params, []reflect.Type{reflect.TypeOf((*[]string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 []string
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].([]string)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockClient) UpdateStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, description string) error {
+	params := []pegomock.Param{ctx, repo, pull, state, description}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdateStatus", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 error
 	if len(result) != 0 {
@@ -75,6 +132,50 @@ func (mock *MockClient) UpdateStatus(repo models.Repo, pull models.PullRequest,
 	return ret0
 }
 
+func (mock *MockClient) UpdateNamedStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, name string, description string) error {
+	params := []pegomock.Param{ctx, repo, pull, state, name, description}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdateNamedStatus", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockClient) UserIsMember(ctx context.Context, repo models.Repo, username string) (bool, error) {
+	params := []pegomock.Param{ctx, repo, username}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UserIsMember", params, []reflect.Type{reflect.TypeOf((*bool)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 bool
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(bool)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockClient) UserIsTeamMember(ctx context.Context, team string, username string) (bool, error) {
+	params := []pegomock.Param{ctx, team, username}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UserIsTeamMember", params, []reflect.Type{reflect.TypeOf((*bool)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 bool
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(bool)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
 func (mock *MockClient) VerifyWasCalledOnce() *VerifierClient {
 	return &VerifierClient{mock, pegomock.Times(1), nil}
 }
@@ -93,8 +194,8 @@ type VerifierClient struct {
 	inOrderContext         *pegomock.InOrderContext
 }
 
-func (verifier *VerifierClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) *Client_GetModifiedFiles_OngoingVerification {
-	params := []pegomock.Param{repo, pull}
+func (verifier *VerifierClient) GetModifiedFiles(ctx context.Context, repo models.Repo, pull models.PullRequest) *Client_GetModifiedFiles_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetModifiedFiles", params)
 	return &Client_GetModifiedFiles_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -104,28 +205,32 @@ type Client_GetModifiedFiles_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *Client_GetModifiedFiles_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest) {
-	repo, pull := c.GetAllCapturedArguments()
-	return repo[len(repo)-1], pull[len(pull)-1]
+func (c *Client_GetModifiedFiles_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest) {
+	ctx, repo, pull := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1]
 }
 
-func (c *Client_GetModifiedFiles_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest) {
+func (c *Client_GetModifiedFiles_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]models.Repo, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(models.Repo)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]models.PullRequest, len(params[1]))
+		_param1 = make([]models.Repo, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(models.PullRequest)
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
 		}
 	}
 	return
 }
 
-func (verifier *VerifierClient) CreateComment(repo models.Repo, pull models.PullRequest, comment string) *Client_CreateComment_OngoingVerification {
-	params := []pegomock.Param{repo, pull, comment}
+func (verifier *VerifierClient) CreateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, comment string) *Client_CreateComment_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, comment}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "CreateComment", params)
 	return &Client_CreateComment_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -135,32 +240,153 @@ type Client_CreateComment_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *Client_CreateComment_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, string) {
-	repo, pull, comment := c.GetAllCapturedArguments()
-	return repo[len(repo)-1], pull[len(pull)-1], comment[len(comment)-1]
+func (c *Client_CreateComment_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, string) {
+	ctx, repo, pull, comment := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], comment[len(comment)-1]
 }
 
-func (c *Client_CreateComment_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []string) {
+func (c *Client_CreateComment_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []string) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]models.Repo, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(models.Repo)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]models.PullRequest, len(params[1]))
+		_param1 = make([]models.Repo, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(models.PullRequest)
+			_param1[u] = param.(models.Repo)
 		}
-		_param2 = make([]string, len(params[2]))
+		_param2 = make([]models.PullRequest, len(params[2]))
 		for u, param := range params[2] {
-			_param2[u] = param.(string)
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]string, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(string)
 		}
 	}
 	return
 }
 
-func (verifier *VerifierClient) PullIsApproved(repo models.Repo, pull models.PullRequest) *Client_PullIsApproved_OngoingVerification {
-	params := []pegomock.Param{repo, pull}
+func (verifier *VerifierClient) UpdateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, comment string) *Client_UpdateComment_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, commentID, comment}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdateComment", params)
+	return &Client_UpdateComment_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Client_UpdateComment_OngoingVerification struct {
+	mock              *MockClient
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Client_UpdateComment_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, int, string) {
+	ctx, repo, pull, commentID, comment := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], commentID[len(commentID)-1], comment[len(comment)-1]
+}
+
+func (c *Client_UpdateComment_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []int, _param4 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]models.Repo, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]int, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(int)
+		}
+		_param4 = make([]string, len(params[4]))
+		for u, param := range params[4] {
+			_param4[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClient) GetComments(ctx context.Context, repo models.Repo, pull models.PullRequest) *Client_GetComments_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetComments", params)
+	return &Client_GetComments_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Client_GetComments_OngoingVerification struct {
+	mock              *MockClient
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Client_GetComments_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest) {
+	ctx, repo, pull := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1]
+}
+
+func (c *Client_GetComments_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]models.Repo, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClient) DeleteComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int) *Client_DeleteComment_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, commentID}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "DeleteComment", params)
+	return &Client_DeleteComment_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Client_DeleteComment_OngoingVerification struct {
+	mock              *MockClient
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Client_DeleteComment_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, int) {
+	ctx, repo, pull, commentID := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], commentID[len(commentID)-1]
+}
+
+func (c *Client_DeleteComment_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []int) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]models.Repo, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]int, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(int)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClient) PullIsApproved(ctx context.Context, repo models.Repo, pull models.PullRequest) *Client_PullIsApproved_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "PullIsApproved", params)
 	return &Client_PullIsApproved_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -170,28 +396,67 @@ type Client_PullIsApproved_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *Client_PullIsApproved_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest) {
-	repo, pull := c.GetAllCapturedArguments()
-	return repo[len(repo)-1], pull[len(pull)-1]
+func (c *Client_PullIsApproved_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest) {
+	ctx, repo, pull := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1]
 }
 
-func (c *Client_PullIsApproved_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest) {
+func (c *Client_PullIsApproved_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]models.Repo, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(models.Repo)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]models.PullRequest, len(params[1]))
+		_param1 = make([]models.Repo, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(models.PullRequest)
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
 		}
 	}
 	return
 }
 
-func (verifier *VerifierClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, description string) *Client_UpdateStatus_OngoingVerification {
-	params := []pegomock.Param{repo, pull, state, description}
+func (verifier *VerifierClient) GetApprovers(ctx context.Context, repo models.Repo, pull models.PullRequest) *Client_GetApprovers_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetApprovers", params)
+	return &Client_GetApprovers_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Client_GetApprovers_OngoingVerification struct {
+	mock              *MockClient
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Client_GetApprovers_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest) {
+	ctx, repo, pull := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1]
+}
+
+func (c *Client_GetApprovers_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]models.Repo, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClient) UpdateStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, description string) *Client_UpdateStatus_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, state, description}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdateStatus", params)
 	return &Client_UpdateStatus_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -201,29 +466,150 @@ type Client_UpdateStatus_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *Client_UpdateStatus_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, vcs.CommitStatus, string) {
-	repo, pull, state, description := c.GetAllCapturedArguments()
-	return repo[len(repo)-1], pull[len(pull)-1], state[len(state)-1], description[len(description)-1]
+func (c *Client_UpdateStatus_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, vcs.CommitStatus, string) {
+	ctx, repo, pull, state, description := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], state[len(state)-1], description[len(description)-1]
 }
 
-func (c *Client_UpdateStatus_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []vcs.CommitStatus, _param3 []string) {
+func (c *Client_UpdateStatus_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []vcs.CommitStatus, _param4 []string) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]models.Repo, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(models.Repo)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]models.PullRequest, len(params[1]))
+		_param1 = make([]models.Repo, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(models.PullRequest)
+			_param1[u] = param.(models.Repo)
 		}
-		_param2 = make([]vcs.CommitStatus, len(params[2]))
+		_param2 = make([]models.PullRequest, len(params[2]))
 		for u, param := range params[2] {
-			_param2[u] = param.(vcs.CommitStatus)
+			_param2[u] = param.(models.PullRequest)
 		}
-		_param3 = make([]string, len(params[3]))
+		_param3 = make([]vcs.CommitStatus, len(params[3]))
 		for u, param := range params[3] {
-			_param3[u] = param.(string)
+			_param3[u] = param.(vcs.CommitStatus)
+		}
+		_param4 = make([]string, len(params[4]))
+		for u, param := range params[4] {
+			_param4[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClient) UpdateNamedStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, name string, description string) *Client_UpdateNamedStatus_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, state, name, description}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdateNamedStatus", params)
+	return &Client_UpdateNamedStatus_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Client_UpdateNamedStatus_OngoingVerification struct {
+	mock              *MockClient
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Client_UpdateNamedStatus_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, vcs.CommitStatus, string, string) {
+	ctx, repo, pull, state, name, description := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], state[len(state)-1], name[len(name)-1], description[len(description)-1]
+}
+
+func (c *Client_UpdateNamedStatus_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []vcs.CommitStatus, _param4 []string, _param5 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]models.Repo, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]vcs.CommitStatus, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(vcs.CommitStatus)
+		}
+		_param4 = make([]string, len(params[4]))
+		for u, param := range params[4] {
+			_param4[u] = param.(string)
+		}
+		_param5 = make([]string, len(params[5]))
+		for u, param := range params[5] {
+			_param5[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClient) UserIsMember(ctx context.Context, repo models.Repo, username string) *Client_UserIsMember_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, username}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UserIsMember", params)
+	return &Client_UserIsMember_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Client_UserIsMember_OngoingVerification struct {
+	mock              *MockClient
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Client_UserIsMember_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, string) {
+	ctx, repo, username := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], username[len(username)-1]
+}
+
+func (c *Client_UserIsMember_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]models.Repo, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]string, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClient) UserIsTeamMember(ctx context.Context, team string, username string) *Client_UserIsTeamMember_OngoingVerification {
+	params := []pegomock.Param{ctx, team, username}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UserIsTeamMember", params)
+	return &Client_UserIsTeamMember_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Client_UserIsTeamMember_OngoingVerification struct {
+	mock              *MockClient
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Client_UserIsTeamMember_OngoingVerification) GetCapturedArguments() (context.Context, string, string) {
+	ctx, team, username := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], team[len(team)-1], username[len(username)-1]
+}
+
+func (c *Client_UserIsTeamMember_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []string, _param2 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]string, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(string)
+		}
+		_param2 = make([]string, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
 		}
 	}
 	return
@@ -4,6 +4,7 @@
 package mocks
 
 import (
+	context "context"
 	"reflect"
 
 	models "github.com/hootsuite/atlantis/server/events/models"
@@ -19,8 +20,8 @@ func NewMockClientProxy() *MockClientProxy {
 	return &MockClientProxy{fail: pegomock.GlobalFailHandler}
 }
 
-func (mock *MockClientProxy) GetModifiedFiles(repo models.Repo, pull models.PullRequest, host vcs.Host) ([]string, error) {
-	params := []pegomock.Param{repo, pull, host}
+func (mock *MockClientProxy) GetModifiedFiles(ctx context.Context, repo models.Repo, pull models.PullRequest, host vcs.Host) ([]string, error) {
+	params := []pegomock.Param{ctx, repo, pull, host}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("GetModifiedFiles", params, []reflect.Type{reflect.TypeOf((*[]string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 []string
 	var ret1 error
@@ -35,8 +36,8 @@ func (mock *MockClientProxy) GetModifiedFiles(repo models.Repo, pull models.Pull
 	return ret0, ret1
 }
 
-func (mock *MockClientProxy) CreateComment(repo models.Repo, pull models.PullRequest, comment string, host vcs.Host) error {
-	params := []pegomock.Param{repo, pull, comment, host}
+func (mock *MockClientProxy) CreateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, comment string, host vcs.Host) error {
+	params := []pegomock.Param{ctx, repo, pull, comment, host}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("CreateComment", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 error
 	if len(result) != 0 {
@@ -47,8 +48,48 @@ func (mock *MockClientProxy) CreateComment(repo models.Repo, pull models.PullReq
 	return ret0
 }
 
-func (mock *MockClientProxy) PullIsApproved(repo models.Repo, pull models.PullRequest, host vcs.Host) (bool, error) {
-	params := []pegomock.Param{repo, pull, host}
+func (mock *MockClientProxy) UpdateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, comment string, host vcs.Host) error {
+	params := []pegomock.Param{ctx, repo, pull, commentID, comment, host}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdateComment", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockClientProxy) GetComments(ctx context.Context, repo models.Repo, pull models.PullRequest, host vcs.Host) ([]vcs.Comment, error) {
+	params := []pegomock.Param{ctx, repo, pull, host}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetComments", params, []reflect.Type{reflect.TypeOf((*[]vcs.Comment)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 []vcs.Comment
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].([]vcs.Comment)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockClientProxy) DeleteComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, host vcs.Host) error {
+	params := []pegomock.Param{ctx, repo, pull, commentID, host}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("DeleteComment", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockClientProxy) PullIsApproved(ctx context.Context, repo models.Repo, pull models.PullRequest, host vcs.Host) (bool, error) {
+	params := []pegomock.Param{ctx, repo, pull, host}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("PullIsApproved", params, []reflect.Type{reflect.TypeOf((*bool)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 bool
 	var ret1 error
@@ -63,8 +104,24 @@ func (mock *MockClientProxy) PullIsApproved(repo models.Repo, pull models.PullRe
 	return ret0, ret1
 }
 
-func (mock *MockClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, description string, host vcs.Host) error {
-	params := []pegomock.Param{repo, pull, state, description, host}
+func (mock *MockClientProxy) GetApprovers(ctx context.Context, repo models.Repo, pull models.PullRequest, host vcs.Host) ([]string, error) {
+	params := []pegomock.Param{ctx, repo, pull, host}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetApprovers", params, []reflect.Type{reflect.TypeOf((*[]string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 []string
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].([]string)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockClientProxy) UpdateStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, description string, host vcs.Host) error {
+	params := []pegomock.Param{ctx, repo, pull, state, description, host}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdateStatus", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 error
 	if len(result) != 0 {
@@ -75,6 +132,50 @@ func (mock *MockClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequ
 	return ret0
 }
 
+func (mock *MockClientProxy) UpdateNamedStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, name string, description string, host vcs.Host) error {
+	params := []pegomock.Param{ctx, repo, pull, state, name, description, host}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdateNamedStatus", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockClientProxy) UserIsMember(ctx context.Context, repo models.Repo, username string, host vcs.Host) (bool, error) {
+	params := []pegomock.Param{ctx, repo, username, host}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UserIsMember", params, []reflect.Type{reflect.TypeOf((*bool)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 bool
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(bool)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockClientProxy) UserIsTeamMember(ctx context.Context, team string, username string, host vcs.Host) (bool, error) {
+	params := []pegomock.Param{ctx, team, username, host}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UserIsTeamMember", params, []reflect.Type{reflect.TypeOf((*bool)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 bool
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(bool)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
 func (mock *MockClientProxy) VerifyWasCalledOnce() *VerifierClientProxy {
 	return &VerifierClientProxy{mock, pegomock.Times(1), nil}
 }
@@ -93,8 +194,8 @@ type VerifierClientProxy struct {
 	inOrderContext         *pegomock.InOrderContext
 }
 
-func (verifier *VerifierClientProxy) GetModifiedFiles(repo models.Repo, pull models.PullRequest, host vcs.Host) *ClientProxy_GetModifiedFiles_OngoingVerification {
-	params := []pegomock.Param{repo, pull, host}
+func (verifier *VerifierClientProxy) GetModifiedFiles(ctx context.Context, repo models.Repo, pull models.PullRequest, host vcs.Host) *ClientProxy_GetModifiedFiles_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, host}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetModifiedFiles", params)
 	return &ClientProxy_GetModifiedFiles_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -104,32 +205,36 @@ type ClientProxy_GetModifiedFiles_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *ClientProxy_GetModifiedFiles_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, vcs.Host) {
-	repo, pull, host := c.GetAllCapturedArguments()
-	return repo[len(repo)-1], pull[len(pull)-1], host[len(host)-1]
+func (c *ClientProxy_GetModifiedFiles_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, vcs.Host) {
+	ctx, repo, pull, host := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], host[len(host)-1]
 }
 
-func (c *ClientProxy_GetModifiedFiles_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []vcs.Host) {
+func (c *ClientProxy_GetModifiedFiles_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []vcs.Host) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]models.Repo, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(models.Repo)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]models.PullRequest, len(params[1]))
+		_param1 = make([]models.Repo, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(models.PullRequest)
+			_param1[u] = param.(models.Repo)
 		}
-		_param2 = make([]vcs.Host, len(params[2]))
+		_param2 = make([]models.PullRequest, len(params[2]))
 		for u, param := range params[2] {
-			_param2[u] = param.(vcs.Host)
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]vcs.Host, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(vcs.Host)
 		}
 	}
 	return
 }
 
-func (verifier *VerifierClientProxy) CreateComment(repo models.Repo, pull models.PullRequest, comment string, host vcs.Host) *ClientProxy_CreateComment_OngoingVerification {
-	params := []pegomock.Param{repo, pull, comment, host}
+func (verifier *VerifierClientProxy) CreateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, comment string, host vcs.Host) *ClientProxy_CreateComment_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, comment, host}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "CreateComment", params)
 	return &ClientProxy_CreateComment_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -139,25 +244,115 @@ type ClientProxy_CreateComment_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *ClientProxy_CreateComment_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, string, vcs.Host) {
-	repo, pull, comment, host := c.GetAllCapturedArguments()
-	return repo[len(repo)-1], pull[len(pull)-1], comment[len(comment)-1], host[len(host)-1]
+func (c *ClientProxy_CreateComment_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, string, vcs.Host) {
+	ctx, repo, pull, comment, host := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], comment[len(comment)-1], host[len(host)-1]
 }
 
-func (c *ClientProxy_CreateComment_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []string, _param3 []vcs.Host) {
+func (c *ClientProxy_CreateComment_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []string, _param4 []vcs.Host) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]models.Repo, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(models.Repo)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]models.PullRequest, len(params[1]))
+		_param1 = make([]models.Repo, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(models.PullRequest)
+			_param1[u] = param.(models.Repo)
 		}
-		_param2 = make([]string, len(params[2]))
+		_param2 = make([]models.PullRequest, len(params[2]))
 		for u, param := range params[2] {
-			_param2[u] = param.(string)
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]string, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(string)
+		}
+		_param4 = make([]vcs.Host, len(params[4]))
+		for u, param := range params[4] {
+			_param4[u] = param.(vcs.Host)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClientProxy) UpdateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, comment string, host vcs.Host) *ClientProxy_UpdateComment_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, commentID, comment, host}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdateComment", params)
+	return &ClientProxy_UpdateComment_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type ClientProxy_UpdateComment_OngoingVerification struct {
+	mock              *MockClientProxy
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *ClientProxy_UpdateComment_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, int, string, vcs.Host) {
+	ctx, repo, pull, commentID, comment, host := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], commentID[len(commentID)-1], comment[len(comment)-1], host[len(host)-1]
+}
+
+func (c *ClientProxy_UpdateComment_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []int, _param4 []string, _param5 []vcs.Host) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]models.Repo, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]int, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(int)
+		}
+		_param4 = make([]string, len(params[4]))
+		for u, param := range params[4] {
+			_param4[u] = param.(string)
+		}
+		_param5 = make([]vcs.Host, len(params[5]))
+		for u, param := range params[5] {
+			_param5[u] = param.(vcs.Host)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClientProxy) GetComments(ctx context.Context, repo models.Repo, pull models.PullRequest, host vcs.Host) *ClientProxy_GetComments_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, host}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetComments", params)
+	return &ClientProxy_GetComments_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type ClientProxy_GetComments_OngoingVerification struct {
+	mock              *MockClientProxy
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *ClientProxy_GetComments_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, vcs.Host) {
+	ctx, repo, pull, host := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], host[len(host)-1]
+}
+
+func (c *ClientProxy_GetComments_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []vcs.Host) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]models.Repo, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
 		}
 		_param3 = make([]vcs.Host, len(params[3]))
 		for u, param := range params[3] {
@@ -167,8 +362,51 @@ func (c *ClientProxy_CreateComment_OngoingVerification) GetAllCapturedArguments(
 	return
 }
 
-func (verifier *VerifierClientProxy) PullIsApproved(repo models.Repo, pull models.PullRequest, host vcs.Host) *ClientProxy_PullIsApproved_OngoingVerification {
-	params := []pegomock.Param{repo, pull, host}
+func (verifier *VerifierClientProxy) DeleteComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, host vcs.Host) *ClientProxy_DeleteComment_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, commentID, host}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "DeleteComment", params)
+	return &ClientProxy_DeleteComment_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type ClientProxy_DeleteComment_OngoingVerification struct {
+	mock              *MockClientProxy
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *ClientProxy_DeleteComment_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, int, vcs.Host) {
+	ctx, repo, pull, commentID, host := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], commentID[len(commentID)-1], host[len(host)-1]
+}
+
+func (c *ClientProxy_DeleteComment_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []int, _param4 []vcs.Host) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]models.Repo, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]int, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(int)
+		}
+		_param4 = make([]vcs.Host, len(params[4]))
+		for u, param := range params[4] {
+			_param4[u] = param.(vcs.Host)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClientProxy) PullIsApproved(ctx context.Context, repo models.Repo, pull models.PullRequest, host vcs.Host) *ClientProxy_PullIsApproved_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, host}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "PullIsApproved", params)
 	return &ClientProxy_PullIsApproved_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -178,32 +416,75 @@ type ClientProxy_PullIsApproved_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *ClientProxy_PullIsApproved_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, vcs.Host) {
-	repo, pull, host := c.GetAllCapturedArguments()
-	return repo[len(repo)-1], pull[len(pull)-1], host[len(host)-1]
+func (c *ClientProxy_PullIsApproved_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, vcs.Host) {
+	ctx, repo, pull, host := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], host[len(host)-1]
+}
+
+func (c *ClientProxy_PullIsApproved_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []vcs.Host) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]models.Repo, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]vcs.Host, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(vcs.Host)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClientProxy) GetApprovers(ctx context.Context, repo models.Repo, pull models.PullRequest, host vcs.Host) *ClientProxy_GetApprovers_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, host}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetApprovers", params)
+	return &ClientProxy_GetApprovers_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type ClientProxy_GetApprovers_OngoingVerification struct {
+	mock              *MockClientProxy
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *ClientProxy_GetApprovers_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, vcs.Host) {
+	ctx, repo, pull, host := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], host[len(host)-1]
 }
 
-func (c *ClientProxy_PullIsApproved_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []vcs.Host) {
+func (c *ClientProxy_GetApprovers_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []vcs.Host) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]models.Repo, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(models.Repo)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]models.PullRequest, len(params[1]))
+		_param1 = make([]models.Repo, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(models.PullRequest)
+			_param1[u] = param.(models.Repo)
 		}
-		_param2 = make([]vcs.Host, len(params[2]))
+		_param2 = make([]models.PullRequest, len(params[2]))
 		for u, param := range params[2] {
-			_param2[u] = param.(vcs.Host)
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]vcs.Host, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(vcs.Host)
 		}
 	}
 	return
 }
 
-func (verifier *VerifierClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, description string, host vcs.Host) *ClientProxy_UpdateStatus_OngoingVerification {
-	params := []pegomock.Param{repo, pull, state, description, host}
+func (verifier *VerifierClientProxy) UpdateStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, description string, host vcs.Host) *ClientProxy_UpdateStatus_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, state, description, host}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdateStatus", params)
 	return &ClientProxy_UpdateStatus_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -213,33 +494,166 @@ type ClientProxy_UpdateStatus_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *ClientProxy_UpdateStatus_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, vcs.CommitStatus, string, vcs.Host) {
-	repo, pull, state, description, host := c.GetAllCapturedArguments()
-	return repo[len(repo)-1], pull[len(pull)-1], state[len(state)-1], description[len(description)-1], host[len(host)-1]
+func (c *ClientProxy_UpdateStatus_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, vcs.CommitStatus, string, vcs.Host) {
+	ctx, repo, pull, state, description, host := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], state[len(state)-1], description[len(description)-1], host[len(host)-1]
 }
 
-func (c *ClientProxy_UpdateStatus_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []vcs.CommitStatus, _param3 []string, _param4 []vcs.Host) {
+func (c *ClientProxy_UpdateStatus_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []vcs.CommitStatus, _param4 []string, _param5 []vcs.Host) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]models.Repo, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(models.Repo)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]models.PullRequest, len(params[1]))
+		_param1 = make([]models.Repo, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(models.PullRequest)
+			_param1[u] = param.(models.Repo)
 		}
-		_param2 = make([]vcs.CommitStatus, len(params[2]))
+		_param2 = make([]models.PullRequest, len(params[2]))
 		for u, param := range params[2] {
-			_param2[u] = param.(vcs.CommitStatus)
+			_param2[u] = param.(models.PullRequest)
 		}
-		_param3 = make([]string, len(params[3]))
+		_param3 = make([]vcs.CommitStatus, len(params[3]))
 		for u, param := range params[3] {
-			_param3[u] = param.(string)
+			_param3[u] = param.(vcs.CommitStatus)
 		}
-		_param4 = make([]vcs.Host, len(params[4]))
+		_param4 = make([]string, len(params[4]))
 		for u, param := range params[4] {
-			_param4[u] = param.(vcs.Host)
+			_param4[u] = param.(string)
+		}
+		_param5 = make([]vcs.Host, len(params[5]))
+		for u, param := range params[5] {
+			_param5[u] = param.(vcs.Host)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClientProxy) UpdateNamedStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, name string, description string, host vcs.Host) *ClientProxy_UpdateNamedStatus_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, pull, state, name, description, host}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdateNamedStatus", params)
+	return &ClientProxy_UpdateNamedStatus_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type ClientProxy_UpdateNamedStatus_OngoingVerification struct {
+	mock              *MockClientProxy
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *ClientProxy_UpdateNamedStatus_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, models.PullRequest, vcs.CommitStatus, string, string, vcs.Host) {
+	ctx, repo, pull, state, name, description, host := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], pull[len(pull)-1], state[len(state)-1], name[len(name)-1], description[len(description)-1], host[len(host)-1]
+}
+
+func (c *ClientProxy_UpdateNamedStatus_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []models.PullRequest, _param3 []vcs.CommitStatus, _param4 []string, _param5 []string, _param6 []vcs.Host) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]models.Repo, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]models.PullRequest, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(models.PullRequest)
+		}
+		_param3 = make([]vcs.CommitStatus, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(vcs.CommitStatus)
+		}
+		_param4 = make([]string, len(params[4]))
+		for u, param := range params[4] {
+			_param4[u] = param.(string)
+		}
+		_param5 = make([]string, len(params[5]))
+		for u, param := range params[5] {
+			_param5[u] = param.(string)
+		}
+		_param6 = make([]vcs.Host, len(params[6]))
+		for u, param := range params[6] {
+			_param6[u] = param.(vcs.Host)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClientProxy) UserIsMember(ctx context.Context, repo models.Repo, username string, host vcs.Host) *ClientProxy_UserIsMember_OngoingVerification {
+	params := []pegomock.Param{ctx, repo, username, host}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UserIsMember", params)
+	return &ClientProxy_UserIsMember_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type ClientProxy_UserIsMember_OngoingVerification struct {
+	mock              *MockClientProxy
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *ClientProxy_UserIsMember_OngoingVerification) GetCapturedArguments() (context.Context, models.Repo, string, vcs.Host) {
+	ctx, repo, username, host := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], repo[len(repo)-1], username[len(username)-1], host[len(host)-1]
+}
+
+func (c *ClientProxy_UserIsMember_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []models.Repo, _param2 []string, _param3 []vcs.Host) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]models.Repo, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.Repo)
+		}
+		_param2 = make([]string, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+		_param3 = make([]vcs.Host, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(vcs.Host)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClientProxy) UserIsTeamMember(ctx context.Context, team string, username string, host vcs.Host) *ClientProxy_UserIsTeamMember_OngoingVerification {
+	params := []pegomock.Param{ctx, team, username, host}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UserIsTeamMember", params)
+	return &ClientProxy_UserIsTeamMember_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type ClientProxy_UserIsTeamMember_OngoingVerification struct {
+	mock              *MockClientProxy
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *ClientProxy_UserIsTeamMember_OngoingVerification) GetCapturedArguments() (context.Context, string, string, vcs.Host) {
+	ctx, team, username, host := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], team[len(team)-1], username[len(username)-1], host[len(host)-1]
+}
+
+func (c *ClientProxy_UserIsTeamMember_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []string, _param2 []string, _param3 []vcs.Host) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]string, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(string)
+		}
+		_param2 = make([]string, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+		_param3 = make([]vcs.Host, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(vcs.Host)
 		}
 	}
 	return
@@ -1,6 +1,7 @@
 package vcs
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/hootsuite/atlantis/server/events/models"
@@ -13,18 +14,39 @@ type NotConfiguredVCSClient struct {
 	Host Host
 }
 
-func (a *NotConfiguredVCSClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
+func (a *NotConfiguredVCSClient) GetModifiedFiles(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]string, error) {
 	return nil, a.err()
 }
-func (a *NotConfiguredVCSClient) CreateComment(repo models.Repo, pull models.PullRequest, comment string) error {
+func (a *NotConfiguredVCSClient) CreateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, comment string) error {
 	return a.err()
 }
-func (a *NotConfiguredVCSClient) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
+func (a *NotConfiguredVCSClient) UpdateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, comment string) error {
+	return a.err()
+}
+func (a *NotConfiguredVCSClient) GetComments(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]Comment, error) {
+	return nil, a.err()
+}
+func (a *NotConfiguredVCSClient) DeleteComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int) error {
+	return a.err()
+}
+func (a *NotConfiguredVCSClient) PullIsApproved(ctx context.Context, repo models.Repo, pull models.PullRequest) (bool, error) {
 	return false, a.err()
 }
-func (a *NotConfiguredVCSClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, description string) error {
+func (a *NotConfiguredVCSClient) GetApprovers(ctx context.Context, repo models.Repo, pull models.PullRequest) ([]string, error) {
+	return nil, a.err()
+}
+func (a *NotConfiguredVCSClient) UpdateStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state CommitStatus, description string) error {
+	return a.err()
+}
+func (a *NotConfiguredVCSClient) UpdateNamedStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state CommitStatus, name string, description string) error {
 	return a.err()
 }
+func (a *NotConfiguredVCSClient) UserIsMember(ctx context.Context, repo models.Repo, username string) (bool, error) {
+	return false, a.err()
+}
+func (a *NotConfiguredVCSClient) UserIsTeamMember(ctx context.Context, team string, username string) (bool, error) {
+	return false, a.err()
+}
 func (a *NotConfiguredVCSClient) err() error {
 	//noinspection GoErrorStringFormat
 	return fmt.Errorf("Atlantis was not configured to support repos from %s", a.Host.String())
@@ -1,6 +1,8 @@
 package vcs
 
 import (
+	"context"
+
 	"github.com/hootsuite/atlantis/server/events/models"
 	"github.com/pkg/errors"
 )
@@ -10,10 +12,17 @@ import (
 // ClientProxy proxies calls to the correct VCS client depending on which
 // VCS host is required.
 type ClientProxy interface {
-	GetModifiedFiles(repo models.Repo, pull models.PullRequest, host Host) ([]string, error)
-	CreateComment(repo models.Repo, pull models.PullRequest, comment string, host Host) error
-	PullIsApproved(repo models.Repo, pull models.PullRequest, host Host) (bool, error)
-	UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, description string, host Host) error
+	GetModifiedFiles(ctx context.Context, repo models.Repo, pull models.PullRequest, host Host) ([]string, error)
+	CreateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, comment string, host Host) error
+	UpdateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, comment string, host Host) error
+	GetComments(ctx context.Context, repo models.Repo, pull models.PullRequest, host Host) ([]Comment, error)
+	DeleteComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, host Host) error
+	PullIsApproved(ctx context.Context, repo models.Repo, pull models.PullRequest, host Host) (bool, error)
+	GetApprovers(ctx context.Context, repo models.Repo, pull models.PullRequest, host Host) ([]string, error)
+	UpdateStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state CommitStatus, description string, host Host) error
+	UpdateNamedStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state CommitStatus, name string, description string, host Host) error
+	UserIsMember(ctx context.Context, repo models.Repo, username string, host Host) (bool, error)
+	UserIsTeamMember(ctx context.Context, team string, username string, host Host) (bool, error)
 }
 
 // DefaultClientProxy proxies calls to the correct VCS client depending on which
@@ -38,42 +47,112 @@ func NewDefaultClientProxy(githubClient Client, gitlabClient Client) *DefaultCli
 
 var invalidVCSErr = errors.New("Invalid VCS Host. This is a bug!")
 
-func (d *DefaultClientProxy) GetModifiedFiles(repo models.Repo, pull models.PullRequest, host Host) ([]string, error) {
+func (d *DefaultClientProxy) GetModifiedFiles(ctx context.Context, repo models.Repo, pull models.PullRequest, host Host) ([]string, error) {
 	switch host {
 	case Github:
-		return d.GithubClient.GetModifiedFiles(repo, pull)
+		return d.GithubClient.GetModifiedFiles(ctx, repo, pull)
 	case Gitlab:
-		return d.GitlabClient.GetModifiedFiles(repo, pull)
+		return d.GitlabClient.GetModifiedFiles(ctx, repo, pull)
 	}
 	return nil, invalidVCSErr
 }
 
-func (d *DefaultClientProxy) CreateComment(repo models.Repo, pull models.PullRequest, comment string, host Host) error {
+func (d *DefaultClientProxy) CreateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, comment string, host Host) error {
 	switch host {
 	case Github:
-		return d.GithubClient.CreateComment(repo, pull, comment)
+		return d.GithubClient.CreateComment(ctx, repo, pull, comment)
 	case Gitlab:
-		return d.GitlabClient.CreateComment(repo, pull, comment)
+		return d.GitlabClient.CreateComment(ctx, repo, pull, comment)
 	}
 	return invalidVCSErr
 }
 
-func (d *DefaultClientProxy) PullIsApproved(repo models.Repo, pull models.PullRequest, host Host) (bool, error) {
+func (d *DefaultClientProxy) UpdateComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, comment string, host Host) error {
 	switch host {
 	case Github:
-		return d.GithubClient.PullIsApproved(repo, pull)
+		return d.GithubClient.UpdateComment(ctx, repo, pull, commentID, comment)
 	case Gitlab:
-		return d.GitlabClient.PullIsApproved(repo, pull)
+		return d.GitlabClient.UpdateComment(ctx, repo, pull, commentID, comment)
+	}
+	return invalidVCSErr
+}
+
+func (d *DefaultClientProxy) GetComments(ctx context.Context, repo models.Repo, pull models.PullRequest, host Host) ([]Comment, error) {
+	switch host {
+	case Github:
+		return d.GithubClient.GetComments(ctx, repo, pull)
+	case Gitlab:
+		return d.GitlabClient.GetComments(ctx, repo, pull)
+	}
+	return nil, invalidVCSErr
+}
+
+func (d *DefaultClientProxy) DeleteComment(ctx context.Context, repo models.Repo, pull models.PullRequest, commentID int, host Host) error {
+	switch host {
+	case Github:
+		return d.GithubClient.DeleteComment(ctx, repo, pull, commentID)
+	case Gitlab:
+		return d.GitlabClient.DeleteComment(ctx, repo, pull, commentID)
+	}
+	return invalidVCSErr
+}
+
+func (d *DefaultClientProxy) PullIsApproved(ctx context.Context, repo models.Repo, pull models.PullRequest, host Host) (bool, error) {
+	switch host {
+	case Github:
+		return d.GithubClient.PullIsApproved(ctx, repo, pull)
+	case Gitlab:
+		return d.GitlabClient.PullIsApproved(ctx, repo, pull)
 	}
 	return false, invalidVCSErr
 }
 
-func (d *DefaultClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, description string, host Host) error {
+func (d *DefaultClientProxy) GetApprovers(ctx context.Context, repo models.Repo, pull models.PullRequest, host Host) ([]string, error) {
+	switch host {
+	case Github:
+		return d.GithubClient.GetApprovers(ctx, repo, pull)
+	case Gitlab:
+		return d.GitlabClient.GetApprovers(ctx, repo, pull)
+	}
+	return nil, invalidVCSErr
+}
+
+func (d *DefaultClientProxy) UpdateStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state CommitStatus, description string, host Host) error {
 	switch host {
 	case Github:
-		return d.GithubClient.UpdateStatus(repo, pull, state, description)
+		return d.GithubClient.UpdateStatus(ctx, repo, pull, state, description)
 	case Gitlab:
-		return d.GitlabClient.UpdateStatus(repo, pull, state, description)
+		return d.GitlabClient.UpdateStatus(ctx, repo, pull, state, description)
 	}
 	return invalidVCSErr
 }
+
+func (d *DefaultClientProxy) UpdateNamedStatus(ctx context.Context, repo models.Repo, pull models.PullRequest, state CommitStatus, name string, description string, host Host) error {
+	switch host {
+	case Github:
+		return d.GithubClient.UpdateNamedStatus(ctx, repo, pull, state, name, description)
+	case Gitlab:
+		return d.GitlabClient.UpdateNamedStatus(ctx, repo, pull, state, name, description)
+	}
+	return invalidVCSErr
+}
+
+func (d *DefaultClientProxy) UserIsMember(ctx context.Context, repo models.Repo, username string, host Host) (bool, error) {
+	switch host {
+	case Github:
+		return d.GithubClient.UserIsMember(ctx, repo, username)
+	case Gitlab:
+		return d.GitlabClient.UserIsMember(ctx, repo, username)
+	}
+	return false, invalidVCSErr
+}
+
+func (d *DefaultClientProxy) UserIsTeamMember(ctx context.Context, team string, username string, host Host) (bool, error) {
+	switch host {
+	case Github:
+		return d.GithubClient.UserIsTeamMember(ctx, team, username)
+	case Gitlab:
+		return d.GitlabClient.UserIsTeamMember(ctx, team, username)
+	}
+	return false, invalidVCSErr
+}
@@ -17,6 +17,10 @@ func (h Host) String() string {
 	return "<missing String() implementation>"
 }
 
+// statusContext is the name of the commit status that Atlantis sets for its
+// commands.
+const statusContext = "Atlantis"
+
 // CommitStatus is the result of executing an Atlantis command for the commit.
 // In Github the options are: error, failure, pending, success.
 // In Gitlab the options are: failed, canceled, pending, running, success.
@@ -0,0 +1,88 @@
+package events
+
+import (
+	"path/filepath"
+
+	"github.com/hootsuite/atlantis/server/events/models"
+)
+
+// versionCheckWorkspace is the workspace we clone into when checking versions.
+// Cloning deletes what was in the workspace before so we can't use the
+// workspace of a real environment or we'd delete its plans.
+const versionCheckWorkspace = ".version-check"
+
+// ProjectDeterminer determines which projects a command should run in.
+type ProjectDeterminer interface {
+	DetermineProjects(ctx *CommandContext) ([]models.Project, error)
+}
+
+// VersionCheckExecutor reports which version of terraform each project will
+// be run with and whether that version satisfies the constraints required for
+// the environment and by the project's config file. It doesn't run terraform.
+type VersionCheckExecutor struct {
+	Workspace         Workspace
+	ProjectDeterminer ProjectDeterminer
+	ProjectPreExecute *ProjectPreExecute
+}
+
+// VersionCheckSuccess is the result of checking the terraform version of a
+// project.
+type VersionCheckSuccess struct {
+	Environment string
+	// Version is the version of terraform the project will be run with.
+	Version string
+	// Constraint is the constraint required for Environment and by the
+	// project's config file or empty if there is none.
+	Constraint string
+	// Satisfied is true if Version satisfies Constraint.
+	Satisfied bool
+}
+
+func (v *VersionCheckExecutor) Execute(ctx *CommandContext) CommandResponse {
+	projects, err := v.ProjectDeterminer.DetermineProjects(ctx)
+	if err != nil {
+		return CommandResponse{Error: err}
+	}
+	if len(projects) == 0 {
+		return CommandResponse{Failure: "No Terraform files were modified."}
+	}
+
+	cloneDir, err := v.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, versionCheckWorkspace)
+	if err != nil {
+		return CommandResponse{Error: err}
+	}
+
+	results := []ProjectResult{}
+	for _, project := range projects {
+		ctx.Log.Info("checking terraform version for project at path %q", project.Path)
+		result := v.check(ctx, cloneDir, project)
+		result.Path = project.Path
+		results = append(results, result)
+	}
+	return CommandResponse{ProjectResults: results}
+}
+
+func (v *VersionCheckExecutor) check(ctx *CommandContext, repoDir string, project models.Project) ProjectResult {
+	var config ProjectConfig
+	absolutePath := filepath.Join(repoDir, project.Path)
+	if v.ProjectPreExecute.ConfigReader.Exists(absolutePath) {
+		var err error
+		config, err = v.ProjectPreExecute.ConfigReader.Read(absolutePath)
+		if err != nil {
+			return ProjectResult{Error: err}
+		}
+	}
+
+	env := ctx.Command.Environment
+	terraformVersion := v.ProjectPreExecute.TerraformVersion(config)
+	constraint, failure := v.ProjectPreExecute.CheckRequiredVersion(env, config, terraformVersion)
+	result := &VersionCheckSuccess{
+		Environment: env,
+		Version:     terraformVersion.String(),
+		Satisfied:   failure == "",
+	}
+	if constraint != nil {
+		result.Constraint = constraint.String()
+	}
+	return ProjectResult{VersionCheckSuccess: result}
+}
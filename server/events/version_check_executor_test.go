@@ -0,0 +1,128 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/mocks"
+	"github.com/hootsuite/atlantis/server/events/models"
+	tmocks "github.com/hootsuite/atlantis/server/events/terraform/mocks"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+var versionCheckCtx = events.CommandContext{
+	Command: &events.Command{
+		Name:        events.VersionCheck,
+		Environment: "production",
+	},
+	Log: logging.NewNoopLogger(),
+}
+
+func TestVersionCheck_DetermineProjectsErr(t *testing.T) {
+	t.Log("If we can't determine the projects we return an error")
+	v, _, _ := setupVersionCheckTest(t, nil, errors.New("err"))
+	r := v.Execute(&versionCheckCtx)
+	Equals(t, "err", r.Error.Error())
+}
+
+func TestVersionCheck_NoProjects(t *testing.T) {
+	t.Log("If there are no modified projects we return a failure")
+	v, _, _ := setupVersionCheckTest(t, nil, nil)
+	r := v.Execute(&versionCheckCtx)
+	Equals(t, "No Terraform files were modified.", r.Failure)
+}
+
+func TestVersionCheck_Results(t *testing.T) {
+	t.Log("Each project should be checked against the environment's constraint")
+	projects := []models.Project{
+		{Path: "default-version"},
+		{Path: "pinned-version"},
+	}
+	v, tm, cr := setupVersionCheckTest(t, projects, nil)
+	defaultVersion, _ := version.NewVersion("0.11.1")
+	pinnedVersion, _ := version.NewVersion("0.10.8")
+	When(tm.Version()).ThenReturn(defaultVersion)
+	When(v.Workspace.Clone(versionCheckCtx.Log, versionCheckCtx.BaseRepo, versionCheckCtx.HeadRepo, versionCheckCtx.Pull, ".version-check")).
+		ThenReturn("/tmp/clone", nil)
+	When(cr.Exists("/tmp/clone/pinned-version")).ThenReturn(true)
+	When(cr.Read("/tmp/clone/pinned-version")).ThenReturn(events.ProjectConfig{TerraformVersion: pinnedVersion}, nil)
+	constraint, _ := version.NewConstraint("~> 0.11.0")
+	v.ProjectPreExecute.RequiredVersions = map[string]version.Constraints{"production": constraint}
+
+	r := v.Execute(&versionCheckCtx)
+	Equals(t, []events.ProjectResult{
+		{
+			Path: "default-version",
+			VersionCheckSuccess: &events.VersionCheckSuccess{
+				Environment: "production",
+				Version:     "0.11.1",
+				Constraint:  "~> 0.11.0",
+				Satisfied:   true,
+			},
+		},
+		{
+			Path: "pinned-version",
+			VersionCheckSuccess: &events.VersionCheckSuccess{
+				Environment: "production",
+				Version:     "0.10.8",
+				Constraint:  "~> 0.11.0",
+				Satisfied:   false,
+			},
+		},
+	}, r.ProjectResults)
+}
+
+func TestVersionCheck_ProjectConstraint(t *testing.T) {
+	t.Log("The constraint in the project's config file should be checked as well as the environment's")
+	projects := []models.Project{{Path: "project"}}
+	v, tm, cr := setupVersionCheckTest(t, projects, nil)
+	defaultVersion, _ := version.NewVersion("0.11.1")
+	When(tm.Version()).ThenReturn(defaultVersion)
+	When(v.Workspace.Clone(versionCheckCtx.Log, versionCheckCtx.BaseRepo, versionCheckCtx.HeadRepo, versionCheckCtx.Pull, ".version-check")).
+		ThenReturn("/tmp/clone", nil)
+	projectConstraint, _ := version.NewConstraint(">= 0.11.5")
+	When(cr.Exists("/tmp/clone/project")).ThenReturn(true)
+	When(cr.Read("/tmp/clone/project")).ThenReturn(events.ProjectConfig{RequiredVersion: projectConstraint}, nil)
+	envConstraint, _ := version.NewConstraint("~> 0.11.0")
+	v.ProjectPreExecute.RequiredVersions = map[string]version.Constraints{"production": envConstraint}
+
+	r := v.Execute(&versionCheckCtx)
+	Equals(t, []events.ProjectResult{
+		{
+			Path: "project",
+			VersionCheckSuccess: &events.VersionCheckSuccess{
+				Environment: "production",
+				Version:     "0.11.1",
+				Constraint:  "~> 0.11.0,>= 0.11.5",
+				Satisfied:   false,
+			},
+		},
+	}, r.ProjectResults)
+}
+
+func setupVersionCheckTest(t *testing.T, projects []models.Project, err error) (*events.VersionCheckExecutor, *tmocks.MockRunner, *mocks.MockProjectConfigReader) {
+	RegisterMockTestingT(t)
+	tm := tmocks.NewMockRunner()
+	cr := mocks.NewMockProjectConfigReader()
+	return &events.VersionCheckExecutor{
+		Workspace:         mocks.NewMockWorkspace(),
+		ProjectDeterminer: &fakeProjectDeterminer{projects, err},
+		ProjectPreExecute: &events.ProjectPreExecute{
+			ConfigReader: cr,
+			Terraform:    tm,
+		},
+	}, tm, cr
+}
+
+type fakeProjectDeterminer struct {
+	projects []models.Project
+	err      error
+}
+
+func (f *fakeProjectDeterminer) DetermineProjects(ctx *events.CommandContext) ([]models.Project, error) {
+	return f.projects, f.err
+}
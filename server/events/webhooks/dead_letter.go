@@ -0,0 +1,123 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events/jsonl"
+	"github.com/pkg/errors"
+)
+
+// DeadLetterFile is the name of the file, relative to the data dir, that
+// failed webhooks are written to.
+const DeadLetterFile = "webhooks-dead-letter.jsonl"
+
+// DeadLetter is a webhook that we failed to send.
+type DeadLetter struct {
+	// ID identifies the letter in the log. It's set when the letter is
+	// appended.
+	ID string `json:"id"`
+	// Time is when the send failed.
+	Time time.Time `json:"time"`
+	// Webhook is the index of the webhook that failed in the list of
+	// configured webhooks.
+	Webhook int `json:"webhook"`
+	// Result is the event that we were trying to send.
+	Result ApplyResult `json:"result"`
+	// Error is the last error we got when sending.
+	Error string `json:"error"`
+}
+
+// DeadLetterLog stores failed webhooks as newline-delimited JSON so they can
+// be inspected and re-sent later.
+type DeadLetterLog struct {
+	file *jsonl.File
+}
+
+// NewDeadLetterLog returns a DeadLetterLog that stores its file in dataDir.
+func NewDeadLetterLog(dataDir string) *DeadLetterLog {
+	return &DeadLetterLog{
+		file: jsonl.NewFile(filepath.Join(dataDir, DeadLetterFile)),
+	}
+}
+
+// Append adds l to the end of the log.
+func (d *DeadLetterLog) Append(l DeadLetter) error {
+	if l.ID == "" {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return errors.Wrap(err, "generating dead letter id")
+		}
+		l.ID = hex.EncodeToString(id)
+	}
+	// The clone URL has the VCS credentials in it so we never write it to disk.
+	l.Result.Repo.CloneURL = ""
+	return d.file.Append(l)
+}
+
+// List returns all the dead letters in the log, oldest first.
+func (d *DeadLetterLog) List() ([]DeadLetter, error) {
+	var letters []DeadLetter
+	err := d.file.Each(func(line []byte) error {
+		l, err := d.parse(line)
+		if err != nil {
+			return err
+		}
+		letters = append(letters, l)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
+
+// Resolve removes the letters that were sent from the log and updates the
+// ones that failed again, ex. with their new error. Letters that were
+// appended after the letters were listed are kept.
+func (d *DeadLetterLog) Resolve(sent []DeadLetter, failed []DeadLetter) error {
+	resolved := make(map[string]*DeadLetter)
+	for _, l := range sent {
+		resolved[l.ID] = nil
+	}
+	for i := range failed {
+		resolved[failed[i].ID] = &failed[i]
+	}
+	return d.file.Replace(func(lines [][]byte) ([]interface{}, error) {
+		var kept []interface{}
+		for _, line := range lines {
+			l, err := d.parse(line)
+			if err != nil {
+				return nil, err
+			}
+			update, ok := resolved[l.ID]
+			if ok {
+				if update == nil {
+					continue
+				}
+				l = *update
+			}
+			l.Result.Repo.CloneURL = ""
+			kept = append(kept, l)
+		}
+		return kept, nil
+	})
+}
+
+// parse returns the letter on line.
+func (d *DeadLetterLog) parse(line []byte) (DeadLetter, error) {
+	var l DeadLetter
+	if err := json.Unmarshal(line, &l); err != nil {
+		return l, errors.Wrapf(err, "parsing %s", d.file.Path)
+	}
+	// Letters written before they had IDs are identified by when they
+	// failed and their webhook.
+	if l.ID == "" {
+		l.ID = fmt.Sprintf("%s/%d", l.Time.Format(time.RFC3339Nano), l.Webhook)
+	}
+	return l, nil
+}
@@ -0,0 +1,63 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/hootsuite/atlantis/server/logging"
+)
+
+// Filter limits which apply results a webhook is sent for on top of its
+// workspace regex and event.
+type Filter struct {
+	// ExcludeWorkspaceRegex, if set, stops the webhook from being sent for
+	// the environments that match it, even if they match the webhook's
+	// workspace regex.
+	ExcludeWorkspaceRegex *regexp.Regexp
+	// FailuresOnly is true if the webhook is only sent for applies that
+	// failed.
+	FailuresOnly bool
+}
+
+// Matches returns true if the webhook should be sent for applyResult.
+func (f Filter) Matches(applyResult ApplyResult) bool {
+	if f.ExcludeWorkspaceRegex != nil && f.ExcludeWorkspaceRegex.MatchString(applyResult.Workspace) {
+		return false
+	}
+	if f.FailuresOnly && applyResult.Success {
+		return false
+	}
+	return true
+}
+
+// FilteredSender sends webhooks with Sender if they match Filter.
+type FilteredSender struct {
+	Sender Sender
+	Filter Filter
+}
+
+// Send sends applyResult if it matches the filter.
+func (f *FilteredSender) Send(ctx context.Context, log *logging.SimpleLogger, applyResult ApplyResult) error {
+	if !f.Filter.Matches(applyResult) {
+		return nil
+	}
+	return f.Sender.Send(ctx, log, applyResult)
+}
+
+// newFilter returns the filter in c. It's empty if c doesn't filter.
+func newFilter(c Config) (Filter, error) {
+	var f Filter
+	if c.ExcludeWorkspaceRegex != "" {
+		r, err := regexp.Compile(c.ExcludeWorkspaceRegex)
+		if err != nil {
+			return Filter{}, err
+		}
+		f.ExcludeWorkspaceRegex = r
+	}
+	if c.FailuresOnly && c.Event == PreApplyEvent {
+		return Filter{}, fmt.Errorf("\"failures-only\" can't be used with \"event: %s\" since the apply hasn't run yet", PreApplyEvent)
+	}
+	f.FailuresOnly = c.FailuresOnly
+	return f, nil
+}
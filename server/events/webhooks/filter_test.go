@@ -0,0 +1,104 @@
+package webhooks_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events/webhooks"
+	"github.com/hootsuite/atlantis/server/events/webhooks/mocks"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+func TestFilter_Matches(t *testing.T) {
+	t.Log("results should only match if they aren't excluded")
+	cases := []struct {
+		Description string
+		Filter      webhooks.Filter
+		Workspace   string
+		Success     bool
+		Exp         bool
+	}{
+		{
+			"an empty filter",
+			webhooks.Filter{},
+			"production",
+			true,
+			true,
+		},
+		{
+			"an excluded environment",
+			webhooks.Filter{ExcludeWorkspaceRegex: regexp.MustCompile("^staging")},
+			"staging-eu",
+			false,
+			false,
+		},
+		{
+			"an environment that isn't excluded",
+			webhooks.Filter{ExcludeWorkspaceRegex: regexp.MustCompile("^staging")},
+			"production",
+			true,
+			true,
+		},
+		{
+			"a success when only failures are sent",
+			webhooks.Filter{FailuresOnly: true},
+			"production",
+			true,
+			false,
+		},
+		{
+			"a failure when only failures are sent",
+			webhooks.Filter{FailuresOnly: true},
+			"production",
+			false,
+			true,
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.Description)
+		Equals(t, c.Exp, c.Filter.Matches(webhooks.ApplyResult{Workspace: c.Workspace, Success: c.Success}))
+	}
+}
+
+func TestNewWebhooksManager_Filters(t *testing.T) {
+	t.Log("a webhook should only be sent for the environments it includes and doesn't exclude")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	When(client.TokenIsSet()).ThenReturn(true)
+	When(client.ChannelExists(validChannel)).ThenReturn(true, nil)
+	configs := validConfigs()
+	configs[0].WorkspaceRegex = "^prod"
+	configs[0].ExcludeWorkspaceRegex = "-sandbox$"
+	configs[0].FailuresOnly = true
+	sender, err := webhooks.NewMultiWebhookSender(configs, client, nil)
+	Ok(t, err)
+
+	for _, workspace := range []string{"production", "staging", "prod-sandbox"} {
+		Ok(t, sender.Send(context.Background(), logging.NewNoopLogger(), webhooks.ApplyResult{Workspace: workspace}))
+		Ok(t, sender.Send(context.Background(), logging.NewNoopLogger(), webhooks.ApplyResult{Workspace: workspace, Success: true}))
+	}
+	client.VerifyWasCalledOnce().PostMessage(context.Background(), validChannel, webhooks.ApplyResult{Workspace: "production"})
+	client.VerifyWasCalled(Never()).PostMessage(context.Background(), validChannel, webhooks.ApplyResult{Workspace: "production", Success: true})
+	client.VerifyWasCalled(Never()).PostMessage(context.Background(), validChannel, webhooks.ApplyResult{Workspace: "prod-sandbox"})
+}
+
+func TestNewWebhooksManager_InvalidFilters(t *testing.T) {
+	t.Log("an invalid exclude regex should be an error")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	configs := validConfigs()
+	configs[0].ExcludeWorkspaceRegex = "("
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
+	Assert(t, err != nil, "expected error")
+
+	t.Log("only sending failures before the apply should be an error")
+	configs = validConfigs()
+	configs[0].Event = webhooks.PreApplyEvent
+	configs[0].FailuresOnly = true
+	_, err = webhooks.NewMultiWebhookSender(configs, client, nil)
+	Assert(t, err != nil, "expected error")
+	Equals(t, "\"failures-only\" can't be used with \"event: pre-apply\" since the apply hasn't run yet", err.Error())
+}
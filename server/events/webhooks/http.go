@@ -0,0 +1,188 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/egress"
+	"github.com/hootsuite/atlantis/server/logging"
+	"github.com/pkg/errors"
+)
+
+// HTTPSignatureHeader is the header of HTTP webhooks with the HMAC-SHA256 of
+// their body, in the same format as approval requests, if the webhook has a
+// secret.
+const HTTPSignatureHeader = "X-Atlantis-Signature"
+
+// httpTimeout is how long we wait for a single attempt to post a webhook.
+const httpTimeout = 10 * time.Second
+
+// Defaults for how HTTP webhooks are retried.
+const (
+	DefaultHTTPAttempts     = 3
+	DefaultHTTPRetryBackoff = 500 * time.Millisecond
+)
+
+// HTTPWebhook posts an HTTPPayload as JSON to any URL, ex. so ticketing
+// systems or bots can react to applies.
+type HTTPWebhook struct {
+	// URL is where the payload is posted. It may contain a credential so it
+	// must not be logged.
+	URL            string
+	WorkspaceRegex *regexp.Regexp
+	// Secret, if set, is used to sign the payload. See HTTPSignatureHeader.
+	Secret []byte
+	Client *http.Client
+	// EmergencyOnly is true if the webhook should only be sent for emergency
+	// applies.
+	EmergencyOnly bool
+	// PreApply is true if the webhook is only sent before applies start and
+	// false if it's only sent once they finish.
+	PreApply bool
+	// EgressHosts, if set, must allow URL for the webhook to be sent.
+	EgressHosts *egress.Allowlist
+	// Attempts is how many times we try to post the payload. Failed attempts
+	// are retried after RetryBackoff, which doubles after each one.
+	Attempts     int
+	RetryBackoff time.Duration
+}
+
+// HTTPPayload is what HTTP webhooks post.
+type HTTPPayload struct {
+	// Event is "pre-apply" before an apply starts and "apply" for each
+	// project once it's been applied.
+	Event       string `json:"event"`
+	Environment string `json:"environment"`
+	Project     string `json:"project,omitempty"`
+	Repo        string `json:"repo"`
+	PullRequest int    `json:"pull_request"`
+	PullURL     string `json:"pull_request_url"`
+	HeadCommit  string `json:"head_commit"`
+	User        string `json:"user"`
+	Success     bool   `json:"success"`
+	Emergency   bool   `json:"emergency"`
+	Ticket      string `json:"ticket,omitempty"`
+	// Changes are the changes of the plans being applied.
+	Changes *PlanChanges `json:"changes,omitempty"`
+	// Output is terraform's apply output.
+	Output           string            `json:"output,omitempty"`
+	DurationSeconds  float64           `json:"duration_seconds"`
+	ExternalApproval *ExternalApproval `json:"external_approval,omitempty"`
+}
+
+// NewHTTP returns a webhook that posts to webhookURL and signs the payload
+// with secret unless it's empty.
+func NewHTTP(r *regexp.Regexp, webhookURL string, secret []byte) *HTTPWebhook {
+	return &HTTPWebhook{
+		URL:            webhookURL,
+		WorkspaceRegex: r,
+		Secret:         secret,
+		Client:         &http.Client{Timeout: httpTimeout},
+		Attempts:       DefaultHTTPAttempts,
+		RetryBackoff:   DefaultHTTPRetryBackoff,
+	}
+}
+
+// Send posts the result if the workspace matches the regex. Transport errors
+// and 429 and 5xx responses are retried until ctx is done.
+func (h *HTTPWebhook) Send(ctx context.Context, log *logging.SimpleLogger, applyResult ApplyResult) error {
+	if !h.WorkspaceRegex.MatchString(applyResult.Workspace) {
+		return nil
+	}
+	if h.EmergencyOnly && !applyResult.Emergency {
+		return nil
+	}
+	if h.PreApply != applyResult.PreApply {
+		return nil
+	}
+	if err := h.EgressHosts.Check(h.URL); err != nil {
+		return errors.Wrap(err, "refusing to post http webhook")
+	}
+	body, err := json.Marshal(NewHTTPPayload(applyResult))
+	if err != nil {
+		return errors.Wrap(err, "encoding http webhook")
+	}
+	backoff := h.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		retryable, err := h.post(ctx, body)
+		if err == nil || !retryable || attempt >= h.Attempts || ctx.Err() != nil {
+			return err
+		}
+		log.Warn("attempt %d to post http webhook failed, retrying in %s: %s", attempt, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return errors.Wrapf(err, "stopped retrying after %d attempt(s) since %s", attempt, ctx.Err())
+		}
+		backoff *= 2
+	}
+}
+
+// post posts body once. retryable is true if the error might go away if we
+// post it again.
+func (h *HTTPWebhook) post(ctx context.Context, body []byte) (retryable bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.New("posting http webhook: invalid url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(h.Secret) > 0 {
+		req.Header.Set(HTTPSignatureHeader, HTTPSignature(h.Secret, body))
+	}
+	resp, err := h.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		// We drop the URL from the error since it may have a credential in
+		// it.
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return true, errors.Wrap(err, "posting http webhook")
+	}
+	resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retryable, fmt.Errorf("posting http webhook: got status %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// NewHTTPPayload returns the payload that HTTP webhooks post for applyResult.
+func NewHTTPPayload(applyResult ApplyResult) HTTPPayload {
+	event := ApplyEvent
+	if applyResult.PreApply {
+		event = PreApplyEvent
+	}
+	return HTTPPayload{
+		Event:            event,
+		Environment:      applyResult.Workspace,
+		Project:          applyResult.Project,
+		Repo:             applyResult.Repo.FullName,
+		PullRequest:      applyResult.Pull.Num,
+		PullURL:          applyResult.Pull.URL,
+		HeadCommit:       applyResult.Pull.HeadCommit,
+		User:             applyResult.User.Username,
+		Success:          applyResult.Success,
+		Emergency:        applyResult.Emergency,
+		Ticket:           applyResult.Ticket,
+		Changes:          applyResult.Changes,
+		Output:           applyResult.Output,
+		DurationSeconds:  applyResult.Duration.Seconds(),
+		ExternalApproval: applyResult.ExternalApproval,
+	}
+}
+
+// HTTPSignature returns the value of HTTPSignatureHeader for body.
+func HTTPSignature(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body) // nolint: errcheck
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
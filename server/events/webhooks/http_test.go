@@ -0,0 +1,137 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/events/webhooks"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+var httpResult = webhooks.ApplyResult{
+	Workspace:        "production",
+	Repo:             models.Repo{FullName: "owner/repo"},
+	Pull:             models.PullRequest{Num: 1, URL: "https://github.com/owner/repo/pull/1", HeadCommit: "abc123"},
+	User:             models.User{Username: "alice"},
+	Success:          true,
+	Ticket:           "CHG-1",
+	Changes:          &webhooks.PlanChanges{Add: 1, Destroy: 2},
+	Project:          "envs/production",
+	Output:           "Apply complete!",
+	Duration:         1500 * time.Millisecond,
+	ExternalApproval: &webhooks.ExternalApproval{Decision: "approved", Approvals: 2, Quorum: 2, Total: 3},
+}
+
+func TestHTTP_Send(t *testing.T) {
+	t.Log("the result should be posted as JSON and signed with the secret")
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(webhooks.HTTPSignatureHeader)
+	}))
+	defer server.Close()
+	hook := webhooks.NewHTTP(regexp.MustCompile(".*"), server.URL, []byte("secret"))
+
+	Ok(t, hook.Send(context.Background(), logging.NewNoopLogger(), httpResult))
+	Equals(t, webhooks.HTTPSignature([]byte("secret"), body), signature)
+	var payload webhooks.HTTPPayload
+	Ok(t, json.Unmarshal(body, &payload))
+	Equals(t, webhooks.HTTPPayload{
+		Event:            "apply",
+		Environment:      "production",
+		Project:          "envs/production",
+		Repo:             "owner/repo",
+		PullRequest:      1,
+		PullURL:          "https://github.com/owner/repo/pull/1",
+		HeadCommit:       "abc123",
+		User:             "alice",
+		Success:          true,
+		Ticket:           "CHG-1",
+		Changes:          &webhooks.PlanChanges{Add: 1, Destroy: 2},
+		Output:           "Apply complete!",
+		DurationSeconds:  1.5,
+		ExternalApproval: &webhooks.ExternalApproval{Decision: "approved", Approvals: 2, Quorum: 2, Total: 3},
+	}, payload)
+
+	t.Log("without a secret the payload shouldn't be signed")
+	hook.Secret = nil
+	Ok(t, hook.Send(context.Background(), logging.NewNoopLogger(), httpResult))
+	Equals(t, "", signature)
+
+	t.Log("a pre-apply result shouldn't be posted by an apply webhook")
+	body = nil
+	pre := httpResult
+	pre.PreApply = true
+	Ok(t, hook.Send(context.Background(), logging.NewNoopLogger(), pre))
+	Assert(t, body == nil, "expected nothing to be posted")
+}
+
+func TestHTTP_SendRetries(t *testing.T) {
+	t.Log("server errors should be retried until they succeed")
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	hook := webhooks.NewHTTP(regexp.MustCompile(".*"), server.URL, nil)
+	hook.RetryBackoff = time.Millisecond
+
+	Ok(t, hook.Send(context.Background(), logging.NewNoopLogger(), httpResult))
+	Equals(t, 3, attempts)
+
+	t.Log("once the attempts are used up the last error should be returned")
+	attempts = 0
+	hook.Attempts = 2
+	err := hook.Send(context.Background(), logging.NewNoopLogger(), httpResult)
+	Assert(t, err != nil, "expected error")
+	Equals(t, "posting http webhook: got status 503", err.Error())
+	Equals(t, 2, attempts)
+}
+
+func TestHTTP_SendStopsRetryingWhenCancelled(t *testing.T) {
+	t.Log("once the send is cancelled, ex. because it timed out, it shouldn't be retried")
+	attempts := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	hook := webhooks.NewHTTP(regexp.MustCompile(".*"), server.URL, nil)
+	hook.RetryBackoff = time.Hour
+
+	err := hook.Send(ctx, logging.NewNoopLogger(), httpResult)
+	Assert(t, err != nil, "expected error")
+	Equals(t, 1, attempts)
+}
+
+func TestHTTP_SendClientError(t *testing.T) {
+	t.Log("client errors other than 429 shouldn't be retried")
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+	hook := webhooks.NewHTTP(regexp.MustCompile(".*"), server.URL, nil)
+	hook.RetryBackoff = time.Millisecond
+
+	err := hook.Send(context.Background(), logging.NewNoopLogger(), httpResult)
+	Assert(t, err != nil, "expected error")
+	Equals(t, "posting http webhook: got status 400", err.Error())
+	Equals(t, 1, attempts)
+}
@@ -4,6 +4,7 @@
 package mocks
 
 import (
+	context "context"
 	"reflect"
 
 	webhooks "github.com/hootsuite/atlantis/server/events/webhooks"
@@ -19,8 +20,8 @@ func NewMockSender() *MockSender {
 	return &MockSender{fail: pegomock.GlobalFailHandler}
 }
 
-func (mock *MockSender) Send(log *logging.SimpleLogger, applyResult webhooks.ApplyResult) error {
-	params := []pegomock.Param{log, applyResult}
+func (mock *MockSender) Send(ctx context.Context, log *logging.SimpleLogger, applyResult webhooks.ApplyResult) error {
+	params := []pegomock.Param{ctx, log, applyResult}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("Send", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 error
 	if len(result) != 0 {
@@ -49,8 +50,8 @@ type VerifierSender struct {
 	inOrderContext         *pegomock.InOrderContext
 }
 
-func (verifier *VerifierSender) Send(log *logging.SimpleLogger, applyResult webhooks.ApplyResult) *Sender_Send_OngoingVerification {
-	params := []pegomock.Param{log, applyResult}
+func (verifier *VerifierSender) Send(ctx context.Context, log *logging.SimpleLogger, applyResult webhooks.ApplyResult) *Sender_Send_OngoingVerification {
+	params := []pegomock.Param{ctx, log, applyResult}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Send", params)
 	return &Sender_Send_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -60,21 +61,25 @@ type Sender_Send_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *Sender_Send_OngoingVerification) GetCapturedArguments() (*logging.SimpleLogger, webhooks.ApplyResult) {
-	log, applyResult := c.GetAllCapturedArguments()
-	return log[len(log)-1], applyResult[len(applyResult)-1]
+func (c *Sender_Send_OngoingVerification) GetCapturedArguments() (context.Context, *logging.SimpleLogger, webhooks.ApplyResult) {
+	ctx, log, applyResult := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], log[len(log)-1], applyResult[len(applyResult)-1]
 }
 
-func (c *Sender_Send_OngoingVerification) GetAllCapturedArguments() (_param0 []*logging.SimpleLogger, _param1 []webhooks.ApplyResult) {
+func (c *Sender_Send_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []*logging.SimpleLogger, _param2 []webhooks.ApplyResult) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]*logging.SimpleLogger, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(*logging.SimpleLogger)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]webhooks.ApplyResult, len(params[1]))
+		_param1 = make([]*logging.SimpleLogger, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(webhooks.ApplyResult)
+			_param1[u] = param.(*logging.SimpleLogger)
+		}
+		_param2 = make([]webhooks.ApplyResult, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(webhooks.ApplyResult)
 		}
 	}
 	return
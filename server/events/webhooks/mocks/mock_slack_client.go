@@ -4,6 +4,7 @@
 package mocks
 
 import (
+	context "context"
 	"reflect"
 
 	webhooks "github.com/hootsuite/atlantis/server/events/webhooks"
@@ -58,8 +59,8 @@ func (mock *MockSlackClient) ChannelExists(channelName string) (bool, error) {
 	return ret0, ret1
 }
 
-func (mock *MockSlackClient) PostMessage(channel string, applyResult webhooks.ApplyResult) error {
-	params := []pegomock.Param{channel, applyResult}
+func (mock *MockSlackClient) PostMessage(ctx context.Context, channel string, applyResult webhooks.ApplyResult) error {
+	params := []pegomock.Param{ctx, channel, applyResult}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("PostMessage", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 error
 	if len(result) != 0 {
@@ -149,8 +150,8 @@ func (c *SlackClient_ChannelExists_OngoingVerification) GetAllCapturedArguments(
 	return
 }
 
-func (verifier *VerifierSlackClient) PostMessage(channel string, applyResult webhooks.ApplyResult) *SlackClient_PostMessage_OngoingVerification {
-	params := []pegomock.Param{channel, applyResult}
+func (verifier *VerifierSlackClient) PostMessage(ctx context.Context, channel string, applyResult webhooks.ApplyResult) *SlackClient_PostMessage_OngoingVerification {
+	params := []pegomock.Param{ctx, channel, applyResult}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "PostMessage", params)
 	return &SlackClient_PostMessage_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -160,21 +161,25 @@ type SlackClient_PostMessage_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *SlackClient_PostMessage_OngoingVerification) GetCapturedArguments() (string, webhooks.ApplyResult) {
-	channel, applyResult := c.GetAllCapturedArguments()
-	return channel[len(channel)-1], applyResult[len(applyResult)-1]
+func (c *SlackClient_PostMessage_OngoingVerification) GetCapturedArguments() (context.Context, string, webhooks.ApplyResult) {
+	ctx, channel, applyResult := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], channel[len(channel)-1], applyResult[len(applyResult)-1]
 }
 
-func (c *SlackClient_PostMessage_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []webhooks.ApplyResult) {
+func (c *SlackClient_PostMessage_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []string, _param2 []webhooks.ApplyResult) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]string, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(string)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]webhooks.ApplyResult, len(params[1]))
+		_param1 = make([]string, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(webhooks.ApplyResult)
+			_param1[u] = param.(string)
+		}
+		_param2 = make([]webhooks.ApplyResult, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(webhooks.ApplyResult)
 		}
 	}
 	return
@@ -50,26 +50,6 @@ func (mock *MockUnderlyingSlackClient) GetChannels(excludeArchived bool) ([]slac
 	return ret0, ret1
 }
 
-func (mock *MockUnderlyingSlackClient) PostMessage(channel string, text string, parameters slack.PostMessageParameters) (string, string, error) {
-	params := []pegomock.Param{channel, text, parameters}
-	result := pegomock.GetGenericMockFrom(mock).Invoke("PostMessage", params, []reflect.Type{reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
-	var ret0 string
-	var ret1 string
-	var ret2 error
-	if len(result) != 0 {
-		if result[0] != nil {
-			ret0 = result[0].(string)
-		}
-		if result[1] != nil {
-			ret1 = result[1].(string)
-		}
-		if result[2] != nil {
-			ret2 = result[2].(error)
-		}
-	}
-	return ret0, ret1, ret2
-}
-
 func (mock *MockUnderlyingSlackClient) VerifyWasCalledOnce() *VerifierUnderlyingSlackClient {
 	return &VerifierUnderlyingSlackClient{mock, pegomock.Times(1), nil}
 }
@@ -131,38 +111,3 @@ func (c *UnderlyingSlackClient_GetChannels_OngoingVerification) GetAllCapturedAr
 	}
 	return
 }
-
-func (verifier *VerifierUnderlyingSlackClient) PostMessage(channel string, text string, parameters slack.PostMessageParameters) *UnderlyingSlackClient_PostMessage_OngoingVerification {
-	params := []pegomock.Param{channel, text, parameters}
-	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "PostMessage", params)
-	return &UnderlyingSlackClient_PostMessage_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
-}
-
-type UnderlyingSlackClient_PostMessage_OngoingVerification struct {
-	mock              *MockUnderlyingSlackClient
-	methodInvocations []pegomock.MethodInvocation
-}
-
-func (c *UnderlyingSlackClient_PostMessage_OngoingVerification) GetCapturedArguments() (string, string, slack.PostMessageParameters) {
-	channel, text, parameters := c.GetAllCapturedArguments()
-	return channel[len(channel)-1], text[len(text)-1], parameters[len(parameters)-1]
-}
-
-func (c *UnderlyingSlackClient_PostMessage_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []string, _param2 []slack.PostMessageParameters) {
-	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
-	if len(params) > 0 {
-		_param0 = make([]string, len(params[0]))
-		for u, param := range params[0] {
-			_param0[u] = param.(string)
-		}
-		_param1 = make([]string, len(params[1]))
-		for u, param := range params[1] {
-			_param1[u] = param.(string)
-		}
-		_param2 = make([]slack.PostMessageParameters, len(params[2]))
-		for u, param := range params[2] {
-			_param2[u] = param.(slack.PostMessageParameters)
-		}
-	}
-	return
-}
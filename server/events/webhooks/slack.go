@@ -1,10 +1,12 @@
 package webhooks
 
 import (
+	"context"
 	"regexp"
 
 	"fmt"
 
+	"github.com/hootsuite/atlantis/server/egress"
 	"github.com/hootsuite/atlantis/server/logging"
 	"github.com/pkg/errors"
 )
@@ -14,6 +16,14 @@ type SlackWebhook struct {
 	Client         SlackClient
 	WorkspaceRegex *regexp.Regexp
 	Channel        string
+	// EmergencyOnly is true if the webhook should only be sent for emergency
+	// applies.
+	EmergencyOnly bool
+	// PreApply is true if the webhook is only sent before applies start and
+	// false if it's only sent once they finish.
+	PreApply bool
+	// EgressHosts, if set, must allow SlackAPIURL for the webhook to be sent.
+	EgressHosts *egress.Allowlist
 }
 
 func NewSlack(r *regexp.Regexp, channel string, client SlackClient) (*SlackWebhook, error) {
@@ -37,9 +47,18 @@ func NewSlack(r *regexp.Regexp, channel string, client SlackClient) (*SlackWebho
 }
 
 // Send sends the webhook to Slack if the workspace matches the regex.
-func (s *SlackWebhook) Send(log *logging.SimpleLogger, applyResult ApplyResult) error {
+func (s *SlackWebhook) Send(ctx context.Context, log *logging.SimpleLogger, applyResult ApplyResult) error {
 	if !s.WorkspaceRegex.MatchString(applyResult.Workspace) {
 		return nil
 	}
-	return s.Client.PostMessage(s.Channel, applyResult)
+	if s.EmergencyOnly && !applyResult.Emergency {
+		return nil
+	}
+	if s.PreApply != applyResult.PreApply {
+		return nil
+	}
+	if err := s.EgressHosts.Check(SlackAPIURL); err != nil {
+		return errors.Wrap(err, "refusing to send to slack")
+	}
+	return s.Client.PostMessage(ctx, s.Channel, applyResult)
 }
@@ -1,14 +1,39 @@
 package webhooks
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/nlopes/slack"
+	"github.com/pkg/errors"
 )
 
 const (
 	slackSuccessColour = "good"
 	slackFailureColour = "danger"
+	slackPendingColour = "warning"
+)
+
+// SlackAPIURL is where the Slack API is, which egress allowlists have to
+// allow for Slack webhooks.
+var SlackAPIURL = slack.SLACK_API
+
+// slackTimeout is how long we wait for Slack to accept a message.
+const slackTimeout = 10 * time.Second
+
+// slackRateLimitAttempts is how many times we try to post a message while
+// Slack is rate limiting us and slackMaxRetryAfter is the longest we wait
+// between attempts. Webhooks are sent while the apply's comment waits for
+// them so we'd rather drop a message than hold it up for longer.
+const (
+	slackRateLimitAttempts = 3
+	slackMaxRetryAfter     = 30 * time.Second
 )
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_slack_client.go SlackClient
@@ -18,7 +43,7 @@ type SlackClient interface {
 	AuthTest() error
 	TokenIsSet() bool
 	ChannelExists(channelName string) (bool, error)
-	PostMessage(channel string, applyResult ApplyResult) error
+	PostMessage(ctx context.Context, channel string, applyResult ApplyResult) error
 }
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_underlying_slack_client.go UnderlyingSlackClient
@@ -28,18 +53,26 @@ type SlackClient interface {
 type UnderlyingSlackClient interface {
 	AuthTest() (response *slack.AuthTestResponse, error error)
 	GetChannels(excludeArchived bool) ([]slack.Channel, error)
-	PostMessage(channel, text string, parameters slack.PostMessageParameters) (string, string, error)
 }
 
+// DefaultSlackClient uses Slack for the API calls it doesn't make itself.
+// Messages are posted directly to APIURL since nlopes/slack doesn't support
+// Block Kit.
 type DefaultSlackClient struct {
 	Slack UnderlyingSlackClient
 	Token string
+	// APIURL is the base URL of the Slack API, with a trailing slash, and
+	// HTTPClient is used to post messages to it.
+	APIURL     string
+	HTTPClient *http.Client
 }
 
 func NewSlackClient(token string) SlackClient {
 	return &DefaultSlackClient{
-		Slack: slack.New(token),
-		Token: token,
+		Slack:      slack.New(token),
+		Token:      token,
+		APIURL:     SlackAPIURL,
+		HTTPClient: &http.Client{Timeout: slackTimeout},
 	}
 }
 
@@ -65,19 +98,110 @@ func (d *DefaultSlackClient) ChannelExists(channelName string) (bool, error) {
 	return false, nil
 }
 
-func (d *DefaultSlackClient) PostMessage(channel string, applyResult ApplyResult) error {
-	params := slack.NewPostMessageParameters()
-	params.Attachments = d.createAttachments(applyResult)
-	params.AsUser = true
-	params.EscapeText = false
-	_, _, err := d.Slack.PostMessage(channel, "", params)
-	return err
+// PostMessage posts applyResult to channel as a Block Kit message in an
+// attachment coloured by its outcome. If Slack is rate limiting us, we wait
+// for as long as it asks, up to slackMaxRetryAfter, and try again unless ctx
+// is done.
+func (d *DefaultSlackClient) PostMessage(ctx context.Context, channel string, applyResult ApplyResult) error {
+	body, err := json.Marshal(d.createMessage(channel, applyResult))
+	if err != nil {
+		return errors.Wrap(err, "encoding slack message")
+	}
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := d.postMessage(ctx, body)
+		if err == nil || retryAfter < 0 || attempt >= slackRateLimitAttempts {
+			return err
+		}
+		select {
+		case <-time.After(retryAfter):
+		case <-ctx.Done():
+			return errors.Wrapf(err, "stopped retrying after %d attempt(s) since %s", attempt, ctx.Err())
+		}
+	}
+}
+
+// postMessage posts body to chat.postMessage once. If Slack is rate limiting
+// us, retryAfter is how long it asked us to wait, otherwise it's negative.
+func (d *DefaultSlackClient) postMessage(ctx context.Context, body []byte) (retryAfter time.Duration, err error) {
+	req, err := http.NewRequest(http.MethodPost, d.APIURL+"chat.postMessage", bytes.NewReader(body))
+	if err != nil {
+		return -1, errors.Wrap(err, "posting to slack")
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+d.Token)
+	resp, err := d.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return -1, errors.Wrap(err, "posting to slack")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter = time.Second
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			retryAfter = time.Duration(secs) * time.Second
+		}
+		if retryAfter > slackMaxRetryAfter {
+			return -1, fmt.Errorf("posting to slack: rate limited for %s", retryAfter)
+		}
+		return retryAfter, errors.New("posting to slack: rate limited")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return -1, fmt.Errorf("posting to slack: got status %d", resp.StatusCode)
+	}
+	var slackResp struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&slackResp); err != nil {
+		return -1, errors.Wrap(err, "posting to slack: decoding response")
+	}
+	if !slackResp.OK {
+		return -1, fmt.Errorf("posting to slack: %s", slackResp.Error)
+	}
+	return -1, nil
+}
+
+// SlackMessage is a chat.postMessage request. Text is only shown in
+// notifications since the message is in the attachment's blocks.
+type SlackMessage struct {
+	Channel     string            `json:"channel"`
+	Text        string            `json:"text"`
+	Attachments []SlackAttachment `json:"attachments"`
+}
+
+// SlackAttachment is a coloured bar with Block Kit blocks in it.
+type SlackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []SlackBlock `json:"blocks"`
+}
+
+// SlackBlock is a Block Kit section or actions block.
+type SlackBlock struct {
+	Type     string        `json:"type"`
+	Text     *SlackText    `json:"text,omitempty"`
+	Fields   []SlackText   `json:"fields,omitempty"`
+	Elements []SlackButton `json:"elements,omitempty"`
+}
+
+// SlackText is a Block Kit text object.
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// SlackButton is a Block Kit button that links to URL.
+type SlackButton struct {
+	Type string    `json:"type"`
+	Text SlackText `json:"text"`
+	URL  string    `json:"url"`
 }
 
-func (d *DefaultSlackClient) createAttachments(applyResult ApplyResult) []slack.Attachment {
+func (d *DefaultSlackClient) createMessage(channel string, applyResult ApplyResult) SlackMessage {
 	var colour string
 	var successWord string
-	if applyResult.Success {
+	if applyResult.PreApply {
+		colour = slackPendingColour
+		successWord = "starting"
+	} else if applyResult.Success {
 		colour = slackSuccessColour
 		successWord = "succeeded"
 	} else {
@@ -85,22 +209,56 @@ func (d *DefaultSlackClient) createAttachments(applyResult ApplyResult) []slack.
 		successWord = "failed"
 	}
 
-	text := fmt.Sprintf("Apply %s for <%s|%s>", successWord, applyResult.Pull.URL, applyResult.Repo.FullName)
-	attachment := slack.Attachment{
-		Color: colour,
-		Text:  text,
-		Fields: []slack.AttachmentField{
-			{
-				Title: "Workspace",
-				Value: applyResult.Workspace,
-				Short: true,
-			},
-			{
-				Title: "User",
-				Value: applyResult.User.Username,
-				Short: true,
+	kind := "Apply"
+	if applyResult.Emergency {
+		kind = "Emergency apply"
+	}
+	repo := slackEscape(applyResult.Repo.FullName)
+	fields := []SlackText{
+		slackField("Workspace", applyResult.Workspace),
+		slackField("User", applyResult.User.Username),
+	}
+	if applyResult.Project != "" {
+		fields = append(fields, slackField("Project", applyResult.Project))
+	}
+	if applyResult.Ticket != "" {
+		fields = append(fields, slackField("Ticket", applyResult.Ticket))
+	}
+	if applyResult.Changes != nil {
+		fields = append(fields, slackField("Changes", applyResult.Changes.String()))
+	}
+	return SlackMessage{
+		Channel: channel,
+		Text:    fmt.Sprintf("%s %s for %s", kind, successWord, repo),
+		Attachments: []SlackAttachment{{
+			Color: colour,
+			Blocks: []SlackBlock{
+				{
+					Type: "section",
+					Text: &SlackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s %s* for <%s|%s>", kind, successWord, applyResult.Pull.URL, repo)},
+				},
+				{
+					Type:   "section",
+					Fields: fields,
+				},
+				{
+					Type: "actions",
+					Elements: []SlackButton{{
+						Type: "button",
+						Text: SlackText{Type: "plain_text", Text: "View pull request"},
+						URL:  applyResult.Pull.URL,
+					}},
+				},
 			},
-		},
+		}},
 	}
-	return []slack.Attachment{attachment}
+}
+
+func slackField(title string, value string) SlackText {
+	return SlackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", title, slackEscape(value))}
+}
+
+// slackEscape escapes the characters that Slack treats as markup.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
 }
@@ -1,8 +1,12 @@
 package webhooks_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/hootsuite/atlantis/server/events/models"
@@ -80,79 +84,149 @@ func TestChannelExists_Error(t *testing.T) {
 func TestPostMessage_Success(t *testing.T) {
 	t.Log("When apply succeds, function should succeed and indicate success")
 	setup(t)
-
-	expParams := slack.NewPostMessageParameters()
-	expParams.Attachments = []slack.Attachment{{
-		Color: "good",
-		Text:  "Apply succeeded for <url|hootsuite/atlantis>",
-		Fields: []slack.AttachmentField{
-			{
-				Title: "Workspace",
-				Value: result.Workspace,
-				Short: true,
-			},
-			{
-				Title: "User",
-				Value: result.User.Username,
-				Short: true,
-			},
-		},
-	}}
-	expParams.AsUser = true
-	expParams.EscapeText = false
+	var req *http.Request
+	var msg webhooks.SlackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ := ioutil.ReadAll(r.Body)
+		msg = webhooks.SlackMessage{}
+		json.Unmarshal(body, &msg)      // nolint: errcheck
+		w.Write([]byte(`{"ok": true}`)) // nolint: errcheck
+	}))
+	defer server.Close()
+	client.APIURL = server.URL + "/"
 
 	channel := "somechannel"
-	err := client.PostMessage(channel, result)
+	err := client.PostMessage(context.Background(), channel, result)
 	Ok(t, err)
-	underlying.VerifyWasCalledOnce().PostMessage(channel, "", expParams)
+	Equals(t, "/chat.postMessage", req.URL.Path)
+	Equals(t, "Bearer sometoken", req.Header.Get("Authorization"))
+	expMsg := webhooks.SlackMessage{
+		Channel: channel,
+		Text:    "Apply succeeded for hootsuite/atlantis",
+		Attachments: []webhooks.SlackAttachment{{
+			Color: "good",
+			Blocks: []webhooks.SlackBlock{
+				{
+					Type: "section",
+					Text: &webhooks.SlackText{Type: "mrkdwn", Text: "*Apply succeeded* for <url|hootsuite/atlantis>"},
+				},
+				{
+					Type: "section",
+					Fields: []webhooks.SlackText{
+						{Type: "mrkdwn", Text: "*Workspace*\nproduction"},
+						{Type: "mrkdwn", Text: "*User*\nlkysow"},
+					},
+				},
+				{
+					Type: "actions",
+					Elements: []webhooks.SlackButton{{
+						Type: "button",
+						Text: webhooks.SlackText{Type: "plain_text", Text: "View pull request"},
+						URL:  "url",
+					}},
+				},
+			},
+		}},
+	}
+	Equals(t, expMsg, msg)
 
 	t.Log("When apply fails, function should succeed and indicate failure")
 	result.Success = false
-	expParams.Attachments[0].Color = "danger"
-	expParams.Attachments[0].Text = "Apply failed for <url|hootsuite/atlantis>"
+	expMsg.Text = "Apply failed for hootsuite/atlantis"
+	expMsg.Attachments[0].Color = "danger"
+	expMsg.Attachments[0].Blocks[0].Text.Text = "*Apply failed* for <url|hootsuite/atlantis>"
 
-	err = client.PostMessage(channel, result)
+	err = client.PostMessage(context.Background(), channel, result)
+	Ok(t, err)
+	Equals(t, expMsg, msg)
+
+	t.Log("When apply is starting, function should succeed and include the changes")
+	result.PreApply = true
+	result.Changes = &webhooks.PlanChanges{Add: 1, Change: 2, Destroy: 3}
+	expMsg.Text = "Apply starting for hootsuite/atlantis"
+	expMsg.Attachments[0].Color = "warning"
+	expMsg.Attachments[0].Blocks[0].Text.Text = "*Apply starting* for <url|hootsuite/atlantis>"
+	expMsg.Attachments[0].Blocks[1].Fields = append(expMsg.Attachments[0].Blocks[1].Fields, webhooks.SlackText{
+		Type: "mrkdwn",
+		Text: "*Changes*\n1 to add, 2 to change, 3 to destroy",
+	})
+
+	err = client.PostMessage(context.Background(), channel, result)
 	Ok(t, err)
-	underlying.VerifyWasCalledOnce().PostMessage(channel, "", expParams)
+	Equals(t, expMsg, msg)
 }
 
-func TestPostMessage_Error(t *testing.T) {
-	t.Log("When the underylying slack client errors, an error should be returned")
+func TestPostMessage_Escapes(t *testing.T) {
+	t.Log("Slack markup in the result should be escaped")
 	setup(t)
+	var msg webhooks.SlackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &msg)      // nolint: errcheck
+		w.Write([]byte(`{"ok": true}`)) // nolint: errcheck
+	}))
+	defer server.Close()
+	client.APIURL = server.URL + "/"
+	result.Ticket = "<!channel> & co"
+
+	Ok(t, client.PostMessage(context.Background(), "somechannel", result))
+	Equals(t, "*Ticket*\n&lt;!channel&gt; &amp; co", msg.Attachments[0].Blocks[1].Fields[2].Text)
+}
 
-	expParams := slack.NewPostMessageParameters()
-	expParams.Attachments = []slack.Attachment{{
-		Color: "good",
-		Text:  "Apply succeeded for <url|hootsuite/atlantis>",
-		Fields: []slack.AttachmentField{
-			{
-				Title: "Workspace",
-				Value: result.Workspace,
-				Short: true,
-			},
-			{
-				Title: "User",
-				Value: result.User.Username,
-				Short: true,
-			},
-		},
-	}}
-	expParams.AsUser = true
-	expParams.EscapeText = false
+func TestPostMessage_Error(t *testing.T) {
+	t.Log("When slack responds with an error, an error should be returned")
+	setup(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok": false, "error": "channel_not_found"}`)) // nolint: errcheck
+	}))
+	defer server.Close()
+	client.APIURL = server.URL + "/"
 
-	channel := "somechannel"
-	When(underlying.PostMessage(channel, "", expParams)).ThenReturn("", "", errors.New(""))
+	err := client.PostMessage(context.Background(), "somechannel", result)
+	Assert(t, err != nil, "expected error")
+	Equals(t, "posting to slack: channel_not_found", err.Error())
+}
 
-	err := client.PostMessage(channel, result)
+func TestPostMessage_RateLimited(t *testing.T) {
+	t.Log("When slack is rate limiting us, the message should be posted again after Retry-After")
+	setup(t)
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"ok": true}`)) // nolint: errcheck
+	}))
+	defer server.Close()
+	client.APIURL = server.URL + "/"
+
+	Ok(t, client.PostMessage(context.Background(), "somechannel", result))
+	Equals(t, 2, attempts)
+
+	t.Log("When slack asks us to wait too long, an error should be returned without waiting")
+	attempts = 0
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	err := client.PostMessage(context.Background(), "somechannel", result)
 	Assert(t, err != nil, "expected error")
+	Equals(t, "posting to slack: rate limited for 1h0m0s", err.Error())
+	Equals(t, 1, attempts)
 }
 
 func setup(t *testing.T) {
 	RegisterMockTestingT(t)
 	underlying = mocks.NewMockUnderlyingSlackClient()
 	client = webhooks.DefaultSlackClient{
-		Slack: underlying,
-		Token: "sometoken",
+		Slack:      underlying,
+		Token:      "sometoken",
+		HTTPClient: http.DefaultClient,
 	}
 	result = webhooks.ApplyResult{
 		Workspace: "production",
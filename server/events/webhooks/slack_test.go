@@ -1,6 +1,7 @@
 package webhooks_test
 
 import (
+	"context"
 	"regexp"
 	"testing"
 
@@ -29,8 +30,8 @@ func TestSend_PostMessage(t *testing.T) {
 	}
 
 	t.Log("PostMessage should be called, doesn't matter if it errors or not")
-	_ = hook.Send(logging.NewNoopLogger(), result)
-	client.VerifyWasCalledOnce().PostMessage(channel, result)
+	_ = hook.Send(context.Background(), logging.NewNoopLogger(), result)
+	client.VerifyWasCalledOnce().PostMessage(context.Background(), channel, result)
 }
 
 func TestSend_NoopSuccess(t *testing.T) {
@@ -49,7 +50,50 @@ func TestSend_NoopSuccess(t *testing.T) {
 	result := webhooks.ApplyResult{
 		Workspace: "production",
 	}
-	err = hook.Send(logging.NewNoopLogger(), result)
+	err = hook.Send(context.Background(), logging.NewNoopLogger(), result)
 	Ok(t, err)
-	client.VerifyWasCalled(Never()).PostMessage(channel, result)
+	client.VerifyWasCalled(Never()).PostMessage(context.Background(), channel, result)
+}
+
+func TestSend_EmergencyOnly(t *testing.T) {
+	t.Log("An emergency only hook should only be sent for emergency applies")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	regex, err := regexp.Compile(".*")
+	Ok(t, err)
+
+	channel := "security"
+	hook := webhooks.SlackWebhook{
+		Client:         client,
+		WorkspaceRegex: regex,
+		Channel:        channel,
+		EmergencyOnly:  true,
+	}
+	normal := webhooks.ApplyResult{Workspace: "production"}
+	emergency := webhooks.ApplyResult{Workspace: "production", Emergency: true, Ticket: "CHG-1"}
+	Ok(t, hook.Send(context.Background(), logging.NewNoopLogger(), normal))
+	_ = hook.Send(context.Background(), logging.NewNoopLogger(), emergency)
+	client.VerifyWasCalled(Never()).PostMessage(context.Background(), channel, normal)
+	client.VerifyWasCalledOnce().PostMessage(context.Background(), channel, emergency)
+}
+
+func TestSend_PreApply(t *testing.T) {
+	t.Log("A pre-apply hook should only be sent before applies and other hooks only after")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	regex, err := regexp.Compile(".*")
+	Ok(t, err)
+
+	preHook := webhooks.SlackWebhook{Client: client, WorkspaceRegex: regex, Channel: "oncall", PreApply: true}
+	postHook := webhooks.SlackWebhook{Client: client, WorkspaceRegex: regex, Channel: "applies"}
+	pre := webhooks.ApplyResult{Workspace: "production", PreApply: true, Changes: &webhooks.PlanChanges{Add: 1}}
+	post := webhooks.ApplyResult{Workspace: "production", Success: true}
+	for _, hook := range []webhooks.SlackWebhook{preHook, postHook} {
+		_ = hook.Send(context.Background(), logging.NewNoopLogger(), pre)
+		_ = hook.Send(context.Background(), logging.NewNoopLogger(), post)
+	}
+	client.VerifyWasCalledOnce().PostMessage(context.Background(), "oncall", pre)
+	client.VerifyWasCalled(Never()).PostMessage(context.Background(), "oncall", post)
+	client.VerifyWasCalledOnce().PostMessage(context.Background(), "applies", post)
+	client.VerifyWasCalled(Never()).PostMessage(context.Background(), "applies", pre)
 }
@@ -0,0 +1,172 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"text/template"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/egress"
+	"github.com/hootsuite/atlantis/server/logging"
+	"github.com/pkg/errors"
+)
+
+const (
+	teamsSuccessColour = "2EB886"
+	teamsFailureColour = "A30200"
+	teamsPendingColour = "F2C744"
+)
+
+// teamsTimeout is how long we wait for Teams to accept a card.
+const teamsTimeout = 10 * time.Second
+
+// DefaultTeamsTemplate renders the result as a Teams MessageCard.
+const DefaultTeamsTemplate = `{
+  "@type": "MessageCard",
+  "@context": "https://schema.org/extensions",
+  "themeColor": {{ json .Colour }},
+  "summary": {{ json (printf "%s %s for %s" .Kind .Outcome .Repo.FullName) }},
+  "title": {{ json (printf "%s %s for %s" .Kind .Outcome .Repo.FullName) }},
+  "sections": [{
+    "facts": [
+      {"name": "Workspace", "value": {{ json .Workspace }}},
+      {"name": "User", "value": {{ json .User.Username }}}{{ if .Ticket }},
+      {"name": "Ticket", "value": {{ json .Ticket }}}{{ end }}{{ if .Changes }},
+      {"name": "Changes", "value": {{ json .Changes.String }}}{{ end }}
+    ]
+  }],
+  "potentialAction": [{
+    "@type": "OpenUri",
+    "name": "View pull request",
+    "targets": [{"os": "default", "uri": {{ json .Pull.URL }}}]
+  }]
+}`
+
+// teamsTemplateFuncs are the funcs that Teams templates can use in addition
+// to the text/template builtins.
+var teamsTemplateFuncs = template.FuncMap{
+	// json encodes a value so it can be put in the card as is, ex. a
+	// repo name with quotes in it.
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// TeamsWebhook sends webhooks to a Microsoft Teams incoming webhook.
+type TeamsWebhook struct {
+	// URL is the incoming webhook's URL. It contains the credential for
+	// posting so it must not be logged.
+	URL            string
+	WorkspaceRegex *regexp.Regexp
+	// Template renders the card that's posted to URL from a TeamsCardData.
+	Template *template.Template
+	Client   *http.Client
+	// EmergencyOnly is true if the webhook should only be sent for emergency
+	// applies.
+	EmergencyOnly bool
+	// PreApply is true if the webhook is only sent before applies start and
+	// false if it's only sent once they finish.
+	PreApply bool
+	// EgressHosts, if set, must allow URL for the webhook to be sent.
+	EgressHosts *egress.Allowlist
+}
+
+// TeamsCardData is what Teams templates are rendered with.
+type TeamsCardData struct {
+	ApplyResult
+	// Kind is "Apply" or "Emergency apply".
+	Kind string
+	// Outcome is "succeeded", "failed" or, before the apply, "starting".
+	Outcome string
+	// Colour is the hex colour for the outcome, without a leading #.
+	Colour string
+}
+
+// NewTeams returns a webhook that posts to webhookURL. If tmpl is empty,
+// DefaultTeamsTemplate is used.
+func NewTeams(r *regexp.Regexp, webhookURL string, tmpl string) (*TeamsWebhook, error) {
+	if tmpl == "" {
+		tmpl = DefaultTeamsTemplate
+	}
+	t, err := template.New("teams").Funcs(teamsTemplateFuncs).Parse(tmpl)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing teams template")
+	}
+	return &TeamsWebhook{
+		URL:            webhookURL,
+		WorkspaceRegex: r,
+		Template:       t,
+		Client:         &http.Client{Timeout: teamsTimeout},
+	}, nil
+}
+
+// Send posts the result to Teams if the workspace matches the regex.
+func (t *TeamsWebhook) Send(ctx context.Context, log *logging.SimpleLogger, applyResult ApplyResult) error {
+	if !t.WorkspaceRegex.MatchString(applyResult.Workspace) {
+		return nil
+	}
+	if t.EmergencyOnly && !applyResult.Emergency {
+		return nil
+	}
+	if t.PreApply != applyResult.PreApply {
+		return nil
+	}
+	if err := t.EgressHosts.Check(t.URL); err != nil {
+		return errors.Wrap(err, "refusing to post to teams")
+	}
+	card, err := t.card(applyResult)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.URL, bytes.NewBuffer(card))
+	if err != nil {
+		return errors.New("posting to teams: invalid url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		// We drop the URL from the error since it has the credential in it.
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return errors.Wrap(err, "posting to teams")
+	}
+	resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("posting to teams: got status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (t *TeamsWebhook) card(applyResult ApplyResult) ([]byte, error) {
+	data := TeamsCardData{
+		ApplyResult: applyResult,
+		Kind:        "Apply",
+		Outcome:     "failed",
+		Colour:      teamsFailureColour,
+	}
+	if applyResult.Emergency {
+		data.Kind = "Emergency apply"
+	}
+	if applyResult.PreApply {
+		data.Outcome = "starting"
+		data.Colour = teamsPendingColour
+	} else if applyResult.Success {
+		data.Outcome = "succeeded"
+		data.Colour = teamsSuccessColour
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Template.Execute(buf, data); err != nil {
+		return nil, errors.Wrap(err, "rendering teams template")
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("rendering teams template: result is not valid JSON")
+	}
+	return buf.Bytes(), nil
+}
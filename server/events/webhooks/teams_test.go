@@ -0,0 +1,170 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/egress"
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/events/webhooks"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+var teamsResult = webhooks.ApplyResult{
+	Workspace: "production",
+	Repo:      models.Repo{FullName: "owner/repo"},
+	Pull:      models.PullRequest{URL: "https://github.com/owner/repo/pull/1"},
+	User:      models.User{Username: "alice"},
+	Success:   true,
+	Ticket:    "CHG-1",
+}
+
+func TestTeams_Send(t *testing.T) {
+	t.Log("Sending a hook with a matching regex should post a MessageCard")
+	var card map[string]interface{}
+	teams := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &card) // nolint: errcheck
+	}))
+	defer teams.Close()
+	hook, err := webhooks.NewTeams(regexp.MustCompile(".*"), teams.URL, "")
+	Ok(t, err)
+
+	err = hook.Send(context.Background(), logging.NewNoopLogger(), teamsResult)
+	Ok(t, err)
+	Equals(t, "MessageCard", card["@type"])
+	Equals(t, "2EB886", card["themeColor"])
+	Equals(t, "Apply succeeded for owner/repo", card["title"])
+	facts := card["sections"].([]interface{})[0].(map[string]interface{})["facts"]
+	Equals(t, []interface{}{
+		map[string]interface{}{"name": "Workspace", "value": "production"},
+		map[string]interface{}{"name": "User", "value": "alice"},
+		map[string]interface{}{"name": "Ticket", "value": "CHG-1"},
+	}, facts)
+	target := card["potentialAction"].([]interface{})[0].(map[string]interface{})["targets"].([]interface{})[0]
+	Equals(t, "https://github.com/owner/repo/pull/1", target.(map[string]interface{})["uri"])
+}
+
+func TestTeams_SendPreApply(t *testing.T) {
+	t.Log("A pre-apply hook should post a card with the planned changes")
+	var card map[string]interface{}
+	teams := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &card) // nolint: errcheck
+	}))
+	defer teams.Close()
+	hook, err := webhooks.NewTeams(regexp.MustCompile(".*"), teams.URL, "")
+	Ok(t, err)
+	hook.PreApply = true
+	result := teamsResult
+	result.Success = false
+	result.PreApply = true
+	result.Changes = &webhooks.PlanChanges{Add: 1, Change: 0, Destroy: 2}
+
+	t.Log("the result of a finished apply shouldn't be posted")
+	Ok(t, hook.Send(context.Background(), logging.NewNoopLogger(), teamsResult))
+	Assert(t, card == nil, "expected nothing to be posted")
+
+	Ok(t, hook.Send(context.Background(), logging.NewNoopLogger(), result))
+	Equals(t, "F2C744", card["themeColor"])
+	Equals(t, "Apply starting for owner/repo", card["title"])
+	facts := card["sections"].([]interface{})[0].(map[string]interface{})["facts"]
+	Equals(t, []interface{}{
+		map[string]interface{}{"name": "Workspace", "value": "production"},
+		map[string]interface{}{"name": "User", "value": "alice"},
+		map[string]interface{}{"name": "Ticket", "value": "CHG-1"},
+		map[string]interface{}{"name": "Changes", "value": "1 to add, 0 to change, 2 to destroy"},
+	}, facts)
+}
+
+func TestTeams_SendEgressHostNotAllowed(t *testing.T) {
+	t.Log("A hook whose host isn't an allowed egress host shouldn't post anything")
+	posted := false
+	teams := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted = true
+	}))
+	defer teams.Close()
+	hook, err := webhooks.NewTeams(regexp.MustCompile(".*"), teams.URL, "")
+	Ok(t, err)
+	hook.EgressHosts, err = egress.NewAllowlist([]string{"*.webhook.office.com"})
+	Ok(t, err)
+
+	err = hook.Send(context.Background(), logging.NewNoopLogger(), teamsResult)
+	Assert(t, err != nil, "expected error")
+	Equals(t, `refusing to post to teams: host "127.0.0.1" isn't an allowed egress host`, err.Error())
+	Assert(t, !posted, "expected nothing to be posted")
+}
+
+func TestTeams_SendNoMatch(t *testing.T) {
+	t.Log("Sending a hook with a non-matching regex shouldn't post anything")
+	posted := false
+	teams := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted = true
+	}))
+	defer teams.Close()
+	hook, err := webhooks.NewTeams(regexp.MustCompile("staging"), teams.URL, "")
+	Ok(t, err)
+
+	err = hook.Send(context.Background(), logging.NewNoopLogger(), teamsResult)
+	Ok(t, err)
+	Assert(t, !posted, "expected nothing to be posted")
+}
+
+func TestTeams_SendCustomTemplate(t *testing.T) {
+	t.Log("A custom template should be used for the card")
+	var body string
+	teams := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer teams.Close()
+	hook, err := webhooks.NewTeams(regexp.MustCompile(".*"), teams.URL, `{"text": {{ json (printf "%s %s by %s" .Kind .Outcome .User.Username) }}}`)
+	Ok(t, err)
+
+	err = hook.Send(context.Background(), logging.NewNoopLogger(), teamsResult)
+	Ok(t, err)
+	Equals(t, `{"text": "Apply succeeded by alice"}`, body)
+}
+
+func TestTeams_SendInvalidJSON(t *testing.T) {
+	t.Log("If the template doesn't render JSON we should error without posting")
+	hook, err := webhooks.NewTeams(regexp.MustCompile(".*"), "http://localhost:1", `not json`)
+	Ok(t, err)
+
+	err = hook.Send(context.Background(), logging.NewNoopLogger(), teamsResult)
+	Assert(t, err != nil, "expected error")
+	Equals(t, "rendering teams template: result is not valid JSON", err.Error())
+}
+
+func TestTeams_SendError(t *testing.T) {
+	t.Log("If Teams errors we return an error without the URL since it's a credential")
+	teams := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer teams.Close()
+	hook, err := webhooks.NewTeams(regexp.MustCompile(".*"), teams.URL+"/secret-token", "")
+	Ok(t, err)
+
+	err = hook.Send(context.Background(), logging.NewNoopLogger(), teamsResult)
+	Assert(t, err != nil, "expected error")
+	Equals(t, "posting to teams: got status 400", err.Error())
+
+	teams.Close()
+	err = hook.Send(context.Background(), logging.NewNoopLogger(), teamsResult)
+	Assert(t, err != nil, "expected error")
+	Assert(t, !strings.Contains(err.Error(), "secret-token"), "expected error not to contain the URL, got %s", err)
+}
+
+func TestNewTeams_InvalidTemplate(t *testing.T) {
+	t.Log("A template that doesn't parse should error")
+	_, err := webhooks.NewTeams(regexp.MustCompile(".*"), "http://localhost:1", "{{ .Oops")
+	Assert(t, err != nil, "expected error")
+	Assert(t, strings.HasPrefix(err.Error(), "parsing teams template"), "unexpected error %s", err)
+}
@@ -1,24 +1,39 @@
 package webhooks
 
 import (
+	"context"
 	"fmt"
 	"regexp"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"errors"
 
+	"github.com/hootsuite/atlantis/server/egress"
 	"github.com/hootsuite/atlantis/server/events/models"
 	"github.com/hootsuite/atlantis/server/logging"
 )
 
 const SlackKind = "slack"
+const TeamsKind = "teams"
+const HTTPKind = "http"
 const ApplyEvent = "apply"
 
+// EmergencyApplyEvent is only sent for emergency applies, ex. to notify a
+// security channel.
+const EmergencyApplyEvent = "emergency-apply"
+
+// PreApplyEvent is sent when an apply is about to start, ex. so on-call know
+// about changes to a protected environment before they land.
+const PreApplyEvent = "pre-apply"
+
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_sender.go Sender
 
 // Sender sends webhooks.
 type Sender interface {
 	// Send sends the webhook (if the implementation thinks it should).
-	Send(log *logging.SimpleLogger, applyResult ApplyResult) error
+	Send(ctx context.Context, log *logging.SimpleLogger, applyResult ApplyResult) error
 }
 
 // ApplyResult is the result of a terraform apply.
@@ -28,11 +43,94 @@ type ApplyResult struct {
 	Pull      models.PullRequest
 	User      models.User
 	Success   bool
+	// Emergency is true if the apply was run with --emergency to bypass the
+	// change window.
+	Emergency bool
+	// Ticket is the change ticket referenced by the apply, if any.
+	Ticket string
+	// PreApply is true if the apply is about to start rather than finished,
+	// in which case Success is meaningless.
+	PreApply bool
+	// Changes are the changes that the apply will make in all its projects
+	// before it starts, or that it made in Project once it's finished. They're
+	// nil if they aren't known.
+	Changes *PlanChanges
+	// Project is the path of the project that was applied. It isn't set for
+	// pre-apply results since they're for the whole apply.
+	Project string
+	// Output is terraform's output from applying Project. MultiWebhookSender
+	// truncates it to MaxOutput.
+	Output string
+	// Duration is how long terraform took to apply Project.
+	Duration time.Duration
+	// ExternalApproval is the decision of the approval services, or nil if
+	// external approval isn't required.
+	ExternalApproval *ExternalApproval
+}
+
+// MaxOutput is the most bytes of apply output that are sent in a webhook and
+// kept in a dead letter. Longer output is truncated to its end since that's
+// where terraform reports what it did and any errors.
+const MaxOutput = 32 * 1024
+
+// truncatedOutputMarker starts output that was truncated to MaxOutput.
+const truncatedOutputMarker = "[output truncated to its last %d bytes]\n"
+
+// truncateOutput returns output with only its last MaxOutput bytes if it's
+// longer than that, after a marker saying that it was truncated.
+func truncateOutput(output string) string {
+	if len(output) <= MaxOutput {
+		return output
+	}
+	start := len(output) - MaxOutput
+	// Don't start in the middle of a multi-byte character.
+	for start < len(output) && !utf8.RuneStart(output[start]) {
+		start++
+	}
+	return fmt.Sprintf(truncatedOutputMarker, len(output)-start) + output[start:]
+}
+
+// ExternalApproval is the combined decision of the approval services that an
+// apply was allowed by.
+type ExternalApproval struct {
+	Decision  string `json:"decision"`
+	Approvals int    `json:"approvals"`
+	Quorum    int    `json:"quorum"`
+	Total     int    `json:"total"`
+}
+
+// PlanChanges counts what a plan changes.
+type PlanChanges struct {
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Destroy int `json:"destroy"`
+}
+
+// String describes the changes like terraform does, ex. "1 to add, 0 to
+// change, 2 to destroy".
+func (c PlanChanges) String() string {
+	return fmt.Sprintf("%d to add, %d to change, %d to destroy", c.Add, c.Change, c.Destroy)
 }
 
 // MultiWebhookSender sends multiple webhooks for each one it's configured for.
 type MultiWebhookSender struct {
+	// Webhooks are the sinks we send to. Use SetWebhooks to change them once
+	// the sender is in use.
 	Webhooks []Sender
+	// Concurrency is the maximum number of webhooks that are sent at the
+	// same time. Values less than 1 mean webhooks are sent one at a time.
+	Concurrency int
+	// SendTimeout is how long we wait for a single webhook to be sent before
+	// giving up on it. If 0, we wait forever.
+	SendTimeout time.Duration
+	// DeadLetters, if set, is where webhooks that failed to send are stored
+	// so they can be re-sent later with Redrive.
+	DeadLetters *DeadLetterLog
+
+	webhooksMutex sync.RWMutex
+	// redriveMutex stops redrives that overlap from sending the same
+	// letters twice.
+	redriveMutex sync.Mutex
 }
 
 type Config struct {
@@ -40,9 +138,19 @@ type Config struct {
 	WorkspaceRegex string
 	Kind           string
 	Channel        string
+	// URL is for Teams and HTTP webhooks and Template is for Teams.
+	URL      string
+	Template string
+	// Secret, if set, signs the payloads of HTTP webhooks.
+	Secret string
+	// ExcludeWorkspaceRegex and FailuresOnly are for any kind. See Filter.
+	ExcludeWorkspaceRegex string
+	FailuresOnly          bool
 }
 
-func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookSender, error) {
+// NewMultiWebhookSender returns a sender for configs. If egressHosts isn't
+// nil, every webhook has to be sent to one of its hosts.
+func NewMultiWebhookSender(configs []Config, client SlackClient, egressHosts *egress.Allowlist) (*MultiWebhookSender, error) {
 	var webhooks []Sender
 	for _, c := range configs {
 		r, err := regexp.Compile(c.WorkspaceRegex)
@@ -52,9 +160,14 @@ func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookS
 		if c.Kind == "" || c.Event == "" {
 			return nil, errors.New("must specify \"kind\" and \"event\" keys for webhooks")
 		}
-		if c.Event != ApplyEvent {
-			return nil, fmt.Errorf("\"event: %s\" not supported. Only \"event: %s\" is supported right now", c.Event, ApplyEvent)
+		if c.Event != ApplyEvent && c.Event != EmergencyApplyEvent && c.Event != PreApplyEvent {
+			return nil, fmt.Errorf("\"event: %s\" not supported. Only \"event: %s\", \"event: %s\" and \"event: %s\" are supported right now", c.Event, ApplyEvent, EmergencyApplyEvent, PreApplyEvent)
 		}
+		filter, err := newFilter(c)
+		if err != nil {
+			return nil, err
+		}
+		var hook Sender
 		switch c.Kind {
 		case SlackKind:
 			if !client.TokenIsSet() {
@@ -63,14 +176,52 @@ func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookS
 			if c.Channel == "" {
 				return nil, errors.New("must specify \"channel\" if using a webhook of \"kind: slack\"")
 			}
+			// Checked before NewSlack since it calls the API.
+			if err := egressHosts.Check(SlackAPIURL); err != nil {
+				return nil, fmt.Errorf("slack webhook for channel %q: %s", c.Channel, err)
+			}
 			slack, err := NewSlack(r, c.Channel, client)
 			if err != nil {
 				return nil, err
 			}
-			webhooks = append(webhooks, slack)
+			slack.EmergencyOnly = c.Event == EmergencyApplyEvent
+			slack.PreApply = c.Event == PreApplyEvent
+			slack.EgressHosts = egressHosts
+			hook = slack
+		case TeamsKind:
+			if c.URL == "" {
+				return nil, errors.New("must specify \"url\" if using a webhook of \"kind: teams\"")
+			}
+			if err := egressHosts.Check(c.URL); err != nil {
+				return nil, fmt.Errorf("teams webhook url: %s", err)
+			}
+			teams, err := NewTeams(r, c.URL, c.Template)
+			if err != nil {
+				return nil, err
+			}
+			teams.EmergencyOnly = c.Event == EmergencyApplyEvent
+			teams.PreApply = c.Event == PreApplyEvent
+			teams.EgressHosts = egressHosts
+			hook = teams
+		case HTTPKind:
+			if c.URL == "" {
+				return nil, errors.New("must specify \"url\" if using a webhook of \"kind: http\"")
+			}
+			if err := egressHosts.Check(c.URL); err != nil {
+				return nil, fmt.Errorf("http webhook url: %s", err)
+			}
+			httpHook := NewHTTP(r, c.URL, []byte(c.Secret))
+			httpHook.EmergencyOnly = c.Event == EmergencyApplyEvent
+			httpHook.PreApply = c.Event == PreApplyEvent
+			httpHook.EgressHosts = egressHosts
+			hook = httpHook
 		default:
-			return nil, fmt.Errorf("\"kind: %s\" not supported. Only \"kind: %s\" is supported right now", c.Kind, SlackKind)
+			return nil, fmt.Errorf("\"kind: %s\" not supported. Only \"kind: %s\", \"kind: %s\" and \"kind: %s\" are supported right now", c.Kind, SlackKind, TeamsKind, HTTPKind)
 		}
+		if filter != (Filter{}) {
+			hook = &FilteredSender{Sender: hook, Filter: filter}
+		}
+		webhooks = append(webhooks, hook)
 	}
 
 	return &MultiWebhookSender{
@@ -78,12 +229,137 @@ func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookS
 	}, nil
 }
 
+// SetWebhooks replaces the sinks that webhooks are sent to. Sends that are
+// already in progress finish with the old sinks. Dead letters reference sinks
+// by their position so they're redriven to whichever sink is now in that
+// position.
+func (w *MultiWebhookSender) SetWebhooks(webhooks []Sender) {
+	w.webhooksMutex.Lock()
+	defer w.webhooksMutex.Unlock()
+	w.Webhooks = webhooks
+}
+
+func (w *MultiWebhookSender) webhooks() []Sender {
+	w.webhooksMutex.RLock()
+	defer w.webhooksMutex.RUnlock()
+	return w.Webhooks
+}
+
 // Send sends the webhook using its Webhooks.
-func (w *MultiWebhookSender) Send(log *logging.SimpleLogger, result ApplyResult) error {
-	for _, w := range w.Webhooks {
-		if err := w.Send(log, result); err != nil {
-			log.Warn("error sending slack webhook: %s", err)
+// Up to Concurrency webhooks are sent at once so a slow sink doesn't hold up
+// the others. Because of this, the order in which the sinks receive the
+// webhook is best-effort only. Errors are logged and never returned.
+// result's Output is truncated to MaxOutput before it's sent.
+func (w *MultiWebhookSender) Send(ctx context.Context, log *logging.SimpleLogger, result ApplyResult) error {
+	result.Output = truncateOutput(result.Output)
+	workers := w.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	hooks := w.webhooks()
+	errs := make([]error, len(hooks))
+	var wg sync.WaitGroup
+	for i, hook := range hooks {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, hook Sender) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = w.sendWithTimeout(ctx, log, hook, result)
+		}(i, hook)
+	}
+	wg.Wait()
+
+	// We log after all the sends are done because the logger isn't safe to
+	// use from multiple goroutines.
+	for i, err := range errs {
+		if err != nil {
+			log.Warn("error sending webhook: %s", err)
+			w.deadLetter(log, i, result, err)
 		}
 	}
 	return nil
 }
+
+// Redrive re-sends every webhook in the dead letter log. Webhooks that are
+// sent successfully are removed from the log, the rest are kept, as are
+// webhooks that fail while the others are re-sent. It returns the number of
+// webhooks that were sent and the number that are still failing.
+func (w *MultiWebhookSender) Redrive(ctx context.Context, log *logging.SimpleLogger) (int, int, error) {
+	if w.DeadLetters == nil {
+		return 0, 0, nil
+	}
+	w.redriveMutex.Lock()
+	defer w.redriveMutex.Unlock()
+	letters, err := w.DeadLetters.List()
+	if err != nil {
+		return 0, 0, err
+	}
+	hooks := w.webhooks()
+	var sent, failed []DeadLetter
+	unconfigured := 0
+	for _, l := range letters {
+		if l.Webhook < 0 || l.Webhook >= len(hooks) {
+			log.Warn("dead letter from %s references webhook %d which is no longer configured", l.Time, l.Webhook)
+			unconfigured++
+			continue
+		}
+		if err := w.sendWithTimeout(ctx, log, hooks[l.Webhook], l.Result); err != nil {
+			log.Warn("error re-sending webhook: %s", err)
+			l.Time = time.Now()
+			l.Error = err.Error()
+			failed = append(failed, l)
+			continue
+		}
+		sent = append(sent, l)
+	}
+	if err := w.DeadLetters.Resolve(sent, failed); err != nil {
+		return 0, 0, err
+	}
+	return len(sent), len(failed) + unconfigured, nil
+}
+
+func (w *MultiWebhookSender) deadLetter(log *logging.SimpleLogger, webhook int, result ApplyResult, sendErr error) {
+	if w.DeadLetters == nil {
+		return
+	}
+	err := w.DeadLetters.Append(DeadLetter{
+		Time:    time.Now(),
+		Webhook: webhook,
+		Result:  result,
+		Error:   sendErr.Error(),
+	})
+	if err != nil {
+		log.Err("failed to write webhook to dead letter log: %s", err)
+	}
+}
+
+// sendWithTimeout sends result using hook. If the send takes longer than
+// SendTimeout we cancel it and return an error.
+func (w *MultiWebhookSender) sendWithTimeout(ctx context.Context, log *logging.SimpleLogger, hook Sender, result ApplyResult) error {
+	// log isn't safe to use from more than one goroutine and sends run
+	// concurrently, including ones we've stopped waiting for, so each send
+	// logs without keeping history.
+	hookLog := logging.NewSimpleLogger(log.Source, log.Logger, false, log.Level)
+	if w.SendTimeout <= 0 {
+		return hook.Send(ctx, hookLog, result)
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.SendTimeout)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- hook.Send(ctx, hookLog, result)
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s", w.SendTimeout)
+		}
+		return ctx.Err()
+	}
+}
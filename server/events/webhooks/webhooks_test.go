@@ -1,11 +1,17 @@
 package webhooks_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/hootsuite/atlantis/server/egress"
 	"github.com/hootsuite/atlantis/server/events/webhooks"
 	"github.com/hootsuite/atlantis/server/events/webhooks/mocks"
+	"github.com/hootsuite/atlantis/server/events/webhooks/mocks/matchers"
 	"github.com/hootsuite/atlantis/server/logging"
 	. "github.com/hootsuite/atlantis/testing"
 	. "github.com/petergtz/pegomock"
@@ -38,7 +44,7 @@ func TestNewWebhooksManager_InvalidRegex(t *testing.T) {
 	invalidRegex := "("
 	configs := validConfigs()
 	configs[0].WorkspaceRegex = invalidRegex
-	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Assert(t, err != nil, "expected error")
 	Assert(t, strings.Contains(err.Error(), "error parsing regexp"), "expected regex error")
 }
@@ -49,7 +55,7 @@ func TestNewWebhooksManager_NoEvent(t *testing.T) {
 	client := mocks.NewMockSlackClient()
 	configs := validConfigs()
 	configs[0].Event = ""
-	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Assert(t, err != nil, "expected error")
 	Equals(t, "must specify \"kind\" and \"event\" keys for webhooks", err.Error())
 }
@@ -63,9 +69,9 @@ func TestNewWebhooksManager_UnsupportedEvent(t *testing.T) {
 	unsupportedEvent := "badevent"
 	configs := validConfigs()
 	configs[0].Event = unsupportedEvent
-	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Assert(t, err != nil, "expected error")
-	Equals(t, "\"event: badevent\" not supported. Only \"event: apply\" is supported right now", err.Error())
+	Equals(t, "\"event: badevent\" not supported. Only \"event: apply\", \"event: emergency-apply\" and \"event: pre-apply\" are supported right now", err.Error())
 }
 
 func TestNewWebhooksManager_NoKind(t *testing.T) {
@@ -74,7 +80,7 @@ func TestNewWebhooksManager_NoKind(t *testing.T) {
 	client := mocks.NewMockSlackClient()
 	configs := validConfigs()
 	configs[0].Kind = ""
-	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Assert(t, err != nil, "expected error")
 	Equals(t, "must specify \"kind\" and \"event\" keys for webhooks", err.Error())
 }
@@ -88,9 +94,48 @@ func TestNewWebhooksManager_UnsupportedKind(t *testing.T) {
 	unsupportedKind := "badkind"
 	configs := validConfigs()
 	configs[0].Kind = unsupportedKind
-	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Assert(t, err != nil, "expected error")
-	Equals(t, "\"kind: badkind\" not supported. Only \"kind: slack\" is supported right now", err.Error())
+	Equals(t, "\"kind: badkind\" not supported. Only \"kind: slack\", \"kind: teams\" and \"kind: http\" are supported right now", err.Error())
+}
+
+func TestNewWebhooksManager_TeamsNoURL(t *testing.T) {
+	t.Log("When a teams webhook has no url, an error is returned")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	configs := []webhooks.Config{{Event: validEvent, WorkspaceRegex: validRegex, Kind: webhooks.TeamsKind}}
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
+	Assert(t, err != nil, "expected error")
+	Equals(t, "must specify \"url\" if using a webhook of \"kind: teams\"", err.Error())
+}
+
+func TestNewWebhooksManager_HTTPNoURL(t *testing.T) {
+	t.Log("When an http webhook has no url, an error is returned")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	configs := []webhooks.Config{{Event: validEvent, WorkspaceRegex: validRegex, Kind: webhooks.HTTPKind}}
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
+	Assert(t, err != nil, "expected error")
+	Equals(t, "must specify \"url\" if using a webhook of \"kind: http\"", err.Error())
+}
+
+func TestNewWebhooksManager_EgressHostNotAllowed(t *testing.T) {
+	t.Log("When a webhook's host isn't an allowed egress host, an error is returned")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	When(client.TokenIsSet()).ThenReturn(true)
+	allowlist, err := egress.NewAllowlist([]string{"*.webhook.office.com"})
+	Ok(t, err)
+
+	configs := []webhooks.Config{{Event: validEvent, WorkspaceRegex: validRegex, Kind: webhooks.TeamsKind, URL: "https://evil.example.org/secret"}}
+	_, err = webhooks.NewMultiWebhookSender(configs, client, allowlist)
+	Assert(t, err != nil, "expected error")
+	Equals(t, `teams webhook url: host "evil.example.org" isn't an allowed egress host`, err.Error())
+
+	_, err = webhooks.NewMultiWebhookSender(validConfigs(), client, allowlist)
+	Assert(t, err != nil, "expected error")
+	Equals(t, `slack webhook for channel "validchannel": host "slack.com" isn't an allowed egress host`, err.Error())
+	client.VerifyWasCalled(Never()).AuthTest()
 }
 
 func TestNewWebhooksManager_NoConfigSuccess(t *testing.T) {
@@ -98,13 +143,13 @@ func TestNewWebhooksManager_NoConfigSuccess(t *testing.T) {
 	t.Log("passing any client should succeed")
 	var emptyConfigs []webhooks.Config
 	emptyToken := ""
-	m, err := webhooks.NewMultiWebhookSender(emptyConfigs, webhooks.NewSlackClient(emptyToken))
+	m, err := webhooks.NewMultiWebhookSender(emptyConfigs, webhooks.NewSlackClient(emptyToken), nil)
 	Ok(t, err)
 	Assert(t, m != nil, "manager shouldn't be nil")
 	Equals(t, 0, len(m.Webhooks))
 
 	t.Log("passing nil client hould succeed")
-	m, err = webhooks.NewMultiWebhookSender(emptyConfigs, nil)
+	m, err = webhooks.NewMultiWebhookSender(emptyConfigs, nil, nil)
 	Ok(t, err)
 	Assert(t, m != nil, "manager shouldn't be nil")
 	Equals(t, 0, len(m.Webhooks))
@@ -117,7 +162,7 @@ func TestNewWebhooksManager_SingleConfigSuccess(t *testing.T) {
 	When(client.ChannelExists(validChannel)).ThenReturn(true, nil)
 
 	configs := validConfigs()
-	m, err := webhooks.NewMultiWebhookSender(configs, client)
+	m, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Ok(t, err)
 	Assert(t, m != nil, "manager shouldn't be nil")
 	Equals(t, 1, len(m.Webhooks))
@@ -135,7 +180,7 @@ func TestNewWebhooksManager_MultipleConfigSuccess(t *testing.T) {
 	for i := 0; i < nConfigs; i++ {
 		configs = append(configs, validConfig)
 	}
-	m, err := webhooks.NewMultiWebhookSender(configs, client)
+	m, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Ok(t, err)
 	Assert(t, m != nil, "manager shouldn't be nil")
 	Equals(t, nConfigs, len(m.Webhooks))
@@ -150,8 +195,34 @@ func TestSend_SingleSuccess(t *testing.T) {
 	}
 	logger := logging.NewNoopLogger()
 	result := webhooks.ApplyResult{}
-	manager.Send(logger, result) // nolint: errcheck
-	sender.VerifyWasCalledOnce().Send(logger, result)
+	manager.Send(context.Background(), logger, result) // nolint: errcheck
+	sender.VerifyWasCalledOnce().Send(matchers.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), matchers.EqWebhooksApplyResult(result))
+}
+
+func TestSend_TruncatesOutput(t *testing.T) {
+	t.Log("output over MaxOutput should be truncated to its end, with a marker, before it's sent or dead lettered")
+	RegisterMockTestingT(t)
+	d, cleanup := deadLetterLog(t)
+	defer cleanup()
+	sender := mocks.NewMockSender()
+	output := strings.Repeat("a", webhooks.MaxOutput) + "Apply complete!"
+	truncated := fmt.Sprintf("[output truncated to its last %d bytes]\n", webhooks.MaxOutput) + output[len(output)-webhooks.MaxOutput:]
+	When(sender.Send(matchers.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), matchers.EqWebhooksApplyResult(webhooks.ApplyResult{Output: truncated}))).ThenReturn(errors.New("down"))
+	manager := webhooks.MultiWebhookSender{
+		Webhooks:    []webhooks.Sender{sender},
+		DeadLetters: d,
+	}
+
+	manager.Send(context.Background(), logging.NewNoopLogger(), webhooks.ApplyResult{Output: output}) // nolint: errcheck
+	sender.VerifyWasCalledOnce().Send(matchers.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), matchers.EqWebhooksApplyResult(webhooks.ApplyResult{Output: truncated}))
+	letters, err := d.List()
+	Ok(t, err)
+	Equals(t, 1, len(letters))
+	Equals(t, truncated, letters[0].Result.Output)
+
+	t.Log("shorter output should be sent as is")
+	manager.Send(context.Background(), logging.NewNoopLogger(), webhooks.ApplyResult{Output: "Apply complete!"}) // nolint: errcheck
+	sender.VerifyWasCalledOnce().Send(matchers.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), matchers.EqWebhooksApplyResult(webhooks.ApplyResult{Output: "Apply complete!"}))
 }
 
 func TestSend_MultipleSuccess(t *testing.T) {
@@ -167,9 +238,133 @@ func TestSend_MultipleSuccess(t *testing.T) {
 	}
 	logger := logging.NewNoopLogger()
 	result := webhooks.ApplyResult{}
-	err := manager.Send(logger, result)
+	err := manager.Send(context.Background(), logger, result)
 	Ok(t, err)
 	for _, s := range senders {
-		s.VerifyWasCalledOnce().Send(logger, result)
+		s.VerifyWasCalledOnce().Send(matchers.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), matchers.EqWebhooksApplyResult(result))
+	}
+}
+
+func TestSend_AfterSetWebhooks(t *testing.T) {
+	t.Log("Webhooks should only be sent to the sinks set by SetWebhooks")
+	RegisterMockTestingT(t)
+	old := mocks.NewMockSender()
+	replacement := mocks.NewMockSender()
+	manager := webhooks.MultiWebhookSender{
+		Webhooks: []webhooks.Sender{old},
+	}
+	manager.SetWebhooks([]webhooks.Sender{replacement})
+	logger := logging.NewNoopLogger()
+	result := webhooks.ApplyResult{}
+	err := manager.Send(context.Background(), logger, result)
+	Ok(t, err)
+	old.VerifyWasCalled(Never()).Send(matchers.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), matchers.EqWebhooksApplyResult(result))
+	replacement.VerifyWasCalledOnce().Send(matchers.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), matchers.EqWebhooksApplyResult(result))
+}
+
+func TestSend_Concurrent(t *testing.T) {
+	t.Log("Webhooks should be sent concurrently up to the concurrency limit")
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	sender := &blockingSender{started: started, release: release}
+	manager := webhooks.MultiWebhookSender{
+		Webhooks:    []webhooks.Sender{sender, sender},
+		Concurrency: 2,
+	}
+	done := make(chan struct{})
+	go func() {
+		manager.Send(context.Background(), logging.NewNoopLogger(), webhooks.ApplyResult{}) // nolint: errcheck
+		close(done)
+	}()
+
+	// Both sends must start before either is released.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("expected both webhooks to be sending at the same time")
+		}
+	}
+	close(release)
+	<-done
+}
+
+func TestSend_Timeout(t *testing.T) {
+	t.Log("A webhook that takes longer than the timeout shouldn't block Send")
+	release := make(chan struct{})
+	defer close(release)
+	manager := webhooks.MultiWebhookSender{
+		Webhooks:    []webhooks.Sender{&blockingSender{release: release}},
+		SendTimeout: 10 * time.Millisecond,
+	}
+	done := make(chan error)
+	go func() {
+		done <- manager.Send(context.Background(), logging.NewNoopLogger(), webhooks.ApplyResult{})
+	}()
+	select {
+	case err := <-done:
+		Ok(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("expected Send to return after the timeout")
+	}
+}
+
+func TestSend_TimeoutCancelsSend(t *testing.T) {
+	t.Log("A webhook that takes longer than the timeout should be cancelled so it doesn't keep retrying")
+	cancelled := make(chan struct{})
+	manager := webhooks.MultiWebhookSender{
+		Webhooks:    []webhooks.Sender{&cancellableSender{cancelled: cancelled}},
+		SendTimeout: 10 * time.Millisecond,
+	}
+	manager.Send(context.Background(), logging.NewNoopLogger(), webhooks.ApplyResult{}) // nolint: errcheck
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the send to be cancelled")
+	}
+}
+
+func TestSend_DoesntKeepHistory(t *testing.T) {
+	t.Log("Webhooks are sent concurrently so they shouldn't log to the history of the logger they're sent with")
+	logger := logging.NewSimpleLogger("", nil, true, logging.Error)
+	manager := webhooks.MultiWebhookSender{
+		Webhooks:    []webhooks.Sender{&loggingSender{}, &loggingSender{}},
+		Concurrency: 2,
+	}
+	Ok(t, manager.Send(context.Background(), logger, webhooks.ApplyResult{}))
+	Equals(t, "", logger.History.String())
+}
+
+// cancellableSender is a Sender that doesn't return until it's cancelled,
+// which it reports by closing cancelled.
+type cancellableSender struct {
+	cancelled chan struct{}
+}
+
+func (c *cancellableSender) Send(ctx context.Context, _ *logging.SimpleLogger, _ webhooks.ApplyResult) error {
+	<-ctx.Done()
+	close(c.cancelled)
+	return ctx.Err()
+}
+
+// loggingSender is a Sender that logs when it sends.
+type loggingSender struct{}
+
+func (l *loggingSender) Send(_ context.Context, log *logging.SimpleLogger, _ webhooks.ApplyResult) error {
+	log.Info("sending")
+	return nil
+}
+
+// blockingSender is a Sender that doesn't return until release is closed.
+type blockingSender struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingSender) Send(_ context.Context, log *logging.SimpleLogger, applyResult webhooks.ApplyResult) error {
+	if b.started != nil {
+		b.started <- struct{}{}
 	}
+	<-b.release
+	return nil
 }
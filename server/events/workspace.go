@@ -1,9 +1,12 @@
 package events
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 
 	"os/exec"
 
@@ -26,6 +29,59 @@ type Workspace interface {
 
 type FileWorkspace struct {
 	DataDir string
+	// EnvDataDirs maps an environment to the directory that its workspaces
+	// are cloned into instead of DataDir, ex. so production state and plans
+	// never share a filesystem with other environments.
+	EnvDataDirs map[string]string
+}
+
+// ValidateEnvDataDirs returns an error if any of the environment data dirs
+// aren't absolute paths, overlap the data dir or each other, or can be
+// accessed by other users. Dirs that don't exist are created.
+func (w *FileWorkspace) ValidateEnvDataDirs() error {
+	absDataDir, err := filepath.Abs(w.DataDir)
+	if err != nil {
+		return errors.Wrapf(err, "resolving %s", w.DataDir)
+	}
+	var envs []string
+	for env := range w.EnvDataDirs {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	for i, env := range envs {
+		dir := w.EnvDataDirs[env]
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("data dir for environment %q must be an absolute path", env)
+		}
+		if overlaps(dir, absDataDir) {
+			return fmt.Errorf("data dir for environment %q must be outside the data dir %s", env, w.DataDir)
+		}
+		for _, other := range envs[i+1:] {
+			if overlaps(dir, w.EnvDataDirs[other]) {
+				return fmt.Errorf("data dirs for environments %q and %q must not overlap", env, other)
+			}
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return errors.Wrapf(err, "creating data dir for environment %q", env)
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			return errors.Wrapf(err, "checking data dir for environment %q", env)
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			return fmt.Errorf("data dir %s for environment %q must only be accessible by its owner but its mode is %#o", dir, env, info.Mode().Perm())
+		}
+	}
+	return nil
+}
+
+// overlaps returns true if a and b are the same directory or one is inside
+// the other.
+func overlaps(a string, b string) bool {
+	a = filepath.Clean(a)
+	b = filepath.Clean(b)
+	sep := string(filepath.Separator)
+	return a == b || strings.HasPrefix(a, strings.TrimSuffix(b, sep)+sep) || strings.HasPrefix(b, strings.TrimSuffix(a, sep)+sep)
 }
 
 // Clone git clones headRepo, checks out the branch and then returns the absolute
@@ -74,15 +130,27 @@ func (w *FileWorkspace) GetWorkspace(r models.Repo, p models.PullRequest, env st
 	return repoDir, nil
 }
 
-// Delete deletes the workspace for this repo and pull
+// Delete deletes the workspaces for this repo and pull in every data dir.
 func (w *FileWorkspace) Delete(r models.Repo, p models.PullRequest) error {
-	return os.RemoveAll(w.repoPullDir(r, p))
+	if err := os.RemoveAll(repoPullDir(w.DataDir, r, p)); err != nil {
+		return err
+	}
+	for _, dir := range w.EnvDataDirs {
+		if err := os.RemoveAll(repoPullDir(dir, r, p)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (w *FileWorkspace) repoPullDir(r models.Repo, p models.PullRequest) string {
-	return filepath.Join(w.DataDir, workspacePrefix, r.FullName, strconv.Itoa(p.Num))
+func repoPullDir(dataDir string, r models.Repo, p models.PullRequest) string {
+	return filepath.Join(dataDir, workspacePrefix, r.FullName, strconv.Itoa(p.Num))
 }
 
 func (w *FileWorkspace) cloneDir(r models.Repo, p models.PullRequest, env string) string {
-	return filepath.Join(w.repoPullDir(r, p), env)
+	dataDir := w.DataDir
+	if dir, ok := w.EnvDataDirs[env]; ok {
+		dataDir = dir
+	}
+	return filepath.Join(repoPullDir(dataDir, r, p), env)
 }
@@ -0,0 +1,210 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events/locking"
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/logging"
+	"github.com/pkg/errors"
+)
+
+// maxJanitorInterval is the longest the janitor waits between clean ups.
+const maxJanitorInterval = time.Hour
+
+// WorkspaceJanitor deletes the workspaces that were last cloned more than TTL
+// ago or whose pull requests are closed, ex. because Atlantis missed the event
+// for the pull request being closed.
+type WorkspaceJanitor struct {
+	Workspace *FileWorkspace
+	// Locker is used to skip workspaces with locked plans.
+	Locker locking.Locker
+	// EnvLocker is held while a workspace is deleted so no command can run in it
+	// at the same time.
+	EnvLocker EnvLocker
+	// GithubPullGetter and GitlabMergeRequestGetter are used to check if a
+	// pull request is closed. They're nil if the VCS host isn't configured.
+	GithubPullGetter         GithubPullGetter
+	GitlabMergeRequestGetter GitlabMergeRequestGetter
+	TTL                      time.Duration
+	Logger                   *logging.SimpleLogger
+}
+
+// pullDir is the directory the workspaces of a pull request are in.
+type pullDir struct {
+	Path         string
+	RepoFullName string
+	PullNum      int
+}
+
+// Start cleans up workspaces until the process exits. It waits TTL or an hour,
+// whichever is shorter, between clean ups.
+func (j *WorkspaceJanitor) Start() {
+	interval := j.TTL
+	if interval > maxJanitorInterval {
+		interval = maxJanitorInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := j.Clean(); err != nil {
+			j.Logger.Err("cleaning up workspaces: %s", err)
+		}
+	}
+}
+
+// Clean deletes every expired workspace and every workspace of a closed pull
+// request that isn't locked or in use.
+func (j *WorkspaceJanitor) Clean() error {
+	locks, err := j.Locker.List()
+	if err != nil {
+		return errors.Wrap(err, "listing locks")
+	}
+	locked := make(map[string]bool)
+	for _, lock := range locks {
+		locked[janitorKey(lock.Project.RepoFullName, lock.Pull.Num, lock.Env)] = true
+	}
+
+	dataDirs := []string{j.Workspace.DataDir}
+	for _, dir := range j.Workspace.EnvDataDirs {
+		dataDirs = append(dataDirs, dir)
+	}
+	closed := make(map[string]bool)
+	for _, dataDir := range dataDirs {
+		pulls, err := findPullDirs(filepath.Join(dataDir, workspacePrefix))
+		if err != nil {
+			return errors.Wrapf(err, "finding workspaces in %s", dataDir)
+		}
+		for _, pull := range pulls {
+			key := janitorKey(pull.RepoFullName, pull.PullNum, "")
+			if _, ok := closed[key]; !ok {
+				isClosed, err := j.pullIsClosed(pull.RepoFullName, pull.PullNum)
+				if err != nil {
+					j.Logger.Warn("checking if %s#%d is closed: %s", pull.RepoFullName, pull.PullNum, err)
+				}
+				closed[key] = isClosed
+			}
+			j.cleanPull(pull, closed[key], locked)
+		}
+	}
+	return nil
+}
+
+// cleanPull deletes the workspaces in pull that are expired or, if closed is
+// true, all of them unless they're locked or in use.
+func (j *WorkspaceJanitor) cleanPull(pull pullDir, closed bool, locked map[string]bool) {
+	envDirs, err := ioutil.ReadDir(pull.Path)
+	if err != nil {
+		j.Logger.Warn("listing workspaces in %s: %s", pull.Path, err)
+		return
+	}
+	for _, envDir := range envDirs {
+		if !envDir.IsDir() {
+			continue
+		}
+		env := envDir.Name()
+		dir := filepath.Join(pull.Path, env)
+		// Clone recreates the workspace so it's last modified when it was last
+		// cloned.
+		age := time.Since(envDir.ModTime())
+		var reason string
+		switch {
+		case closed:
+			reason = "its pull request is closed"
+		case age > j.TTL:
+			reason = fmt.Sprintf("it was last cloned %s ago", age.Truncate(time.Second))
+		default:
+			continue
+		}
+		if locked[janitorKey(pull.RepoFullName, pull.PullNum, env)] {
+			j.Logger.Info("not deleting workspace %s since it's locked", dir)
+			continue
+		}
+		if !j.EnvLocker.TryLock(pull.RepoFullName, env, pull.PullNum) {
+			j.Logger.Info("not deleting workspace %s since a command is running in it", dir)
+			continue
+		}
+		err := os.RemoveAll(dir)
+		j.EnvLocker.Unlock(pull.RepoFullName, env, pull.PullNum)
+		if err != nil {
+			j.Logger.Warn("deleting workspace %s: %s", dir, err)
+			continue
+		}
+		j.Logger.Info("deleted workspace %s since %s", dir, reason)
+	}
+	// Only succeeds if every workspace was deleted.
+	os.Remove(pull.Path) // nolint: errcheck
+}
+
+// pullIsClosed returns true if the pull request is closed or merged. It asks
+// GitHub first and GitLab if GitHub doesn't know the pull request since the
+// workspace doesn't record which host it's from.
+func (j *WorkspaceJanitor) pullIsClosed(repoFullName string, pullNum int) (bool, error) {
+	ctx := context.Background()
+	var err error
+	if j.GithubPullGetter != nil {
+		owner, name := filepath.Split(repoFullName)
+		repo := models.Repo{FullName: repoFullName, Owner: strings.TrimSuffix(owner, "/"), Name: name}
+		pull, ghErr := j.GithubPullGetter.GetPullRequest(ctx, repo, pullNum)
+		if ghErr == nil {
+			return pull.GetState() == "closed", nil
+		}
+		err = errors.Wrap(ghErr, "making pull request API call to GitHub")
+	}
+	if j.GitlabMergeRequestGetter != nil {
+		mr, glErr := j.GitlabMergeRequestGetter.GetMergeRequest(ctx, repoFullName, pullNum)
+		if glErr == nil {
+			return mr.State == "closed" || mr.State == "merged", nil
+		}
+		err = errors.Wrap(glErr, "making merge request API call to GitLab")
+	}
+	return false, err
+}
+
+// findPullDirs returns the pull request directories in reposDir, which are
+// laid out as {repoFullName}/{pullNum}. GitLab repos can be nested in
+// subgroups so repoFullName can have more than one /.
+func findPullDirs(reposDir string) ([]pullDir, error) {
+	var pulls []pullDir
+	err := filepath.Walk(reposDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == reposDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !info.IsDir() || path == reposDir {
+			return nil
+		}
+		rel, err := filepath.Rel(reposDir, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) < 3 {
+			return nil
+		}
+		num, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil {
+			return nil
+		}
+		pulls = append(pulls, pullDir{
+			Path:         path,
+			RepoFullName: strings.Join(parts[:len(parts)-1], "/"),
+			PullNum:      num,
+		})
+		return filepath.SkipDir
+	})
+	return pulls, err
+}
+
+func janitorKey(repoFullName string, pullNum int, env string) string {
+	return fmt.Sprintf("%s/%d/%s", repoFullName, pullNum, env)
+}
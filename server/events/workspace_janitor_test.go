@@ -0,0 +1,98 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/hootsuite/atlantis/server/events"
+	lmocks "github.com/hootsuite/atlantis/server/events/locking/mocks"
+	"github.com/hootsuite/atlantis/server/events/mocks"
+	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/logging"
+	. "github.com/hootsuite/atlantis/testing"
+	. "github.com/petergtz/pegomock"
+)
+
+func TestWorkspaceJanitor_Clean(t *testing.T) {
+	t.Log("expired workspaces and workspaces of closed pull requests should be deleted unless they're locked or in use")
+	RegisterMockTestingT(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	old := time.Now().Add(-2 * time.Hour)
+	workspace := func(pullNum string, env string, modified time.Time) string {
+		dir := filepath.Join(tmp, "repos", "owner", "repo", pullNum, env)
+		Ok(t, os.MkdirAll(dir, 0700))
+		Ok(t, os.Chtimes(dir, modified, modified))
+		return dir
+	}
+	expired := workspace("1", "staging", old)
+	lockedDir := workspace("1", "production", old)
+	closedDir := workspace("2", "staging", time.Now())
+	fresh := workspace("3", "staging", time.Now())
+	running := workspace("3", "production", old)
+
+	repo := models.Repo{FullName: "owner/repo", Owner: "owner", Name: "repo"}
+	getter := mocks.NewMockGithubPullGetter()
+	When(getter.GetPullRequest(context.Background(), repo, 1)).ThenReturn(&github.PullRequest{State: github.String("open")}, nil)
+	When(getter.GetPullRequest(context.Background(), repo, 2)).ThenReturn(&github.PullRequest{State: github.String("closed")}, nil)
+	When(getter.GetPullRequest(context.Background(), repo, 3)).ThenReturn(nil, errors.New("not found"))
+	locker := lmocks.NewMockLocker()
+	When(locker.List()).ThenReturn(map[string]models.ProjectLock{
+		"owner/repo/./production": {
+			Project: models.NewProject("owner/repo", "."),
+			Env:     "production",
+			Pull:    models.PullRequest{Num: 1},
+		},
+	}, nil)
+	envLock := events.NewEnvLock()
+	envLock.TryLock("owner/repo", "production", 3)
+	j := &events.WorkspaceJanitor{
+		Workspace:        &events.FileWorkspace{DataDir: tmp},
+		Locker:           locker,
+		EnvLocker:        envLock,
+		GithubPullGetter: getter,
+		TTL:              time.Hour,
+		Logger:           logging.NewNoopLogger(),
+	}
+
+	Ok(t, j.Clean())
+	for _, dir := range []string{expired, closedDir, filepath.Dir(closedDir)} {
+		_, err := os.Stat(dir)
+		Assert(t, os.IsNotExist(err), "exp %s to be deleted", dir)
+	}
+	for _, dir := range []string{lockedDir, fresh, running} {
+		_, err := os.Stat(dir)
+		Ok(t, err)
+	}
+
+	t.Log("once the command is done its workspace should be deleted")
+	envLock.Unlock("owner/repo", "production", 3)
+	Ok(t, j.Clean())
+	_, err = os.Stat(running)
+	Assert(t, os.IsNotExist(err), "exp %s to be deleted", running)
+}
+
+func TestWorkspaceJanitor_CleanNoWorkspaces(t *testing.T) {
+	t.Log("a data dir without any workspaces shouldn't be an error")
+	RegisterMockTestingT(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	locker := lmocks.NewMockLocker()
+	When(locker.List()).ThenReturn(map[string]models.ProjectLock{}, nil)
+	j := &events.WorkspaceJanitor{
+		Workspace: &events.FileWorkspace{DataDir: tmp},
+		Locker:    locker,
+		EnvLocker: events.NewEnvLock(),
+		TTL:       time.Hour,
+		Logger:    logging.NewNoopLogger(),
+	}
+	Ok(t, j.Clean())
+}
@@ -0,0 +1,109 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/models"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestFileWorkspace_EnvDataDirs(t *testing.T) {
+	t.Log("an environment with its own data dir should have its workspaces there and be deleted with the others")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	w := &events.FileWorkspace{
+		DataDir:     filepath.Join(tmp, "data"),
+		EnvDataDirs: map[string]string{"production": filepath.Join(tmp, "production")},
+	}
+	Ok(t, w.ValidateEnvDataDirs())
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1}
+	prodDir := filepath.Join(tmp, "production", "repos", "owner/repo", "1", "production")
+	stagingDir := filepath.Join(tmp, "data", "repos", "owner/repo", "1", "staging")
+	Ok(t, os.MkdirAll(prodDir, 0700))
+	Ok(t, os.MkdirAll(stagingDir, 0700))
+
+	dir, err := w.GetWorkspace(repo, pull, "production")
+	Ok(t, err)
+	Equals(t, prodDir, dir)
+	dir, err = w.GetWorkspace(repo, pull, "staging")
+	Ok(t, err)
+	Equals(t, stagingDir, dir)
+
+	Ok(t, w.Delete(repo, pull))
+	_, err = os.Stat(prodDir)
+	Assert(t, os.IsNotExist(err), "exp the production workspace to be deleted")
+	_, err = os.Stat(stagingDir)
+	Assert(t, os.IsNotExist(err), "exp the staging workspace to be deleted")
+}
+
+func TestFileWorkspace_ValidateEnvDataDirs(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	shared := filepath.Join(tmp, "shared")
+	Ok(t, os.MkdirAll(shared, 0755))
+
+	cases := []struct {
+		Description string
+		Dirs        map[string]string
+		Expected    string
+	}{
+		{
+			"relative dir",
+			map[string]string{"production": "production"},
+			`data dir for environment "production" must be an absolute path`,
+		},
+		{
+			"dir inside the data dir",
+			map[string]string{"production": filepath.Join(tmp, "data", "production")},
+			`data dir for environment "production" must be outside the data dir ` + filepath.Join(tmp, "data"),
+		},
+		{
+			"environments sharing a dir",
+			map[string]string{"production": filepath.Join(tmp, "envs"), "staging": filepath.Join(tmp, "envs", "staging")},
+			`data dirs for environments "production" and "staging" must not overlap`,
+		},
+		{
+			"dir that other users can access",
+			map[string]string{"production": shared},
+			`data dir ` + shared + ` for environment "production" must only be accessible by its owner but its mode is 0755`,
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.Description + " should be an error")
+		w := &events.FileWorkspace{DataDir: filepath.Join(tmp, "data"), EnvDataDirs: c.Dirs}
+		err := w.ValidateEnvDataDirs()
+		Assert(t, err != nil, "exp an error")
+		Equals(t, c.Expected, err.Error())
+	}
+}
+
+func TestFileWorkspace_EnvironmentsHaveSeparateWorkspaces(t *testing.T) {
+	t.Log("each environment should have its own workspace so their plans can't overwrite each other")
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	w := &events.FileWorkspace{DataDir: tmp}
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1}
+	for _, env := range []string{"production", "staging"} {
+		Ok(t, os.MkdirAll(filepath.Join(tmp, "repos", "owner/repo", "1", env, "project"), 0700))
+		dir, err := w.GetWorkspace(repo, pull, env)
+		Ok(t, err)
+		Ok(t, ioutil.WriteFile(filepath.Join(dir, "project", env+".tfplan"), []byte(env), 0600))
+	}
+
+	for _, env := range []string{"production", "staging"} {
+		dir, err := w.GetWorkspace(repo, pull, env)
+		Ok(t, err)
+		plans, err := filepath.Glob(filepath.Join(dir, "*", "*.tfplan"))
+		Ok(t, err)
+		Equals(t, []string{filepath.Join(dir, "project", env+".tfplan")}, plans)
+	}
+}
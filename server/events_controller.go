@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -33,6 +34,18 @@ type EventsController struct {
 	// SupportedVCSHosts is which VCS hosts Atlantis was configured upon
 	// startup to support.
 	SupportedVCSHosts []vcs.Host
+	// CommandLimiter limits how many commands run at the same time. If nil,
+	// there's no limit.
+	CommandLimiter *events.CommandLimiter
+	// CommandThrottle ignores commands that are duplicates of one that was
+	// just commented on the same pull request. If nil, nothing is throttled.
+	CommandThrottle *events.CommandThrottle
+	// RepoAllowlist is the repos that commands are run for. If nil, commands
+	// are run for every repo.
+	RepoAllowlist *events.RepoAllowlist
+	// VCSClient is used to comment when a command is throttled or its repo
+	// isn't allowed.
+	VCSClient vcs.ClientProxy
 }
 
 func (e *EventsController) Post(w http.ResponseWriter, r *http.Request) {
@@ -118,12 +131,25 @@ func (e *EventsController) HandleGithubCommentEvent(w http.ResponseWriter, event
 		e.respond(w, logging.Debug, http.StatusOK, "Ignoring: %s %s", err, githubReqID)
 		return
 	}
+	if !e.repoAllowed(baseRepo, pullNum, vcs.Github) {
+		e.respond(w, logging.Info, http.StatusOK, "Ignoring %s command since repo %s isn't allowlisted %s", command.Name, baseRepo.FullName, githubReqID)
+		return
+	}
+	if e.throttled(baseRepo, pullNum, command, vcs.Github) {
+		e.respond(w, logging.Info, http.StatusOK, "Ignoring duplicate %s command %s", command.Name, githubReqID)
+		return
+	}
 
 	// Respond with success and then actually execute the command asynchronously.
 	// We use a goroutine so that this function returns and the connection is
 	// closed.
+	if !e.CommandLimiter.Go(func() {
+		e.CommandRunner.ExecuteCommand(baseRepo, models.Repo{}, user, pullNum, command, vcs.Github)
+	}) {
+		e.respond(w, logging.Warn, http.StatusTooManyRequests, "Too many commands in progress, try again later %s", githubReqID)
+		return
+	}
 	fmt.Fprintln(w, "Processing...")
-	go e.CommandRunner.ExecuteCommand(baseRepo, models.Repo{}, user, pullNum, command, vcs.Github)
 }
 
 func (e *EventsController) HandleGitlabCommentEvent(w http.ResponseWriter, event gitlab.MergeCommentEvent) {
@@ -133,12 +159,25 @@ func (e *EventsController) HandleGitlabCommentEvent(w http.ResponseWriter, event
 		e.respond(w, logging.Debug, http.StatusOK, "Ignoring: %s", err)
 		return
 	}
+	if !e.repoAllowed(baseRepo, event.MergeRequest.IID, vcs.Gitlab) {
+		e.respond(w, logging.Info, http.StatusOK, "Ignoring %s command since repo %s isn't allowlisted", command.Name, baseRepo.FullName)
+		return
+	}
+	if e.throttled(baseRepo, event.MergeRequest.IID, command, vcs.Gitlab) {
+		e.respond(w, logging.Info, http.StatusOK, "Ignoring duplicate %s command", command.Name)
+		return
+	}
 
 	// Respond with success and then actually execute the command asynchronously.
 	// We use a goroutine so that this function returns and the connection is
 	// closed.
+	if !e.CommandLimiter.Go(func() {
+		e.CommandRunner.ExecuteCommand(baseRepo, headRepo, user, event.MergeRequest.IID, command, vcs.Gitlab)
+	}) {
+		e.respond(w, logging.Warn, http.StatusTooManyRequests, "Too many commands in progress, try again later")
+		return
+	}
 	fmt.Fprintln(w, "Processing...")
-	go e.CommandRunner.ExecuteCommand(baseRepo, headRepo, user, event.MergeRequest.IID, command, vcs.Gitlab)
 }
 
 // HandleGitlabMergeRequestEvent will delete any locks associated with the merge request
@@ -181,6 +220,38 @@ func (e *EventsController) HandleGithubPullRequestEvent(w http.ResponseWriter, p
 	fmt.Fprintln(w, "Pull request cleaned successfully")
 }
 
+// throttled returns true if command is a duplicate of a command that was just
+// run on the pull request. If it is, we comment on the pull request so the
+// user knows why nothing happened.
+func (e *EventsController) throttled(baseRepo models.Repo, pullNum int, command *events.Command, vcsHost vcs.Host) bool {
+	if e.CommandThrottle.Allow(baseRepo.FullName, pullNum, command) {
+		return false
+	}
+	// Only the pull request's number is needed to comment so we don't fetch
+	// the rest of it.
+	pull := models.PullRequest{Num: pullNum}
+	if err := e.VCSClient.CreateComment(context.Background(), baseRepo, pull, e.CommandThrottle.ThrottledComment(command), vcsHost); err != nil {
+		e.Logger.Warn("unable to comment that %s was throttled: %s", command.Name, err)
+	}
+	return true
+}
+
+// repoAllowed returns true if commands are run for baseRepo. If they aren't
+// and the allowlist has a comment, we comment it on the pull request the first
+// time so the user knows why nothing happened.
+func (e *EventsController) repoAllowed(baseRepo models.Repo, pullNum int, vcsHost vcs.Host) bool {
+	if e.RepoAllowlist.IsAllowed(baseRepo.FullName) {
+		return true
+	}
+	if e.RepoAllowlist.ShouldComment(baseRepo.FullName, pullNum) {
+		pull := models.PullRequest{Num: pullNum}
+		if err := e.VCSClient.CreateComment(context.Background(), baseRepo, pull, e.RepoAllowlist.Comment, vcsHost); err != nil {
+			e.Logger.Warn("unable to comment that repo %s isn't allowlisted: %s", baseRepo.FullName, err)
+		}
+	}
+	return false
+}
+
 func (e *EventsController) respond(w http.ResponseWriter, lvl logging.LogLevel, code int, format string, args ...interface{}) {
 	response := fmt.Sprintf(format, args...)
 	e.Logger.Log(lvl, response)
@@ -14,6 +14,7 @@ import (
 	"github.com/hootsuite/atlantis/server/events/mocks/matchers"
 	"github.com/hootsuite/atlantis/server/events/models"
 	"github.com/hootsuite/atlantis/server/events/vcs"
+	vcsmocks "github.com/hootsuite/atlantis/server/events/vcs/mocks"
 	"github.com/hootsuite/atlantis/server/logging"
 	"github.com/hootsuite/atlantis/server/mocks"
 	"github.com/lkysow/go-gitlab"
@@ -176,6 +177,94 @@ func TestPost_GithubCommentSuccess(t *testing.T) {
 	cr.VerifyWasCalledOnce().ExecuteCommand(baseRepo, baseRepo, user, 1, &cmd, vcs.Github)
 }
 
+func TestPost_GithubCommentTooManyCommands(t *testing.T) {
+	t.Log("when the command limit and queue are full we respond with a 429 and don't run the command")
+	e, v, _, p, cr, _ := setup(t)
+	release := make(chan struct{})
+	defer close(release)
+	e.CommandLimiter = events.NewCommandLimiter(1, 0)
+	e.CommandLimiter.Go(func() { <-release })
+	eventsReq.Header.Set(githubHeader, "issue_comment")
+	event := `{"action": "created"}`
+	When(v.Validate(eventsReq, secret)).ThenReturn([]byte(event), nil)
+	When(p.ParseGithubIssueCommentEvent(matchers.AnyPtrToGithubIssueCommentEvent())).ThenReturn(models.Repo{}, models.User{}, 1, nil)
+	When(p.DetermineCommand("", vcs.Github)).ThenReturn(&events.Command{}, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusTooManyRequests, "Too many commands in progress")
+	cr.VerifyWasCalled(Never()).ExecuteCommand(matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsUser(), AnyInt(), matchers.AnyPtrToEventsCommand(), matchers.AnyVcsHost())
+}
+
+func TestPost_GithubCommentThrottled(t *testing.T) {
+	t.Log("when the same command was just run on the pull request we comment and don't run it again")
+	e, v, _, p, cr, _ := setup(t)
+	vcsClient := vcsmocks.NewMockClientProxy()
+	e.VCSClient = vcsClient
+	e.CommandThrottle = events.NewCommandThrottle(time.Minute)
+	eventsReq.Header.Set(githubHeader, "issue_comment")
+	event := `{"action": "created"}`
+	When(v.Validate(eventsReq, secret)).ThenReturn([]byte(event), nil)
+	baseRepo := models.Repo{FullName: "owner/repo"}
+	cmd := events.Command{Name: events.Plan, Environment: "default"}
+	When(p.ParseGithubIssueCommentEvent(matchers.AnyPtrToGithubIssueCommentEvent())).ThenReturn(baseRepo, models.User{}, 1, nil)
+	When(p.DetermineCommand("", vcs.Github)).ThenReturn(&cmd, nil)
+
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusOK, "Processing...")
+	w = httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusOK, "Ignoring duplicate plan command")
+
+	time.Sleep(200 * time.Millisecond)
+	cr.VerifyWasCalledOnce().ExecuteCommand(baseRepo, models.Repo{}, models.User{}, 1, &cmd, vcs.Github)
+	vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyContextContext(), matchers.EqModelsRepo(baseRepo), matchers.EqModelsPullRequest(models.PullRequest{Num: 1}),
+		EqString("The same plan for the default environment was commented on this pull request less than 1m0s ago so this command was ignored as a duplicate."), matchers.EqVcsHost(vcs.Github))
+}
+
+func TestPost_GithubCommentRepoNotAllowlisted(t *testing.T) {
+	t.Log("when the repo isn't allowlisted we don't run the command and comment the allowlist's comment once")
+	e, v, _, p, cr, _ := setup(t)
+	vcsClient := vcsmocks.NewMockClientProxy()
+	e.VCSClient = vcsClient
+	e.RepoAllowlist, _ = events.NewRepoAllowlist([]string{"owner/allowed"}, "This repo isn't set up for Atlantis. Ask #infra to add it.")
+	eventsReq.Header.Set(githubHeader, "issue_comment")
+	event := `{"action": "created"}`
+	When(v.Validate(eventsReq, secret)).ThenReturn([]byte(event), nil)
+	baseRepo := models.Repo{FullName: "owner/repo"}
+	When(p.ParseGithubIssueCommentEvent(matchers.AnyPtrToGithubIssueCommentEvent())).ThenReturn(baseRepo, models.User{}, 1, nil)
+	When(p.DetermineCommand("", vcs.Github)).ThenReturn(&events.Command{Name: events.Plan}, nil)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		e.Post(w, eventsReq)
+		responseContains(t, w, http.StatusOK, "Ignoring plan command since repo owner/repo isn't allowlisted")
+	}
+
+	cr.VerifyWasCalled(Never()).ExecuteCommand(matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsUser(), AnyInt(), matchers.AnyPtrToEventsCommand(), matchers.AnyVcsHost())
+	vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyContextContext(), matchers.EqModelsRepo(baseRepo), matchers.EqModelsPullRequest(models.PullRequest{Num: 1}),
+		EqString("This repo isn't set up for Atlantis. Ask #infra to add it."), matchers.EqVcsHost(vcs.Github))
+}
+
+func TestPost_GithubCommentRepoNotAllowlistedSilent(t *testing.T) {
+	t.Log("when the repo isn't allowlisted and there's no comment we ignore the command silently")
+	e, v, _, p, cr, _ := setup(t)
+	vcsClient := vcsmocks.NewMockClientProxy()
+	e.VCSClient = vcsClient
+	e.RepoAllowlist, _ = events.NewRepoAllowlist([]string{"owner/allowed"}, "")
+	eventsReq.Header.Set(githubHeader, "issue_comment")
+	event := `{"action": "created"}`
+	When(v.Validate(eventsReq, secret)).ThenReturn([]byte(event), nil)
+	When(p.ParseGithubIssueCommentEvent(matchers.AnyPtrToGithubIssueCommentEvent())).ThenReturn(models.Repo{FullName: "owner/repo"}, models.User{}, 1, nil)
+	When(p.DetermineCommand("", vcs.Github)).ThenReturn(&events.Command{Name: events.Plan}, nil)
+
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusOK, "isn't allowlisted")
+	cr.VerifyWasCalled(Never()).ExecuteCommand(matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsUser(), AnyInt(), matchers.AnyPtrToEventsCommand(), matchers.AnyVcsHost())
+	vcsClient.VerifyWasCalled(Never()).CreateComment(matchers.AnyContextContext(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString(), matchers.AnyVcsHost())
+}
+
 func TestPost_GithubPullRequestNotClosed(t *testing.T) {
 	t.Log("when the event is a github pull reuqest but it's not a closed event we ignore it")
 	e, v, _, _, _, _ := setup(t)
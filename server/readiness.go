@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/events/vcs"
+	"github.com/pkg/errors"
+)
+
+// Default paths of the health and readiness endpoints.
+const (
+	DefaultHealthzPath = "/healthz"
+	DefaultReadyzPath  = "/readyz"
+)
+
+// readinessRetryInterval is how long we wait to verify the server's
+// dependencies again after they failed.
+const readinessRetryInterval = 30 * time.Second
+
+// readiness verifies that the server can run commands: that its VCS
+// credentials work and that it can write to its workspace dirs. Once they've
+// been verified they aren't checked again so readiness probes don't use up
+// the VCS hosts' rate limits.
+type readiness struct {
+	workspaceDirs []string
+	authCheckers  map[vcs.Host]authChecker
+	mutex         sync.Mutex
+	ready         bool
+}
+
+// check verifies the dependencies unless they've already been verified. The
+// mutex isn't held while checking so readiness probes don't wait for it.
+func (r *readiness) check() error {
+	if r.isReady() {
+		return nil
+	}
+	for _, dir := range r.workspaceDirs {
+		f, err := ioutil.TempFile(dir, ".readyz")
+		if err != nil {
+			return errors.Wrapf(err, "checking that %s is writable", dir)
+		}
+		f.Close()           // nolint: errcheck
+		os.Remove(f.Name()) // nolint: errcheck
+	}
+	for host, checker := range r.authCheckers {
+		ctx, cancel := context.WithTimeout(context.Background(), vcsAuthCheckTimeout)
+		err := checker.CheckAuth(ctx)
+		cancel()
+		if err != nil {
+			return errors.Wrapf(err, "checking %s credentials", host)
+		}
+	}
+	r.mutex.Lock()
+	r.ready = true
+	r.mutex.Unlock()
+	return nil
+}
+
+func (r *readiness) isReady() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.ready
+}
+
+// CheckReadiness verifies the server's dependencies so /readyz succeeds once
+// they work. Start calls it until it succeeds.
+func (s *Server) CheckReadiness() error {
+	return s.readiness.check()
+}
+
+// checkReadinessUntilReady calls CheckReadiness until it succeeds, logging
+// each failure.
+func (s *Server) checkReadinessUntilReady() {
+	for {
+		err := s.CheckReadiness()
+		if err == nil {
+			s.Logger.Info("server is ready")
+			return
+		}
+		s.Logger.Warn("server isn't ready, checking again in %s: %s", readinessRetryInterval, err)
+		time.Sleep(readinessRetryInterval)
+	}
+}
+
+// healthResponse is the body of the health and readiness endpoints.
+type healthResponse struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+}
+
+// Healthz responds with a 200 as long as the server is serving requests.
+func (s *Server) Healthz(w http.ResponseWriter, _ *http.Request) {
+	s.writeHealth(w, http.StatusOK, "ok")
+}
+
+// Readyz responds with a 200 once CheckReadiness has succeeded and a 503
+// until then. Why it isn't ready is logged rather than shown since the
+// endpoint isn't authenticated.
+func (s *Server) Readyz(w http.ResponseWriter, _ *http.Request) {
+	if !s.readiness.isReady() {
+		s.writeHealth(w, http.StatusServiceUnavailable, "not ready")
+		return
+	}
+	s.writeHealth(w, http.StatusOK, "ready")
+}
+
+func (s *Server) writeHealth(w http.ResponseWriter, code int, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(healthResponse{Status: status, Version: s.Build.Version}) // nolint: errcheck
+}
+
+// workspaceDirs returns the dirs that workspaces are cloned into, the data
+// dir and every environment's own data dir, in a stable order.
+func workspaceDirs(config Config) []string {
+	dirs := []string{config.DataDir}
+	var envDirs []string
+	for _, dir := range config.EnvDataDirs {
+		envDirs = append(envDirs, dir)
+	}
+	sort.Strings(envDirs)
+	return append(dirs, envDirs...)
+}
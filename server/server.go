@@ -3,27 +3,41 @@
 package server
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"flag"
 
 	"github.com/elazarl/go-bindata-assetfs"
 	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-version"
+	"github.com/hootsuite/atlantis/server/egress"
 	"github.com/hootsuite/atlantis/server/events"
+	"github.com/hootsuite/atlantis/server/events/audit"
+	"github.com/hootsuite/atlantis/server/events/history"
+	"github.com/hootsuite/atlantis/server/events/jira"
 	"github.com/hootsuite/atlantis/server/events/locking"
 	"github.com/hootsuite/atlantis/server/events/locking/boltdb"
 	"github.com/hootsuite/atlantis/server/events/run"
 	"github.com/hootsuite/atlantis/server/events/terraform"
+	"github.com/hootsuite/atlantis/server/events/tfc"
 	"github.com/hootsuite/atlantis/server/events/vcs"
 	"github.com/hootsuite/atlantis/server/events/webhooks"
 	"github.com/hootsuite/atlantis/server/logging"
 	"github.com/hootsuite/atlantis/server/static"
-	"github.com/lkysow/go-gitlab"
+	"github.com/hootsuite/atlantis/server/tracing"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli"
 	"github.com/urfave/negroni"
@@ -34,83 +48,288 @@ const LockRouteName = "lock-detail"
 // Server runs the Atlantis web server. It's used for webhook requests and the
 // Atlantis UI.
 type Server struct {
-	Router             *mux.Router
-	Port               int
+	Router *mux.Router
+	Port   int
+	// MetricsPort, if set, is the port /metrics is served on instead of Port.
+	MetricsPort        int
 	CommandHandler     *events.CommandHandler
+	ApplyExecutor      *events.ApplyExecutor
 	Logger             *logging.SimpleLogger
 	Locker             locking.Locker
 	AtlantisURL        string
 	EventsController   *EventsController
 	IndexTemplate      TemplateWriter
 	LockDetailTemplate TemplateWriter
+	Webhooks           *webhooks.MultiWebhookSender
+	CommandLimiter     *events.CommandLimiter
+	// PlanExporter serves exported plans. It's nil if exporting is disabled.
+	PlanExporter *events.PlanExporter
+	// CommandMetrics records the results of plans and applies.
+	CommandMetrics *events.CommandMetrics
+	// WorkspaceJanitor deletes stale workspaces. It's nil if --workspace-ttl
+	// isn't set.
+	WorkspaceJanitor *events.WorkspaceJanitor
+	// Build identifies the build of Atlantis that's running.
+	Build       BuildInfo
+	HealthzPath string
+	ReadyzPath  string
+	// AdminTokens maps the name of each admin to the bearer token they
+	// authenticate to the admin endpoints with, ex. to freeze applies. If
+	// it's empty, the admin endpoints are disabled.
+	AdminTokens map[string]string
+	readiness   *readiness
 }
 
 // Config configures Server.
 // The mapstructure tags correspond to flags in cmd/server.go and are used when
 // the config is parsed from a YAML file.
 type Config struct {
-	AtlantisURL             string          `mapstructure:"atlantis-url"`
-	ApprovalURL             string          `mapstructure:"approval-url"`
-	DataDir                 string          `mapstructure:"data-dir"`
-	GithubHostname          string          `mapstructure:"gh-hostname"`
-	GithubToken             string          `mapstructure:"gh-token"`
-	GithubUser              string          `mapstructure:"gh-user"`
-	GithubWebHookSecret     string          `mapstructure:"gh-webhook-secret"`
-	GitlabHostname          string          `mapstructure:"gitlab-hostname"`
-	GitlabToken             string          `mapstructure:"gitlab-token"`
-	GitlabUser              string          `mapstructure:"gitlab-user"`
-	GitlabWebHookSecret     string          `mapstructure:"gitlab-webhook-secret"`
-	LogLevel                string          `mapstructure:"log-level"`
-	Port                    int             `mapstructure:"port"`
-	RequireApproval         bool            `mapstructure:"require-approval"`
-	RequireExternalApproval bool            `mapstructure:"require-external-approval"`
-	SlackToken              string          `mapstructure:"slack-token"`
-	Webhooks                []WebhookConfig `mapstructure:"webhooks"`
-	GitflowEnvDir           string          `mapstructure:"gitflow-environment-dir"`
-	GitflowEnvBranchMapping []string        `mapstructure:"gitflow-environment-branch-map"`
-	EnvDetectionWorkflow    string          `mapstructure:"environment-detection-workflow"`
+	AdminTokens               map[string]string `mapstructure:"admin-tokens"`
+	AtlantisURL               string            `mapstructure:"atlantis-url"`
+	AuditLogPath              string            `mapstructure:"audit-log-path"`
+	AllowFmtPush              bool              `mapstructure:"allow-fmt-push"`
+	ApplyFailFast             bool              `mapstructure:"apply-fail-fast"`
+	AllowedEgressHosts        []string          `mapstructure:"allowed-egress-hosts"`
+	AllowedRepos              []string          `mapstructure:"allowed-repos"`
+	ApprovalCACert            string            `mapstructure:"approval-ca-cert"`
+	ApprovalClientCert        string            `mapstructure:"approval-client-cert"`
+	ApprovalClientKey         string            `mapstructure:"approval-client-key"`
+	ApprovalJWTPublicKey      string            `mapstructure:"approval-jwt-public-key"`
+	ApprovalSigningSecret     string            `mapstructure:"approval-signing-secret"`
+	ApprovalURLs              []string          `mapstructure:"approval-url"`
+	ApplyRoleARN              string            `mapstructure:"apply-role-arn"`
+	ApplyRollupStatus         bool              `mapstructure:"apply-rollup-status"`
+	AWSAccounts               map[string]string `mapstructure:"aws-accounts"`
+	AWSAssumeRoleARN          string            `mapstructure:"aws-assume-role-arn"`
+	AWSAssumeRoles            map[string]string `mapstructure:"aws-assume-roles"`
+	AWSCredentialsPath        string            `mapstructure:"aws-credentials-path"`
+	AWSProfile                string            `mapstructure:"aws-profile"`
+	CommentFormat             string            `mapstructure:"comment-format"`
+	DataDir                   string            `mapstructure:"data-dir"`
+	DestroyConfirmationEnvs   []string          `mapstructure:"destroy-confirmation-envs"`
+	DestroyWarning            bool              `mapstructure:"destroy-warning"`
+	DestroyWarningIgnoreTypes []string          `mapstructure:"destroy-warning-ignore-types"`
+	ForkPolicy                string            `mapstructure:"fork-policy"`
+	GithubAppID               int               `mapstructure:"gh-app-id"`
+	GithubAppInstallationID   int               `mapstructure:"gh-app-installation-id"`
+	GithubAppKeyFile          string            `mapstructure:"gh-app-key-file"`
+	GithubCACert              string            `mapstructure:"gh-ca-cert"`
+	GithubHostname            string            `mapstructure:"gh-hostname"`
+	GithubRateLimitWait       time.Duration     `mapstructure:"gh-rate-limit-wait"`
+	GithubToken               string            `mapstructure:"gh-token"`
+	GithubUser                string            `mapstructure:"gh-user"`
+	GithubWebHookSecret       string            `mapstructure:"gh-webhook-secret"`
+	GitlabCACert              string            `mapstructure:"gitlab-ca-cert"`
+	GitlabHostname            string            `mapstructure:"gitlab-hostname"`
+	GitlabToken               string            `mapstructure:"gitlab-token"`
+	GitlabUser                string            `mapstructure:"gitlab-user"`
+	GitlabWebHookSecret       string            `mapstructure:"gitlab-webhook-secret"`
+	HealthzPath               string            `mapstructure:"healthz-path"`
+	JiraToken                 string            `mapstructure:"jira-token"`
+	JiraTransitions           map[string]string `mapstructure:"jira-transitions"`
+	JiraURL                   string            `mapstructure:"jira-url"`
+	JiraUser                  string            `mapstructure:"jira-user"`
+	LogLevel                  string            `mapstructure:"log-level"`
+	MaxConcurrentCommands     int               `mapstructure:"max-concurrent-commands"`
+	MaxQueuedCommands         int               `mapstructure:"max-queued-commands"`
+	ParallelApplyLimit        int               `mapstructure:"parallel-apply-limit"`
+	MaxProjectsPerCommand     int               `mapstructure:"max-projects-per-command"`
+	RepoMaxProjects           map[string]int    `mapstructure:"repo-max-projects-per-command"`
+	TerraformDistribution     string            `mapstructure:"terraform-distribution"`
+	TerraformNotFoundMessage  string            `mapstructure:"terraform-not-found-message"`
+	TerragruntEnvironmentDirs map[string]string `mapstructure:"terragrunt-environment-dirs"`
+	TFCAddress                string            `mapstructure:"tfc-address"`
+	TFCOrganization           string            `mapstructure:"tfc-organization"`
+	TFCToken                  string            `mapstructure:"tfc-token"`
+	TFCWorkspaces             map[string]string `mapstructure:"tfc-workspaces"`
+	OTLPEndpoint              string            `mapstructure:"otlp-endpoint"`
+	PlanDependents            bool              `mapstructure:"plan-dependents"`
+	PlanRoleARN               string            `mapstructure:"plan-role-arn"`
+	Port                      int               `mapstructure:"port"`
+	MetricsPort               int               `mapstructure:"metrics-port"`
+	StartupVCSCheck           string            `mapstructure:"startup-vcs-check"`
+	PreviousComments          string            `mapstructure:"previous-comments"`
+	ReadyzPath                string            `mapstructure:"readyz-path"`
+	RepoAllowlist             []string          `mapstructure:"repo-allowlist"`
+	RepoAllowlistComment      string            `mapstructure:"repo-allowlist-comment"`
+	RequireApproval           bool              `mapstructure:"require-approval"`
+	RequireExternalApproval   bool              `mapstructure:"require-external-approval"`
+	RequiredApprovers         []string          `mapstructure:"required-approvers"`
+	NoSelfApproval            bool              `mapstructure:"no-self-approval"`
+	RequiredTerraformVersions map[string]string `mapstructure:"required-terraform-versions"`
+	ApplyFreezeWindows        map[string]string `mapstructure:"apply-freeze-windows"`
+	ChangeWindows             map[string]string `mapstructure:"change-windows"`
+	EnvironmentApprovals      map[string]string `mapstructure:"environment-approvals"`
+	CommandThrottleWindow     time.Duration     `mapstructure:"command-throttle-window"`
+	EmergencyApplyUsers       []string          `mapstructure:"emergency-apply-users"`
+	ExternalApprovalQuorum    int               `mapstructure:"external-approval-quorum"`
+	ExternalApprovalRetries   int               `mapstructure:"external-approval-retries"`
+	ExternalApprovalTimeout   time.Duration     `mapstructure:"external-approval-timeout"`
+	FmtPushUsers              []string          `mapstructure:"fmt-push-users"`
+	MaxPlanAge                time.Duration     `mapstructure:"max-plan-age"`
+	PlanEncryptionKey         string            `mapstructure:"plan-encryption-key"`
+	PlanExportTTL             time.Duration     `mapstructure:"plan-export-ttl"`
+	PlanExportUsers           []string          `mapstructure:"plan-export-users"`
+	SecretVarFiles            map[string]string `mapstructure:"secret-var-files"`
+	SlackChannel              string            `mapstructure:"slack-channel"`
+	SlackToken                string            `mapstructure:"slack-token"`
+	Webhooks                  []WebhookConfig   `mapstructure:"webhooks"`
+	WebhookConcurrency        int               `mapstructure:"webhook-concurrency"`
+	WebhookSendTimeout        time.Duration     `mapstructure:"webhook-send-timeout"`
+	WorkspaceTTL              time.Duration     `mapstructure:"workspace-ttl"`
+	GitflowEnvDir             string            `mapstructure:"gitflow-environment-dir"`
+	GitflowEnvBranchMapping   []string          `mapstructure:"gitflow-environment-branch-map"`
+	EnvDetectionWorkflow      string            `mapstructure:"environment-detection-workflow"`
+	EnvDataDirs               map[string]string `mapstructure:"environment-data-dirs"`
+	// Version, Commit and BuildDate aren't flags. They're set from the build
+	// of the binary.
+	Version   string `mapstructure:"version"`
+	Commit    string `mapstructure:"commit"`
+	BuildDate string `mapstructure:"build-date"`
 }
 
 type WebhookConfig struct {
 	Event          string `mapstructure:"event"`
 	WorkspaceRegex string `mapstructure:"workspace-regex"`
 	Kind           string `mapstructure:"kind"`
+	// Filters for any kind
+	ExcludeWorkspaceRegex string `mapstructure:"exclude-workspace-regex"`
+	FailuresOnly          bool   `mapstructure:"failures-only"`
 	// Slack specific
 	Channel string `mapstructure:"channel"`
+	// Teams and HTTP specific
+	URL string `mapstructure:"url"`
+	// Teams specific
+	Template string `mapstructure:"template"`
+	// HTTP specific
+	Secret string `mapstructure:"secret"`
+}
+
+// RedactedValue replaces the secrets in a redacted config.
+const RedactedValue = "<redacted>"
+
+// Redacted returns a copy of c with its tokens, admin tokens, plan encryption key, webhook and
+// approval signing secrets and webhook URLs, which have a token in them, replaced by RedactedValue
+// so it can be printed. Secrets that aren't set are left empty so it's clear they aren't.
+func (c Config) Redacted() Config {
+	redact := func(s *string) {
+		if *s != "" {
+			*s = RedactedValue
+		}
+	}
+	redact(&c.ApprovalSigningSecret)
+	redact(&c.GithubToken)
+	redact(&c.GithubWebHookSecret)
+	redact(&c.GitlabToken)
+	redact(&c.GitlabWebHookSecret)
+	redact(&c.JiraToken)
+	redact(&c.PlanEncryptionKey)
+	redact(&c.SlackToken)
+	redact(&c.TFCToken)
+	if c.AdminTokens != nil {
+		adminTokens := make(map[string]string, len(c.AdminTokens))
+		for name, token := range c.AdminTokens {
+			redact(&token)
+			adminTokens[name] = token
+		}
+		c.AdminTokens = adminTokens
+	}
+	c.Webhooks = append([]WebhookConfig(nil), c.Webhooks...)
+	for i := range c.Webhooks {
+		redact(&c.Webhooks[i].URL)
+		redact(&c.Webhooks[i].Secret)
+	}
+	return c
+}
+
+// String returns the config with its secrets redacted so printing or
+// logging it can't leak them.
+func (c Config) String() string {
+	// plainConfig doesn't have this method so formatting it doesn't recurse.
+	type plainConfig Config
+	return fmt.Sprintf("%+v", plainConfig(c.Redacted()))
+}
+
+// GoString is String so formatting the config with %#v, ex. in a test
+// failure, redacts its secrets too.
+func (c Config) GoString() string {
+	return c.String()
+}
+
+// authChecker is implemented by the VCS clients that can check their
+// credentials.
+type authChecker interface {
+	CheckAuth(ctx context.Context) error
+}
+
+// vcsAuthCheckTimeout is how long we wait for each VCS host when checking
+// credentials on startup.
+const vcsAuthCheckTimeout = 10 * time.Second
+
+// checkVCSAuth checks the credentials for each configured VCS host. If mode
+// is "fail" it returns an error for the first host that fails, if it's "warn"
+// it only logs. Any other mode skips the check.
+func checkVCSAuth(mode string, checkers map[vcs.Host]authChecker, logger *logging.SimpleLogger) error {
+	if mode != "fail" && mode != "warn" {
+		return nil
+	}
+	for host, checker := range checkers {
+		ctx, cancel := context.WithTimeout(context.Background(), vcsAuthCheckTimeout)
+		err := checker.CheckAuth(ctx)
+		cancel()
+		if err == nil {
+			continue
+		}
+		if mode == "fail" {
+			return errors.Wrapf(err, "checking %s credentials", host)
+		}
+		logger.Warn("checking %s credentials: %s", host, err)
+	}
+	return nil
 }
 
 func NewServer(config Config) (*Server, error) {
 	var supportedVCSHosts []vcs.Host
 	var githubClient *vcs.GithubClient
 	var gitlabClient *vcs.GitlabClient
-	if config.GithubUser != "" {
+	var githubApp *vcs.GithubAppTransport
+	if config.GithubAppID != 0 {
+		pemKey, err := ioutil.ReadFile(config.GithubAppKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading gh-app-key-file")
+		}
+		githubApp, err = vcs.NewGithubAppTransport(config.GithubHostname, config.GithubAppID, config.GithubAppInstallationID, pemKey, config.GithubCACert)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating GitHub App client")
+		}
 		supportedVCSHosts = append(supportedVCSHosts, vcs.Github)
-		var err error
-		githubClient, err = vcs.NewGithubClient(config.GithubHostname, config.GithubUser, config.GithubToken)
+		githubClient, err = vcs.NewGithubAppClient(config.GithubHostname, githubApp)
 		if err != nil {
 			return nil, err
 		}
+	} else if config.GithubUser != "" {
+		supportedVCSHosts = append(supportedVCSHosts, vcs.Github)
+		var err error
+		githubClient, err = vcs.NewGithubClient(config.GithubHostname, config.GithubUser, config.GithubToken, config.GithubCACert)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating GitHub client")
+		}
 	}
 	if config.GitlabUser != "" {
 		supportedVCSHosts = append(supportedVCSHosts, vcs.Gitlab)
-		gitlabClient = &vcs.GitlabClient{
-			Client: gitlab.NewClient(nil, config.GitlabToken),
-		}
-	}
-	var webhooksConfig []webhooks.Config
-	for _, c := range config.Webhooks {
-		config := webhooks.Config{
-			Channel:        c.Channel,
-			Event:          c.Event,
-			Kind:           c.Kind,
-			WorkspaceRegex: c.WorkspaceRegex,
+		var err error
+		gitlabClient, err = vcs.NewGitlabClient(config.GitlabHostname, config.GitlabToken, config.GitlabCACert)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating GitLab client")
 		}
-		webhooksConfig = append(webhooksConfig, config)
 	}
-	webhooksManager, err := webhooks.NewMultiWebhookSender(webhooksConfig, webhooks.NewSlackClient(config.SlackToken))
+	webhooksManager, err := newWebhooks(config)
 	if err != nil {
-		return nil, errors.Wrap(err, "initializing webhooks")
+		return nil, err
 	}
+	webhooksManager.Concurrency = config.WebhookConcurrency
+	webhooksManager.SendTimeout = config.WebhookSendTimeout
+	webhooksManager.DeadLetters = webhooks.NewDeadLetterLog(config.DataDir)
 	vcsClient := vcs.NewDefaultClientProxy(githubClient, gitlabClient)
 	commitStatusUpdater := &events.DefaultCommitStatusUpdater{Client: vcsClient}
 	terraformClient, err := terraform.NewClient()
@@ -120,7 +339,10 @@ func NewServer(config Config) (*Server, error) {
 	if err != nil && flag.Lookup("test.v") == nil {
 		return nil, errors.Wrap(err, "initializing terraform")
 	}
-	markdownRenderer := &events.MarkdownRenderer{}
+	if terraformClient != nil {
+		terraformClient.NotFoundMessage = config.TerraformNotFoundMessage
+	}
+	markdownRenderer := &events.MarkdownRenderer{Format: events.CommentFormat(config.CommentFormat)}
 	boltdb, err := boltdb.New(config.DataDir)
 	if err != nil {
 		return nil, err
@@ -130,52 +352,237 @@ func NewServer(config Config) (*Server, error) {
 	configReader := &events.ProjectConfigManager{}
 	concurrentRunLocker := events.NewEnvLock()
 	workspace := &events.FileWorkspace{
-		DataDir: config.DataDir,
+		DataDir:     config.DataDir,
+		EnvDataDirs: config.EnvDataDirs,
+	}
+	if err := workspace.ValidateEnvDataDirs(); err != nil {
+		return nil, errors.Wrap(err, "validating environment-data-dirs")
+	}
+	requiredVersions := make(map[string]version.Constraints)
+	for env, c := range config.RequiredTerraformVersions {
+		constraint, err := version.NewConstraint(c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing required terraform version for environment %q", env)
+		}
+		requiredVersions[env] = constraint
+	}
+	applyPolicy, err := newApplyPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	secretVarFiles := events.SecretVarFiles(config.SecretVarFiles)
+	if err := secretVarFiles.Validate(config.DataDir); err != nil {
+		return nil, err
+	}
+	for _, dir := range config.EnvDataDirs {
+		if err := secretVarFiles.Validate(dir); err != nil {
+			return nil, err
+		}
 	}
 	projectPreExecute := &events.ProjectPreExecute{
-		Locker:       lockingClient,
-		Run:          run,
-		ConfigReader: configReader,
-		Terraform:    terraformClient,
+		Locker:           lockingClient,
+		Run:              run,
+		ConfigReader:     configReader,
+		Terraform:        terraformClient,
+		RequiredVersions: requiredVersions,
+		Distribution:     events.TerraformDistribution(config.TerraformDistribution),
 	}
+	commandMetrics := events.NewCommandMetrics()
 	applyExecutor := &events.ApplyExecutor{
-		VCSClient:               vcsClient,
-		Terraform:               terraformClient,
-		RequireApproval:         config.RequireApproval,
-		RequireExternalApproval: config.RequireExternalApproval,
-		ApprovalURL:             config.ApprovalURL,
-		Run:                     run,
-		Workspace:               workspace,
-		ProjectPreExecute:       projectPreExecute,
-		Webhooks:                webhooksManager,
+		VCSClient:          vcsClient,
+		Terraform:          terraformClient,
+		Run:                run,
+		Workspace:          workspace,
+		ProjectPreExecute:  projectPreExecute,
+		Webhooks:           webhooksManager,
+		ApprovalMetrics:    events.NewApprovalMetrics(),
+		CommandMetrics:     commandMetrics,
+		ParallelApplyLimit: config.ParallelApplyLimit,
+		ApplyFailFast:      config.ApplyFailFast,
+		MaxPlanAge:         config.MaxPlanAge,
+	}
+	applyExecutor.SetPolicy(applyPolicy)
+	applyExecutor.Freeze, err = events.NewApplyFreeze(config.DataDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading apply freeze")
+	}
+	if config.AuditLogPath != "" {
+		applyExecutor.AuditLog, err = audit.NewLog(config.AuditLogPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing audit log")
+		}
+	}
+	if len(config.AWSAccounts) > 0 {
+		for env, id := range config.AWSAccounts {
+			if !events.ValidAWSAccountID(id) {
+				return nil, fmt.Errorf("invalid AWS account ID %q for the %s environment in aws-accounts: must be 12 digits", id, env)
+			}
+		}
+		applyExecutor.AccountGuard = &events.AWSAccountGuard{
+			Accounts: config.AWSAccounts,
+			Identity: events.AWSCLICallerIdentity{},
+		}
+	}
+	if config.JiraURL != "" {
+		applyExecutor.Jira = jira.NewClient(config.JiraURL, config.JiraUser, config.JiraToken)
+		applyExecutor.JiraTransitions = config.JiraTransitions
 	}
 	wflow := events.ModifiedFilesWorkflow
+	var envDetector events.EnvDetector = &events.ModifiedFilesDetector{
+		VCSClient:     vcsClient,
+		ProjectFinder: &events.ProjectFinder{},
+	}
 	if config.EnvDetectionWorkflow == string(events.GitFlowWorkflow) {
 		wflow = events.GitFlowWorkflow
+		gitflowDetector, err := events.NewGitflowDetector(config.GitflowEnvDir, config.GitflowEnvBranchMapping)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing gitflow-environment-branch-map")
+		}
+		envDetector = gitflowDetector
 	}
 
 	planExecutor := &events.PlanExecutor{
-		VCSClient:               vcsClient,
-		Terraform:               terraformClient,
-		Run:                     run,
-		Workspace:               workspace,
-		ProjectPreExecute:       projectPreExecute,
-		Locker:                  lockingClient,
-		ProjectFinder:           &events.ProjectFinder{},
-		ConfiguredWorkflow:      wflow,
-		GitflowEnvDir:           config.GitflowEnvDir,
-		GitflowEnvBranchMapping: config.GitflowEnvBranchMapping,
+		CommandMetrics:     commandMetrics,
+		VCSClient:          vcsClient,
+		Terraform:          terraformClient,
+		Run:                run,
+		Workspace:          workspace,
+		ProjectPreExecute:  projectPreExecute,
+		ConfigReader:       configReader,
+		Locker:             lockingClient,
+		EnvDetector:        envDetector,
+		ConfiguredWorkflow: wflow,
+		SecretVarFiles:     secretVarFiles,
+	}
+	if config.TFCToken != "" {
+		tfcClient := tfc.NewClient(config.TFCAddress, config.TFCToken, config.TFCOrganization)
+		planExecutor.TFC = tfcClient
+		planExecutor.TFCWorkspaces = config.TFCWorkspaces
+		applyExecutor.TFC = tfcClient
+		applyExecutor.TFCWorkspaces = config.TFCWorkspaces
+	}
+	projectLimit, err := events.NewProjectLimit(config.MaxProjectsPerCommand, config.RepoMaxProjects)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing repo-max-projects-per-command")
+	}
+	planExecutor.ProjectLimit = projectLimit
+	applyExecutor.ProjectLimit = projectLimit
+	if config.DestroyWarning {
+		planExecutor.DestroyWarning, err = events.NewDestroyWarning(config.DestroyWarningIgnoreTypes)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing destroy-warning-ignore-types")
+		}
+		applyExecutor.DestroyWarning = planExecutor.DestroyWarning
+	}
+	if config.PlanDependents {
+		planExecutor.DependentFinder = &events.DependentProjectFinder{ConfigReader: configReader}
+	}
+	if config.TerraformDistribution == string(events.TerraformDistributionTerragrunt) {
+		for env, dir := range config.TerragruntEnvironmentDirs {
+			if filepath.IsAbs(dir) || strings.HasPrefix(filepath.Clean(dir), "..") {
+				return nil, fmt.Errorf("invalid directory %q for the %s environment in terragrunt-environment-dirs: must be relative to the repo root and inside it", dir, env)
+			}
+		}
+		terragrunt := &events.Terragrunt{EnvironmentDirs: config.TerragruntEnvironmentDirs}
+		planExecutor.Terragrunt = terragrunt
+		applyExecutor.Terragrunt = terragrunt
+	} else if len(config.TerragruntEnvironmentDirs) > 0 {
+		return nil, errors.New("terragrunt-environment-dirs requires terraform-distribution to be terragrunt")
+	}
+	var planExporter *events.PlanExporter
+	if len(config.PlanExportUsers) > 0 {
+		planExporter, err = events.NewPlanExporter(config.DataDir, config.PlanExportUsers, config.PlanExportTTL, config.AtlantisURL)
+		if err != nil {
+			return nil, err
+		}
+		planExecutor.Exporter = planExporter
+	}
+	if config.PlanEncryptionKey != "" {
+		planEncryptor, err := events.NewPlanEncryptor(config.PlanEncryptionKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing plan-encryption-key")
+		}
+		planExecutor.PlanEncryptor = planEncryptor
+		applyExecutor.PlanEncryptor = planEncryptor
+		if planExporter != nil {
+			planExporter.Encryptor = planEncryptor
+		}
 	}
 	helpExecutor := &events.HelpExecutor{}
+	runHistory := history.NewLog(config.DataDir)
+	historyExecutor := &events.HistoryExecutor{History: runHistory}
+	versionCheckExecutor := &events.VersionCheckExecutor{
+		Workspace:         workspace,
+		ProjectDeterminer: planExecutor,
+		ProjectPreExecute: projectPreExecute,
+	}
+	fmtExecutor := &events.FmtExecutor{
+		Workspace:         workspace,
+		ProjectDeterminer: planExecutor,
+		ProjectPreExecute: projectPreExecute,
+		CommitPusher:      &events.GitCommitPusher{},
+		AllowPush:         config.AllowFmtPush,
+		PushUsers:         config.FmtPushUsers,
+		ApplyPolicy:       applyExecutor,
+	}
+	validateExecutor := &events.ValidateExecutor{
+		Workspace:         workspace,
+		ProjectDeterminer: planExecutor,
+		ProjectPreExecute: projectPreExecute,
+	}
 	pullClosedExecutor := &events.PullClosedExecutor{
 		VCSClient: vcsClient,
 		Locker:    lockingClient,
 		Workspace: workspace,
 	}
+	if config.ApplyRollupStatus {
+		applyRollup := events.NewApplyRollup(config.DataDir, vcsClient, planExecutor)
+		planExecutor.ApplyRollup = applyRollup
+		applyExecutor.ApplyRollup = applyRollup
+		pullClosedExecutor.ApplyRollup = applyRollup
+	}
 	logger := logging.NewSimpleLogger("server", nil, false, logging.ToLogLevel(config.LogLevel))
+	logger.Debug("starting with config %s", config)
+	if freeze := applyExecutor.Freeze.Current(); freeze != nil {
+		logger.Warn("applies have been frozen since %s: %s", freeze.Time.Format(time.RFC3339), freeze.Reason)
+	}
+	var tracer *tracing.Tracer
+	if config.OTLPEndpoint != "" {
+		tracer = tracing.NewTracer(config.OTLPEndpoint, logger)
+	}
+	authCheckers := make(map[vcs.Host]authChecker)
+	if githubClient != nil {
+		githubClient.RateLimitWait = config.GithubRateLimitWait
+		githubClient.Logger = logger
+		authCheckers[vcs.Github] = githubClient
+	}
+	if gitlabClient != nil {
+		authCheckers[vcs.Gitlab] = gitlabClient
+	}
+	if err := checkVCSAuth(config.StartupVCSCheck, authCheckers, logger); err != nil {
+		return nil, err
+	}
+	var workspaceJanitor *events.WorkspaceJanitor
+	if config.WorkspaceTTL > 0 {
+		workspaceJanitor = &events.WorkspaceJanitor{
+			Workspace: workspace,
+			Locker:    lockingClient,
+			EnvLocker: concurrentRunLocker,
+			TTL:       config.WorkspaceTTL,
+			Logger:    logger,
+		}
+		// Checked separately so a host that isn't configured stays nil.
+		if githubClient != nil {
+			workspaceJanitor.GithubPullGetter = githubClient
+		}
+		if gitlabClient != nil {
+			workspaceJanitor.GitlabMergeRequestGetter = gitlabClient
+		}
+	}
 	eventParser := &events.EventParser{
 		GithubUser:  config.GithubUser,
 		GithubToken: config.GithubToken,
+		GithubApp:   githubApp,
 		GitlabUser:  config.GitlabUser,
 		GitlabToken: config.GitlabToken,
 	}
@@ -183,6 +590,10 @@ func NewServer(config Config) (*Server, error) {
 		ApplyExecutor:            applyExecutor,
 		PlanExecutor:             planExecutor,
 		HelpExecutor:             helpExecutor,
+		VersionCheckExecutor:     versionCheckExecutor,
+		FmtExecutor:              fmtExecutor,
+		ValidateExecutor:         validateExecutor,
+		HistoryExecutor:          historyExecutor,
 		LockURLGenerator:         planExecutor,
 		EventParser:              eventParser,
 		VCSClient:                vcsClient,
@@ -193,6 +604,24 @@ func NewServer(config Config) (*Server, error) {
 		MarkdownRenderer:         markdownRenderer,
 		Logger:                   logger,
 		ConfiguredWorkflow:       wflow,
+		CommentRetention:         events.CommentRetention(config.PreviousComments),
+		GithubUser:               config.GithubUser,
+		GithubApp:                githubApp,
+		GitlabUser:               config.GitlabUser,
+		ForkPolicy:               events.ForkPolicy(config.ForkPolicy),
+		PlanRoleARN:              config.PlanRoleARN,
+		ApplyRoleARN:             config.ApplyRoleARN,
+		AWSAssumeRoleARN:         config.AWSAssumeRoleARN,
+		AWSAssumeRoles:           config.AWSAssumeRoles,
+		AWSCredentialsPath:       config.AWSCredentialsPath,
+		AWSProfile:               config.AWSProfile,
+		RunHistory:               runHistory,
+		Tracer:                   tracer,
+	}
+	commandLimiter := events.NewCommandLimiter(config.MaxConcurrentCommands, config.MaxQueuedCommands)
+	repoAllowlist, err := events.NewRepoAllowlist(config.RepoAllowlist, config.RepoAllowlistComment)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing repo-allowlist")
 	}
 	eventsController := &EventsController{
 		CommandRunner:          commandHandler,
@@ -204,21 +633,189 @@ func NewServer(config Config) (*Server, error) {
 		GitlabRequestParser:    &DefaultGitlabRequestParser{},
 		GitlabWebHookSecret:    []byte(config.GitlabWebHookSecret),
 		SupportedVCSHosts:      supportedVCSHosts,
+		CommandLimiter:         commandLimiter,
+		CommandThrottle:        events.NewCommandThrottle(config.CommandThrottleWindow),
+		RepoAllowlist:          repoAllowlist,
+		VCSClient:              vcsClient,
 	}
 	router := mux.NewRouter()
+	healthzPath := config.HealthzPath
+	if healthzPath == "" {
+		healthzPath = DefaultHealthzPath
+	}
+	readyzPath := config.ReadyzPath
+	if readyzPath == "" {
+		readyzPath = DefaultReadyzPath
+	}
 	return &Server{
 		Router:             router,
 		Port:               config.Port,
+		MetricsPort:        config.MetricsPort,
+		CommandMetrics:     commandMetrics,
 		CommandHandler:     commandHandler,
+		ApplyExecutor:      applyExecutor,
 		Logger:             logger,
 		Locker:             lockingClient,
 		AtlantisURL:        config.AtlantisURL,
 		EventsController:   eventsController,
 		IndexTemplate:      indexTemplate,
 		LockDetailTemplate: lockTemplate,
+		Webhooks:           webhooksManager,
+		CommandLimiter:     commandLimiter,
+		PlanExporter:       planExporter,
+		WorkspaceJanitor:   workspaceJanitor,
+		Build:              BuildInfo{Version: config.Version, Commit: config.Commit, Date: config.BuildDate},
+		HealthzPath:        healthzPath,
+		ReadyzPath:         readyzPath,
+		AdminTokens:        config.AdminTokens,
+		readiness: &readiness{
+			workspaceDirs: workspaceDirs(config),
+			authCheckers:  authCheckers,
+		},
+	}, nil
+}
+
+// newWebhooks returns the sender for the webhooks in config.
+func newWebhooks(config Config) (*webhooks.MultiWebhookSender, error) {
+	var webhooksConfig []webhooks.Config
+	for _, c := range config.Webhooks {
+		config := webhooks.Config{
+			Channel:               c.Channel,
+			Event:                 c.Event,
+			Kind:                  c.Kind,
+			WorkspaceRegex:        c.WorkspaceRegex,
+			URL:                   c.URL,
+			Template:              c.Template,
+			Secret:                c.Secret,
+			ExcludeWorkspaceRegex: c.ExcludeWorkspaceRegex,
+			FailuresOnly:          c.FailuresOnly,
+		}
+		webhooksConfig = append(webhooksConfig, config)
+	}
+	// It's last so the dead letters of the configured webhooks are still
+	// redriven to them.
+	if config.SlackChannel != "" {
+		webhooksConfig = append(webhooksConfig, webhooks.Config{
+			Event:          webhooks.ApplyEvent,
+			Kind:           webhooks.SlackKind,
+			WorkspaceRegex: ".*",
+			Channel:        config.SlackChannel,
+		})
+	}
+	egressHosts, err := egress.NewAllowlist(config.AllowedEgressHosts)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing allowed-egress-hosts")
+	}
+	sender, err := webhooks.NewMultiWebhookSender(webhooksConfig, webhooks.NewSlackClient(config.SlackToken), egressHosts)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing webhooks")
+	}
+	return sender, nil
+}
+
+// newApplyPolicy returns the apply policy in config.
+func newApplyPolicy(config Config) (events.ApplyPolicy, error) {
+	changeWindows := make(map[string]events.ChangeWindow)
+	for env, spec := range config.ChangeWindows {
+		window, err := events.ParseChangeWindow(spec)
+		if err != nil {
+			return events.ApplyPolicy{}, errors.Wrapf(err, "parsing change window for environment %q", env)
+		}
+		changeWindows[env] = window
+	}
+	freezeWindows := make(map[string]events.ChangeWindow)
+	for name, spec := range config.ApplyFreezeWindows {
+		window, err := events.ParseChangeWindow(spec)
+		if err != nil {
+			return events.ApplyPolicy{}, errors.Wrapf(err, "parsing apply freeze window %q", name)
+		}
+		freezeWindows[name] = window
+	}
+	envApprovals := make(map[string]events.EnvApproval)
+	requireExternalApproval := config.RequireExternalApproval
+	for env, spec := range config.EnvironmentApprovals {
+		approval, err := events.ParseEnvApproval(spec)
+		if err != nil {
+			return events.ApplyPolicy{}, errors.Wrapf(err, "parsing approvals for environment %q", env)
+		}
+		envApprovals[env] = approval
+		requireExternalApproval = requireExternalApproval || approval.External
+	}
+	allowedRepos, err := events.NewAllowedRepos(config.AllowedRepos)
+	if err != nil {
+		return events.ApplyPolicy{}, errors.Wrap(err, "parsing allowed-repos")
+	}
+	var verifier *events.ApprovalTokenVerifier
+	if config.ApprovalJWTPublicKey != "" {
+		pemKey, err := ioutil.ReadFile(config.ApprovalJWTPublicKey)
+		if err != nil {
+			return events.ApplyPolicy{}, errors.Wrap(err, "reading approval-jwt-public-key")
+		}
+		verifier, err = events.NewApprovalTokenVerifier(pemKey)
+		if err != nil {
+			return events.ApplyPolicy{}, errors.Wrapf(err, "parsing approval-jwt-public-key %s", config.ApprovalJWTPublicKey)
+		}
+	}
+	approvalTLS, err := events.NewApprovalTLSConfig(config.ApprovalClientCert, config.ApprovalClientKey, config.ApprovalCACert)
+	if err != nil {
+		return events.ApplyPolicy{}, errors.Wrap(err, "building approval client TLS config")
+	}
+	egressHosts, err := egress.NewAllowlist(config.AllowedEgressHosts)
+	if err != nil {
+		return events.ApplyPolicy{}, errors.Wrap(err, "parsing allowed-egress-hosts")
+	}
+	if requireExternalApproval {
+		for _, u := range config.ApprovalURLs {
+			if err := egressHosts.Check(u); err != nil {
+				return events.ApplyPolicy{}, errors.Wrap(err, "checking approval-url")
+			}
+		}
+	}
+	return events.ApplyPolicy{
+		RequireApproval:         config.RequireApproval,
+		RequireExternalApproval: config.RequireExternalApproval,
+		EnvApprovals:            envApprovals,
+		RequiredApprovers:       config.RequiredApprovers,
+		NoSelfApproval:          config.NoSelfApproval,
+		ApprovalURLs:            config.ApprovalURLs,
+		ApprovalQuorum:          config.ExternalApprovalQuorum,
+		ApprovalTimeout:         config.ExternalApprovalTimeout,
+		ApprovalRetries:         config.ExternalApprovalRetries,
+		ApprovalRetryBackoff:    events.DefaultApprovalRetryBackoff,
+		ApprovalTLS:             approvalTLS,
+		ApprovalSigningSecret:   []byte(config.ApprovalSigningSecret),
+		ApprovalTokenVerifier:   verifier,
+		EgressHosts:             egressHosts,
+		ChangeWindows:           changeWindows,
+		FreezeWindows:           freezeWindows,
+		EmergencyUsers:          config.EmergencyApplyUsers,
+		AllowedRepos:            allowedRepos,
+		DestroyConfirmationEnvs: config.DestroyConfirmationEnvs,
 	}, nil
 }
 
+// Reload applies the parts of config that can change without restarting the
+// server: the apply policy (approvals, change and freeze windows, emergency
+// users, allowed repos and destroy confirmation) and the webhook sinks. Everything
+// is validated before anything is replaced so if Reload returns an error the
+// server is unchanged. The other settings in config, ex. credentials and the port, are
+// ignored and need a restart.
+func (s *Server) Reload(config Config) error {
+	applyPolicy, err := newApplyPolicy(config)
+	if err != nil {
+		return err
+	}
+	sender, err := newWebhooks(config)
+	if err != nil {
+		return err
+	}
+	s.ApplyExecutor.SetPolicy(applyPolicy)
+	s.Webhooks.SetWebhooks(sender.Webhooks)
+	s.Logger.Info("reloaded config")
+	s.Logger.Debug("reloaded config is %s", config)
+	return nil
+}
+
 func (s *Server) Start() error {
 	s.Router.HandleFunc("/", s.Index).Methods("GET").MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
 		return r.URL.Path == "/" || r.URL.Path == "/index.html"
@@ -226,7 +823,22 @@ func (s *Server) Start() error {
 	s.Router.PathPrefix("/static/").Handler(http.FileServer(&assetfs.AssetFS{Asset: static.Asset, AssetDir: static.AssetDir, AssetInfo: static.AssetInfo}))
 	s.Router.HandleFunc("/events", s.postEvents).Methods("POST")
 	s.Router.HandleFunc("/locks", s.DeleteLockRoute).Methods("DELETE").Queries("id", "{id:.*}")
+	s.Router.HandleFunc("/locks", s.ListLocks).Methods("GET")
 	s.Router.HandleFunc("/health", s.Health).Methods("GET")
+	s.Router.HandleFunc(s.HealthzPath, s.Healthz).Methods("GET")
+	s.Router.HandleFunc(s.ReadyzPath, s.Readyz).Methods("GET")
+	s.Router.HandleFunc("/version", s.Version).Methods("GET")
+	if s.MetricsPort == 0 {
+		s.Router.HandleFunc("/metrics", s.Metrics).Methods("GET")
+	} else {
+		go s.serveMetrics()
+	}
+	s.Router.HandleFunc("/webhooks/dead-letters", s.ListDeadLetters).Methods("GET")
+	s.Router.HandleFunc("/webhooks/dead-letters/redrive", s.RedriveDeadLetters).Methods("POST")
+	s.Router.HandleFunc("/apply-freeze", s.GetApplyFreeze).Methods("GET")
+	s.Router.HandleFunc("/apply-freeze", s.StartApplyFreeze).Methods("POST")
+	s.Router.HandleFunc("/apply-freeze", s.LiftApplyFreeze).Methods("DELETE")
+	s.Router.HandleFunc("/plan-exports/{id}", s.GetPlanExport).Methods("GET")
 	lockRoute := s.Router.HandleFunc("/lock", s.GetLockRoute).Methods("GET").Queries("id", "{id}").Name(LockRouteName)
 	// function that planExecutor can use to construct detail view url
 	// injecting this here because this is the earliest routes are created
@@ -242,7 +854,11 @@ func (s *Server) Start() error {
 		StackSize:  1024 * 8,
 	}, NewRequestLogger(s.Logger))
 	n.UseHandler(s.Router)
-	s.Logger.Warn("Atlantis started - listening on port %v", s.Port)
+	go s.checkReadinessUntilReady()
+	if s.WorkspaceJanitor != nil {
+		go s.WorkspaceJanitor.Start()
+	}
+	s.Logger.Warn("Atlantis %s started - listening on port %v", s.Build, s.Port)
 	return cli.NewExitError(http.ListenAndServe(fmt.Sprintf(":%d", s.Port), n), 1)
 }
 
@@ -267,11 +883,87 @@ func (s *Server) Index(w http.ResponseWriter, _ *http.Request) {
 	s.IndexTemplate.Execute(w, results) // nolint: errcheck
 }
 
+// LockData is a lock as it's listed by ListLocks.
+type LockData struct {
+	// ID is the lock's id, which DELETE /locks?id= takes.
+	ID          string    `json:"id"`
+	Repo        string    `json:"repo"`
+	Path        string    `json:"path"`
+	Environment string    `json:"environment"`
+	PullNum     int       `json:"pull_num"`
+	PullURL     string    `json:"pull_url"`
+	User        string    `json:"user"`
+	LockedAt    time.Time `json:"locked_at"`
+	// Age is how long the lock has been held, ex. "26h3m5s".
+	Age string `json:"age"`
+}
+
+// ListLocks responds with the locks that are held as JSON, oldest first so
+// stale locks are at the top.
+func (s *Server) ListLocks(w http.ResponseWriter, _ *http.Request) {
+	locks, err := s.Locker.List()
+	if err != nil {
+		s.respond(w, logging.Error, http.StatusServiceUnavailable, "Could not retrieve locks: %s", err)
+		return
+	}
+	now := time.Now()
+	results := []LockData{}
+	for id, l := range locks {
+		results = append(results, LockData{
+			ID:          id,
+			Repo:        l.Project.RepoFullName,
+			Path:        l.Project.Path,
+			Environment: l.Env,
+			PullNum:     l.Pull.Num,
+			PullURL:     l.Pull.URL,
+			User:        l.User.Username,
+			LockedAt:    l.Time,
+			Age:         now.Sub(l.Time).Round(time.Second).String(),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].LockedAt.Equal(results[j].LockedAt) {
+			return results[i].LockedAt.Before(results[j].LockedAt)
+		}
+		return results[i].ID < results[j].ID
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results) // nolint: errcheck
+}
+
 func (s *Server) Health(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	return
 }
 
+// Metrics responds with gauges about the commands being run, the results of
+// plans and applies and the outcomes of external approvals in the Prometheus
+// text format.
+func (s *Server) Metrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeGauge(w, "atlantis_commands_in_flight", "Number of commands that are running.", s.CommandLimiter.InFlight())
+	writeGauge(w, "atlantis_commands_queued", "Number of commands waiting for another command to finish before they can run.", s.CommandLimiter.Queued())
+	s.CommandMetrics.Write(w)
+	if s.ApplyExecutor != nil {
+		s.ApplyExecutor.ApprovalMetrics.Write(w)
+	}
+}
+
+// serveMetrics serves /metrics on MetricsPort so it can be kept off the port
+// that the VCS hosts and users reach.
+func (s *Server) serveMetrics() {
+	router := mux.NewRouter()
+	router.HandleFunc("/metrics", s.Metrics).Methods("GET")
+	s.Logger.Info("serving metrics on port %v", s.MetricsPort)
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", s.MetricsPort), router); err != nil {
+		s.Logger.Err("serving metrics on port %v: %s", s.MetricsPort, err)
+	}
+}
+
+func writeGauge(w io.Writer, name string, help string, value int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+}
+
 func (s *Server) GetLockRoute(w http.ResponseWriter, r *http.Request) {
 	id, ok := mux.Vars(r)["id"]
 	if !ok {
@@ -349,6 +1041,141 @@ func (s *Server) DeleteLock(w http.ResponseWriter, _ *http.Request, id string) {
 	s.respond(w, logging.Info, http.StatusOK, "Deleted lock id %s", idUnencoded)
 }
 
+// ListDeadLetters responds with the webhooks that failed to send as JSON.
+// Only admins can list them since they include the output of each apply.
+func (s *Server) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authenticateAdmin(w, r); !ok {
+		return
+	}
+	letters, err := s.Webhooks.DeadLetters.List()
+	if err != nil {
+		s.respond(w, logging.Error, http.StatusInternalServerError, "Failed to list dead letters: %s", err)
+		return
+	}
+	if letters == nil {
+		letters = []webhooks.DeadLetter{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(letters) // nolint: errcheck
+}
+
+// RedriveDeadLetters re-sends the webhooks that failed to send. Only admins
+// can re-send them.
+func (s *Server) RedriveDeadLetters(w http.ResponseWriter, r *http.Request) {
+	admin, ok := s.authenticateAdmin(w, r)
+	if !ok {
+		return
+	}
+	sent, remaining, err := s.Webhooks.Redrive(r.Context(), s.Logger)
+	if err != nil {
+		s.respond(w, logging.Error, http.StatusInternalServerError, "Failed to re-send dead letters: %s", err)
+		return
+	}
+	s.respond(w, logging.Info, http.StatusOK, "%s re-sent %d webhook(s), %d still failing", admin, sent, remaining)
+}
+
+// GetApplyFreeze responds with the freeze of all applies as JSON, ex.
+// {"frozen":true,"reason":"incident","time":"2017-09-01T00:00:00Z"}, or
+// {"frozen":false} if applies aren't frozen. Scheduled freezes aren't
+// included since they're in the config.
+func (s *Server) GetApplyFreeze(w http.ResponseWriter, _ *http.Request) {
+	response := struct {
+		Frozen bool `json:"frozen"`
+		*events.Freeze
+	}{Freeze: s.ApplyExecutor.Freeze.Current()}
+	response.Frozen = response.Freeze != nil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response) // nolint: errcheck
+}
+
+// StartApplyFreeze freezes all applies with the reason in the request's
+// reason form value until the freeze is lifted. Only admins can freeze
+// applies and the freeze records which one did.
+func (s *Server) StartApplyFreeze(w http.ResponseWriter, r *http.Request) {
+	admin, ok := s.authenticateAdmin(w, r)
+	if !ok {
+		return
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		s.respond(w, logging.Warn, http.StatusBadRequest, "No reason for the freeze in request")
+		return
+	}
+	if _, err := s.ApplyExecutor.Freeze.Start(reason, admin); err != nil {
+		s.respond(w, logging.Error, http.StatusInternalServerError, "Failed to freeze applies: %s", err)
+		return
+	}
+	s.respond(w, logging.Warn, http.StatusOK, "%s froze applies: %s", admin, reason)
+}
+
+// LiftApplyFreeze unfreezes applies. Only admins can lift a freeze.
+func (s *Server) LiftApplyFreeze(w http.ResponseWriter, r *http.Request) {
+	admin, ok := s.authenticateAdmin(w, r)
+	if !ok {
+		return
+	}
+	lifted, err := s.ApplyExecutor.Freeze.Lift()
+	if err != nil {
+		s.respond(w, logging.Error, http.StatusInternalServerError, "Failed to lift the apply freeze: %s", err)
+		return
+	}
+	if lifted == nil {
+		s.respond(w, logging.Info, http.StatusOK, "Applies weren't frozen")
+		return
+	}
+	s.respond(w, logging.Warn, http.StatusOK, "%s lifted the apply freeze by %s: %s", admin, lifted.By, lifted.Reason)
+}
+
+// authenticateAdmin returns the name of the admin whose token is the
+// request's bearer token. If there's no such admin, it responds with why not
+// and returns false.
+func (s *Server) authenticateAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if len(s.AdminTokens) == 0 {
+		s.respond(w, logging.Warn, http.StatusForbidden, "%s %s is disabled since no admin-tokens are configured", r.Method, r.URL.Path)
+		return "", false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	admin := ""
+	if token != "" && token != r.Header.Get("Authorization") {
+		// Every token is compared in constant time so the response time
+		// doesn't give away which one is close.
+		for name, adminToken := range s.AdminTokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+				admin = name
+			}
+		}
+	}
+	if admin == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		s.respond(w, logging.Warn, http.StatusUnauthorized, "%s %s requires an admin token", r.Method, r.URL.Path)
+		return "", false
+	}
+	return admin, true
+}
+
+// GetPlanExport responds with an exported plan file if the link to it is
+// signed and hasn't expired.
+func (s *Server) GetPlanExport(w http.ResponseWriter, r *http.Request) {
+	if s.PlanExporter == nil {
+		s.respond(w, logging.Warn, http.StatusNotFound, "Exporting plans is disabled")
+		return
+	}
+	id := mux.Vars(r)["id"]
+	f, err := s.PlanExporter.Open(id, r.URL.Query().Get("expires"), r.URL.Query().Get("signature"))
+	if err == events.ErrPlanExportNotFound {
+		s.respond(w, logging.Warn, http.StatusNotFound, "%s", err)
+		return
+	}
+	if err != nil {
+		s.respond(w, logging.Error, http.StatusInternalServerError, "Failed to open plan export: %s", err)
+		return
+	}
+	defer f.Close() // nolint: errcheck
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"plan.tfplan\"")
+	io.Copy(w, f) // nolint: errcheck
+}
+
 // postEvents handles POST requests to our /events endpoint. These should be
 // VCS webhook requests.
 func (s *Server) postEvents(w http.ResponseWriter, r *http.Request) {
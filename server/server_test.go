@@ -2,18 +2,23 @@ package server_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/hootsuite/atlantis/server"
+	"github.com/hootsuite/atlantis/server/events"
 	"github.com/hootsuite/atlantis/server/events/locking/mocks"
 	"github.com/hootsuite/atlantis/server/events/models"
+	"github.com/hootsuite/atlantis/server/events/webhooks"
 	"github.com/hootsuite/atlantis/server/logging"
 	sMocks "github.com/hootsuite/atlantis/server/mocks"
 	. "github.com/hootsuite/atlantis/testing"
@@ -30,6 +35,498 @@ func TestNewServer(t *testing.T) {
 	Ok(t, err)
 }
 
+func TestNewServer_InvalidRequiredTerraformVersion(t *testing.T) {
+	t.Log("NewServer should error if a required terraform version can't be parsed")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	_, err = server.NewServer(server.Config{
+		DataDir: tmpDir,
+		RequiredTerraformVersions: map[string]string{
+			"production": "not a constraint",
+		},
+	})
+	Assert(t, err != nil, "expected error")
+	Assert(t, strings.HasPrefix(err.Error(), "parsing required terraform version for environment \"production\""), "unexpected error %s", err)
+}
+
+func TestNewServer_InvalidChangeWindow(t *testing.T) {
+	t.Log("NewServer should error if a change window can't be parsed")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	_, err = server.NewServer(server.Config{
+		DataDir: tmpDir,
+		ChangeWindows: map[string]string{
+			"production": "weekdays",
+		},
+	})
+	Assert(t, err != nil, "expected error")
+	Assert(t, strings.HasPrefix(err.Error(), "parsing change window for environment \"production\""), "unexpected error %s", err)
+}
+
+func TestNewServer_InvalidGithubAppKey(t *testing.T) {
+	t.Log("NewServer should error if the GitHub App's key can't be parsed")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	keyFile := filepath.Join(tmpDir, "app.pem")
+	Ok(t, ioutil.WriteFile(keyFile, []byte("not a key"), 0600))
+	_, err = server.NewServer(server.Config{
+		DataDir:                 tmpDir,
+		GithubAppID:             1,
+		GithubAppInstallationID: 2,
+		GithubAppKeyFile:        keyFile,
+	})
+	Assert(t, err != nil, "expected error")
+	Equals(t, "creating GitHub App client: no PEM encoded key found", err.Error())
+}
+
+func TestNewServer_InvalidGitlabCACert(t *testing.T) {
+	t.Log("NewServer should error if the GitLab CA certificates can't be parsed")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	caFile := filepath.Join(tmpDir, "ca.pem")
+	Ok(t, ioutil.WriteFile(caFile, []byte("not a certificate"), 0600))
+	_, err = server.NewServer(server.Config{
+		DataDir:      tmpDir,
+		GitlabUser:   "user",
+		GitlabToken:  "token",
+		GitlabCACert: caFile,
+	})
+	Assert(t, err != nil, "expected error")
+	Equals(t, "creating GitLab client: no PEM encoded certificates found in "+caFile, err.Error())
+}
+
+func TestReload(t *testing.T) {
+	t.Log("Reload should replace the apply policy")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	s, err := server.NewServer(server.Config{
+		DataDir:      tmpDir,
+		ApprovalURLs: []string{"https://old.example.com"},
+	})
+	Ok(t, err)
+
+	err = s.Reload(server.Config{
+		DataDir:                 tmpDir,
+		ApprovalURLs:            []string{"https://new.example.com"},
+		RequireExternalApproval: true,
+		ChangeWindows:           map[string]string{"production": "Mon-Fri 09:00-17:00"},
+		ApplyFreezeWindows:      map[string]string{"weekend": "Sat-Sun 00:00-00:00"},
+		EmergencyApplyUsers:     []string{"oncall"},
+		AllowedRepos:            []string{"owner/repo"},
+		DestroyConfirmationEnvs: []string{"production"},
+		EnvironmentApprovals:    map[string]string{"dev": "none"},
+	})
+	Ok(t, err)
+	policy := s.ApplyExecutor.Policy()
+	Equals(t, []string{"https://new.example.com"}, policy.ApprovalURLs)
+	Equals(t, true, policy.RequireExternalApproval)
+	Equals(t, "Mon-Fri 09:00-17:00", policy.ChangeWindows["production"].String())
+	Equals(t, "Sat-Sun 00:00-00:00", policy.FreezeWindows["weekend"].String())
+	Equals(t, []string{"oncall"}, policy.EmergencyUsers)
+	Equals(t, false, policy.AllowedRepos.IsAllowed("other/repo"))
+	Equals(t, []string{"production"}, policy.DestroyConfirmationEnvs)
+	Equals(t, map[string]events.EnvApproval{"dev": {}}, policy.EnvApprovals)
+}
+
+func TestReload_Invalid(t *testing.T) {
+	t.Log("Reload should error and keep the current config if the new config is invalid")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	s, err := server.NewServer(server.Config{
+		DataDir:      tmpDir,
+		ApprovalURLs: []string{"https://old.example.com"},
+	})
+	Ok(t, err)
+
+	err = s.Reload(server.Config{
+		DataDir:      tmpDir,
+		ApprovalURLs: []string{"https://new.example.com"},
+		Webhooks:     []server.WebhookConfig{{Event: "apply", Kind: "unknown"}},
+	})
+	Assert(t, err != nil, "expected error")
+	Equals(t, []string{"https://old.example.com"}, s.ApplyExecutor.Policy().ApprovalURLs)
+}
+
+func TestNewServer_SecretVarFileInDataDir(t *testing.T) {
+	t.Log("NewServer should error if a secret var file is inside the data dir")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	_, err = server.NewServer(server.Config{
+		DataDir: tmpDir,
+		SecretVarFiles: map[string]string{
+			"production": filepath.Join(tmpDir, "repos", "secrets.tfvars"),
+		},
+	})
+	Assert(t, err != nil, "expected error")
+	Assert(t, strings.HasPrefix(err.Error(), "secret var file for environment \"production\" must be outside the data dir"), "unexpected error %s", err)
+}
+
+func TestNewServer_InvalidAWSAccount(t *testing.T) {
+	t.Log("NewServer should error if an environment is mapped to something that isn't an AWS account ID")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	_, err = server.NewServer(server.Config{
+		DataDir:     tmpDir,
+		AWSAccounts: map[string]string{"production": "12345"},
+	})
+	Assert(t, err != nil, "expected error")
+	Equals(t, "invalid AWS account ID \"12345\" for the production environment in aws-accounts: must be 12 digits", err.Error())
+}
+
+func TestNewServer_InvalidTerragruntEnvironmentDir(t *testing.T) {
+	t.Log("NewServer should error if an environment is mapped to a directory outside the repo")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	_, err = server.NewServer(server.Config{
+		DataDir:                   tmpDir,
+		TerraformDistribution:     "terragrunt",
+		TerragruntEnvironmentDirs: map[string]string{"production": "../production"},
+	})
+	Assert(t, err != nil, "expected error")
+	Equals(t, "invalid directory \"../production\" for the production environment in terragrunt-environment-dirs: must be relative to the repo root and inside it", err.Error())
+}
+
+func TestNewServer_InvalidRepoMaxProjects(t *testing.T) {
+	t.Log("NewServer should error if a repo's project limit is negative")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	_, err = server.NewServer(server.Config{
+		DataDir:         tmpDir,
+		RepoMaxProjects: map[string]int{"owner/repo": -1},
+	})
+	Assert(t, err != nil, "expected error")
+	Equals(t, "parsing repo-max-projects-per-command: invalid limit -1 for owner/repo: must not be negative", err.Error())
+}
+
+func TestNewServer_InvalidApprovalJWTPublicKey(t *testing.T) {
+	t.Log("NewServer should error if the approval JWT public key isn't a PEM encoded key")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	keyFile := filepath.Join(tmpDir, "approval.pem")
+	Ok(t, ioutil.WriteFile(keyFile, []byte("not a key"), 0600))
+	_, err = server.NewServer(server.Config{
+		DataDir:              tmpDir,
+		ApprovalJWTPublicKey: keyFile,
+	})
+	Assert(t, err != nil, "expected error")
+	Equals(t, fmt.Sprintf("parsing approval-jwt-public-key %s: no PEM encoded key found", keyFile), err.Error())
+}
+
+func TestConfig_StringRedactsSecrets(t *testing.T) {
+	t.Log("printing the config in any format should never show its secrets")
+	c := server.Config{
+		AdminTokens:           map[string]string{"alice": "admin-token-value"},
+		GithubUser:            "gh-user",
+		ApprovalSigningSecret: "approval-secret-value",
+		GithubToken:           "gh-token-value",
+		GithubWebHookSecret:   "gh-secret-value",
+		GitlabToken:           "gitlab-token-value",
+		GitlabWebHookSecret:   "gitlab-secret-value",
+		JiraToken:             "jira-token-value",
+		PlanEncryptionKey:     "plan-key-value",
+		SlackToken:            "slack-token-value",
+		TFCToken:              "tfc-token-value",
+		Webhooks:              []server.WebhookConfig{{Kind: "msteams", URL: "https://example.webhook.office.com/webhook-url-value"}, {Kind: "http", URL: "https://hooks.example.com", Secret: "webhook-secret-value"}},
+	}
+	for _, format := range []string{"%s", "%v", "%+v", "%#v"} {
+		out := fmt.Sprintf(format, c)
+		Assert(t, strings.Contains(out, "gh-user"), "exp %s to print the config but got %s", format, out)
+		Assert(t, strings.Contains(out, server.RedactedValue), "exp %s to show redacted secrets", format)
+		for _, secret := range []string{"admin-token-value", "approval-secret-value", "gh-token-value", "gh-secret-value", "gitlab-token-value", "gitlab-secret-value", "jira-token-value", "plan-key-value", "slack-token-value", "tfc-token-value", "webhook-url-value", "webhook-secret-value"} {
+			Assert(t, !strings.Contains(out, secret), "exp %s to redact %s but got %s", format, secret, out)
+		}
+	}
+	Equals(t, "https://example.webhook.office.com/webhook-url-value", c.Webhooks[0].URL)
+	Equals(t, "admin-token-value", c.AdminTokens["alice"])
+}
+
+func TestNewServer_ApprovalURLNotAllowed(t *testing.T) {
+	t.Log("NewServer should error if the approval url's host isn't an allowed egress host")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	_, err = server.NewServer(server.Config{
+		DataDir:                 tmpDir,
+		RequireExternalApproval: true,
+		ApprovalURLs:            []string{"https://evil.example.org/approve"},
+		AllowedEgressHosts:      []string{"approvals.example.com"},
+	})
+	Assert(t, err != nil, "expected error")
+	Equals(t, `checking approval-url: host "evil.example.org" isn't an allowed egress host`, err.Error())
+}
+
+func TestNewServer_InvalidGitflowBranchMap(t *testing.T) {
+	t.Log("NewServer should error if an environment is mapped to more than one branch")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	_, err = server.NewServer(server.Config{
+		DataDir:                 tmpDir,
+		EnvDetectionWorkflow:    "gitflow",
+		GitflowEnvBranchMapping: []string{"prod:master", "prod:release"},
+	})
+	Assert(t, err != nil, "expected error")
+	Equals(t, `parsing gitflow-environment-branch-map: environment "prod" is mapped by both "prod:master" and "prod:release"`, err.Error())
+}
+
+func TestNewServer_InvalidEnvDataDir(t *testing.T) {
+	t.Log("NewServer should error if an environment's data dir is inside the data dir")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	_, err = server.NewServer(server.Config{
+		DataDir:     tmpDir,
+		EnvDataDirs: map[string]string{"production": filepath.Join(tmpDir, "production")},
+	})
+	Assert(t, err != nil, "expected error")
+	Equals(t, fmt.Sprintf("validating environment-data-dirs: data dir for environment \"production\" must be outside the data dir %s", tmpDir), err.Error())
+}
+
+func TestNewServer_StartupVCSCheck(t *testing.T) {
+	t.Log("NewServer should only error on unreachable VCS hosts if the startup check is fail")
+	// Nothing is listening on this port so the check fails straight away.
+	ghHostname := "127.0.0.1:1"
+	for mode, expErr := range map[string]bool{"fail": true, "warn": false, "skip": false} {
+		tmpDir, err := ioutil.TempDir("", "")
+		Ok(t, err)
+		_, err = server.NewServer(server.Config{
+			DataDir:         tmpDir,
+			GithubHostname:  ghHostname,
+			GithubUser:      "user",
+			GithubToken:     "token",
+			StartupVCSCheck: mode,
+		})
+		if expErr {
+			Assert(t, err != nil, "expected error for mode %s", mode)
+			Assert(t, strings.HasPrefix(err.Error(), "checking Github credentials"), "unexpected error %s", err)
+		} else {
+			Ok(t, err)
+		}
+	}
+}
+
+func TestListLocks(t *testing.T) {
+	t.Log("list locks should return the locks as JSON, oldest first")
+	RegisterMockTestingT(t)
+	l := mocks.NewMockLocker()
+	now := time.Now()
+	When(l.List()).ThenReturn(map[string]models.ProjectLock{
+		"owner/repo/./staging": {
+			Project: models.NewProject("owner/repo", "."),
+			Pull:    models.PullRequest{Num: 2, URL: "https://github.com/owner/repo/pull/2"},
+			User:    models.User{Username: "bob"},
+			Env:     "staging",
+			Time:    now.Add(-time.Minute),
+		},
+		"owner/repo/./production": {
+			Project: models.NewProject("owner/repo", "."),
+			Pull:    models.PullRequest{Num: 1, URL: "https://github.com/owner/repo/pull/1"},
+			User:    models.User{Username: "alice"},
+			Env:     "production",
+			Time:    now.Add(-26 * time.Hour),
+		},
+	}, nil)
+	s := server.Server{Locker: l}
+	w := httptest.NewRecorder()
+	s.ListLocks(w, nil)
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+
+	var locks []server.LockData
+	Ok(t, json.NewDecoder(w.Result().Body).Decode(&locks))
+	Equals(t, 2, len(locks))
+	Equals(t, "owner/repo/./production", locks[0].ID)
+	Equals(t, "owner/repo", locks[0].Repo)
+	Equals(t, ".", locks[0].Path)
+	Equals(t, "production", locks[0].Environment)
+	Equals(t, 1, locks[0].PullNum)
+	Equals(t, "https://github.com/owner/repo/pull/1", locks[0].PullURL)
+	Equals(t, "alice", locks[0].User)
+	Equals(t, "26h0m0s", locks[0].Age)
+	Equals(t, "owner/repo/./staging", locks[1].ID)
+	Equals(t, "1m0s", locks[1].Age)
+}
+
+func TestListLocks_Err(t *testing.T) {
+	t.Log("list locks should return a 503 if unable to list locks")
+	RegisterMockTestingT(t)
+	l := mocks.NewMockLocker()
+	When(l.List()).ThenReturn(nil, errors.New("err"))
+	s := server.Server{Locker: l, Logger: logging.NewNoopLogger()}
+	w := httptest.NewRecorder()
+	s.ListLocks(w, nil)
+	responseContains(t, w, http.StatusServiceUnavailable, "Could not retrieve locks: err")
+}
+
+func TestHealthz(t *testing.T) {
+	t.Log("healthz should return a 200 with the version")
+	s := server.Server{Build: server.BuildInfo{Version: "0.2.0"}}
+	w := httptest.NewRecorder()
+	s.Healthz(w, nil)
+	responseContains(t, w, http.StatusOK, `{"status":"ok","version":"0.2.0"}`)
+}
+
+func TestReadyz(t *testing.T) {
+	t.Log("readyz should return a 503 until the data dir and VCS credentials have been checked")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	s, err := server.NewServer(server.Config{
+		DataDir: tmpDir,
+		Version: "0.2.0",
+	})
+	Ok(t, err)
+	w := httptest.NewRecorder()
+	s.Readyz(w, nil)
+	responseContains(t, w, http.StatusServiceUnavailable, `{"status":"not ready","version":"0.2.0"}`)
+
+	Ok(t, s.CheckReadiness())
+	w = httptest.NewRecorder()
+	s.Readyz(w, nil)
+	responseContains(t, w, http.StatusOK, `{"status":"ready","version":"0.2.0"}`)
+}
+
+func TestReadyz_VCSUnreachable(t *testing.T) {
+	t.Log("readyz should return a 503 if the VCS credentials can't be checked")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	s, err := server.NewServer(server.Config{
+		DataDir: tmpDir,
+		// Nothing is listening on this port so the check fails straight away.
+		GithubHostname: "127.0.0.1:1",
+		GithubUser:     "user",
+		GithubToken:    "token",
+	})
+	Ok(t, err)
+	err = s.CheckReadiness()
+	Assert(t, err != nil, "exp an error")
+	Assert(t, strings.HasPrefix(err.Error(), "checking Github credentials"), "unexpected error %s", err)
+	w := httptest.NewRecorder()
+	s.Readyz(w, nil)
+	Equals(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+}
+
+func TestVersion(t *testing.T) {
+	t.Log("version should return the build info")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	s, err := server.NewServer(server.Config{
+		DataDir:   tmpDir,
+		Version:   "0.2.0",
+		Commit:    "1a2b3c4",
+		BuildDate: "2017-09-01T00:00:00Z",
+	})
+	Ok(t, err)
+	w := httptest.NewRecorder()
+	s.Version(w, nil)
+	responseContains(t, w, http.StatusOK, `{"version":"0.2.0","commit":"1a2b3c4","date":"2017-09-01T00:00:00Z"}`)
+}
+
+func TestApplyFreeze(t *testing.T) {
+	t.Log("admins should be able to freeze and unfreeze applies through /apply-freeze")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	s, err := server.NewServer(server.Config{
+		DataDir:     tmpDir,
+		AdminTokens: map[string]string{"alice": "alice-token", "bob": "bob-token"},
+	})
+	Ok(t, err)
+
+	w := httptest.NewRecorder()
+	s.StartApplyFreeze(w, adminRequest("POST", "/apply-freeze", "", "alice-token"))
+	responseContains(t, w, http.StatusBadRequest, "No reason for the freeze in request")
+
+	w = httptest.NewRecorder()
+	s.StartApplyFreeze(w, adminRequest("POST", "/apply-freeze", "reason=incident+42", "alice-token"))
+	responseContains(t, w, http.StatusOK, "alice froze applies: incident 42")
+	w = httptest.NewRecorder()
+	s.GetApplyFreeze(w, nil)
+	responseContains(t, w, http.StatusOK, `{"frozen":true,"reason":"incident 42","by":"alice","time":`)
+
+	w = httptest.NewRecorder()
+	s.LiftApplyFreeze(w, adminRequest("DELETE", "/apply-freeze", "", "bob-token"))
+	responseContains(t, w, http.StatusOK, "bob lifted the apply freeze by alice: incident 42")
+	w = httptest.NewRecorder()
+	s.GetApplyFreeze(w, nil)
+	responseContains(t, w, http.StatusOK, `{"frozen":false}`)
+}
+
+func TestApplyFreeze_RequiresAdmin(t *testing.T) {
+	t.Log("only admins should be able to freeze or unfreeze applies")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	s, err := server.NewServer(server.Config{DataDir: tmpDir, AdminTokens: map[string]string{"alice": "alice-token"}})
+	Ok(t, err)
+
+	for _, token := range []string{"", "wrong-token"} {
+		w := httptest.NewRecorder()
+		s.StartApplyFreeze(w, adminRequest("POST", "/apply-freeze", "reason=incident+42", token))
+		responseContains(t, w, http.StatusUnauthorized, "POST /apply-freeze requires an admin token")
+		Equals(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	}
+	Assert(t, s.ApplyExecutor.Freeze.Current() == nil, "exp no freeze")
+
+	_, err = s.ApplyExecutor.Freeze.Start("incident 42", "alice")
+	Ok(t, err)
+	w := httptest.NewRecorder()
+	s.LiftApplyFreeze(w, adminRequest("DELETE", "/apply-freeze", "", "alice"))
+	responseContains(t, w, http.StatusUnauthorized, "DELETE /apply-freeze requires an admin token")
+	Assert(t, s.ApplyExecutor.Freeze.Current() != nil, "exp the freeze not to be lifted")
+
+	t.Log("without admin tokens the endpoints should be disabled")
+	s.AdminTokens = nil
+	w = httptest.NewRecorder()
+	s.LiftApplyFreeze(w, adminRequest("DELETE", "/apply-freeze", "", "alice-token"))
+	responseContains(t, w, http.StatusForbidden, "DELETE /apply-freeze is disabled since no admin-tokens are configured")
+	Assert(t, s.ApplyExecutor.Freeze.Current() != nil, "exp the freeze not to be lifted")
+}
+
+// adminRequest returns a request with the form body authenticated with
+// token, or not authenticated if it's empty.
+func adminRequest(method string, target string, body string, token string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestDeadLetters(t *testing.T) {
+	t.Log("admins should be able to list and re-send the webhooks that failed to send")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	s, err := server.NewServer(server.Config{DataDir: tmpDir, AdminTokens: map[string]string{"alice": "alice-token"}})
+	Ok(t, err)
+	Ok(t, s.Webhooks.DeadLetters.Append(webhooks.DeadLetter{Webhook: 0, Result: webhooks.ApplyResult{Workspace: "production"}, Error: "sink down"}))
+
+	w := httptest.NewRecorder()
+	s.ListDeadLetters(w, adminRequest("GET", "/webhooks/dead-letters", "", "alice-token"))
+	responseContains(t, w, http.StatusOK, `"error":"sink down"`)
+
+	w = httptest.NewRecorder()
+	s.RedriveDeadLetters(w, adminRequest("POST", "/webhooks/dead-letters/redrive", "", "alice-token"))
+	responseContains(t, w, http.StatusOK, "alice re-sent 0 webhook(s), 1 still failing")
+}
+
+func TestDeadLetters_RequiresAdmin(t *testing.T) {
+	t.Log("only admins should be able to list or re-send dead letters since they include the apply output")
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	s, err := server.NewServer(server.Config{DataDir: tmpDir, AdminTokens: map[string]string{"alice": "alice-token"}})
+	Ok(t, err)
+	Ok(t, s.Webhooks.DeadLetters.Append(webhooks.DeadLetter{Webhook: 0, Result: webhooks.ApplyResult{Workspace: "production"}, Error: "sink down"}))
+
+	for _, token := range []string{"", "wrong-token"} {
+		w := httptest.NewRecorder()
+		s.ListDeadLetters(w, adminRequest("GET", "/webhooks/dead-letters", "", token))
+		responseContains(t, w, http.StatusUnauthorized, "GET /webhooks/dead-letters requires an admin token")
+		Assert(t, !strings.Contains(w.Body.String(), "sink down"), "exp no dead letters in the response")
+
+		w = httptest.NewRecorder()
+		s.RedriveDeadLetters(w, adminRequest("POST", "/webhooks/dead-letters/redrive", "", token))
+		responseContains(t, w, http.StatusUnauthorized, "POST /webhooks/dead-letters/redrive requires an admin token")
+	}
+	letters, err := s.Webhooks.DeadLetters.List()
+	Ok(t, err)
+	Equals(t, 1, len(letters))
+}
+
 func TestIndex_LockErr(t *testing.T) {
 	t.Log("index should return a 503 if unable to list locks")
 	RegisterMockTestingT(t)
@@ -85,6 +582,29 @@ func TestIndex_Success(t *testing.T) {
 	responseContains(t, w, http.StatusOK, "")
 }
 
+func TestMetrics(t *testing.T) {
+	t.Log("metrics should include the number of commands in flight and queued and the results of applies")
+	limiter := events.NewCommandLimiter(1, 1)
+	release := make(chan struct{})
+	defer close(release)
+	limiter.Go(func() { <-release })
+	limiter.Go(func() {})
+	for i := 0; i < 100 && limiter.InFlight() != 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	metrics := events.NewCommandMetrics()
+	metrics.ObserveResult(events.Apply, "production", events.ProjectResult{Failure: "failed"})
+	s := server.Server{CommandLimiter: limiter, CommandMetrics: metrics}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	w := httptest.NewRecorder()
+	s.Metrics(w, req)
+	Equals(t, http.StatusOK, w.Result().StatusCode)
+	body, _ := ioutil.ReadAll(w.Result().Body)
+	Assert(t, strings.Contains(string(body), "atlantis_commands_in_flight 1\n"), "missing in flight gauge in %q", string(body))
+	Assert(t, strings.Contains(string(body), "atlantis_commands_queued 1\n"), "missing queued gauge in %q", string(body))
+	Assert(t, strings.Contains(string(body), `atlantis_apply_total{result="failure",environment="production"} 1`+"\n"), "missing apply counter in %q", string(body))
+}
+
 func TestGetLockRoute_NoLockID(t *testing.T) {
 	t.Log("If there is no lock ID in the request then we should get a 400")
 	eventsReq, _ = http.NewRequest("GET", "", bytes.NewBuffer(nil))
@@ -0,0 +1,292 @@
+// Package tracing records spans for the phases of a command and sends them to
+// an OpenTelemetry collector using OTLP over HTTP with JSON encoding.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hootsuite/atlantis/server/logging"
+	"github.com/pkg/errors"
+)
+
+// serviceName is the service.name resource attribute of our spans.
+const serviceName = "atlantis"
+
+// batchSize is how many ended spans we buffer before sending them.
+const batchSize = 100
+
+// flushInterval is how often we send spans even if the batch isn't full.
+const flushInterval = 5 * time.Second
+
+// OTLP status codes.
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+// spanKindInternal is the OTLP span kind for work that isn't a request to or
+// from another service.
+const spanKindInternal = 1
+
+type spanKey struct{}
+
+// Tracer creates spans and sends them to an OTLP collector in batches. A nil
+// Tracer doesn't record anything so tracing is disabled by not creating one.
+type Tracer struct {
+	// Endpoint is the URL of the collector's traces endpoint, ex.
+	// http://localhost:4318/v1/traces.
+	Endpoint string
+	Client   *http.Client
+	Logger   logging.SimpleLogging
+
+	mutex sync.Mutex
+	spans []*Span
+}
+
+// NewTracer returns a Tracer that sends spans to the collector at endpoint,
+// ex. http://localhost:4318, every few seconds.
+func NewTracer(endpoint string, logger logging.SimpleLogging) *Tracer {
+	t := &Tracer{
+		Endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		Logger:   logger,
+	}
+	go func() {
+		for range time.Tick(flushInterval) {
+			t.Flush()
+		}
+	}()
+	return t
+}
+
+// Start starts a new trace with a root span called name. The returned context
+// carries the span so phases can create children of it with Start.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		tracer:  t,
+		traceID: randomHex(16),
+		spanID:  randomHex(8),
+		name:    name,
+		start:   time.Now(),
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Start starts a span called name that's a child of the span in ctx. If ctx
+// doesn't have a span, ex. because tracing is disabled, it returns a nil Span
+// which records nothing.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	if ctx == nil {
+		return ctx, nil
+	}
+	parent, ok := ctx.Value(spanKey{}).(*Span)
+	if !ok || parent == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		tracer:   parent.tracer,
+		traceID:  parent.traceID,
+		spanID:   randomHex(8),
+		parentID: parent.spanID,
+		name:     name,
+		start:    time.Now(),
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Flush sends the spans that have ended so far.
+func (t *Tracer) Flush() {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mutex.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	if err := t.export(spans); err != nil {
+		t.Logger.Warn("dropping %d trace spans: %s", len(spans), err)
+	}
+}
+
+func (t *Tracer) end(s *Span) {
+	t.mutex.Lock()
+	t.spans = append(t.spans, s)
+	full := len(t.spans) >= batchSize
+	t.mutex.Unlock()
+	if full {
+		go t.Flush()
+	}
+}
+
+func (t *Tracer) export(spans []*Span) error {
+	var otlpSpans []otlpSpan
+	for _, s := range spans {
+		otlpSpans = append(otlpSpans, s.otlp())
+	}
+	body, err := json.Marshal(otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{Attributes: []otlpAttribute{stringAttribute("service.name", serviceName)}},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: serviceName},
+				Spans: otlpSpans,
+			}},
+		}},
+	})
+	if err != nil {
+		return errors.Wrap(err, "serializing spans")
+	}
+	resp, err := t.Client.Post(t.Endpoint, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return errors.Wrapf(err, "posting to %s", t.Endpoint)
+	}
+	resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("posting to %s: got status %d", t.Endpoint, resp.StatusCode)
+	}
+	return nil
+}
+
+// Span is one phase of a command. A nil Span records nothing so callers don't
+// need to check if tracing is enabled. A Span must only be used by one
+// goroutine.
+type Span struct {
+	tracer     *Tracer
+	traceID    string
+	spanID     string
+	parentID   string
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes []otlpAttribute
+	err        string
+}
+
+// SetAttribute adds an attribute to the span, ex. the repo the command was
+// run on.
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+	s.attributes = append(s.attributes, stringAttribute(key, value))
+}
+
+// SetError marks the span as failed with err.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.err = err.Error()
+}
+
+// TraceID returns the ID of the trace the span is in or an empty string if
+// the span is nil.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.traceID
+}
+
+// End ends the span and queues it to be sent.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.tracer.end(s)
+}
+
+func (s *Span) otlp() otlpSpan {
+	span := otlpSpan{
+		TraceID:           s.traceID,
+		SpanID:            s.spanID,
+		ParentSpanID:      s.parentID,
+		Name:              s.name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes:        s.attributes,
+		Status:            otlpStatus{Code: statusUnset},
+	}
+	if s.err != "" {
+		span.Status = otlpStatus{Code: statusError, Message: s.err}
+	}
+	return span
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b) // nolint: errcheck
+	return hex.EncodeToString(b)
+}
+
+func stringAttribute(key string, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: value}}
+}
+
+// The types below are the parts of the OTLP JSON encoding that we use.
+// See https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
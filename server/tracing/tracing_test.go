@@ -0,0 +1,65 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hootsuite/atlantis/server/logging"
+	"github.com/hootsuite/atlantis/server/tracing"
+	. "github.com/hootsuite/atlantis/testing"
+)
+
+func TestTracer_Export(t *testing.T) {
+	t.Log("ended spans should be sent to the collector as OTLP JSON with their parent and attributes")
+	var req map[string]interface{}
+	var path string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &req) // nolint: errcheck
+	}))
+	defer collector.Close()
+
+	tracer := tracing.NewTracer(collector.URL, logging.NewNoopLogger())
+	ctx, root := tracer.Start(context.Background(), "command")
+	root.SetAttribute("atlantis.repo", "owner/repo")
+	_, child := tracing.Start(ctx, "plan")
+	child.SetError(errors.New("plan failed"))
+	child.End()
+	root.End()
+	tracer.Flush()
+
+	Equals(t, "/v1/traces", path)
+	spans := req["resourceSpans"].([]interface{})[0].(map[string]interface{})["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	Equals(t, 2, len(spans))
+	plan := spans[0].(map[string]interface{})
+	command := spans[1].(map[string]interface{})
+	Equals(t, "plan", plan["name"])
+	Equals(t, root.TraceID(), plan["traceId"])
+	Equals(t, command["spanId"], plan["parentSpanId"])
+	Equals(t, map[string]interface{}{"code": float64(2), "message": "plan failed"}, plan["status"])
+	Equals(t, "command", command["name"])
+	Equals(t, nil, command["parentSpanId"])
+	Equals(t, []interface{}{map[string]interface{}{"key": "atlantis.repo", "value": map[string]interface{}{"stringValue": "owner/repo"}}}, command["attributes"])
+}
+
+func TestTracer_Disabled(t *testing.T) {
+	t.Log("a nil tracer should return nil spans that are safe to use")
+	var tracer *tracing.Tracer
+	ctx, root := tracer.Start(context.Background(), "command")
+	Assert(t, root == nil, "expected a nil span")
+	root.SetAttribute("key", "value")
+	root.SetError(errors.New("err"))
+	root.End()
+	Equals(t, "", root.TraceID())
+
+	_, child := tracing.Start(ctx, "plan")
+	Assert(t, child == nil, "expected a nil child span")
+	child.End()
+	tracer.Flush()
+}
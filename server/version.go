@@ -0,0 +1,27 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// BuildInfo identifies the build of Atlantis that's running. It's embedded
+// into the binary at build time with -ldflags.
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+}
+
+// String returns the build info as it's shown in logs and by the version
+// command, ex. 0.2.0 (commit 1a2b3c4, built 2017-09-01T00:00:00Z).
+func (b BuildInfo) String() string {
+	return fmt.Sprintf("%s (commit %s, built %s)", b.Version, b.Commit, b.Date)
+}
+
+// Version responds with the build info as JSON.
+func (s *Server) Version(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Build) // nolint: errcheck
+}